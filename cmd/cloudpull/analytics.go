@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var analyticsCmd = &cobra.Command{
+	Use:   "analytics <session-id>",
+	Short: "Show the transfer throughput of a session over time",
+	Long: `Show how fast a session downloaded, in time buckets from its start.
+
+Each bucket lists the files and bytes completed in it, the throughput and
+files per minute, and the cumulative bytes so far. The data is read from the
+state database, so it works for finished sessions too.
+
+With --csv the buckets are written to a CSV file for charting. Lines starting
+with # before the header hold the session start and end, and the average and
+peak speeds.`,
+	Example: `  # Show one-minute buckets of a session
+  cloudpull analytics abc123
+
+  # Export ten-second buckets for charting
+  cloudpull analytics abc123 --csv throughput.csv --interval 10s`,
+	Args: cobra.ExactArgs(1),
+	RunE: runAnalytics,
+}
+
+var (
+	analyticsCSV      string
+	analyticsInterval = time.Minute
+)
+
+func init() {
+	analyticsCmd.Flags().StringVar(&analyticsCSV, "csv", "",
+		"Write the buckets to this CSV file (- for stdout)")
+	analyticsCmd.Flags().Var((*util.DurationValue)(&analyticsInterval), "interval",
+		"Length of one time bucket, at least 1s (e.g. 10s, 1h or 1d)")
+}
+
+func runAnalytics(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+	if session == nil {
+		return fmt.Errorf("session not found: %s", args[0])
+	}
+
+	stats, err := application.GetTransferStats(ctx, session.ID, analyticsInterval)
+	if err != nil {
+		return err
+	}
+	summary := state.SummarizeTransfers(session, stats)
+
+	switch analyticsCSV {
+	case "":
+		printTransferStats(session, summary, stats)
+		return nil
+	case "-":
+		return writeTransferCSV(os.Stdout, summary, stats)
+	}
+
+	file, err := os.Create(analyticsCSV)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	if err := writeTransferCSV(file, summary, stats); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+
+	fmt.Println(color.GreenString("✓ Wrote %d buckets to %s", len(stats), analyticsCSV))
+	return nil
+}
+
+// writeTransferCSV writes the summary as # lines followed by one row per bucket.
+func writeTransferCSV(w io.Writer, summary *state.TransferSummary, stats []*state.TransferStats) error {
+	end := ""
+	if summary.EndTime.Valid {
+		end = summary.EndTime.Time.UTC().Format(time.RFC3339)
+	}
+
+	header := fmt.Sprintf("# session_start=%s\n# session_end=%s\n# duration_seconds=%.0f\n"+
+		"# completed_files=%d\n# completed_bytes=%d\n"+
+		"# average_bytes_per_second=%.2f\n# peak_bytes_per_second=%.2f\n",
+		summary.StartTime.UTC().Format(time.RFC3339), end, summary.Duration.Seconds(),
+		summary.CompletedFiles, summary.CompletedBytes,
+		summary.AverageBytesPerSecond, summary.PeakBytesPerSecond)
+	if _, err := io.WriteString(w, header); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{
+		"timestamp", "files", "bytes", "bytes_per_second", "files_per_minute",
+		"cumulative_files", "cumulative_bytes",
+	})
+	for _, bucket := range stats {
+		_ = writer.Write([]string{
+			bucket.Timestamp.UTC().Format(time.RFC3339),
+			strconv.FormatInt(bucket.Files, 10),
+			strconv.FormatInt(bucket.Bytes, 10),
+			strconv.FormatFloat(bucket.BytesPerSecond, 'f', 2, 64),
+			strconv.FormatFloat(bucket.FilesPerMinute, 'f', 2, 64),
+			strconv.FormatInt(bucket.CumulativeFiles, 10),
+			strconv.FormatInt(bucket.CumulativeBytes, 10),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
+
+// printTransferStats renders the summary and buckets for the terminal.
+func printTransferStats(session *state.Session, summary *state.TransferSummary, stats []*state.TransferStats) {
+	fmt.Printf("Session:  %s (%s)\n", session.ID, session.Status)
+	fmt.Printf("Started:  %s\n", summary.StartTime.Local().Format("2006-01-02 15:04:05"))
+	if summary.EndTime.Valid {
+		fmt.Printf("Ended:    %s\n", summary.EndTime.Time.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("Duration: %s\n", summary.Duration.Round(time.Second))
+	fmt.Printf("Data:     %d files, %s\n", summary.CompletedFiles, util.FormatBytes(summary.CompletedBytes))
+	fmt.Printf("Speed:    %s/s average, %s/s peak\n\n",
+		util.FormatBytes(int64(summary.AverageBytesPerSecond)), util.FormatBytes(int64(summary.PeakBytesPerSecond)))
+
+	if len(stats) == 0 {
+		fmt.Println("No completed downloads recorded for this session")
+		return
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Time", "Files", "Data", "Speed", "Files/min", "Total"})
+	for _, bucket := range stats {
+		t.AppendRow(table.Row{
+			bucket.Timestamp.Local().Format("15:04:05"),
+			bucket.Files,
+			util.FormatBytes(bucket.Bytes),
+			util.FormatBytes(int64(bucket.BytesPerSecond)) + "/s",
+			fmt.Sprintf("%.1f", bucket.FilesPerMinute),
+			util.FormatBytes(bucket.CumulativeBytes),
+		})
+	}
+	t.Render()
+}
@@ -3,6 +3,11 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -16,25 +21,48 @@ var authCmd = &cobra.Command{
 	Long: `Authenticate CloudPull with Google Drive.
 
 This command initiates the OAuth2 authentication flow to authorize
-CloudPull to access your Google Drive files.`,
+CloudPull to access your Google Drive files. The consent page opens in
+your browser and CloudPull receives the authorization on a temporary
+local server. Without a browser, the authorization code is pasted instead.`,
 	Example: `  # Start authentication
   cloudpull auth
 
   # Re-authenticate (replace existing credentials)
-  cloudpull auth --force`,
+  cloudpull auth --force
+
+  # Authenticate on a machine without a browser
+  cloudpull auth --no-browser`,
+	RunE: runAuth,
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Authorize CloudPull in the browser",
+	Long: `Open the Google consent page in the browser and capture the
+authorization on a temporary local server, with no code to copy.
+
+Falls back to pasting the authorization code when no browser can be opened.`,
 	RunE: runAuth,
 }
 
 var (
-	forceAuth  bool
-	revokeAuth bool
+	forceAuth     bool
+	revokeAuth    bool
+	authNoBrowser bool
 )
 
+// authTimeout bounds how long the browser flow waits for the consent page.
+const authTimeout = 5 * time.Minute
+
 func init() {
-	authCmd.Flags().BoolVar(&forceAuth, "force", false,
+	authCmd.PersistentFlags().BoolVar(&forceAuth, "force", false,
 		"Force re-authentication even if already authenticated")
+	authCmd.PersistentFlags().BoolVar(&authNoBrowser, "no-browser", false,
+		"Paste the authorization code instead of opening a browser")
 	authCmd.Flags().BoolVar(&revokeAuth, "revoke", false,
 		"Revoke current authentication")
+
+	authCmd.AddCommand(authLoginCmd)
 }
 
 func runAuth(cmd *cobra.Command, args []string) error {
@@ -75,6 +103,61 @@ func runAuth(cmd *cobra.Command, args []string) error {
 	// Perform authentication
 	fmt.Println(color.CyanString("🔐 CloudPull Authentication"))
 	fmt.Println()
+
+	if err := authenticate(ctx, application, authNoBrowser); err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(color.GreenString("✅ Authentication successful!"))
+	fmt.Println()
+	fmt.Println("CloudPull is now authorized to access your Google Drive.")
+	fmt.Println("You can start syncing with 'cloudpull sync'")
+
+	return nil
+}
+
+// authenticate runs the browser flow with a local callback server, or the
+// manual flow when noBrowser is set or no browser can be opened.
+func authenticate(ctx context.Context, application *app.App, noBrowser bool) error {
+	if noBrowser {
+		return authenticateManually(ctx, application)
+	}
+
+	flow, err := application.StartLocalAuth()
+	if err != nil {
+		fmt.Println(color.YellowString("⚠️  %v", err))
+		return authenticateManually(ctx, application)
+	}
+	defer flow.Close()
+
+	if err := openBrowser(flow.URL); err != nil {
+		fmt.Println(color.YellowString("⚠️  Could not open a browser: %v", err))
+		return authenticateManually(ctx, application)
+	}
+
+	fmt.Println("Opened the Google consent page in your browser.")
+	fmt.Printf("If it did not open, visit:\n%s\n\n", flow.URL)
+	fmt.Println("Waiting for authorization...")
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, authTimeout)
+	defer cancel()
+
+	if err := application.FinishLocalAuth(ctx, flow); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("authentication timed out after %v", authTimeout)
+		}
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+
+	return nil
+}
+
+// authenticateManually shows the consent URL and reads the authorization
+// code pasted by the user.
+func authenticateManually(ctx context.Context, application *app.App) error {
 	fmt.Println("Starting OAuth2 authentication flow...")
 	fmt.Println()
 
@@ -115,11 +198,22 @@ func runAuth(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
 
-	fmt.Println()
-	fmt.Println(color.GreenString("✅ Authentication successful!"))
-	fmt.Println()
-	fmt.Println("CloudPull is now authorized to access your Google Drive.")
-	fmt.Println("You can start syncing with 'cloudpull sync'")
-
 	return nil
 }
+
+// openBrowser opens url in the default browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return fmt.Errorf("no graphical display")
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Clean up stuck sync sessions",
+	Long: `Mark sync sessions that are still recorded as active but are no
+longer running as canceled.
+
+Sessions can be left in the active state when CloudPull is terminated
+abruptly (for example by a crash or a forced exit). Events recorded with
+sync.persist_events that are older than sync.events_retention days are
+deleted as well.`,
+	Example: `  # Clean up stuck sessions with confirmation
+  cloudpull cleanup
+
+  # Clean up without prompting
+  cloudpull cleanup --yes`,
+	RunE: runCleanup,
+}
+
+var cleanupNoConfirm bool
+
+func init() {
+	cleanupCmd.Flags().BoolVarP(&cleanupNoConfirm, "yes", "y", false,
+		"Skip confirmation prompt")
+}
+
+func runCleanup(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	fmt.Println(color.CyanString("🧹 CloudPull Cleanup"))
+	fmt.Println()
+
+	pruned, err := application.PruneEvents(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to prune events: %w", err)
+	}
+	if pruned > 0 {
+		fmt.Println(color.GreenString("✓ Deleted %d old event(s)", pruned))
+	}
+
+	sessions, err := application.GetAllSessions()
+	if err != nil {
+		return fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	var stuck []*state.Session
+	for _, session := range sessions {
+		if session.Status == state.SessionStatusActive && !application.IsSessionRunning(session.ID) {
+			stuck = append(stuck, session)
+		}
+	}
+
+	if len(stuck) == 0 {
+		fmt.Println(color.GreenString("✓ No stuck sessions found"))
+		return nil
+	}
+
+	fmt.Printf("Found %d stuck session(s):\n", len(stuck))
+	for _, session := range stuck {
+		fmt.Printf("  • %s (started %s)\n", session.ID, session.StartTime.Format("Jan 2 15:04"))
+	}
+	fmt.Println()
+
+	if !cleanupNoConfirm {
+		var proceed bool
+		prompt := &survey.Confirm{
+			Message: "Mark these sessions as canceled?",
+			Default: true,
+		}
+		if err := survey.AskOne(prompt, &proceed); err != nil {
+			return fmt.Errorf("failed to get user confirmation: %w", err)
+		}
+		if !proceed {
+			return nil
+		}
+	}
+
+	cleaned := 0
+	for _, session := range stuck {
+		if err := application.CleanupSession(session.ID); err != nil {
+			fmt.Printf("%s Failed to clean up session %s: %v\n", color.RedString("❌"), session.ID, err)
+			continue
+		}
+		cleaned++
+	}
+
+	fmt.Println(color.GreenString("✓ Cleaned up %d session(s)", cleaned))
+	return nil
+}
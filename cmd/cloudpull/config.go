@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -14,8 +15,10 @@ import (
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 
 	"github.com/VatsalSy/CloudPull/internal/config"
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 var configCmd = &cobra.Command{
@@ -41,7 +44,13 @@ Configuration can be managed through:
   cloudpull config reset
 
   # Edit config file directly
-  cloudpull config edit`,
+  cloudpull config edit
+
+  # Show the resolved configuration, including env overrides and profile
+  cloudpull config show --format json
+
+  # Print the config file path
+  cloudpull config path`,
 }
 
 var (
@@ -70,6 +79,26 @@ var (
 		Short: "Edit configuration file in default editor",
 		RunE:  runConfigEdit,
 	}
+
+	configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show the resolved configuration",
+		Long: `Show the configuration CloudPull actually uses: defaults, the config
+file and CLOUDPULL_* environment variables combined, with the selected
+profile merged in. token_file is the token path in use; passwords in URLs
+are redacted.`,
+		Args: cobra.NoArgs,
+		RunE: runConfigShow,
+	}
+
+	configPathCmd = &cobra.Command{
+		Use:   "path",
+		Short: "Print the config file path",
+		Args:  cobra.NoArgs,
+		RunE:  runConfigPath,
+	}
+
+	configShowFormat string
 )
 
 func init() {
@@ -78,6 +107,10 @@ func init() {
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configResetCmd)
 	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configPathCmd)
+
+	configShowCmd.Flags().StringVar(&configShowFormat, "format", "yaml", "output format (yaml or json)")
 
 	// Set default run function
 	configCmd.Run = func(cmd *cobra.Command, args []string) {
@@ -94,25 +127,35 @@ func runConfigList() {
 		home, _ := os.UserHomeDir()
 		configFile = filepath.Join(home, ".cloudpull", "config.yaml")
 	}
-	fmt.Printf("Config file: %s\n\n", configFile)
+	fmt.Printf("Config file: %s\n", configFile)
+
+	// Profile-specific values come from the merged config when it loads
+	getProfileString := viper.GetString
+	activeProfile := viper.GetString("profile")
+	if cfg, err := config.Load(); err == nil {
+		getProfileString = cfg.GetString
+		activeProfile = cfg.ActiveProfile()
+	}
+	fmt.Printf("Profile: %s\n\n", activeProfile)
 
 	// Group configurations
 	groups := map[string][]ConfigItem{
 		"Authentication": {
-			{"credentials_file", "OAuth2 credentials file", viper.GetString("credentials_file")},
-			{"token_file", "Stored auth token", viper.GetString("token_file")},
+			{"credentials_file", "OAuth2 credentials file", getProfileString("credentials_file")},
+			{"token_file", "Stored auth token", getProfileString("token_file")},
 		},
 		"Sync Settings": {
-			{"sync.default_directory", "Default sync directory", viper.GetString("sync.default_directory")},
+			{"sync.default_directory", "Default sync directory", getProfileString("sync.default_directory")},
 			{"sync.max_concurrent", "Max concurrent downloads", fmt.Sprintf("%d", viper.GetInt("sync.max_concurrent"))},
 			{"sync.chunk_size", "Download chunk size", viper.GetString("sync.chunk_size")},
-			{"sync.bandwidth_limit", "Bandwidth limit (MB/s)", formatOptionalInt(viper.GetInt("sync.bandwidth_limit"))},
+			{"sync.bandwidth_limit", "Bandwidth limit", formatBandwidthLimit(viper.GetString("sync.bandwidth_limit"))},
 			{"sync.resume_on_failure", "Auto-resume on failure", fmt.Sprintf("%v", viper.GetBool("sync.resume_on_failure"))},
 		},
 		"File Handling": {
 			{"files.skip_duplicates", "Skip duplicate files", fmt.Sprintf("%v", viper.GetBool("files.skip_duplicates"))},
 			{"files.preserve_timestamps", "Preserve timestamps", fmt.Sprintf("%v", viper.GetBool("files.preserve_timestamps"))},
 			{"files.follow_shortcuts", "Follow Drive shortcuts", fmt.Sprintf("%v", viper.GetBool("files.follow_shortcuts"))},
+			{"files.respect_ignore_files", "Honor .cloudpullignore files", fmt.Sprintf("%v", viper.GetBool("files.respect_ignore_files"))},
 		},
 		"Advanced": {
 			{"cache.enabled", "Enable metadata cache", fmt.Sprintf("%v", viper.GetBool("cache.enabled"))},
@@ -192,6 +235,14 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 	oldValue := viper.Get(key)
 	var newValue interface{}
 
+	if key == "sync.bandwidth_limit" {
+		// Accepts units such as "500KB/s", so always store as a string
+		if _, err := config.ParseBandwidthLimit(value); err != nil {
+			return fmt.Errorf("invalid bandwidth limit for %s: %w", key, err)
+		}
+		oldValue = value
+	}
+
 	switch oldValue.(type) {
 	case bool:
 		parsedBool, err := strconv.ParseBool(value)
@@ -338,11 +389,51 @@ type ConfigItem struct {
 	Value       string
 }
 
-func formatOptionalInt(value int) string {
-	if value == 0 {
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	if configShowFormat != "yaml" && configShowFormat != "json" {
+		return fmt.Errorf("invalid format %q: use yaml or json", configShowFormat)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	settings := cfg.Effective()
+
+	out := cmd.OutOrStdout()
+	if configShowFormat == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(settings)
+	}
+
+	encoder := yaml.NewEncoder(out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(settings); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func runConfigPath(cmd *cobra.Command, args []string) error {
+	path := config.ConfigPath()
+	fmt.Fprintln(cmd.OutOrStdout(), path)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		fmt.Fprintln(cmd.ErrOrStderr(), color.YellowString("Config file does not exist yet; run 'cloudpull init' to create it"))
+	}
+	return nil
+}
+
+func formatBandwidthLimit(value string) string {
+	limit, err := config.ParseBandwidthLimit(value)
+	if err != nil {
+		return value + " (invalid)"
+	}
+	if limit == 0 {
 		return "(unlimited)"
 	}
-	return fmt.Sprintf("%d", value)
+	return util.FormatBytes(limit) + "/s"
 }
 
 func flattenMap(prefix string, m map[string]interface{}) map[string]interface{} {
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var ctlCmd = &cobra.Command{
+	Use:   "ctl <pause|resume|status|stop>",
+	Short: "Control a running sync through its control socket",
+	Long: `Send a command to a sync started with --control-socket.
+
+  pause   Stop starting new downloads until resumed
+  resume  Continue a paused sync
+  status  Show the progress of the running sync
+  stop    Finish in-flight downloads and stop, like Ctrl+C`,
+	Example: `  # Start a sync that can be controlled from another terminal
+  cloudpull sync 1ABC123DEF456GHI --control-socket /tmp/cloudpull.sock
+
+  # Pause and resume it
+  cloudpull ctl pause --control-socket /tmp/cloudpull.sock
+  cloudpull ctl resume --control-socket /tmp/cloudpull.sock`,
+	Args:      cobra.ExactValidArgs(1),
+	ValidArgs: []string{app.ControlPause, app.ControlResume, app.ControlStatus, app.ControlStop},
+	RunE:      runCtl,
+}
+
+var (
+	// controlSocket is the --control-socket path of the sync commands
+	controlSocket string
+
+	// ctlSocket is the --control-socket path ctl connects to
+	ctlSocket string
+)
+
+func init() {
+	ctlCmd.Flags().StringVar(&ctlSocket, "control-socket", defaultControlSocket(),
+		"Control socket of the running sync")
+}
+
+// defaultControlSocket is the socket ctl talks to without --control-socket.
+func defaultControlSocket() string {
+	return filepath.Join(os.TempDir(), "cloudpull.sock")
+}
+
+// addControlSocketFlag registers --control-socket on a command that runs a sync.
+func addControlSocketFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&controlSocket, "control-socket", "",
+		"Listen for 'cloudpull ctl' commands on this Unix socket")
+}
+
+// startControlServer starts the control socket when --control-socket was
+// given and returns a function that closes it.
+func startControlServer(application *app.App) (func(), error) {
+	if controlSocket == "" {
+		return func() {}, nil
+	}
+
+	if err := application.StartControlServer(controlSocket); err != nil {
+		return nil, fmt.Errorf("failed to start control socket: %w", err)
+	}
+
+	return application.StopControlServer, nil
+}
+
+func runCtl(cmd *cobra.Command, args []string) error {
+	response, err := app.SendControlCommand(ctlSocket, args[0])
+	if err != nil {
+		return err
+	}
+
+	if response.Progress == nil {
+		fmt.Println(color.GreenString("✓ %s", response.Message))
+		return nil
+	}
+
+	progress := response.Progress
+	fmt.Printf("Session:  %s (%s)\n", progress.SessionID, progress.Status)
+	fmt.Printf("Files:    %d/%d completed, %d failed, %d skipped\n",
+		progress.CompletedFiles, progress.TotalFiles, progress.FailedFiles, progress.SkippedFiles)
+	fmt.Printf("Data:     %s of %s\n",
+		util.FormatBytes(progress.CompletedBytes), util.FormatBytes(progress.TotalBytes))
+	fmt.Printf("Speed:    %s/s\n", util.FormatBytes(progress.CurrentSpeed))
+	fmt.Printf("Elapsed:  %s\n", progress.ElapsedTime.Round(time.Second))
+	fmt.Printf("API:      %d calls\n", progress.APICalls)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Maintain the state database",
+	Long: `Maintain the SQLite database holding sessions, files and folders.
+
+Maintenance is refused while a session is active. An in-memory database
+is left alone.`,
+}
+
+var dbVacuumCmd = &cobra.Command{
+	Use:   "vacuum",
+	Short: "Rebuild the state database to reclaim free space",
+	Long: `Rebuild the state database so space freed by deleted sessions is
+returned to the file system, then run PRAGMA optimize.
+
+VACUUM locks the database while it runs and temporarily needs up to
+twice its size in free disk space.`,
+	Example: `  # Shrink the database after pruning old sessions
+  cloudpull sessions prune --older-than 30d --yes
+  cloudpull db vacuum`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDBMaintenance(app.DatabaseVacuum)
+	},
+}
+
+var dbOptimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "Refresh the query planner statistics of the state database",
+	Long: `Run PRAGMA optimize, which refreshes the statistics SQLite uses to
+plan queries. It is quick and does not change the file size.`,
+	Example: `  cloudpull db optimize`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDBMaintenance(app.DatabaseOptimize)
+	},
+}
+
+func init() {
+	dbCmd.AddCommand(dbVacuumCmd)
+	dbCmd.AddCommand(dbOptimizeCmd)
+}
+
+func runDBMaintenance(operation string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	result, err := application.MaintainDatabase(context.Background(), operation)
+	if err != nil {
+		return fmt.Errorf("failed to %s database: %w", operation, err)
+	}
+
+	fmt.Println(color.GreenString("✓ Database %s complete", operation))
+	printDBSpace("Before", result.Before)
+	printDBSpace("After ", result.After)
+	return nil
+}
+
+func printDBSpace(label string, stats *state.SpaceStats) {
+	fmt.Printf("  %s: %s, %s free (%d of %d pages)\n", label,
+		util.FormatBytes(stats.Size()), util.FormatBytes(stats.FreeBytes()),
+		stats.FreePages, stats.PageCount)
+}
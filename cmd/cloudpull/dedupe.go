@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe <session-id>",
+	Short: "Find files with identical content in a session",
+	Long: `Report groups of downloaded files that share the same content.
+
+Files are grouped by their MD5 checksum, using the checksum reported by
+Google Drive or, when Drive has none, the one computed after download.
+Files without any checksum are never reported as duplicates.
+
+By default nothing is changed. Use --delete-extra to remove every copy but
+one, or --hardlink to replace the redundant copies with hard links to the
+kept copy.`,
+	Example: `  # Show duplicated files and the space they use
+  cloudpull dedupe abc123
+
+  # Remove redundant copies
+  cloudpull dedupe abc123 --delete-extra
+
+  # Replace redundant copies with hard links
+  cloudpull dedupe abc123 --hardlink`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDedupe,
+}
+
+var (
+	dedupeDeleteExtra bool
+	dedupeHardlink    bool
+)
+
+func init() {
+	dedupeCmd.Flags().BoolVar(&dedupeDeleteExtra, "delete-extra", false,
+		"Delete every copy of a duplicated file but one")
+	dedupeCmd.Flags().BoolVar(&dedupeHardlink, "hardlink", false,
+		"Replace redundant copies with hard links to the kept copy")
+	dedupeCmd.MarkFlagsMutuallyExclusive("delete-extra", "hardlink")
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	sessionID := args[0]
+
+	action := app.DedupeReport
+	switch {
+	case dedupeDeleteExtra:
+		action = app.DedupeDelete
+	case dedupeHardlink:
+		action = app.DedupeHardlink
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	fmt.Println(color.CyanString("🧬 CloudPull Dedupe"))
+	fmt.Println()
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	result, err := application.DedupeSession(ctx, session.ID, action)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicates: %w", err)
+	}
+
+	if len(result.Sets) == 0 {
+		fmt.Println(color.GreenString("No duplicate files found."))
+		return nil
+	}
+
+	printDuplicateSets(result, action)
+
+	fmt.Printf("\n%d groups of duplicates, %s reclaimable\n",
+		len(result.Sets), util.FormatBytes(result.ReclaimableBytes))
+
+	switch action {
+	case app.DedupeDelete:
+		fmt.Println(color.GreenString("Deleted %d files, reclaimed %s",
+			result.Processed, util.FormatBytes(result.ReclaimedBytes)))
+	case app.DedupeHardlink:
+		fmt.Println(color.GreenString("Hard linked %d files, reclaimed %s",
+			result.Processed, util.FormatBytes(result.ReclaimedBytes)))
+	default:
+		fmt.Println("Run with --delete-extra or --hardlink to reclaim space.")
+	}
+
+	if result.Failed > 0 {
+		fmt.Println(color.YellowString("⚠️  %d files could not be changed", result.Failed))
+	}
+
+	return nil
+}
+
+// printDuplicateSets lists every copy of each duplicated file.
+func printDuplicateSets(result *app.DedupeResult, action app.DedupeAction) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Group", "Checksum", "Size", "Copy", "Path"})
+
+	for i, set := range result.Sets {
+		for _, dup := range set.Copies {
+			t.AppendRow(table.Row{
+				i + 1,
+				truncateString(set.Group.Checksum, 12),
+				util.FormatBytes(set.Group.Size),
+				duplicateCopyLabel(dup, action),
+				dup.LocalPath,
+			})
+		}
+		t.AppendSeparator()
+	}
+
+	t.Render()
+}
+
+// duplicateCopyLabel describes what happened to a copy.
+func duplicateCopyLabel(dup *app.DuplicateCopy, action app.DedupeAction) string {
+	switch {
+	case dup.Kept:
+		return color.GreenString("keep")
+	case dup.Missing:
+		return color.YellowString("missing")
+	case dup.Err != nil:
+		return color.RedString("failed")
+	case action == app.DedupeDelete:
+		return "deleted"
+	case action == app.DedupeHardlink:
+		return "linked"
+	default:
+		return "duplicate"
+	}
+}
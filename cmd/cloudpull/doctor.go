@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [session-id]",
+	Short: "Check the state database for inconsistencies",
+	Long: `Check the recorded state of a session for rows the sync engine cannot
+handle, using the most recent session when none is given:
+
+  • files whose folder record is missing
+  • downloads recorded as in progress whose temp file is gone
+  • files and folders of sessions that no longer exist
+
+With --repair, orphaned files and rows without a session are deleted, and
+downloads without a temp file start over as pending.`,
+	Example: `  # Check the latest session
+  cloudpull doctor
+
+  # Check and repair a specific session
+  cloudpull doctor abc123 --repair`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDoctor,
+}
+
+var doctorRepair bool
+
+// maxListedDoctorFiles caps the files listed per problem.
+const maxListedDoctorFiles = 10
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorRepair, "repair", false,
+		"Fix the inconsistencies that were found")
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	ctx := context.Background()
+
+	var sessionID string
+	if len(args) > 0 {
+		sessionID = args[0]
+	} else {
+		session, err := application.GetLatestSession(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get latest session: %w", err)
+		}
+		if session == nil {
+			fmt.Println(color.YellowString("No sessions found"))
+			return nil
+		}
+		sessionID = session.ID
+	}
+
+	report, err := application.CheckIntegrity(ctx, sessionID, doctorRepair)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s %s\n\n", color.CyanString("🩺 Checking session"), sessionID)
+	if report.Running {
+		fmt.Printf("%s The session is being synced; downloads in progress were not checked\n\n",
+			color.YellowString("ℹ"))
+	}
+
+	if report.OK() {
+		fmt.Println(color.GreenString("✓ No problems found"))
+		return nil
+	}
+
+	printDoctorFiles("file(s) reference a missing folder", report.OrphanedFiles)
+	printDoctorFiles("download(s) in progress have no temp file", report.StaleDownloads)
+	if report.SessionlessFiles > 0 || report.SessionlessFolders > 0 {
+		fmt.Printf("%s %d file(s) and %d folder(s) belong to no session\n",
+			color.YellowString("⚠"), report.SessionlessFiles, report.SessionlessFolders)
+	}
+	fmt.Println()
+
+	if report.Repaired {
+		fmt.Println(color.GreenString("✓ Repaired"))
+	} else {
+		fmt.Println("Run with --repair to fix these problems.")
+	}
+	return nil
+}
+
+// printDoctorFiles lists files that share a problem.
+func printDoctorFiles(problem string, files []*state.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Printf("%s %d %s\n", color.YellowString("⚠"), len(files), problem)
+	for i, file := range files {
+		if i == maxListedDoctorFiles {
+			fmt.Printf("    ... and %d more\n", len(files)-i)
+			break
+		}
+		fmt.Printf("    %s\n", file.Path)
+	}
+}
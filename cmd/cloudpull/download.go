@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download <session-id>",
+	Short: "Download the files of a scanned session",
+	Long: `Download the files recorded by 'cloudpull scan'.
+
+Files are taken from the state database, so no Drive folder is listed
+again. Use --output when the database was copied from another machine and
+the recorded destination does not apply here.`,
+	Example: `  # Download a scanned session
+  cloudpull download abc123
+
+  # Download into another directory than the one recorded by the scan
+  cloudpull download abc123 --output /mnt/archive`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDownload,
+}
+
+var downloadOutputDir string
+
+func init() {
+	downloadCmd.Flags().StringVarP(&downloadOutputDir, "output", "o", "",
+		"Output directory (default: the one recorded by the scan)")
+	addControlSocketFlag(downloadCmd)
+}
+
+func runDownload(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	fmt.Println(color.CyanString("⬇️  CloudPull Download"))
+	fmt.Println()
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("session not found: %s", args[0])
+	}
+
+	if session.Status != state.SessionStatusScanned {
+		return fmt.Errorf("session %s is %s, not scanned; use 'cloudpull resume' instead", session.ID, session.Status)
+	}
+
+	if err := application.InitializeAuth(); err != nil {
+		return fmt.Errorf("not authenticated. Run 'cloudpull init' first")
+	}
+
+	if err := application.InitializeSyncEngine(); err != nil {
+		return fmt.Errorf("failed to initialize sync engine: %w", err)
+	}
+
+	stopControl, err := startControlServer(application)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
+	destination := session.DestinationPath
+	if downloadOutputDir != "" {
+		destination = downloadOutputDir
+	}
+	fmt.Printf("Downloading %d files (%s) into %s\n\n",
+		session.TotalFiles, util.FormatBytes(session.TotalBytes), destination)
+
+	monitorCtx, cancelMonitor := context.WithCancel(ctx)
+	defer cancelMonitor()
+
+	go monitorResumeProgress(monitorCtx, application)
+
+	err = application.DownloadScanned(ctx, session.ID, downloadOutputDir)
+	cancelMonitor()
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+
+	session, err = application.GetSession(ctx, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload session: %w", err)
+	}
+
+	if session.Status != state.SessionStatusCompleted {
+		fmt.Println(color.YellowString("\n⚠️  Download ended as %s with %d failed files", session.Status, session.FailedFiles))
+		return nil
+	}
+
+	fmt.Println(color.GreenString("\n✅ Downloaded %d files", session.CompletedFiles))
+	return nil
+}
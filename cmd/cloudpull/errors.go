@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+var errorsCmd = &cobra.Command{
+	Use:   "errors <session-id>",
+	Short: "List the errors recorded for a session",
+	Long: `List individual errors recorded while a session ran, newest first.
+
+Each entry shows the file or folder it concerns, the kind of error, its
+message, when it happened and whether retrying could help. Use the filters
+to narrow the list down when triaging failures. Errors of a file download
+carry the trace_id of its log lines.`,
+	Example: `  # Show the latest errors of a session
+  cloudpull errors abc123
+
+  # Only download failures
+  cloudpull errors abc123 --type download_failed
+
+  # Errors that retrying will not fix
+  cloudpull errors abc123 --retryable=false
+
+  # Errors of one download, by the trace_id of its log lines
+  cloudpull errors abc123 --trace-id session_20250130_101500_1a2b3c4d
+
+  # The next page of results
+  cloudpull errors abc123 --limit 50 --offset 50`,
+	Args: cobra.ExactArgs(1),
+	RunE: runErrors,
+}
+
+var (
+	errorsType      string
+	errorsItemType  string
+	errorsRetryable bool
+	errorsTraceID   string
+	errorsLimit     int
+	errorsOffset    int
+)
+
+func init() {
+	errorsCmd.Flags().StringVar(&errorsType, "type", "",
+		"Only show errors of this type (e.g. download_failed)")
+	errorsCmd.Flags().StringVar(&errorsItemType, "item-type", "",
+		"Only show errors for files or folders")
+	errorsCmd.Flags().BoolVar(&errorsRetryable, "retryable", false,
+		"Only show retryable (true) or permanent (false) errors")
+	errorsCmd.Flags().StringVar(&errorsTraceID, "trace-id", "",
+		"Only show errors of the download with this trace ID")
+	errorsCmd.Flags().IntVar(&errorsLimit, "limit", 50,
+		"Maximum number of errors to show (0 for all)")
+	errorsCmd.Flags().IntVar(&errorsOffset, "offset", 0,
+		"Number of errors to skip")
+}
+
+func runErrors(cmd *cobra.Command, args []string) error {
+	sessionID := args[0]
+
+	if errorsItemType != "" && errorsItemType != "file" && errorsItemType != "folder" {
+		return fmt.Errorf("--item-type must be file or folder")
+	}
+	if errorsLimit < 0 || errorsOffset < 0 {
+		return fmt.Errorf("--limit and --offset must not be negative")
+	}
+
+	filter := &state.ErrorLogFilter{
+		ErrorType: errorsType,
+		ItemType:  errorsItemType,
+		TraceID:   errorsTraceID,
+		Limit:     errorsLimit,
+		Offset:    errorsOffset,
+	}
+	if cmd.Flags().Changed("retryable") {
+		filter.Retryable = &errorsRetryable
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	entries, err := application.GetErrors(ctx, session.ID, filter)
+	if err != nil {
+		return fmt.Errorf("failed to get errors: %w", err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Println(color.GreenString("No errors recorded for session %s", session.ID))
+		return nil
+	}
+
+	printErrorLog(entries)
+
+	if errorsLimit > 0 && len(entries) == errorsLimit {
+		fmt.Printf("\nShowing %d errors; use --offset %d for more\n", len(entries), errorsOffset+len(entries))
+	}
+
+	return nil
+}
+
+// printErrorLog renders error log entries as a table.
+func printErrorLog(entries []*state.ErrorLog) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Time", "Item", "Type", "Error", "Retryable"})
+
+	for _, entry := range entries {
+		message := ""
+		if entry.ErrorMessage.Valid {
+			message = truncateString(strings.Join(strings.Fields(entry.ErrorMessage.String), " "), 70)
+		}
+
+		retryable := color.RedString("no")
+		if entry.IsRetryable {
+			retryable = color.GreenString("yes")
+		}
+
+		t.AppendRow(table.Row{
+			entry.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			entry.ItemType + " " + entry.ItemID,
+			entry.ErrorType,
+			message,
+			retryable,
+		})
+	}
+
+	t.Render()
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events <session-id>",
+	Short: "Show the file events recorded for a session",
+	Long: `Show the file events recorded for a session, oldest first.
+
+Events are only recorded while sync.persist_events is enabled. Each file
+download records when it started, sampled progress and whether it
+completed, failed or was skipped, which helps to follow intermittent
+problems after the fact.`,
+	Example: `  # Dump every recorded event of a session
+  cloudpull events abc123
+
+  # Only failures
+  cloudpull events abc123 --type file_failed
+
+  # Everything recorded for one file, as JSON lines
+  cloudpull events abc123 --file 1a2b3c --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEvents,
+}
+
+var (
+	eventsType   string
+	eventsFileID string
+	eventsLimit  int
+	eventsJSON   bool
+)
+
+func init() {
+	eventsCmd.Flags().StringVar(&eventsType, "type", "",
+		"Only show events of this type (file_started, file_progress, file_completed, file_failed or file_skipped)")
+	eventsCmd.Flags().StringVar(&eventsFileID, "file", "",
+		"Only show events of the file with this ID")
+	eventsCmd.Flags().IntVar(&eventsLimit, "limit", 0,
+		"Maximum number of events to show (0 for all)")
+	eventsCmd.Flags().BoolVar(&eventsJSON, "json", false,
+		"Print one JSON object per event")
+}
+
+func runEvents(cmd *cobra.Command, args []string) error {
+	if eventsLimit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("session not found: %s", args[0])
+	}
+
+	events, err := application.GetEvents(ctx, session.ID, &state.EventFilter{
+		Type:   eventsType,
+		ItemID: eventsFileID,
+		Limit:  eventsLimit,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get events: %w", err)
+	}
+
+	if eventsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(events) == 0 {
+		fmt.Println(color.YellowString("No events recorded for session %s", session.ID))
+		fmt.Println("Enable sync.persist_events to record them.")
+		return nil
+	}
+
+	printEvents(events)
+	return nil
+}
+
+// printEvents renders recorded events as a table.
+func printEvents(events []*state.Event) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Time", "Event", "File", "Bytes", "Details"})
+
+	for _, event := range events {
+		path := event.ItemID.String
+		if event.ItemPath.Valid {
+			path = event.ItemPath.String
+		}
+
+		bytes := ""
+		if event.Bytes > 0 {
+			bytes = util.FormatBytes(event.Bytes)
+		}
+
+		t.AppendRow(table.Row{
+			event.OccurredAt.Local().Format("2006-01-02 15:04:05.000"),
+			event.Type,
+			truncateString(path, 50),
+			bytes,
+			truncateString(event.Message.String, 60),
+		})
+	}
+
+	t.Render()
+}
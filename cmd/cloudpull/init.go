@@ -17,6 +17,7 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/config"
 )
 
 var initCmd = &cobra.Command{
@@ -162,8 +163,8 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	if config.EnableBandwidth {
 		bandwidthPrompt := &survey.Input{
-			Message: "Bandwidth limit (MB/s):",
-			Default: "10",
+			Message: "Bandwidth limit (e.g. 10MB/s, 500KB/s):",
+			Default: "10MB/s",
 		}
 		if err := survey.AskOne(bandwidthPrompt, &config.BandwidthLimit); err != nil {
 			return err
@@ -179,10 +180,8 @@ func runInit(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("invalid max concurrent value: %w", err)
 	}
 
-	var bandwidthLimit int
 	if config.EnableBandwidth {
-		bandwidthLimit, err = strconv.Atoi(config.BandwidthLimit)
-		if err != nil {
+		if err := validateBandwidthLimit(config.BandwidthLimit); err != nil {
 			return fmt.Errorf("invalid bandwidth limit value: %w", err)
 		}
 	}
@@ -199,7 +198,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 	viper.Set("sync.chunk_size", config.ChunkSize)
 	viper.Set("sync.chunk_size_bytes", chunkSizeBytes)
 	if config.EnableBandwidth {
-		viper.Set("sync.bandwidth_limit", bandwidthLimit)
+		viper.Set("sync.bandwidth_limit", config.BandwidthLimit)
 	}
 
 	configDir := filepath.Dir(configPath)
@@ -232,7 +231,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 		// Perform authentication
 		fmt.Println("\nStarting authentication flow...")
-		if err := application.Authenticate(context.Background()); err != nil {
+		if err := authenticate(context.Background(), application, false); err != nil {
 			// Check for user cancellation or denial
 			var oauth2Err *oauth2.RetrieveError
 			if errors.As(err, &oauth2Err) && strings.Contains(oauth2Err.ErrorDescription, "access_denied") {
@@ -241,7 +240,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 			if errors.Is(err, io.EOF) {
 				return fmt.Errorf("authentication canceled by user")
 			}
-			return fmt.Errorf("authentication failed: %w", err)
+			return err
 		}
 	} else {
 		fmt.Println("Run 'cloudpull auth' to complete authentication.")
@@ -256,6 +255,11 @@ func runInit(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func validateBandwidthLimit(limit string) error {
+	_, err := config.ParseBandwidthLimit(limit)
+	return err
+}
+
 func parseChunkSize(size string) (int64, error) {
 	size = strings.ToUpper(strings.TrimSpace(size))
 	multiplier := int64(1)
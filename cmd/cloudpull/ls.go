@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls <folder-id|folder-url>",
+	Short: "Preview the contents of a Google Drive folder",
+	Long: `List a Google Drive folder without downloading anything or starting a
+session.
+
+Each item shows its size, MIME type and whether it is a folder, a Google
+Docs file (exported on download) or a shortcut. Include and exclude
+patterns are applied exactly as a sync applies them, so items a sync would
+leave out are marked together with the reason. Use this to check filters
+before a real run.`,
+	Example: `  # List the top level of a folder
+  cloudpull ls 1ABC123DEF456GHI
+
+  # List the whole tree
+  cloudpull ls 1ABC123DEF456GHI --recursive
+
+  # Check which files a filter keeps, two levels deep
+  cloudpull ls 1ABC123DEF456GHI -r --max-depth 2 --exclude '\.tmp$'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runLs,
+}
+
+var (
+	lsRecursive bool
+	lsMaxDepth  int
+	lsInclude   []string
+	lsExclude   []string
+)
+
+func init() {
+	lsCmd.Flags().BoolVarP(&lsRecursive, "recursive", "r", false,
+		"List subfolders too")
+	lsCmd.Flags().IntVar(&lsMaxDepth, "max-depth", 0,
+		"Maximum folder depth with --recursive (-1 for unlimited, default: from config)")
+	lsCmd.Flags().StringSliceVarP(&lsInclude, "include", "i", []string{},
+		"Include only paths matching pattern (can be used multiple times)")
+	lsCmd.Flags().StringSliceVarP(&lsExclude, "exclude", "e", []string{},
+		"Exclude paths matching pattern (can be used multiple times)")
+}
+
+func runLs(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.InitializeForAuth(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	if !application.IsAuthenticated() {
+		return fmt.Errorf("not authenticated. Run 'cloudpull auth' first")
+	}
+
+	folderID, err := extractFolderID(args[0])
+	if err != nil {
+		return err
+	}
+	listing, err := application.ListRemote(context.Background(), folderID, &app.ListOptions{
+		IncludePatterns: lsInclude,
+		ExcludePatterns: lsExclude,
+		Recursive:       lsRecursive,
+		MaxDepth:        lsMaxDepth,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list folder: %w", err)
+	}
+
+	if listing.RootSkipped {
+		fmt.Println(color.YellowString("The folder itself is excluded by the filter patterns; a sync would download nothing"))
+		return nil
+	}
+	if len(listing.Items) == 0 {
+		fmt.Println("Folder is empty")
+		return nil
+	}
+
+	printRemoteListing(listing)
+	return nil
+}
+
+// printRemoteListing renders a remote listing as a table followed by totals.
+func printRemoteListing(listing *cloudsync.RemoteListing) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Name", "Size", "Kind", "MIME Type", "Sync"})
+
+	for _, item := range listing.Items {
+		name := strings.Repeat("  ", item.Depth) + item.Info.Name
+		size := util.FormatBytes(item.Info.Size)
+
+		kind := "file"
+		switch {
+		case item.Info.IsFolder:
+			kind = "folder"
+			name += "/"
+			size = ""
+		case item.IsShortcut:
+			kind = "shortcut"
+		case item.Info.CanExport:
+			kind = "google doc"
+			size = ""
+		}
+
+		status := color.GreenString("include")
+		if item.SkipReason != "" {
+			status = color.YellowString("skip: %s", item.SkipReason)
+		}
+
+		t.AppendRow(table.Row{truncateString(name, 60), size, kind, item.Info.MimeType, status})
+	}
+
+	t.Render()
+
+	fmt.Println()
+	fmt.Printf("%d folders, %d files (%d Google Docs, %d shortcuts)\n",
+		listing.Folders, listing.Files, listing.GoogleDocs, listing.Shortcuts)
+	fmt.Printf("Would download: %d files, %s\n",
+		listing.Files-listing.SkippedFiles, util.FormatBytes(listing.TotalBytes))
+	if listing.SkippedFiles > 0 || listing.SkippedFolders > 0 {
+		fmt.Println(color.YellowString("Skipped: %d files (%s), %d folders",
+			listing.SkippedFiles, util.FormatBytes(listing.SkippedBytes), listing.SkippedFolders))
+	}
+	if listing.GoogleDocs > 0 {
+		fmt.Println(color.HiBlackString("Google Docs are exported on download; their size is only known afterwards"))
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+)
+
+// syncProgressBar draws the progress of a running sync as a single-line bar
+// with speed and ETA. Log lines written to stdout while the bar is shown are
+// printed above it instead of through it.
+type syncProgressBar struct {
+	bar     *progressbar.ProgressBar
+	mu      sync.Mutex
+	enabled bool
+	done    bool
+}
+
+// newSyncProgressBar returns a bar that is drawn when enabled.
+func newSyncProgressBar(enabled bool) *syncProgressBar {
+	return &syncProgressBar{enabled: enabled}
+}
+
+// stdoutIsTerminal reports whether a progress bar can be redrawn on stdout.
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// wrapLogOutput routes log output to stdout around the bar; it is passed to
+// app.WithLogWriter.
+func (p *syncProgressBar) wrapLogOutput(w io.Writer) io.Writer {
+	if !p.enabled || w != os.Stdout {
+		return w
+	}
+	return &progressLogWriter{progress: p, out: w}
+}
+
+// progressLogWriter erases the bar before each log line and redraws it after.
+type progressLogWriter struct {
+	progress *syncProgressBar
+	out      io.Writer
+}
+
+func (w *progressLogWriter) Write(b []byte) (int, error) {
+	w.progress.mu.Lock()
+	defer w.progress.mu.Unlock()
+
+	bar := w.progress.bar
+	if bar == nil || w.progress.done {
+		return w.out.Write(b)
+	}
+
+	_ = bar.Clear()
+	n, err := w.out.Write(b)
+	_ = bar.RenderBlank()
+	return n, err
+}
+
+// update draws the latest progress, creating the bar once totals are known.
+func (p *syncProgressBar) update(progress *cloudsync.SyncProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done {
+		return
+	}
+	if p.bar == nil {
+		if progress.TotalBytes <= 0 {
+			return
+		}
+		p.bar = progressbar.NewOptions64(
+			progress.TotalBytes,
+			progressbar.OptionSetWidth(30),
+			progressbar.OptionShowBytes(true),
+			progressbar.OptionSetPredictTime(true),
+			progressbar.OptionSetElapsedTime(false),
+			progressbar.OptionOnCompletion(func() {
+				fmt.Print("\n")
+			}),
+			progressbar.OptionSetRenderBlankState(true),
+		)
+	}
+
+	// Totals grow while folders are still being scanned
+	if progress.TotalBytes > p.bar.GetMax64() {
+		p.bar.ChangeMax64(progress.TotalBytes)
+	}
+
+	p.bar.Describe(fmt.Sprintf("%d/%d files", progress.CompletedFiles, progress.TotalFiles))
+	_ = p.bar.Set64(min(progress.CompletedBytes, p.bar.GetMax64()))
+}
+
+// finish leaves the bar at its last state and moves past it.
+func (p *syncProgressBar) finish() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.bar != nil && !p.done {
+		_ = p.bar.Exit()
+	}
+	p.done = true
+}
+
+// monitorSyncProgress updates the bar until the sync completes or stops.
+func monitorSyncProgress(application *app.App, bar *syncProgressBar, completionChan <-chan struct{}) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	defer bar.finish()
+
+	for {
+		select {
+		case <-completionChan:
+			if progress := application.GetProgress(); progress != nil {
+				bar.update(progress)
+			}
+			return
+		case <-ticker.C:
+			progress := application.GetProgress()
+			if progress == nil {
+				continue
+			}
+
+			bar.update(progress)
+
+			if progress.Status == "stopped" || progress.Status == "completed" {
+				return
+			}
+		}
+	}
+}
@@ -42,9 +42,10 @@ var (
 
 func init() {
 	resumeCmd.Flags().BoolVar(&resumeLatest, "latest", false,
-		"Resume the most recent interrupted session")
+		"Resume the most recent active, paused, failed or quota-stopped session")
 	resumeCmd.Flags().BoolVar(&forceResume, "force", false,
 		"Force resume even if session appears corrupted")
+	addControlSocketFlag(resumeCmd)
 }
 
 func runResume(cmd *cobra.Command, args []string) error {
@@ -66,6 +67,12 @@ func runResume(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize sync engine: %w", err)
 	}
 
+	stopControl, err := startControlServer(application)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
 	fmt.Println(color.CyanString("🔄 CloudPull Resume"))
 	fmt.Println()
 
@@ -89,7 +96,7 @@ func runResume(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("session not found: %s", args[0])
 		}
 	} else if resumeLatest {
-		session, err = application.GetLatestSession(ctx)
+		session, err = application.GetLatestResumableSession(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get latest session: %w", err)
 		}
@@ -130,6 +137,12 @@ func runResume(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	if session.Status == state.SessionStatusCompletedWithErrors {
+		fmt.Println(color.YellowString("⚠️  Warning: Session is already completed with errors"))
+		fmt.Printf("Use 'cloudpull retry %s' to download its failed files again\n", session.ID)
+		return nil
+	}
+
 	if session.Status == state.SessionStatusFailed && !forceResume {
 		fmt.Println(color.RedString("⚠️  Warning: Session failed previously"))
 		var proceed bool
@@ -192,7 +205,8 @@ func selectSessionFromApp(ctx context.Context, app *app.App) (*state.Session, er
 	// Filter out completed sessions
 	var resumableSessions []*state.Session
 	for _, s := range sessions {
-		if s.Status != state.SessionStatusCompleted && s.Status != state.SessionStatusCancelled {
+		if s.Status != state.SessionStatusCompleted && s.Status != state.SessionStatusCancelled &&
+			s.Status != state.SessionStatusCompletedWithErrors {
 			resumableSessions = append(resumableSessions, s)
 		}
 	}
@@ -229,10 +243,12 @@ func selectSessionFromApp(ctx context.Context, app *app.App) (*state.Session, er
 
 		statusColor := session.Status
 		switch session.Status {
-		case state.SessionStatusFailed:
+		case state.SessionStatusFailed, state.SessionStatusStalled:
 			statusColor = color.RedString(session.Status)
-		case state.SessionStatusPaused:
+		case state.SessionStatusPaused, state.SessionStatusStoppedQuota:
 			statusColor = color.YellowString(session.Status)
+		case state.SessionStatusScanned:
+			statusColor = color.CyanString(session.Status)
 		case state.SessionStatusActive:
 			statusColor = color.GreenString(session.Status)
 		}
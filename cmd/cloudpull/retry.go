@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var retryCmd = &cobra.Command{
+	Use:   "retry <session-id>",
+	Short: "Re-download only the failed files of a session",
+	Long: `Re-attempt the files that failed in a previous sync session.
+
+Unlike resume, retry does not walk folders again: only failed files with
+fewer than --max-attempts download attempts are requeued. The session is
+reopened and finishes as completed or failed depending on the outcome.`,
+	Example: `  # Retry failed files of a session
+  cloudpull retry abc123
+
+  # Show what would be retried
+  cloudpull retry abc123 --dry-run
+
+  # Allow files that already failed many times
+  cloudpull retry abc123 --max-attempts 20`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRetry,
+}
+
+var (
+	retryMaxAttempts int
+	retryDryRun      bool
+)
+
+func init() {
+	retryCmd.Flags().IntVar(&retryMaxAttempts, "max-attempts", app.DefaultRetryMaxAttempts,
+		"Only retry files with fewer download attempts than this")
+	retryCmd.Flags().BoolVar(&retryDryRun, "dry-run", false,
+		"List the files that would be retried without downloading")
+	addControlSocketFlag(retryCmd)
+}
+
+func runRetry(cmd *cobra.Command, args []string) error {
+	sessionID := args[0]
+
+	if retryMaxAttempts < 1 {
+		return fmt.Errorf("--max-attempts must be at least 1")
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	fmt.Println(color.CyanString("🔁 CloudPull Retry"))
+	fmt.Println()
+
+	ctx := context.Background()
+
+	session, err := application.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	files, err := application.GetRetryableFiles(ctx, session.ID, retryMaxAttempts)
+	if err != nil {
+		return fmt.Errorf("failed to get failed files: %w", err)
+	}
+
+	if len(files) == 0 {
+		fmt.Println(color.YellowString("No failed files to retry."))
+		if session.FailedFiles > 0 {
+			fmt.Printf("Files that reached %d attempts are skipped; raise --max-attempts to include them.\n",
+				retryMaxAttempts)
+		}
+		return nil
+	}
+
+	if retryDryRun {
+		printRetryableFiles(files)
+		return nil
+	}
+
+	if err := application.InitializeAuth(); err != nil {
+		return fmt.Errorf("not authenticated. Run 'cloudpull init' first")
+	}
+
+	if err := application.InitializeSyncEngine(); err != nil {
+		return fmt.Errorf("failed to initialize sync engine: %w", err)
+	}
+
+	stopControl, err := startControlServer(application)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
+	fmt.Printf("Retrying %d failed files from session %s\n\n", len(files), color.CyanString(session.ID))
+
+	monitorCtx, cancelMonitor := context.WithCancel(ctx)
+	defer cancelMonitor()
+
+	go monitorResumeProgress(monitorCtx, application)
+
+	count, err := application.RetrySync(ctx, session.ID, retryMaxAttempts)
+	cancelMonitor()
+	if err != nil {
+		return fmt.Errorf("retry failed: %w", err)
+	}
+
+	session, err = application.GetSession(ctx, session.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload session: %w", err)
+	}
+
+	if session.FailedFiles > 0 {
+		fmt.Println(color.YellowString("\n⚠️  Retried %d files, %d still failing", count, session.FailedFiles))
+		return nil
+	}
+
+	fmt.Println(color.GreenString("\n✅ Retried %d files successfully!", count))
+	return nil
+}
+
+// printRetryableFiles lists the files a retry would download.
+func printRetryableFiles(files []*state.File) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Path", "Size", "Attempts", "Last Error"})
+
+	var totalSize int64
+	for _, file := range files {
+		lastError := ""
+		if file.ErrorMessage.Valid {
+			lastError = truncateString(file.ErrorMessage.String, 60)
+		}
+
+		t.AppendRow(table.Row{
+			file.Path,
+			util.FormatBytes(file.Size),
+			file.DownloadAttempts,
+			lastError,
+		})
+		totalSize += file.Size
+	}
+
+	t.Render()
+	fmt.Printf("\n%d files (%s) would be retried\n", len(files), util.FormatBytes(totalSize))
+}
+
+// truncateString shortens s to at most max runes, adding an ellipsis.
+func truncateString(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
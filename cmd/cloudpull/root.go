@@ -7,10 +7,13 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/VatsalSy/CloudPull/internal/config"
 )
 
 var (
 	cfgFile string
+	profile string
 	verbose bool
 	rootCmd = &cobra.Command{
 		Use:   "cloudpull",
@@ -23,7 +26,7 @@ Features:
   • Resume interrupted downloads
   • Real-time progress tracking
   • Bandwidth throttling
-  • Multiple account support`,
+  • Multiple account support via config profiles`,
 		Version: "0.1.0",
 	}
 )
@@ -41,20 +44,39 @@ func init() {
 		"config file (default is $HOME/.cloudpull/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
 		"verbose output")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "",
+		"config profile to use (default is $CLOUDPULL_PROFILE or \"default\")")
 
 	// Bind flags to viper
 	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
 		fmt.Fprintf(os.Stderr, "Error binding flag: %v\n", err)
 	}
+	if err := viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile")); err != nil {
+		fmt.Fprintf(os.Stderr, "Error binding flag: %v\n", err)
+	}
 
 	// Add commands
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(authCmd)
 	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(downloadCmd)
 	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(retryCmd)
+	rootCmd.AddCommand(ctlCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(errorsCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(analyticsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(catCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(cleanupCmd)
+	rootCmd.AddCommand(sessionsCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(doctorCmd)
 
 	// Enable shell completion
 	rootCmd.CompletionOptions.DisableDefaultCmd = false
@@ -82,8 +104,7 @@ func initConfig() {
 	}
 
 	// Environment variables
-	viper.SetEnvPrefix("CLOUDPULL")
-	viper.AutomaticEnv()
+	config.BindEnv(viper.GetViper())
 
 	// Read config file
 	if err := viper.ReadInConfig(); err == nil {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <folder-id|folder-url>",
+	Short: "Record a Drive folder's structure without downloading",
+	Long: `Walk a Google Drive folder and record its folders and files in the
+state database without downloading anything.
+
+The session is left in the scanned state. Download it later with
+'cloudpull download <session-id>', which needs no folder listings, possibly
+on another machine after copying the state database there.`,
+	Example: `  # Scan a folder now, download it later
+  cloudpull scan 1ABC123DEF456GHI
+  cloudpull download <session-id>
+
+  # Record the destination to download into
+  cloudpull scan 1ABC123DEF456GHI --output ~/Archive`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScan,
+}
+
+var (
+	scanOutputDir string
+	scanFlatten   bool
+)
+
+func init() {
+	scanCmd.Flags().StringVarP(&scanOutputDir, "output", "o", "",
+		"Output directory recorded for the download (default: configured sync directory)")
+	scanCmd.Flags().BoolVar(&scanFlatten, "flatten", false,
+		"Download all files into the output directory without Drive folders")
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+
+	if err := application.InitializeAuth(); err != nil {
+		return fmt.Errorf("not authenticated. Run 'cloudpull init' first")
+	}
+
+	if err := application.InitializeSyncEngine(); err != nil {
+		return fmt.Errorf("failed to initialize sync engine: %w", err)
+	}
+
+	folderID, err := extractFolderID(args[0])
+	if err != nil {
+		return err
+	}
+	outputDir := scanOutputDir
+	if outputDir == "" {
+		outputDir, err = defaultOutputDir(application, folderID)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(color.CyanString("🔍 CloudPull Scan"))
+	fmt.Println()
+	fmt.Printf("Scanning Google Drive folder %s\n\n", folderID)
+
+	ctx := context.Background()
+
+	sessionID, err := application.ScanSync(ctx, folderID, outputDir, &app.SyncOptions{Flatten: scanFlatten})
+	if err != nil {
+		return fmt.Errorf("scan failed: %w", err)
+	}
+
+	session, err := application.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to reload session: %w", err)
+	}
+
+	if session.Status != state.SessionStatusScanned {
+		fmt.Println(color.YellowString("⚠️  Scan of session %s ended as %s", session.ID, session.Status))
+		fmt.Println("Run 'cloudpull resume " + session.ID + "' to continue it.")
+		return nil
+	}
+
+	fmt.Println(color.GreenString("✅ Scanned %d files (%s)", session.TotalFiles, util.FormatBytes(session.TotalBytes)))
+	fmt.Printf("Download them with: cloudpull download %s\n", session.ID)
+	return nil
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage recorded sync sessions",
+	Long:  `Inspect and maintain the sync sessions kept in the state database.`,
+}
+
+var sessionsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old sessions from the state database",
+	Long: `Remove old sessions to keep the state database small.
+
+By default finished (completed, failed or canceled) sessions older than
+--older-than are deleted with everything recorded about them.
+
+With --archive, completed sessions are kept in the session list with a
+summary of their file counts, bytes and duration, while their per-file
+and per-folder records are deleted. Downloaded files are never touched.`,
+	Example: `  # Delete finished sessions older than 30 days
+  cloudpull sessions prune --older-than 30d
+
+  # Keep a summary of completed sessions older than a week
+  cloudpull sessions prune --older-than 7d --archive --yes`,
+	Args: cobra.NoArgs,
+	RunE: runSessionsPrune,
+}
+
+var sessionsConfigCmd = &cobra.Command{
+	Use:   "config <session-id> [key value]",
+	Short: "Show or override the settings of a session",
+	Long: `Show the settings a session was started with, or override one of them.
+
+A resumed or retried session uses these settings instead of the current
+configuration, so it keeps running the way it was started.`,
+	Example: `  # Show the settings of a session
+  cloudpull sessions config abc123
+
+  # Resume it with more download workers
+  cloudpull sessions config abc123 max_concurrent 8
+  cloudpull resume abc123`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 && len(args) != 3 {
+			return fmt.Errorf("accepts a session ID, optionally followed by a key and value")
+		}
+		return nil
+	},
+	RunE: runSessionsConfig,
+}
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "Show everything recorded about a session",
+	Long: `Show one session in full, whether it is running or finished: its
+record, file and folder counts, the top-level folders with the totals of
+their subtrees, a summary of its errors, unfinished downloads and the
+settings it is resumed with.
+
+The data is read from the state database, so a running session shows its
+progress as of its last checkpoint.`,
+	Example: `  # Show a session
+  cloudpull sessions show abc123
+
+  # Dump it as JSON
+  cloudpull sessions show abc123 --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsShow,
+}
+
+// maxShownPartialDownloads caps the unfinished downloads listed by
+// sessions show; --json lists them all.
+const maxShownPartialDownloads = 20
+
+var (
+	sessionsShowJSON bool
+
+	pruneOlderThan string
+	pruneArchive   bool
+	pruneNoConfirm bool
+)
+
+func init() {
+	sessionsPruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "30d",
+		"Only prune sessions created longer ago than this, e.g. 30d, 2w, 1mo or 12h")
+	sessionsPruneCmd.Flags().BoolVar(&pruneArchive, "archive", false,
+		"Keep a summary of completed sessions instead of deleting them")
+	sessionsPruneCmd.Flags().BoolVarP(&pruneNoConfirm, "yes", "y", false,
+		"Skip confirmation prompt")
+
+	sessionsShowCmd.Flags().BoolVar(&sessionsShowJSON, "json", false,
+		"Print the session as JSON")
+
+	sessionsCmd.AddCommand(sessionsPruneCmd)
+	sessionsCmd.AddCommand(sessionsConfigCmd)
+	sessionsCmd.AddCommand(sessionsShowCmd)
+}
+
+func runSessionsPrune(cmd *cobra.Command, args []string) error {
+	olderThan, err := parseAge(pruneOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than: %w", err)
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	if !pruneNoConfirm {
+		message := fmt.Sprintf("Delete finished sessions older than %s?", pruneOlderThan)
+		if pruneArchive {
+			message = fmt.Sprintf("Archive completed sessions older than %s?", pruneOlderThan)
+		}
+
+		var proceed bool
+		if err := survey.AskOne(&survey.Confirm{Message: message, Default: false}, &proceed); err != nil {
+			return fmt.Errorf("failed to get user confirmation: %w", err)
+		}
+		if !proceed {
+			return nil
+		}
+	}
+
+	count, err := application.PruneSessions(context.Background(), olderThan, pruneArchive)
+	if err != nil {
+		return err
+	}
+
+	if pruneArchive {
+		fmt.Println(color.GreenString("✓ Archived %d session(s)", count))
+	} else {
+		fmt.Println(color.GreenString("✓ Deleted %d session(s)", count))
+	}
+	return nil
+}
+
+func runSessionsConfig(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	ctx := context.Background()
+	sessionID := args[0]
+
+	if len(args) == 3 {
+		if err := application.SetSessionConfig(ctx, sessionID, args[1], args[2]); err != nil {
+			return err
+		}
+		fmt.Println(color.GreenString("✓ Set %s = %s for session %s", args[1], args[2], sessionID))
+		return nil
+	}
+
+	settings, err := application.GetSessionConfig(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		fmt.Println("No settings stored for this session")
+		return nil
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Printf("%-24s %s\n", key, settings[key])
+	}
+	return nil
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) error {
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	detail, err := application.GetSessionDetail(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	if sessionsShowJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(detail)
+	}
+
+	printSessionDetail(detail)
+	return nil
+}
+
+// printSessionDetail renders a session and the tables of its folders,
+// errors and unfinished downloads.
+func printSessionDetail(detail *state.SessionDetail) {
+	session := detail.Session
+
+	fmt.Printf("%s Session %s\n", color.GreenString("▶"), color.CyanString(session.ID))
+	fmt.Println(strings.Repeat("─", 50))
+
+	source := session.RootFolderID
+	if session.RootFolderName.Valid && session.RootFolderName.String != "" {
+		source = fmt.Sprintf("%s (%s)", session.RootFolderName.String, session.RootFolderID)
+	}
+	end := session.UpdatedAt
+	ended := "-"
+	if session.EndTime.Valid {
+		end = session.EndTime.Time
+		ended = end.Format("Jan 2, 2006 3:04:05 PM")
+	}
+
+	fmt.Printf("%-15s: %s\n", "Status", colorSessionStatus(session.Status))
+	fmt.Printf("%-15s: %s\n", "Source", source)
+	fmt.Printf("%-15s: %s\n", "Destination", session.DestinationPath)
+	fmt.Printf("%-15s: %s\n", "Started", session.StartTime.Format("Jan 2, 2006 3:04:05 PM"))
+	fmt.Printf("%-15s: %s\n", "Ended", ended)
+	fmt.Printf("%-15s: %s\n", "Duration", formatDuration(end.Sub(session.StartTime)))
+	if session.Flatten {
+		fmt.Printf("%-15s: %s\n", "Layout", "flattened")
+	}
+	fmt.Println()
+
+	fmt.Println(color.YellowString("Progress:"))
+	fmt.Printf("  Files      : %d / %d (%.1f%%)\n", session.CompletedFiles, session.TotalFiles, session.Progress())
+	fmt.Printf("  Downloaded : %s / %s\n", util.FormatBytes(session.CompletedBytes), util.FormatBytes(session.TotalBytes))
+	if files := detail.Stats.Files; files != nil {
+		fmt.Printf("  By status  : %d completed, %d failed, %d skipped, %d pending, %d queued, %d downloading\n",
+			files.CompletedCount, files.FailedCount, files.SkippedCount,
+			files.PendingCount, files.QueuedCount, files.DownloadingCount)
+	}
+	fmt.Printf("  Folders    : %s\n", formatStatusCounts(detail.Stats.FolderCounts,
+		state.FolderStatusScanned, state.FolderStatusScanning, state.FolderStatusPending, state.FolderStatusFailed))
+	fmt.Printf("  To resume  : %d files, %d folders, %d retryable failures\n",
+		detail.PendingFiles, detail.PendingFolders, detail.FailedRetryable)
+
+	if archive := detail.Archive; archive != nil {
+		fmt.Println()
+		fmt.Println(color.YellowString("Archived %s:", archive.ArchivedAt.Format("Jan 2, 2006")))
+		fmt.Printf("  %d files (%d completed, %d failed, %d skipped) in %d folders, %s of %s in %s\n",
+			archive.TotalFiles, archive.CompletedFiles, archive.FailedFiles, archive.SkippedFiles,
+			archive.TotalFolders, util.FormatBytes(archive.CompletedBytes), util.FormatBytes(archive.TotalBytes),
+			formatDuration(time.Duration(archive.DurationSeconds)*time.Second))
+	}
+
+	if len(detail.Folders) > 0 {
+		fmt.Println()
+		fmt.Println(color.YellowString("Folders:"))
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Folder", "Status", "Files", "Size", "Downloaded"})
+		for _, folder := range detail.Folders {
+			t.AppendRow(table.Row{folder.Path, folder.Status, folder.FileCount,
+				util.FormatBytes(folder.TotalSize), util.FormatBytes(folder.DownloadSize)})
+		}
+		t.Render()
+	}
+
+	if len(detail.Stats.Errors) > 0 {
+		fmt.Println()
+		fmt.Println(color.YellowString("Errors:"))
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"Type", "Code", "Item", "Count", "Retryable", "Last"})
+		for _, summary := range detail.Stats.Errors {
+			t.AppendRow(table.Row{summary.ErrorType, summary.ErrorCode, summary.ItemType, summary.Count,
+				summary.IsRetryable, summary.LastOccurred.Local().Format("Jan 2 15:04")})
+		}
+		t.Render()
+	}
+
+	if len(detail.PartialDownloads) > 0 {
+		fmt.Println()
+		fmt.Println(color.YellowString("Unfinished downloads:"))
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"File", "Downloaded", "Size", "Progress"})
+		for i, partial := range detail.PartialDownloads {
+			if i == maxShownPartialDownloads {
+				t.AppendFooter(table.Row{fmt.Sprintf("... and %d more", len(detail.PartialDownloads)-i)})
+				break
+			}
+			t.AppendRow(table.Row{partial.Path, util.FormatBytes(partial.BytesDownloaded),
+				util.FormatBytes(partial.Size), fmt.Sprintf("%.1f%%", partial.Progress)})
+		}
+		t.Render()
+	}
+
+	if len(detail.Config) > 0 {
+		keys := make([]string, 0, len(detail.Config))
+		for key := range detail.Config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		fmt.Println()
+		fmt.Println(color.YellowString("Settings:"))
+		for _, key := range keys {
+			fmt.Printf("  %-24s %s\n", key, detail.Config[key])
+		}
+	}
+}
+
+// colorSessionStatus colors a session status by how the session ended.
+func colorSessionStatus(status string) string {
+	switch status {
+	case state.SessionStatusCompleted, state.SessionStatusActive:
+		return color.GreenString(status)
+	case state.SessionStatusFailed, state.SessionStatusStalled:
+		return color.RedString(status)
+	case state.SessionStatusScanned:
+		return color.CyanString(status)
+	default:
+		return color.YellowString(status)
+	}
+}
+
+// parseAge parses a non-negative duration such as "12h", "30d" or "2w".
+func parseAge(value string) (time.Duration, error) {
+	age, err := util.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if age < 0 {
+		return 0, fmt.Errorf("age must not be negative, got %s", value)
+	}
+	return age, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+
+	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show totals across all sync sessions",
+	Long: `Show an overview of every session in the state database: how many
+sessions ran and how they ended, files by final status, the bytes downloaded,
+the average throughput and the success rate, followed by totals per day.
+
+The data is read from the state database alone. --since and --until take a
+date (2025-01-30) or an age (7d, 2w) and limit the overview to the sessions
+started in that range; --until includes the whole day given.`,
+	Example: `  # Totals of every session
+  cloudpull stats
+
+  # Sessions started in the last 30 days, as JSON
+  cloudpull stats --since 30d --json
+
+  # Sessions started in January 2025
+  cloudpull stats --since 2025-01-01 --until 2025-01-31`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+var (
+	statsSince string
+	statsUntil string
+	statsJSON  bool
+)
+
+func init() {
+	statsCmd.Flags().StringVar(&statsSince, "since", "",
+		"Only include sessions started on or after this date or within this age (e.g. 2025-01-30 or 7d)")
+	statsCmd.Flags().StringVar(&statsUntil, "until", "",
+		"Only include sessions started on or before this date or longer ago than this age")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false,
+		"Print the statistics as JSON")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	var statsRange state.StatsRange
+	var err error
+	if statsSince != "" {
+		if statsRange.Since, err = parseStatsTime(statsSince, false); err != nil {
+			return fmt.Errorf("invalid --since: %w", err)
+		}
+	}
+	if statsUntil != "" {
+		if statsRange.Until, err = parseStatsTime(statsUntil, true); err != nil {
+			return fmt.Errorf("invalid --until: %w", err)
+		}
+	}
+	if !statsRange.Since.IsZero() && !statsRange.Until.IsZero() && !statsRange.Since.Before(statsRange.Until) {
+		return fmt.Errorf("--since must be before --until")
+	}
+
+	application, err := app.New()
+	if err != nil {
+		return fmt.Errorf("failed to create application: %w", err)
+	}
+
+	if err := application.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize application: %w", err)
+	}
+	defer application.Stop()
+
+	stats, err := application.GetGlobalStats(context.Background(), statsRange)
+	if err != nil {
+		return err
+	}
+
+	if statsJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	printGlobalStats(stats)
+	return nil
+}
+
+// parseStatsTime parses a local date or an age before now. A date given as
+// an end of range covers that whole day.
+func parseStatsTime(value string, end bool) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if end {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+
+	age, err := parseAge(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a date like 2025-01-30 or an age like 7d, got %q", value)
+	}
+	return time.Now().Add(-age), nil
+}
+
+// printGlobalStats renders the totals and the per-day table.
+func printGlobalStats(stats *state.GlobalStats) {
+	if stats.Sessions == 0 {
+		fmt.Println(color.YellowString("No sessions recorded in this range"))
+		return
+	}
+
+	fmt.Println(color.CyanString("📊 CloudPull Statistics"))
+	fmt.Println()
+	fmt.Printf("Sessions:     %d (%s)\n", stats.Sessions, formatStatusCounts(stats.SessionsByStatus,
+		state.SessionStatusCompleted, state.SessionStatusCompletedWithErrors, state.SessionStatusFailed,
+		state.SessionStatusStalled, state.SessionStatusCancelled, state.SessionStatusStoppedQuota, state.SessionStatusScanned,
+		state.SessionStatusActive, state.SessionStatusPaused))
+	fmt.Printf("Files:        %d (%s)\n", stats.TotalFiles, formatStatusCounts(stats.FilesByStatus,
+		state.FileStatusCompleted, state.FileStatusFailed, state.FileStatusSkipped, state.FileStatusPending))
+	fmt.Printf("Downloaded:   %s of %s\n", util.FormatBytes(stats.CompletedBytes), util.FormatBytes(stats.TotalBytes))
+	fmt.Printf("Time:         %s\n", formatDuration(stats.Duration))
+	fmt.Printf("Speed:        %s/s average\n", util.FormatBytes(int64(stats.AverageBytesPerSecond)))
+	fmt.Printf("Success rate: %.1f%%\n\n", stats.SuccessRate*100)
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Date (UTC)", "Sessions", "Completed", "Failed", "Data"})
+	for _, day := range stats.Daily {
+		t.AppendRow(table.Row{day.Date, day.Sessions, day.CompletedFiles, day.FailedFiles,
+			util.FormatBytes(day.CompletedBytes)})
+	}
+	t.Render()
+}
+
+// formatStatusCounts lists the non-zero counts in the given status order.
+func formatStatusCounts(counts map[string]int64, order ...string) string {
+	result := ""
+	for _, status := range order {
+		if counts[status] == 0 {
+			continue
+		}
+		if result != "" {
+			result += ", "
+		}
+		result += fmt.Sprintf("%d %s", counts[status], status)
+	}
+	if result == "" {
+		return "none"
+	}
+	return result
+}
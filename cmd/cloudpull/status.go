@@ -17,6 +17,7 @@ import (
 
 	"github.com/VatsalSy/CloudPull/internal/app"
 	"github.com/VatsalSy/CloudPull/internal/state"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
 	"github.com/VatsalSy/CloudPull/internal/util"
 	"github.com/VatsalSy/CloudPull/pkg/progress"
 )
@@ -38,7 +39,10 @@ for active syncs.`,
   cloudpull status --detailed
 
   # Monitor status continuously
-  cloudpull status --watch`,
+  cloudpull status --watch
+
+  # Show per-folder progress for a session
+  cloudpull status --tree abc123`,
 	RunE: runStatus,
 }
 
@@ -46,6 +50,9 @@ var (
 	watchStatus    bool
 	detailedStatus bool
 	showHistory    bool
+	historySince   string
+	showTree       bool
+	treeDepth      int
 )
 
 func init() {
@@ -55,6 +62,12 @@ func init() {
 		"Show detailed statistics")
 	statusCmd.Flags().BoolVar(&showHistory, "history", false,
 		"Show completed sessions")
+	statusCmd.Flags().StringVar(&historySince, "since", "",
+		"With --history, only show sessions that ended within this long, e.g. 7d or 2w")
+	statusCmd.Flags().BoolVar(&showTree, "tree", false,
+		"Show per-folder progress for a session")
+	statusCmd.Flags().IntVar(&treeDepth, "depth", -1,
+		"Maximum folder depth shown with --tree (-1 for unlimited)")
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
@@ -63,12 +76,113 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	}
 
 	if showHistory {
-		return showSyncHistory()
+		var since time.Duration
+		if historySince != "" {
+			var err error
+			if since, err = parseAge(historySince); err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+		}
+		return showSyncHistory(since)
+	}
+
+	if showTree {
+		if len(args) == 0 {
+			return fmt.Errorf("--tree requires a session ID")
+		}
+		return showFolderTree(args[0])
 	}
 
 	return showSyncStatus(args)
 }
 
+func showFolderTree(sessionID string) error {
+	application, err := getOrCreateApp()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	session, err := application.GetSession(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	fmt.Printf("%s Folder Progress: %s\n",
+		color.GreenString("▶"),
+		color.CyanString(session.ID))
+	fmt.Println(strings.Repeat("─", 50))
+
+	roots, err := application.GetFolderTree(ctx, sessionID, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load folder tree: %w", err)
+	}
+
+	if len(roots) == 0 {
+		fmt.Println(color.YellowString("No folders scanned yet."))
+		return nil
+	}
+
+	for _, root := range roots {
+		fmt.Println(formatFolderProgress(root))
+		if err := printFolderChildren(ctx, application, sessionID, root, "", 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printFolderChildren prints the subfolders of folder, only querying
+// folders that are known to have children.
+func printFolderChildren(ctx context.Context, application *app.App, sessionID string,
+	folder *state.FolderTree, prefix string, depth int) error {
+
+	if folder.ChildCount == 0 || (treeDepth >= 0 && depth > treeDepth) {
+		return nil
+	}
+
+	children, err := application.GetFolderTree(ctx, sessionID, &folder.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load folder tree: %w", err)
+	}
+
+	for i, child := range children {
+		branch, indent := "├─ ", "│  "
+		if i == len(children)-1 {
+			branch, indent = "└─ ", "   "
+		}
+
+		fmt.Println(prefix + branch + formatFolderProgress(child))
+		if err := printFolderChildren(ctx, application, sessionID, child, prefix+indent, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatFolderProgress renders a folder's name with its rolled-up progress.
+func formatFolderProgress(folder *state.FolderTree) string {
+	percent := 100.0
+	if folder.TotalSize > 0 {
+		percent = float64(folder.DownloadSize) / float64(folder.TotalSize) * 100
+	}
+
+	percentText := fmt.Sprintf("%5.1f%%", percent)
+	switch {
+	case percent >= 100:
+		percentText = color.GreenString(percentText)
+	case percent > 0:
+		percentText = color.YellowString(percentText)
+	}
+
+	return fmt.Sprintf("%s  %s  %s / %s (%d files)",
+		folder.Name, percentText,
+		util.FormatBytes(folder.DownloadSize), util.FormatBytes(folder.TotalSize),
+		folder.FileCount)
+}
+
 func showSyncStatus(args []string) error {
 	fmt.Println(color.CyanString("📊 CloudPull Status"))
 	fmt.Println()
@@ -176,6 +290,9 @@ func showDetailedSession(session ActiveSession) error {
 		util.FormatBytes(session.DownloadedBytes), util.FormatBytes(session.TotalBytes),
 		float64(session.DownloadedBytes)/float64(session.TotalBytes)*100)
 	fmt.Printf("  Remaining  : %s\n", util.FormatBytes(session.TotalBytes-session.DownloadedBytes))
+	if session.FailedFiles > 0 {
+		fmt.Printf("  Failed     : %d (%d permission denied)\n", session.FailedFiles, session.PermissionDenied)
+	}
 
 	fmt.Println()
 
@@ -184,6 +301,9 @@ func showDetailedSession(session ActiveSession) error {
 	fmt.Printf("  Current Speed : %s/s\n", util.FormatBytes(session.Speed))
 	fmt.Printf("  Average Speed : %s/s\n", util.FormatBytes(session.AvgSpeed))
 	fmt.Printf("  Peak Speed    : %s/s\n", util.FormatBytes(session.PeakSpeed))
+	if session.LiveStats {
+		fmt.Printf("  Connections   : %d\n", session.ActiveConnections)
+	}
 	fmt.Printf("  ETA           : %s\n", formatDuration(session.ETA))
 
 	if session.CurrentFile != "" {
@@ -221,11 +341,23 @@ func watchSyncStatus(args []string) error {
 	}
 }
 
-func showSyncHistory() error {
+// showSyncHistory lists finished sessions; a since other than zero limits
+// it to sessions that ended within that long.
+func showSyncHistory(since time.Duration) error {
 	fmt.Println(color.CyanString("📜 CloudPull Sync History"))
 	fmt.Println()
 
 	history := getSyncHistory()
+	if since > 0 {
+		cutoff := time.Now().Add(-since)
+		recent := history[:0]
+		for _, session := range history {
+			if session.EndTime.After(cutoff) {
+				recent = append(recent, session)
+			}
+		}
+		history = recent
+	}
 	if len(history) == 0 {
 		fmt.Println("No completed sync sessions.")
 		return nil
@@ -241,6 +373,12 @@ func showSyncHistory() error {
 			status = color.RedString("✗ Failed")
 		} else if session.Canceled {
 			status = color.YellowString("⚠ Canceled")
+		} else if session.StoppedQuota {
+			status = color.YellowString("⏹ Quota reached")
+		} else if session.CompletedWithErrors {
+			status = color.YellowString("⚠ Completed with errors")
+		} else if session.Stalled {
+			status = color.RedString("✗ Stalled")
 		}
 
 		t.AppendRow(table.Row{
@@ -290,6 +428,10 @@ type ActiveSession struct {
 	CurrentFileSize     int64
 	CurrentFileProgress float64
 	CompletedFiles      int
+	FailedFiles         int
+	PermissionDenied    int
+	ActiveConnections   int64
+	LiveStats           bool
 }
 
 type CompletedFile struct {
@@ -322,7 +464,20 @@ func getActiveSessions() []ActiveSession {
 	var activeSessions []ActiveSession
 	for _, session := range sessions {
 		if session.Status == "active" || session.Status == "paused" {
-			activeSessions = append(activeSessions, convertToActiveSession(session))
+			// The listed record may be older than the session's live progress
+			snapshot, err := app.GetSessionSnapshot(ctx, session.ID)
+			if err != nil {
+				continue
+			}
+
+			active := convertToActiveSession(snapshot.Session)
+			if snapshot.Progress != nil {
+				applyLiveProgress(&active, snapshot.Progress)
+			}
+			if denied, err := app.GetPermissionDeniedFiles(ctx, session.ID); err == nil {
+				active.PermissionDenied = len(denied)
+			}
+			activeSessions = append(activeSessions, active)
 		}
 	}
 
@@ -343,7 +498,9 @@ func getSyncHistory() []SyncSession {
 
 	var history []SyncSession
 	for _, session := range sessions {
-		if session.Status == "completed" || session.Status == "failed" || session.Status == "canceled" {
+		if session.Status == "completed" || session.Status == "failed" || session.Status == "canceled" ||
+			session.Status == state.SessionStatusStoppedQuota || session.Status == state.SessionStatusCompletedWithErrors ||
+			session.Status == state.SessionStatusStalled {
 			history = append(history, convertToSyncSession(session))
 		}
 	}
@@ -462,6 +619,17 @@ type SyncSession struct {
 	TotalBytes int64
 	Failed     bool
 	Canceled   bool
+
+	// StoppedQuota is set when the byte quota ended the session early
+	StoppedQuota bool
+
+	// CompletedWithErrors is set when the session ran to the end with
+	// some files failed
+	CompletedWithErrors bool
+
+	// Stalled is set when the watchdog stopped a session that made no
+	// progress
+	Stalled bool
 }
 
 // safeUint64ToInt safely converts uint64 to int, capping at MaxInt.
@@ -525,6 +693,7 @@ func convertToActiveSession(session *state.Session) ActiveSession {
 		Destination:     session.DestinationPath,
 		TotalFiles:      safeInt64ToInt(session.TotalFiles),
 		CompletedFiles:  safeInt64ToInt(session.CompletedFiles),
+		FailedFiles:     safeInt64ToInt(session.FailedFiles),
 		TotalBytes:      session.TotalBytes,
 		DownloadedBytes: session.CompletedBytes,
 		Speed:           speed,
@@ -534,6 +703,19 @@ func convertToActiveSession(session *state.Session) ActiveSession {
 	}
 }
 
+// applyLiveProgress replaces estimates derived from the database with the
+// download manager's figures for a session running in this process.
+func applyLiveProgress(session *ActiveSession, progress *cloudsync.SyncProgress) {
+	session.Speed = progress.DownloadSpeed
+	session.AvgSpeed = progress.AverageSpeed
+	if session.Speed > session.PeakSpeed {
+		session.PeakSpeed = session.Speed
+	}
+	session.ETA = progress.RemainingTime
+	session.ActiveConnections = progress.ActiveConnections
+	session.LiveStats = true
+}
+
 // convertToSyncSession converts a state.Session to SyncSession.
 func convertToSyncSession(session *state.Session) SyncSession {
 	endTime := time.Now()
@@ -550,5 +732,9 @@ func convertToSyncSession(session *state.Session) SyncSession {
 		TotalBytes: session.TotalBytes,
 		Failed:     session.Status == state.SessionStatusFailed,
 		Canceled:   session.Status == state.SessionStatusCancelled,
+
+		StoppedQuota:        session.Status == state.SessionStatusStoppedQuota,
+		CompletedWithErrors: session.Status == state.SessionStatusCompletedWithErrors,
+		Stalled:             session.Status == state.SessionStatusStalled,
 	}
 }
@@ -3,21 +3,22 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"regexp"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
-	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 
+	"github.com/VatsalSy/CloudPull/internal/api"
 	"github.com/VatsalSy/CloudPull/internal/app"
+	"github.com/VatsalSy/CloudPull/internal/config"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 var syncCmd = &cobra.Command{
@@ -28,6 +29,8 @@ var syncCmd = &cobra.Command{
 You can specify the folder by:
   • Folder ID: The unique identifier from the Drive URL
   • Share URL: The full Google Drive sharing URL
+  • --shared-with-me: Everything in "Shared with me", which is not part
+    of My Drive
   • Nothing: Interactive folder selection`,
 	Example: `  # Interactive folder selection
   cloudpull sync
@@ -38,6 +41,9 @@ You can specify the folder by:
   # Sync using share URL
   cloudpull sync "https://drive.google.com/drive/folders/1ABC123DEF456GHI"
 
+  # Sync the files and folders shared with you
+  cloudpull sync --shared-with-me
+
   # Sync with custom options
   cloudpull sync --output ~/Documents/DriveSync --include "*.pdf" --exclude "temp/*"`,
 	RunE: runSync,
@@ -51,6 +57,25 @@ var (
 	noProgress      bool
 	maxDepth        int
 	noConfirm       bool
+	flatten         bool
+	checksumAlgo    string
+	maxBytes        string
+	maxFiles        int
+	maxAPICalls     int64
+	mirror          bool
+	mirrorTrash     string
+	permanentDelete bool
+	skipPermErrors  bool
+	quiet           bool
+	progressBar     bool
+	starredOnly     bool
+	onlyGoogleDocs  bool
+	skipGoogleDocs  bool
+	resumeExisting  bool
+	sharedWithMe    bool
+	destDateSubdir  string
+	eventsFD        int
+	eventsFile      string
 )
 
 func init() {
@@ -64,15 +89,83 @@ func init() {
 		"Show what would be synced without downloading")
 	syncCmd.Flags().BoolVar(&noProgress, "no-progress", false,
 		"Disable progress bars")
+	syncCmd.Flags().BoolVar(&progressBar, "progress-bar", false,
+		"Draw the progress bar even when stdout is not a terminal")
+	syncCmd.Flags().BoolVarP(&quiet, "quiet", "q", false,
+		"Do not log periodic sync progress")
 	syncCmd.Flags().IntVar(&maxDepth, "max-depth", -1,
 		"Maximum folder depth to sync (-1 for unlimited)")
 	syncCmd.Flags().BoolVarP(&noConfirm, "yes", "y", false,
 		"Skip confirmation prompt")
+	syncCmd.Flags().BoolVar(&flatten, "flatten", false,
+		"Download all files into the output directory without Drive folders")
+	syncCmd.Flags().StringVar(&checksumAlgo, "checksum-algorithm", "",
+		"Checksums to record for downloaded files: md5, sha256, both or none (default: from config)")
+	syncCmd.Flags().StringVar(&maxBytes, "max-bytes", "",
+		"Stop downloading once this much data was downloaded, e.g. 50GB (default: from config)")
+	syncCmd.Flags().IntVar(&maxFiles, "limit", 0,
+		"Download at most this many files, e.g. to try out a setup (default: unlimited)")
+	syncCmd.Flags().Int64Var(&maxAPICalls, "max-api-calls", 0,
+		"Stop the sync after this many Drive API requests (default: from config)")
+	syncCmd.Flags().BoolVar(&mirror, "mirror", false,
+		"Delete local files and folders that no longer exist in Drive after the sync")
+	syncCmd.Flags().StringVar(&mirrorTrash, "mirror-trash", "",
+		"With --mirror, move removed entries into this directory (default: .cloudpull-trash in the output directory)")
+	syncCmd.Flags().BoolVar(&permanentDelete, "permanent-delete", false,
+		"With --mirror, delete removed entries instead of moving them to the trash")
+	syncCmd.Flags().BoolVar(&skipPermErrors, "skip-permission-errors", false,
+		"Do not count files you have no access to toward the maximum errors")
+	syncCmd.Flags().BoolVar(&sharedWithMe, "shared-with-me", false,
+		"Sync the items in \"Shared with me\" instead of a folder")
+	syncCmd.Flags().BoolVar(&starredOnly, "starred-only", false,
+		"Download only starred files")
+	syncCmd.Flags().BoolVar(&onlyGoogleDocs, "only-google-docs", false,
+		"Download only Google Docs, Sheets and other Workspace files as exports")
+	syncCmd.Flags().BoolVar(&skipGoogleDocs, "skip-google-docs", false,
+		"Skip Google Docs, Sheets and other Workspace files")
+	syncCmd.Flags().StringVar(&destDateSubdir, "dest-subdir-by-date", "",
+		"Download into a subdirectory of the output named after today's date, in a Go time layout or strftime pattern")
+	syncCmd.Flags().Lookup("dest-subdir-by-date").NoOptDefVal = "2006-01-02"
+	syncCmd.Flags().BoolVar(&resumeExisting, "resume-existing", false,
+		"Resume an incomplete session of the same folder and destination instead of starting a new one")
+	syncCmd.Flags().IntVar(&eventsFD, "events-fd", 0,
+		"Write progress events as JSON lines to this open file descriptor, e.g. 3")
+	syncCmd.Flags().StringVar(&eventsFile, "events-file", "",
+		"Write progress events as JSON lines to this file")
+	addControlSocketFlag(syncCmd)
+}
+
+// openEventStream returns the writer selected by --events-fd or
+// --events-file, or nil if neither is set.
+func openEventStream() (io.WriteCloser, error) {
+	switch {
+	case eventsFD != 0 && eventsFile != "":
+		return nil, fmt.Errorf("--events-fd and --events-file cannot be used together")
+	case eventsFD < 0:
+		return nil, fmt.Errorf("invalid --events-fd: must be a file descriptor number")
+	case eventsFD > 0:
+		f := os.NewFile(uintptr(eventsFD), "events")
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("invalid --events-fd %d: %w", eventsFD, err)
+		}
+		return f, nil
+	case eventsFile != "":
+		f, err := os.OpenFile(eventsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open events file: %w", err)
+		}
+		return f, nil
+	}
+	return nil, nil
 }
 
 func runSync(cmd *cobra.Command, args []string) error {
+	// The bar is redrawn in place, so by default it is only drawn on a terminal
+	showProgress := !noProgress && !dryRun && (progressBar || stdoutIsTerminal())
+	bar := newSyncProgressBar(showProgress)
+
 	// Initialize app
-	application, err := app.New()
+	application, err := app.New(app.WithLogWriter(bar.wrapLogOutput))
 	if err != nil {
 		return fmt.Errorf("failed to create application: %w", err)
 	}
@@ -94,13 +187,60 @@ func runSync(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize sync engine: %w", err)
 	}
 
+	stopControl, err := startControlServer(application)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
 	fmt.Println(color.CyanString("📂 CloudPull Sync"))
 	fmt.Println()
 
+	var checksumAlgorithm cloudsync.ChecksumAlgorithm
+	if checksumAlgo != "" {
+		checksumAlgorithm, err = cloudsync.ParseChecksumAlgorithm(checksumAlgo)
+		if err != nil {
+			return err
+		}
+	}
+
+	maxTotalBytes, err := config.ParseSize(maxBytes)
+	if err != nil {
+		return fmt.Errorf("invalid --max-bytes: %w", err)
+	}
+	if maxFiles < 0 {
+		return fmt.Errorf("invalid --limit: must not be negative")
+	}
+	if maxAPICalls < 0 {
+		return fmt.Errorf("invalid --max-api-calls: must not be negative")
+	}
+	if onlyGoogleDocs && skipGoogleDocs {
+		return fmt.Errorf("--only-google-docs and --skip-google-docs cannot be used together")
+	}
+	destDateLayout, err := config.ParseDestDateLayout(destDateSubdir)
+	if err != nil {
+		return fmt.Errorf("invalid --dest-subdir-by-date: %w", err)
+	}
+	eventStream, err := openEventStream()
+	if err != nil {
+		return err
+	}
+	if eventStream != nil {
+		defer eventStream.Close()
+	}
+
 	// Get folder to sync
 	var folderID string
-	if len(args) > 0 {
-		folderID = extractFolderID(args[0])
+	if sharedWithMe {
+		if len(args) > 0 {
+			return fmt.Errorf("--shared-with-me cannot be used with a folder")
+		}
+		folderID = api.SharedWithMeFolderID
+	} else if len(args) > 0 {
+		folderID, err = extractFolderID(args[0])
+		if err != nil {
+			return err
+		}
 	} else {
 		// Interactive folder selection
 		folderID = selectDriveFolder()
@@ -111,33 +251,43 @@ func runSync(cmd *cobra.Command, args []string) error {
 
 	// Determine output directory
 	if outputDir == "" {
-		outputDir = viper.GetString("sync.default_directory")
-		if outputDir == "" {
-			home, _ := os.UserHomeDir()
-			// Sanitize folderID to prevent path traversal
-			cleanedFolderID := filepath.Clean(folderID)
-			// Check for any path separators
-			if strings.ContainsAny(cleanedFolderID, "/\\") {
-				return fmt.Errorf("invalid folder ID: contains path separators")
-			}
-			// Remove any leading slashes or dots
-			cleanedFolderID = strings.TrimLeft(cleanedFolderID, "./")
-			// Ensure it doesn't contain parent directory references
-			if strings.Contains(cleanedFolderID, "..") {
-				return fmt.Errorf("invalid folder ID: contains directory traversal")
+		outputDir, err = defaultOutputDir(application, folderID)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Offer to continue an interrupted sync of the same folder
+	if !resumeExisting && !noConfirm && !dryRun {
+		existing, err := application.FindResumableSession(context.Background(), folderID, outputDir, destDateLayout)
+		if err != nil {
+			return fmt.Errorf("failed to look up earlier sessions: %w", err)
+		}
+		if existing != nil {
+			fmt.Printf("Found %s session %s of this folder from %s (%d/%d files).\n",
+				existing.Status, existing.ID, existing.StartTime.Format("Jan 2, 2006 3:04 PM"),
+				existing.CompletedFiles, existing.TotalFiles)
+			prompt := &survey.Confirm{
+				Message: "Resume it instead of starting a new session?",
+				Default: true,
 			}
-			outputDir = filepath.Join(home, "CloudPull", cleanedFolderID)
-			// Validate the final path doesn't escape the base directory
-			baseDir := filepath.Join(home, "CloudPull")
-			if !strings.HasPrefix(filepath.Clean(outputDir), filepath.Clean(baseDir)) {
-				return fmt.Errorf("invalid output directory: path traversal detected")
+			if err := survey.AskOne(prompt, &resumeExisting); err != nil {
+				if err.Error() == "interrupt" {
+					return fmt.Errorf("sync canceled by user")
+				}
+				return fmt.Errorf("failed to get user confirmation: %w", err)
 			}
+			fmt.Println()
 		}
 	}
 
 	// Confirm sync settings
 	fmt.Println(color.YellowString("Sync Configuration:"))
-	fmt.Printf("  Source: Google Drive folder %s\n", folderID)
+	if folderID == api.SharedWithMeFolderID {
+		fmt.Println("  Source: Google Drive, Shared with me")
+	} else {
+		fmt.Printf("  Source: Google Drive folder %s\n", folderID)
+	}
 	fmt.Printf("  Destination: %s\n", outputDir)
 	if len(includePatterns) > 0 {
 		fmt.Printf("  Include: %s\n", strings.Join(includePatterns, ", "))
@@ -145,6 +295,34 @@ func runSync(cmd *cobra.Command, args []string) error {
 	if len(excludePatterns) > 0 {
 		fmt.Printf("  Exclude: %s\n", strings.Join(excludePatterns, ", "))
 	}
+	if destDateLayout != "" {
+		fmt.Printf("  Dated subdirectory: %s\n", time.Now().Format(destDateLayout))
+	}
+	if flatten {
+		fmt.Println("  Layout: flattened (duplicate names get a numbered suffix)")
+	}
+	if maxTotalBytes > 0 {
+		fmt.Printf("  Download cap: %s\n", util.FormatBytes(maxTotalBytes))
+	}
+	if maxAPICalls > 0 {
+		fmt.Printf("  API call cap: %d requests\n", maxAPICalls)
+	}
+	if mirror {
+		switch {
+		case dryRun:
+			fmt.Println("  Mirror: local entries missing from Drive are only listed")
+		case permanentDelete:
+			fmt.Println(color.RedString("  Mirror: local entries missing from Drive are deleted"))
+		case mirrorTrash != "":
+			fmt.Printf("  Mirror: local entries missing from Drive are moved to %s\n", mirrorTrash)
+		default:
+			fmt.Printf("  Mirror: local entries missing from Drive are moved to %s\n",
+				filepath.Join(outputDir, cloudsync.TrashDirName))
+		}
+	}
+	if resumeExisting {
+		fmt.Println("  Session: an incomplete session of this folder is resumed if there is one")
+	}
 	if dryRun {
 		fmt.Println(color.YellowString("  Mode: DRY RUN (no files will be downloaded)"))
 	}
@@ -152,8 +330,12 @@ func runSync(cmd *cobra.Command, args []string) error {
 
 	if !dryRun && !noConfirm {
 		var proceed bool
+		message := "Start sync?"
+		if mirror {
+			message = "Start sync? Local files missing from Drive will be removed."
+		}
 		prompt := &survey.Confirm{
-			Message: "Start sync?",
+			Message: message,
 			Default: true,
 		}
 		err := survey.AskOne(prompt, &proceed)
@@ -180,6 +362,24 @@ func runSync(cmd *cobra.Command, args []string) error {
 		ExcludePatterns: excludePatterns,
 		MaxDepth:        maxDepth,
 		DryRun:          dryRun,
+		Flatten:         flatten,
+
+		ChecksumAlgorithm: checksumAlgorithm,
+		DestDateSubdir:    destDateLayout,
+		MaxTotalBytes:     maxTotalBytes,
+		MaxFiles:          maxFiles,
+		MaxAPICalls:       maxAPICalls,
+		Mirror:            mirror,
+		MirrorTrashDir:    mirrorTrash,
+		PermanentDelete:   permanentDelete,
+
+		SkipPermissionErrors: skipPermErrors,
+		QuietProgress:        quiet,
+		StarredOnly:          starredOnly,
+		OnlyGoogleDocs:       onlyGoogleDocs,
+		SkipGoogleDocs:       skipGoogleDocs,
+		ResumeExisting:       resumeExisting,
+		EventStream:          eventStream,
 	}
 
 	// Start sync with progress monitoring
@@ -188,7 +388,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	application.NotifyStop(sigChan)
 	defer signal.Stop(sigChan)
 
 	// Start sync session
@@ -206,9 +406,9 @@ func runSync(cmd *cobra.Command, args []string) error {
 
 	// Monitor progress
 	progressDone := make(chan struct{})
-	if !noProgress && !dryRun {
+	if showProgress {
 		go func() {
-			monitorSyncProgress(application, completionChan)
+			monitorSyncProgress(application, bar, completionChan)
 			close(progressDone)
 		}()
 	}
@@ -253,7 +453,7 @@ func runSync(cmd *cobra.Command, args []string) error {
 			}
 
 			// Wait for progress monitoring to finish with timeout
-			if !noProgress && !dryRun {
+			if showProgress {
 				select {
 				case <-progressDone:
 					// Progress monitoring finished
@@ -270,40 +470,110 @@ func runSync(cmd *cobra.Command, args []string) error {
 	// Sync completed successfully
 	fmt.Println(color.GreenString("\n✅ Sync completed successfully!"))
 
+	if mirror {
+		// The mirror cleanup runs after the downloads; wait for the engine
+		<-completionChan
+		printMirrorSummary(application, sessionID)
+	}
+
+	printPermissionSummary(application, sessionID)
+
 	return nil
 }
 
-func extractFolderID(input string) string {
-	// Extract folder ID from URL or return as-is
-	if strings.Contains(input, "drive.google.com") {
-		parts := strings.Split(input, "/")
-		for i, part := range parts {
-			if part == "folders" && i+1 < len(parts) {
-				folderID := parts[i+1]
-				// Strip any query parameters
-				if idx := strings.Index(folderID, "?"); idx != -1 {
-					folderID = folderID[:idx]
-				}
-				// Validate folder ID pattern
-				if isValidDriveID(folderID) {
-					return folderID
-				}
-				return ""
-			}
+// maxListedPermissionErrors caps the files listed by printPermissionSummary.
+const maxListedPermissionErrors = 10
+
+// printPermissionSummary lists the files Drive refused access to.
+func printPermissionSummary(application *app.App, sessionID string) {
+	files, err := application.GetPermissionDeniedFiles(context.Background(), sessionID)
+	if err != nil {
+		fmt.Printf("%s Failed to load permission errors: %v\n", color.RedString("❌"), err)
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Println(color.YellowString("Permission denied for %d files:", len(files)))
+	for i, file := range files {
+		if i == maxListedPermissionErrors {
+			fmt.Printf("  ... and %d more (see 'cloudpull errors %s --type %s')\n",
+				len(files)-i, sessionID, cloudsync.ErrorTypePermissionDenied)
+			break
 		}
+		fmt.Printf("  - %s\n", file.Path)
+	}
+}
+
+// printMirrorSummary lists the local entries removed by a mirror sync.
+func printMirrorSummary(application *app.App, sessionID string) {
+	deletions, err := application.GetMirrorDeletions(context.Background(), sessionID)
+	if err != nil {
+		fmt.Printf("%s Failed to load mirror deletions: %v\n", color.RedString("❌"), err)
+		return
 	}
-	// Validate if input is already a valid Drive ID
-	if isValidDriveID(input) {
-		return input
+	if len(deletions) == 0 {
+		fmt.Println("Mirror: no local entries to remove")
+		return
+	}
+
+	verb := "removed"
+	if deletions[0].DryRun {
+		verb = "would remove"
+	}
+	fmt.Println(color.YellowString("Mirror: %s %d local entries missing from Drive:", verb, len(deletions)))
+	for _, deletion := range deletions {
+		line := fmt.Sprintf("  - %s", deletion.Path)
+		if deletion.Kind == "folder" {
+			line += "/"
+		}
+		if deletion.TrashPath.Valid {
+			line += " → " + deletion.TrashPath.String
+		}
+		fmt.Println(line)
 	}
-	return ""
 }
 
-// isValidDriveID validates if a string matches the Google Drive ID pattern.
-func isValidDriveID(id string) bool {
-	// Google Drive IDs are typically 10+ characters containing alphanumeric, underscore, and hyphen
-	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_-]{10,}$`, id)
-	return matched
+// defaultOutputDir returns the configured sync directory, or a directory
+// named after folderID below ~/CloudPull.
+func defaultOutputDir(application *app.App, folderID string) (string, error) {
+	outputDir := application.GetConfig().GetString("sync.default_directory")
+	if outputDir != "" {
+		return outputDir, nil
+	}
+
+	home, _ := os.UserHomeDir()
+	// Sanitize folderID to prevent path traversal
+	cleanedFolderID := filepath.Clean(folderID)
+	// Check for any path separators
+	if strings.ContainsAny(cleanedFolderID, "/\\") {
+		return "", fmt.Errorf("invalid folder ID: contains path separators")
+	}
+	// Remove any leading slashes or dots
+	cleanedFolderID = strings.TrimLeft(cleanedFolderID, "./")
+	// Ensure it doesn't contain parent directory references
+	if strings.Contains(cleanedFolderID, "..") {
+		return "", fmt.Errorf("invalid folder ID: contains directory traversal")
+	}
+	outputDir = filepath.Join(home, "CloudPull", cleanedFolderID)
+	// Validate the final path doesn't escape the base directory
+	baseDir := filepath.Join(home, "CloudPull")
+	if !strings.HasPrefix(filepath.Clean(outputDir), filepath.Clean(baseDir)) {
+		return "", fmt.Errorf("invalid output directory: path traversal detected")
+	}
+
+	return outputDir, nil
+}
+
+// extractFolderID returns the folder ID named by an ID or a pasted Drive
+// URL.
+func extractFolderID(input string) (string, error) {
+	folderID, err := api.ParseDriveID(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid folder ID or URL: %w", err)
+	}
+	return folderID, nil
 }
 
 func selectDriveFolder() string {
@@ -320,73 +590,10 @@ func selectDriveFolder() string {
 		// Handle user cancellation or I/O errors
 		return ""
 	}
-	return extractFolderID(folderID)
-}
-
-func monitorSyncProgress(app *app.App, completionChan <-chan struct{}) {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
-	var bar *progressbar.ProgressBar
-	lastFiles := int64(0)
-
-	// Create a copy of the completion channel to avoid consuming it
-	done := make(chan struct{})
-	go func() {
-		<-completionChan
-		close(done)
-	}()
-
-	for {
-		select {
-		case <-done:
-			// Sync completed
-			if bar != nil {
-				bar.Finish()
-			}
-			return
-		case <-ticker.C:
-			progress := app.GetProgress()
-			if progress == nil {
-				continue
-			}
-
-			// Initialize progress bar on first update
-			if bar == nil && progress.TotalFiles > 0 {
-				bar = progressbar.NewOptions64(
-					progress.TotalFiles,
-					progressbar.OptionSetDescription("Syncing files"),
-					progressbar.OptionSetWidth(40),
-					progressbar.OptionShowCount(),
-					progressbar.OptionShowIts(),
-					progressbar.OptionSetItsString("files"),
-					progressbar.OptionOnCompletion(func() {
-						fmt.Print("\n")
-					}),
-					progressbar.OptionSpinnerType(14),
-					progressbar.OptionFullWidth(),
-					progressbar.OptionSetRenderBlankState(true),
-				)
-			}
-
-			// Update progress bar max if TotalFiles increased
-			if bar != nil && progress.TotalFiles > bar.GetMax64() {
-				bar.ChangeMax64(progress.TotalFiles)
-			}
-
-			// Update progress
-			if bar != nil && progress.CompletedFiles > lastFiles {
-				_ = bar.Set64(progress.CompletedFiles)
-				lastFiles = progress.CompletedFiles
-			}
-
-			// Check if complete via status
-			if progress.Status == "stopped" || progress.Status == "completed" {
-				if bar != nil {
-					_ = bar.Finish()
-				}
-				return
-			}
-		}
+	folderID, err = extractFolderID(folderID)
+	if err != nil {
+		fmt.Println(err)
+		return ""
 	}
+	return folderID
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 
@@ -110,40 +111,81 @@ func TestRateLimiter(t *testing.T) {
 	})
 }
 
-func TestAdaptiveRateLimiter(t *testing.T) {
-	t.Run("rate limit adjustment", func(t *testing.T) {
-		// Skip this test in CI or when -short flag is used
-		if testing.Short() || os.Getenv("CI") == "true" {
-			t.Skip("Skipping long-running adaptive rate limiter test in CI")
-		}
-
-		config := &RateLimiterConfig{
-			RateLimit: 10,
-			BurstSize: 20,
-		}
-		arl := NewAdaptiveRateLimiter(config)
+func TestRateLimiterAdaptsToThrottling(t *testing.T) {
+	rl := NewRateLimiter(&RateLimiterConfig{
+		RateLimit:    20,
+		BurstSize:    40,
+		MinRateLimit: 4,
+		MaxRateLimit: 30,
+	})
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	// Throttled responses arriving together lower the rate once
+	rl.RecordThrottle()
+	rl.RecordThrottle()
+	assert.Equal(t, 10, rl.CurrentRateLimit())
+
+	// Later throttling keeps halving, down to the minimum
+	for i := 0; i < 3; i++ {
+		now = now.Add(throttleCooldown)
+		rl.RecordThrottle()
+	}
+	assert.Equal(t, 4, rl.CurrentRateLimit())
+	assert.Equal(t, rate.Limit(4), rl.limiter.Limit())
 
-		// Record some rate limit errors
-		arl.RecordRateLimitError()
-		arl.RecordRateLimitError()
+	// Recovery needs both sustained success and time since the last change
+	for i := 0; i < recoverySuccesses; i++ {
+		rl.RecordSuccess()
+	}
+	assert.Equal(t, 4, rl.CurrentRateLimit())
 
-		// Rate limit should be reduced
-		assert.Less(t, arl.GetCurrentRateLimit(), 10)
+	now = now.Add(recoveryInterval)
+	rl.RecordSuccess()
+	assert.Equal(t, 7, rl.CurrentRateLimit())
 
-		// Record successes
-		for i := 0; i < 5; i++ {
-			arl.RecordSuccess()
-			time.Sleep(10 * time.Millisecond)
+	// It grows in steps up to the maximum, never beyond it
+	for i := 0; i < 20; i++ {
+		now = now.Add(recoveryInterval)
+		for j := 0; j < recoverySuccesses; j++ {
+			rl.RecordSuccess()
 		}
+	}
+	assert.Equal(t, 30, rl.CurrentRateLimit())
+	assert.Equal(t, rate.Limit(30), rl.limiter.Limit())
 
-		// Wait for adjustment period
-		time.Sleep(31 * time.Second)
-		arl.RecordSuccess()
+	metrics := rl.GetMetrics()
+	assert.Equal(t, int64(5), metrics.Throttles)
+	assert.Equal(t, 30, metrics.CurrentRateLimit)
+}
 
-		// Rate limit should start increasing
-		currentLimit := arl.GetCurrentRateLimit()
-		assert.Greater(t, currentLimit, 1)
+func TestRateLimiterClampsStartingRate(t *testing.T) {
+	rl := NewRateLimiter(&RateLimiterConfig{
+		RateLimit:       20,
+		BurstSize:       40,
+		BatchRateLimit:  10,
+		ExportRateLimit: 5,
+		MaxRateLimit:    8,
 	})
+	assert.Equal(t, 8, rl.CurrentRateLimit())
+	assert.Equal(t, rate.Limit(8), rl.limiter.Limit())
+	assert.Equal(t, rate.Limit(4), rl.batchLimiter.Limit())
+
+	// Recovery after throttling returns to the maximum, not the higher
+	// configured rate
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+	rl.RecordThrottle()
+	for i := 0; i < 10; i++ {
+		now = now.Add(recoveryInterval)
+		for j := 0; j < recoverySuccesses; j++ {
+			rl.RecordSuccess()
+		}
+	}
+	assert.Equal(t, 8, rl.CurrentRateLimit())
+
+	rl = NewRateLimiter(&RateLimiterConfig{RateLimit: 2, BurstSize: 4, MinRateLimit: 5, MaxRateLimit: 10})
+	assert.Equal(t, 5, rl.CurrentRateLimit())
 }
 
 func TestAuthManager(t *testing.T) {
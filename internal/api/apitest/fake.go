@@ -0,0 +1,370 @@
+// Package apitest provides an in-memory Drive for testing code that
+// depends on api.DriveAPI without making API calls.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+/**
+ * In-Memory Drive
+ *
+ * Features:
+ * - Implements api.DriveAPI over folders and files held in memory
+ * - Paginated listings, byte range reads and exports
+ * - Injected per-item errors and per-method call counts
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// FolderMimeType is the MIME type of Drive folders.
+const FolderMimeType = "application/vnd.google-apps.folder"
+
+// DefaultEmail is the account email reported by a new FakeDrive.
+const DefaultEmail = "tester@example.com"
+
+type fakeItem struct {
+	info    *api.FileInfo
+	content []byte
+}
+
+// FakeDrive is an in-memory api.DriveAPI. Items are listed in the order
+// they were added. Unknown IDs fail like Drive does, with a 404
+// *googleapi.Error. It is safe for concurrent use.
+type FakeDrive struct {
+	items    map[string]*fakeItem
+	children map[string][]string
+	errs     map[string]error
+	calls    map[string]int
+	email    string
+	pageSize int
+	mu       sync.Mutex
+}
+
+var _ api.DriveAPI = (*FakeDrive)(nil)
+
+// NewFakeDrive creates an empty drive whose My Drive folder has the ID
+// "root". Items added below api.SharedWithMeFolderID are listed as shared
+// with the user and have no parents, like items outside My Drive.
+func NewFakeDrive() *FakeDrive {
+	d := &FakeDrive{
+		items:    make(map[string]*fakeItem),
+		children: make(map[string][]string),
+		errs:     make(map[string]error),
+		calls:    make(map[string]int),
+		email:    DefaultEmail,
+	}
+	d.items[api.RootFolderID] = &fakeItem{info: &api.FileInfo{
+		ID:       api.RootFolderID,
+		Name:     "My Drive",
+		MimeType: FolderMimeType,
+		IsFolder: true,
+	}}
+	d.items[api.SharedWithMeFolderID] = &fakeItem{info: &api.FileInfo{
+		ID:       api.SharedWithMeFolderID,
+		Name:     "Shared with me",
+		MimeType: FolderMimeType,
+		IsFolder: true,
+	}}
+	return d
+}
+
+// SetEmail sets the email GetAccountEmail returns.
+func (d *FakeDrive) SetEmail(email string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.email = email
+}
+
+// SetPageSize splits folder listings into pages of size items; 0 lists
+// each folder in a single page.
+func (d *FakeDrive) SetPageSize(size int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pageSize = size
+}
+
+// Add adds an item below parentID, which must already exist. The content
+// is served by downloads and exports. Parents is set from parentID.
+func (d *FakeDrive) Add(parentID string, info *api.FileInfo, content []byte) *api.FileInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.items[parentID]; !ok {
+		panic(fmt.Sprintf("apitest: parent %q does not exist", parentID))
+	}
+	info.Parents = []string{parentID}
+	if parentID == api.SharedWithMeFolderID {
+		info.Parents = nil
+	}
+	d.items[info.ID] = &fakeItem{info: info, content: content}
+	d.children[parentID] = append(d.children[parentID], info.ID)
+	return info
+}
+
+// AddFolder adds a folder named name below parentID.
+func (d *FakeDrive) AddFolder(parentID, id, name string) *api.FileInfo {
+	return d.Add(parentID, &api.FileInfo{
+		ID:       id,
+		Name:     name,
+		MimeType: FolderMimeType,
+		IsFolder: true,
+	}, nil)
+}
+
+// AddFile adds a plain text file holding content below parentID, with the
+// size and MD5 checksum Drive would report.
+func (d *FakeDrive) AddFile(parentID, id, name, content string) *api.FileInfo {
+	sum := md5.Sum([]byte(content))
+	return d.Add(parentID, &api.FileInfo{
+		ID:          id,
+		Name:        name,
+		MimeType:    "text/plain",
+		Size:        int64(len(content)),
+		MD5Checksum: hex.EncodeToString(sum[:]),
+	}, []byte(content))
+}
+
+// FailWith makes every later call naming id return err; a nil err clears
+// it. Listings of a failing folder fail too.
+func (d *FakeDrive) FailWith(id string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		delete(d.errs, id)
+		return
+	}
+	d.errs[id] = err
+}
+
+// Calls returns how many times the DriveAPI method named method was called.
+func (d *FakeDrive) Calls(method string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[method]
+}
+
+// lookup counts a call to method and returns the item with the given id.
+func (d *FakeDrive) lookup(method, id string) (*fakeItem, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.calls[method]++
+	if err := d.errs[id]; err != nil {
+		return nil, err
+	}
+	item, ok := d.items[id]
+	if !ok {
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "File not found: " + id}
+	}
+	return item, nil
+}
+
+// ListFiles lists one page of the items in a folder.
+func (d *FakeDrive) ListFiles(ctx context.Context, folderID, pageToken string) ([]*api.FileInfo, string, error) {
+	return d.ListFilesMatching(ctx, folderID, pageToken, nil)
+}
+
+// ListFilesMatching lists one page of the items in a folder that match
+// filter; folders always match.
+func (d *FakeDrive) ListFilesMatching(ctx context.Context, folderID, pageToken string,
+	filter *api.ListFilter) ([]*api.FileInfo, string, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	if _, err := d.lookup("ListFiles", folderID); err != nil {
+		return nil, "", err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var matching []*api.FileInfo
+	for _, id := range d.children[folderID] {
+		info := d.items[id].info
+		if filter != nil && filter.StarredOnly && !info.Starred && !info.IsFolder {
+			continue
+		}
+		copied := *info
+		matching = append(matching, &copied)
+	}
+
+	start := 0
+	if pageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(pageToken); err != nil || start > len(matching) {
+			return nil, "", errors.Errorf("invalid page token %q", pageToken)
+		}
+	}
+	end := len(matching)
+	if d.pageSize > 0 && start+d.pageSize < end {
+		end = start + d.pageSize
+	}
+
+	next := ""
+	if end < len(matching) {
+		next = strconv.Itoa(end)
+	}
+	return matching[start:end], next, nil
+}
+
+// GetFile returns a copy of the metadata of an item.
+func (d *FakeDrive) GetFile(ctx context.Context, fileID string) (*api.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, err := d.lookup("GetFile", fileID)
+	if err != nil {
+		return nil, err
+	}
+	copied := *item.info
+	return &copied, nil
+}
+
+// ReadFile returns the content of a file no larger than limit bytes.
+func (d *FakeDrive) ReadFile(ctx context.Context, fileID string, limit int64) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, err := d.lookup("ReadFile", fileID)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(item.content)) > limit {
+		return nil, errors.Errorf("file is larger than %d bytes", limit)
+	}
+	return append([]byte(nil), item.content...), nil
+}
+
+// GetFileContent returns the bytes startOffset to endOffset, inclusive,
+// of a file as a 206 response. A negative endOffset from offset 0 returns
+// the whole file as a 200 response, without a size.
+func (d *FakeDrive) GetFileContent(ctx context.Context, fileID string, startOffset, endOffset int64) (*http.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	item, err := d.lookup("GetFileContent", fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(item.content))
+	if endOffset < 0 && startOffset == 0 {
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          io.NopCloser(bytes.NewReader(item.content)),
+			ContentLength: -1,
+		}, nil
+	}
+	if endOffset < 0 {
+		endOffset = size - 1
+	}
+	if startOffset < 0 || startOffset > endOffset || startOffset >= size {
+		return nil, &googleapi.Error{Code: http.StatusRequestedRangeNotSatisfiable, Message: "invalid range"}
+	}
+	if endOffset >= size {
+		endOffset = size - 1
+	}
+
+	chunk := item.content[startOffset : endOffset+1]
+	return &http.Response{
+		Status:        "206 Partial Content",
+		StatusCode:    http.StatusPartialContent,
+		Header:        http.Header{"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", startOffset, endOffset, size)}},
+		Body:          io.NopCloser(bytes.NewReader(chunk)),
+		ContentLength: int64(len(chunk)),
+	}, nil
+}
+
+// DownloadFile writes the content of a file to destPath; Google Workspace
+// files are exported instead.
+func (d *FakeDrive) DownloadFile(ctx context.Context, fileID, destPath string, progressFn func(downloaded, total int64)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	item, err := d.lookup("DownloadFile", fileID)
+	if err != nil {
+		return err
+	}
+	if item.info.CanExport {
+		return d.ExportFile(ctx, fileID, item.info.ExportFormat, destPath, progressFn)
+	}
+	return writeContent(destPath, item.content, progressFn)
+}
+
+// ExportFile writes the content of a Google Workspace file to destPath.
+// Items added without content export as "exported <id>".
+func (d *FakeDrive) ExportFile(ctx context.Context, fileID, mimeType, destPath string, progressFn func(downloaded, total int64)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	item, err := d.lookup("ExportFile", fileID)
+	if err != nil {
+		return err
+	}
+	content := item.content
+	if content == nil {
+		content = []byte("exported " + fileID)
+	}
+	return writeContent(destPath, content, progressFn)
+}
+
+// GetAccountEmail returns the email set by SetEmail.
+func (d *FakeDrive) GetAccountEmail(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls["GetAccountEmail"]++
+	return d.email, nil
+}
+
+// GetRootFolderID returns "root".
+func (d *FakeDrive) GetRootFolderID() string {
+	return api.RootFolderID
+}
+
+// GetAPICallCount returns the number of calls made to every method but
+// GetRootFolderID and GetAPICallCount.
+func (d *FakeDrive) GetAPICallCount() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var total int64
+	for _, n := range d.calls {
+		total += int64(n)
+	}
+	return total
+}
+
+// writeContent writes content to path, creating its directory, and reports
+// it as fully downloaded.
+func writeContent(path string, content []byte, progressFn func(downloaded, total int64)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if progressFn != nil {
+		progressFn(int64(len(content)), int64(len(content)))
+	}
+	return nil
+}
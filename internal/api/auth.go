@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -27,6 +28,8 @@ import (
  * - Secure token storage with file permissions
  * - Browser-based authentication flow
  * - Token validation and expiry handling
+ * - Refreshed tokens written back to the token file
+ * - Re-authorization when Drive rejects the token mid-sync
  *
  * Author: CloudPull Team
  * Updated: 2025-01-29
@@ -41,15 +44,31 @@ const (
 
 	// HTTP client timeout for all requests.
 	httpTimeout = 30 * time.Second
+
+	// Reauthorize calls this soon after a successful refresh reuse it.
+	reauthCoalesceWindow = 30 * time.Second
 )
 
+// ErrReauthRequired means the saved authorization was revoked or expired
+// and cannot be refreshed, so the user has to authenticate again.
+var ErrReauthRequired = errors.NewSimple("re-authentication required")
+
 // AuthManager handles OAuth2 authentication for Google Drive.
 type AuthManager struct {
 	config     *oauth2.Config
 	httpClient *http.Client
+	transport  *http.Transport
 	token      *oauth2.Token
 	logger     *logger.Logger
 	tokenPath  string
+
+	// tokenSource refreshes tokens; nil uses the OAuth2 config
+	tokenSource func(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
+
+	// source authorizes the latest client; Reauthorize swaps its token
+	source         *savingTokenSource
+	reauthMu       sync.Mutex
+	reauthorizedAt time.Time
 }
 
 // NewAuthManager creates a new authentication manager.
@@ -86,6 +105,12 @@ func NewAuthManager(credentialsPath, tokenPath string, logger *logger.Logger) (*
 	}, nil
 }
 
+// SetTransportConfig sets the connection pool used by the clients
+// returned afterwards.
+func (am *AuthManager) SetTransportConfig(config *TransportConfig) {
+	am.transport = NewTransport(config)
+}
+
 // GetClient returns an authenticated HTTP client for Google Drive API.
 func (am *AuthManager) GetClient(ctx context.Context) (*http.Client, error) {
 	token, err := am.getToken(ctx)
@@ -107,13 +132,155 @@ func (am *AuthManager) GetClient(ctx context.Context) (*http.Client, error) {
 	}
 
 	am.token = token
-	// Create HTTP client with consistent timeout
-	httpClient := am.config.Client(ctx, token)
-	httpClient.Timeout = httpTimeout
-	am.httpClient = httpClient
+	am.httpClient = am.newHTTPClient(ctx, token)
 	return am.httpClient, nil
 }
 
+// newHTTPClient returns a client authorized with token whose refreshed
+// tokens are saved to the token file.
+func (am *AuthManager) newHTTPClient(ctx context.Context, token *oauth2.Token) *http.Client {
+	source := &savingTokenSource{
+		am:   am,
+		base: oauth2.ReuseTokenSource(token, am.newTokenSource(ctx, token)),
+		last: token.AccessToken,
+	}
+
+	am.source = source
+
+	if am.transport == nil {
+		am.transport = NewTransport(nil)
+	}
+
+	// Create HTTP client with consistent timeout
+	return &http.Client{
+		Transport: &oauth2.Transport{Source: source, Base: am.transport},
+		Timeout:   httpTimeout,
+	}
+}
+
+// newTokenSource returns the source that refreshes token.
+func (am *AuthManager) newTokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	if am.tokenSource != nil {
+		return am.tokenSource(ctx, token)
+	}
+	return am.config.TokenSource(ctx, token)
+}
+
+// savingTokenSource saves every new token of its base source, so tokens
+// refreshed during a sync survive it.
+type savingTokenSource struct {
+	am   *AuthManager
+	base oauth2.TokenSource
+	last string
+	mu   sync.Mutex
+}
+
+// Token returns the current token, saving it when it was refreshed.
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	base := s.base
+	s.mu.Unlock()
+
+	token, err := base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.AccessToken != s.last {
+		s.last = token.AccessToken
+		if err := s.am.saveToken(token); err != nil {
+			s.am.logger.Warn("Failed to save refreshed token", "error", err)
+		}
+	}
+
+	return token, nil
+}
+
+// ValidateToken refreshes the saved token and saves the result, so that a
+// revoked or expired authorization is reported before a sync starts rather
+// than by an API call deep into it. Such failures wrap ErrReauthRequired.
+func (am *AuthManager) ValidateToken(ctx context.Context) error {
+	token, err := am.loadToken()
+	if err != nil {
+		return reauthError(err)
+	}
+
+	if token.RefreshToken == "" {
+		if token.Valid() {
+			return nil
+		}
+		return reauthError(errors.NewSimple("the token has expired and cannot be refreshed"))
+	}
+
+	// Without an access token the source always asks for a new one
+	refreshed, err := am.newTokenSource(ctx, &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.Response != nil &&
+			retrieveErr.Response.StatusCode >= 400 && retrieveErr.Response.StatusCode < 500 {
+			return reauthError(err)
+		}
+		return errors.Wrap(err, "failed to refresh token")
+	}
+
+	// Refresh responses usually leave out the refresh token
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	if err := am.saveToken(refreshed); err != nil {
+		return err
+	}
+
+	am.token = refreshed
+	am.logger.Debug("Token validated", "expiry", refreshed.Expiry)
+	return nil
+}
+
+// reset makes the source hand out base's tokens from now on; token is
+// already saved.
+func (s *savingTokenSource) reset(base oauth2.TokenSource, token *oauth2.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.base = base
+	s.last = token.AccessToken
+}
+
+// Reauthorize refreshes the saved token after Drive rejected the current
+// one mid-sync, and switches the client returned by GetClient to the new
+// token. Concurrent callers wait for a single refresh. Like ValidateToken,
+// failures that need the user to authenticate again wrap ErrReauthRequired.
+func (am *AuthManager) Reauthorize(ctx context.Context) error {
+	am.reauthMu.Lock()
+	defer am.reauthMu.Unlock()
+
+	// Callers that waited for a refresh that just succeeded use its token
+	if time.Since(am.reauthorizedAt) < reauthCoalesceWindow {
+		return nil
+	}
+
+	am.logger.Warn("Drive rejected the authorization, refreshing the token")
+	if err := am.ValidateToken(ctx); err != nil {
+		return err
+	}
+
+	// The client outlives ctx, so its refreshes must not depend on it
+	if am.source != nil && am.token != nil {
+		am.source.reset(oauth2.ReuseTokenSource(am.token, am.newTokenSource(context.Background(), am.token)), am.token)
+	}
+
+	am.reauthorizedAt = time.Now()
+	am.logger.Info("Token refreshed, continuing")
+	return nil
+}
+
+// reauthError explains how to recover from an unusable authorization.
+func reauthError(cause error) error {
+	return errors.Errorf("%w: %v; run 'cloudpull auth --force' to authorize CloudPull again",
+		ErrReauthRequired, cause)
+}
+
 // GetDriveService returns an authenticated Drive service.
 func (am *AuthManager) GetDriveService(ctx context.Context) (*drive.Service, error) {
 	client, err := am.GetClient(ctx)
@@ -198,8 +365,7 @@ func (am *AuthManager) shouldRefreshToken(token *oauth2.Token) bool {
 
 // refreshToken refreshes the OAuth2 token.
 func (am *AuthManager) refreshToken(ctx context.Context, token *oauth2.Token) (*oauth2.Token, error) {
-	tokenSource := am.config.TokenSource(ctx, token)
-	newToken, err := tokenSource.Token()
+	newToken, err := am.newTokenSource(ctx, token).Token()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to refresh token")
 	}
@@ -219,8 +385,13 @@ func (am *AuthManager) ExchangeAuthCode(ctx context.Context, authCode string) (*
 		return nil, errors.NewSimple("authorization code cannot be empty")
 	}
 
-	// Exchange code for token
-	token, err := am.config.Exchange(ctx, authCode)
+	return am.exchange(ctx, am.config, authCode)
+}
+
+// exchange trades an authorization code obtained with config for a token,
+// saves it and makes it the current token.
+func (am *AuthManager) exchange(ctx context.Context, config *oauth2.Config, authCode string) (*oauth2.Token, error) {
+	token, err := config.Exchange(ctx, authCode)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to exchange authorization code")
 	}
@@ -232,9 +403,7 @@ func (am *AuthManager) ExchangeAuthCode(ctx context.Context, authCode string) (*
 
 	// Update in-memory token and HTTP client
 	am.token = token
-	httpClient := am.config.Client(ctx, token)
-	httpClient.Timeout = httpTimeout
-	am.httpClient = httpClient
+	am.httpClient = am.newHTTPClient(ctx, token)
 
 	am.logger.Info("Authentication successful")
 	return token, nil
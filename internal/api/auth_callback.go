@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+/**
+ * Local OAuth2 Callback Server
+ *
+ * Features:
+ * - Loopback redirect on a random port, no code copy-paste
+ * - State parameter checked against the authorization request
+ * - Token exchanged and saved once the browser is redirected back
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// localAuthShutdownTimeout bounds how long closing the callback server
+// waits for the response to the browser.
+const localAuthShutdownTimeout = 5 * time.Second
+
+// LocalAuth is an OAuth2 authorization in progress whose redirect goes to a
+// temporary HTTP server on the loopback interface.
+type LocalAuth struct {
+	am       *AuthManager
+	config   *oauth2.Config
+	server   *http.Server
+	listener net.Listener
+	results  chan localAuthResult
+	state    string
+
+	// URL is the consent page to open in the browser
+	URL string
+}
+
+// localAuthResult is what the callback received.
+type localAuthResult struct {
+	err  error
+	code string
+}
+
+// StartLocalAuth starts a callback server on a random loopback port and
+// returns the authorization whose consent URL redirects to it.
+func (am *AuthManager) StartLocalAuth() (*LocalAuth, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start local callback server")
+	}
+
+	state, err := randomState()
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	config := *am.config
+	config.RedirectURL = fmt.Sprintf("http://%s/callback", listener.Addr())
+
+	la := &LocalAuth{
+		am:       am,
+		config:   &config,
+		listener: listener,
+		results:  make(chan localAuthResult, 1),
+		state:    state,
+		URL:      config.AuthCodeURL(state, oauth2.AccessTypeOffline),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", la.handleCallback)
+	la.server = &http.Server{Handler: mux, ReadHeaderTimeout: httpTimeout}
+
+	go func() {
+		if err := la.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			la.deliver(localAuthResult{err: errors.Wrap(err, "local callback server failed")})
+		}
+	}()
+
+	am.logger.Debug("Local callback server started", "redirect_url", config.RedirectURL)
+	return la, nil
+}
+
+// Wait blocks until the browser is redirected back, then exchanges the
+// code and saves the token.
+func (la *LocalAuth) Wait(ctx context.Context) (*oauth2.Token, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-la.results:
+		if result.err != nil {
+			return nil, result.err
+		}
+		return la.am.exchange(ctx, la.config, result.code)
+	}
+}
+
+// Close stops the callback server.
+func (la *LocalAuth) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), localAuthShutdownTimeout)
+	defer cancel()
+	return la.server.Shutdown(ctx)
+}
+
+// handleCallback receives the redirect from the consent page.
+func (la *LocalAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Requests not answering our authorization request are ignored
+	if query.Get("state") != la.state {
+		http.Error(w, "Unexpected authorization response.", http.StatusBadRequest)
+		return
+	}
+
+	if reason := query.Get("error"); reason != "" {
+		la.deliver(localAuthResult{err: errors.Errorf("authorization denied: %s", reason)})
+		fmt.Fprintln(w, "CloudPull was not authorized. You can close this window.")
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		http.Error(w, "Missing authorization code.", http.StatusBadRequest)
+		return
+	}
+
+	la.deliver(localAuthResult{code: code})
+	fmt.Fprintln(w, "CloudPull is authorized. You can close this window and return to the terminal.")
+}
+
+// deliver passes the first result to Wait and drops later ones.
+func (la *LocalAuth) deliver(result localAuthResult) {
+	select {
+	case la.results <- result:
+	default:
+	}
+}
+
+// randomState returns an unguessable OAuth2 state parameter.
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "failed to generate state")
+	}
+	return hex.EncodeToString(buf), nil
+}
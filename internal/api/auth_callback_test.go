@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// newTestAuthManager creates an auth manager whose token endpoint only
+// accepts code "good-code" redirected to a loopback callback.
+func newTestAuthManager(t *testing.T) *AuthManager {
+	t.Helper()
+
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.Form.Get("code") != "good-code" || !strings.HasPrefix(r.Form.Get("redirect_uri"), "http://127.0.0.1:") {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "access", "refresh_token": "refresh", "token_type": "Bearer", "expires_in": 3600}`))
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	return &AuthManager{
+		config: &oauth2.Config{
+			ClientID:    "client",
+			Endpoint:    oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth", TokenURL: tokenServer.URL},
+			RedirectURL: "http://localhost",
+		},
+		tokenPath: filepath.Join(t.TempDir(), "token.json"),
+		logger:    newMockLogger(),
+	}
+}
+
+// consentRedirect returns the callback URL of flow and its state.
+func consentRedirect(t *testing.T, flow *LocalAuth) (string, string) {
+	t.Helper()
+
+	consent, err := url.Parse(flow.URL)
+	require.NoError(t, err)
+	return consent.Query().Get("redirect_uri"), consent.Query().Get("state")
+}
+
+func TestLocalAuthCapturesCode(t *testing.T) {
+	am := newTestAuthManager(t)
+
+	flow, err := am.StartLocalAuth()
+	require.NoError(t, err)
+	defer flow.Close()
+
+	redirect, state := consentRedirect(t, flow)
+	require.True(t, strings.HasPrefix(redirect, "http://127.0.0.1:"))
+
+	// A response to another request is rejected and does not end the flow
+	resp, err := http.Get(redirect + "?code=bad-code&state=other")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, err = http.Get(redirect + "?code=good-code&state=" + state)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	token, err := flow.Wait(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+
+	saved, err := am.loadToken()
+	require.NoError(t, err)
+	assert.Equal(t, "refresh", saved.RefreshToken)
+}
+
+func TestLocalAuthReportsDeniedConsent(t *testing.T) {
+	am := newTestAuthManager(t)
+
+	flow, err := am.StartLocalAuth()
+	require.NoError(t, err)
+	defer flow.Close()
+
+	redirect, state := consentRedirect(t, flow)
+	resp, err := http.Get(redirect + "?error=access_denied&state=" + state)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = flow.Wait(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "access_denied")
+	assert.False(t, am.IsAuthenticated())
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// tokenSourceFunc adapts a function to oauth2.TokenSource.
+type tokenSourceFunc func() (*oauth2.Token, error)
+
+func (f tokenSourceFunc) Token() (*oauth2.Token, error) { return f() }
+
+// newTokenTestManager creates an auth manager with token saved and tokens
+// refreshed by refresh.
+func newTokenTestManager(t *testing.T, token *oauth2.Token, refresh func() (*oauth2.Token, error)) *AuthManager {
+	t.Helper()
+
+	am := &AuthManager{
+		config:    &oauth2.Config{ClientID: "client"},
+		tokenPath: filepath.Join(t.TempDir(), "token.json"),
+		logger:    newMockLogger(),
+		tokenSource: func(context.Context, *oauth2.Token) oauth2.TokenSource {
+			return tokenSourceFunc(refresh)
+		},
+	}
+	require.NoError(t, am.saveToken(token))
+	return am
+}
+
+func TestValidateTokenSavesRefreshedToken(t *testing.T) {
+	expiry := time.Now().Add(time.Hour).Round(time.Second)
+	am := newTokenTestManager(t,
+		&oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)},
+		func() (*oauth2.Token, error) {
+			return &oauth2.Token{AccessToken: "new", TokenType: "Bearer", Expiry: expiry}, nil
+		})
+
+	require.NoError(t, am.ValidateToken(context.Background()))
+
+	saved, err := am.loadToken()
+	require.NoError(t, err)
+	assert.Equal(t, "new", saved.AccessToken)
+	assert.Equal(t, "refresh", saved.RefreshToken)
+	assert.True(t, saved.Expiry.Equal(expiry))
+}
+
+func TestValidateTokenRequiresReauthOnInvalidGrant(t *testing.T) {
+	am := newTokenTestManager(t,
+		&oauth2.Token{AccessToken: "old", RefreshToken: "revoked", Expiry: time.Now().Add(time.Hour)},
+		func() (*oauth2.Token, error) {
+			return nil, &oauth2.RetrieveError{
+				Response:  &http.Response{StatusCode: http.StatusBadRequest},
+				ErrorCode: "invalid_grant",
+			}
+		})
+
+	err := am.ValidateToken(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrReauthRequired)
+	assert.Contains(t, err.Error(), "cloudpull auth --force")
+
+	// The saved token is left alone
+	saved, err := am.loadToken()
+	require.NoError(t, err)
+	assert.Equal(t, "revoked", saved.RefreshToken)
+
+	// Without a saved token there is nothing to refresh either
+	require.NoError(t, os.Remove(am.tokenPath))
+	assert.ErrorIs(t, am.ValidateToken(context.Background()), ErrReauthRequired)
+}
+
+func TestClientSavesTokensRefreshedDuringUse(t *testing.T) {
+	expired := &oauth2.Token{AccessToken: "old", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}
+	am := newTokenTestManager(t, expired, func() (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "refreshed", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+
+	var saved *oauth2.Token
+	client := am.newHTTPClient(context.Background(), expired)
+	source := client.Transport.(*oauth2.Transport).Source
+	_, err := source.Token()
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(am.tokenPath)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.Equal(t, "refreshed", saved.AccessToken)
+}
+
+func TestDriveClientUsesTunedTransport(t *testing.T) {
+	am := newTokenTestManager(t,
+		&oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)},
+		func() (*oauth2.Token, error) { return nil, assert.AnError })
+	am.SetTransportConfig(DefaultTransportConfig(16))
+
+	client, err := am.GetClient(context.Background())
+	require.NoError(t, err)
+
+	authTransport, ok := client.Transport.(*oauth2.Transport)
+	require.True(t, ok)
+	transport, ok := authTransport.Base.(*http.Transport)
+	require.True(t, ok)
+
+	assert.Equal(t, 16+idleConnHeadroom, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 2*(16+idleConnHeadroom), transport.MaxIdleConns)
+	assert.Equal(t, defaultIdleConnTimeout, transport.IdleConnTimeout)
+	assert.False(t, transport.DisableKeepAlives)
+	assert.Equal(t, httpTimeout, client.Timeout)
+}
+
+// newReauthTestClient creates a Drive client authorized by am against a
+// server that accepts only the access token "fresh".
+func newReauthTestClient(t *testing.T, am *AuthManager, requests *int) *DriveClient {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": {"code": 401, "message": "Invalid Credentials"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "file-1", "name": "report.pdf", "mimeType": "application/pdf"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	httpClient, err := am.GetClient(context.Background())
+	require.NoError(t, err)
+	service, err := drive.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(httpClient),
+	)
+	require.NoError(t, err)
+
+	client := NewDriveClient(service, NewRateLimiter(DefaultRateLimiterConfig()), newMockLogger())
+	client.SetReauthHandler(am.Reauthorize)
+	return client
+}
+
+func TestDriveClientReauthorizesRejectedToken(t *testing.T) {
+	// The saved token looks valid, but Drive has revoked it
+	am := newTokenTestManager(t,
+		&oauth2.Token{AccessToken: "revoked", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)},
+		func() (*oauth2.Token, error) {
+			return &oauth2.Token{AccessToken: "fresh", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)}, nil
+		})
+
+	requests := 0
+	client := newReauthTestClient(t, am, &requests)
+
+	info, err := client.GetFile(context.Background(), "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, "report.pdf", info.Name)
+	assert.Equal(t, 2, requests)
+
+	saved, err := am.loadToken()
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", saved.AccessToken)
+
+	// Later requests use the new token right away
+	_, err = client.GetFile(context.Background(), "file-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, requests)
+}
+
+func TestDriveClientRequiresReauthWhenRefreshFails(t *testing.T) {
+	am := newTokenTestManager(t,
+		&oauth2.Token{AccessToken: "revoked", RefreshToken: "revoked", Expiry: time.Now().Add(time.Hour)},
+		func() (*oauth2.Token, error) {
+			return nil, &oauth2.RetrieveError{
+				Response:  &http.Response{StatusCode: http.StatusBadRequest},
+				ErrorCode: "invalid_grant",
+			}
+		})
+
+	requests := 0
+	client := newReauthTestClient(t, am, &requests)
+
+	_, err := client.GetFile(context.Background(), "file-1")
+	require.Error(t, err)
+	assert.True(t, IsReauthRequired(err))
+	assert.Contains(t, err.Error(), "cloudpull auth --force")
+
+	// The rejected request is not retried like a server error
+	assert.Equal(t, 1, requests)
+}
@@ -254,6 +254,7 @@ func (bp *BatchProcessor) processBatch(ctx context.Context, batch []BatchRequest
 func (bp *BatchProcessor) executeMetadataRequest(ctx context.Context, req BatchRequest) {
 	resp, err := bp.service.Files.Get(req.FileID).
 		Fields("id, name, mimeType, size, md5Checksum, modifiedTime, parents").
+		SupportsAllDrives(true).
 		Context(ctx).
 		Do()
 
@@ -8,13 +8,17 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/oauth2"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/googleapi"
 
 	"github.com/VatsalSy/CloudPull/internal/errors"
 	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 /**
@@ -39,13 +43,29 @@ const (
 	// Maximum number of retries for API calls.
 	maxRetries = 3
 
+	// Maximum number of times an interrupted export stream is resumed.
+	maxExportResumes = 3
+
+	// Default attempts for folder listings and metadata lookups, which
+	// abort a whole subtree when they fail.
+	defaultListMaxRetries = 5
+
 	// Base delay for exponential backoff.
 	baseRetryDelay = time.Second
 
+	// Longest delay between two attempts.
+	maxRetryDelay = 30 * time.Second
+
 	// Default chunk size for downloads (10MB).
 	defaultChunkSize = 10 * 1024 * 1024
 )
 
+// MIME type of Drive folders.
+const folderMimeType = "application/vnd.google-apps.folder"
+
+// EmptyFileMD5 is the MD5 checksum Drive reports for empty files.
+const EmptyFileMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
 // Google Workspace MIME type mappings.
 var googleMimeTypes = map[string]string{
 	"application/vnd.google-apps.document":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
@@ -65,38 +85,156 @@ var exportExtensions = map[string]string{
 
 // DriveClient provides high-level operations for Google Drive API.
 type DriveClient struct {
-	service     *drive.Service
-	rateLimiter *RateLimiter
-	logger      *logger.Logger
-	chunkSize   int64
+	service        *drive.Service
+	rateLimiter    *RateLimiter
+	logger         *logger.Logger
+	chunkSize      int64
+	fileFields     string
+	listMaxRetries int
+	retryDelay     time.Duration
+	retryPolicy    *retryPolicy
+	fileMode       os.FileMode
+	dirMode        os.FileMode
+
+	// apiCalls counts the requests sent to Drive, retries included
+	apiCalls atomic.Int64
+
+	// reauth refreshes the authorization after Drive rejects it; nil
+	// fails such requests right away. New requests wait on authMu while
+	// it runs.
+	reauth func(ctx context.Context) error
+	authMu sync.RWMutex
 }
 
 // NewDriveClient creates a new Drive API client.
 func NewDriveClient(service *drive.Service, rateLimiter *RateLimiter, logger *logger.Logger) *DriveClient {
+	fileFields, _ := buildFileFields(nil)
 	return &DriveClient{
-		service:     service,
-		rateLimiter: rateLimiter,
-		logger:      logger,
-		chunkSize:   defaultChunkSize,
+		fileFields:     fileFields,
+		service:        service,
+		rateLimiter:    rateLimiter,
+		logger:         logger,
+		chunkSize:      defaultChunkSize,
+		listMaxRetries: defaultListMaxRetries,
+		retryDelay:     baseRetryDelay,
+		retryPolicy:    defaultRetryPolicy,
+		fileMode:       util.DefaultFileMode,
+		dirMode:        util.DefaultDirMode,
+	}
+}
+
+// SetFileModes sets the permissions of downloaded files and of the
+// directories created for them. A zero mode restores its default.
+func (dc *DriveClient) SetFileModes(fileMode, dirMode os.FileMode) {
+	if fileMode == 0 {
+		fileMode = util.DefaultFileMode
+	}
+	if dirMode == 0 {
+		dirMode = util.DefaultDirMode
+	}
+	dc.fileMode = fileMode
+	dc.dirMode = dirMode
+}
+
+// SetReauthHandler sets the function called when Drive rejects the
+// client's authorization, typically AuthManager.Reauthorize. Other
+// requests are held back while it runs, and the rejected request is retried
+// once after it succeeds.
+func (dc *DriveClient) SetReauthHandler(reauth func(ctx context.Context) error) {
+	dc.reauth = reauth
+}
+
+// HasReauthHandler reports whether a reauthorization handler is set.
+func (dc *DriveClient) HasReauthHandler() bool {
+	return dc.reauth != nil
+}
+
+// GetAPICallCount returns the number of requests this client has sent to
+// Drive, counting every retry attempt.
+func (dc *DriveClient) GetAPICallCount() int64 {
+	return dc.apiCalls.Load()
+}
+
+// SetListMaxRetries sets how many attempts folder listings and metadata
+// lookups get, independently of downloads. Values below 1 restore the
+// default.
+func (dc *DriveClient) SetListMaxRetries(attempts int) {
+	if attempts < 1 {
+		attempts = defaultListMaxRetries
+	}
+	dc.listMaxRetries = attempts
+}
+
+// SetRetryPolicy sets which failed requests are retried: the HTTP status
+// codes and error message fragments are merged with DefaultRetryableCodes
+// and DefaultRetryableReasons, and a negative code or a reason prefixed
+// with "-" removes one of them. Invalid entries are rejected.
+func (dc *DriveClient) SetRetryPolicy(codes []int, reasons []string) error {
+	policy, err := buildRetryPolicy(codes, reasons)
+	if err != nil {
+		return err
+	}
+	dc.retryPolicy = policy
+	return nil
+}
+
+// SetFileFields sets the Drive file fields requested by ListFiles and
+// GetFile. Fields the sync depends on are always added; an empty list
+// restores DefaultFileFields. Unknown field names are rejected.
+func (dc *DriveClient) SetFileFields(fields []string) error {
+	fileFields, err := buildFileFields(fields)
+	if err != nil {
+		return err
 	}
+	dc.fileFields = fileFields
+	return nil
 }
 
-// FileInfo contains essential file metadata.
+// FileInfo contains essential file metadata. CreatedTime, Owners, OwnedByMe,
+// Spaces and Trashed are only set when the matching fields are requested.
 type FileInfo struct {
 	ModifiedTime time.Time
+	CreatedTime  time.Time
 	ID           string
 	Name         string
 	MimeType     string
 	MD5Checksum  string
 	ExportFormat string
 	Parents      []string
+	Owners       []string
+	Spaces       []string
 	Size         int64
 	IsFolder     bool
 	CanExport    bool
+	OwnedByMe    bool
+	Trashed      bool
+	Starred      bool
+}
+
+// SizeUnknown reports whether Drive omitted the size of a file stored in
+// Drive. Such files report a size of 0, like empty files, but lack the
+// checksum of empty content. Google Workspace items never have a size.
+func (f *FileInfo) SizeUnknown() bool {
+	return f.Size == 0 && f.MD5Checksum != EmptyFileMD5 && !f.IsFolder && !f.CanExport &&
+		!strings.HasPrefix(f.MimeType, "application/vnd.google-apps.")
+}
+
+// ListFilter narrows the files returned by a folder listing. Folders are
+// always returned so the walk can reach matching files beneath them.
+type ListFilter struct {
+	StarredOnly bool
 }
 
 // ListFiles lists files in a folder with pagination.
 func (dc *DriveClient) ListFiles(ctx context.Context, folderID string, pageToken string) ([]*FileInfo, string, error) {
+	return dc.ListFilesMatching(ctx, folderID, pageToken, nil)
+}
+
+// ListFilesMatching lists the files in a folder that match filter, with
+// pagination. A nil filter lists every file.
+func (dc *DriveClient) ListFilesMatching(ctx context.Context, folderID, pageToken string,
+	filter *ListFilter) ([]*FileInfo, string, error) {
+
 	dc.logger.Debug("ListFiles called", "folderID", folderID, "pageToken", pageToken)
 
 	// Wait for rate limit
@@ -105,14 +243,19 @@ func (dc *DriveClient) ListFiles(ctx context.Context, folderID string, pageToken
 		return nil, "", err
 	}
 
-	query := fmt.Sprintf("'%s' in parents and trashed = false", folderID)
+	query := listQuery(folderID, filter)
 	dc.logger.Debug("Constructed query", "query", query)
 
+	// Folders shared with the user or on shared drives list nothing
+	// without the all drives flags
 	call := dc.service.Files.List().
 		Q(query).
 		PageSize(int64(defaultPageSize)).
-		Fields("nextPageToken, files(id, name, mimeType, size, md5Checksum, modifiedTime, parents)").
-		OrderBy("folder,name")
+		Fields(googleapi.Field("nextPageToken, files(" + dc.fileFields + ")")).
+		OrderBy("folder,name").
+		SupportsAllDrives(true).
+		IncludeItemsFromAllDrives(true).
+		Context(ctx)
 
 	if pageToken != "" {
 		call = call.PageToken(pageToken)
@@ -120,7 +263,7 @@ func (dc *DriveClient) ListFiles(ctx context.Context, folderID string, pageToken
 
 	dc.logger.Debug("Executing API call")
 	var fileList *drive.FileList
-	err := dc.retryWithBackoff(ctx, func() error {
+	err := dc.retryAttempts(ctx, dc.listMaxRetries, func() error {
 		var err error
 		fileList, err = call.Do()
 		if err != nil {
@@ -143,6 +286,19 @@ func (dc *DriveClient) ListFiles(ctx context.Context, folderID string, pageToken
 	return files, fileList.NextPageToken, nil
 }
 
+// listQuery builds the search query listing the children of a folder, or
+// the items shared with the user for SharedWithMeFolderID.
+func listQuery(folderID string, filter *ListFilter) string {
+	query := fmt.Sprintf("'%s' in parents and trashed = false", folderID)
+	if folderID == SharedWithMeFolderID {
+		query = "sharedWithMe = true and trashed = false"
+	}
+	if filter != nil && filter.StarredOnly {
+		query += fmt.Sprintf(" and (starred = true or mimeType = '%s')", folderMimeType)
+	}
+	return query
+}
+
 // GetFile retrieves file metadata.
 func (dc *DriveClient) GetFile(ctx context.Context, fileID string) (*FileInfo, error) {
 	// Wait for rate limit
@@ -151,10 +307,12 @@ func (dc *DriveClient) GetFile(ctx context.Context, fileID string) (*FileInfo, e
 	}
 
 	var file *drive.File
-	err := dc.retryWithBackoff(ctx, func() error {
+	err := dc.retryAttempts(ctx, dc.listMaxRetries, func() error {
 		var err error
 		file, err = dc.service.Files.Get(fileID).
-			Fields("id, name, mimeType, size, md5Checksum, modifiedTime, parents").
+			Fields(googleapi.Field(dc.fileFields)).
+			SupportsAllDrives(true).
+			Context(ctx).
 			Do()
 		return err
 	})
@@ -166,6 +324,56 @@ func (dc *DriveClient) GetFile(ctx context.Context, fileID string) (*FileInfo, e
 	return dc.convertFileInfo(file), nil
 }
 
+// ReadFile returns the content of a small file, failing if it is larger
+// than limit bytes.
+func (dc *DriveClient) ReadFile(ctx context.Context, fileID string, limit int64) ([]byte, error) {
+	if err := dc.rateLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	err := dc.retryWithBackoff(ctx, func() error {
+		resp, err := dc.service.Files.Get(fileID).AcknowledgeAbuse(true).SupportsAllDrives(true).Context(ctx).Download()
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		content, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file content")
+	}
+	if int64(len(content)) > limit {
+		return nil, errors.Errorf("file is larger than %d bytes", limit)
+	}
+
+	return content, nil
+}
+
+// GetAccountEmail returns the email address of the authenticated user.
+func (dc *DriveClient) GetAccountEmail(ctx context.Context) (string, error) {
+	if err := dc.rateLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	var about *drive.About
+	err := dc.retryWithBackoff(ctx, func() error {
+		var err error
+		about, err = dc.service.About.Get().Fields("user(emailAddress)").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get account information")
+	}
+	if about.User == nil || about.User.EmailAddress == "" {
+		return "", errors.Errorf("account has no email address")
+	}
+
+	return about.User.EmailAddress, nil
+}
+
 // DownloadFile downloads a file with resumable support.
 func (dc *DriveClient) DownloadFile(ctx context.Context, fileID string, destPath string, progressFn func(downloaded, total int64)) error {
 	// Get file metadata first
@@ -180,18 +388,82 @@ func (dc *DriveClient) DownloadFile(ctx context.Context, fileID string, destPath
 	}
 
 	// Regular file download
-	return dc.downloadRegularFile(ctx, fileID, destPath, fileInfo.Size, progressFn)
+	if fileInfo.SizeUnknown() {
+		return dc.streamToPath(ctx, fileID, destPath, progressFn)
+	}
+	return dc.downloadToPath(ctx, fileID, destPath, fileInfo.Size, progressFn)
+}
+
+// streamToPath downloads a file of unknown size to destPath from the start.
+func (dc *DriveClient) streamToPath(ctx context.Context, fileID, destPath string, progressFn func(downloaded, total int64)) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), dc.dirMode); err != nil {
+		return errors.Wrap(err, "failed to create destination directory")
+	}
+
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dc.fileMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to create destination file")
+	}
+	defer file.Close()
+
+	return dc.streamFile(ctx, fileID, file, progressFn)
 }
 
-// downloadRegularFile handles downloading of regular (non-Google Workspace) files.
-func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, destPath string, fileSize int64, progressFn func(downloaded, total int64)) error {
+// streamFile copies the whole content of a file to w in a single request,
+// for files whose size Drive did not report. The content ends at EOF, so
+// progressFn reports the bytes written as both values.
+func (dc *DriveClient) streamFile(ctx context.Context, fileID string, w io.Writer, progressFn func(downloaded, total int64)) error {
+	resp, err := dc.GetFileContent(ctx, fileID, 0, -1)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	written, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	if progressFn != nil {
+		progressFn(written, written)
+	}
+	return nil
+}
+
+// DownloadTo writes the content of file to w without touching the local
+// file system. Google Workspace files are exported as exportMimeType, or
+// their default format when it is empty.
+func (dc *DriveClient) DownloadTo(ctx context.Context, file *FileInfo, exportMimeType string, w io.Writer, progressFn func(downloaded, total int64)) error {
+	if file.SizeUnknown() {
+		return dc.streamFile(ctx, file.ID, w, progressFn)
+	}
+	if !file.CanExport {
+		return dc.downloadRegularFile(ctx, file.ID, w, 0, file.Size, progressFn)
+	}
+
+	if exportMimeType == "" {
+		exportMimeType = file.ExportFormat
+	}
+	if exportMimeType == "" {
+		return errors.Errorf("unsupported Google Workspace file type: %s", file.MimeType)
+	}
+
+	_, err := dc.exportTo(ctx, file.ID, exportMimeType, w, progressFn)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// downloadToPath downloads a regular file to destPath, resuming after the
+// bytes already on disk.
+func (dc *DriveClient) downloadToPath(ctx context.Context, fileID string, destPath string, fileSize int64, progressFn func(downloaded, total int64)) error {
 	// Create destination directory
-	if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
+	if err := os.MkdirAll(filepath.Dir(destPath), dc.dirMode); err != nil {
 		return errors.Wrap(err, "failed to create destination directory")
 	}
 
 	// Open/create destination file
-	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, dc.fileMode)
 	if err != nil {
 		return errors.Wrap(err, "failed to create destination file")
 	}
@@ -217,6 +489,17 @@ func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, d
 		return errors.Wrap(err, "failed to seek in file")
 	}
 
+	if err := dc.downloadRegularFile(ctx, fileID, file, startOffset, fileSize, progressFn); err != nil {
+		return err
+	}
+
+	dc.logger.Info("File downloaded successfully", "file", destPath)
+	return nil
+}
+
+// downloadRegularFile writes a regular (non-Google Workspace) file to w in
+// chunks, starting at startOffset.
+func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, w io.Writer, startOffset, fileSize int64, progressFn func(downloaded, total int64)) error {
 	// Download in chunks
 	for startOffset < fileSize {
 		endOffset := startOffset + dc.chunkSize - 1
@@ -237,7 +520,7 @@ func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, d
 		// Download chunk with retries
 		var resp *http.Response
 		err := dc.retryWithBackoff(ctx, func() error {
-			req := dc.service.Files.Get(fileID)
+			req := dc.service.Files.Get(fileID).SupportsAllDrives(true).Context(ctx)
 			req = req.AcknowledgeAbuse(true) // Handle potential abuse warnings
 			req.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", startOffset, endOffset))
 
@@ -250,8 +533,8 @@ func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, d
 			return errors.Wrap(err, "failed to download chunk")
 		}
 
-		// Write chunk to file
-		written, err := io.Copy(file, resp.Body)
+		// Write chunk
+		written, err := io.Copy(w, resp.Body)
 		resp.Body.Close()
 
 		if err != nil {
@@ -265,7 +548,6 @@ func (dc *DriveClient) downloadRegularFile(ctx context.Context, fileID string, d
 		}
 	}
 
-	dc.logger.Info("File downloaded successfully", "file", destPath)
 	return nil
 }
 
@@ -294,67 +576,205 @@ func (dc *DriveClient) ExportFile(ctx context.Context, fileID string, mimeType s
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
+	if err := os.MkdirAll(filepath.Dir(destPath), dc.dirMode); err != nil {
 		return errors.Wrap(err, "failed to create destination directory")
 	}
 
+	// Create destination file
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dc.fileMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to create destination file")
+	}
+
+	written, err := dc.exportTo(ctx, fileID, exportMimeType, file, progressFn)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "failed to close destination file")
+	}
+	if err != nil {
+		// A later attempt exports again from the start, so drop the partial file
+		if removeErr := os.Remove(destPath); removeErr != nil {
+			dc.logger.Warn("Failed to remove partial export", "file", destPath, "error", removeErr)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	dc.logger.Info("File exported successfully",
+		"file", destPath,
+		"format", exportMimeType,
+		"size", written)
+
+	return nil
+}
+
+// truncater is an export destination that can be started over.
+type truncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// exportTo writes the export of fileID into w and returns its size. An
+// export stream that breaks off is resumed up to maxExportResumes times
+// with a ranged request for the missing bytes. If the server ignores the
+// range, the export starts over from the beginning of w, which fails for
+// destinations such as pipes that cannot be truncated.
+func (dc *DriveClient) exportTo(ctx context.Context, fileID, mimeType string, w io.Writer, progressFn func(downloaded, total int64)) (int64, error) {
+	var written int64
+	for resumes := 0; ; resumes++ {
+		body, offset, err := dc.openExport(ctx, fileID, mimeType, written)
+		if err != nil {
+			return written, err
+		}
+
+		if offset != written && offset != 0 {
+			body.Close()
+			return written, errors.Errorf("export resumed at byte %d instead of %d", offset, written)
+		}
+		if offset != written {
+			dc.logger.Debug("Export range not honored, exporting again",
+				"fileID", fileID,
+				"offset", written)
+
+			file, ok := w.(truncater)
+			if !ok {
+				body.Close()
+				return written, errors.Errorf("export range not honored after %d bytes and the output cannot be restarted", written)
+			}
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				body.Close()
+				return written, errors.Wrap(err, "failed to seek in file")
+			}
+			if err := file.Truncate(0); err != nil {
+				body.Close()
+				return written, errors.Wrap(err, "failed to truncate file")
+			}
+			written = 0
+		}
+
+		n, err := dc.copyExport(ctx, w, body, written, progressFn)
+		body.Close()
+		written += n
+
+		var interrupted *exportInterruptedError
+		if err == nil || !errors.As(err, &interrupted) || resumes >= maxExportResumes {
+			return written, err
+		}
+
+		dc.logger.Warn("Export interrupted, resuming",
+			"fileID", fileID,
+			"offset", written,
+			"error", interrupted.err)
+	}
+}
+
+// openExport requests the export of fileID from offset on and returns the
+// response body with the offset it starts at, which is 0 when the server
+// sent the whole export instead of the requested range.
+func (dc *DriveClient) openExport(ctx context.Context, fileID, mimeType string, offset int64) (io.ReadCloser, int64, error) {
 	// Wait for rate limit
 	if err := dc.rateLimiter.Wait(ctx); err != nil {
-		return err
+		return nil, 0, err
 	}
 
 	// Export file with retries
 	var resp *http.Response
 	err := dc.retryWithBackoff(ctx, func() error {
+		call := dc.service.Files.Export(fileID, mimeType).Context(ctx)
+		if offset > 0 {
+			call.Header().Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
 		var err error
-		resp, err = dc.service.Files.Export(fileID, exportMimeType).Download()
+		resp, err = call.Download()
 		return err
 	})
 
 	if err != nil {
-		return errors.Wrap(err, "failed to export file")
+		if ctx.Err() != nil {
+			return nil, 0, ctx.Err()
+		}
+		return nil, 0, errors.Wrap(err, "failed to export file")
 	}
-	defer resp.Body.Close()
 
-	// Create destination file
-	file, err := os.Create(destPath)
-	if err != nil {
-		return errors.Wrap(err, "failed to create destination file")
+	if resp.StatusCode != http.StatusPartialContent {
+		return resp.Body, 0, nil
+	}
+
+	start, ok := contentRangeStart(resp.Header.Get("Content-Range"))
+	if !ok {
+		resp.Body.Close()
+		return nil, 0, errors.Errorf("invalid Content-Range in export response: %q", resp.Header.Get("Content-Range"))
+	}
+	return resp.Body, start, nil
+}
+
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-199/200".
+func contentRangeStart(header string) (int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, false
 	}
-	defer file.Close()
 
-	// Copy content with progress tracking
+	var start int64
+	if _, err := fmt.Sscan(first, &start); err != nil || start < 0 {
+		return 0, false
+	}
+	return start, true
+}
+
+// exportInterruptedError reports an export stream that broke off; the
+// bytes read before are valid, so the export can be resumed.
+type exportInterruptedError struct {
+	err error
+}
+
+func (e *exportInterruptedError) Error() string {
+	return "failed to read export data: " + e.err.Error()
+}
+
+func (e *exportInterruptedError) Unwrap() error {
+	return e.err
+}
+
+// copyExport copies an export response into w with progress tracking,
+// reporting progress from offset on. It stops with ctx.Err() as soon as ctx
+// is canceled.
+func (dc *DriveClient) copyExport(ctx context.Context, w io.Writer, body io.Reader, offset int64, progressFn func(downloaded, total int64)) (int64, error) {
 	var written int64
 	buf := make([]byte, 32*1024) // 32KB buffer
+	reader := util.ContextReader(ctx, body)
 
 	for {
-		n, err := resp.Body.Read(buf)
+		n, err := reader.Read(buf)
 		if n > 0 {
-			if _, writeErr := file.Write(buf[:n]); writeErr != nil {
-				return errors.Wrap(writeErr, "failed to write to file")
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return written, errors.Wrap(writeErr, "failed to write export data")
 			}
 			written += int64(n)
 
 			if progressFn != nil {
 				// For exports, we don't know total size in advance
-				progressFn(written, -1)
+				progressFn(offset+written, -1)
 			}
 		}
 
 		if err == io.EOF {
-			break
+			return written, nil
 		}
 		if err != nil {
-			return errors.Wrap(err, "failed to read export data")
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return written, ctxErr
+			}
+			return written, &exportInterruptedError{err: err}
 		}
 	}
-
-	dc.logger.Info("File exported successfully",
-		"file", destPath,
-		"format", exportMimeType,
-		"size", written)
-
-	return nil
 }
 
 // GetRootFolderID returns the ID of the root folder.
@@ -371,7 +791,25 @@ func (dc *DriveClient) convertFileInfo(f *drive.File) *FileInfo {
 		Size:        f.Size,
 		MD5Checksum: f.Md5Checksum,
 		Parents:     f.Parents,
-		IsFolder:    f.MimeType == "application/vnd.google-apps.folder",
+		Spaces:      f.Spaces,
+		IsFolder:    f.MimeType == folderMimeType,
+		Trashed:     f.Trashed,
+		Starred:     f.Starred,
+	}
+
+	if f.CreatedTime != "" {
+		if t, err := time.Parse(time.RFC3339, f.CreatedTime); err == nil {
+			info.CreatedTime = t
+		}
+	}
+
+	for _, owner := range f.Owners {
+		if owner.EmailAddress != "" {
+			info.Owners = append(info.Owners, owner.EmailAddress)
+		}
+		if owner.Me {
+			info.OwnedByMe = true
+		}
 	}
 
 	// Parse modified time
@@ -390,25 +828,62 @@ func (dc *DriveClient) convertFileInfo(f *drive.File) *FileInfo {
 	return info
 }
 
-// retryWithBackoff implements exponential backoff retry logic.
+// retryWithBackoff implements exponential backoff retry logic. Outcomes are
+// reported to the rate limiter so it adapts to Drive's throttling.
 func (dc *DriveClient) retryWithBackoff(ctx context.Context, operation func() error) error {
+	return dc.retryAttempts(ctx, maxRetries, operation)
+}
+
+// retryAttempts runs operation up to attempts times with exponential backoff.
+func (dc *DriveClient) retryAttempts(ctx context.Context, attempts int, operation func() error) error {
 	var lastErr error
+	reauthorized := false
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
+	for attempt := 0; attempt < attempts; attempt++ {
+		// Wait for a refresh of the authorization to finish
+		dc.authMu.RLock()
+		dc.authMu.RUnlock()
+
+		dc.apiCalls.Add(1)
 		err := operation()
 		if err == nil {
+			dc.rateLimiter.RecordSuccess()
 			return nil
 		}
 
+		// A rejected authorization is refreshed once rather than retried
+		if isAuthError(err) {
+			if dc.reauth == nil || reauthorized {
+				return reauthError(err)
+			}
+			reauthorized = true
+			if err := dc.reauthorize(ctx); err != nil {
+				return err
+			}
+			attempt--
+			continue
+		}
+
 		lastErr = err
+		if isRateLimitError(err) {
+			dc.rateLimiter.RecordThrottle()
+		}
 
 		// Check if error is retryable
 		if !dc.isRetryableError(err) {
 			return err
 		}
 
+		// No point waiting after the last attempt
+		if attempt == attempts-1 {
+			break
+		}
+
 		// Calculate backoff delay
-		delay := baseRetryDelay * time.Duration(1<<uint(attempt))
+		delay := dc.retryDelay * time.Duration(1<<uint(attempt))
+		if delay > maxRetryDelay || delay <= 0 {
+			delay = maxRetryDelay
+		}
 
 		// Add jitter (±25%)
 		jitter := time.Duration(float64(delay) * 0.25 * (2*generateRandom() - 1))
@@ -431,39 +906,110 @@ func (dc *DriveClient) retryWithBackoff(ctx context.Context, operation func() er
 	return errors.Wrap(lastErr, "max retries exceeded")
 }
 
-// isRetryableError checks if an error is retryable.
-func (dc *DriveClient) isRetryableError(err error) bool {
-	if err == nil {
+// isRateLimitError reports whether err is Drive telling the client to slow
+// down: HTTP 429, or 403 with a rate limit reason.
+func isRateLimitError(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
 		return false
 	}
 
-	// Check for Google API errors
-	if apiErr, ok := err.(*googleapi.Error); ok {
-		switch apiErr.Code {
-		case 429, 500, 502, 503, 504: // Rate limit and server errors
-			return true
-		case 403: // Check for rate limit in 403 errors
-			for _, e := range apiErr.Errors {
-				if e.Reason == "userRateLimitExceeded" || e.Reason == "rateLimitExceeded" {
-					return true
-				}
+	switch apiErr.Code {
+	case 429:
+		return true
+	case 403:
+		for _, e := range apiErr.Errors {
+			if e.Reason == "userRateLimitExceeded" || e.Reason == "rateLimitExceeded" {
+				return true
 			}
 		}
 	}
+	return false
+}
 
-	// Check for network errors
-	errStr := err.Error()
-	if strings.Contains(errStr, "connection refused") ||
-		strings.Contains(errStr, "connection reset") ||
-		strings.Contains(errStr, "timeout") {
+// reauthorize runs the reauth handler while holding back other requests.
+func (dc *DriveClient) reauthorize(ctx context.Context) error {
+	dc.authMu.Lock()
+	defer dc.authMu.Unlock()
 
-		return true
+	if err := dc.reauth(ctx); err != nil {
+		if IsReauthRequired(err) {
+			return err
+		}
+		return errors.Wrap(err, "failed to refresh authorization")
+	}
+	return nil
+}
+
+// isAuthError reports whether Drive rejected the request's authorization:
+// HTTP 401, or the token refresh failing with invalid_grant.
+func isAuthError(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == 401
 	}
 
-	return false
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	return retrieveErr.ErrorCode == "invalid_grant" ||
+		(retrieveErr.Response != nil && retrieveErr.Response.StatusCode == 401)
+}
+
+// IsReauthRequired reports whether err, or an error it wraps, means the
+// user has to authenticate again before syncing can continue.
+func IsReauthRequired(err error) bool {
+	return errors.Is(err, ErrReauthRequired)
+}
+
+// IsPermissionDenied reports whether err, or an error it wraps, is Drive
+// refusing access to an item: HTTP 403 for a reason other than rate limiting.
+func IsPermissionDenied(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 403 && !isRateLimitError(apiErr)
+}
+
+// IsNotFound reports whether err, or an error it wraps, is Drive reporting
+// that an item does not exist (HTTP 404), such as a file trashed or deleted
+// after it was listed.
+func IsNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == 404
 }
 
-// GetFileContent downloads a file chunk with byte range support.
+// isRetryableError checks if an error is retryable under the client's
+// retry policy. A 403 is retried when Drive reports rate limiting.
+func (dc *DriveClient) isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	policy := dc.retryPolicy
+	if policy == nil {
+		policy = defaultRetryPolicy
+	}
+
+	code := 0
+	if apiErr, ok := err.(*googleapi.Error); ok {
+		code = apiErr.Code
+		if code == 403 && isRateLimitError(err) {
+			return true
+		}
+	}
+
+	return policy.retryable(code, err.Error())
+}
+
+// GetFileContent downloads a file chunk with byte range support. A
+// negative endOffset requests everything from startOffset on, which also
+// works for files of unknown size.
 func (dc *DriveClient) GetFileContent(ctx context.Context, fileID string, startOffset, endOffset int64) (*http.Response, error) {
 	// Wait for rate limit
 	if err := dc.rateLimiter.Wait(ctx); err != nil {
@@ -471,9 +1017,14 @@ func (dc *DriveClient) GetFileContent(ctx context.Context, fileID string, startO
 	}
 
 	// Create request with byte range
-	req := dc.service.Files.Get(fileID)
+	req := dc.service.Files.Get(fileID).SupportsAllDrives(true).Context(ctx)
 	req = req.AcknowledgeAbuse(true)
-	req.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", startOffset, endOffset))
+	switch {
+	case endOffset >= 0:
+		req.Header().Set("Range", fmt.Sprintf("bytes=%d-%d", startOffset, endOffset))
+	case startOffset > 0:
+		req.Header().Set("Range", fmt.Sprintf("bytes=%d-", startOffset))
+	}
 
 	var resp *http.Response
 	err := dc.retryWithBackoff(ctx, func() error {
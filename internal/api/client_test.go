@@ -0,0 +1,414 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// newTestDriveClient creates a client whose requests are served by handler.
+func newTestDriveClient(t *testing.T, handler http.HandlerFunc) *DriveClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service, err := drive.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	require.NoError(t, err)
+
+	return NewDriveClient(service, NewRateLimiter(DefaultRateLimiterConfig()), newMockLogger())
+}
+
+func TestExportFileStopsOnCancel(t *testing.T) {
+	// The export streams until the client goes away
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		chunk := []byte(strings.Repeat("x", 32*1024))
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	destPath := filepath.Join(t.TempDir(), "export.pdf")
+	progressFn := func(downloaded, total int64) {
+		if downloaded >= 256*1024 {
+			cancel()
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.ExportFile(ctx, "doc-1", "application/pdf", destPath, progressFn)
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("export did not stop after cancel")
+	}
+
+	assert.NoFileExists(t, destPath)
+}
+
+// exportContent is the export served by the interrupting export servers.
+var exportContent = bytes.Repeat([]byte("0123456789abcdef"), 20*1024)
+
+// serveInterruptedExport declares the whole export but sends only its first
+// cut bytes, so the client sees the connection drop midway.
+func serveInterruptedExport(w http.ResponseWriter, cut int) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(exportContent)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(exportContent[:cut])
+}
+
+func TestExportFileResumesInterruptedExport(t *testing.T) {
+	const cut = 100 * 1024
+
+	// A server ignoring the range sends the whole export again
+	tests := []struct {
+		name       string
+		honorRange bool
+	}{
+		{name: "ranged", honorRange: true},
+		{name: "range ignored", honorRange: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			var retryRange atomic.Value
+			client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					serveInterruptedExport(w, cut)
+					return
+				}
+				retryRange.Store(r.Header.Get("Range"))
+				if !tt.honorRange {
+					r.Header.Del("Range")
+				}
+				http.ServeContent(w, r, "export.pdf", time.Time{}, bytes.NewReader(exportContent))
+			})
+
+			var lastProgress int64
+			destPath := filepath.Join(t.TempDir(), "export.pdf")
+			err := client.ExportFile(context.Background(), "doc-1", "application/pdf", destPath, func(downloaded, total int64) {
+				lastProgress = downloaded
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, int32(2), requests.Load())
+			assert.Equal(t, fmt.Sprintf("bytes=%d-", cut), retryRange.Load())
+			assert.Equal(t, int64(len(exportContent)), lastProgress)
+
+			exported, err := os.ReadFile(destPath)
+			require.NoError(t, err)
+			assert.Equal(t, exportContent, exported)
+		})
+	}
+}
+
+func TestExportFileRemovesPartialExport(t *testing.T) {
+	var requests atomic.Int32
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveInterruptedExport(w, 1024)
+	})
+
+	destPath := filepath.Join(t.TempDir(), "export.pdf")
+	err := client.ExportFile(context.Background(), "doc-1", "application/pdf", destPath, nil)
+	assert.ErrorContains(t, err, "failed to read export data")
+
+	// The first request and every resume fail
+	assert.Equal(t, int32(1+maxExportResumes), requests.Load())
+	assert.NoFileExists(t, destPath)
+}
+
+func TestDownloadToWritesContentToWriter(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "media", r.URL.Query().Get("alt"))
+		http.ServeContent(w, r, "data.bin", time.Time{}, bytes.NewReader(content))
+	})
+	client.chunkSize = 4096
+
+	var out bytes.Buffer
+	file := &FileInfo{ID: "file-1", Size: int64(len(content))}
+	require.NoError(t, client.DownloadTo(context.Background(), file, "", &out, nil))
+	assert.Equal(t, content, out.Bytes())
+}
+
+func TestDownloadToStreamsFilesOfUnknownSize(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	var ranges []string
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		w.Write(content)
+	})
+	client.chunkSize = 4096
+
+	// Listed without a size, so it is read to EOF in one request
+	file := &FileInfo{ID: "file-1", MimeType: "application/octet-stream"}
+	require.True(t, file.SizeUnknown())
+
+	var out bytes.Buffer
+	var total int64
+	require.NoError(t, client.DownloadTo(context.Background(), file, "", &out,
+		func(downloaded, size int64) { total = size }))
+	assert.Equal(t, content, out.Bytes())
+	assert.Equal(t, int64(len(content)), total)
+	assert.Equal(t, []string{""}, ranges)
+
+	// Empty files and Workspace files have a known size of 0
+	assert.False(t, (&FileInfo{MimeType: "text/plain", MD5Checksum: EmptyFileMD5}).SizeUnknown())
+	assert.False(t, (&FileInfo{MimeType: "application/vnd.google-apps.document"}).SizeUnknown())
+	assert.False(t, (&FileInfo{MimeType: "text/plain", Size: 10}).SizeUnknown())
+}
+
+func TestDownloadToExportsWorkspaceFiles(t *testing.T) {
+	var exportedAs string
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		exportedAs = r.URL.Query().Get("mimeType")
+		w.Write(exportContent)
+	})
+
+	var out bytes.Buffer
+	file := &FileInfo{ID: "doc-1", CanExport: true, ExportFormat: "application/pdf"}
+	require.NoError(t, client.DownloadTo(context.Background(), file, "text/plain", &out, nil))
+	assert.Equal(t, "text/plain", exportedAs)
+	assert.Equal(t, exportContent, out.Bytes())
+}
+
+func TestRetryWithBackoffReportsThrottling(t *testing.T) {
+	var requests int
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"code": 429, "message": "Rate Limit Exceeded", "errors": [{"reason": "rateLimitExceeded"}]}}`))
+			return
+		}
+		w.Write([]byte(`{"files": []}`))
+	})
+
+	_, _, err := client.ListFiles(context.Background(), "root", "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, requests)
+
+	// The default rate of 10 requests per second was halved
+	assert.Equal(t, 5, client.rateLimiter.CurrentRateLimit())
+	assert.Equal(t, int64(1), client.rateLimiter.GetMetrics().Throttles)
+}
+
+func TestListFilesUsesListRetryLimit(t *testing.T) {
+	var requests int
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error": {"code": 503, "message": "Backend Error"}}`))
+	})
+	client.retryDelay = time.Millisecond
+
+	client.SetListMaxRetries(7)
+	_, _, err := client.ListFiles(context.Background(), "root", "")
+	require.Error(t, err)
+	assert.Equal(t, 7, requests)
+
+	// Downloads keep their own limit
+	requests = 0
+	_, err = client.GetFileContent(context.Background(), "file-1", 0, 10)
+	require.Error(t, err)
+	assert.Equal(t, maxRetries, requests)
+}
+
+func TestAPICallCountIncludesRetries(t *testing.T) {
+	var requests atomic.Int64
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"code": 503, "message": "Backend Error"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "f1", "name": "a.txt", "files": []}`))
+	})
+	client.retryDelay = time.Millisecond
+	assert.Equal(t, int64(0), client.GetAPICallCount())
+
+	_, _, err := client.ListFiles(context.Background(), "root", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), client.GetAPICallCount())
+
+	_, err = client.GetFile(context.Background(), "f1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), client.GetAPICallCount())
+	assert.Equal(t, requests.Load(), client.GetAPICallCount())
+}
+
+func TestListFilesRequestsConfiguredFields(t *testing.T) {
+	var fields string
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fields = r.URL.Query().Get("fields")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"files": [{"id": "f1", "name": "a.txt", "createdTime": "2024-03-01T10:00:00Z",
+			"owners": [{"emailAddress": "owner@example.com", "me": true}]}]}`))
+	})
+
+	files, _, err := client.ListFiles(context.Background(), "root", "")
+	require.NoError(t, err)
+	assert.Contains(t, fields, "owners(emailAddress,me)")
+	require.Len(t, files, 1)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), files[0].CreatedTime)
+	assert.Equal(t, []string{"owner@example.com"}, files[0].Owners)
+	assert.True(t, files[0].OwnedByMe)
+
+	// Required fields are kept when a smaller set is configured
+	require.NoError(t, client.SetFileFields([]string{"id", "description"}))
+	_, _, err = client.ListFiles(context.Background(), "root", "")
+	require.NoError(t, err)
+	assert.Equal(t, "nextPageToken, files(id,description,name,mimeType,size,md5Checksum,modifiedTime,parents,spaces)", fields)
+}
+
+func TestListFilesMatchingStarredOnly(t *testing.T) {
+	var query string
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"files": [{"id": "f1", "name": "a.txt", "starred": true}]}`))
+	})
+
+	_, _, err := client.ListFiles(context.Background(), "folder-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "'folder-1' in parents and trashed = false", query)
+
+	// Folders stay listed so starred files below them are found
+	files, _, err := client.ListFilesMatching(context.Background(), "folder-1", "", &ListFilter{StarredOnly: true})
+	require.NoError(t, err)
+	assert.Equal(t, "'folder-1' in parents and trashed = false and "+
+		"(starred = true or mimeType = 'application/vnd.google-apps.folder')", query)
+	require.Len(t, files, 1)
+	assert.True(t, files[0].Starred)
+}
+
+func TestListFilesSharedWithMe(t *testing.T) {
+	var query, allDrives, itemsFromAllDrives string
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query().Get("q")
+		allDrives = r.URL.Query().Get("supportsAllDrives")
+		itemsFromAllDrives = r.URL.Query().Get("includeItemsFromAllDrives")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"files": [{"id": "shared-1", "name": "Team", "mimeType": "application/vnd.google-apps.folder"}]}`))
+	})
+
+	files, _, err := client.ListFiles(context.Background(), SharedWithMeFolderID, "")
+	require.NoError(t, err)
+	assert.Equal(t, "sharedWithMe = true and trashed = false", query)
+	assert.Equal(t, "true", allDrives)
+	assert.Equal(t, "true", itemsFromAllDrives)
+	require.Len(t, files, 1)
+	assert.True(t, files[0].IsFolder)
+
+	// Folders outside My Drive are listed by ID with the same flags
+	_, _, err = client.ListFiles(context.Background(), "shared-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "'shared-1' in parents and trashed = false", query)
+	assert.Equal(t, "true", itemsFromAllDrives)
+}
+
+func TestSetFileFieldsRejectsUnknownFields(t *testing.T) {
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	assert.NoError(t, client.SetFileFields([]string{"appProperties", "capabilities/canDownload"}))
+	assert.Error(t, client.SetFileFields([]string{"ownerz"}))
+	assert.Error(t, client.SetFileFields([]string{"owners(emailAddress"}))
+}
+
+func TestConfiguredRetryableCodeIsRetried(t *testing.T) {
+	var requests atomic.Int64
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusRequestTimeout)
+			w.Write([]byte(`{"error": {"code": 408, "message": "Request Timeout"}}`))
+			return
+		}
+		w.Write([]byte(`{"id": "f1", "name": "a.txt"}`))
+	})
+	client.retryDelay = time.Millisecond
+
+	// 408 is not retried by default
+	_, err := client.GetFile(context.Background(), "f1")
+	require.Error(t, err)
+	assert.Equal(t, int64(1), requests.Load())
+
+	requests.Store(0)
+	require.NoError(t, client.SetRetryPolicy([]int{408, -500}, []string{"-timeout", "unexpected EOF"}))
+	_, err = client.GetFile(context.Background(), "f1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), requests.Load())
+
+	assert.False(t, client.isRetryableError(&googleapi.Error{Code: 500}))
+	assert.True(t, client.isRetryableError(&googleapi.Error{Code: 503}))
+	assert.False(t, client.isRetryableError(fmt.Errorf("i/o timeout")))
+	assert.True(t, client.isRetryableError(fmt.Errorf("read: unexpected EOF")))
+	assert.True(t, client.isRetryableError(fmt.Errorf("connection reset by peer")))
+}
+
+func TestSetRetryPolicyRejectsInvalidEntries(t *testing.T) {
+	client := newTestDriveClient(t, func(w http.ResponseWriter, r *http.Request) {})
+
+	assert.Error(t, client.SetRetryPolicy([]int{42}, nil))
+	assert.Error(t, client.SetRetryPolicy([]int{-700}, nil))
+	assert.Error(t, client.SetRetryPolicy(nil, []string{" - "}))
+}
+
+func TestIsNotFound(t *testing.T) {
+	gone := &googleapi.Error{Code: 404, Errors: []googleapi.ErrorItem{{Reason: "notFound"}}}
+
+	assert.True(t, IsNotFound(gone))
+	assert.True(t, IsNotFound(fmt.Errorf("download failed: %w", gone)))
+	assert.False(t, IsNotFound(&googleapi.Error{Code: 403}))
+	assert.False(t, IsNotFound(fmt.Errorf("connection reset")))
+}
+
+func TestIsPermissionDenied(t *testing.T) {
+	denied := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientFilePermissions"}}}
+	throttled := &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}
+
+	assert.True(t, IsPermissionDenied(denied))
+	assert.True(t, IsPermissionDenied(fmt.Errorf("download failed: %w", denied)))
+	assert.False(t, IsPermissionDenied(throttled))
+	assert.False(t, IsPermissionDenied(&googleapi.Error{Code: 404}))
+	assert.False(t, IsPermissionDenied(fmt.Errorf("connection reset")))
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+)
+
+/**
+ * Drive API Interface
+ *
+ * Features:
+ * - The Drive operations the sync engine depends on
+ * - Implemented by DriveClient and by the in-memory fake in apitest
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// DriveAPI is the subset of Drive operations used to walk folders and
+// download files. DriveClient implements it against the Drive v3 API.
+type DriveAPI interface {
+	// ListFiles lists one page of the items in a folder. An empty next
+	// page token means the listing is complete.
+	ListFiles(ctx context.Context, folderID, pageToken string) ([]*FileInfo, string, error)
+
+	// ListFilesMatching is ListFiles narrowed by filter; folders are always
+	// returned. A nil filter lists every item.
+	ListFilesMatching(ctx context.Context, folderID, pageToken string, filter *ListFilter) ([]*FileInfo, string, error)
+
+	// GetFile returns the metadata of a file or folder.
+	GetFile(ctx context.Context, fileID string) (*FileInfo, error)
+
+	// ReadFile returns the content of a file no larger than limit bytes.
+	ReadFile(ctx context.Context, fileID string, limit int64) ([]byte, error)
+
+	// GetFileContent returns the bytes startOffset to endOffset, inclusive,
+	// of a file; a negative endOffset reads to the end of the file. The
+	// caller closes the response body.
+	GetFileContent(ctx context.Context, fileID string, startOffset, endOffset int64) (*http.Response, error)
+
+	// DownloadFile writes a file to destPath, exporting Google Workspace
+	// files in their default format.
+	DownloadFile(ctx context.Context, fileID, destPath string, progressFn func(downloaded, total int64)) error
+
+	// ExportFile writes a Google Workspace file exported as mimeType to
+	// destPath.
+	ExportFile(ctx context.Context, fileID, mimeType, destPath string, progressFn func(downloaded, total int64)) error
+
+	// GetAccountEmail returns the email address of the signed-in account.
+	GetAccountEmail(ctx context.Context) (string, error)
+
+	// GetRootFolderID returns the ID of the account's My Drive folder.
+	GetRootFolderID() string
+
+	// GetAPICallCount returns the number of Drive requests made so far.
+	GetAPICallCount() int64
+}
+
+var _ DriveAPI = (*DriveClient)(nil)
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+/**
+ * Drive URL Parsing
+ *
+ * Features:
+ * - Accepts a bare Drive ID or a pasted drive.google.com link
+ * - Understands folder, file, open?id=, My Drive and Shared with me links
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// RootFolderID is the alias Drive accepts for the user's My Drive.
+const RootFolderID = "root"
+
+// SharedWithMeFolderID names the "Shared with me" view as if it were a
+// folder. It is not a Drive ID: listing it lists the items shared directly
+// with the user, which are not part of My Drive.
+const SharedWithMeFolderID = "sharedWithMe"
+
+var driveIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{10,}$`)
+
+// IsValidDriveID reports whether id looks like a Drive file or folder ID.
+func IsValidDriveID(id string) bool {
+	return driveIDPattern.MatchString(id)
+}
+
+// ParseDriveID returns the Drive ID named by input, which is either an ID,
+// "root", "sharedWithMe", or a drive.google.com URL such as
+// https://drive.google.com/drive/folders/<id>?usp=sharing. A URL of a file
+// yields the file's ID; callers that need a folder check its type.
+func ParseDriveID(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == RootFolderID || input == SharedWithMeFolderID || IsValidDriveID(input) {
+		return input, nil
+	}
+	if !strings.Contains(input, "drive.google.com") {
+		return "", errors.Errorf("%q is neither a Drive ID nor a drive.google.com URL", input)
+	}
+
+	if !strings.Contains(input, "://") {
+		input = "https://" + input
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid Drive URL %q", input)
+	}
+
+	id := u.Query().Get("id")
+	if id == "" {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		for i, part := range parts {
+			switch {
+			case (part == "folders" || part == "d") && i+1 < len(parts):
+				id = parts[i+1]
+			case part == "my-drive":
+				return RootFolderID, nil
+			case part == "shared-with-me":
+				return SharedWithMeFolderID, nil
+			}
+			if id != "" {
+				break
+			}
+		}
+	}
+	if !IsValidDriveID(id) {
+		return "", errors.Errorf("no Drive ID found in URL %q", input)
+	}
+	return id, nil
+}
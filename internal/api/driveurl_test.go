@@ -0,0 +1,40 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDriveID(t *testing.T) {
+	tests := map[string]string{
+		"1ABC123DEF456GHI":   "1ABC123DEF456GHI",
+		" 1ABC123DEF456GHI ": "1ABC123DEF456GHI",
+		"root":               "root",
+		"https://drive.google.com/drive/folders/1ABC123DEF456GHI":                 "1ABC123DEF456GHI",
+		"https://drive.google.com/drive/u/0/folders/1ABC123DEF456GHI?usp=sharing": "1ABC123DEF456GHI",
+		"drive.google.com/drive/folders/1ABC123DEF456GHI":                         "1ABC123DEF456GHI",
+		"https://drive.google.com/open?id=1ABC123DEF456GHI":                       "1ABC123DEF456GHI",
+		"https://drive.google.com/file/d/1ABC123DEF456GHI/view?usp=sharing":       "1ABC123DEF456GHI",
+		"https://drive.google.com/drive/my-drive":                                 "root",
+		"sharedWithMe": "sharedWithMe",
+		"https://drive.google.com/drive/u/1/shared-with-me": "sharedWithMe",
+	}
+	for input, want := range tests {
+		id, err := ParseDriveID(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, id, input)
+	}
+
+	for _, input := range []string{
+		"",
+		"short",
+		"../etc/passwd",
+		"https://example.com/drive/folders/1ABC123DEF456GHI",
+		"https://drive.google.com/drive/folders/",
+	} {
+		_, err := ParseDriveID(input)
+		assert.Error(t, err, input)
+	}
+}
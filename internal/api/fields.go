@@ -0,0 +1,98 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+/**
+ * Drive File Field Selection
+ *
+ * Features:
+ * - Configurable partial response fields for file listings and lookups
+ * - Fields the sync depends on are always requested
+ * - Validation of field names against the Drive file resource
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// requiredFileFields are requested whatever the configuration says, as
+// walking and downloading depend on them.
+var requiredFileFields = []string{"id", "name", "mimeType", "size", "md5Checksum", "modifiedTime", "parents", "spaces"}
+
+// DefaultFileFields is the field set requested for files when none is
+// configured.
+var DefaultFileFields = []string{
+	"id", "name", "mimeType", "size", "md5Checksum", "modifiedTime", "parents",
+	"createdTime", "owners(emailAddress,me)", "trashed", "starred", "spaces",
+}
+
+// driveFileFields holds the top-level field names of a Drive file resource.
+var driveFileFields = func() map[string]bool {
+	names := make(map[string]bool)
+	t := reflect.TypeOf(drive.File{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}()
+
+// buildFileFields validates the requested file fields and returns them
+// joined for a partial response, with the required fields added. An empty
+// list selects DefaultFileFields.
+func buildFileFields(fields []string) (string, error) {
+	if len(fields) == 0 {
+		fields = DefaultFileFields
+	}
+
+	selected := make([]string, 0, len(fields)+len(requiredFileFields))
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, err := fileFieldName(field)
+		if err != nil {
+			return "", err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		selected = append(selected, field)
+	}
+	for _, name := range requiredFileFields {
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, name)
+		}
+	}
+
+	return strings.Join(selected, ","), nil
+}
+
+// fileFieldName returns the top-level name of a field selector such as
+// "owners(emailAddress)" or "capabilities/canDownload", checking that the
+// Drive file resource has it.
+func fileFieldName(field string) (string, error) {
+	name := field
+	if i := strings.IndexAny(field, "(/"); i >= 0 {
+		name = field[:i]
+	}
+	if strings.Count(field, "(") != strings.Count(field, ")") {
+		return "", errors.Errorf("invalid Drive file field %q: unbalanced parentheses", field)
+	}
+	if !driveFileFields[name] {
+		return "", errors.Errorf("unknown Drive file field %q", name)
+	}
+	return name, nil
+}
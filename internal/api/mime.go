@@ -0,0 +1,92 @@
+package api
+
+import (
+	"strings"
+)
+
+/**
+ * MIME Type Categories
+ *
+ * Features:
+ * - Groups Drive and Google Workspace MIME types into broad categories
+ * - Shared by path templates, category folders and filters
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// MIME categories returned by MimeCategory.
+const (
+	MimeCategoryDocuments = "documents"
+	MimeCategoryImages    = "images"
+	MimeCategoryVideos    = "videos"
+	MimeCategoryAudio     = "audio"
+	MimeCategoryArchives  = "archives"
+	MimeCategoryOther     = "other"
+)
+
+// mimeCategories maps MIME types that cannot be told apart by prefix.
+var mimeCategories = map[string]string{
+	"application/vnd.google-apps.document":     MimeCategoryDocuments,
+	"application/vnd.google-apps.spreadsheet":  MimeCategoryDocuments,
+	"application/vnd.google-apps.presentation": MimeCategoryDocuments,
+	"application/vnd.google-apps.form":         MimeCategoryDocuments,
+	"application/vnd.google-apps.drawing":      MimeCategoryImages,
+	"application/vnd.google-apps.photo":        MimeCategoryImages,
+	"application/vnd.google-apps.video":        MimeCategoryVideos,
+	"application/vnd.google-apps.audio":        MimeCategoryAudio,
+
+	"application/pdf":               MimeCategoryDocuments,
+	"application/rtf":               MimeCategoryDocuments,
+	"application/msword":            MimeCategoryDocuments,
+	"application/vnd.ms-excel":      MimeCategoryDocuments,
+	"application/vnd.ms-powerpoint": MimeCategoryDocuments,
+	"application/epub+zip":          MimeCategoryDocuments,
+	"application/json":              MimeCategoryDocuments,
+	"application/xml":               MimeCategoryDocuments,
+
+	"application/zip":              MimeCategoryArchives,
+	"application/x-zip-compressed": MimeCategoryArchives,
+	"application/gzip":             MimeCategoryArchives,
+	"application/x-gzip":           MimeCategoryArchives,
+	"application/x-tar":            MimeCategoryArchives,
+	"application/x-bzip2":          MimeCategoryArchives,
+	"application/x-xz":             MimeCategoryArchives,
+	"application/zstd":             MimeCategoryArchives,
+	"application/x-7z-compressed":  MimeCategoryArchives,
+	"application/x-rar-compressed": MimeCategoryArchives,
+	"application/vnd.rar":          MimeCategoryArchives,
+}
+
+// mimeCategoryPrefixes maps MIME type prefixes to categories.
+var mimeCategoryPrefixes = []struct {
+	prefix   string
+	category string
+}{
+	{"image/", MimeCategoryImages},
+	{"video/", MimeCategoryVideos},
+	{"audio/", MimeCategoryAudio},
+	{"text/", MimeCategoryDocuments},
+	{"application/vnd.openxmlformats-officedocument.", MimeCategoryDocuments},
+	{"application/vnd.oasis.opendocument.", MimeCategoryDocuments},
+}
+
+// MimeCategory returns the category of a MIME type: documents, images,
+// videos, audio, archives or other. Parameters such as "; charset=utf-8"
+// are ignored.
+func MimeCategory(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+
+	if category, ok := mimeCategories[mimeType]; ok {
+		return category
+	}
+	for _, p := range mimeCategoryPrefixes {
+		if strings.HasPrefix(mimeType, p.prefix) {
+			return p.category
+		}
+	}
+	return MimeCategoryOther
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMimeCategory(t *testing.T) {
+	tests := map[string]string{
+		"application/vnd.google-apps.document":                                    MimeCategoryDocuments,
+		"application/vnd.google-apps.spreadsheet":                                 MimeCategoryDocuments,
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document": MimeCategoryDocuments,
+		"application/vnd.oasis.opendocument.text":                                 MimeCategoryDocuments,
+		"application/pdf":                     MimeCategoryDocuments,
+		"text/plain; charset=utf-8":           MimeCategoryDocuments,
+		"application/vnd.google-apps.drawing": MimeCategoryImages,
+		"image/JPEG":                          MimeCategoryImages,
+		"video/mp4":                           MimeCategoryVideos,
+		"application/vnd.google-apps.video":   MimeCategoryVideos,
+		"audio/mpeg":                          MimeCategoryAudio,
+		"application/zip":                     MimeCategoryArchives,
+		"application/x-7z-compressed":         MimeCategoryArchives,
+		"application/octet-stream":            MimeCategoryOther,
+		"":                                    MimeCategoryOther,
+	}
+
+	for mimeType, want := range tests {
+		assert.Equal(t, want, MimeCategory(mimeType), mimeType)
+	}
+}
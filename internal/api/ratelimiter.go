@@ -20,6 +20,7 @@ import (
  * - Burst capacity handling
  * - Context-aware blocking
  * - Per-operation rate limiting
+ * - Backs off on throttled responses and recovers on sustained success
  * - Metrics collection
  *
  * Author: CloudPull Team
@@ -38,6 +39,16 @@ const (
 
 	// Rate limit for export operations (lower due to higher server load).
 	exportRateLimit = 3
+
+	// Throttled responses this close together lower the rate only once,
+	// since concurrent requests tend to be throttled together.
+	throttleCooldown = time.Second
+
+	// Minimum time between rate increases while recovering.
+	recoveryInterval = 10 * time.Second
+
+	// Successful requests needed since the last change before recovering.
+	recoverySuccesses = 10
 )
 
 // RateLimiter manages API request rate limiting.
@@ -49,6 +60,16 @@ type RateLimiter struct {
 	totalRequests   atomic.Int64
 	blockedRequests atomic.Int64
 	mu              sync.RWMutex
+
+	// Adaptive rate state, guarded by mu
+	now            func() time.Time
+	lastThrottle   time.Time
+	lastAdjustment time.Time
+	currentRate    int
+	minRate        int
+	maxRate        int
+	successStreak  int
+	throttles      int64
 }
 
 // RateLimiterConfig holds rate limiter configuration.
@@ -57,6 +78,11 @@ type RateLimiterConfig struct {
 	BurstSize       int
 	BatchRateLimit  int
 	ExportRateLimit int
+
+	// Bounds for adapting RateLimit to throttling; 0 selects 1 and
+	// RateLimit respectively. RateLimit itself is clamped to them.
+	MinRateLimit int
+	MaxRateLimit int
 }
 
 // DefaultRateLimiterConfig returns default configuration.
@@ -75,12 +101,40 @@ func NewRateLimiter(config *RateLimiterConfig) *RateLimiter {
 		config = DefaultRateLimiterConfig()
 	}
 
-	return &RateLimiter{
+	minRate := config.MinRateLimit
+	if minRate < 1 {
+		minRate = 1
+	}
+	maxRate := config.MaxRateLimit
+	if maxRate <= 0 {
+		maxRate = config.RateLimit
+	}
+	if maxRate < minRate {
+		maxRate = minRate
+	}
+
+	rl := &RateLimiter{
 		limiter:       rate.NewLimiter(rate.Limit(config.RateLimit), config.BurstSize),
 		batchLimiter:  rate.NewLimiter(rate.Limit(config.BatchRateLimit), config.BatchRateLimit*2),
 		exportLimiter: rate.NewLimiter(rate.Limit(config.ExportRateLimit), config.ExportRateLimit),
 		lastResetTime: time.Now(),
+		now:           time.Now,
+		currentRate:   config.RateLimit,
+		minRate:       minRate,
+		maxRate:       maxRate,
+	}
+
+	// A starting rate outside the bounds would never be reached again
+	// once the rate adapts, so it is clamped from the start
+	if rl.currentRate > maxRate {
+		rl.currentRate = maxRate
+		rl.setLimits(maxRate)
+	} else if rl.currentRate < minRate {
+		rl.currentRate = minRate
+		rl.setLimits(minRate)
 	}
+
+	return rl
 }
 
 // Wait blocks until a request can proceed.
@@ -146,8 +200,82 @@ func (rl *RateLimiter) TryAcquire() bool {
 	return rl.limiter.Allow()
 }
 
+// RecordThrottle lowers the request rate after Drive reported a rate limit
+// error. The rate is halved, but not below the configured minimum.
+func (rl *RateLimiter) RecordThrottle() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.throttles++
+	rl.successStreak = 0
+	if !rl.lastThrottle.IsZero() && now.Sub(rl.lastThrottle) < throttleCooldown {
+		return
+	}
+	rl.lastThrottle = now
+	rl.lastAdjustment = now
+
+	newRate := rl.currentRate / 2
+	if newRate < rl.minRate {
+		newRate = rl.minRate
+	}
+	if newRate != rl.currentRate {
+		rl.currentRate = newRate
+		rl.setLimits(newRate)
+	}
+}
+
+// RecordSuccess records a request that was not throttled. After enough
+// successes the rate recovers in steps of a tenth of the maximum, at most
+// once per recoveryInterval.
+func (rl *RateLimiter) RecordSuccess() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.successStreak++
+	if rl.currentRate >= rl.maxRate || rl.successStreak < recoverySuccesses {
+		return
+	}
+
+	now := rl.now()
+	if now.Sub(rl.lastAdjustment) < recoveryInterval {
+		return
+	}
+
+	step := rl.maxRate / 10
+	if step < 1 {
+		step = 1
+	}
+	newRate := rl.currentRate + step
+	if newRate > rl.maxRate {
+		newRate = rl.maxRate
+	}
+
+	rl.currentRate = newRate
+	rl.setLimits(newRate)
+	rl.lastAdjustment = now
+	rl.successStreak = 0
+}
+
+// CurrentRateLimit returns the request rate currently enforced.
+func (rl *RateLimiter) CurrentRateLimit() int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.currentRate
+}
+
 // SetRateLimit updates the rate limit dynamically.
 func (rl *RateLimiter) SetRateLimit(rateLimit int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.currentRate = rateLimit
+	rl.setLimits(rateLimit)
+}
+
+// setLimits applies rateLimit to the main limiter and scales the batch and
+// export limiters with it.
+func (rl *RateLimiter) setLimits(rateLimit int) {
 	rl.limiter.SetLimit(rate.Limit(rateLimit))
 
 	// Update batch and export limiters proportionally
@@ -211,6 +339,8 @@ func (rl *RateLimiter) GetMetrics() RateLimiterMetrics {
 		RequestsPerSecond: requestsPerSecond,
 		BlockRate:         blockRate,
 		Duration:          duration,
+		CurrentRateLimit:  rl.currentRate,
+		Throttles:         rl.throttles,
 	}
 }
 
@@ -221,6 +351,7 @@ func (rl *RateLimiter) ResetMetrics() {
 
 	rl.totalRequests.Store(0)
 	rl.blockedRequests.Store(0)
+	rl.throttles = 0
 	rl.lastResetTime = time.Now()
 }
 
@@ -241,81 +372,8 @@ type RateLimiterMetrics struct {
 	RequestsPerSecond float64
 	BlockRate         float64
 	Duration          time.Duration
-}
-
-// AdaptiveRateLimiter adjusts rate limits based on API responses.
-type AdaptiveRateLimiter struct {
-	lastAdjustment time.Time
-	*RateLimiter
-	baseRateLimit     int
-	currentRateLimit  int
-	consecutiveErrors int
-	mu                sync.RWMutex
-}
-
-// NewAdaptiveRateLimiter creates a rate limiter that adapts to API conditions.
-func NewAdaptiveRateLimiter(config *RateLimiterConfig) *AdaptiveRateLimiter {
-	if config == nil {
-		config = DefaultRateLimiterConfig()
-	}
-
-	return &AdaptiveRateLimiter{
-		RateLimiter:      NewRateLimiter(config),
-		baseRateLimit:    config.RateLimit,
-		currentRateLimit: config.RateLimit,
-		lastAdjustment:   time.Now(),
-	}
-}
-
-// RecordSuccess records a successful API call.
-func (arl *AdaptiveRateLimiter) RecordSuccess() {
-	arl.mu.Lock()
-	defer arl.mu.Unlock()
-
-	// Reset error counter on success
-	arl.consecutiveErrors = 0
-
-	// Gradually increase rate limit if we've been throttled
-	if arl.currentRateLimit < arl.baseRateLimit &&
-		time.Since(arl.lastAdjustment) > 30*time.Second {
-
-		newLimit := arl.currentRateLimit + 1
-		if newLimit > arl.baseRateLimit {
-			newLimit = arl.baseRateLimit
-		}
-
-		arl.currentRateLimit = newLimit
-		arl.SetRateLimit(newLimit)
-		arl.lastAdjustment = time.Now()
-	}
-}
-
-// RecordRateLimitError records a rate limit error.
-func (arl *AdaptiveRateLimiter) RecordRateLimitError() {
-	arl.mu.Lock()
-	defer arl.mu.Unlock()
-
-	arl.consecutiveErrors++
-
-	// Reduce rate limit on rate limit errors
-	if arl.consecutiveErrors >= 2 {
-		newLimit := arl.currentRateLimit / 2
-		if newLimit < 1 {
-			newLimit = 1
-		}
-
-		arl.currentRateLimit = newLimit
-		arl.SetRateLimit(newLimit)
-		arl.lastAdjustment = time.Now()
-		arl.consecutiveErrors = 0
-	}
-}
-
-// GetCurrentRateLimit returns the current rate limit.
-func (arl *AdaptiveRateLimiter) GetCurrentRateLimit() int {
-	arl.mu.RLock()
-	defer arl.mu.RUnlock()
-	return arl.currentRateLimit
+	CurrentRateLimit  int
+	Throttles         int64
 }
 
 // MultiTenantRateLimiter manages rate limits for multiple users/tenants.
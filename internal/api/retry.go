@@ -0,0 +1,101 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+/**
+ * Retryable Error Policy
+ *
+ * Features:
+ * - Configurable HTTP status codes and error message fragments to retry
+ * - Configured entries are merged with the defaults; a leading "-" removes one
+ * - Validation of status codes and fragments
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+// DefaultRetryableCodes are the HTTP status codes retried unless removed by
+// the configuration: rate limiting and server errors.
+var DefaultRetryableCodes = []int{429, 500, 502, 503, 504}
+
+// DefaultRetryableReasons are the error message fragments retried unless
+// removed by the configuration: network failures.
+var DefaultRetryableReasons = []string{"connection refused", "connection reset", "timeout"}
+
+// retryPolicy decides which failed requests are retried.
+type retryPolicy struct {
+	codes   map[int]bool
+	reasons []string
+}
+
+// defaultRetryPolicy is used by clients without a configured policy.
+var defaultRetryPolicy, _ = buildRetryPolicy(nil, nil)
+
+// buildRetryPolicy merges configured codes and reasons with the defaults.
+// A negative code, such as -500, removes that code, and a reason starting
+// with "-" removes that reason. Codes must be HTTP status codes (100-599).
+func buildRetryPolicy(codes []int, reasons []string) (*retryPolicy, error) {
+	policy := &retryPolicy{codes: make(map[int]bool)}
+	for _, code := range DefaultRetryableCodes {
+		policy.codes[code] = true
+	}
+	for _, code := range codes {
+		remove := code < 0
+		if remove {
+			code = -code
+		}
+		if code < 100 || code > 599 {
+			return nil, errors.Errorf("invalid retryable HTTP status code %d", code)
+		}
+		policy.codes[code] = !remove
+	}
+	for code, retry := range policy.codes {
+		if !retry {
+			delete(policy.codes, code)
+		}
+	}
+
+	removed := make(map[string]bool)
+	added := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		reason = strings.TrimSpace(reason)
+		name, remove := strings.CutPrefix(reason, "-")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, errors.Errorf("invalid retryable reason %q: empty", reason)
+		}
+		if remove {
+			removed[name] = true
+		} else {
+			added = append(added, name)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, reason := range append(append([]string{}, DefaultRetryableReasons...), added...) {
+		if removed[reason] || seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		policy.reasons = append(policy.reasons, reason)
+	}
+
+	return policy, nil
+}
+
+// retryable reports whether a request failing with the given HTTP status
+// code, 0 when there is none, and error message should be retried.
+func (p *retryPolicy) retryable(code int, message string) bool {
+	if p.codes[code] {
+		return true
+	}
+	for _, reason := range p.reasons {
+		if strings.Contains(message, reason) {
+			return true
+		}
+	}
+	return false
+}
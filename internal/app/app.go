@@ -17,18 +17,23 @@ package app
 import (
 	"context"
 	"io"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/spf13/viper"
+	"google.golang.org/api/drive/v3"
+	"gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/VatsalSy/CloudPull/internal/api"
 	"github.com/VatsalSy/CloudPull/internal/config"
 	"github.com/VatsalSy/CloudPull/internal/errors"
 	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/metrics"
 	"github.com/VatsalSy/CloudPull/internal/state"
 	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
 	"github.com/VatsalSy/CloudPull/internal/util"
@@ -42,13 +47,25 @@ type App struct {
 	apiClient     *api.DriveClient
 	stateManager  *state.Manager
 	syncEngine    *cloudsync.Engine
+	metricsServer *metrics.Server
+	logCloser     io.Closer
 	config        *config.Config
 	shutdownChan  chan struct{}
 	configLoader  func() (*config.Config, error)
+	logWriter     func(io.Writer) io.Writer
+	bandwidth     *cloudsync.SharedBandwidthLimiter
 	mu            sync.RWMutex
 	shutdownOnce  sync.Once
+	metricsOnce   sync.Once
 	isInitialized bool
 	isRunning     bool
+
+	// Control socket state, guarded by controlMu
+	controlListener net.Listener
+	controlPath     string
+	stopChan        chan os.Signal
+	controlWG       sync.WaitGroup
+	controlMu       sync.Mutex
 }
 
 // Option is a functional option for configuring the App.
@@ -61,10 +78,23 @@ func WithConfigLoader(loader func() (*config.Config, error)) Option {
 	}
 }
 
+// WithLogWriter wraps the log output chosen by the configuration, such as
+// to keep log lines from breaking a progress bar.
+func WithLogWriter(wrap func(io.Writer) io.Writer) Option {
+	return func(app *App) {
+		app.logWriter = wrap
+	}
+}
+
+// sharedBandwidth is the bandwidth limit shared by the download managers of
+// every session in the process.
+var sharedBandwidth = cloudsync.NewSharedBandwidthLimiter(0)
+
 // New creates a new application instance.
 func New(opts ...Option) (*App, error) {
 	app := &App{
 		shutdownChan: make(chan struct{}),
+		bandwidth:    sharedBandwidth,
 		configLoader: func() (*config.Config, error) {
 			return config.Load("")
 		}, // default to global config loader
@@ -95,20 +125,27 @@ func (app *App) Initialize() error {
 
 	// Initialize logger
 	// Create output writer based on config
-	var output io.Writer = os.Stdout
-	outputPath := cfg.GetString("log.output")
-	if outputPath != "" && outputPath != "stdout" {
-		file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			return errors.Wrap(err, "failed to open log file")
-		}
-		output = file
+	output, err := newLogOutput(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to open log file")
+	}
+	// stdout and stderr stay open; only the rotating log file is closed
+	if rotating, ok := output.(*lumberjack.Logger); ok {
+		app.logCloser = rotating
+	}
+	if app.logWriter != nil {
+		output = app.logWriter(output)
+	}
+
+	logFormat, err := logger.ParseFormat(cfg.GetLogFormat())
+	if err != nil {
+		return errors.Wrap(err, "invalid log format")
 	}
 
 	logConfig := &logger.Config{
 		Level:         cfg.GetLogLevel(),
 		Output:        output,
-		Pretty:        cfg.GetString("log.format") == "pretty",
+		Format:        logFormat,
 		IncludeCaller: true,
 	}
 
@@ -119,6 +156,7 @@ func (app *App) Initialize() error {
 
 	app.logger.Info("Initializing CloudPull",
 		"version", cfg.GetString("version"),
+		"profile", cfg.ActiveProfile(),
 		"config", viper.ConfigFileUsed(),
 	)
 
@@ -172,8 +210,8 @@ func (app *App) InitializeAuth() error {
 		return errors.Wrap(err, "credentials file not found")
 	}
 
-	// Get token path
-	tokenPath := filepath.Join(app.config.GetDataDir(), "token.json")
+	// Get token path (per profile so accounts don't share tokens)
+	tokenPath := app.config.GetTokenFile()
 
 	// Initialize auth manager
 	authManager, err := api.NewAuthManager(credentialsPath, tokenPath, app.logger)
@@ -181,6 +219,7 @@ func (app *App) InitializeAuth() error {
 		return errors.Wrap(err, "failed to initialize auth manager")
 	}
 
+	authManager.SetTransportConfig(app.transportConfig())
 	app.authManager = authManager
 
 	// Only initialize API client if already authenticated
@@ -195,16 +234,13 @@ func (app *App) InitializeAuth() error {
 		}
 
 		// Initialize rate limiter
-		rateLimiterConfig := &api.RateLimiterConfig{
-			RateLimit:       app.config.GetInt("api.rate_limit"),
-			BurstSize:       app.config.GetInt("api.rate_limit") * 2,
-			BatchRateLimit:  app.config.GetInt("api.rate_limit") / 2,
-			ExportRateLimit: app.config.GetInt("api.rate_limit") / 4,
-		}
-		rateLimiter := api.NewRateLimiter(rateLimiterConfig)
+		rateLimiter := api.NewRateLimiter(app.rateLimiterConfig())
 
 		// Initialize API client
-		app.apiClient = api.NewDriveClient(driveService, rateLimiter, app.logger)
+		app.apiClient, err = app.newDriveClient(driveService, rateLimiter)
+		if err != nil {
+			return err
+		}
 		app.logger.Info("API client initialized successfully")
 	}
 
@@ -229,28 +265,9 @@ func (app *App) InitializeSyncEngine() error {
 		return nil // Already initialized
 	}
 
-	// Create sync engine configuration
-	engineConfig := &cloudsync.EngineConfig{
-		WalkerConfig: &cloudsync.WalkerConfig{
-			MaxDepth:          app.config.GetInt("sync.max_depth"),
-			Strategy:          cloudsync.TraversalBFS,
-			Concurrency:       3, // Number of concurrent folder scanners
-			ChannelBufferSize: 100,
-		},
-		DownloadConfig: &cloudsync.DownloadManagerConfig{
-			MaxConcurrent:   app.config.GetInt("sync.max_concurrent"),
-			ChunkSize:       app.config.GetInt64("sync.chunk_size_bytes"),
-			VerifyChecksums: true,
-			TempDir:         app.config.GetString("sync.temp_dir"),
-		},
-		WorkerConfig: &cloudsync.WorkerPoolConfig{
-			WorkerCount:     app.config.GetInt("sync.max_concurrent"),
-			MaxRetries:      app.config.GetInt("sync.max_retries"),
-			ShutdownTimeout: app.config.GetDuration("sync.shutdown_timeout"),
-		},
-		ProgressInterval:   app.config.GetDuration("sync.progress_interval"),
-		CheckpointInterval: app.config.GetDuration("sync.checkpoint_interval"),
-		MaxErrors:          app.config.GetInt("sync.max_errors"),
+	engineConfig, err := app.engineConfig()
+	if err != nil {
+		return err
 	}
 
 	// Create sync engine
@@ -271,6 +288,185 @@ func (app *App) InitializeSyncEngine() error {
 	return nil
 }
 
+// engineConfig builds the sync engine configuration from the application
+// configuration.
+func (app *App) engineConfig() (*cloudsync.EngineConfig, error) {
+	bandwidthLimit, err := app.config.GetBandwidthLimitBytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid bandwidth limit")
+	}
+
+	globalBandwidthLimit, err := app.config.GetGlobalBandwidthLimitBytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid global bandwidth limit")
+	}
+	app.bandwidth.SetLimit(globalBandwidthLimit)
+
+	maxTotalBytes, err := app.config.GetMaxTotalBytes()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid maximum total bytes")
+	}
+
+	priorityRules, tierLimits, err := app.priorityConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	checksumAlgorithm, err := cloudsync.ParseChecksumAlgorithm(app.config.GetString("sync.checksum_algorithm"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid checksum algorithm")
+	}
+
+	exportFormats, err := app.exportFormats()
+	if err != nil {
+		return nil, err
+	}
+	if cloudsync.SheetsExportedAsCSV(exportFormats) {
+		app.logger.Warn("Google Sheets are exported as CSV, which keeps only the first tab of each sheet; use files.sheets_export_mode xlsx to keep every tab")
+	}
+
+	fileMode, dirMode, err := app.config.GetFileModes()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid file permissions")
+	}
+
+	pathTemplate, err := cloudsync.ParsePathTemplate(app.config.Sync.PathTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	destDateLayout, err := app.config.GetDestDateLayout()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid destination date subdirectory")
+	}
+
+	scheduleOrder, err := cloudsync.ParseScheduleOrder(app.config.Sync.ScheduleOrder)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid schedule order")
+	}
+
+	conflictPolicy, err := cloudsync.ParseConflictPolicy(app.config.Sync.ConflictPolicy)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid conflict policy")
+	}
+
+	casMode, err := cloudsync.ParseCASMode(app.config.Sync.CASMode)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cas mode")
+	}
+
+	postDownloadPolicy, err := cloudsync.ParsePostDownloadFailurePolicy(app.config.GetString("files.post_download_on_failure"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid post-download failure policy")
+	}
+	postDownload := &cloudsync.PostDownloadConfig{
+		Command:       app.config.GetString("files.post_download_command"),
+		OnFailure:     postDownloadPolicy,
+		Timeout:       app.config.GetDuration("files.post_download_timeout"),
+		MaxConcurrent: app.config.GetInt("files.post_download_concurrency"),
+	}
+
+	return &cloudsync.EngineConfig{
+		WalkerConfig: app.walkerConfig(exportFormats),
+		DownloadConfig: &cloudsync.DownloadManagerConfig{
+			MaxConcurrent:       app.config.GetInt("sync.max_concurrent"),
+			ChunkSize:           app.config.GetInt64("sync.chunk_size_bytes"),
+			VerifyChecksums:     true,
+			ChecksumAlgorithm:   checksumAlgorithm,
+			PostDownload:        postDownload,
+			PerFileTimeout:      app.config.GetDuration("sync.per_file_timeout"),
+			PerChunkTimeout:     app.config.GetDuration("sync.per_chunk_timeout"),
+			TempDir:             app.config.GetString("sync.temp_dir"),
+			PriorityRules:       priorityRules,
+			TierBandwidthLimits: tierLimits,
+			SharedBandwidth:     app.bandwidth,
+			ExportFormats:       exportFormats,
+			PathTemplate:        pathTemplate,
+			OrganizeByCategory:  app.config.Sync.OrganizeByCategory,
+			ScheduleOrder:       scheduleOrder,
+			ConflictPolicy:      conflictPolicy,
+			CASMode:             casMode,
+			MaxPathLength:       app.config.Files.MaxPathLength,
+			FileMode:            fileMode,
+			DirMode:             dirMode,
+		},
+		WorkerConfig: &cloudsync.WorkerPoolConfig{
+			WorkerCount:     app.config.GetInt("sync.max_concurrent"),
+			MaxRetries:      app.config.GetInt("sync.max_retries"),
+			ShutdownTimeout: app.config.GetDuration("sync.shutdown_timeout"),
+		},
+		HookConfig: &cloudsync.HookConfig{
+			OnCompleteURL:     app.config.GetString("hooks.on_complete_url"),
+			OnCompleteCommand: app.config.GetString("hooks.on_complete_command"),
+			Timeout:           app.config.GetDuration("hooks.timeout"),
+			MaxRetries:        app.config.GetInt("hooks.max_retries"),
+		},
+		BandwidthLimit:       bandwidthLimit,
+		ProgressInterval:     app.config.GetDuration("sync.progress_interval"),
+		CheckpointInterval:   app.config.GetDuration("sync.checkpoint_interval"),
+		MaxErrors:            app.config.GetInt("sync.max_errors"),
+		MaxConsecutiveErrors: app.config.Sync.MaxConsecutiveErrors,
+		ContinueOnErrors:     app.config.Sync.ContinueOnErrors,
+		MaxTotalBytes:        maxTotalBytes,
+		MaxAPICalls:          app.config.API.MaxCallsPerRun,
+		BatchSize:            app.config.Sync.BatchSize,
+		MaxQueuedFiles:       app.config.Sync.MaxQueuedFiles,
+		WriteReport:          app.config.Sync.WriteReport,
+		ScanThenDownload:     app.config.Sync.ScanThenDownload,
+		RefreshModified:      app.config.Sync.RefreshModified,
+		PersistEvents:        app.config.Sync.PersistEvents,
+		NoProgressTimeout:    app.config.GetDuration("sync.no_progress_timeout"),
+		DestDateSubdir:       destDateLayout,
+	}, nil
+}
+
+// walkerConfig builds the folder walker settings shared by sync and ls, so
+// a listing applies the same filters a sync would.
+func (app *App) walkerConfig(exportFormats map[string][]string) *cloudsync.WalkerConfig {
+	return &cloudsync.WalkerConfig{
+		MaxDepth:          app.config.GetInt("sync.max_depth"),
+		Strategy:          cloudsync.TraversalBFS,
+		Concurrency:       app.config.Sync.WalkerConcurrent,
+		ChannelBufferSize: app.config.Sync.QueueSize,
+		FollowShortcuts:   app.config.Files.FollowShortcuts,
+
+		MaxConcurrentFolders: app.config.Sync.WalkerConcurrent,
+		PageDelay:            cloudsync.DefaultWalkerConfig().PageDelay,
+		ExportFormats:        exportFormats,
+		RespectIgnoreFiles:   app.config.Files.RespectIgnoreFiles,
+		SkipHidden:           app.config.Files.SkipHidden,
+	}
+}
+
+// priorityConfig converts the MIME type priority rules and per-tier
+// bandwidth caps from the configuration.
+func (app *App) priorityConfig() ([]cloudsync.PriorityRule, map[cloudsync.PriorityTier]int64, error) {
+	rules := make([]cloudsync.PriorityRule, 0, len(app.config.Sync.PriorityRules))
+	for _, rule := range app.config.Sync.PriorityRules {
+		tier, err := cloudsync.ParsePriorityTier(rule.Tier)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid priority rule")
+		}
+		rules = append(rules, cloudsync.PriorityRule{Pattern: rule.MimeType, Tier: tier})
+	}
+
+	limits, err := app.config.GetTierBandwidthLimits()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid tier bandwidth limit")
+	}
+
+	tierLimits := make(map[cloudsync.PriorityTier]int64, len(limits))
+	for name, limit := range limits {
+		tier, err := cloudsync.ParsePriorityTier(name)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid tier bandwidth limit")
+		}
+		tierLimits[tier] = limit
+	}
+
+	return rules, tierLimits, nil
+}
+
 // InitializeForAuth initializes the application for authentication operations.
 // This combines Initialize() and InitializeAuth() for convenience.
 func (app *App) InitializeForAuth() error {
@@ -346,6 +542,32 @@ func (app *App) ExchangeAuthCode(ctx context.Context, authCode string) error {
 	return nil
 }
 
+// StartLocalAuth starts an OAuth2 authorization whose consent page
+// redirects to a temporary local server. Complete it with FinishLocalAuth.
+func (app *App) StartLocalAuth() (*api.LocalAuth, error) {
+	if app.authManager == nil {
+		if err := app.InitializeAuth(); err != nil {
+			return nil, err
+		}
+	}
+	return app.authManager.StartLocalAuth()
+}
+
+// FinishLocalAuth waits for the browser to return to the local server of
+// flow, saves the token and initializes the API client.
+func (app *App) FinishLocalAuth(ctx context.Context, flow *api.LocalAuth) error {
+	if _, err := flow.Wait(ctx); err != nil {
+		return err
+	}
+
+	if err := app.initializeAPIClient(ctx); err != nil {
+		return errors.Wrap(err, "failed to initialize API client after authentication")
+	}
+
+	app.logger.Info("Authentication successful")
+	return nil
+}
+
 // initializeAPIClient initializes the API client after authentication.
 func (app *App) initializeAPIClient(ctx context.Context) error {
 	if app.authManager == nil {
@@ -359,20 +581,81 @@ func (app *App) initializeAPIClient(ctx context.Context) error {
 	}
 
 	// Initialize rate limiter
-	rateLimiterConfig := &api.RateLimiterConfig{
-		RateLimit:       app.config.GetInt("api.rate_limit"),
-		BurstSize:       app.config.GetInt("api.rate_limit") * 2,
-		BatchRateLimit:  app.config.GetInt("api.rate_limit") / 2,
-		ExportRateLimit: app.config.GetInt("api.rate_limit") / 4,
-	}
-	rateLimiter := api.NewRateLimiter(rateLimiterConfig)
+	rateLimiter := api.NewRateLimiter(app.rateLimiterConfig())
 
 	// Initialize API client
-	app.apiClient = api.NewDriveClient(driveService, rateLimiter, app.logger)
+	app.apiClient, err = app.newDriveClient(driveService, rateLimiter)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// newDriveClient creates a Drive API client with the configured retry,
+// field and permission settings.
+func (app *App) newDriveClient(driveService *drive.Service, rateLimiter *api.RateLimiter) (*api.DriveClient, error) {
+	client := api.NewDriveClient(driveService, rateLimiter, app.logger)
+	client.SetListMaxRetries(app.config.API.ListMaxRetries)
+	if err := client.SetFileFields(app.config.API.FileFields); err != nil {
+		return nil, errors.Wrap(err, "invalid api.file_fields")
+	}
+	if err := client.SetRetryPolicy(app.config.API.RetryableCodes, app.config.API.RetryableReasons); err != nil {
+		return nil, errors.Wrap(err, "invalid api.retryable_codes or api.retryable_reasons")
+	}
+	fileMode, dirMode, err := app.config.GetFileModes()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid file permissions")
+	}
+	client.SetFileModes(fileMode, dirMode)
+
+	// A token revoked mid-sync is refreshed instead of failing every call
+	if app.authManager != nil {
+		client.SetReauthHandler(app.authManager.Reauthorize)
+	}
+	return client, nil
+}
+
+// exportFormats parses files.export_formats and applies
+// files.sheets_export_mode to Google Sheets not listed there.
+func (app *App) exportFormats() (map[string][]string, error) {
+	formats, err := cloudsync.ParseExportFormats(app.config.Files.ExportFormats)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid files.export_formats")
+	}
+
+	formats, err = cloudsync.ApplySheetsExportMode(formats, app.config.Files.SheetsExportMode)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid files.sheets_export_mode")
+	}
+	return formats, nil
+}
+
+// rateLimiterConfig builds the API rate limiter configuration. The rate
+// adapts to throttling between api.min_rate_limit and api.max_rate_limit.
+func (app *App) rateLimiterConfig() *api.RateLimiterConfig {
+	rateLimit := app.config.GetInt("api.rate_limit")
+	return &api.RateLimiterConfig{
+		RateLimit:       rateLimit,
+		BurstSize:       rateLimit * 2,
+		BatchRateLimit:  rateLimit / 2,
+		ExportRateLimit: rateLimit / 4,
+		MinRateLimit:    app.config.GetInt("api.min_rate_limit"),
+		MaxRateLimit:    app.config.GetInt("api.max_rate_limit"),
+	}
+}
+
+// transportConfig sizes the Drive connection pool for the configured
+// download concurrency.
+func (app *App) transportConfig() *api.TransportConfig {
+	config := api.DefaultTransportConfig(app.config.GetInt("sync.max_concurrent"))
+	if perHost := app.config.GetInt("api.max_idle_conns_per_host"); perHost > 0 {
+		config.MaxIdleConnsPerHost = perHost
+		config.MaxIdleConns = perHost * 2
+	}
+	return config
+}
+
 // RevokeAuth revokes the current authentication.
 func (app *App) RevokeAuth(ctx context.Context) error {
 	if app.authManager == nil {
@@ -400,7 +683,7 @@ func (app *App) IsAuthenticated() bool {
 
 // StartSync starts a new sync session.
 func (app *App) StartSync(ctx context.Context, folderID, outputDir string, options *SyncOptions) error {
-	if err := app.ensureReady(); err != nil {
+	if err := app.ensureReady(ctx); err != nil {
 		return err
 	}
 
@@ -425,13 +708,17 @@ func (app *App) StartSync(ctx context.Context, folderID, outputDir string, optio
 	go app.handleSignals(cancel)
 
 	// Start sync engine
-	if err := app.syncEngine.StartNewSession(ctx, folderID, outputDir); err != nil {
+	sessionID, err := app.startOrResume(ctx, folderID, outputDir, options)
+	if err != nil {
 		app.mu.Lock()
 		app.isRunning = false
 		app.mu.Unlock()
 		return errors.Wrap(err, "failed to start sync")
 	}
 
+	app.registerSessionMetrics(sessionID)
+	defer app.unregisterSessionMetrics(sessionID)
+
 	// Monitor progress
 	go app.monitorProgress(ctx)
 
@@ -455,7 +742,7 @@ func (app *App) StartSync(ctx context.Context, folderID, outputDir string, optio
 
 // StartSyncWithSession starts a new sync session and returns the session ID.
 func (app *App) StartSyncWithSession(ctx context.Context, folderID, outputDir string, options *SyncOptions) (string, error) {
-	if err := app.ensureReady(); err != nil {
+	if err := app.ensureReady(ctx); err != nil {
 		return "", err
 	}
 
@@ -473,7 +760,7 @@ func (app *App) StartSyncWithSession(ctx context.Context, folderID, outputDir st
 	}
 
 	// Start sync engine and get session ID
-	sessionID, err := app.syncEngine.StartNewSessionWithID(ctx, folderID, outputDir)
+	sessionID, err := app.startOrResume(ctx, folderID, outputDir, options)
 	if err != nil {
 		app.mu.Lock()
 		app.isRunning = false
@@ -481,11 +768,15 @@ func (app *App) StartSyncWithSession(ctx context.Context, folderID, outputDir st
 		return "", errors.Wrap(err, "failed to start sync")
 	}
 
+	app.registerSessionMetrics(sessionID)
+
 	// Monitor progress
 	go app.monitorProgress(ctx)
 
 	// Wait for completion or cancellation in background
 	go func() {
+		defer app.unregisterSessionMetrics(sessionID)
+
 		select {
 		case <-app.syncEngine.WaitForCompletion():
 			// Sync completed naturally
@@ -504,9 +795,72 @@ func (app *App) StartSyncWithSession(ctx context.Context, folderID, outputDir st
 	return sessionID, nil
 }
 
+// startOrResume starts a new session of folderID into outputDir. An
+// incomplete session of the same folder and destination is resumed instead
+// when options.ResumeExisting is set, and otherwise reported.
+func (app *App) startOrResume(ctx context.Context, folderID, outputDir string, options *SyncOptions) (string, error) {
+	dateLayout := ""
+	if options != nil {
+		dateLayout = options.DestDateSubdir
+	}
+	existing, err := app.FindResumableSession(ctx, folderID, outputDir, dateLayout)
+	if err != nil {
+		app.logger.Warn("Failed to look up earlier sessions", "error", err)
+	}
+
+	if existing != nil {
+		if options != nil && options.ResumeExisting {
+			app.logger.Info("Resuming incomplete session of the same folder and destination",
+				"session_id", existing.ID,
+				"status", existing.Status,
+			)
+			return existing.ID, app.syncEngine.ResumeSession(ctx, existing.ID)
+		}
+
+		app.logger.Warn("An incomplete session of the same folder and destination exists, starting a new one",
+			"session_id", existing.ID,
+			"status", existing.Status,
+			"hint", "resume it with 'cloudpull resume "+existing.ID+"' or sync with --resume-existing",
+		)
+	}
+
+	return app.syncEngine.StartNewSessionWithID(ctx, folderID, outputDir)
+}
+
+// FindResumableSession returns the latest session of folderID into
+// outputDir that can be resumed, or nil if there is none or it is still
+// running in this or another process. With a dated destination subdirectory, sessions
+// of today's subdirectory are looked up; dateLayout overrides
+// sync.dest_date_subdir like SyncOptions.DestDateSubdir.
+func (app *App) FindResumableSession(ctx context.Context, folderID, outputDir, dateLayout string) (*state.Session, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	if dateLayout == "" {
+		var err error
+		if dateLayout, err = app.config.GetDestDateLayout(); err != nil {
+			return nil, err
+		}
+	}
+	outputDir, err := cloudsync.DatedDestination(outputDir, dateLayout, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := app.stateManager.GetSessionByRootFolder(ctx, folderID, outputDir)
+	if err != nil || session == nil {
+		return nil, err
+	}
+	if app.IsSessionRunning(session.ID) || session.IsRunning() {
+		return nil, nil
+	}
+	return session, nil
+}
+
 // ResumeSync resumes an existing sync session.
 func (app *App) ResumeSync(ctx context.Context, sessionID string) error {
-	if err := app.ensureReady(); err != nil {
+	if err := app.ensureReady(ctx); err != nil {
 		return err
 	}
 
@@ -533,6 +887,9 @@ func (app *App) ResumeSync(ctx context.Context, sessionID string) error {
 		return errors.Wrap(err, "failed to resume sync")
 	}
 
+	app.registerSessionMetrics(sessionID)
+	defer app.unregisterSessionMetrics(sessionID)
+
 	// Monitor progress
 	go app.monitorProgress(ctx)
 
@@ -554,64 +911,470 @@ func (app *App) ResumeSync(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-// GetSessions returns all sync sessions.
-func (app *App) GetSessions(ctx context.Context) ([]*state.Session, error) {
-	if app.stateManager == nil {
-		return nil, errors.Errorf("state manager not initialized")
+// ScanSync walks a Drive folder into a new session without downloading
+// anything and waits until the scan ends. It returns the session ID; the
+// files are downloaded later by DownloadScanned.
+func (app *App) ScanSync(ctx context.Context, folderID, outputDir string, options *SyncOptions) (string, error) {
+	if err := app.ensureReady(ctx); err != nil {
+		return "", err
 	}
 
-	// Get up to 100 recent sessions
-	return app.stateManager.Sessions().List(ctx, 100, 0)
-}
+	app.mu.Lock()
+	if app.isRunning {
+		app.mu.Unlock()
+		return "", errors.Errorf("sync already running")
+	}
+	app.isRunning = true
+	app.mu.Unlock()
 
-// GetLatestSession returns the most recent session.
-func (app *App) GetLatestSession(ctx context.Context) (*state.Session, error) {
-	if app.stateManager == nil {
-		return nil, errors.Errorf("state manager not initialized")
+	defer func() {
+		app.mu.Lock()
+		app.isRunning = false
+		app.mu.Unlock()
+	}()
+
+	// Apply options
+	if options != nil {
+		app.applySyncOptions(options)
 	}
 
-	sessions, err := app.stateManager.Sessions().List(ctx, 100, 0)
+	// Create context with cancellation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Setup signal handling
+	go app.handleSignals(cancel)
+
+	sessionID, err := app.syncEngine.ScanNewSession(ctx, folderID, outputDir)
 	if err != nil {
-		return nil, err
+		return "", errors.Wrap(err, "failed to start scan")
 	}
 
-	if len(sessions) == 0 {
-		return nil, nil
+	// Wait for completion or cancellation
+	select {
+	case <-app.syncEngine.WaitForCompletion():
+		app.logger.Info("Scan completed", "session_id", sessionID)
+	case <-ctx.Done():
+		app.logger.Info("Scan canceled")
+		app.syncEngine.Stop()
 	}
 
-	// Sessions are ordered by created_at DESC
-	return sessions[0], nil
+	return sessionID, nil
 }
 
-// GetProgress returns current sync progress.
-func (app *App) GetProgress() *cloudsync.SyncProgress {
-	app.mu.RLock()
-	defer app.mu.RUnlock()
+// DownloadScanned downloads the files of a session recorded by ScanSync
+// without listing Drive folders again. A non-empty outputDir replaces the
+// destination recorded by the scan.
+func (app *App) DownloadScanned(ctx context.Context, sessionID, outputDir string) error {
+	if err := app.ensureReady(ctx); err != nil {
+		return err
+	}
 
-	if app.syncEngine == nil || !app.isRunning {
-		return nil
+	app.mu.Lock()
+	if app.isRunning {
+		app.mu.Unlock()
+		return errors.Errorf("sync already running")
 	}
+	app.isRunning = true
+	app.mu.Unlock()
 
-	return app.syncEngine.GetProgress()
-}
+	defer func() {
+		app.mu.Lock()
+		app.isRunning = false
+		app.mu.Unlock()
+	}()
 
-// Stop stops the application gracefully.
-func (app *App) Stop() error {
-	app.shutdownOnce.Do(func() {
-		close(app.shutdownChan)
+	// Create context with cancellation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		app.mu.Lock()
-		defer app.mu.Unlock()
+	// Setup signal handling
+	go app.handleSignals(cancel)
 
-		app.logger.Info("Shutting down CloudPull...")
+	if outputDir != "" {
+		outputDir = app.expandPath(outputDir)
+	}
+	if err := app.syncEngine.DownloadScannedSession(ctx, sessionID, outputDir); err != nil {
+		return errors.Wrap(err, "failed to start download")
+	}
 
-		// Stop sync engine if running
+	app.registerSessionMetrics(sessionID)
+	defer app.unregisterSessionMetrics(sessionID)
+
+	// Monitor progress
+	go app.monitorProgress(ctx)
+
+	// Wait for completion or cancellation
+	select {
+	case <-app.syncEngine.WaitForCompletion():
+		app.logger.Info("Download completed", "session_id", sessionID)
+	case <-ctx.Done():
+		app.logger.Info("Download canceled")
+		app.syncEngine.Stop()
+	}
+
+	return nil
+}
+
+// DefaultRetryMaxAttempts is the attempt limit used when retrying failed files.
+const DefaultRetryMaxAttempts = 10
+
+// GetRetryableFiles returns the failed files of a session that have fewer
+// than maxAttempts download attempts.
+func (app *App) GetRetryableFiles(ctx context.Context, sessionID string, maxAttempts int) ([]*state.File, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.Files().GetFailedFiles(ctx, sessionID, maxAttempts)
+}
+
+// GetErrors returns the error log entries of a session matching filter.
+func (app *App) GetErrors(ctx context.Context, sessionID string, filter *state.ErrorLogFilter) ([]*state.ErrorLog, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetErrors(ctx, sessionID, filter)
+}
+
+// GetPermissionDeniedFiles returns the files of a session that failed
+// because Drive refused access to them.
+func (app *App) GetPermissionDeniedFiles(ctx context.Context, sessionID string) ([]*state.File, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	entries, err := app.stateManager.GetErrors(ctx, sessionID, &state.ErrorLogFilter{
+		ItemType:  "file",
+		ErrorType: cloudsync.ErrorTypePermissionDenied,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(entries))
+	files := make([]*state.File, 0, len(entries))
+	for _, entry := range entries {
+		if seen[entry.ItemID] {
+			continue
+		}
+		seen[entry.ItemID] = true
+
+		file, err := app.stateManager.Files().Get(ctx, entry.ItemID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get file %s", entry.ItemID)
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// GetMirrorDeletions returns the local entries a mirror sync of a session
+// removed, or would have removed in a dry run.
+func (app *App) GetMirrorDeletions(ctx context.Context, sessionID string) ([]*state.MirrorDeletion, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetMirrorDeletions(ctx, sessionID)
+}
+
+// GetEvents returns the recorded file events of a session matching filter.
+func (app *App) GetEvents(ctx context.Context, sessionID string, filter *state.EventFilter) ([]*state.Event, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetEvents(ctx, sessionID, filter)
+}
+
+// PruneEvents deletes recorded events older than sync.events_retention
+// days and returns the number deleted. A retention of zero keeps them.
+func (app *App) PruneEvents(ctx context.Context) (int64, error) {
+	if app.stateManager == nil {
+		return 0, errors.Errorf("state manager not initialized")
+	}
+
+	days := app.config.Sync.EventsRetention
+	if days <= 0 {
+		return 0, nil
+	}
+	pruned, err := app.stateManager.PruneEvents(ctx, time.Duration(days)*24*time.Hour)
+	if err != nil {
+		return pruned, err
+	}
+	if pruned > 0 {
+		app.maintainAfterCleanup(ctx)
+	}
+	return pruned, nil
+}
+
+// RetrySync re-downloads the failed files of a session without walking its
+// folders again. It returns the number of files retried.
+func (app *App) RetrySync(ctx context.Context, sessionID string, maxAttempts int) (int, error) {
+	if err := app.ensureReady(ctx); err != nil {
+		return 0, err
+	}
+
+	app.mu.Lock()
+	if app.isRunning {
+		app.mu.Unlock()
+		return 0, errors.Errorf("sync already running")
+	}
+	app.isRunning = true
+	app.mu.Unlock()
+
+	defer func() {
+		app.mu.Lock()
+		app.isRunning = false
+		app.mu.Unlock()
+	}()
+
+	// Create context with cancellation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Setup signal handling
+	go app.handleSignals(cancel)
+
+	count, err := app.syncEngine.RetryFailed(ctx, sessionID, maxAttempts)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to retry session")
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	app.registerSessionMetrics(sessionID)
+	defer app.unregisterSessionMetrics(sessionID)
+
+	// Monitor progress
+	go app.monitorProgress(ctx)
+
+	// Wait for completion or cancellation
+	select {
+	case <-app.syncEngine.WaitForCompletion():
+		app.logger.Info("Retry completed", "files", count)
+	case <-ctx.Done():
+		app.logger.Info("Retry canceled")
+		app.syncEngine.Stop()
+	}
+
+	return count, nil
+}
+
+// GetSessions returns all sync sessions.
+func (app *App) GetSessions(ctx context.Context) ([]*state.Session, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	// Get up to 100 recent sessions
+	return app.stateManager.Sessions().List(ctx, 100, 0)
+}
+
+// GetLatestSession returns the most recent session.
+func (app *App) GetLatestSession(ctx context.Context) (*state.Session, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	sessions, err := app.stateManager.Sessions().List(ctx, 100, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	// Sessions are ordered by created_at DESC
+	return sessions[0], nil
+}
+
+// GetLatestResumableSession returns the most recently started session that
+// can be resumed, or nil if there is none. A session still running in this
+// or another process is refused.
+func (app *App) GetLatestResumableSession(ctx context.Context) (*state.Session, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	session, err := app.stateManager.Sessions().GetLatestResumable(ctx)
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	if app.IsSessionRunning(session.ID) {
+		return nil, errors.Errorf("session %s is currently running", session.ID)
+	}
+	if session.IsRunning() {
+		return nil, errors.Errorf("session %s is running in another process", session.ID)
+	}
+
+	return session, nil
+}
+
+// GetSession returns a session by ID.
+func (app *App) GetSession(ctx context.Context, sessionID string) (*state.Session, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetSession(ctx, sessionID)
+}
+
+// GetSessionDetail returns everything the state database holds about a
+// session, running or finished.
+func (app *App) GetSessionDetail(ctx context.Context, sessionID string) (*state.SessionDetail, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetSessionDetail(ctx, sessionID)
+}
+
+// GetTransferStats returns the throughput of a session in buckets of
+// interval, read from the files it completed.
+func (app *App) GetTransferStats(ctx context.Context, sessionID string, interval time.Duration) ([]*state.TransferStats, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.Queries().GetTransferStats(ctx, sessionID, interval)
+}
+
+// GetGlobalStats returns totals across the sessions started within r,
+// read from the state database alone.
+func (app *App) GetGlobalStats(ctx context.Context, r state.StatsRange) (*state.GlobalStats, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.Queries().GetGlobalStats(ctx, r)
+}
+
+// GetSessionConfig returns the settings stored with a session, which are
+// applied again when it is resumed.
+func (app *App) GetSessionConfig(ctx context.Context, sessionID string) (map[string]string, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.GetSessionConfig(ctx, sessionID)
+}
+
+// SetSessionConfig overrides one setting of a session for its next resume.
+func (app *App) SetSessionConfig(ctx context.Context, sessionID, key, value string) error {
+	if app.stateManager == nil {
+		return errors.Errorf("state manager not initialized")
+	}
+
+	if err := cloudsync.ValidateSessionConfig(key, value); err != nil {
+		return err
+	}
+
+	session, err := app.stateManager.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return errors.Errorf("session not found: %s", sessionID)
+	}
+
+	return app.stateManager.SetSessionConfig(ctx, sessionID, key, value)
+}
+
+// GetFolderTree returns the folders directly under parentID (nil for the
+// top level) with file counts and sizes rolled up from all descendants.
+func (app *App) GetFolderTree(ctx context.Context, sessionID string, parentID *string) ([]*state.FolderTree, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	return app.stateManager.Queries().GetFolderTreeRollup(ctx, sessionID, parentID)
+}
+
+// GetProgress returns current sync progress.
+func (app *App) GetProgress() *cloudsync.SyncProgress {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	if app.syncEngine == nil || !app.isRunning {
+		return nil
+	}
+
+	return app.syncEngine.GetProgress()
+}
+
+// SessionSnapshot is a consistent view of a session's progress.
+type SessionSnapshot struct {
+	// Session is a copy of the session record; its counters were read
+	// together
+	Session *state.Session
+
+	// Progress is the live progress of the session, nil unless it is
+	// syncing in this process
+	Progress *cloudsync.SyncProgress
+}
+
+// GetSessionSnapshot returns the progress of a session read at one moment:
+// from the sync engine while the session is syncing in this process, and
+// otherwise from a single read of its database record.
+func (app *App) GetSessionSnapshot(ctx context.Context, sessionID string) (*SessionSnapshot, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	app.mu.RLock()
+	engine := app.syncEngine
+	running := app.isRunning
+	app.mu.RUnlock()
+
+	if engine != nil && running {
+		if session, progress := engine.Snapshot(); session != nil && session.ID == sessionID {
+			return &SessionSnapshot{Session: session, Progress: progress}, nil
+		}
+	}
+
+	session, err := app.stateManager.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionSnapshot{Session: session}, nil
+}
+
+// Stop stops the application gracefully.
+func (app *App) Stop() error {
+	app.shutdownOnce.Do(func() {
+		close(app.shutdownChan)
+
+		app.StopControlServer()
+
+		app.mu.Lock()
+		defer app.mu.Unlock()
+
+		app.logger.Info("Shutting down CloudPull...")
+
+		// Stop sync engine if running
 		if app.syncEngine != nil && app.isRunning {
 			if err := app.syncEngine.Stop(); err != nil {
 				app.logger.Error(err, "Failed to stop sync engine")
 			}
 		}
 
+		// Stop metrics server
+		if app.metricsServer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := app.metricsServer.Stop(ctx); err != nil {
+				app.logger.Error(err, "Failed to stop metrics server")
+			}
+			cancel()
+		}
+
 		// Close state manager
 		if app.stateManager != nil {
 			if err := app.stateManager.Close(); err != nil {
@@ -620,6 +1383,11 @@ func (app *App) Stop() error {
 		}
 
 		app.logger.Info("CloudPull shutdown complete")
+
+		// Close rotating log file
+		if app.logCloser != nil {
+			_ = app.logCloser.Close()
+		}
 	})
 
 	return nil
@@ -649,7 +1417,39 @@ func (app *App) initializeDatabase(dbPath string) error {
 	return nil
 }
 
-func (app *App) ensureReady() error {
+// newLogOutput returns the writer configured by log.output. Any value other
+// than stdout/stderr is a file path written through a rotating writer sized
+// by log.max_size, log.max_backups, log.max_age and log.compress. The value
+// "file" selects the path configured in log.file.
+func newLogOutput(cfg *config.Config) (io.Writer, error) {
+	outputPath := cfg.GetString("log.output")
+	switch outputPath {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		outputPath = cfg.GetString("log.file")
+		if outputPath == "" {
+			return nil, errors.NewSimple("log.output is \"file\" but log.file is not set")
+		}
+	}
+
+	outputPath = expandHomePath(outputPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return nil, errors.Wrap(err, "failed to create log directory")
+	}
+
+	return &lumberjack.Logger{
+		Filename:   outputPath,
+		MaxSize:    cfg.GetInt("log.max_size"),
+		MaxBackups: cfg.GetInt("log.max_backups"),
+		MaxAge:     cfg.GetInt("log.max_age"),
+		Compress:   cfg.GetBool("log.compress"),
+	}, nil
+}
+
+func (app *App) ensureReady(ctx context.Context) error {
 	if !app.isInitialized {
 		return errors.Errorf("application not initialized")
 	}
@@ -660,6 +1460,11 @@ func (app *App) ensureReady() error {
 		}
 	}
 
+	// A revoked authorization fails here instead of deep into the sync
+	if err := app.authManager.ValidateToken(ctx); err != nil {
+		return err
+	}
+
 	if app.syncEngine == nil {
 		if err := app.InitializeSyncEngine(); err != nil {
 			return err
@@ -670,16 +1475,65 @@ func (app *App) ensureReady() error {
 }
 
 func (app *App) handleSignals(cancel context.CancelFunc) {
-	sigChan := make(chan os.Signal, 1)
-	app.setupSignalHandling(sigChan)
+	sigChan := make(chan os.Signal, 2)
+	app.NotifyStop(sigChan)
+	defer signal.Stop(sigChan)
+
+	app.runShutdown(sigChan, app.drainSyncEngine, cancel)
+}
+
+// runShutdown performs a two-phase shutdown. The first signal stops new
+// downloads and lets in-flight ones finish (bounded by sync.shutdown_timeout)
+// before canceling; a second signal cancels immediately.
+func (app *App) runShutdown(sigChan <-chan os.Signal, drain func(context.Context) error, cancel context.CancelFunc) {
+	defer cancel()
+
+	select {
+	case sig := <-sigChan:
+		app.logger.Info("Received signal, finishing in-flight downloads (signal again to abort)",
+			"signal", sig)
+	case <-app.shutdownChan:
+		return
+	}
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), app.shutdownTimeout())
+	defer drainCancel()
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- drain(drainCtx)
+	}()
 
 	select {
+	case err := <-drained:
+		if err != nil {
+			app.logger.Warn("Graceful shutdown incomplete", "error", err)
+		}
 	case sig := <-sigChan:
-		app.logger.Info("Received signal", "signal", sig)
-		cancel()
+		app.logger.Warn("Received second signal, aborting immediately", "signal", sig)
+		drainCancel()
 	case <-app.shutdownChan:
-		cancel()
+		drainCancel()
+	}
+}
+
+// drainSyncEngine drains the running sync engine, if any.
+func (app *App) drainSyncEngine(ctx context.Context) error {
+	engine := app.GetSyncEngine()
+	if engine == nil {
+		return nil
+	}
+
+	return engine.Drain(ctx)
+}
+
+// shutdownTimeout returns how long a graceful shutdown may take.
+func (app *App) shutdownTimeout() time.Duration {
+	if timeout := app.config.GetDuration("sync.shutdown_timeout"); timeout > 0 {
+		return timeout
 	}
+
+	return 30 * time.Second
 }
 
 func (app *App) monitorProgress(ctx context.Context) {
@@ -714,6 +1568,36 @@ func (app *App) monitorProgress(ctx context.Context) {
 	}
 }
 
+// registerSessionMetrics exposes a sync session on the metrics server. The
+// server starts with the first sync, so commands that do not sync never
+// bind metrics.addr; if it cannot start, syncs run without metrics.
+func (app *App) registerSessionMetrics(sessionID string) {
+	app.metricsOnce.Do(app.startMetricsServer)
+	if app.metricsServer != nil {
+		app.metricsServer.RegisterSession(sessionID, app.syncEngine)
+	}
+}
+
+// startMetricsServer starts the metrics server if metrics.enabled is set.
+func (app *App) startMetricsServer() {
+	if !app.config.GetBool("metrics.enabled") {
+		return
+	}
+
+	server := metrics.NewServer(app.config.GetString("metrics.addr"), app.logger)
+	if err := server.Start(); err != nil {
+		app.logger.Warn("Metrics server not started", "addr", app.config.GetString("metrics.addr"), "error", err)
+		return
+	}
+	app.metricsServer = server
+}
+
+func (app *App) unregisterSessionMetrics(sessionID string) {
+	if app.metricsServer != nil {
+		app.metricsServer.UnregisterSession(sessionID)
+	}
+}
+
 func (app *App) applySyncOptions(options *SyncOptions) {
 	// Apply include/exclude patterns
 	if len(options.IncludePatterns) > 0 || len(options.ExcludePatterns) > 0 {
@@ -724,6 +1608,88 @@ func (app *App) applySyncOptions(options *SyncOptions) {
 		)
 	}
 
+	// Apply directory layout
+	app.syncEngine.SetFlatten(options.Flatten)
+	if options.Flatten {
+		app.logger.Info("Flattened output enabled; Drive folders are not recreated")
+	}
+
+	// Apply dated destination subdirectory
+	if options.DestDateSubdir != "" {
+		app.syncEngine.SetDestDateSubdir(options.DestDateSubdir)
+		app.logger.Info("New sessions download into a dated subdirectory", "layout", options.DestDateSubdir)
+	}
+
+	// Apply checksum algorithm
+	if options.ChecksumAlgorithm != "" {
+		app.syncEngine.SetChecksumAlgorithm(options.ChecksumAlgorithm)
+		app.logger.Info("Checksum algorithm applied", "algorithm", options.ChecksumAlgorithm)
+	}
+
+	// Apply download quota
+	if options.MaxTotalBytes > 0 {
+		app.syncEngine.SetMaxTotalBytes(options.MaxTotalBytes)
+		app.logger.Info("Download quota applied", "limit", util.FormatBytes(options.MaxTotalBytes))
+	}
+
+	// Apply API call limit
+	if options.MaxAPICalls > 0 {
+		app.syncEngine.SetMaxAPICalls(options.MaxAPICalls)
+		app.logger.Info("API call limit applied", "limit", options.MaxAPICalls)
+	}
+
+	// Apply file limit
+	if options.MaxFiles > 0 {
+		app.syncEngine.SetMaxFiles(options.MaxFiles)
+		app.logger.Info("File limit applied", "limit", options.MaxFiles)
+	}
+
+	// Apply mirror mode
+	if options.Mirror {
+		// A trash directory given by the user may hold other things, so
+		// it is only pruned with an explicit retention
+		var trashRetention time.Duration
+		if app.config.Sync.TrashRetention != nil {
+			trashRetention = time.Duration(*app.config.Sync.TrashRetention) * 24 * time.Hour
+		} else if options.MirrorTrashDir == "" {
+			trashRetention = cloudsync.DefaultTrashRetention
+		}
+		app.syncEngine.SetMirror(&cloudsync.MirrorConfig{
+			Enabled:         true,
+			TrashDir:        app.expandPath(options.MirrorTrashDir),
+			TrashRetention:  trashRetention,
+			DryRun:          options.DryRun,
+			PermanentDelete: options.PermanentDelete,
+		})
+		app.logger.Info("Mirror mode enabled",
+			"trash_dir", options.MirrorTrashDir,
+			"permanent_delete", options.PermanentDelete,
+			"dry_run", options.DryRun,
+		)
+	} else {
+		app.syncEngine.SetMirror(nil)
+	}
+
+	// Apply permission error handling
+	app.syncEngine.SetSkipPermissionErrors(options.SkipPermissionErrors)
+	app.syncEngine.SetQuietProgress(options.QuietProgress)
+	app.syncEngine.SetEventStream(options.EventStream)
+
+	// Apply starred filter
+	app.syncEngine.SetStarredOnly(options.StarredOnly)
+	if options.StarredOnly {
+		app.logger.Info("Only starred files are synced")
+	}
+
+	// Apply Google Workspace filter
+	app.syncEngine.SetGoogleDocsFilter(options.OnlyGoogleDocs, options.SkipGoogleDocs)
+	switch {
+	case options.OnlyGoogleDocs:
+		app.logger.Info("Only Google Workspace files are synced")
+	case options.SkipGoogleDocs:
+		app.logger.Info("Google Workspace files are skipped")
+	}
+
 	// Apply bandwidth limit
 	if options.BandwidthLimit > 0 {
 		// TODO: Configure rate limiter
@@ -734,6 +1700,11 @@ func (app *App) applySyncOptions(options *SyncOptions) {
 }
 
 func (app *App) expandPath(path string) string {
+	return expandHomePath(path)
+}
+
+// expandHomePath expands a leading "~/" to the user's home directory.
+func expandHomePath(path string) string {
 	if strings.HasPrefix(path, "~/") {
 		home, _ := os.UserHomeDir()
 		path = filepath.Join(home, path[2:])
@@ -748,6 +1719,55 @@ type SyncOptions struct {
 	MaxDepth        int
 	BandwidthLimit  int64
 	DryRun          bool
+	Flatten         bool
+
+	// ChecksumAlgorithm overrides sync.checksum_algorithm when set
+	ChecksumAlgorithm cloudsync.ChecksumAlgorithm
+
+	// DestDateSubdir overrides sync.dest_date_subdir when set; it is a Go
+	// time layout
+	DestDateSubdir string
+
+	// MaxTotalBytes overrides sync.max_total_bytes when set
+	MaxTotalBytes int64
+
+	// MaxAPICalls overrides api.max_calls_per_run when set
+	MaxAPICalls int64
+
+	// MaxFiles stops the sync after this many files were scheduled for
+	// download (0 = unlimited)
+	MaxFiles int
+
+	// Mirror removes local files and folders missing from Drive after the
+	// sync; with DryRun they are only recorded. They are moved to
+	// MirrorTrashDir (default: the destination's trash) unless
+	// PermanentDelete is set
+	Mirror          bool
+	MirrorTrashDir  string
+	PermanentDelete bool
+
+	// SkipPermissionErrors keeps files Drive refuses access to from
+	// counting toward sync.max_errors
+	SkipPermissionErrors bool
+
+	// QuietProgress suppresses the periodic progress log lines
+	QuietProgress bool
+
+	// StarredOnly downloads only starred files
+	StarredOnly bool
+
+	// OnlyGoogleDocs downloads only Google Workspace files and
+	// SkipGoogleDocs skips them; at most one may be set
+	OnlyGoogleDocs bool
+	SkipGoogleDocs bool
+
+	// ResumeExisting resumes an incomplete session of the same folder and
+	// destination instead of starting a new one
+	ResumeExisting bool
+
+	// EventStream receives every progress event as a JSON line, see
+	// cloudsync.StreamRecord (nil = none)
+	EventStream io.Writer
 }
 
 // Helper functions
@@ -800,6 +1820,143 @@ func (app *App) CleanupSession(sessionID string) error {
 	return nil
 }
 
+// PruneSessions shrinks the state database. With archive set, completed
+// sessions created more than olderThan ago keep a summary and lose their
+// file and folder rows; otherwise finished sessions that old are deleted.
+// It returns the number of sessions affected.
+func (app *App) PruneSessions(ctx context.Context, olderThan time.Duration, archive bool) (int64, error) {
+	if app.stateManager == nil {
+		return 0, errors.NewSimple("state manager not initialized")
+	}
+
+	if archive {
+		archived, err := app.stateManager.ArchiveCompletedSessions(ctx, olderThan)
+		if err != nil {
+			return int64(archived), errors.Wrap(err, "failed to archive sessions")
+		}
+		if archived > 0 {
+			app.maintainAfterCleanup(ctx)
+		}
+		return int64(archived), nil
+	}
+
+	deleted, err := app.stateManager.Queries().CleanupOldSessions(ctx, olderThan)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete sessions")
+	}
+	if deleted > 0 {
+		app.maintainAfterCleanup(ctx)
+	}
+	return deleted, nil
+}
+
+// Database maintenance operations, see MaintainDatabase.
+const (
+	DatabaseOptimize = "optimize"
+	DatabaseVacuum   = "vacuum"
+)
+
+// DatabaseMaintenance reports the state database before and after
+// maintenance.
+type DatabaseMaintenance struct {
+	Before *state.SpaceStats
+	After  *state.SpaceStats
+}
+
+// MaintainDatabase runs PRAGMA optimize on the state database or, with
+// DatabaseVacuum, rebuilds it first to shrink the file. VACUUM locks the
+// database, so it is refused while a session is active here or in another
+// process.
+func (app *App) MaintainDatabase(ctx context.Context, operation string) (*DatabaseMaintenance, error) {
+	if app.stateManager == nil {
+		return nil, errors.NewSimple("state manager not initialized")
+	}
+	if operation != DatabaseOptimize && operation != DatabaseVacuum {
+		return nil, errors.Errorf("unknown database maintenance %q", operation)
+	}
+
+	app.mu.RLock()
+	running := app.isRunning
+	app.mu.RUnlock()
+	if running {
+		return nil, errors.NewSimple("a sync is running; run database maintenance after it finishes")
+	}
+
+	active, err := app.stateManager.Sessions().GetActive(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check for active sessions")
+	}
+	if len(active) > 0 {
+		return nil, errors.Errorf("session %s is active; wait for it to finish, "+
+			"or run cloudpull cleanup if it is no longer running", active[0].ID)
+	}
+
+	result := &DatabaseMaintenance{}
+	if result.Before, err = app.stateManager.SpaceStats(ctx); err != nil {
+		return nil, err
+	}
+	if operation == DatabaseVacuum {
+		if err := app.stateManager.Vacuum(ctx); err != nil {
+			return nil, errors.Wrap(err, "failed to vacuum database")
+		}
+	}
+	if err := app.stateManager.Optimize(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to optimize database")
+	}
+	if result.After, err = app.stateManager.SpaceStats(ctx); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// maintainAfterCleanup runs the maintenance set by database.after_cleanup
+// once a cleanup deleted rows. Failures are logged, since the cleanup
+// itself succeeded.
+func (app *App) maintainAfterCleanup(ctx context.Context) {
+	operation := strings.ToLower(app.config.Database.AfterCleanup)
+	if operation == "" || operation == "off" {
+		return
+	}
+
+	result, err := app.MaintainDatabase(ctx, operation)
+	if err != nil {
+		app.logger.Warn("Skipped database maintenance after cleanup", "operation", operation, "error", err)
+		return
+	}
+	app.logger.Info("Database maintenance finished",
+		"operation", operation,
+		"size_before", result.Before.Size(),
+		"size_after", result.After.Size(),
+	)
+}
+
+// CheckIntegrity looks for inconsistent state database rows of a session
+// and, with repair set, fixes them. A session that this or another process
+// is running is not repaired.
+func (app *App) CheckIntegrity(ctx context.Context, sessionID string, repair bool) (*state.IntegrityReport, error) {
+	if app.stateManager == nil {
+		return nil, errors.NewSimple("state manager not initialized")
+	}
+
+	tempDir := app.config.GetString("sync.temp_dir")
+	report, err := app.stateManager.CheckIntegrity(ctx, sessionID, &state.IntegrityOptions{
+		TempPath: func(file *state.File) string { return cloudsync.DownloadTempPath(tempDir, file) },
+		Repair:   repair,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "integrity check failed")
+	}
+	return report, nil
+}
+
+// GetConfig returns the loaded configuration.
+func (app *App) GetConfig() *config.Config {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+	return app.config
+}
+
 // GetSyncEngine returns the sync engine.
 func (app *App) GetSyncEngine() *cloudsync.Engine {
 	app.mu.RLock()
@@ -1,11 +1,15 @@
 package app
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
@@ -13,8 +17,11 @@ import (
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/VatsalSy/CloudPull/internal/config"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
 )
 
 func TestAppInitialization(t *testing.T) {
@@ -71,6 +78,36 @@ func TestAppAuthInitialization(t *testing.T) {
 	assert.NotNil(t, app.apiClient)
 }
 
+func TestAuthenticatedClientReauthorizesRevokedTokens(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+
+	credentials := filepath.Join(dir, "credentials.json")
+	require.NoError(t, os.WriteFile(credentials, []byte(`{"installed":{"client_id":"client",`+
+		`"client_secret":"secret","auth_uri":"https://accounts.google.com/o/oauth2/auth",`+
+		`"token_uri":"https://oauth2.googleapis.com/token","redirect_uris":["http://localhost"]}}`), 0600))
+
+	token := filepath.Join(dir, "token.json")
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	require.NoError(t, os.WriteFile(token, []byte(`{"access_token":"access","token_type":"Bearer",`+
+		`"refresh_token":"refresh","expiry":"`+expiry+`"}`), 0600))
+
+	v := setupTestConfig(t)
+	v.Set("credentials_file", credentials)
+	v.Set("token_file", token)
+
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	require.NoError(t, app.InitializeAuth())
+	require.NotNil(t, app.apiClient)
+	assert.True(t, app.apiClient.HasReauthHandler())
+}
+
 func TestAppSyncEngineInitialization(t *testing.T) {
 	// Skip if no credentials
 	credFile := os.Getenv("CLOUDPULL_TEST_CREDENTIALS")
@@ -101,6 +138,71 @@ func TestAppSyncEngineInitialization(t *testing.T) {
 	assert.NotNil(t, app.syncEngine)
 }
 
+func TestEngineConfigUsesSyncSettings(t *testing.T) {
+	v := setupTestConfig(t)
+	v.Set("sync.walker_concurrent", 7)
+	v.Set("sync.queue_size", 250)
+	v.Set("sync.batch_size", 40)
+	v.Set("sync.write_report", true)
+	v.Set("sync.scan_then_download", true)
+	v.Set("sync.refresh_modified", true)
+	v.Set("sync.persist_events", true)
+	v.Set("sync.no_progress_timeout", 120)
+	v.Set("sync.max_consecutive_errors", 5)
+	v.Set("sync.continue_on_errors", true)
+	v.Set("sync.dest_date_subdir", "%Y-%m-%d")
+	v.Set("sync.schedule_order", "largest_first")
+	v.Set("sync.conflict_policy", "newer_wins")
+	v.Set("sync.cas_mode", "hardlink")
+	v.Set("sync.global_bandwidth_limit", "2MB/s")
+	v.Set("files.export_formats", map[string][]string{"document": {"pdf", "docx"}})
+	v.Set("files.sheets_export_mode", "csv")
+	v.Set("files.file_mode", "0600")
+	v.Set("files.dir_mode", "0700")
+	v.Set("files.skip_hidden", true)
+	v.Set("files.follow_shortcuts", true)
+	v.Set("api.max_calls_per_run", 500)
+
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+
+	engineConfig, err := app.engineConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, 7, engineConfig.WalkerConfig.Concurrency)
+	assert.Equal(t, 7, engineConfig.WalkerConfig.MaxConcurrentFolders)
+	assert.Equal(t, 250, engineConfig.WalkerConfig.ChannelBufferSize)
+	assert.Equal(t, 40, engineConfig.BatchSize)
+	assert.True(t, engineConfig.WriteReport)
+	assert.True(t, engineConfig.ScanThenDownload)
+	assert.True(t, engineConfig.RefreshModified)
+	assert.True(t, engineConfig.PersistEvents)
+	assert.Equal(t, 2*time.Minute, engineConfig.NoProgressTimeout)
+	assert.Equal(t, 5, engineConfig.MaxConsecutiveErrors)
+	assert.True(t, engineConfig.ContinueOnErrors)
+	assert.Equal(t, int64(500), engineConfig.MaxAPICalls)
+	assert.Equal(t, "2006-01-02", engineConfig.DestDateSubdir)
+	assert.Equal(t, cloudsync.ScheduleLargestFirst, engineConfig.DownloadConfig.ScheduleOrder)
+	assert.Equal(t, cloudsync.ConflictNewerWins, engineConfig.DownloadConfig.ConflictPolicy)
+	assert.Equal(t, cloudsync.CASHardlink, engineConfig.DownloadConfig.CASMode)
+	assert.Equal(t, os.FileMode(0600), engineConfig.DownloadConfig.FileMode)
+	assert.Equal(t, os.FileMode(0700), engineConfig.DownloadConfig.DirMode)
+	assert.True(t, engineConfig.WalkerConfig.SkipHidden)
+	assert.True(t, engineConfig.WalkerConfig.FollowShortcuts)
+	assert.Same(t, sharedBandwidth, engineConfig.DownloadConfig.SharedBandwidth)
+	assert.Equal(t, int64(2*1024*1024), sharedBandwidth.Limit())
+	t.Cleanup(func() { sharedBandwidth.SetLimit(0) })
+
+	exportFormats := engineConfig.DownloadConfig.ExportFormats["application/vnd.google-apps.document"]
+	require.Len(t, exportFormats, 2)
+	assert.Equal(t, "application/pdf", exportFormats[0])
+	assert.Equal(t, []string{"text/csv"}, engineConfig.DownloadConfig.ExportFormats["application/vnd.google-apps.spreadsheet"])
+	assert.Equal(t, engineConfig.DownloadConfig.ExportFormats, engineConfig.WalkerConfig.ExportFormats)
+}
+
 func TestAppShutdown(t *testing.T) {
 	v := setupTestConfig(t)
 
@@ -183,6 +285,200 @@ func TestAppSignalHandling(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func newShutdownTestApp(t *testing.T) *App {
+	t.Helper()
+
+	v := setupTestConfig(t)
+	v.Set("sync.shutdown_timeout", 5)
+
+	configLoader := func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}
+
+	app, err := New(WithConfigLoader(configLoader))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	t.Cleanup(func() { app.Stop() })
+
+	return app
+}
+
+func TestShutdownSingleSignalDrainsBeforeCancel(t *testing.T) {
+	app := newShutdownTestApp(t)
+
+	sigChan := make(chan os.Signal, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var checkpointed atomic.Bool
+	drain := func(drainCtx context.Context) error {
+		// In-flight downloads finish, then the checkpoint is written
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-drainCtx.Done():
+			return drainCtx.Err()
+		}
+		assert.NoError(t, ctx.Err(), "context canceled before drain finished")
+		checkpointed.Store(true)
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.runShutdown(sigChan, drain, cancel)
+	}()
+
+	sigChan <- syscall.SIGTERM
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown did not finish")
+	}
+
+	assert.True(t, checkpointed.Load())
+	assert.Error(t, ctx.Err())
+}
+
+func TestShutdownSecondSignalAbortsImmediately(t *testing.T) {
+	app := newShutdownTestApp(t)
+
+	sigChan := make(chan os.Signal, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	drainStarted := make(chan struct{})
+	drainAborted := make(chan struct{})
+	drain := func(drainCtx context.Context) error {
+		// Downloads that never finish on their own
+		close(drainStarted)
+		<-drainCtx.Done()
+		close(drainAborted)
+		return drainCtx.Err()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.runShutdown(sigChan, drain, cancel)
+	}()
+
+	sigChan <- syscall.SIGTERM
+	<-drainStarted
+
+	start := time.Now()
+	sigChan <- syscall.SIGTERM
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second signal did not abort shutdown")
+	}
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Error(t, ctx.Err())
+
+	select {
+	case <-drainAborted:
+	case <-time.After(time.Second):
+		t.Fatal("drain was not interrupted")
+	}
+}
+
+func TestLogOutputRotation(t *testing.T) {
+	v := setupTestConfig(t)
+	logPath := filepath.Join(t.TempDir(), "logs", "cloudpull.log")
+	v.Set("log.output", logPath)
+	v.Set("log.max_size", 25)
+	v.Set("log.max_backups", 4)
+	v.Set("log.max_age", 14)
+	v.Set("log.compress", true)
+
+	cfg, err := config.LoadFromViper(v)
+	require.NoError(t, err)
+
+	output, err := newLogOutput(cfg)
+	require.NoError(t, err)
+
+	rotating, ok := output.(*lumberjack.Logger)
+	require.True(t, ok, "file output should use a rotating writer")
+	defer rotating.Close()
+
+	assert.Equal(t, logPath, rotating.Filename)
+	assert.Equal(t, 25, rotating.MaxSize)
+	assert.Equal(t, 4, rotating.MaxBackups)
+	assert.Equal(t, 14, rotating.MaxAge)
+	assert.True(t, rotating.Compress)
+
+	// stdout keeps writing directly to the terminal
+	v.Set("log.output", "stdout")
+	cfg, err = config.LoadFromViper(v)
+	require.NoError(t, err)
+	output, err = newLogOutput(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, os.Stdout, output)
+}
+
+func TestLogWriterWrapsConfiguredOutput(t *testing.T) {
+	v := setupTestConfig(t)
+	v.Set("log.output", "stdout")
+
+	var wrapped io.Writer
+	var buf bytes.Buffer
+	app, err := New(
+		WithConfigLoader(func() (*config.Config, error) { return config.LoadFromViper(v) }),
+		WithLogWriter(func(w io.Writer) io.Writer {
+			wrapped = w
+			return &buf
+		}),
+	)
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	assert.Equal(t, os.Stdout, wrapped)
+	assert.Contains(t, buf.String(), "Initializing CloudPull")
+}
+
+func TestLogCloserOnlyClosesLogFile(t *testing.T) {
+	v := setupTestConfig(t)
+	v.Set("log.output", "stdout")
+	app, err := New(WithConfigLoader(func() (*config.Config, error) { return config.LoadFromViper(v) }))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	assert.Nil(t, app.logCloser, "stdout must not be closed on shutdown")
+	require.NoError(t, app.Stop())
+
+	v = setupTestConfig(t)
+	v.Set("log.output", filepath.Join(t.TempDir(), "cloudpull.log"))
+	app, err = New(WithConfigLoader(func() (*config.Config, error) { return config.LoadFromViper(v) }))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	assert.IsType(t, &lumberjack.Logger{}, app.logCloser)
+	require.NoError(t, app.Stop())
+}
+
+func TestMetricsServerStartsWithFirstSync(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer busy.Close()
+
+	v := setupTestConfig(t)
+	v.Set("metrics.enabled", true)
+	v.Set("metrics.addr", busy.Addr().String())
+	app, err := New(WithConfigLoader(func() (*config.Config, error) { return config.LoadFromViper(v) }))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+	assert.Nil(t, app.metricsServer, "commands that do not sync must not bind metrics.addr")
+
+	// An address in use leaves the sync without metrics
+	app.registerSessionMetrics("session")
+	app.unregisterSessionMetrics("session")
+	assert.Nil(t, app.metricsServer)
+}
+
 func TestSyncOptions(t *testing.T) {
 	options := &SyncOptions{
 		IncludePatterns: []string{"*.pdf", "*.doc"},
@@ -242,3 +538,91 @@ func setupTestConfig(t *testing.T) *viper.Viper {
 
 	return v
 }
+
+func TestMaintainDatabaseRefusedWhileSessionActive(t *testing.T) {
+	// Sessions left active by other tests must not be seen
+	t.Setenv("HOME", t.TempDir())
+	v := setupTestConfig(t)
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	ctx := context.Background()
+	session, err := app.stateManager.CreateSession(ctx, "root-id", "root", t.TempDir())
+	require.NoError(t, err)
+
+	_, err = app.MaintainDatabase(ctx, DatabaseVacuum)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), session.ID)
+
+	require.NoError(t, app.stateManager.UpdateSessionStatus(ctx, session.ID, state.SessionStatusCompleted))
+	result, err := app.MaintainDatabase(ctx, DatabaseVacuum)
+	require.NoError(t, err)
+	assert.Positive(t, result.After.PageCount)
+
+	_, err = app.MaintainDatabase(ctx, "reindex")
+	assert.Error(t, err)
+}
+
+func TestFindResumableSessionLooksInDatedSubdirectory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v := setupTestConfig(t)
+	v.Set("sync.dest_date_subdir", "%Y-%m-%d")
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	ctx := context.Background()
+	dest := t.TempDir()
+	session, err := app.stateManager.CreateSession(ctx, "root-id", "root",
+		filepath.Join(dest, time.Now().Format("2006-01-02")))
+	require.NoError(t, err)
+	require.NoError(t, app.stateManager.UpdateSessionStatus(ctx, session.ID, state.SessionStatusFailed))
+
+	found, err := app.FindResumableSession(ctx, "root-id", dest, "")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, session.ID, found.ID)
+
+	// A layout override looks in its own subdirectory
+	found, err = app.FindResumableSession(ctx, "root-id", dest, "2006")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestResumableSessionRunningElsewhereIsRefused(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	v := setupTestConfig(t)
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	ctx := context.Background()
+	dest := t.TempDir()
+	session, err := app.stateManager.CreateSession(ctx, "root-id", "root", dest)
+	require.NoError(t, err)
+	require.NoError(t, app.stateManager.UpdateSessionStatus(ctx, session.ID, state.SessionStatusFailed))
+
+	latest, err := app.GetLatestResumableSession(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+
+	// Another process records heartbeats while it runs the session
+	require.NoError(t, app.stateManager.Sessions().Heartbeat(ctx, session.ID))
+
+	_, err = app.GetLatestResumableSession(ctx)
+	assert.ErrorContains(t, err, "running in another process")
+
+	found, err := app.FindResumableSession(ctx, "root-id", dest, "")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
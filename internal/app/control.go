@@ -0,0 +1,259 @@
+/**
+ * Control Socket for CloudPull
+ *
+ * Features:
+ * - Local Unix socket for controlling a running sync
+ * - pause, resume, status and stop commands
+ * - Line-based requests with JSON replies
+ * - Stale socket cleanup and clean shutdown
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+)
+
+// Control commands accepted on the control socket.
+const (
+	ControlPause  = "pause"
+	ControlResume = "resume"
+	ControlStatus = "status"
+	ControlStop   = "stop"
+)
+
+// controlTimeout bounds how long a single control connection may take.
+const controlTimeout = 5 * time.Second
+
+// ControlResponse is the reply written for every control command.
+type ControlResponse struct {
+	Progress *cloudsync.SyncProgress `json:"progress,omitempty"`
+	Message  string                  `json:"message,omitempty"`
+	Error    string                  `json:"error,omitempty"`
+	OK       bool                    `json:"ok"`
+}
+
+// controlStop is delivered to the stop channel when a stop command arrives,
+// so it is handled like SIGINT or SIGTERM.
+type controlStop struct{}
+
+func (controlStop) String() string { return "control stop" }
+func (controlStop) Signal()        {}
+
+// NotifyStop relays SIGINT, SIGTERM and control socket stop commands to
+// sigChan.
+func (app *App) NotifyStop(sigChan chan os.Signal) {
+	app.setupSignalHandling(sigChan)
+
+	app.controlMu.Lock()
+	app.stopChan = sigChan
+	app.controlMu.Unlock()
+}
+
+// StartControlServer listens for control commands on a Unix socket at path.
+// A socket file left behind by a process that is gone is replaced.
+func (app *App) StartControlServer(path string) error {
+	app.controlMu.Lock()
+	defer app.controlMu.Unlock()
+
+	if app.controlListener != nil {
+		return errors.Errorf("control server already listening on %s", app.controlPath)
+	}
+
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return errors.Wrap(err, "failed to listen on control socket")
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return errors.Wrap(err, "failed to restrict control socket permissions")
+	}
+
+	app.controlListener = listener
+	app.controlPath = path
+
+	app.controlWG.Add(1)
+	go app.serveControl(listener)
+
+	if app.logger != nil {
+		app.logger.Info("Control socket listening", "path", path)
+	}
+
+	return nil
+}
+
+// StopControlServer closes the control socket, waits for open connections
+// and removes the socket file. It is a no-op when no server is running.
+func (app *App) StopControlServer() {
+	app.controlMu.Lock()
+	listener := app.controlListener
+	path := app.controlPath
+	app.controlListener = nil
+	app.controlPath = ""
+	app.controlMu.Unlock()
+
+	if listener == nil {
+		return
+	}
+
+	listener.Close()
+	app.controlWG.Wait()
+	os.Remove(path)
+}
+
+// serveControl accepts control connections until the listener is closed.
+func (app *App) serveControl(listener net.Listener) {
+	defer app.controlWG.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		app.controlWG.Add(1)
+		go func() {
+			defer app.controlWG.Done()
+			app.handleControlConn(conn)
+		}()
+	}
+}
+
+// handleControlConn reads one command and writes its reply.
+func (app *App) handleControlConn(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+
+	response := app.runControlCommand(strings.TrimSpace(line))
+	_ = json.NewEncoder(conn).Encode(response)
+}
+
+// runControlCommand executes a control command against the running sync.
+func (app *App) runControlCommand(command string) *ControlResponse {
+	switch command {
+	case ControlStatus:
+		progress := app.GetProgress()
+		if progress == nil {
+			return &ControlResponse{OK: true, Message: "no sync is running"}
+		}
+		return &ControlResponse{OK: true, Progress: progress}
+
+	case ControlPause, ControlResume:
+		engine := app.GetSyncEngine()
+		if engine == nil {
+			return controlError(errors.Errorf("no sync is running"))
+		}
+
+		var err error
+		if command == ControlPause {
+			err = engine.Pause()
+		} else {
+			err = engine.Resume()
+		}
+		if err != nil {
+			return controlError(err)
+		}
+		return &ControlResponse{OK: true, Message: "sync " + command + "d"}
+
+	case ControlStop:
+		if err := app.requestStop(); err != nil {
+			return controlError(err)
+		}
+		return &ControlResponse{OK: true, Message: "stopping sync"}
+
+	default:
+		return controlError(errors.Errorf("unknown control command %q", command))
+	}
+}
+
+// requestStop delivers a stop request to the channel registered with
+// NotifyStop.
+func (app *App) requestStop() error {
+	app.controlMu.Lock()
+	stopChan := app.stopChan
+	app.controlMu.Unlock()
+
+	if stopChan == nil {
+		return errors.Errorf("no sync is running")
+	}
+
+	select {
+	case stopChan <- controlStop{}:
+		return nil
+	default:
+		return errors.Errorf("a stop is already in progress")
+	}
+}
+
+func controlError(err error) *ControlResponse {
+	return &ControlResponse{Error: err.Error()}
+}
+
+// removeStaleSocket removes a socket file nobody is listening on.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect control socket path")
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return errors.Errorf("control socket path %s exists and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return errors.Errorf("control socket %s is in use by another process", path)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return errors.Wrap(err, "failed to remove stale control socket")
+	}
+	return nil
+}
+
+// SendControlCommand sends command to the control socket at path and
+// returns the reply. A reply reporting a failure is returned as an error.
+func SendControlCommand(path, command string) (*ControlResponse, error) {
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to control socket")
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if _, err := conn.Write([]byte(command + "\n")); err != nil {
+		return nil, errors.Wrap(err, "failed to send control command")
+	}
+
+	var response ControlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, errors.Wrap(err, "failed to read control reply")
+	}
+	if !response.OK {
+		return &response, errors.Errorf("%s", response.Error)
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,86 @@
+package app
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startTestControlServer(t *testing.T) (*App, string) {
+	t.Helper()
+
+	app := newShutdownTestApp(t)
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+	require.NoError(t, app.StartControlServer(path))
+	t.Cleanup(app.StopControlServer)
+
+	return app, path
+}
+
+func TestControlStatusWithoutSync(t *testing.T) {
+	_, path := startTestControlServer(t)
+
+	response, err := SendControlCommand(path, ControlStatus)
+	require.NoError(t, err)
+	assert.True(t, response.OK)
+	assert.Nil(t, response.Progress)
+	assert.Equal(t, "no sync is running", response.Message)
+}
+
+func TestControlRejectsUnknownAndUnavailableCommands(t *testing.T) {
+	_, path := startTestControlServer(t)
+
+	_, err := SendControlCommand(path, "reboot")
+	assert.ErrorContains(t, err, "unknown control command")
+
+	_, err = SendControlCommand(path, ControlPause)
+	assert.ErrorContains(t, err, "no sync is running")
+
+	_, err = SendControlCommand(path, ControlStop)
+	assert.ErrorContains(t, err, "no sync is running")
+}
+
+func TestControlStopReachesStopChannel(t *testing.T) {
+	app, path := startTestControlServer(t)
+
+	sigChan := make(chan os.Signal, 1)
+	app.NotifyStop(sigChan)
+
+	response, err := SendControlCommand(path, ControlStop)
+	require.NoError(t, err)
+	assert.Equal(t, "stopping sync", response.Message)
+
+	select {
+	case sig := <-sigChan:
+		assert.Equal(t, "control stop", sig.String())
+	case <-time.After(time.Second):
+		t.Fatal("stop request was not delivered")
+	}
+}
+
+func TestControlServerShutdownRemovesSocket(t *testing.T) {
+	app := newShutdownTestApp(t)
+	path := filepath.Join(t.TempDir(), "ctl.sock")
+
+	// A socket file left by a crashed process is replaced
+	stale, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	require.FileExists(t, path)
+
+	require.NoError(t, app.StartControlServer(path))
+	_, err = SendControlCommand(path, ControlStatus)
+	require.NoError(t, err)
+
+	app.StopControlServer()
+	assert.NoFileExists(t, path)
+
+	_, err = SendControlCommand(path, ControlStatus)
+	assert.Error(t, err)
+}
@@ -0,0 +1,158 @@
+/**
+ * Duplicate File Cleanup for CloudPull
+ *
+ * Features:
+ * - Content-based duplicate detection for a session
+ * - Removal of redundant local copies
+ * - Hard links in place of redundant copies
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package app
+
+import (
+	"context"
+	"os"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+)
+
+// DedupeAction selects what happens to redundant copies of a file.
+type DedupeAction int
+
+const (
+	// DedupeReport leaves files untouched.
+	DedupeReport DedupeAction = iota
+
+	// DedupeDelete removes every copy but the first.
+	DedupeDelete
+
+	// DedupeHardlink replaces every copy but the first with a hard link.
+	DedupeHardlink
+)
+
+// DuplicateCopy is a local copy of a duplicated file.
+type DuplicateCopy struct {
+	File      *state.File
+	LocalPath string
+	Kept      bool
+	Missing   bool
+	Err       error
+}
+
+// DuplicateSet is a group of identical files and what happened to each
+// local copy. The first copy is the one kept.
+type DuplicateSet struct {
+	Group  *state.DuplicateGroup
+	Copies []*DuplicateCopy
+}
+
+// DedupeResult summarizes a dedupe run.
+type DedupeResult struct {
+	Sets             []*DuplicateSet
+	ReclaimableBytes int64
+	ReclaimedBytes   int64
+	Processed        int
+	Failed           int
+}
+
+// DedupeSession finds files of a session with identical content and
+// applies action to the redundant local copies. Database records are left
+// unchanged, so the files still count as downloaded.
+func (app *App) DedupeSession(ctx context.Context, sessionID string, action DedupeAction) (*DedupeResult, error) {
+	if app.stateManager == nil {
+		return nil, errors.Errorf("state manager not initialized")
+	}
+
+	session, err := app.stateManager.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := app.stateManager.Queries().FindDuplicateGroups(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DedupeResult{ReclaimableBytes: report.ReclaimableBytes}
+	for _, group := range report.Groups {
+		set := &DuplicateSet{Group: group}
+		result.Sets = append(result.Sets, set)
+
+		var keep string
+		for _, file := range group.Files {
+			dup := &DuplicateCopy{File: file, LocalPath: cloudsync.LocalFilePath(session, file)}
+			set.Copies = append(set.Copies, dup)
+
+			if _, err := os.Stat(dup.LocalPath); err != nil {
+				dup.Missing = true
+				continue
+			}
+
+			// The first copy found on disk is kept
+			if keep == "" {
+				keep = dup.LocalPath
+				dup.Kept = true
+				continue
+			}
+
+			if action == DedupeReport {
+				continue
+			}
+
+			changed, err := dedupeCopy(action, keep, dup.LocalPath)
+			if err != nil {
+				dup.Err = err
+				result.Failed++
+				app.logger.Error(err, "Failed to dedupe file", "path", dup.LocalPath, "kept", keep)
+				continue
+			}
+			if changed {
+				result.Processed++
+				result.ReclaimedBytes += group.Size
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// dedupeCopy removes path or replaces it with a hard link to keep. It
+// reports whether path was changed; copies already linked to keep are left
+// alone when linking.
+func dedupeCopy(action DedupeAction, keep, path string) (bool, error) {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return false, err
+	}
+
+	if action == DedupeDelete {
+		if err := os.Remove(path); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if os.SameFile(keepInfo, pathInfo) {
+		return false, nil
+	}
+
+	// Link under a temporary name first so path is never left missing
+	tmp := path + ".cloudpull-link"
+	if err := os.Link(keep, tmp); err != nil {
+		return false, errors.Wrap(err, "failed to create hard link")
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return false, errors.Wrap(err, "failed to replace file with hard link")
+	}
+	return true, nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/config"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// newDedupeTestSession creates a completed session whose destination holds
+// three copies of the same content and one unrelated file.
+func newDedupeTestSession(t *testing.T) (*App, *state.Session) {
+	t.Helper()
+
+	v := setupTestConfig(t)
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	t.Cleanup(func() { app.Stop() })
+
+	ctx := context.Background()
+	dest := t.TempDir()
+	session, err := app.stateManager.CreateSession(ctx, "root-id", "root", dest)
+	require.NoError(t, err)
+
+	folder := &state.Folder{DriveID: "root-id", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, app.stateManager.CreateFolder(ctx, folder))
+
+	contents := map[string]string{
+		"a.txt":      "same content",
+		"b.txt":      "same content",
+		"sub/c.txt":  "same content",
+		"unique.txt": "different",
+	}
+	var files []*state.File
+	for name, content := range contents {
+		path := filepath.Join(dest, "root", name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		checksum := "same"
+		if name == "unique.txt" {
+			checksum = "unique"
+		}
+		files = append(files, &state.File{
+			DriveID:     "drive-" + name,
+			FolderID:    folder.ID,
+			SessionID:   session.ID,
+			Name:        filepath.Base(name),
+			Path:        "root/" + name,
+			Size:        int64(len(content)),
+			MD5Checksum: state.NewNullString(checksum),
+			Status:      state.FileStatusCompleted,
+		})
+	}
+	require.NoError(t, app.stateManager.Files().CreateBatch(ctx, files))
+
+	return app, session
+}
+
+func TestDedupeSessionReportLeavesFiles(t *testing.T) {
+	app, session := newDedupeTestSession(t)
+
+	result, err := app.DedupeSession(context.Background(), session.ID, DedupeReport)
+	require.NoError(t, err)
+	require.Len(t, result.Sets, 1)
+	assert.Len(t, result.Sets[0].Copies, 3)
+	assert.Equal(t, int64(2*len("same content")), result.ReclaimableBytes)
+	assert.Zero(t, result.Processed)
+
+	for _, dup := range result.Sets[0].Copies {
+		assert.FileExists(t, dup.LocalPath)
+	}
+}
+
+func TestDedupeSessionDeletesExtraCopies(t *testing.T) {
+	app, session := newDedupeTestSession(t)
+
+	result, err := app.DedupeSession(context.Background(), session.ID, DedupeDelete)
+	require.NoError(t, err)
+	require.Len(t, result.Sets, 1)
+	assert.Equal(t, 2, result.Processed)
+	assert.Equal(t, result.ReclaimableBytes, result.ReclaimedBytes)
+
+	copies := result.Sets[0].Copies
+	assert.True(t, copies[0].Kept)
+	assert.FileExists(t, copies[0].LocalPath)
+	for _, dup := range copies[1:] {
+		assert.NoFileExists(t, dup.LocalPath)
+	}
+	assert.FileExists(t, filepath.Join(session.DestinationPath, "root", "unique.txt"))
+
+	// Running again finds the removed copies missing and changes nothing
+	result, err = app.DedupeSession(context.Background(), session.ID, DedupeDelete)
+	require.NoError(t, err)
+	assert.Zero(t, result.Processed)
+	assert.True(t, result.Sets[0].Copies[1].Missing)
+}
+
+func TestDedupeSessionHardlinksExtraCopies(t *testing.T) {
+	app, session := newDedupeTestSession(t)
+
+	result, err := app.DedupeSession(context.Background(), session.ID, DedupeHardlink)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Processed)
+
+	copies := result.Sets[0].Copies
+	kept, err := os.Stat(copies[0].LocalPath)
+	require.NoError(t, err)
+	for _, dup := range copies[1:] {
+		info, err := os.Stat(dup.LocalPath)
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(kept, info), dup.LocalPath)
+		assert.NoFileExists(t, dup.LocalPath+".cloudpull-link")
+	}
+
+	// Copies already linked are left alone
+	result, err = app.DedupeSession(context.Background(), session.ID, DedupeHardlink)
+	require.NoError(t, err)
+	assert.Zero(t, result.Processed)
+}
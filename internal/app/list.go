@@ -0,0 +1,65 @@
+/**
+ * Remote Folder Preview for CloudPull
+ *
+ * Features:
+ * - Lists a Drive folder without starting a session
+ * - Applies the sync filters so they can be checked before a run
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package app
+
+import (
+	"context"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+)
+
+// ListOptions contains options for listing a remote folder.
+type ListOptions struct {
+	IncludePatterns []string
+	ExcludePatterns []string
+	Recursive       bool
+
+	// MaxDepth overrides sync.max_depth when non-zero; -1 is unlimited
+	MaxDepth int
+}
+
+// ListRemote lists a Drive folder the way a sync would see it, without
+// recording anything in the state database.
+func (app *App) ListRemote(ctx context.Context, folderID string, options *ListOptions) (*cloudsync.RemoteListing, error) {
+	if app.apiClient == nil {
+		return nil, errors.Errorf("API client not initialized")
+	}
+	if options == nil {
+		options = &ListOptions{}
+	}
+
+	walkerConfig, err := app.listWalkerConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return cloudsync.ListRemote(ctx, app.apiClient, app.logger, folderID, options.Recursive, walkerConfig)
+}
+
+// listWalkerConfig returns the walker settings of a sync with the listing's
+// own patterns and depth applied on top.
+func (app *App) listWalkerConfig(options *ListOptions) (*cloudsync.WalkerConfig, error) {
+	exportFormats, err := app.exportFormats()
+	if err != nil {
+		return nil, err
+	}
+
+	walkerConfig := app.walkerConfig(exportFormats)
+	walkerConfig.IncludePatterns = options.IncludePatterns
+	walkerConfig.ExcludePatterns = options.ExcludePatterns
+	if options.MaxDepth != 0 {
+		walkerConfig.MaxDepth = options.MaxDepth
+	}
+
+	return walkerConfig, nil
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/config"
+)
+
+func TestListWalkerConfigMatchesSync(t *testing.T) {
+	v := setupTestConfig(t)
+	v.Set("sync.max_depth", 3)
+	v.Set("files.skip_hidden", true)
+	v.Set("files.respect_ignore_files", true)
+	v.Set("files.follow_shortcuts", true)
+	v.Set("files.export_formats", map[string][]string{"document": {"pdf"}})
+
+	app, err := New(WithConfigLoader(func() (*config.Config, error) {
+		return config.LoadFromViper(v)
+	}))
+	require.NoError(t, err)
+	require.NoError(t, app.Initialize())
+	defer app.Stop()
+
+	engineConfig, err := app.engineConfig()
+	require.NoError(t, err)
+
+	walkerConfig, err := app.listWalkerConfig(&ListOptions{ExcludePatterns: []string{"*.tmp"}})
+	require.NoError(t, err)
+
+	assert.True(t, walkerConfig.SkipHidden)
+	assert.True(t, walkerConfig.RespectIgnoreFiles)
+	assert.True(t, walkerConfig.FollowShortcuts)
+	assert.Equal(t, 3, walkerConfig.MaxDepth)
+	assert.Equal(t, engineConfig.WalkerConfig.ExportFormats, walkerConfig.ExportFormats)
+	assert.Equal(t, []string{"*.tmp"}, walkerConfig.ExcludePatterns)
+
+	walkerConfig, err = app.listWalkerConfig(&ListOptions{MaxDepth: -1})
+	require.NoError(t, err)
+	assert.Equal(t, -1, walkerConfig.MaxDepth)
+}
@@ -2,14 +2,19 @@ package config
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
-
+	"text/template"
 	"time"
 
 	"github.com/spf13/viper"
+
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 var (
@@ -19,16 +24,37 @@ var (
 
 // Config represents the application configuration.
 type Config struct {
-	viper           *viper.Viper
-	CredentialsFile string      `mapstructure:"credentials_file"`
-	TokenFile       string      `mapstructure:"token_file"`
-	Version         string      `mapstructure:"version"`
-	Files           FileConfig  `mapstructure:"files"`
-	Cache           CacheConfig `mapstructure:"cache"`
-	Log             LogConfig   `mapstructure:"log"`
-	Sync            SyncConfig  `mapstructure:"sync"`
-	API             APIConfig   `mapstructure:"api"`
-	Errors          ErrorConfig `mapstructure:"errors"`
+	viper            *viper.Viper
+	profileOverrides map[string]string
+	Profiles         map[string]ProfileConfig `mapstructure:"profiles"`
+	Profile          string                   `mapstructure:"profile"`
+	CredentialsFile  string                   `mapstructure:"credentials_file"`
+	TokenFile        string                   `mapstructure:"token_file"`
+	Version          string                   `mapstructure:"version"`
+	Files            FileConfig               `mapstructure:"files"`
+	Cache            CacheConfig              `mapstructure:"cache"`
+	Log              LogConfig                `mapstructure:"log"`
+	Sync             SyncConfig               `mapstructure:"sync"`
+	API              APIConfig                `mapstructure:"api"`
+	Errors           ErrorConfig              `mapstructure:"errors"`
+	Hooks            HooksConfig              `mapstructure:"hooks"`
+	Metrics          MetricsConfig            `mapstructure:"metrics"`
+	Database         DatabaseConfig           `mapstructure:"database"`
+}
+
+// DefaultProfile is the profile used when none is selected.
+const DefaultProfile = "default"
+
+// ProfileConfig contains per-account settings merged over the base config.
+type ProfileConfig struct {
+	CredentialsFile string            `mapstructure:"credentials_file"`
+	TokenFile       string            `mapstructure:"token_file"`
+	Sync            ProfileSyncConfig `mapstructure:"sync"`
+}
+
+// ProfileSyncConfig contains the sync settings a profile may override.
+type ProfileSyncConfig struct {
+	DefaultDirectory string `mapstructure:"default_directory"`
 }
 
 // SyncConfig contains sync-related settings.
@@ -37,7 +63,8 @@ type SyncConfig struct {
 	DefaultDirectory   string `mapstructure:"default_directory"`
 	MaxDepth           int    `mapstructure:"max_depth"`
 	BatchSize          int    `mapstructure:"batch_size"`
-	BandwidthLimit     int    `mapstructure:"bandwidth_limit"`
+	MaxQueuedFiles     int    `mapstructure:"max_queued_files"` // files waiting in the download queue before scheduling pauses; 0 = unlimited
+	BandwidthLimit     string `mapstructure:"bandwidth_limit"`  // e.g. "500KB/s", "5MB/s"; bare numbers are MB/s
 	MaxRetries         int    `mapstructure:"max_retries"`
 	RetryAttempts      int    `mapstructure:"retry_attempts"`
 	RetryDelay         int    `mapstructure:"retry_delay"`
@@ -48,7 +75,54 @@ type SyncConfig struct {
 	ProgressInterval   int    `mapstructure:"progress_interval"`
 	CheckpointInterval int    `mapstructure:"checkpoint_interval"`
 	MaxErrors          int    `mapstructure:"max_errors"`
+	ShutdownTimeout    int    `mapstructure:"shutdown_timeout"`    // seconds to let in-flight downloads finish on shutdown
+	PerFileTimeout     int    `mapstructure:"per_file_timeout"`    // seconds for one attempt at a file; 0 disables
+	PerChunkTimeout    int    `mapstructure:"per_chunk_timeout"`   // seconds for one ranged request; 0 disables
+	NoProgressTimeout  int    `mapstructure:"no_progress_timeout"` // seconds without progress before a sync stops as stalled
 	ResumeOnFailure    bool   `mapstructure:"resume_on_failure"`
+	WriteReport        bool   `mapstructure:"write_report"`         // write cloudpull-report.json into the destination
+	ChecksumAlgorithm  string `mapstructure:"checksum_algorithm"`   // md5, sha256, both or none
+	MaxTotalBytes      string `mapstructure:"max_total_bytes"`      // e.g. "50GB"; empty or "0" means unlimited
+	PathTemplate       string `mapstructure:"path_template"`        // text/template for local paths; empty keeps the Drive layout
+	OrganizeByCategory bool   `mapstructure:"organize_by_category"` // prefix local paths with documents/, images/, ...
+	ScanThenDownload   bool   `mapstructure:"scan_then_download"`   // finish listing folders before downloading
+	TrashRetention     *int   `mapstructure:"trash_retention"`      // days mirror trash is kept; 0 keeps it; nil: 30, forever for --mirror-trash
+	RefreshModified    bool   `mapstructure:"refresh_modified"`     // on resume, download completed files changed in Drive again
+	PersistEvents      bool   `mapstructure:"persist_events"`       // record file events in the state database
+	EventsRetention    int    `mapstructure:"events_retention"`     // days recorded events are kept by cleanup; 0 keeps them
+
+	// PriorityRules map MIME type globs to download priority tiers
+	PriorityRules []PriorityRule `mapstructure:"priority_rules"`
+	// TierBandwidthLimits caps each tier, e.g. {"low": "500KB/s"}
+	TierBandwidthLimits map[string]string `mapstructure:"tier_bandwidth_limits"`
+	// GlobalBandwidthLimit caps all sessions of the process together, in
+	// the format of BandwidthLimit
+	GlobalBandwidthLimit string `mapstructure:"global_bandwidth_limit"`
+	// MaxConsecutiveErrors stops a sync once this many files failed in a
+	// row (0 = disabled)
+	MaxConsecutiveErrors int `mapstructure:"max_consecutive_errors"`
+	// ContinueOnErrors keeps syncing after max_errors is reached
+	ContinueOnErrors bool `mapstructure:"continue_on_errors"`
+	// DestDateSubdir is a Go time layout or strftime pattern; each new
+	// session downloads into a subdirectory of the destination named after
+	// its start date
+	DestDateSubdir string `mapstructure:"dest_date_subdir"`
+	// ScheduleOrder picks which pending files download first:
+	// smallest_first, largest_first or drive_order
+	ScheduleOrder string `mapstructure:"schedule_order"`
+	// ConflictPolicy handles local files changed since their last download:
+	// overwrite, skip, rename or newer_wins
+	ConflictPolicy string `mapstructure:"conflict_policy"`
+	// CASMode stores each distinct content once below .cas in the
+	// destination and links the Drive layout to it: off, symlink or hardlink
+	CASMode string `mapstructure:"cas_mode"`
+}
+
+// PriorityRule assigns files whose MIME type matches a glob such as
+// "video/*" to a priority tier (high, normal or low).
+type PriorityRule struct {
+	MimeType string `mapstructure:"mime_type"`
+	Tier     string `mapstructure:"tier"`
 }
 
 // FileConfig contains file handling settings.
@@ -58,7 +132,25 @@ type FileConfig struct {
 	SkipDuplicates     bool     `mapstructure:"skip_duplicates"`
 	PreserveTimestamps bool     `mapstructure:"preserve_timestamps"`
 	FollowShortcuts    bool     `mapstructure:"follow_shortcuts"`
+	RespectIgnoreFiles bool     `mapstructure:"respect_ignore_files"` // honor .cloudpullignore files in Drive folders
+	SkipHidden         bool     `mapstructure:"skip_hidden"`          // skip dot-named entries and app data files
 	ConvertGoogleDocs  bool     `mapstructure:"convert_google_docs"`
+	MaxPathLength      int      `mapstructure:"max_path_length"` // longest local path in bytes; 0 = no limit
+	FileMode           string   `mapstructure:"file_mode"`       // octal permissions of downloaded files
+	DirMode            string   `mapstructure:"dir_mode"`        // octal permissions of created directories
+
+	// ExportFormats lists export formats per Google file type, e.g.
+	// document: [docx, pdf]; the first format is the main export
+	ExportFormats map[string][]string `mapstructure:"export_formats"`
+
+	// SheetsExportMode exports Google Sheets as xlsx, keeping every tab, or
+	// as csv, which keeps only the first tab; export_formats overrides it
+	SheetsExportMode string `mapstructure:"sheets_export_mode"`
+
+	PostDownloadCommand     string `mapstructure:"post_download_command"`     // run on each downloaded file
+	PostDownloadTimeout     int    `mapstructure:"post_download_timeout"`     // seconds
+	PostDownloadOnFailure   string `mapstructure:"post_download_on_failure"`  // log or fail
+	PostDownloadConcurrency int    `mapstructure:"post_download_concurrency"` // commands running at once
 }
 
 // CacheConfig contains cache settings.
@@ -72,7 +164,7 @@ type CacheConfig struct {
 // LogConfig contains logging settings.
 type LogConfig struct {
 	Level      string `mapstructure:"level"`  // debug, info, warn, error
-	Format     string `mapstructure:"format"` // json, text
+	Format     string `mapstructure:"format"` // json, console (alias pretty) or text
 	Output     string `mapstructure:"output"` // stdout, stderr, file
 	File       string `mapstructure:"file"`
 	MaxSize    int    `mapstructure:"max_size"` // MB
@@ -83,11 +175,19 @@ type LogConfig struct {
 
 // APIConfig contains API-related settings.
 type APIConfig struct {
-	MaxRetries      int `mapstructure:"max_retries"`
-	RetryDelay      int `mapstructure:"retry_delay"`     // seconds
-	RequestTimeout  int `mapstructure:"request_timeout"` // seconds
-	MaxConcurrent   int `mapstructure:"max_concurrent"`
-	RateLimitPerSec int `mapstructure:"rate_limit"`
+	MaxRetries          int      `mapstructure:"max_retries"`
+	RetryDelay          int      `mapstructure:"retry_delay"`     // seconds
+	RequestTimeout      int      `mapstructure:"request_timeout"` // seconds
+	MaxConcurrent       int      `mapstructure:"max_concurrent"`
+	RateLimitPerSec     int      `mapstructure:"rate_limit"`
+	MinRateLimit        int      `mapstructure:"min_rate_limit"`          // lowest rate after throttling
+	MaxRateLimit        int      `mapstructure:"max_rate_limit"`          // highest rate when recovering; 0 means rate_limit
+	ListMaxRetries      int      `mapstructure:"list_max_retries"`        // attempts for folder listings and metadata lookups
+	FileFields          []string `mapstructure:"file_fields"`             // Drive file fields to request; empty means the default set
+	MaxIdleConnsPerHost int      `mapstructure:"max_idle_conns_per_host"` // 0 means derived from sync.max_concurrent
+	MaxCallsPerRun      int64    `mapstructure:"max_calls_per_run"`       // Drive requests per sync run; 0 means unlimited
+	RetryableCodes      []int    `mapstructure:"retryable_codes"`         // HTTP statuses retried besides the defaults; -N removes N
+	RetryableReasons    []string `mapstructure:"retryable_reasons"`       // error message fragments retried besides the defaults; "-x" removes x
 }
 
 // ErrorConfig contains error handling settings.
@@ -98,6 +198,27 @@ type ErrorConfig struct {
 	RetryMaxDelay   int     `mapstructure:"retry_max_delay"` // seconds
 }
 
+// HooksConfig contains sync completion hook settings.
+type HooksConfig struct {
+	OnCompleteURL     string `mapstructure:"on_complete_url"`
+	OnCompleteCommand string `mapstructure:"on_complete_command"`
+	Timeout           int    `mapstructure:"timeout"` // seconds
+	MaxRetries        int    `mapstructure:"max_retries"`
+}
+
+// MetricsConfig contains Prometheus metrics endpoint settings.
+type MetricsConfig struct {
+	Addr    string `mapstructure:"addr"`
+	Enabled bool   `mapstructure:"enabled"`
+}
+
+// DatabaseConfig contains state database maintenance settings.
+type DatabaseConfig struct {
+	// AfterCleanup is run after cleanups delete rows: off, optimize
+	// (PRAGMA optimize) or vacuum (VACUUM, then PRAGMA optimize)
+	AfterCleanup string `mapstructure:"after_cleanup"`
+}
+
 // Load initializes and loads the configuration.
 func Load(cfgFile ...string) (*Config, error) {
 	once.Do(func() {
@@ -113,9 +234,16 @@ func Load(cfgFile ...string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Merge the selected profile over the base config
+	applyProfile(config)
+
 	// Set defaults if not configured
 	setDefaults(config)
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
@@ -126,9 +254,16 @@ func LoadFromViper(v *viper.Viper) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
+	// Merge the selected profile over the base config
+	applyProfile(cfg)
+
 	// Set defaults if not configured
 	setDefaults(cfg)
 
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
 	return cfg, nil
 }
 
@@ -186,8 +321,7 @@ func initViper(cfgFile string) {
 	}
 
 	// Environment variables
-	viper.SetEnvPrefix("CLOUDPULL")
-	viper.AutomaticEnv()
+	BindEnv(viper.GetViper())
 
 	// Set defaults
 	setViperDefaults()
@@ -203,29 +337,62 @@ func setViperDefaults() {
 		home = "."
 	}
 
+	// Profile defaults (CLOUDPULL_PROFILE or --profile select another)
+	viper.SetDefault("profile", DefaultProfile)
+
 	// Sync defaults
 	viper.SetDefault("sync.default_directory", filepath.Join(home, "CloudPull"))
 	viper.SetDefault("sync.max_concurrent", 3)
 	viper.SetDefault("sync.chunk_size", "1MB")
-	viper.SetDefault("sync.bandwidth_limit", 0)
+	viper.SetDefault("sync.bandwidth_limit", "0")
+	viper.SetDefault("sync.global_bandwidth_limit", "0")
 	viper.SetDefault("sync.resume_on_failure", true)
 	viper.SetDefault("sync.retry_attempts", 3)
 	viper.SetDefault("sync.retry_delay", 5)
 	viper.SetDefault("sync.max_depth", -1)
 	viper.SetDefault("sync.batch_size", 100)
+	viper.SetDefault("sync.max_queued_files", 10000)
 	viper.SetDefault("sync.walker_concurrent", 5)
 	viper.SetDefault("sync.queue_size", 1000)
 	viper.SetDefault("sync.progress_interval", 1)
 	viper.SetDefault("sync.checkpoint_interval", 30)
 	viper.SetDefault("sync.max_errors", 100)
+	viper.SetDefault("sync.max_consecutive_errors", 0)
+	viper.SetDefault("sync.continue_on_errors", false)
+	viper.SetDefault("sync.dest_date_subdir", "")
+	viper.SetDefault("sync.schedule_order", "smallest_first")
+	viper.SetDefault("sync.conflict_policy", "overwrite")
+	viper.SetDefault("sync.cas_mode", "off")
 	viper.SetDefault("sync.max_retries", 3)
+	viper.SetDefault("sync.shutdown_timeout", 30)
+	viper.SetDefault("sync.per_file_timeout", 0)
+	viper.SetDefault("sync.per_chunk_timeout", 300)
+	viper.SetDefault("sync.no_progress_timeout", 900)
+	viper.SetDefault("sync.checksum_algorithm", "md5")
+	viper.SetDefault("sync.max_total_bytes", "0")
+	viper.SetDefault("sync.write_report", false)
+	viper.SetDefault("sync.scan_then_download", false)
+	viper.SetDefault("sync.refresh_modified", false)
+	viper.SetDefault("sync.persist_events", false)
+	viper.SetDefault("sync.events_retention", 7)
+	viper.SetDefault("sync.organize_by_category", false)
 
 	// File defaults
 	viper.SetDefault("files.skip_duplicates", true)
 	viper.SetDefault("files.preserve_timestamps", true)
 	viper.SetDefault("files.follow_shortcuts", false)
+	viper.SetDefault("files.respect_ignore_files", false)
+	viper.SetDefault("files.skip_hidden", false)
 	viper.SetDefault("files.convert_google_docs", true)
+	viper.SetDefault("files.max_path_length", 0)
+	viper.SetDefault("files.file_mode", "0644")
+	viper.SetDefault("files.dir_mode", "0750")
 	viper.SetDefault("files.google_docs_format", "pdf")
+	viper.SetDefault("files.sheets_export_mode", "xlsx")
+	viper.SetDefault("files.post_download_command", "")
+	viper.SetDefault("files.post_download_timeout", 60)
+	viper.SetDefault("files.post_download_on_failure", "log")
+	viper.SetDefault("files.post_download_concurrency", 2)
 	viper.SetDefault("files.ignore_patterns", []string{
 		"*.tmp",
 		"~$*",
@@ -255,6 +422,11 @@ func setViperDefaults() {
 	viper.SetDefault("api.request_timeout", 30)
 	viper.SetDefault("api.max_concurrent", 10)
 	viper.SetDefault("api.rate_limit", 10)
+	viper.SetDefault("api.min_rate_limit", 1)
+	viper.SetDefault("api.max_rate_limit", 0)
+	viper.SetDefault("api.list_max_retries", 5)
+	viper.SetDefault("api.max_idle_conns_per_host", 0)
+	viper.SetDefault("api.max_calls_per_run", 0)
 
 	// Error defaults
 	viper.SetDefault("errors.max_retries", 3)
@@ -262,10 +434,59 @@ func setViperDefaults() {
 	viper.SetDefault("errors.retry_multiplier", 2.0)
 	viper.SetDefault("errors.retry_max_delay", 60)
 
+	// Hook defaults
+	viper.SetDefault("hooks.on_complete_url", "")
+	viper.SetDefault("hooks.on_complete_command", "")
+	viper.SetDefault("hooks.timeout", 10)
+	viper.SetDefault("hooks.max_retries", 3)
+
+	// Metrics defaults
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.addr", "127.0.0.1:9090")
+
+	// Database defaults
+	viper.SetDefault("database.after_cleanup", "optimize")
+
 	// Version
 	viper.SetDefault("version", "1.0.0")
 }
 
+// applyProfile merges the selected profile over the base configuration.
+// Token files are never shared between profiles: a non-default profile
+// only uses the token file it declares itself.
+func applyProfile(cfg *Config) {
+	cfg.Profile = strings.ToLower(strings.TrimSpace(cfg.Profile))
+	if cfg.Profile == "" {
+		cfg.Profile = DefaultProfile
+	}
+
+	cfg.profileOverrides = make(map[string]string)
+	if cfg.Profile != DefaultProfile {
+		cfg.TokenFile = ""
+		cfg.profileOverrides["token_file"] = ""
+	}
+
+	profile, ok := cfg.Profiles[cfg.Profile]
+	if !ok {
+		return
+	}
+
+	if profile.CredentialsFile != "" {
+		cfg.CredentialsFile = profile.CredentialsFile
+		cfg.profileOverrides["credentials_file"] = profile.CredentialsFile
+	}
+
+	if profile.TokenFile != "" {
+		cfg.TokenFile = profile.TokenFile
+		cfg.profileOverrides["token_file"] = profile.TokenFile
+	}
+
+	if profile.Sync.DefaultDirectory != "" {
+		cfg.Sync.DefaultDirectory = profile.Sync.DefaultDirectory
+		cfg.profileOverrides["sync.default_directory"] = profile.Sync.DefaultDirectory
+	}
+}
+
 // setDefaults ensures all config fields have sensible defaults.
 func setDefaults(cfg *Config) {
 	home, err := os.UserHomeDir()
@@ -285,6 +506,18 @@ func setDefaults(cfg *Config) {
 		cfg.Sync.ChunkSize = "1MB"
 	}
 
+	if cfg.Sync.WalkerConcurrent == 0 {
+		cfg.Sync.WalkerConcurrent = 5
+	}
+
+	if cfg.Sync.QueueSize == 0 {
+		cfg.Sync.QueueSize = 1000
+	}
+
+	if cfg.Sync.BatchSize == 0 {
+		cfg.Sync.BatchSize = 100
+	}
+
 	if cfg.Cache.Directory == "" {
 		cfg.Cache.Directory = filepath.Join(home, ".cloudpull", "cache")
 	}
@@ -294,6 +527,251 @@ func setDefaults(cfg *Config) {
 	}
 }
 
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validLogLevels and validLogFormats are the accepted logging settings.
+var (
+	validLogLevels  = []string{"trace", "debug", "info", "warn", "error"}
+	validLogFormats = []string{"json", "console", "pretty", "text"}
+	validTiers      = []string{"high", "normal", "low"}
+	validChecksums  = []string{"md5", "sha256", "both", "none"}
+	validHookPolicy = []string{"log", "fail"}
+	validOrders     = []string{"smallest_first", "largest_first", "drive_order"}
+	validConflicts  = []string{"overwrite", "skip", "rename", "newer_wins"}
+	validSheetModes = []string{"xlsx", "csv"}
+	validCASModes   = []string{"off", "symlink", "hardlink"}
+	validDBUpkeep   = []string{"off", "optimize", "vacuum"}
+)
+
+// Validate checks the configuration for invalid values and returns a
+// *ValidationError describing every problem found.
+func (c *Config) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Sync.MaxConcurrent < 1 {
+		addProblem("sync.max_concurrent must be at least 1, got %d", c.Sync.MaxConcurrent)
+	}
+
+	if c.Sync.MaxDepth < -1 {
+		addProblem("sync.max_depth must be -1 (unlimited) or greater, got %d", c.Sync.MaxDepth)
+	}
+
+	if chunkSize, err := c.GetChunkSizeBytes(); err != nil {
+		addProblem("sync.chunk_size is not a valid size: %v", err)
+	} else if chunkSize <= 0 {
+		addProblem("sync.chunk_size must be positive, got %q", c.Sync.ChunkSize)
+	}
+
+	if _, err := c.GetBandwidthLimitBytes(); err != nil {
+		addProblem("sync.bandwidth_limit is not a valid rate: %v", err)
+	}
+
+	if _, err := c.GetGlobalBandwidthLimitBytes(); err != nil {
+		addProblem("sync.global_bandwidth_limit is not a valid rate: %v", err)
+	}
+
+	if c.Sync.WalkerConcurrent < 0 {
+		addProblem("sync.walker_concurrent must not be negative, got %d", c.Sync.WalkerConcurrent)
+	}
+
+	if c.Sync.QueueSize < 0 {
+		addProblem("sync.queue_size must not be negative, got %d", c.Sync.QueueSize)
+	}
+
+	if c.Sync.BatchSize < 0 {
+		addProblem("sync.batch_size must not be negative, got %d", c.Sync.BatchSize)
+	}
+
+	if c.Sync.MaxQueuedFiles < 0 {
+		addProblem("sync.max_queued_files must not be negative, got %d", c.Sync.MaxQueuedFiles)
+	}
+
+	if c.Sync.MaxConsecutiveErrors < 0 {
+		addProblem("sync.max_consecutive_errors must not be negative, got %d", c.Sync.MaxConsecutiveErrors)
+	}
+
+	if _, err := c.GetDestDateLayout(); err != nil {
+		addProblem("sync.dest_date_subdir: %v", err)
+	}
+
+	if c.Sync.PerFileTimeout < 0 {
+		addProblem("sync.per_file_timeout must not be negative, got %d", c.Sync.PerFileTimeout)
+	}
+
+	if c.Sync.PerChunkTimeout < 0 {
+		addProblem("sync.per_chunk_timeout must not be negative, got %d", c.Sync.PerChunkTimeout)
+	}
+
+	if c.Sync.NoProgressTimeout < 0 {
+		addProblem("sync.no_progress_timeout must not be negative, got %d", c.Sync.NoProgressTimeout)
+	}
+
+	if c.Sync.EventsRetention < 0 {
+		addProblem("sync.events_retention must not be negative, got %d", c.Sync.EventsRetention)
+	}
+
+	if _, err := c.GetMaxTotalBytes(); err != nil {
+		addProblem("sync.max_total_bytes is not a valid size: %v", err)
+	}
+
+	if c.Sync.PathTemplate != "" {
+		if _, err := template.New("path").Parse(c.Sync.PathTemplate); err != nil {
+			addProblem("sync.path_template is not a valid template: %v", err)
+		}
+	}
+
+	for i, rule := range c.Sync.PriorityRules {
+		if _, err := path.Match(rule.MimeType, ""); err != nil || rule.MimeType == "" {
+			addProblem("sync.priority_rules[%d].mime_type %q is not a valid glob", i, rule.MimeType)
+		}
+		if !containsString(validTiers, strings.ToLower(rule.Tier)) {
+			addProblem("sync.priority_rules[%d].tier must be one of %s, got %q", i, strings.Join(validTiers, ", "), rule.Tier)
+		}
+	}
+
+	if _, err := c.GetTierBandwidthLimits(); err != nil {
+		addProblem("sync.tier_bandwidth_limits: %v", err)
+	}
+
+	if c.Sync.ChecksumAlgorithm != "" && !containsString(validChecksums, strings.ToLower(c.Sync.ChecksumAlgorithm)) {
+		addProblem("sync.checksum_algorithm must be one of %s, got %q", strings.Join(validChecksums, ", "), c.Sync.ChecksumAlgorithm)
+	}
+
+	if c.Sync.ScheduleOrder != "" && !containsString(validOrders, strings.ToLower(c.Sync.ScheduleOrder)) {
+		addProblem("sync.schedule_order must be one of %s, got %q", strings.Join(validOrders, ", "), c.Sync.ScheduleOrder)
+	}
+
+	if c.Sync.ConflictPolicy != "" && !containsString(validConflicts, strings.ToLower(c.Sync.ConflictPolicy)) {
+		addProblem("sync.conflict_policy must be one of %s, got %q", strings.Join(validConflicts, ", "), c.Sync.ConflictPolicy)
+	}
+
+	if c.Sync.CASMode != "" && !containsString(validCASModes, strings.ToLower(c.Sync.CASMode)) {
+		addProblem("sync.cas_mode must be one of %s, got %q", strings.Join(validCASModes, ", "), c.Sync.CASMode)
+	}
+
+	if c.Database.AfterCleanup != "" && !containsString(validDBUpkeep, strings.ToLower(c.Database.AfterCleanup)) {
+		addProblem("database.after_cleanup must be one of %s, got %q", strings.Join(validDBUpkeep, ", "), c.Database.AfterCleanup)
+	}
+
+	if c.Files.PostDownloadOnFailure != "" && !containsString(validHookPolicy, strings.ToLower(c.Files.PostDownloadOnFailure)) {
+		addProblem("files.post_download_on_failure must be one of %s, got %q", strings.Join(validHookPolicy, ", "), c.Files.PostDownloadOnFailure)
+	}
+
+	if c.Files.SheetsExportMode != "" && !containsString(validSheetModes, strings.ToLower(c.Files.SheetsExportMode)) {
+		addProblem("files.sheets_export_mode must be one of %s, got %q", strings.Join(validSheetModes, ", "), c.Files.SheetsExportMode)
+	}
+
+	if c.Files.PostDownloadTimeout < 0 {
+		addProblem("files.post_download_timeout must not be negative, got %d", c.Files.PostDownloadTimeout)
+	}
+
+	if c.API.MinRateLimit < 0 {
+		addProblem("api.min_rate_limit must not be negative, got %d", c.API.MinRateLimit)
+	}
+
+	if c.API.MaxRateLimit != 0 && c.API.MaxRateLimit < c.API.MinRateLimit {
+		addProblem("api.max_rate_limit must be 0 or at least api.min_rate_limit, got %d", c.API.MaxRateLimit)
+	}
+
+	if c.Files.MaxPathLength < 0 {
+		addProblem("files.max_path_length must not be negative, got %d", c.Files.MaxPathLength)
+	}
+
+	if _, err := ParseFileMode(c.Files.FileMode); err != nil {
+		addProblem("files.file_mode: %v", err)
+	}
+
+	if _, err := ParseFileMode(c.Files.DirMode); err != nil {
+		addProblem("files.dir_mode: %v", err)
+	}
+
+	if c.API.ListMaxRetries < 0 {
+		addProblem("api.list_max_retries must not be negative, got %d", c.API.ListMaxRetries)
+	}
+
+	for _, code := range c.API.RetryableCodes {
+		status := code
+		if status < 0 {
+			status = -status
+		}
+		if status < 100 || status > 599 {
+			addProblem("api.retryable_codes must be HTTP status codes (100-599, negative to remove), got %d", code)
+		}
+	}
+
+	for _, reason := range c.API.RetryableReasons {
+		if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(reason), "-")) == "" {
+			addProblem("api.retryable_reasons must not contain empty entries")
+			break
+		}
+	}
+
+	if c.API.MaxIdleConnsPerHost < 0 {
+		addProblem("api.max_idle_conns_per_host must not be negative, got %d", c.API.MaxIdleConnsPerHost)
+	}
+
+	if c.API.MaxCallsPerRun < 0 {
+		addProblem("api.max_calls_per_run must not be negative, got %d", c.API.MaxCallsPerRun)
+	}
+
+	if !containsString(validLogLevels, strings.ToLower(c.Log.Level)) {
+		addProblem("log.level must be one of %s, got %q", strings.Join(validLogLevels, ", "), c.Log.Level)
+	}
+
+	if c.Log.Format != "" && !containsString(validLogFormats, strings.ToLower(c.Log.Format)) {
+		addProblem("log.format must be one of %s, got %q", strings.Join(validLogFormats, ", "), c.Log.Format)
+	}
+
+	if c.Profile != "" && c.Profile != DefaultProfile {
+		if _, ok := c.Profiles[c.Profile]; !ok {
+			addProblem("profile %q is not defined under profiles", c.Profile)
+		}
+	}
+
+	if c.CredentialsFile != "" {
+		if _, err := os.Stat(expandHome(c.CredentialsFile)); err != nil {
+			addProblem("credentials_file %q is not accessible: %v", c.CredentialsFile, err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// containsString reports whether list contains value.
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome expands a leading "~/" to the user's home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
 // GetChunkSizeBytes converts chunk size string to bytes.
 func (c *Config) GetChunkSizeBytes() (int64, error) {
 	size := c.Sync.ChunkSize
@@ -301,31 +779,161 @@ func (c *Config) GetChunkSizeBytes() (int64, error) {
 		size = "1MB"
 	}
 
-	multiplier := int64(1)
-	value := int64(0)
+	return parseByteSize(size, 1)
+}
 
-	if strings.HasSuffix(size, "KB") {
-		multiplier = 1024
-		fmt.Sscanf(size, "%dKB", &value)
-	} else if strings.HasSuffix(size, "MB") {
-		multiplier = 1024 * 1024
-		fmt.Sscanf(size, "%dMB", &value)
-	} else if strings.HasSuffix(size, "GB") {
-		multiplier = 1024 * 1024 * 1024
-		fmt.Sscanf(size, "%dGB", &value)
-	} else {
-		fmt.Sscanf(size, "%d", &value)
+// GetBandwidthLimitBytes converts bandwidth limit to bytes/second.
+// A result of 0 means unlimited.
+func (c *Config) GetBandwidthLimitBytes() (int64, error) {
+	return ParseBandwidthLimit(c.Sync.BandwidthLimit)
+}
+
+// GetGlobalBandwidthLimitBytes converts the limit shared by all sessions to
+// bytes/second. A result of 0 means unlimited.
+func (c *Config) GetGlobalBandwidthLimitBytes() (int64, error) {
+	return ParseBandwidthLimit(c.Sync.GlobalBandwidthLimit)
+}
+
+// GetMaxTotalBytes converts the per-sync download cap to bytes.
+// A result of 0 means unlimited.
+func (c *Config) GetMaxTotalBytes() (int64, error) {
+	return ParseSize(c.Sync.MaxTotalBytes)
+}
+
+// GetDestDateLayout returns the Go time layout of the dated destination
+// subdirectory, or "" if sessions download into the destination itself.
+func (c *Config) GetDestDateLayout() (string, error) {
+	return ParseDestDateLayout(c.Sync.DestDateSubdir)
+}
+
+// ParseDestDateLayout converts a Go time layout or strftime pattern for
+// dated destination subdirectories to a Go layout, checking that it names a
+// subdirectory. An empty value is returned as "".
+func ParseDestDateLayout(layout string) (string, error) {
+	if layout == "" {
+		return "", nil
 	}
 
-	return value * multiplier, nil
+	goLayout, err := util.ParseDateLayout(layout)
+	if err != nil {
+		return "", err
+	}
+	if _, err := util.DateSubdir(goLayout, time.Now()); err != nil {
+		return "", err
+	}
+	return goLayout, nil
 }
 
-// GetBandwidthLimitBytes converts bandwidth limit to bytes/second.
-func (c *Config) GetBandwidthLimitBytes() int64 {
-	if c.Sync.BandwidthLimit <= 0 {
-		return 0 // unlimited
+// GetTierBandwidthLimits converts the per-tier bandwidth caps to
+// bytes/second, keyed by lower-case tier name. Unlimited tiers are omitted.
+func (c *Config) GetTierBandwidthLimits() (map[string]int64, error) {
+	limits := make(map[string]int64, len(c.Sync.TierBandwidthLimits))
+	for tier, limit := range c.Sync.TierBandwidthLimits {
+		tier = strings.ToLower(tier)
+		if !containsString(validTiers, tier) {
+			return nil, fmt.Errorf("unknown tier %q", tier)
+		}
+
+		bytesPerSecond, err := ParseBandwidthLimit(limit)
+		if err != nil {
+			return nil, fmt.Errorf("tier %s: %w", tier, err)
+		}
+		if bytesPerSecond > 0 {
+			limits[tier] = bytesPerSecond
+		}
+	}
+
+	return limits, nil
+}
+
+// GetFileModes returns the permissions of downloaded files and of the
+// directories created for them.
+func (c *Config) GetFileModes() (fileMode, dirMode os.FileMode, err error) {
+	if fileMode, err = ParseFileMode(c.Files.FileMode); err != nil {
+		return 0, 0, fmt.Errorf("files.file_mode: %w", err)
+	}
+	if dirMode, err = ParseFileMode(c.Files.DirMode); err != nil {
+		return 0, 0, fmt.Errorf("files.dir_mode: %w", err)
+	}
+	return fileMode, dirMode, nil
+}
+
+// ParseFileMode parses octal permissions such as "0640" or "750". An empty
+// value or 0 means the default and is returned as 0.
+func ParseFileMode(mode string) (os.FileMode, error) {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseUint(strings.TrimPrefix(mode, "0o"), 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid permissions %q, use octal such as 0644", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// ParseBandwidthLimit parses a bandwidth limit such as "500KB/s" or "5MB"
+// into bytes per second. Bare numbers are treated as MB/s for backward
+// compatibility, and an empty value or "0" means unlimited.
+func ParseBandwidthLimit(limit string) (int64, error) {
+	limit = strings.TrimSpace(limit)
+	if limit == "" {
+		return 0, nil
+	}
+
+	return parseByteSize(limit, 1024*1024)
+}
+
+// ParseSize parses a size such as "500MB" or "2TB" into bytes. Bare
+// numbers are bytes, and an empty value means 0.
+func ParseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, nil
+	}
+
+	return parseByteSize(size, 1)
+}
+
+// parseByteSize parses a size with an optional B/KB/MB/GB/TB unit and an
+// optional "/s" suffix. Values without a unit are multiplied by
+// defaultMultiplier.
+func parseByteSize(size string, defaultMultiplier int64) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	value = strings.TrimSpace(strings.TrimSuffix(value, "/S"))
+
+	multiplier := defaultMultiplier
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KB", 1024},
+		{"MB", 1024 * 1024},
+		{"GB", 1024 * 1024 * 1024},
+		{"TB", 1024 * 1024 * 1024 * 1024},
+		{"B", 1},
+	}
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.multiplier
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", size, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid size %q: must not be negative", size)
 	}
-	return int64(c.Sync.BandwidthLimit) * 1024 * 1024 // MB/s to bytes/s
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size %q: too large", size)
+	}
+
+	return n * multiplier, nil
 }
 
 // ConfigPath returns the path to the config file.
@@ -355,8 +963,34 @@ func (c *Config) GetDataDir() string {
 	return DataDir()
 }
 
-// GetString returns a string value from viper.
+// ActiveProfile returns the name of the selected profile.
+func (c *Config) ActiveProfile() string {
+	if c.Profile == "" {
+		return DefaultProfile
+	}
+	return c.Profile
+}
+
+// GetTokenFile returns the OAuth token path for the active profile.
+// Non-default profiles get their own token under the data directory so
+// tokens from different accounts never collide.
+func (c *Config) GetTokenFile() string {
+	if c.TokenFile != "" {
+		return expandHome(c.TokenFile)
+	}
+
+	if c.ActiveProfile() == DefaultProfile {
+		return filepath.Join(DataDir(), "token.json")
+	}
+
+	return filepath.Join(DataDir(), "profiles", c.ActiveProfile(), "token.json")
+}
+
+// GetString returns a string value from viper, honoring profile overrides.
 func (c *Config) GetString(key string) string {
+	if value, ok := c.profileOverrides[key]; ok {
+		return value
+	}
 	if c.viper != nil {
 		return c.viper.GetString(key)
 	}
@@ -387,6 +1021,14 @@ func (c *Config) GetFloat64(key string) float64 {
 	return viper.GetFloat64(key)
 }
 
+// GetBool returns a bool value from viper.
+func (c *Config) GetBool(key string) bool {
+	if c.viper != nil {
+		return c.viper.GetBool(key)
+	}
+	return viper.GetBool(key)
+}
+
 // GetDuration returns a duration value from viper.
 func (c *Config) GetDuration(key string) time.Duration {
 	// Get the value as int (seconds) and convert to duration
@@ -403,3 +1045,11 @@ func (c *Config) GetDuration(key string) time.Duration {
 func (c *Config) GetLogLevel() string {
 	return c.Log.Level
 }
+
+// GetLogFormat returns the log format, defaulting to text.
+func (c *Config) GetLogFormat() string {
+	if c.Log.Format == "" {
+		return "text"
+	}
+	return c.Log.Format
+}
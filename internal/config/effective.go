@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// BindEnv makes v read CLOUDPULL_* environment variables, with nested keys
+// such as sync.max_concurrent read from CLOUDPULL_SYNC_MAX_CONCURRENT.
+func BindEnv(v *viper.Viper) {
+	v.SetEnvPrefix("CLOUDPULL")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+}
+
+// Effective returns the resolved configuration keyed like the config file:
+// defaults, the config file and environment variables combined, with the
+// selected profile merged in. token_file is the token path actually used,
+// and passwords in URLs are redacted.
+func (c *Config) Effective() map[string]interface{} {
+	settings := settingsOf(reflect.ValueOf(*c)).(map[string]interface{})
+	settings["profile"] = c.ActiveProfile()
+	settings["token_file"] = c.GetTokenFile()
+
+	if hooks, ok := settings["hooks"].(map[string]interface{}); ok {
+		hooks["on_complete_url"] = redactURL(c.Hooks.OnCompleteURL)
+	}
+
+	return settings
+}
+
+// settingsOf converts a configuration value into maps, slices and plain
+// values, naming struct fields after their mapstructure tags.
+func settingsOf(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		settings := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "" || !field.IsExported() {
+				continue
+			}
+			settings[name] = settingsOf(v.Field(i))
+		}
+		return settings
+
+	case reflect.Map:
+		settings := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			settings[fmt.Sprint(iter.Key().Interface())] = settingsOf(iter.Value())
+		}
+		return settings
+
+	case reflect.Slice:
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = settingsOf(v.Index(i))
+		}
+		return items
+
+	default:
+		return v.Interface()
+	}
+}
+
+// redactURL hides the password of a URL such as a webhook address.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	return u.Redacted()
+}
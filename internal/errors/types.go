@@ -48,6 +48,9 @@ const (
 
 	// ErrorTypeAPI represents general API errors.
 	ErrorTypeAPI
+
+	// ErrorTypeNotFound represents items that no longer exist (permanent).
+	ErrorTypeNotFound
 )
 
 // String returns the string representation of ErrorType.
@@ -69,6 +72,8 @@ func (et ErrorType) String() string {
 		return "Context"
 	case ErrorTypeAPI:
 		return "API"
+	case ErrorTypeNotFound:
+		return "NotFound"
 	default:
 		return "Unknown"
 	}
@@ -79,7 +84,7 @@ func (et ErrorType) IsRetryable() bool {
 	switch et {
 	case ErrorTypeNetwork, ErrorTypeAPIQuota, ErrorTypeStorage:
 		return true
-	case ErrorTypePermission, ErrorTypeConfiguration:
+	case ErrorTypePermission, ErrorTypeConfiguration, ErrorTypeNotFound:
 		return false
 	case ErrorTypeCorruption:
 		// Corruption errors are retryable but require special handling.
@@ -69,3 +69,13 @@ func IsTemporary(err error) bool {
 func AsError(err error, target **Error) bool {
 	return errors.As(err, target)
 }
+
+// As finds the first error in err's chain that matches target.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// Is reports whether any error in err's chain matches target.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
@@ -30,12 +30,47 @@ type Logger struct {
 	config *Config
 }
 
+// Format selects how log entries are written.
+type Format string
+
+const (
+	// FormatJSON writes one JSON object per entry.
+	FormatJSON Format = "json"
+
+	// FormatConsole writes colorized, human-readable lines.
+	FormatConsole Format = "console"
+
+	// FormatText writes human-readable lines without colors, for files and
+	// terminals that do not support them.
+	FormatText Format = "text"
+)
+
+// ParseFormat parses a log format name. "pretty" is accepted as another
+// name for FormatConsole.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "json":
+		return FormatJSON, nil
+	case "console", "pretty":
+		return FormatConsole, nil
+	case "text":
+		return FormatText, nil
+	default:
+		return "", fmt.Errorf("unknown log format %q (valid: json, console, text)", name)
+	}
+}
+
 // Config configures the logger behavior.
 type Config struct {
-	Output        io.Writer
-	Fields        map[string]interface{}
-	Level         string
-	TimeFormat    string
+	Output     io.Writer
+	Fields     map[string]interface{}
+	Level      string
+	TimeFormat string
+
+	// Format selects the output format; when empty, Pretty chooses between
+	// FormatConsole and FormatJSON
+	Format Format
+
 	Pretty        bool
 	IncludeCaller bool
 }
@@ -67,11 +102,12 @@ func New(config *Config) *Logger {
 
 	// Configure output
 	var output = config.Output
-	if config.Pretty {
+	switch config.format() {
+	case FormatConsole, FormatText:
 		output = zerolog.ConsoleWriter{
 			Out:        config.Output,
 			TimeFormat: config.TimeFormat,
-			NoColor:    false,
+			NoColor:    config.format() == FormatText,
 		}
 	}
 
@@ -104,6 +140,17 @@ func New(config *Config) *Logger {
 	}
 }
 
+// format returns the output format, honoring Pretty when Format is unset.
+func (c *Config) format() Format {
+	if c.Format != "" {
+		return c.Format
+	}
+	if c.Pretty {
+		return FormatConsole
+	}
+	return FormatJSON
+}
+
 // WithContext adds the logger to context.
 func (l *Logger) WithContext(ctx context.Context) context.Context {
 	return context.WithValue(ctx, loggerKey, l)
@@ -322,6 +369,7 @@ func NewDevelopmentConfig() *Config {
 	return &Config{
 		Level:         "debug",
 		Output:        os.Stdout,
+		Format:        FormatConsole,
 		Pretty:        true,
 		IncludeCaller: true,
 		Fields: map[string]interface{}{
@@ -336,6 +384,7 @@ func NewProductionConfig() *Config {
 	return &Config{
 		Level:         "info",
 		Output:        os.Stdout,
+		Format:        FormatJSON,
 		Pretty:        false,
 		IncludeCaller: false,
 		Fields: map[string]interface{}{
@@ -434,3 +434,58 @@ func TestPrettyLogging(t *testing.T) {
 	assert.Contains(t, output, "key=")
 	assert.Contains(t, output, "value")
 }
+
+// Test each output format.
+func TestLogFormats(t *testing.T) {
+	write := func(format Format) string {
+		buf := &bytes.Buffer{}
+		log := New(&Config{Level: "info", Output: buf, Format: format})
+		log.Info("format message", "key", "value")
+		return buf.String()
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(write(FormatJSON)), &entry))
+		assert.Equal(t, "format message", entry["message"])
+		assert.Equal(t, "value", entry["key"])
+	})
+
+	t.Run("Console", func(t *testing.T) {
+		output := write(FormatConsole)
+		assert.False(t, json.Valid([]byte(output)))
+		assert.Contains(t, output, "\x1b[")
+		assert.Contains(t, output, "format message")
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		output := write(FormatText)
+		assert.False(t, json.Valid([]byte(output)))
+		assert.NotContains(t, output, "\x1b[")
+		assert.Contains(t, output, "INF format message")
+		assert.Contains(t, output, "key=value")
+	})
+
+	t.Run("PrettyWithoutFormat", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		New(&Config{Level: "info", Output: buf, Pretty: true}).Info("format message")
+		assert.Contains(t, buf.String(), "\x1b[")
+	})
+}
+
+// Test log format names.
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{
+		"json":    FormatJSON,
+		"console": FormatConsole,
+		"pretty":  FormatConsole,
+		"TEXT":    FormatText,
+	} {
+		format, err := ParseFormat(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, format, name)
+	}
+
+	_, err := ParseFormat("xml")
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	cloudsync "github.com/VatsalSy/CloudPull/internal/sync"
+)
+
+type fakeSource struct {
+	progress *cloudsync.ProgressStats
+	workers  *cloudsync.WorkerPoolStats
+}
+
+func (f *fakeSource) GetStats() (*cloudsync.ProgressStats, *cloudsync.WorkerPoolStats) {
+	return f.progress, f.workers
+}
+
+func TestSessionCollectorGather(t *testing.T) {
+	server := NewServer("127.0.0.1:0", logger.New(&logger.Config{Level: "error", Output: io.Discard}))
+	server.RegisterSession("session-1", &fakeSource{
+		progress: &cloudsync.ProgressStats{
+			TotalFiles:      10,
+			CompletedFiles:  4,
+			FailedFiles:     1,
+			SkippedFiles:    2,
+			CompletedBytes:  2048,
+			CurrentSpeed:    512,
+			ActiveDownloads: 3,
+		},
+		workers: &cloudsync.WorkerPoolStats{WorkerCount: 5, QueuedTasks: 7, ScheduleBacklog: 40},
+	})
+
+	families, err := server.Registry().Gather()
+	require.NoError(t, err)
+
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			require.Len(t, metric.GetLabel(), 1)
+			assert.Equal(t, "session-1", metric.GetLabel()[0].GetValue())
+			if metric.GetCounter() != nil {
+				values[family.GetName()] = metric.GetCounter().GetValue()
+			} else {
+				values[family.GetName()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+
+	assert.Equal(t, 4.0, values["cloudpull_files_completed_total"])
+	assert.Equal(t, 1.0, values["cloudpull_files_failed_total"])
+	assert.Equal(t, 2.0, values["cloudpull_files_skipped_total"])
+	assert.Equal(t, 2048.0, values["cloudpull_bytes_downloaded_total"])
+	assert.Equal(t, 512.0, values["cloudpull_download_speed_bytes"])
+	assert.Equal(t, 3.0, values["cloudpull_active_downloads"])
+	assert.Equal(t, 7.0, values["cloudpull_queued_downloads"])
+	assert.Equal(t, 40.0, values["cloudpull_schedule_backlog"])
+	assert.Equal(t, 5.0, values["cloudpull_workers"])
+
+	server.UnregisterSession("session-1")
+	families, err = server.Registry().Gather()
+	require.NoError(t, err)
+	assert.Empty(t, families)
+}
+
+func TestServerStartStop(t *testing.T) {
+	server := NewServer("127.0.0.1:0", logger.New(&logger.Config{Level: "error", Output: io.Discard}))
+	server.RegisterSession("session-1", &fakeSource{
+		progress: &cloudsync.ProgressStats{CompletedFiles: 3},
+	})
+	require.NoError(t, server.Start())
+
+	resp, err := http.Get("http://" + server.Addr() + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.Contains(string(body), `cloudpull_files_completed_total{session_id="session-1"} 3`))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, server.Stop(ctx))
+}
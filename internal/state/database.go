@@ -20,6 +20,7 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +31,86 @@ import (
 //go:embed schema.sql
 var schemaFS embed.FS
 
+// columnMigration adds a column introduced after a table was first created.
+// Indexes on the column are created afterwards, since schema.sql runs before
+// older tables have the column.
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+	index      string
+}
+
+// columnMigrations lists columns that CREATE TABLE IF NOT EXISTS does not
+// add to databases created by older versions.
+var columnMigrations = []columnMigration{
+	{table: "sessions", column: "flatten", definition: "BOOLEAN DEFAULT FALSE"},
+	{table: "sessions", column: "heartbeat_at", definition: "TIMESTAMP"},
+	{
+		table:      "files",
+		column:     "local_path",
+		definition: "TEXT",
+		index:      "CREATE INDEX IF NOT EXISTS idx_files_local_path ON files(session_id, local_path COLLATE NOCASE)",
+	},
+	{table: "files", column: "local_md5", definition: "TEXT"},
+	{table: "files", column: "local_sha256", definition: "TEXT"},
+	{
+		table:      "error_log",
+		column:     "trace_id",
+		definition: "TEXT",
+		index:      "CREATE INDEX IF NOT EXISTS idx_errors_trace_id ON error_log(trace_id)",
+	},
+	{table: "files", column: "drive_created_time", definition: "TIMESTAMP"},
+	{table: "files", column: "owner_email", definition: "TEXT"},
+	{table: "files", column: "cas_path", definition: "TEXT"},
+	{table: "files", column: "conflict_decision", definition: "TEXT"},
+	{table: "files", column: "completed_at", definition: "TIMESTAMP"},
+}
+
+// constraintMigration rewrites a CHECK constraint of a table created by an
+// older version. Only constraints that accept more values than before may be
+// migrated this way, since existing rows are not checked again.
+type constraintMigration struct {
+	table string
+	from  string
+	to    string
+}
+
+// constraintMigrations lists CHECK constraints that CREATE TABLE IF NOT
+// EXISTS does not update in databases created by older versions.
+var constraintMigrations = []constraintMigration{
+	{
+		table: "sessions",
+		from:  "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled'))",
+		to:    "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota'))",
+	},
+	{
+		table: "sessions",
+		from:  "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota'))",
+		to:    "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned'))",
+	},
+	{
+		table: "sessions",
+		from:  "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned'))",
+		to:    "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned', 'completed_with_errors'))",
+	},
+	{
+		table: "sessions",
+		from:  "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned', 'completed_with_errors'))",
+		to:    "CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned', 'completed_with_errors', 'stalled'))",
+	},
+	{
+		table: "files",
+		from:  "CHECK (status IN ('pending', 'downloading', 'completed', 'failed', 'skipped'))",
+		to:    "CHECK (status IN ('pending', 'queued', 'downloading', 'completed', 'failed', 'skipped'))",
+	},
+	{
+		table: "folders",
+		from:  "CHECK (status IN ('pending', 'scanning', 'scanned', 'failed'))",
+		to:    "CHECK (status IN ('pending', 'scanning', 'scanned', 'failed', 'skipped'))",
+	},
+}
+
 // DB represents the database connection manager.
 type DB struct {
 	*sqlx.DB
@@ -123,6 +204,18 @@ func (db *DB) InitSchema(ctx context.Context) error {
 		return fmt.Errorf("failed to execute schema: %w", err)
 	}
 
+	for _, migration := range columnMigrations {
+		if err := ensureColumn(ctx, tx, migration); err != nil {
+			return err
+		}
+	}
+
+	for _, migration := range constraintMigrations {
+		if err := ensureConstraint(ctx, tx, migration); err != nil {
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit schema: %w", err)
 	}
@@ -130,6 +223,89 @@ func (db *DB) InitSchema(ctx context.Context) error {
 	return nil
 }
 
+// ensureColumn adds the migration's column if the table lacks it and
+// creates its index.
+func ensureColumn(ctx context.Context, tx *sqlx.Tx, migration columnMigration) error {
+	var columns []struct {
+		CID        int            `db:"cid"`
+		Name       string         `db:"name"`
+		Type       string         `db:"type"`
+		NotNull    bool           `db:"notnull"`
+		Default    sql.NullString `db:"dflt_value"`
+		PrimaryKey int            `db:"pk"`
+	}
+	if err := tx.SelectContext(ctx, &columns, fmt.Sprintf("PRAGMA table_info(%s)", migration.table)); err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", migration.table, err)
+	}
+
+	exists := false
+	for _, column := range columns {
+		if column.Name == migration.column {
+			exists = true
+			break
+		}
+	}
+
+	if !exists {
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", migration.table, migration.column, migration.definition)
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", migration.table, migration.column, err)
+		}
+	}
+
+	if migration.index != "" {
+		if _, err := tx.ExecContext(ctx, migration.index); err != nil {
+			return fmt.Errorf("failed to index column %s.%s: %w", migration.table, migration.column, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureConstraint replaces an outdated CHECK constraint in the stored table
+// definition. SQLite cannot alter constraints, but widening one needs no
+// change to the stored rows, so the schema text is edited directly as
+// described in https://www.sqlite.org/lang_altertable.html.
+func ensureConstraint(ctx context.Context, tx *sqlx.Tx, migration constraintMigration) error {
+	var definition string
+	err := tx.GetContext(ctx, &definition,
+		"SELECT sql FROM sqlite_master WHERE type = 'table' AND name = $1", migration.table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", migration.table, err)
+	}
+
+	if !strings.Contains(definition, migration.from) {
+		return nil
+	}
+
+	var version int
+	if err := tx.GetContext(ctx, &version, "PRAGMA schema_version"); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "PRAGMA writable_schema = ON"); err != nil {
+		return fmt.Errorf("failed to enable schema changes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sqlite_master SET sql = replace(sql, $1, $2) WHERE type = 'table' AND name = $3",
+		migration.from, migration.to, migration.table)
+	if err != nil {
+		return fmt.Errorf("failed to update constraint of table %s: %w", migration.table, err)
+	}
+
+	// A new schema version makes every connection reload the definition
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA schema_version = %d", version+1)); err != nil {
+		return fmt.Errorf("failed to update schema version: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "PRAGMA writable_schema = OFF"); err != nil {
+		return fmt.Errorf("failed to disable schema changes: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes the database connection.
 func (db *DB) Close() error {
 	db.mu.Lock()
@@ -187,12 +363,72 @@ func (db *DB) WithReadTx(ctx context.Context, fn func(*sqlx.Tx) error) error {
 	return nil
 }
 
-// Vacuum performs database maintenance.
+// Vacuum rebuilds the database file, returning the pages freed by deleted
+// rows to the filesystem. It locks the database while it runs. In-memory
+// databases are skipped.
 func (db *DB) Vacuum(ctx context.Context) error {
+	if db.InMemory() {
+		return nil
+	}
 	_, err := db.ExecContext(ctx, "VACUUM")
 	return err
 }
 
+// Optimize refreshes the query planner statistics with PRAGMA optimize.
+// In-memory databases are skipped.
+func (db *DB) Optimize(ctx context.Context) error {
+	if db.InMemory() {
+		return nil
+	}
+	_, err := db.ExecContext(ctx, "PRAGMA optimize")
+	return err
+}
+
+// InMemory reports whether the database lives in memory only.
+func (db *DB) InMemory() bool {
+	return db.path == ":memory:" || strings.HasPrefix(db.path, "file::memory:") ||
+		strings.Contains(db.path, "mode=memory")
+}
+
+// SpaceStats describes how the database file is used.
+type SpaceStats struct {
+	PageSize  int64 `json:"page_size"`
+	PageCount int64 `json:"page_count"`
+
+	// FreePages are pages left unused by deleted rows; VACUUM returns them
+	// to the filesystem
+	FreePages int64 `json:"free_pages"`
+}
+
+// Size returns the size of the database in bytes.
+func (s *SpaceStats) Size() int64 {
+	return s.PageSize * s.PageCount
+}
+
+// FreeBytes returns the bytes held by free pages.
+func (s *SpaceStats) FreeBytes() int64 {
+	return s.PageSize * s.FreePages
+}
+
+// SpaceStats reports the page usage of the database file.
+func (db *DB) SpaceStats(ctx context.Context) (*SpaceStats, error) {
+	stats := &SpaceStats{}
+	pragmas := []struct {
+		name  string
+		value *int64
+	}{
+		{"page_size", &stats.PageSize},
+		{"page_count", &stats.PageCount},
+		{"freelist_count", &stats.FreePages},
+	}
+	for _, pragma := range pragmas {
+		if err := db.GetContext(ctx, pragma.value, "PRAGMA "+pragma.name); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma.name, err)
+		}
+	}
+	return stats, nil
+}
+
 // Stats returns database statistics.
 func (db *DB) Stats() sql.DBStats {
 	return db.DB.Stats()
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitSchemaAddsMissingColumns(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cloudpull.db")
+
+	// Create a database with the schema from before the columns existed
+	schema, err := schemaFS.ReadFile("schema.sql")
+	require.NoError(t, err)
+	oldSchema := string(schema)
+	for _, migration := range columnMigrations {
+		line := "    " + migration.column + " " + migration.definition + ",\n"
+		require.Contains(t, oldSchema, line)
+		oldSchema = strings.Replace(oldSchema, line, "", 1)
+	}
+	// Undo constraint migrations newest first, as later ones build on earlier ones
+	for i := len(constraintMigrations) - 1; i >= 0; i-- {
+		migration := constraintMigrations[i]
+		require.Contains(t, oldSchema, migration.to)
+		oldSchema = strings.Replace(oldSchema, migration.to, migration.from, 1)
+	}
+
+	raw, err := sqlx.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = raw.ExecContext(ctx, oldSchema)
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+
+	cfg := DefaultConfig()
+	cfg.Path = path
+	m, err := NewManager(cfg)
+	require.NoError(t, err)
+	defer m.Close()
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	session.Flatten = true
+	require.NoError(t, m.UpdateSession(ctx, session))
+
+	reloaded, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, reloaded.Flatten)
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	file := createTestFile(t, m, root, "a.txt", 10, 0)
+	reserved, err := m.Files().ReserveLocalPath(ctx, file.ID, session.ID, "/tmp/dest/a.txt")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Statuses added after the table was created are accepted
+	require.NoError(t, m.Files().MarkQueued(ctx, []string{file.ID}))
+	reloadedFile, err := m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusQueued, reloadedFile.Status)
+
+	// Opening the migrated database again leaves it unchanged
+	require.NoError(t, m.Close())
+	m, err = NewManager(cfg)
+	require.NoError(t, err)
+	reloadedFile, err = m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusQueued, reloadedFile.Status)
+}
+
+func TestReserveLocalPath(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	first := createTestFile(t, m, root, "a.txt", 10, 0)
+	second := createTestFile(t, m, root, "A.txt", 10, 0)
+
+	reserved, err := m.Files().ReserveLocalPath(ctx, first.ID, session.ID, "/tmp/dest/a.txt")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Reserving again for the same file is allowed
+	reserved, err = m.Files().ReserveLocalPath(ctx, first.ID, session.ID, "/tmp/dest/a.txt")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Names differing only in case collide
+	reserved, err = m.Files().ReserveLocalPath(ctx, second.ID, session.ID, "/tmp/dest/A.txt")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	reloaded, err := m.Files().Get(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/dest/a.txt", reloaded.LocalPath.String)
+}
+
+func TestVacuumAfterCleanupReclaimsFreePages(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	files := make([]*File, 0, 500)
+	for i := 0; i < 500; i++ {
+		files = append(files, &File{
+			DriveID:   fmt.Sprintf("drive-%d", i),
+			FolderID:  root.ID,
+			SessionID: session.ID,
+			Name:      fmt.Sprintf("%s-%d.txt", strings.Repeat("x", 200), i),
+			Path:      fmt.Sprintf("root/%d.txt", i),
+			Size:      10,
+			Status:    FileStatusCompleted,
+		})
+	}
+	require.NoError(t, m.CreateFiles(ctx, files))
+	require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, SessionStatusCompleted))
+
+	deleted, err := m.Queries().CleanupOldSessions(ctx, -time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	before, err := m.SpaceStats(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, before.FreePages)
+
+	require.NoError(t, m.Vacuum(ctx))
+	require.NoError(t, m.Optimize(ctx))
+
+	after, err := m.SpaceStats(ctx)
+	require.NoError(t, err)
+	assert.Less(t, after.FreePages, before.FreePages)
+	assert.Less(t, after.Size(), before.Size())
+}
+
+func TestMaintenanceSkipsInMemoryDatabase(t *testing.T) {
+	// Nothing would be reclaimed, and the statements are never run
+	db := &DB{path: ":memory:"}
+	assert.True(t, db.InMemory())
+	assert.NoError(t, db.Vacuum(context.Background()))
+	assert.NoError(t, db.Optimize(context.Background()))
+
+	assert.True(t, (&DB{path: "file::memory:?cache=shared"}).InMemory())
+	assert.False(t, (&DB{path: "/tmp/cloudpull.db"}).InMemory())
+}
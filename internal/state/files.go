@@ -40,12 +40,12 @@ func (s *FileStore) Create(ctx context.Context, file *File) error {
       drive_id, folder_id, session_id, name, path, size,
       md5_checksum, mime_type, is_google_doc, export_mime_type,
       status, bytes_downloaded, download_attempts, error_message,
-      drive_modified_time, local_modified_time
+      drive_modified_time, local_modified_time, drive_created_time, owner_email
     ) VALUES (
       :drive_id, :folder_id, :session_id, :name, :path, :size,
       :md5_checksum, :mime_type, :is_google_doc, :export_mime_type,
       :status, :bytes_downloaded, :download_attempts, :error_message,
-      :drive_modified_time, :local_modified_time
+      :drive_modified_time, :local_modified_time, :drive_created_time, :owner_email
     ) RETURNING id, created_at, updated_at`
 
 	stmt, err := s.db.PrepareNamedContext(ctx, query)
@@ -77,11 +77,13 @@ func (s *FileStore) CreateBatch(ctx context.Context, files []*File) error {
       INSERT INTO files (
         drive_id, folder_id, session_id, name, path, size,
         md5_checksum, mime_type, is_google_doc, export_mime_type,
-        status, drive_modified_time
+        status, error_message, drive_modified_time, drive_created_time,
+        owner_email
       ) VALUES (
         :drive_id, :folder_id, :session_id, :name, :path, :size,
         :md5_checksum, :mime_type, :is_google_doc, :export_mime_type,
-        :status, :drive_modified_time
+        :status, :error_message, :drive_modified_time, :drive_created_time,
+        :owner_email
       ) RETURNING id, created_at, updated_at`
 
 		stmt, err := tx.PrepareNamedContext(ctx, query)
@@ -137,6 +139,33 @@ func (s *FileStore) GetByDriveID(ctx context.Context, driveID, sessionID string)
 	return &file, nil
 }
 
+// AddAlias records a further path of a file. Recording the same path again
+// has no effect.
+func (s *FileStore) AddAlias(ctx context.Context, alias *FileAlias) error {
+	query := `
+    INSERT OR IGNORE INTO file_aliases (session_id, drive_id, path)
+    VALUES ($1, $2, $3)`
+
+	if _, err := s.db.ExecContext(ctx, query, alias.SessionID, alias.DriveID, alias.Path); err != nil {
+		return fmt.Errorf("failed to add file alias: %w", err)
+	}
+
+	return nil
+}
+
+// GetAliases retrieves the further paths recorded for a file, in the order
+// they were found.
+func (s *FileStore) GetAliases(ctx context.Context, sessionID, driveID string) ([]*FileAlias, error) {
+	var aliases []*FileAlias
+	query := `SELECT * FROM file_aliases WHERE session_id = $1 AND drive_id = $2 ORDER BY id`
+
+	if err := s.db.SelectContext(ctx, &aliases, query, sessionID, driveID); err != nil {
+		return nil, fmt.Errorf("failed to get file aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
 // GetByFolder retrieves files in a folder.
 func (s *FileStore) GetByFolder(ctx context.Context, folderID string) ([]*File, error) {
 	var files []*File
@@ -211,7 +240,14 @@ func (s *FileStore) Update(ctx context.Context, file *File) error {
       download_attempts = :download_attempts,
       error_message = :error_message,
       drive_modified_time = :drive_modified_time,
-      local_modified_time = :local_modified_time
+      local_modified_time = :local_modified_time,
+      local_path = :local_path,
+      local_md5 = :local_md5,
+      local_sha256 = :local_sha256,
+      drive_created_time = :drive_created_time,
+      owner_email = :owner_email,
+      cas_path = :cas_path,
+      conflict_decision = :conflict_decision
     WHERE id = :id`
 
 	result, err := s.db.NamedExecContext(ctx, query, file)
@@ -231,11 +267,21 @@ func (s *FileStore) Update(ctx context.Context, file *File) error {
 	return nil
 }
 
-// UpdateStatus updates the file status.
+// completedAt returns the completion time recorded with status: now for
+// completed files and none otherwise, which keeps the previous time.
+func completedAt(status string) sql.NullTime {
+	if status != FileStatusCompleted {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: time.Now().UTC(), Valid: true}
+}
+
+// UpdateStatus updates the file status. Completed files record when they
+// were completed.
 func (s *FileStore) UpdateStatus(ctx context.Context, id, status string) error {
-	query := `UPDATE files SET status = $1 WHERE id = $2`
+	query := `UPDATE files SET status = $1, completed_at = COALESCE($2, completed_at) WHERE id = $3`
 
-	result, err := s.db.ExecContext(ctx, query, status, id)
+	result, err := s.db.ExecContext(ctx, query, status, completedAt(status), id)
 	if err != nil {
 		return fmt.Errorf("failed to update file status: %w", err)
 	}
@@ -252,6 +298,174 @@ func (s *FileStore) UpdateStatus(ctx context.Context, id, status string) error {
 	return nil
 }
 
+// UpdateStatusBatch updates the status of multiple files in a single
+// transaction. Completed files record when they were completed.
+func (s *FileStore) UpdateStatusBatch(ctx context.Context, ids []string, status string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, "UPDATE files SET status = $1, completed_at = COALESCE($2, completed_at) WHERE id = $3")
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		completed := completedAt(status)
+		for _, id := range ids {
+			result, err := stmt.ExecContext(ctx, status, completed, id)
+			if err != nil {
+				return fmt.Errorf("failed to update file %s: %w", id, err)
+			}
+
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to get rows affected for file %s: %w", id, err)
+			}
+
+			if rows == 0 {
+				return fmt.Errorf("file not found: %s", id)
+			}
+		}
+
+		return nil
+	})
+}
+
+// ReserveLocalPath records localPath as the file's download location unless
+// another file in the session already uses it. Paths are compared without
+// regard to case so names stay distinct on case-insensitive filesystems.
+// It reports whether the path was reserved.
+func (s *FileStore) ReserveLocalPath(ctx context.Context, id, sessionID, localPath string) (bool, error) {
+	query := `
+    UPDATE files
+    SET local_path = $1
+    WHERE id = $2 AND NOT EXISTS (
+      SELECT 1 FROM files
+      WHERE session_id = $3 AND local_path = $1 COLLATE NOCASE AND id != $2
+    )`
+
+	result, err := s.db.ExecContext(ctx, query, localPath, id, sessionID)
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve local path: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// SetLocalChecksums records the checksums computed from the downloaded
+// file. Empty values are stored as NULL.
+func (s *FileStore) SetLocalChecksums(ctx context.Context, id, md5, sha256 string) error {
+	query := `UPDATE files SET local_md5 = $1, local_sha256 = $2 WHERE id = $3`
+
+	result, err := s.db.ExecContext(ctx, query, NewNullString(md5), NewNullString(sha256), id)
+	if err != nil {
+		return fmt.Errorf("failed to set local checksums: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("file not found: %s", id)
+	}
+
+	return nil
+}
+
+// SetConflictDecision records what the conflict policy did with a local
+// file that differed from the one the file's last download wrote.
+func (s *FileStore) SetConflictDecision(ctx context.Context, id, decision string) error {
+	query := `UPDATE files SET conflict_decision = $1 WHERE id = $2`
+
+	result, err := s.db.ExecContext(ctx, query, NewNullString(decision), id)
+	if err != nil {
+		return fmt.Errorf("failed to set conflict decision: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("file not found: %s", id)
+	}
+
+	return nil
+}
+
+// GetPreviousDownloads retrieves the records of a Drive file that hold
+// local checksums or a completion time from its downloads into
+// destinationPath, in any session, most recent first.
+func (s *FileStore) GetPreviousDownloads(ctx context.Context, driveID, destinationPath string) ([]*File, error) {
+	var files []*File
+	query := `
+    SELECT f.* FROM files f
+    JOIN sessions s ON s.id = f.session_id
+    WHERE f.drive_id = $1 AND s.destination_path = $2
+      AND (f.local_md5 IS NOT NULL OR f.local_sha256 IS NOT NULL OR f.local_modified_time IS NOT NULL)
+    ORDER BY f.updated_at DESC, f.rowid DESC`
+
+	if err := s.db.SelectContext(ctx, &files, query, driveID, destinationPath); err != nil {
+		return nil, fmt.Errorf("failed to get previous downloads: %w", err)
+	}
+
+	return files, nil
+}
+
+// SetCASPath records the content-addressed blob of a file and the logical
+// path linked to it.
+func (s *FileStore) SetCASPath(ctx context.Context, id, localPath, casPath string) error {
+	query := `UPDATE files SET local_path = $1, cas_path = $2 WHERE id = $3`
+
+	result, err := s.db.ExecContext(ctx, query, NewNullString(localPath), NewNullString(casPath), id)
+	if err != nil {
+		return fmt.Errorf("failed to set cas path: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("file not found: %s", id)
+	}
+
+	return nil
+}
+
+// UpdateSize records the size of a file learned by downloading it, for
+// files Drive listed without one.
+func (s *FileStore) UpdateSize(ctx context.Context, id string, size int64) error {
+	query := `UPDATE files SET size = $1 WHERE id = $2`
+
+	result, err := s.db.ExecContext(ctx, query, size, id)
+	if err != nil {
+		return fmt.Errorf("failed to update file size: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return fmt.Errorf("file not found: %s", id)
+	}
+
+	return nil
+}
+
 // UpdateProgress updates file download progress.
 func (s *FileStore) UpdateProgress(ctx context.Context, id string, bytesDownloaded int64) error {
 	query := `
@@ -276,6 +490,128 @@ func (s *FileStore) UpdateProgress(ctx context.Context, id string, bytesDownload
 	return nil
 }
 
+// MarkQueued marks pending files as handed to the download queue. Files in
+// any other status are left unchanged.
+func (s *FileStore) MarkQueued(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		stmt, err := tx.PreparexContext(ctx, "UPDATE files SET status = $1 WHERE id = $2 AND status = $3")
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, id := range ids {
+			if _, err := stmt.ExecContext(ctx, FileStatusQueued, id, FileStatusPending); err != nil {
+				return fmt.Errorf("failed to mark file %s as queued: %w", id, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// SkipPending marks every pending file of a session as skipped for
+// reason. It returns the number of files skipped.
+func (s *FileStore) SkipPending(ctx context.Context, sessionID, reason string) (int64, error) {
+	query := `
+    UPDATE files
+    SET status = $1, error_message = $2
+    WHERE session_id = $3
+      AND status = $4`
+
+	result, err := s.db.ExecContext(ctx, query, FileStatusSkipped, reason, sessionID, FileStatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to skip pending files: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// ResetInterrupted returns queued and downloading files of a session to
+// pending. Files in those states belonged to a run that has ended, so their
+// in-memory queue entries no longer exist. Downloaded bytes are kept so
+// partial downloads still resume.
+func (s *FileStore) ResetInterrupted(ctx context.Context, sessionID string) (int64, error) {
+	query := `
+    UPDATE files
+    SET status = $1
+    WHERE session_id = $2
+      AND status IN ($3, $4)`
+
+	result, err := s.db.ExecContext(ctx, query, FileStatusPending, sessionID, FileStatusQueued, FileStatusDownloading)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset interrupted files: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
+// RequeueModified returns a completed file that changed in Drive to
+// pending with its new size, checksum and modification time, so it is
+// downloaded again. It reports whether the file was requeued; files in any
+// other status are left unchanged.
+func (s *FileStore) RequeueModified(ctx context.Context, id string, size int64, md5 string, modifiedTime time.Time) (bool, error) {
+	query := `
+    UPDATE files
+    SET status = $1, bytes_downloaded = 0, size = $2, md5_checksum = $3, drive_modified_time = $4
+    WHERE id = $5 AND status = $6`
+
+	result, err := s.db.ExecContext(ctx, query, FileStatusPending, size, NewNullString(md5), modifiedTime,
+		id, FileStatusCompleted)
+	if err != nil {
+		return false, fmt.Errorf("failed to requeue modified file: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ResetDownload returns a file to pending with no bytes downloaded and
+// forgets its download journal, so its next attempt starts from the beginning.
+func (s *FileStore) ResetDownload(ctx context.Context, id string) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		query := `UPDATE files SET status = $1, bytes_downloaded = 0 WHERE id = $2`
+
+		result, err := tx.ExecContext(ctx, query, FileStatusPending, id)
+		if err != nil {
+			return fmt.Errorf("failed to reset download: %w", err)
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+
+		if rows == 0 {
+			return fmt.Errorf("file not found: %s", id)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM download_journal WHERE file_id = $1`, id); err != nil {
+			return fmt.Errorf("failed to delete download journal: %w", err)
+		}
+
+		return nil
+	})
+}
+
 // MarkAsDownloading marks a file as downloading.
 func (s *FileStore) MarkAsDownloading(ctx context.Context, id string) error {
 	query := `
@@ -304,10 +640,10 @@ func (s *FileStore) MarkAsDownloading(ctx context.Context, id string) error {
 func (s *FileStore) MarkAsCompleted(ctx context.Context, id string, localModTime time.Time) error {
 	query := `
     UPDATE files
-    SET status = $1, bytes_downloaded = size, local_modified_time = $2
-    WHERE id = $3`
+    SET status = $1, bytes_downloaded = size, local_modified_time = $2, completed_at = $3
+    WHERE id = $4`
 
-	result, err := s.db.ExecContext(ctx, query, FileStatusCompleted, localModTime, id)
+	result, err := s.db.ExecContext(ctx, query, FileStatusCompleted, localModTime, completedAt(FileStatusCompleted), id)
 	if err != nil {
 		return fmt.Errorf("failed to mark file as completed: %w", err)
 	}
@@ -404,6 +740,7 @@ func (s *FileStore) GetStats(ctx context.Context, sessionID string) (*FileStats,
       COALESCE(SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END), 0) as failed_count,
       COALESCE(SUM(CASE WHEN status = 'skipped' THEN 1 ELSE 0 END), 0) as skipped_count,
       COALESCE(SUM(CASE WHEN status = 'pending' THEN 1 ELSE 0 END), 0) as pending_count,
+      COALESCE(SUM(CASE WHEN status = 'queued' THEN 1 ELSE 0 END), 0) as queued_count,
       COALESCE(SUM(CASE WHEN status = 'downloading' THEN 1 ELSE 0 END), 0) as downloading_count
     FROM files
     WHERE session_id = $1`
@@ -495,6 +832,69 @@ func (s *FileStore) WithTx(tx *sqlx.Tx) *FileStore {
 	}
 }
 
+// RecordFlushedOffset stores the offset up to which a partial download
+// was synced to disk, and records it as the bytes downloaded of the file.
+func (s *FileStore) RecordFlushedOffset(ctx context.Context, journal *DownloadJournal) error {
+	return s.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		query := `
+      INSERT INTO download_journal (file_id, temp_path, flushed_bytes, size, md5_checksum, updated_at)
+      VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP)
+      ON CONFLICT (file_id) DO UPDATE SET
+        temp_path = excluded.temp_path,
+        flushed_bytes = excluded.flushed_bytes,
+        size = excluded.size,
+        md5_checksum = excluded.md5_checksum,
+        updated_at = CURRENT_TIMESTAMP`
+
+		if _, err := tx.ExecContext(ctx, query, journal.FileID, journal.TempPath,
+			journal.FlushedBytes, journal.Size, journal.MD5Checksum); err != nil {
+			return fmt.Errorf("failed to record flushed offset: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE files SET bytes_downloaded = $1 WHERE id = $2`,
+			journal.FlushedBytes, journal.FileID); err != nil {
+			return fmt.Errorf("failed to update file progress: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// GetJournal retrieves the download journal of a file, or nil if the file
+// has no partial download on record.
+func (s *FileStore) GetJournal(ctx context.Context, fileID string) (*DownloadJournal, error) {
+	var journal DownloadJournal
+	err := s.db.GetContext(ctx, &journal, `SELECT * FROM download_journal WHERE file_id = $1`, fileID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get download journal: %w", err)
+	}
+
+	return &journal, nil
+}
+
+// DeleteJournal removes the download journal of a file.
+func (s *FileStore) DeleteJournal(ctx context.Context, fileID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM download_journal WHERE file_id = $1`, fileID); err != nil {
+		return fmt.Errorf("failed to delete download journal: %w", err)
+	}
+
+	return nil
+}
+
+// JournaledTempPaths returns the temp files of every journaled partial
+// download.
+func (s *FileStore) JournaledTempPaths(ctx context.Context) ([]string, error) {
+	var paths []string
+	if err := s.db.SelectContext(ctx, &paths, `SELECT temp_path FROM download_journal`); err != nil {
+		return nil, fmt.Errorf("failed to get journaled temp files: %w", err)
+	}
+
+	return paths, nil
+}
+
 // CreateChunks creates download chunks for a file.
 func (s *FileStore) CreateChunks(ctx context.Context, fileID string, chunkSize int64) error {
 	// Get file size
@@ -282,6 +282,30 @@ func (s *FolderStore) UpdateStatusBatch(ctx context.Context, ids []string, statu
 	})
 }
 
+// SkipUnscanned marks the folders of a session that are not fully listed
+// as skipped, so resuming the session does not list them. It returns the
+// number of folders skipped.
+func (s *FolderStore) SkipUnscanned(ctx context.Context, sessionID string) (int64, error) {
+	query := `
+    UPDATE folders
+    SET status = $1
+    WHERE session_id = $2
+      AND status IN ($3, $4, $5)`
+
+	result, err := s.db.ExecContext(ctx, query, FolderStatusSkipped, sessionID,
+		FolderStatusPending, FolderStatusScanning, FolderStatusFailed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to skip unscanned folders: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rows, nil
+}
+
 // MarkAsScanning marks a folder as being scanned.
 func (s *FolderStore) MarkAsScanning(ctx context.Context, id string) error {
 	return s.UpdateStatus(ctx, id, FolderStatusScanning)
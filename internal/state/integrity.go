@@ -0,0 +1,167 @@
+/**
+ * State Database Integrity Checks for CloudPull
+ *
+ * Features:
+ * - Files whose folder record is missing
+ * - Files and folders whose session no longer exists
+ * - Downloads recorded as in progress without a temp file
+ * - Sessions still run by a sync process are not repaired
+ * - Optional repair of the inconsistent rows
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// IntegrityOptions configures CheckIntegrity.
+type IntegrityOptions struct {
+	// TempPath returns where the partial download of a file is kept. Stale
+	// downloads are only detected when it is set.
+	TempPath func(file *File) string
+
+	// Repair fixes the inconsistent rows after they were found
+	Repair bool
+}
+
+// IntegrityReport lists the inconsistencies found in the state database.
+type IntegrityReport struct {
+	SessionID string
+
+	// OrphanedFiles reference a folder that does not exist
+	OrphanedFiles []*File
+
+	// StaleDownloads are downloading files whose partial download is gone;
+	// they are not looked for while the session is running
+	StaleDownloads []*File
+
+	// SessionlessFiles and SessionlessFolders belong to no existing session;
+	// they are counted across the whole database
+	SessionlessFiles   int64
+	SessionlessFolders int64
+
+	// Running is set when a sync process, this one or another, is running
+	// the session
+	Running bool
+
+	// Repaired is set when the rows above were fixed
+	Repaired bool
+}
+
+// OK reports whether no inconsistency was found.
+func (r *IntegrityReport) OK() bool {
+	return len(r.OrphanedFiles) == 0 && len(r.StaleDownloads) == 0 &&
+		r.SessionlessFiles == 0 && r.SessionlessFolders == 0
+}
+
+// CheckIntegrity looks for rows of a session that the sync engine cannot
+// handle, and rows that belong to no session at all. With opts.Repair set,
+// orphaned and sessionless rows are deleted and stale downloads start over
+// as pending; a session a sync process is running is refused.
+func (m *Manager) CheckIntegrity(ctx context.Context, sessionID string, opts *IntegrityOptions) (*IntegrityReport, error) {
+	if opts == nil {
+		opts = &IntegrityOptions{}
+	}
+
+	session, err := m.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	report := &IntegrityReport{SessionID: sessionID, Running: session.IsRunning()}
+	if report.Running && opts.Repair {
+		return nil, fmt.Errorf("session %s is being synced; stop it before repairing", sessionID)
+	}
+
+	orphaned := `
+    SELECT * FROM files
+    WHERE session_id = $1
+      AND folder_id NOT IN (SELECT id FROM folders)
+    ORDER BY path`
+	if err := m.db.SelectContext(ctx, &report.OrphanedFiles, orphaned, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to find orphaned files: %w", err)
+	}
+
+	// A running sync marks files downloading before creating their temp
+	// files; queued files have none yet
+	if opts.TempPath != nil && !report.Running {
+		var inProgress []*File
+		query := `SELECT * FROM files WHERE session_id = $1 AND status = $2 ORDER BY path`
+		if err := m.db.SelectContext(ctx, &inProgress, query, sessionID, FileStatusDownloading); err != nil {
+			return nil, fmt.Errorf("failed to get in-progress files: %w", err)
+		}
+
+		for _, file := range inProgress {
+			if _, err := os.Stat(opts.TempPath(file)); os.IsNotExist(err) {
+				report.StaleDownloads = append(report.StaleDownloads, file)
+			}
+		}
+	}
+
+	counts := []struct {
+		dest  *int64
+		table string
+	}{
+		{&report.SessionlessFiles, "files"},
+		{&report.SessionlessFolders, "folders"},
+	}
+	for _, c := range counts {
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE session_id NOT IN (SELECT id FROM sessions)", c.table)
+		if err := m.db.GetContext(ctx, c.dest, query); err != nil {
+			return nil, fmt.Errorf("failed to count sessionless %s: %w", c.table, err)
+		}
+	}
+
+	if !opts.Repair || report.OK() {
+		return report, nil
+	}
+
+	if err := m.repairIntegrity(ctx, report); err != nil {
+		return report, err
+	}
+	report.Repaired = true
+
+	return report, nil
+}
+
+// repairIntegrity fixes the rows listed in report in one transaction.
+func (m *Manager) repairIntegrity(ctx context.Context, report *IntegrityReport) error {
+	return m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		// Without a folder the walker never finds these files again
+		for _, file := range report.OrphanedFiles {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM files WHERE id = $1`, file.ID); err != nil {
+				return fmt.Errorf("failed to delete orphaned file %s: %w", file.ID, err)
+			}
+		}
+
+		// Downloaded bytes are gone with the temp file
+		reset := `
+      UPDATE files
+      SET status = $1, bytes_downloaded = 0
+      WHERE id = $2`
+		for _, file := range report.StaleDownloads {
+			if _, err := tx.ExecContext(ctx, reset, FileStatusPending, file.ID); err != nil {
+				return fmt.Errorf("failed to reset download of %s: %w", file.ID, err)
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM download_chunks WHERE file_id = $1`, file.ID); err != nil {
+				return fmt.Errorf("failed to reset chunks of %s: %w", file.ID, err)
+			}
+		}
+
+		for _, table := range []string{"files", "folders"} {
+			query := fmt.Sprintf("DELETE FROM %s WHERE session_id NOT IN (SELECT id FROM sessions)", table)
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to delete sessionless %s: %w", table, err)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIntegrityFindsAndRepairsInconsistentRows(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	lost := createTestFolder(t, m, session.ID, "lost", root)
+	healthy := createTestFile(t, m, root, "healthy.txt", 10, 0)
+	orphan := createTestFile(t, m, lost, "orphan.txt", 10, 0)
+	partial := createTestFile(t, m, root, "partial.bin", 100, 40)
+	stale := createTestFile(t, m, root, "stale.bin", 100, 60)
+	for _, file := range []*File{partial, stale} {
+		file.Status = FileStatusDownloading
+		require.NoError(t, m.Files().Update(ctx, file))
+	}
+	queued := createTestFile(t, m, root, "queued.bin", 100, 0)
+	require.NoError(t, m.Files().MarkQueued(ctx, []string{queued.ID}))
+
+	gone, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/other")
+	require.NoError(t, err)
+	createTestFile(t, m, createTestFolder(t, m, gone.ID, "root", nil), "gone.txt", 10, 0)
+
+	// Rows like these are left behind when foreign keys were not enforced
+	conn, err := m.db.Connx(ctx)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `DELETE FROM folders WHERE id = $1`, lost.ID)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, gone.ID)
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	tempDir := t.TempDir()
+	tempPath := func(file *File) string { return filepath.Join(tempDir, file.ID) }
+	require.NoError(t, os.WriteFile(tempPath(partial), make([]byte, 40), 0600))
+
+	report, err := m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath})
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.False(t, report.Repaired)
+	require.Len(t, report.OrphanedFiles, 1)
+	assert.Equal(t, orphan.ID, report.OrphanedFiles[0].ID)
+	require.Len(t, report.StaleDownloads, 1)
+	assert.Equal(t, stale.ID, report.StaleDownloads[0].ID)
+	assert.Equal(t, int64(1), report.SessionlessFiles)
+	assert.Equal(t, int64(1), report.SessionlessFolders)
+
+	report, err = m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath, Repair: true})
+	require.NoError(t, err)
+	assert.True(t, report.Repaired)
+
+	files, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	byID := make(map[string]*File)
+	for _, file := range files {
+		byID[file.ID] = file
+	}
+	assert.NotContains(t, byID, orphan.ID)
+	assert.Contains(t, byID, healthy.ID)
+	assert.Equal(t, FileStatusDownloading, byID[partial.ID].Status)
+	assert.Equal(t, FileStatusPending, byID[stale.ID].Status)
+	assert.Zero(t, byID[stale.ID].BytesDownloaded)
+
+	report, err = m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath})
+	require.NoError(t, err)
+	assert.True(t, report.OK())
+}
+
+func TestCheckIntegrityLeavesRunningSessionAlone(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	starting := createTestFile(t, m, root, "starting.bin", 100, 0)
+	starting.Status = FileStatusDownloading
+	require.NoError(t, m.Files().Update(ctx, starting))
+
+	// Another process syncs the session and has not created the temp file yet
+	require.NoError(t, m.Sessions().Heartbeat(ctx, session.ID))
+	tempPath := func(file *File) string { return filepath.Join(t.TempDir(), file.ID) }
+
+	report, err := m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath})
+	require.NoError(t, err)
+	assert.True(t, report.Running)
+	assert.Empty(t, report.StaleDownloads)
+
+	_, err = m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath, Repair: true})
+	assert.Error(t, err)
+
+	// A stopped sync clears its heartbeat
+	require.NoError(t, m.Sessions().ClearHeartbeat(ctx, session.ID))
+	report, err = m.CheckIntegrity(ctx, session.ID, &IntegrityOptions{TempPath: tempPath})
+	require.NoError(t, err)
+	assert.False(t, report.Running)
+	require.Len(t, report.StaleDownloads, 1)
+	assert.Equal(t, starting.ID, report.StaleDownloads[0].ID)
+}
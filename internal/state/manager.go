@@ -19,6 +19,7 @@ import (
 	"database/sql"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -81,9 +82,27 @@ func (m *Manager) Queries() *QueryBuilder {
 	return m.queries
 }
 
-// LogError logs an error to the error_log table.
+// traceIDKey is the context key of the trace ID set by WithTraceID.
+type traceIDKey struct{}
+
+// WithTraceID returns a context whose error log entries are recorded with
+// traceID, which correlates them with the log lines of the same download.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID set by WithTraceID, or an empty
+// string.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// LogError logs an error to the error_log table. The entry carries the
+// trace ID of ctx, if any.
 func (m *Manager) LogError(ctx context.Context, sessionID, itemID, itemType, errorType string, err error) error {
 	var errorCode, errorMessage, stackTrace sql.NullString
+	traceID := NewNullString(TraceIDFromContext(ctx))
 
 	if err != nil {
 		errorMessage = sql.NullString{String: err.Error(), Valid: true}
@@ -97,12 +116,12 @@ func (m *Manager) LogError(ctx context.Context, sessionID, itemID, itemType, err
 	query := `
     INSERT INTO error_log (
       session_id, item_id, item_type, error_type,
-      error_code, error_message, stack_trace, is_retryable
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+      error_code, error_message, stack_trace, is_retryable, trace_id
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
 	_, dbErr := m.db.ExecContext(ctx, query,
 		sessionID, itemID, itemType, errorType,
-		errorCode, errorMessage, stackTrace, true,
+		errorCode, errorMessage, stackTrace, IsRetryableError(err), traceID,
 	)
 
 	if dbErr != nil {
@@ -112,6 +131,256 @@ func (m *Manager) LogError(ctx context.Context, sessionID, itemID, itemType, err
 	return nil
 }
 
+// RecordMirrorDeletion adds an entry to the mirror deletion log.
+func (m *Manager) RecordMirrorDeletion(ctx context.Context, deletion *MirrorDeletion) error {
+	query := `
+    INSERT INTO mirror_deletions (session_id, path, kind, trash_path, dry_run)
+    VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := m.db.ExecContext(ctx, query,
+		deletion.SessionID, deletion.Path, deletion.Kind, deletion.TrashPath, deletion.DryRun,
+	); err != nil {
+		return fmt.Errorf("failed to record mirror deletion: %w", err)
+	}
+
+	return nil
+}
+
+// GetMirrorDeletions retrieves the mirror deletion log of a session in the
+// order the entries were recorded.
+func (m *Manager) GetMirrorDeletions(ctx context.Context, sessionID string) ([]*MirrorDeletion, error) {
+	var deletions []*MirrorDeletion
+	query := `SELECT * FROM mirror_deletions WHERE session_id = $1 ORDER BY id`
+	if err := m.db.SelectContext(ctx, &deletions, query, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get mirror deletions: %w", err)
+	}
+
+	return deletions, nil
+}
+
+// RecordEvents adds events to the event log in one transaction.
+func (m *Manager) RecordEvents(ctx context.Context, events []*Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		stmt, err := tx.PreparexContext(ctx, `
+      INSERT INTO events (session_id, type, item_id, item_path, bytes, message, occurred_at)
+      VALUES ($1, $2, $3, $4, $5, $6, $7)`)
+		if err != nil {
+			return fmt.Errorf("failed to prepare event insert: %w", err)
+		}
+		defer stmt.Close()
+
+		for _, event := range events {
+			if _, err := stmt.ExecContext(ctx,
+				event.SessionID, event.Type, event.ItemID, event.ItemPath,
+				event.Bytes, event.Message, event.OccurredAt,
+			); err != nil {
+				return fmt.Errorf("failed to record event: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// EventFilter selects event log entries. Empty fields match every entry.
+type EventFilter struct {
+	Type   string
+	ItemID string
+
+	// Limit caps the number of entries returned; 0 means no limit
+	Limit int
+}
+
+// GetEvents retrieves the event log of a session in the order the events
+// were recorded.
+func (m *Manager) GetEvents(ctx context.Context, sessionID string, filter *EventFilter) ([]*Event, error) {
+	if filter == nil {
+		filter = &EventFilter{}
+	}
+
+	conditions := []string{"session_id = $1"}
+	args := []interface{}{sessionID}
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if filter.ItemID != "" {
+		args = append(args, filter.ItemID)
+		conditions = append(conditions, fmt.Sprintf("item_id = $%d", len(args)))
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as none
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+    SELECT * FROM events
+    WHERE %s
+    ORDER BY id
+    LIMIT $%d`, strings.Join(conditions, " AND "), len(args))
+
+	var events []*Event
+	if err := m.db.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get events: %w", err)
+	}
+
+	return events, nil
+}
+
+// PruneEvents deletes events recorded more than olderThan ago and returns
+// the number deleted.
+func (m *Manager) PruneEvents(ctx context.Context, olderThan time.Duration) (int64, error) {
+	// Timestamps are stored in UTC by SQLite, so the cutoff is computed there
+	cutoff := fmt.Sprintf("-%d seconds", int64(olderThan.Seconds()))
+	result, err := m.db.ExecContext(ctx,
+		`DELETE FROM events WHERE created_at < datetime('now', $1)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// ArchiveCompletedSessions summarizes completed sessions created more than
+// olderThan ago into the session_archive table and deletes their file and
+// folder rows, keeping the session itself. Sessions archived before are
+// skipped. It returns the number of sessions archived.
+func (m *Manager) ArchiveCompletedSessions(ctx context.Context, olderThan time.Duration) (int, error) {
+	var sessionIDs []string
+	query := `
+    SELECT id FROM sessions
+    WHERE created_at < datetime('now', $1)
+      AND status = $2
+      AND id NOT IN (SELECT session_id FROM session_archive)
+    ORDER BY created_at`
+
+	// Timestamps are stored in UTC by SQLite, so the cutoff is computed there
+	cutoff := fmt.Sprintf("-%d seconds", int64(olderThan.Seconds()))
+	if err := m.db.SelectContext(ctx, &sessionIDs, query, cutoff, SessionStatusCompleted); err != nil {
+		return 0, fmt.Errorf("failed to find sessions to archive: %w", err)
+	}
+
+	// One transaction per session keeps each one small on huge databases
+	for i, sessionID := range sessionIDs {
+		if err := m.archiveSession(ctx, sessionID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(sessionIDs), nil
+}
+
+// archiveSession writes the summary of one session and deletes its details.
+func (m *Manager) archiveSession(ctx context.Context, sessionID string) error {
+	return m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		summary := `
+      INSERT INTO session_archive (
+        session_id, total_files, completed_files, failed_files, skipped_files,
+        total_folders, total_bytes, completed_bytes, duration_seconds
+      )
+      SELECT
+        s.id, s.total_files, s.completed_files, s.failed_files, s.skipped_files,
+        (SELECT COUNT(*) FROM folders WHERE session_id = s.id),
+        s.total_bytes, s.completed_bytes,
+        CAST(ROUND(COALESCE((julianday(s.end_time) - julianday(s.start_time)) * 86400, 0)) AS INTEGER)
+      FROM sessions s
+      WHERE s.id = $1`
+
+		if _, err := tx.ExecContext(ctx, summary, sessionID); err != nil {
+			return fmt.Errorf("failed to archive session %s: %w", sessionID, err)
+		}
+
+		// Chunks go with their files
+		for _, table := range []string{"files", "file_aliases", "folders", "session_config", "events"} {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE session_id = $1", table), sessionID); err != nil {
+				return fmt.Errorf("failed to delete %s of session %s: %w", table, sessionID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetSessionArchive retrieves the archived summary of a session, or nil if
+// it was not archived.
+func (m *Manager) GetSessionArchive(ctx context.Context, sessionID string) (*SessionArchive, error) {
+	var archive SessionArchive
+	err := m.db.GetContext(ctx, &archive, `SELECT * FROM session_archive WHERE session_id = $1`, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session archive: %w", err)
+	}
+
+	return &archive, nil
+}
+
+// ErrorLogFilter selects error log entries. Empty fields match every entry.
+type ErrorLogFilter struct {
+	// Retryable, if set, matches entries with this retryability
+	Retryable *bool
+	ItemType  string
+	ErrorType string
+	TraceID   string
+
+	// Limit caps the number of entries returned; 0 means no limit
+	Limit  int
+	Offset int
+}
+
+// GetErrors retrieves the error log entries of a session, newest first.
+func (m *Manager) GetErrors(ctx context.Context, sessionID string, filter *ErrorLogFilter) ([]*ErrorLog, error) {
+	if filter == nil {
+		filter = &ErrorLogFilter{}
+	}
+
+	conditions := []string{"session_id = $1"}
+	args := []interface{}{sessionID}
+	addCondition := func(column string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if filter.ItemType != "" {
+		addCondition("item_type", filter.ItemType)
+	}
+	if filter.ErrorType != "" {
+		addCondition("error_type", filter.ErrorType)
+	}
+	if filter.TraceID != "" {
+		addCondition("trace_id", filter.TraceID)
+	}
+	if filter.Retryable != nil {
+		addCondition("is_retryable", *filter.Retryable)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as none
+	}
+	args = append(args, limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+    SELECT * FROM error_log
+    WHERE %s
+    ORDER BY created_at DESC, id DESC
+    LIMIT $%d OFFSET $%d`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	var entries []*ErrorLog
+	if err := m.db.SelectContext(ctx, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get errors: %w", err)
+	}
+
+	return entries, nil
+}
+
 // UpdateSessionProgress atomically updates session progress.
 func (m *Manager) UpdateSessionProgress(ctx context.Context, sessionID string, fileCompleted bool, bytesCompleted int64, failed bool) error {
 	delta := SessionProgressDelta{
@@ -181,8 +450,10 @@ func (m *Manager) MarkFileFailed(ctx context.Context, fileID, sessionID string,
 	})
 }
 
-// GetNextPendingFile retrieves the next file to download.
-func (m *Manager) GetNextPendingFile(ctx context.Context, sessionID string) (*File, error) {
+// GetNextPendingFile retrieves the next file to download, partially
+// downloaded files first, then pending files in order (smallest first by
+// default).
+func (m *Manager) GetNextPendingFile(ctx context.Context, sessionID, order string) (*File, error) {
 	// First check for partially downloaded files
 	query := `
     SELECT * FROM files
@@ -200,12 +471,12 @@ func (m *Manager) GetNextPendingFile(ctx context.Context, sessionID string) (*Fi
 		return nil, fmt.Errorf("failed to get partial download: %w", err)
 	}
 
-	// Then get next pending file (smallest first)
+	// Then get next pending file
 	query = `
     SELECT * FROM files
     WHERE session_id = $1
       AND status = $2
-    ORDER BY size ASC
+    ORDER BY ` + pendingOrder(order) + `
     LIMIT 1`
 
 	err = m.db.GetContext(ctx, &file, query, sessionID, FileStatusPending)
@@ -240,6 +511,26 @@ func (m *Manager) GetNextPendingFolder(ctx context.Context, sessionID string) (*
 	return &folder, nil
 }
 
+// GetUnscannedFolders retrieves the folders of a session whose contents were
+// never fully listed: pending folders, folders interrupted while scanning and
+// folders whose listing failed.
+func (m *Manager) GetUnscannedFolders(ctx context.Context, sessionID string) ([]*Folder, error) {
+	query := `
+    SELECT * FROM folders
+    WHERE session_id = $1
+      AND status IN ($2, $3, $4)
+    ORDER BY path`
+
+	var folders []*Folder
+	err := m.db.SelectContext(ctx, &folders, query, sessionID,
+		FolderStatusPending, FolderStatusScanning, FolderStatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get unscanned folders: %w", err)
+	}
+
+	return folders, nil
+}
+
 // ResumeSession prepares a session for resumption.
 func (m *Manager) ResumeSession(ctx context.Context, sessionID string) error {
 	return m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
@@ -257,6 +548,11 @@ func (m *Manager) ResumeSession(ctx context.Context, sessionID string) error {
 			return fmt.Errorf("failed to reset failed files: %w", err)
 		}
 
+		// Files queued or downloading by the previous run start over as pending
+		if _, err = fileStore.ResetInterrupted(ctx, sessionID); err != nil {
+			return err
+		}
+
 		// Reset failed folders
 		query := `
       UPDATE folders
@@ -272,6 +568,76 @@ func (m *Manager) ResumeSession(ctx context.Context, sessionID string) error {
 	})
 }
 
+// PrepareRetry resets a session's failed files with fewer than maxAttempts
+// download attempts back to pending and reopens the session. Folders are
+// left untouched. It returns the files that will be retried.
+func (m *Manager) PrepareRetry(ctx context.Context, sessionID string, maxAttempts int) ([]*File, error) {
+	var files []*File
+
+	err := m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		fileStore := m.files.WithTx(tx)
+
+		var err error
+		files, err = fileStore.GetFailedFiles(ctx, sessionID, maxAttempts)
+		if err != nil {
+			return err
+		}
+
+		if len(files) == 0 {
+			return nil
+		}
+
+		if _, err := fileStore.ResetFailedFiles(ctx, sessionID, maxAttempts); err != nil {
+			return err
+		}
+
+		// Reopen the session, whatever state it finished in
+		query := `
+      UPDATE sessions
+      SET status = $1, end_time = NULL
+      WHERE id = $2`
+
+		if _, err := tx.ExecContext(ctx, query, SessionStatusActive, sessionID); err != nil {
+			return fmt.Errorf("failed to reopen session: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		file.Status = FileStatusPending
+		file.ErrorMessage = sql.NullString{}
+	}
+
+	return files, nil
+}
+
+// RecalculateSessionProgress recomputes a session's totals and its completed,
+// failed and skipped counters from its file records. Skipped files count
+// towards total_files but not total_bytes.
+func (m *Manager) RecalculateSessionProgress(ctx context.Context, sessionID string) error {
+	query := `
+    UPDATE sessions
+    SET
+      completed_files = (SELECT COUNT(*) FROM files WHERE session_id = $1 AND status = $2),
+      failed_files = (SELECT COUNT(*) FROM files WHERE session_id = $1 AND status = $3),
+      skipped_files = (SELECT COUNT(*) FROM files WHERE session_id = $1 AND status = $4),
+      completed_bytes = (SELECT COALESCE(SUM(size), 0) FROM files WHERE session_id = $1 AND status = $2),
+      total_files = (SELECT COUNT(*) FROM files WHERE session_id = $1),
+      total_bytes = (SELECT COALESCE(SUM(size), 0) FROM files WHERE session_id = $1 AND status != $4)
+    WHERE id = $1`
+
+	_, err := m.db.ExecContext(ctx, query, sessionID, FileStatusCompleted, FileStatusFailed, FileStatusSkipped)
+	if err != nil {
+		return fmt.Errorf("failed to recalculate session progress: %w", err)
+	}
+
+	return nil
+}
+
 // GetSessionStats retrieves comprehensive statistics for a session.
 func (m *Manager) GetSessionStats(ctx context.Context, sessionID string) (*SessionStats, error) {
 	stats := &SessionStats{SessionID: sessionID}
@@ -316,6 +682,61 @@ type SessionStats struct {
 	Errors       []*ErrorSummary  `json:"errors"`
 }
 
+// SessionDetail is everything recorded about one session, see
+// GetSessionDetail.
+type SessionDetail struct {
+	Session *Session      `json:"session"`
+	Stats   *SessionStats `json:"stats"`
+
+	// Folders are the top-level folders with totals of their subtrees
+	Folders []*FolderTree `json:"folders"`
+
+	// PartialDownloads are unfinished files with bytes downloaded
+	PartialDownloads []*PartialFile `json:"partial_downloads"`
+	PendingFolders   int64          `json:"pending_folders"`
+	PendingFiles     int64          `json:"pending_files"`
+	FailedRetryable  int64          `json:"failed_retryable"`
+
+	// Archive is the summary kept when the session was archived, nil
+	// otherwise
+	Archive *SessionArchive `json:"archive,omitempty"`
+
+	// Config holds the settings the session is resumed with
+	Config map[string]string `json:"config"`
+}
+
+// GetSessionDetail assembles the record, statistics, folder summary, error
+// summary and resumable state of a session, whatever its status.
+func (m *Manager) GetSessionDetail(ctx context.Context, sessionID string) (*SessionDetail, error) {
+	resumable, err := m.queries.GetResumableState(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &SessionDetail{
+		Session:          resumable.Session,
+		PartialDownloads: resumable.PartialDownloads,
+		PendingFolders:   resumable.PendingFolders,
+		PendingFiles:     resumable.PendingFiles,
+		FailedRetryable:  resumable.FailedRetryable,
+	}
+
+	if detail.Stats, err = m.GetSessionStats(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if detail.Folders, err = m.queries.GetFolderTreeRollup(ctx, sessionID, nil); err != nil {
+		return nil, err
+	}
+	if detail.Archive, err = m.GetSessionArchive(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	if detail.Config, err = m.GetSessionConfig(ctx, sessionID); err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
 // HealthCheck performs a comprehensive health check.
 func (m *Manager) HealthCheck(ctx context.Context) error {
 	// Check database connection
@@ -341,6 +762,19 @@ func (m *Manager) Vacuum(ctx context.Context) error {
 	return m.db.Vacuum(ctx)
 }
 
+// Optimize refreshes the query planner statistics of the database.
+func (m *Manager) Optimize(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.db.Optimize(ctx)
+}
+
+// SpaceStats reports the page usage of the database file.
+func (m *Manager) SpaceStats(ctx context.Context) (*SpaceStats, error) {
+	return m.db.SpaceStats(ctx)
+}
+
 // GetConfig retrieves a configuration value.
 func (m *Manager) GetConfig(ctx context.Context, key string) (string, error) {
 	var value string
@@ -371,6 +805,41 @@ func (m *Manager) SetConfig(ctx context.Context, key, value string) error {
 	return nil
 }
 
+// GetSessionConfig retrieves the settings stored for a session, keyed by
+// config key. A session without stored settings returns an empty map.
+func (m *Manager) GetSessionConfig(ctx context.Context, sessionID string) (map[string]string, error) {
+	var rows []struct {
+		Key   string `db:"key"`
+		Value string `db:"value"`
+	}
+	query := `SELECT key, value FROM session_config WHERE session_id = $1`
+
+	if err := m.db.SelectContext(ctx, &rows, query, sessionID); err != nil {
+		return nil, fmt.Errorf("failed to get session config: %w", err)
+	}
+
+	config := make(map[string]string, len(rows))
+	for _, row := range rows {
+		config[row.Key] = row.Value
+	}
+
+	return config, nil
+}
+
+// SetSessionConfig sets a setting of one session.
+func (m *Manager) SetSessionConfig(ctx context.Context, sessionID, key, value string) error {
+	query := `
+    INSERT INTO session_config (session_id, key, value) VALUES ($1, $2, $3)
+    ON CONFLICT(session_id, key) DO UPDATE SET value = $3, updated_at = CURRENT_TIMESTAMP`
+
+	_, err := m.db.ExecContext(ctx, query, sessionID, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to set session config: %w", err)
+	}
+
+	return nil
+}
+
 // CreateSession creates a new session.
 func (m *Manager) CreateSession(ctx context.Context, rootFolderID, rootFolderName, destinationPath string) (*Session, error) {
 	session := &Session{
@@ -394,6 +863,12 @@ func (m *Manager) GetSession(ctx context.Context, sessionID string) (*Session, e
 	return m.sessions.Get(ctx, sessionID)
 }
 
+// GetSessionByRootFolder returns the latest resumable session syncing
+// rootFolderID into destinationPath, or nil if there is none.
+func (m *Manager) GetSessionByRootFolder(ctx context.Context, rootFolderID, destinationPath string) (*Session, error) {
+	return m.sessions.FindByRoot(ctx, rootFolderID, destinationPath)
+}
+
 // UpdateSession updates a session.
 func (m *Manager) UpdateSession(ctx context.Context, session *Session) error {
 	return m.sessions.Update(ctx, session)
@@ -449,28 +924,76 @@ func (m *Manager) CreateFiles(ctx context.Context, files []*File) error {
 	return m.files.CreateBatch(ctx, files)
 }
 
+// ResetInterruptedFiles returns the queued and downloading files of a
+// session to pending before it resumes. It returns the number of files reset.
+func (m *Manager) ResetInterruptedFiles(ctx context.Context, sessionID string) (int64, error) {
+	return m.files.ResetInterrupted(ctx, sessionID)
+}
+
 // UpdateFileStatus updates the status of a file.
 func (m *Manager) UpdateFileStatus(ctx context.Context, file *File) error {
 	return m.files.UpdateStatus(ctx, file.ID, file.Status)
 }
 
-// GetPendingFiles retrieves pending files for a session.
-func (m *Manager) GetPendingFiles(ctx context.Context, sessionID string, limit int) ([]*File, error) {
+// UpdateFileStatuses writes the statuses of many files, keyed by file ID,
+// in a single transaction.
+func (m *Manager) UpdateFileStatuses(ctx context.Context, statuses map[string]string) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	byStatus := make(map[string][]string)
+	for id, status := range statuses {
+		byStatus[status] = append(byStatus[status], id)
+	}
+
+	return m.db.WithTx(ctx, func(tx *sqlx.Tx) error {
+		files := m.files.WithTx(tx)
+		for status, ids := range byStatus {
+			if err := files.UpdateStatusBatch(ctx, ids, status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPendingFiles retrieves files of a session that still need downloading,
+// partially downloaded files first, then in order (smallest first by
+// default). A limit of 0 returns every such file.
+func (m *Manager) GetPendingFiles(ctx context.Context, sessionID string, limit int, order string) ([]*File, error) {
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as none
+	}
+
 	query := `
     SELECT * FROM files
     WHERE session_id = $1
-      AND status IN ($2, $3)
+      AND status IN ($2, $3, $4)
     ORDER BY
-      CASE WHEN status = $3 THEN 0 ELSE 1 END,
-      size ASC
-    LIMIT $4`
+      CASE WHEN bytes_downloaded > 0 THEN 0 ELSE 1 END,
+      ` + pendingOrder(order) + `
+    LIMIT $5`
 
 	var files []*File
 	err := m.db.SelectContext(ctx, &files, query,
-		sessionID, FileStatusPending, FileStatusDownloading, limit)
+		sessionID, FileStatusPending, FileStatusQueued, FileStatusDownloading, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pending files: %w", err)
 	}
 
 	return files, nil
 }
+
+// pendingOrder returns the ORDER BY terms that list pending files in order.
+// Files are recorded as folders are listed, so row order is Drive order.
+func pendingOrder(order string) string {
+	switch order {
+	case OrderLargestFirst:
+		return "size DESC, rowid ASC"
+	case OrderDriveOrder:
+		return "rowid ASC"
+	default:
+		return "size ASC, rowid ASC"
+	}
+}
@@ -0,0 +1,611 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrepareRetryResetsOnlyRetryableFailures(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, SessionStatusFailed))
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	root.Status = FolderStatusFailed
+	require.NoError(t, m.UpdateFolder(ctx, root))
+
+	done := createTestFile(t, m, root, "done.txt", 100, 100)
+	retryable := createTestFile(t, m, root, "retry.txt", 200, 0)
+	exhausted := createTestFile(t, m, root, "exhausted.txt", 300, 0)
+
+	done.Status = FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, done))
+
+	retryable.Status = FileStatusFailed
+	retryable.DownloadAttempts = 2
+	retryable.ErrorMessage = NewNullString("timeout")
+	require.NoError(t, m.Files().Update(ctx, retryable))
+
+	exhausted.Status = FileStatusFailed
+	exhausted.DownloadAttempts = 5
+	require.NoError(t, m.Files().Update(ctx, exhausted))
+
+	files, err := m.PrepareRetry(ctx, session.ID, 5)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, retryable.ID, files[0].ID)
+	assert.Equal(t, FileStatusPending, files[0].Status)
+
+	reloaded, err := m.Files().Get(ctx, retryable.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusPending, reloaded.Status)
+	assert.False(t, reloaded.ErrorMessage.Valid)
+
+	reloaded, err = m.Files().Get(ctx, exhausted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusFailed, reloaded.Status)
+
+	// The session is reopened but folders are not reset
+	reopened, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, SessionStatusActive, reopened.Status)
+	assert.False(t, reopened.EndTime.Valid)
+
+	folder, err := m.Folders().Get(ctx, root.ID)
+	require.NoError(t, err)
+	assert.Equal(t, FolderStatusFailed, folder.Status)
+
+	require.NoError(t, m.RecalculateSessionProgress(ctx, session.ID))
+	reopened, err = m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), reopened.CompletedFiles)
+	assert.Equal(t, int64(1), reopened.FailedFiles)
+	assert.Equal(t, int64(100), reopened.CompletedBytes)
+	assert.Equal(t, int64(3), reopened.TotalFiles)
+}
+
+func TestPrepareRetryWithNothingToRetry(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, SessionStatusCompleted))
+
+	files, err := m.PrepareRetry(ctx, session.ID, 5)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Sessions with nothing to retry keep their status
+	reloaded, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, SessionStatusCompleted, reloaded.Status)
+}
+
+func TestResetInterruptedFilesAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	other, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/other")
+	require.NoError(t, err)
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	otherRoot := createTestFolder(t, m, other.ID, "root", nil)
+
+	// The state a crash leaves behind: files never scheduled, queued,
+	// mid-download and finished
+	pending := createTestFile(t, m, root, "pending.txt", 100, 0)
+	queued := createTestFile(t, m, root, "queued.txt", 200, 0)
+	partial := createTestFile(t, m, root, "partial.txt", 300, 0)
+	completed := createTestFile(t, m, root, "completed.txt", 400, 0)
+	failed := createTestFile(t, m, root, "failed.txt", 500, 0)
+	otherQueued := createTestFile(t, m, otherRoot, "queued.txt", 600, 0)
+
+	require.NoError(t, m.Files().MarkQueued(ctx, []string{queued.ID, partial.ID, completed.ID, failed.ID, otherQueued.ID}))
+	require.NoError(t, m.Files().UpdateProgress(ctx, partial.ID, 150))
+	completed.Status = FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, completed))
+	failed.Status = FileStatusFailed
+	require.NoError(t, m.UpdateFileStatus(ctx, failed))
+
+	// Only pending files are queued
+	require.NoError(t, m.Files().MarkQueued(ctx, []string{completed.ID}))
+
+	state, err := m.Queries().GetResumableState(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), state.PendingFiles)
+	require.Len(t, state.PartialDownloads, 1)
+
+	reset, err := m.ResetInterruptedFiles(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), reset)
+
+	expected := map[string]string{
+		pending.ID:     FileStatusPending,
+		queued.ID:      FileStatusPending,
+		partial.ID:     FileStatusPending,
+		completed.ID:   FileStatusCompleted,
+		failed.ID:      FileStatusFailed,
+		otherQueued.ID: FileStatusQueued,
+	}
+	for id, status := range expected {
+		file, err := m.Files().Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, status, file.Status, file.Name)
+	}
+
+	// Resuming reconstructs exactly the unfinished files, partial ones first
+	files, err := m.GetPendingFiles(ctx, session.ID, 10, OrderSmallestFirst)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, partial.ID, files[0].ID)
+	assert.Equal(t, int64(150), files[0].BytesDownloaded)
+	assert.ElementsMatch(t, []string{pending.ID, queued.ID}, []string{files[1].ID, files[2].ID})
+}
+
+func TestPendingFilesFollowScheduleOrder(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+
+	// Recorded in Drive listing order
+	medium := createTestFile(t, m, root, "medium.bin", 200, 0)
+	large := createTestFile(t, m, root, "large.bin", 300, 0)
+	small := createTestFile(t, m, root, "small.bin", 100, 0)
+	partial := createTestFile(t, m, root, "partial.bin", 50, 0)
+	partial.Status = FileStatusDownloading
+	require.NoError(t, m.UpdateFileStatus(ctx, partial))
+	require.NoError(t, m.Files().UpdateProgress(ctx, partial.ID, 10))
+
+	tests := []struct {
+		order    string
+		expected []string
+	}{
+		{order: OrderSmallestFirst, expected: []string{partial.ID, small.ID, medium.ID, large.ID}},
+		{order: OrderLargestFirst, expected: []string{partial.ID, large.ID, medium.ID, small.ID}},
+		{order: OrderDriveOrder, expected: []string{partial.ID, medium.ID, large.ID, small.ID}},
+		{order: "", expected: []string{partial.ID, small.ID, medium.ID, large.ID}},
+	}
+
+	for _, tt := range tests {
+		files, err := m.GetPendingFiles(ctx, session.ID, 0, tt.order)
+		require.NoError(t, err)
+		ids := make([]string, len(files))
+		for i, file := range files {
+			ids[i] = file.ID
+		}
+		assert.Equal(t, tt.expected, ids, tt.order)
+
+		// The partial download is continued before any pending file
+		next, err := m.GetNextPendingFile(ctx, session.ID, tt.order)
+		require.NoError(t, err)
+		assert.Equal(t, partial.ID, next.ID, tt.order)
+	}
+
+	partial.Status = FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, partial))
+	for _, tt := range tests {
+		next, err := m.GetNextPendingFile(ctx, session.ID, tt.order)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected[1], next.ID, tt.order)
+	}
+}
+
+func TestGetErrorsFiltersAndPages(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	other, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/other")
+	require.NoError(t, err)
+
+	require.NoError(t, m.LogError(ctx, session.ID, "file-1", "file", "download_failed", errors.New("connection reset")))
+	require.NoError(t, m.LogError(ctx, session.ID, "file-2", "file", "download_failed", errors.New("permission denied")))
+	require.NoError(t, m.LogError(ctx, session.ID, "folder-1", "folder", "scan_failed", errors.New("timeout")))
+	require.NoError(t, m.LogError(ctx, other.ID, "file-3", "file", "download_failed", errors.New("timeout")))
+
+	all, err := m.GetErrors(ctx, session.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "folder-1", all[0].ItemID, "newest first")
+	assert.Equal(t, "timeout", all[0].ErrorMessage.String)
+
+	downloads, err := m.GetErrors(ctx, session.ID, &ErrorLogFilter{ErrorType: "download_failed"})
+	require.NoError(t, err)
+	assert.Len(t, downloads, 2)
+
+	folders, err := m.GetErrors(ctx, session.ID, &ErrorLogFilter{ItemType: "folder"})
+	require.NoError(t, err)
+	require.Len(t, folders, 1)
+	assert.Equal(t, "scan_failed", folders[0].ErrorType)
+
+	permanent := false
+	failures, err := m.GetErrors(ctx, session.ID, &ErrorLogFilter{Retryable: &permanent})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "file-2", failures[0].ItemID)
+
+	page, err := m.GetErrors(ctx, session.ID, &ErrorLogFilter{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, all[1].ID, page[0].ID)
+	assert.Equal(t, all[2].ID, page[1].ID)
+}
+
+func TestEventsRecordFilterAndPrune(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+
+	now := time.Now()
+	event := func(eventType, itemID string, bytes int64, message string) *Event {
+		return &Event{
+			OccurredAt: now,
+			SessionID:  session.ID,
+			Type:       eventType,
+			ItemID:     NewNullString(itemID),
+			ItemPath:   NewNullString("docs/" + itemID),
+			Message:    NewNullString(message),
+			Bytes:      bytes,
+		}
+	}
+	require.NoError(t, m.RecordEvents(ctx, []*Event{
+		event("file_started", "file-1", 0, ""),
+		event("file_progress", "file-1", 512, ""),
+		event("file_failed", "file-1", 0, "connection reset"),
+		event("file_started", "file-2", 0, ""),
+	}))
+
+	all, err := m.GetEvents(ctx, session.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, "file_started", all[0].Type, "recorded order")
+	assert.Equal(t, int64(512), all[1].Bytes)
+	assert.Equal(t, "docs/file-1", all[1].ItemPath.String)
+
+	failed, err := m.GetEvents(ctx, session.ID, &EventFilter{Type: "file_failed"})
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "connection reset", failed[0].Message.String)
+
+	second, err := m.GetEvents(ctx, session.ID, &EventFilter{ItemID: "file-2"})
+	require.NoError(t, err)
+	assert.Len(t, second, 1)
+
+	limited, err := m.GetEvents(ctx, session.ID, &EventFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	// Only events recorded before the retention window are pruned
+	_, err = m.db.ExecContext(ctx, `UPDATE events SET created_at = datetime('now', '-10 days') WHERE item_id = $1`, "file-1")
+	require.NoError(t, err)
+
+	pruned, err := m.PruneEvents(ctx, 7*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), pruned)
+
+	remaining, err := m.GetEvents(ctx, session.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "file-2", remaining[0].ItemID.String)
+}
+
+func TestGetLatestResumableSkipsFinishedSessions(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	latest, err := m.Sessions().GetLatestResumable(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, latest)
+
+	// Oldest first; the newest sessions cannot be resumed
+	var ids []string
+	for i, status := range []string{
+		SessionStatusPaused, SessionStatusFailed, SessionStatusCompleted, SessionStatusCancelled,
+	} {
+		session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+		require.NoError(t, err)
+		require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, status))
+		_, err = m.db.ExecContext(ctx, `UPDATE sessions SET start_time = datetime('now', $1) WHERE id = $2`,
+			fmt.Sprintf("-%d minutes", 10-i), session.ID)
+		require.NoError(t, err)
+		ids = append(ids, session.ID)
+	}
+
+	latest, err = m.Sessions().GetLatestResumable(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, ids[1], latest.ID)
+	assert.Equal(t, SessionStatusFailed, latest.Status)
+}
+
+func TestGetSessionByRootFolderMatchesFolderAndDestination(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	// Oldest first; only the paused one matches and can be resumed
+	sessions := []struct {
+		root, dest, status string
+	}{
+		{"root-id", "/tmp/dest", SessionStatusPaused},
+		{"root-id", "/tmp/other", SessionStatusFailed},
+		{"other-root", "/tmp/dest", SessionStatusActive},
+		{"root-id", "/tmp/dest", SessionStatusCompleted},
+	}
+	var ids []string
+	for i, s := range sessions {
+		session, err := m.CreateSession(ctx, s.root, "Root", s.dest)
+		require.NoError(t, err)
+		require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, s.status))
+		_, err = m.db.ExecContext(ctx, `UPDATE sessions SET start_time = datetime('now', $1) WHERE id = $2`,
+			fmt.Sprintf("-%d minutes", 10-i), session.ID)
+		require.NoError(t, err)
+		ids = append(ids, session.ID)
+	}
+
+	found, err := m.GetSessionByRootFolder(ctx, "root-id", "/tmp/dest")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, ids[0], found.ID)
+
+	found, err = m.GetSessionByRootFolder(ctx, "root-id", "/tmp/missing")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestArchiveCompletedSessionsKeepsSummary(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	old, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, old.ID, "root", nil)
+	createTestFolder(t, m, old.ID, "sub", root)
+	done := createTestFile(t, m, root, "done.txt", 100, 100)
+	done.Status = FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, done))
+	createTestFile(t, m, root, "skipped.txt", 50, 0)
+	require.NoError(t, m.RecalculateSessionProgress(ctx, old.ID))
+	require.NoError(t, m.UpdateSessionStatus(ctx, old.ID, SessionStatusCompleted))
+	_, err = m.db.ExecContext(ctx, `
+    UPDATE sessions
+    SET created_at = datetime('now', '-40 days'), start_time = datetime('now', '-40 days'),
+        end_time = datetime('now', '-40 days', '+90 seconds')
+    WHERE id = $1`, old.ID)
+	require.NoError(t, err)
+
+	// Recent and unfinished sessions are kept as they are
+	recent, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	require.NoError(t, m.UpdateSessionStatus(ctx, recent.ID, SessionStatusCompleted))
+	createTestFile(t, m, createTestFolder(t, m, recent.ID, "root", nil), "recent.txt", 10, 10)
+
+	archived, err := m.ArchiveCompletedSessions(ctx, 30*24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, archived)
+
+	summary, err := m.GetSessionArchive(ctx, old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, summary)
+	assert.Equal(t, int64(2), summary.TotalFiles)
+	assert.Equal(t, int64(1), summary.CompletedFiles)
+	assert.Equal(t, int64(2), summary.TotalFolders)
+	assert.Equal(t, int64(150), summary.TotalBytes)
+	assert.Equal(t, int64(100), summary.CompletedBytes)
+	assert.Equal(t, int64(90), summary.DurationSeconds)
+
+	// The session header stays, its details are gone
+	session, err := m.GetSession(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Equal(t, SessionStatusCompleted, session.Status)
+	files, err := m.Files().GetBySession(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+	counts, err := m.Folders().CountByStatus(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	files, err = m.Files().GetBySession(ctx, recent.ID)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+	summary, err = m.GetSessionArchive(ctx, recent.ID)
+	require.NoError(t, err)
+	assert.Nil(t, summary)
+
+	// Archiving again finds nothing new
+	archived, err = m.ArchiveCompletedSessions(ctx, 30*24*time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, archived)
+}
+
+func TestSessionConfigRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	other, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/other")
+	require.NoError(t, err)
+
+	config, err := m.GetSessionConfig(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Empty(t, config)
+
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "sync.max_concurrent", "3"))
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "sync.checksum_algorithm", "md5"))
+	require.NoError(t, m.SetSessionConfig(ctx, other.ID, "sync.max_concurrent", "8"))
+
+	// Setting a key again overrides it
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "sync.max_concurrent", "1"))
+
+	config, err = m.GetSessionConfig(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"sync.max_concurrent":     "1",
+		"sync.checksum_algorithm": "md5",
+	}, config)
+
+	config, err = m.GetSessionConfig(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"sync.max_concurrent": "8"}, config)
+}
+
+// createPendingFiles records count pending files in a new session.
+func createPendingFiles(tb testing.TB, m *Manager, count int) []*File {
+	tb.Helper()
+	ctx := context.Background()
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(tb, err)
+	folder := &Folder{DriveID: "root-id", SessionID: session.ID, Name: "Root", Path: "Root",
+		Status: FolderStatusScanned}
+	require.NoError(tb, m.CreateFolder(ctx, folder))
+
+	files := make([]*File, count)
+	for i := range files {
+		files[i] = &File{DriveID: fmt.Sprintf("file-%d", i), FolderID: folder.ID, SessionID: session.ID,
+			Name: fmt.Sprintf("%d.txt", i), Path: fmt.Sprintf("Root/%d.txt", i), Size: 10,
+			Status: FileStatusPending}
+	}
+	require.NoError(tb, m.Files().CreateBatch(ctx, files))
+
+	return files
+}
+
+func TestUpdateFileStatusesWritesEveryStatus(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+	files := createPendingFiles(t, m, 5)
+
+	require.NoError(t, m.UpdateFileStatuses(ctx, map[string]string{
+		files[0].ID: FileStatusCompleted,
+		files[1].ID: FileStatusCompleted,
+		files[2].ID: FileStatusQueued,
+	}))
+
+	counts, err := m.Files().CountByStatus(ctx, files[0].SessionID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{
+		FileStatusCompleted: 2,
+		FileStatusQueued:    1,
+		FileStatusPending:   2,
+	}, counts)
+
+	// A missing file rolls the whole batch back
+	err = m.UpdateFileStatuses(ctx, map[string]string{
+		files[3].ID: FileStatusCompleted,
+		"missing":   FileStatusCompleted,
+	})
+	assert.ErrorContains(t, err, "file not found")
+
+	file, err := m.Files().Get(ctx, files[3].ID)
+	require.NoError(t, err)
+	assert.Equal(t, FileStatusPending, file.Status)
+}
+
+// BenchmarkFileStatusUpdates compares writing download results one file at
+// a time with writing them in batches of 100.
+func BenchmarkFileStatusUpdates(b *testing.B) {
+	const fileCount = 500
+	ctx := context.Background()
+
+	b.Run("per-file", func(b *testing.B) {
+		m := newTestManager(b)
+		files := createPendingFiles(b, m, fileCount)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			for _, file := range files {
+				if err := m.Files().UpdateStatus(ctx, file.ID, FileStatusCompleted); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		m := newTestManager(b)
+		files := createPendingFiles(b, m, fileCount)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			statuses := make(map[string]string, 100)
+			for _, file := range files {
+				statuses[file.ID] = FileStatusCompleted
+				if len(statuses) == 100 {
+					if err := m.UpdateFileStatuses(ctx, statuses); err != nil {
+						b.Fatal(err)
+					}
+					statuses = make(map[string]string, 100)
+				}
+			}
+			if err := m.UpdateFileStatuses(ctx, statuses); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGetSessionDetailOfFinishedSession(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	sub := createTestFolder(t, m, session.ID, "sub", root)
+	done := createTestFile(t, m, root, "done.txt", 100, 100)
+	createTestFile(t, m, sub, "partial.txt", 300, 120)
+	failed := createTestFile(t, m, sub, "failed.txt", 50, 0)
+
+	done.Status = FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, done))
+	failed.Status = FileStatusFailed
+	require.NoError(t, m.UpdateFileStatus(ctx, failed))
+	require.NoError(t, m.LogError(ctx, session.ID, failed.ID, "file", "download_failed", fmt.Errorf("boom")))
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "max_concurrent", "4"))
+	require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, SessionStatusFailed))
+
+	detail, err := m.GetSessionDetail(ctx, session.ID)
+	require.NoError(t, err)
+
+	assert.Equal(t, session.ID, detail.Session.ID)
+	assert.Equal(t, SessionStatusFailed, detail.Session.Status)
+	assert.Equal(t, int64(3), detail.Stats.Files.TotalCount)
+	assert.Equal(t, int64(2), detail.Stats.FolderCounts[FolderStatusScanned])
+	require.Len(t, detail.Stats.Errors, 1)
+	assert.Equal(t, "download_failed", detail.Stats.Errors[0].ErrorType)
+
+	// The top-level folder rolls up the files of its subfolders
+	require.Len(t, detail.Folders, 1)
+	assert.Equal(t, int64(3), detail.Folders[0].FileCount)
+	assert.Equal(t, int64(450), detail.Folders[0].TotalSize)
+
+	require.Len(t, detail.PartialDownloads, 1)
+	assert.Equal(t, "partial.txt", detail.PartialDownloads[0].Name)
+	assert.Equal(t, int64(1), detail.PendingFiles)
+	assert.Equal(t, int64(1), detail.FailedRetryable)
+	assert.Nil(t, detail.Archive)
+	assert.Equal(t, map[string]string{"max_concurrent": "4"}, detail.Config)
+
+	_, err = m.GetSessionDetail(ctx, "missing")
+	assert.Error(t, err)
+}
@@ -26,6 +26,39 @@ const (
 	SessionStatusCompleted = "completed"
 	SessionStatusFailed    = "failed"
 	SessionStatusCancelled = "cancelled"
+
+	// SessionStatusStoppedQuota marks a session that stopped downloading
+	// after reaching its byte quota
+	SessionStatusStoppedQuota = "stopped_quota"
+
+	// SessionStatusScanned marks a session whose folders were walked
+	// without downloading; its files are downloaded by a later run
+	SessionStatusScanned = "scanned"
+
+	// SessionStatusCompletedWithErrors marks a session that ran to the end
+	// with continue_on_errors set although some files failed
+	SessionStatusCompletedWithErrors = "completed_with_errors"
+
+	// SessionStatusStalled marks a session the watchdog stopped after it
+	// made no progress for too long; it is resumed like a failed one
+	SessionStatusStalled = "stalled"
+)
+
+// A running sync records a heartbeat every SessionHeartbeatInterval and
+// clears it when it stops. A heartbeat older than SessionHeartbeatTimeout
+// was left by a process that died.
+const (
+	SessionHeartbeatInterval = 30 * time.Second
+	SessionHeartbeatTimeout  = 2 * time.Minute
+)
+
+// Orders in which pending files are downloaded.
+const (
+	OrderSmallestFirst = "smallest_first"
+	OrderLargestFirst  = "largest_first"
+
+	// OrderDriveOrder follows the order files were listed in
+	OrderDriveOrder = "drive_order"
 )
 
 // Folder statuses.
@@ -34,11 +67,16 @@ const (
 	FolderStatusScanning = "scanning"
 	FolderStatusScanned  = "scanned"
 	FolderStatusFailed   = "failed"
+
+	// FolderStatusSkipped marks folders left out by a filter; they are
+	// never listed
+	FolderStatusSkipped = "skipped"
 )
 
 // File statuses.
 const (
 	FileStatusPending     = "pending"
+	FileStatusQueued      = "queued" // handed to the download queue, not yet started
 	FileStatusDownloading = "downloading"
 	FileStatusCompleted   = "completed"
 	FileStatusFailed      = "failed"
@@ -59,6 +97,7 @@ type Session struct {
 	UpdatedAt       time.Time      `db:"updated_at" json:"updated_at"`
 	CreatedAt       time.Time      `db:"created_at" json:"created_at"`
 	EndTime         sql.NullTime   `db:"end_time" json:"end_time"`
+	HeartbeatAt     sql.NullTime   `db:"heartbeat_at" json:"heartbeat_at,omitempty"`
 	Status          string         `db:"status" json:"status"`
 	DestinationPath string         `db:"destination_path" json:"destination_path"`
 	ID              string         `db:"id" json:"id"`
@@ -70,6 +109,7 @@ type Session struct {
 	SkippedFiles    int64          `db:"skipped_files" json:"skipped_files"`
 	TotalBytes      int64          `db:"total_bytes" json:"total_bytes"`
 	CompletedBytes  int64          `db:"completed_bytes" json:"completed_bytes"`
+	Flatten         bool           `db:"flatten" json:"flatten"`
 }
 
 // IsActive returns true if the session is active.
@@ -77,6 +117,12 @@ func (s *Session) IsActive() bool {
 	return s.Status == SessionStatusActive
 }
 
+// IsRunning reports whether a sync process, this one or another, still runs
+// the session: it recorded a heartbeat recently.
+func (s *Session) IsRunning() bool {
+	return s.HeartbeatAt.Valid && time.Since(s.HeartbeatAt.Time) < SessionHeartbeatTimeout
+}
+
 // Progress returns the completion percentage.
 func (s *Session) Progress() float64 {
 	if s.TotalFiles == 0 {
@@ -126,6 +172,8 @@ type File struct {
 	CreatedAt         time.Time      `db:"created_at" json:"created_at"`
 	LocalModifiedTime sql.NullTime   `db:"local_modified_time" json:"local_modified_time,omitempty"`
 	DriveModifiedTime sql.NullTime   `db:"drive_modified_time" json:"drive_modified_time,omitempty"`
+	DriveCreatedTime  sql.NullTime   `db:"drive_created_time" json:"drive_created_time,omitempty"`
+	CompletedAt       sql.NullTime   `db:"completed_at" json:"completed_at,omitempty"`
 	Status            string         `db:"status" json:"status"`
 	DriveID           string         `db:"drive_id" json:"drive_id"`
 	FolderID          string         `db:"folder_id" json:"folder_id"`
@@ -137,6 +185,12 @@ type File struct {
 	ErrorMessage      sql.NullString `db:"error_message" json:"error_message,omitempty"`
 	ExportMimeType    sql.NullString `db:"export_mime_type" json:"export_mime_type,omitempty"`
 	MD5Checksum       sql.NullString `db:"md5_checksum" json:"md5_checksum,omitempty"`
+	LocalPath         sql.NullString `db:"local_path" json:"local_path,omitempty"`
+	LocalMD5          sql.NullString `db:"local_md5" json:"local_md5,omitempty"`
+	LocalSHA256       sql.NullString `db:"local_sha256" json:"local_sha256,omitempty"`
+	OwnerEmail        sql.NullString `db:"owner_email" json:"owner_email,omitempty"`
+	CASPath           sql.NullString `db:"cas_path" json:"cas_path,omitempty"`
+	ConflictDecision  sql.NullString `db:"conflict_decision" json:"conflict_decision,omitempty"`
 	BytesDownloaded   int64          `db:"bytes_downloaded" json:"bytes_downloaded"`
 	DownloadAttempts  int            `db:"download_attempts" json:"download_attempts"`
 	Size              int64          `db:"size" json:"size"`
@@ -174,6 +228,17 @@ type DownloadChunk struct {
 	Attempts    int          `db:"attempts" json:"attempts"`
 }
 
+// DownloadJournal records how much of a partial download is known to be
+// on disk, for the version of the file given by Size and MD5Checksum.
+type DownloadJournal struct {
+	UpdatedAt    time.Time      `db:"updated_at" json:"updated_at"`
+	FileID       string         `db:"file_id" json:"file_id"`
+	TempPath     string         `db:"temp_path" json:"temp_path"`
+	MD5Checksum  sql.NullString `db:"md5_checksum" json:"md5_checksum,omitempty"`
+	FlushedBytes int64          `db:"flushed_bytes" json:"flushed_bytes"`
+	Size         int64          `db:"size" json:"size"`
+}
+
 // Size returns the chunk size in bytes.
 func (c *DownloadChunk) Size() int64 {
 	return c.EndByte - c.StartByte + 1
@@ -194,11 +259,62 @@ type ErrorLog struct {
 	ErrorCode    sql.NullString `db:"error_code" json:"error_code,omitempty"`
 	ErrorMessage sql.NullString `db:"error_message" json:"error_message,omitempty"`
 	StackTrace   sql.NullString `db:"stack_trace" json:"stack_trace,omitempty"`
+	TraceID      sql.NullString `db:"trace_id" json:"trace_id,omitempty"`
 	ID           int64          `db:"id" json:"id"`
 	RetryCount   int            `db:"retry_count" json:"retry_count"`
 	IsRetryable  bool           `db:"is_retryable" json:"is_retryable"`
 }
 
+// FileAlias records a further path of a file listed in several folders. The
+// file is downloaded once, to the path of its file record.
+type FileAlias struct {
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	SessionID string    `db:"session_id" json:"session_id"`
+	DriveID   string    `db:"drive_id" json:"drive_id"`
+	Path      string    `db:"path" json:"path"`
+	ID        int64     `db:"id" json:"id"`
+}
+
+// MirrorDeletion records a local file or folder removed by a mirror sync
+// because it no longer exists in Drive.
+type MirrorDeletion struct {
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	SessionID string         `db:"session_id" json:"session_id"`
+	Path      string         `db:"path" json:"path"`
+	Kind      string         `db:"kind" json:"kind"`
+	TrashPath sql.NullString `db:"trash_path" json:"trash_path,omitempty"`
+	ID        int64          `db:"id" json:"id"`
+	DryRun    bool           `db:"dry_run" json:"dry_run"`
+}
+
+// Event records a file event of a session for later inspection.
+type Event struct {
+	OccurredAt time.Time      `db:"occurred_at" json:"occurred_at"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	SessionID  string         `db:"session_id" json:"session_id"`
+	Type       string         `db:"type" json:"type"`
+	ItemID     sql.NullString `db:"item_id" json:"item_id,omitempty"`
+	ItemPath   sql.NullString `db:"item_path" json:"item_path,omitempty"`
+	Message    sql.NullString `db:"message" json:"message,omitempty"`
+	ID         int64          `db:"id" json:"id"`
+	Bytes      int64          `db:"bytes" json:"bytes"`
+}
+
+// SessionArchive summarizes a session whose file and folder rows were
+// deleted to keep the database small.
+type SessionArchive struct {
+	ArchivedAt      time.Time `db:"archived_at" json:"archived_at"`
+	SessionID       string    `db:"session_id" json:"session_id"`
+	TotalFiles      int64     `db:"total_files" json:"total_files"`
+	CompletedFiles  int64     `db:"completed_files" json:"completed_files"`
+	FailedFiles     int64     `db:"failed_files" json:"failed_files"`
+	SkippedFiles    int64     `db:"skipped_files" json:"skipped_files"`
+	TotalFolders    int64     `db:"total_folders" json:"total_folders"`
+	TotalBytes      int64     `db:"total_bytes" json:"total_bytes"`
+	CompletedBytes  int64     `db:"completed_bytes" json:"completed_bytes"`
+	DurationSeconds int64     `db:"duration_seconds" json:"duration_seconds"`
+}
+
 // Config represents a configuration entry.
 type Config struct {
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
@@ -250,5 +366,6 @@ type FileStats struct {
 	FailedCount      int64 `db:"failed_count" json:"failed_count"`
 	SkippedCount     int64 `db:"skipped_count" json:"skipped_count"`
 	PendingCount     int64 `db:"pending_count" json:"pending_count"`
+	QueuedCount      int64 `db:"queued_count" json:"queued_count"`
 	DownloadingCount int64 `db:"downloading_count" json:"downloading_count"`
 }
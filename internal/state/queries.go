@@ -16,9 +16,12 @@ package state
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/mattn/go-sqlite3"
 )
 
 // QueryBuilder provides complex query functionality.
@@ -120,7 +123,7 @@ func (q *QueryBuilder) GetFolderTree(ctx context.Context, sessionID string, pare
     SELECT
       f.id,
       f.drive_id,
-      f.parent_id,
+      COALESCE(f.parent_id, '') as parent_id,
       f.name,
       f.path,
       f.status,
@@ -151,6 +154,61 @@ func (q *QueryBuilder) GetFolderTree(ctx context.Context, sessionID string, pare
 	return folders, nil
 }
 
+// GetFolderTreeRollup retrieves one level of the folder tree like
+// GetFolderTree, but FileCount, TotalSize and DownloadSize include every
+// descendant folder so each entry shows the rolled-up progress of its
+// whole subtree. Pass a folder ID as parentID to expand the next level.
+func (q *QueryBuilder) GetFolderTreeRollup(ctx context.Context, sessionID string, parentID *string) ([]*FolderTree, error) {
+	levelFilter := "parent_id IS NULL"
+	args := []interface{}{sessionID}
+	if parentID != nil {
+		levelFilter = "parent_id = $2"
+		args = append(args, *parentID)
+	}
+
+	query := `
+    WITH RECURSIVE subtree(root_id, folder_id) AS (
+      SELECT id, id FROM folders
+      WHERE session_id = $1 AND ` + levelFilter + `
+      UNION ALL
+      SELECT s.root_id, c.id
+      FROM folders c
+      JOIN subtree s ON c.parent_id = s.folder_id
+    ),
+    totals AS (
+      SELECT
+        s.root_id,
+        COUNT(fi.id) as file_count,
+        COALESCE(SUM(fi.size), 0) as total_size,
+        COALESCE(SUM(fi.bytes_downloaded), 0) as downloaded_size
+      FROM subtree s
+      LEFT JOIN files fi ON fi.folder_id = s.folder_id
+      GROUP BY s.root_id
+    )
+    SELECT
+      f.id,
+      f.drive_id,
+      COALESCE(f.parent_id, '') as parent_id,
+      f.name,
+      f.path,
+      f.status,
+      (SELECT COUNT(*) FROM folders WHERE parent_id = f.id) as child_count,
+      t.file_count,
+      t.total_size,
+      t.downloaded_size
+    FROM folders f
+    JOIN totals t ON t.root_id = f.id
+    ORDER BY f.name`
+
+	var folders []*FolderTree
+	err := q.db.SelectContext(ctx, &folders, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get folder tree rollup: %w", err)
+	}
+
+	return folders, nil
+}
+
 // ErrorSummary represents error statistics.
 type ErrorSummary struct {
 	LastOccurred time.Time `db:"last_occurred" json:"last_occurred"`
@@ -166,7 +224,7 @@ func (q *QueryBuilder) GetErrorSummary(ctx context.Context, sessionID string) ([
 	query := `
     SELECT
       error_type,
-      error_code,
+      COALESCE(error_code, '') as error_code,
       item_type,
       COUNT(*) as count,
       MAX(created_at) as last_occurred,
@@ -176,15 +234,47 @@ func (q *QueryBuilder) GetErrorSummary(ctx context.Context, sessionID string) ([
     GROUP BY error_type, error_code, item_type, is_retryable
     ORDER BY count DESC`
 
-	var errors []*ErrorSummary
-	err := q.db.SelectContext(ctx, &errors, query, sessionID)
-	if err != nil {
+	// The driver only converts columns declared as timestamps, so the
+	// aggregated time arrives as text
+	var rows []struct {
+		LastOccurred string `db:"last_occurred"`
+		ErrorType    string `db:"error_type"`
+		ErrorCode    string `db:"error_code"`
+		ItemType     string `db:"item_type"`
+		Count        int64  `db:"count"`
+		IsRetryable  bool   `db:"is_retryable"`
+	}
+	if err := q.db.SelectContext(ctx, &rows, query, sessionID); err != nil {
 		return nil, fmt.Errorf("failed to get error summary: %w", err)
 	}
 
+	errors := make([]*ErrorSummary, 0, len(rows))
+	for _, row := range rows {
+		errors = append(errors, &ErrorSummary{
+			LastOccurred: parseSQLiteTime(row.LastOccurred),
+			ErrorType:    row.ErrorType,
+			ErrorCode:    row.ErrorCode,
+			ItemType:     row.ItemType,
+			Count:        row.Count,
+			IsRetryable:  row.IsRetryable,
+		})
+	}
+
 	return errors, nil
 }
 
+// parseSQLiteTime parses a timestamp stored by SQLite or the driver. It
+// returns the zero time if value is in none of the known formats.
+func parseSQLiteTime(value string) time.Time {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
 // ResumableState represents the state needed to resume a session.
 type ResumableState struct {
 	Session          *Session       `json:"session"`
@@ -225,8 +315,8 @@ func (q *QueryBuilder) GetResumableState(ctx context.Context, sessionID string)
 
 	// Count pending files
 	err = q.db.GetContext(ctx, &state.PendingFiles,
-		"SELECT COUNT(*) FROM files WHERE session_id = $1 AND status IN ($2, $3)",
-		sessionID, FileStatusPending, FileStatusDownloading)
+		"SELECT COUNT(*) FROM files WHERE session_id = $1 AND status IN ($2, $3, $4)",
+		sessionID, FileStatusPending, FileStatusQueued, FileStatusDownloading)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count pending files: %w", err)
 	}
@@ -242,11 +332,12 @@ func (q *QueryBuilder) GetResumableState(ctx context.Context, sessionID string)
       ROUND(CAST(bytes_downloaded AS FLOAT) / size * 100, 2) as progress
     FROM files
     WHERE session_id = $1
-      AND status = $2
+      AND status IN ($2, $3, $4)
       AND bytes_downloaded > 0
     ORDER BY bytes_downloaded DESC`
 
-	err = q.db.SelectContext(ctx, &state.PartialDownloads, partialQuery, sessionID, FileStatusDownloading)
+	err = q.db.SelectContext(ctx, &state.PartialDownloads, partialQuery,
+		sessionID, FileStatusPending, FileStatusQueued, FileStatusDownloading)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get partial downloads: %w", err)
 	}
@@ -262,29 +353,267 @@ func (q *QueryBuilder) GetResumableState(ctx context.Context, sessionID string)
 	return state, nil
 }
 
-// TransferStats represents transfer statistics over time.
+// TransferStats represents transfer statistics of one time bucket of a
+// session.
 type TransferStats struct {
-	Timestamp      time.Time `db:"timestamp" json:"timestamp"`
-	BytesPerSecond float64   `db:"bytes_per_second" json:"bytes_per_second"`
-	FilesPerMinute float64   `db:"files_per_minute" json:"files_per_minute"`
+	Timestamp       time.Time `db:"timestamp" json:"timestamp"`
+	BytesPerSecond  float64   `db:"bytes_per_second" json:"bytes_per_second"`
+	FilesPerMinute  float64   `db:"files_per_minute" json:"files_per_minute"`
+	Files           int64     `db:"files" json:"files"`
+	Bytes           int64     `db:"bytes" json:"bytes"`
+	CumulativeFiles int64     `db:"cumulative_files" json:"cumulative_files"`
+	CumulativeBytes int64     `db:"cumulative_bytes" json:"cumulative_bytes"`
 }
 
-// GetTransferStats retrieves transfer statistics for charting.
+// maxTransferBuckets bounds the buckets of GetTransferStats, so a short
+// interval over a long session cannot allocate without limit.
+const maxTransferBuckets = 100000
+
+// GetTransferStats buckets the files a session completed by the time they
+// were completed, from the session start in steps of interval. Buckets
+// without completed files are included, up to the session end if it ended.
 func (q *QueryBuilder) GetTransferStats(ctx context.Context, sessionID string, interval time.Duration) ([]*TransferStats, error) {
-	// This would require a more complex schema with transfer history
-	// For now, return current stats
-	var stats []*TransferStats
+	if interval < time.Second {
+		return nil, fmt.Errorf("interval must be at least one second, got %s", interval)
+	}
 
-	progress, err := q.GetSessionProgress(ctx, sessionID)
-	if err != nil {
-		return nil, err
+	var session Session
+	if err := q.db.GetContext(ctx, &session, "SELECT * FROM sessions WHERE id = $1", sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	// Files completed by older versions did not record the time, and fall
+	// back to when they were last updated
+	query := `
+    SELECT
+      MAX(0, CAST((julianday(COALESCE(f.completed_at, f.updated_at)) - julianday(s.start_time)) * 86400 / $1 AS INTEGER)) as bucket,
+      COUNT(*) as files,
+      COALESCE(SUM(f.size), 0) as bytes
+    FROM files f
+    JOIN sessions s ON s.id = f.session_id
+    WHERE f.session_id = $2 AND f.status = $3
+    GROUP BY bucket
+    ORDER BY bucket`
+
+	var rows []struct {
+		Bucket int64 `db:"bucket"`
+		Files  int64 `db:"files"`
+		Bytes  int64 `db:"bytes"`
+	}
+	if err := q.db.SelectContext(ctx, &rows, query, interval.Seconds(), sessionID, FileStatusCompleted); err != nil {
+		return nil, fmt.Errorf("failed to get transfer stats: %w", err)
+	}
+
+	var buckets int64
+	if len(rows) > 0 {
+		buckets = rows[len(rows)-1].Bucket + 1
+	}
+	if session.EndTime.Valid {
+		if last := int64(session.EndTime.Time.Sub(session.StartTime) / interval); last+1 > buckets {
+			buckets = last + 1
+		}
+	}
+	if buckets > maxTransferBuckets {
+		return nil, fmt.Errorf("interval %s splits the session into %d buckets, more than %d; use a longer interval",
+			interval, buckets, maxTransferBuckets)
+	}
+
+	stats := make([]*TransferStats, buckets)
+	for i := range stats {
+		stats[i] = &TransferStats{Timestamp: session.StartTime.Add(time.Duration(i) * interval)}
+	}
+	for _, row := range rows {
+		stats[row.Bucket].Files = row.Files
+		stats[row.Bucket].Bytes = row.Bytes
 	}
 
-	stats = append(stats, &TransferStats{
-		Timestamp:      time.Now(),
-		BytesPerSecond: progress.TransferRate,
-		FilesPerMinute: float64(progress.CompletedFiles) / (progress.ElapsedSeconds / 60),
-	})
+	var files, bytes int64
+	for _, bucket := range stats {
+		files += bucket.Files
+		bytes += bucket.Bytes
+		bucket.CumulativeFiles = files
+		bucket.CumulativeBytes = bytes
+		bucket.BytesPerSecond = float64(bucket.Bytes) / interval.Seconds()
+		bucket.FilesPerMinute = float64(bucket.Files) / interval.Minutes()
+	}
+
+	return stats, nil
+}
+
+// TransferSummary describes the transfers of a whole session.
+type TransferSummary struct {
+	StartTime             time.Time     `json:"start_time"`
+	EndTime               sql.NullTime  `json:"end_time"`
+	Duration              time.Duration `json:"duration"`
+	CompletedFiles        int64         `json:"completed_files"`
+	CompletedBytes        int64         `json:"completed_bytes"`
+	AverageBytesPerSecond float64       `json:"average_bytes_per_second"`
+	PeakBytesPerSecond    float64       `json:"peak_bytes_per_second"`
+}
+
+// SummarizeTransfers computes the summary of a session from its transfer
+// stats. Sessions that have not ended are measured up to now.
+func SummarizeTransfers(session *Session, stats []*TransferStats) *TransferSummary {
+	summary := &TransferSummary{
+		StartTime: session.StartTime,
+		EndTime:   session.EndTime,
+	}
+
+	end := time.Now()
+	if session.EndTime.Valid {
+		end = session.EndTime.Time
+	}
+	summary.Duration = end.Sub(session.StartTime)
+
+	for _, bucket := range stats {
+		summary.CompletedFiles += bucket.Files
+		summary.CompletedBytes += bucket.Bytes
+		summary.PeakBytesPerSecond = max(summary.PeakBytesPerSecond, bucket.BytesPerSecond)
+	}
+	if summary.Duration > 0 {
+		summary.AverageBytesPerSecond = float64(summary.CompletedBytes) / summary.Duration.Seconds()
+	}
+
+	return summary
+}
+
+// StatsRange limits aggregate statistics to the sessions started in
+// [Since, Until). A zero time leaves that side open.
+type StatsRange struct {
+	Since time.Time
+	Until time.Time
+}
+
+// where returns the SQL condition on the session alias s and its arguments,
+// numbered from the first placeholder.
+func (r StatsRange) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if !r.Since.IsZero() {
+		args = append(args, r.Since.UTC().Format("2006-01-02 15:04:05"))
+		conditions = append(conditions, fmt.Sprintf("julianday(s.start_time) >= julianday($%d)", len(args)))
+	}
+	if !r.Until.IsZero() {
+		args = append(args, r.Until.UTC().Format("2006-01-02 15:04:05"))
+		conditions = append(conditions, fmt.Sprintf("julianday(s.start_time) < julianday($%d)", len(args)))
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// DailyStats holds the totals of the sessions started on one UTC day.
+type DailyStats struct {
+	Date           string `db:"date" json:"date"`
+	Sessions       int64  `db:"sessions" json:"sessions"`
+	CompletedFiles int64  `db:"completed_files" json:"completed_files"`
+	FailedFiles    int64  `db:"failed_files" json:"failed_files"`
+	CompletedBytes int64  `db:"completed_bytes" json:"completed_bytes"`
+}
+
+// GlobalStats aggregates the sessions recorded in the state database.
+type GlobalStats struct {
+	SessionsByStatus      map[string]int64 `json:"sessions_by_status"`
+	FilesByStatus         map[string]int64 `json:"files_by_status"`
+	Daily                 []*DailyStats    `json:"daily"`
+	Sessions              int64            `json:"sessions"`
+	TotalFiles            int64            `json:"total_files"`
+	TotalBytes            int64            `json:"total_bytes"`
+	CompletedBytes        int64            `json:"completed_bytes"`
+	Duration              time.Duration    `json:"duration"`
+	AverageBytesPerSecond float64          `json:"average_bytes_per_second"`
+	SuccessRate           float64          `json:"success_rate"`
+}
+
+// GetGlobalStats sums the sessions started within r. It reads the counters
+// kept on each session, so archived sessions are included. Duration adds up
+// how long each session ran, up to its last update if it has not ended, and
+// SuccessRate is the share of completed files among completed and failed.
+func (q *QueryBuilder) GetGlobalStats(ctx context.Context, r StatsRange) (*GlobalStats, error) {
+	where, args := r.where()
+
+	stats := &GlobalStats{
+		SessionsByStatus: make(map[string]int64),
+		FilesByStatus:    make(map[string]int64),
+		Daily:            []*DailyStats{},
+	}
+
+	query := `
+    SELECT
+      s.status,
+      COUNT(*) as sessions,
+      COALESCE(SUM(s.total_files), 0) as total_files,
+      COALESCE(SUM(s.completed_files), 0) as completed_files,
+      COALESCE(SUM(s.failed_files), 0) as failed_files,
+      COALESCE(SUM(s.skipped_files), 0) as skipped_files,
+      COALESCE(SUM(s.total_bytes), 0) as total_bytes,
+      COALESCE(SUM(s.completed_bytes), 0) as completed_bytes,
+      COALESCE(SUM(MAX(0, julianday(COALESCE(s.end_time, s.updated_at)) - julianday(s.start_time))), 0) * 86400 as seconds
+    FROM sessions s
+    WHERE ` + where + `
+    GROUP BY s.status
+    ORDER BY s.status`
+
+	var rows []struct {
+		Status         string  `db:"status"`
+		Sessions       int64   `db:"sessions"`
+		TotalFiles     int64   `db:"total_files"`
+		CompletedFiles int64   `db:"completed_files"`
+		FailedFiles    int64   `db:"failed_files"`
+		SkippedFiles   int64   `db:"skipped_files"`
+		TotalBytes     int64   `db:"total_bytes"`
+		CompletedBytes int64   `db:"completed_bytes"`
+		Seconds        float64 `db:"seconds"`
+	}
+	if err := q.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get session totals: %w", err)
+	}
+
+	var seconds float64
+	for _, row := range rows {
+		stats.SessionsByStatus[row.Status] = row.Sessions
+		stats.Sessions += row.Sessions
+		stats.TotalFiles += row.TotalFiles
+		stats.FilesByStatus[FileStatusCompleted] += row.CompletedFiles
+		stats.FilesByStatus[FileStatusFailed] += row.FailedFiles
+		stats.FilesByStatus[FileStatusSkipped] += row.SkippedFiles
+		stats.TotalBytes += row.TotalBytes
+		stats.CompletedBytes += row.CompletedBytes
+		seconds += row.Seconds
+	}
+
+	// Whatever is neither completed, failed nor skipped has not finished yet
+	finished := stats.FilesByStatus[FileStatusCompleted] + stats.FilesByStatus[FileStatusFailed] +
+		stats.FilesByStatus[FileStatusSkipped]
+	stats.FilesByStatus[FileStatusPending] = max(0, stats.TotalFiles-finished)
+
+	stats.Duration = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	if seconds > 0 {
+		stats.AverageBytesPerSecond = float64(stats.CompletedBytes) / seconds
+	}
+	if attempted := stats.FilesByStatus[FileStatusCompleted] + stats.FilesByStatus[FileStatusFailed]; attempted > 0 {
+		stats.SuccessRate = float64(stats.FilesByStatus[FileStatusCompleted]) / float64(attempted)
+	}
+
+	dailyQuery := `
+    SELECT
+      date(s.start_time) as date,
+      COUNT(*) as sessions,
+      COALESCE(SUM(s.completed_files), 0) as completed_files,
+      COALESCE(SUM(s.failed_files), 0) as failed_files,
+      COALESCE(SUM(s.completed_bytes), 0) as completed_bytes
+    FROM sessions s
+    WHERE ` + where + `
+    GROUP BY date
+    ORDER BY date`
+
+	if err := q.db.SelectContext(ctx, &stats.Daily, dailyQuery, args...); err != nil {
+		return nil, fmt.Errorf("failed to get daily totals: %w", err)
+	}
 
 	return stats, nil
 }
@@ -300,7 +629,8 @@ type DuplicateFile struct {
 	Size     int64  `db:"size" json:"size"`
 }
 
-// FindDuplicates finds duplicate files in a session.
+// FindDuplicates finds pairs of files in a session with the same name and
+// size. FindDuplicateGroups matches files by content instead.
 func (q *QueryBuilder) FindDuplicates(ctx context.Context, sessionID string) ([]*DuplicateFile, error) {
 	query := `
     SELECT
@@ -330,6 +660,80 @@ func (q *QueryBuilder) FindDuplicates(ctx context.Context, sessionID string) ([]
 	return duplicates, nil
 }
 
+// DuplicateGroup is a set of downloaded files with the same content.
+type DuplicateGroup struct {
+	Checksum string  `json:"checksum"`
+	Files    []*File `json:"files"`
+	Size     int64   `json:"size"`
+}
+
+// ReclaimableBytes returns the space freed by keeping a single copy.
+func (g *DuplicateGroup) ReclaimableBytes() int64 {
+	return g.Size * int64(len(g.Files)-1)
+}
+
+// DuplicateReport lists the duplicate groups of a session.
+type DuplicateReport struct {
+	Groups           []*DuplicateGroup `json:"groups"`
+	ReclaimableBytes int64             `json:"reclaimable_bytes"`
+}
+
+// contentChecksumSQL is the MD5 identifying a file's content: Drive's
+// checksum, or the locally computed one for files Drive does not hash.
+const contentChecksumSQL = `COALESCE(NULLIF(md5_checksum, ''), NULLIF(local_md5, ''))`
+
+// contentChecksum mirrors contentChecksumSQL for a loaded file.
+func contentChecksum(file *File) string {
+	if file.MD5Checksum.Valid && file.MD5Checksum.String != "" {
+		return file.MD5Checksum.String
+	}
+	return file.LocalMD5.String
+}
+
+// FindDuplicateGroups groups the completed files of a session by content
+// checksum and size. Only groups of two or more files are returned, largest
+// files first, with each group's files ordered by path. Files without any
+// checksum are ignored.
+func (q *QueryBuilder) FindDuplicateGroups(ctx context.Context, sessionID string) (*DuplicateReport, error) {
+	query := `
+    SELECT * FROM files
+    WHERE session_id = $1
+      AND status = $2
+      AND ` + contentChecksumSQL + ` IS NOT NULL
+      AND (` + contentChecksumSQL + `, size) IN (
+        SELECT ` + contentChecksumSQL + `, size FROM files
+        WHERE session_id = $1
+          AND status = $2
+          AND ` + contentChecksumSQL + ` IS NOT NULL
+        GROUP BY 1, 2
+        HAVING COUNT(*) > 1
+      )
+    ORDER BY size DESC, ` + contentChecksumSQL + `, path`
+
+	var files []*File
+	err := q.db.SelectContext(ctx, &files, query, sessionID, FileStatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find duplicate groups: %w", err)
+	}
+
+	report := &DuplicateReport{}
+	var group *DuplicateGroup
+	for _, file := range files {
+		checksum := contentChecksum(file)
+		if group == nil || group.Checksum != checksum || group.Size != file.Size {
+			group = &DuplicateGroup{Checksum: checksum, Size: file.Size}
+			report.Groups = append(report.Groups, group)
+		}
+		group.Files = append(group.Files, file)
+	}
+
+	for _, group := range report.Groups {
+		report.ReclaimableBytes += group.ReclaimableBytes()
+	}
+
+	return report, nil
+}
+
 // SearchFiles searches for files by name pattern.
 func (q *QueryBuilder) SearchFiles(ctx context.Context, sessionID string, pattern string, limit int) ([]*File, error) {
 	// Escape special characters and add wildcards
@@ -393,10 +797,10 @@ func (q *QueryBuilder) CleanupOldSessions(ctx context.Context, olderThan time.Du
 	query := `
     DELETE FROM sessions
     WHERE created_at < $1
-      AND status IN ($2, $3, $4)`
+      AND status IN ($2, $3, $4, $5)`
 
 	result, err := q.db.ExecContext(ctx, query, cutoff,
-		SessionStatusCompleted, SessionStatusFailed, SessionStatusCancelled)
+		SessionStatusCompleted, SessionStatusFailed, SessionStatusCancelled, SessionStatusCompletedWithErrors)
 	if err != nil {
 		return 0, fmt.Errorf("failed to cleanup old sessions: %w", err)
 	}
@@ -0,0 +1,313 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestManager creates a state manager backed by a temporary database.
+func newTestManager(t testing.TB) *Manager {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "cloudpull.db")
+
+	manager, err := NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Close() })
+
+	return manager
+}
+
+// createTestFolder creates a folder record under parent (nil for root).
+func createTestFolder(t *testing.T, m *Manager, sessionID, name string, parent *Folder) *Folder {
+	t.Helper()
+
+	folder := &Folder{
+		DriveID:   "drive-" + name,
+		SessionID: sessionID,
+		Name:      name,
+		Path:      name,
+		Status:    FolderStatusScanned,
+	}
+	if parent != nil {
+		folder.ParentID = NewNullString(parent.ID)
+		folder.Path = parent.Path + "/" + name
+	}
+
+	require.NoError(t, m.CreateFolder(context.Background(), folder))
+	return folder
+}
+
+// createTestFile creates a file record in folder.
+func createTestFile(t *testing.T, m *Manager, folder *Folder, name string, size, downloaded int64) *File {
+	t.Helper()
+
+	file := &File{
+		DriveID:         "drive-" + folder.Name + "-" + name,
+		FolderID:        folder.ID,
+		SessionID:       folder.SessionID,
+		Name:            name,
+		Path:            folder.Path + "/" + name,
+		Size:            size,
+		BytesDownloaded: downloaded,
+		Status:          FileStatusPending,
+	}
+
+	require.NoError(t, m.Files().Create(context.Background(), file))
+	return file
+}
+
+func TestGetFolderTreeRollup(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	docs := createTestFolder(t, m, session.ID, "docs", root)
+	deep := createTestFolder(t, m, session.ID, "deep", docs)
+	media := createTestFolder(t, m, session.ID, "media", root)
+
+	createTestFile(t, m, root, "a.txt", 100, 100)
+	createTestFile(t, m, docs, "b.txt", 200, 50)
+	createTestFile(t, m, deep, "c.txt", 300, 0)
+	createTestFile(t, m, media, "d.mp4", 1000, 1000)
+
+	// Top level rolls up the whole tree
+	top, err := m.Queries().GetFolderTreeRollup(ctx, session.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, top, 1)
+	assert.Equal(t, root.ID, top[0].ID)
+	assert.Equal(t, int64(2), top[0].ChildCount)
+	assert.Equal(t, int64(4), top[0].FileCount)
+	assert.Equal(t, int64(1600), top[0].TotalSize)
+	assert.Equal(t, int64(1150), top[0].DownloadSize)
+
+	// Children include their own descendants only
+	children, err := m.Queries().GetFolderTreeRollup(ctx, session.ID, &root.ID)
+	require.NoError(t, err)
+	require.Len(t, children, 2)
+
+	assert.Equal(t, "docs", children[0].Name)
+	assert.Equal(t, root.ID, children[0].ParentID)
+	assert.Equal(t, int64(2), children[0].FileCount)
+	assert.Equal(t, int64(500), children[0].TotalSize)
+	assert.Equal(t, int64(50), children[0].DownloadSize)
+
+	assert.Equal(t, "media", children[1].Name)
+	assert.Equal(t, int64(1), children[1].FileCount)
+	assert.Equal(t, int64(1000), children[1].DownloadSize)
+
+	// The non-rollup query still reports immediate files only
+	flat, err := m.Queries().GetFolderTree(ctx, session.ID, &root.ID)
+	require.NoError(t, err)
+	require.Len(t, flat, 2)
+	assert.Equal(t, int64(1), flat[0].FileCount)
+	assert.Equal(t, int64(200), flat[0].TotalSize)
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+
+	complete := func(name string, size int64, driveMD5, localMD5 string) *File {
+		file := createTestFile(t, m, root, name, size, size)
+		file.Status = FileStatusCompleted
+		file.MD5Checksum = NewNullString(driveMD5)
+		file.LocalMD5 = NewNullString(localMD5)
+		require.NoError(t, m.Files().Update(ctx, file))
+		return file
+	}
+
+	// Three copies under different names
+	complete("a.bin", 100, "aaaa", "")
+	complete("b.bin", 100, "aaaa", "")
+	complete("copy of a.bin", 100, "aaaa", "")
+	// Exports are matched by their local checksum
+	complete("doc.pdf", 50, "", "dddd")
+	complete("doc copy.pdf", 50, "", "dddd")
+	// Same checksum but different size is not a duplicate
+	complete("c.bin", 10, "cccc", "")
+	complete("c-truncated.bin", 9, "cccc", "")
+	// Files without checksums are ignored
+	complete("x.bin", 5, "", "")
+	complete("y.bin", 5, "", "")
+	// Pending files are not on disk yet
+	pending := createTestFile(t, m, root, "pending.bin", 100, 0)
+	pending.MD5Checksum = NewNullString("aaaa")
+	require.NoError(t, m.Files().Update(ctx, pending))
+
+	report, err := m.Queries().FindDuplicateGroups(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 2)
+
+	group := report.Groups[0]
+	assert.Equal(t, "aaaa", group.Checksum)
+	assert.Equal(t, int64(100), group.Size)
+	require.Len(t, group.Files, 3)
+	assert.Equal(t, "root/a.bin", group.Files[0].Path)
+	assert.Equal(t, int64(200), group.ReclaimableBytes())
+
+	group = report.Groups[1]
+	assert.Equal(t, "dddd", group.Checksum)
+	assert.Len(t, group.Files, 2)
+
+	assert.Equal(t, int64(250), report.ReclaimableBytes)
+}
+
+func TestGetTransferStatsBucketsCompletedFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	start := time.Date(2025, 1, 30, 10, 0, 0, 0, time.UTC)
+	_, err = m.db.ExecContext(ctx, `UPDATE sessions SET start_time = $1, end_time = $2 WHERE id = $3`,
+		start, start.Add(4*time.Minute+30*time.Second), session.ID)
+	require.NoError(t, err)
+
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	complete := func(name string, size int64, after time.Duration) {
+		file := createTestFile(t, m, root, name, size, size)
+		_, err := m.db.ExecContext(ctx, `UPDATE files SET status = $1, completed_at = $2 WHERE id = $3`,
+			FileStatusCompleted, start.Add(after), file.ID)
+		require.NoError(t, err)
+	}
+	complete("a.txt", 600, 10*time.Second)
+	complete("b.txt", 1200, 50*time.Second)
+	createTestFile(t, m, root, "pending.txt", 999, 0)
+
+	// Files completed by older versions are bucketed by their last update
+	legacy := createTestFile(t, m, root, "c.txt", 6000, 6000)
+	_, err = m.db.ExecContext(ctx, `UPDATE files SET status = $1, updated_at = $2 WHERE id = $3`,
+		FileStatusCompleted, start.Add(2*time.Minute+5*time.Second).Format("2006-01-02 15:04:05"), legacy.ID)
+	require.NoError(t, err)
+
+	// Later updates of a completed file do not move it
+	_, err = m.db.ExecContext(ctx, `UPDATE files SET conflict_decision = 'overwrote', updated_at = $1 WHERE name = 'a.txt'`,
+		start.Add(4*time.Minute).Format("2006-01-02 15:04:05"))
+	require.NoError(t, err)
+
+	stats, err := m.Queries().GetTransferStats(ctx, session.ID, time.Minute)
+	require.NoError(t, err)
+
+	// Buckets run to the session end, including those without downloads
+	require.Len(t, stats, 5)
+	assert.Equal(t, start, stats[0].Timestamp.UTC())
+	assert.Equal(t, start.Add(2*time.Minute), stats[2].Timestamp.UTC())
+
+	assert.Equal(t, int64(2), stats[0].Files)
+	assert.Equal(t, int64(1800), stats[0].Bytes)
+	assert.Equal(t, 30.0, stats[0].BytesPerSecond)
+	assert.Equal(t, 2.0, stats[0].FilesPerMinute)
+
+	assert.Zero(t, stats[1].Files)
+	assert.Equal(t, int64(1800), stats[1].CumulativeBytes)
+
+	assert.Equal(t, int64(6000), stats[2].Bytes)
+	assert.Equal(t, int64(3), stats[4].CumulativeFiles)
+	assert.Equal(t, int64(7800), stats[4].CumulativeBytes)
+
+	final, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	summary := SummarizeTransfers(final, stats)
+	assert.Equal(t, 4*time.Minute+30*time.Second, summary.Duration)
+	assert.Equal(t, int64(3), summary.CompletedFiles)
+	assert.Equal(t, int64(7800), summary.CompletedBytes)
+	assert.Equal(t, 100.0, summary.PeakBytesPerSecond)
+	assert.InDelta(t, 7800.0/270, summary.AverageBytesPerSecond, 0.001)
+
+	_, err = m.Queries().GetTransferStats(ctx, "missing", time.Minute)
+	assert.ErrorContains(t, err, "session not found")
+
+	// A long session is not split into unbounded buckets
+	_, err = m.db.ExecContext(ctx, `UPDATE sessions SET end_time = $1 WHERE id = $2`,
+		start.Add(30*24*time.Hour), session.ID)
+	require.NoError(t, err)
+	_, err = m.Queries().GetTransferStats(ctx, session.ID, time.Second)
+	assert.ErrorContains(t, err, "use a longer interval")
+}
+
+func TestUpdateStatusRecordsCompletionTime(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+	require.NoError(t, err)
+	root := createTestFolder(t, m, session.ID, "root", nil)
+	file := createTestFile(t, m, root, "a.txt", 10, 0)
+
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, m.UpdateFileStatuses(ctx, map[string]string{file.ID: FileStatusCompleted}))
+
+	stored, err := m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	require.True(t, stored.CompletedAt.Valid)
+	assert.True(t, stored.CompletedAt.Time.After(before))
+
+	// Other statuses keep the recorded time
+	require.NoError(t, m.Files().UpdateStatus(ctx, file.ID, FileStatusPending))
+	requeued, err := m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	assert.True(t, requeued.CompletedAt.Time.Equal(stored.CompletedAt.Time))
+}
+
+func TestGetGlobalStatsSumsSessions(t *testing.T) {
+	ctx := context.Background()
+	m := newTestManager(t)
+
+	record := func(status string, start time.Time, took time.Duration, completed, failed, skipped, total, bytes int64) {
+		session, err := m.CreateSession(ctx, "root-id", "Root", "/tmp/dest")
+		require.NoError(t, err)
+		_, err = m.db.ExecContext(ctx, `
+      UPDATE sessions SET status = $1, start_time = $2, end_time = $3,
+        completed_files = $4, failed_files = $5, skipped_files = $6,
+        total_files = $7, completed_bytes = $8
+      WHERE id = $9`,
+			status, start, start.Add(took), completed, failed, skipped, total, bytes, session.ID)
+		require.NoError(t, err)
+	}
+
+	day := time.Date(2025, 1, 28, 9, 0, 0, 0, time.UTC)
+	record(SessionStatusCompleted, day, time.Minute, 8, 0, 2, 10, 6000)
+	record(SessionStatusCompletedWithErrors, day.Add(2*time.Hour), time.Minute, 6, 2, 0, 10, 6000)
+	record(SessionStatusCancelled, day.Add(48*time.Hour), 2*time.Minute, 2, 0, 0, 5, 1200)
+
+	stats, err := m.Queries().GetGlobalStats(ctx, StatsRange{})
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), stats.Sessions)
+	assert.Equal(t, int64(1), stats.SessionsByStatus[SessionStatusCancelled])
+	assert.Equal(t, int64(16), stats.FilesByStatus[FileStatusCompleted])
+	assert.Equal(t, int64(2), stats.FilesByStatus[FileStatusFailed])
+	assert.Equal(t, int64(2), stats.FilesByStatus[FileStatusSkipped])
+	assert.Equal(t, int64(5), stats.FilesByStatus[FileStatusPending])
+	assert.Equal(t, int64(13200), stats.CompletedBytes)
+	assert.Equal(t, 4*time.Minute, stats.Duration)
+	assert.InDelta(t, 13200.0/240, stats.AverageBytesPerSecond, 0.01)
+	assert.InDelta(t, 16.0/18, stats.SuccessRate, 0.0001)
+
+	require.Len(t, stats.Daily, 2)
+	assert.Equal(t, "2025-01-28", stats.Daily[0].Date)
+	assert.Equal(t, int64(2), stats.Daily[0].Sessions)
+	assert.Equal(t, int64(12000), stats.Daily[0].CompletedBytes)
+	assert.Equal(t, "2025-01-30", stats.Daily[1].Date)
+
+	// The range keeps only the sessions started within it
+	ranged, err := m.Queries().GetGlobalStats(ctx, StatsRange{Since: day.Add(time.Hour), Until: day.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), ranged.Sessions)
+	assert.Equal(t, int64(6000), ranged.CompletedBytes)
+	assert.Equal(t, 0.75, ranged.SuccessRate)
+}
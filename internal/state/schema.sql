@@ -12,13 +12,15 @@ CREATE TABLE IF NOT EXISTS sessions (
     destination_path TEXT NOT NULL,
     start_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     end_time TIMESTAMP,
-    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled')),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'completed', 'failed', 'cancelled', 'stopped_quota', 'scanned', 'completed_with_errors', 'stalled')),
     total_files INTEGER DEFAULT 0,
     completed_files INTEGER DEFAULT 0,
     failed_files INTEGER DEFAULT 0,
     skipped_files INTEGER DEFAULT 0,
     total_bytes INTEGER DEFAULT 0,
     completed_bytes INTEGER DEFAULT 0,
+    flatten BOOLEAN DEFAULT FALSE,
+    heartbeat_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -31,7 +33,7 @@ CREATE TABLE IF NOT EXISTS folders (
     session_id TEXT NOT NULL,
     name TEXT NOT NULL,
     path TEXT NOT NULL,
-    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'scanning', 'scanned', 'failed')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'scanning', 'scanned', 'failed', 'skipped')),
     error_message TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -53,12 +55,20 @@ CREATE TABLE IF NOT EXISTS files (
     mime_type TEXT,
     is_google_doc BOOLEAN DEFAULT FALSE,
     export_mime_type TEXT,
-    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'downloading', 'completed', 'failed', 'skipped')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'queued', 'downloading', 'completed', 'failed', 'skipped')),
     bytes_downloaded INTEGER DEFAULT 0,
     download_attempts INTEGER DEFAULT 0,
     error_message TEXT,
     drive_modified_time TIMESTAMP,
     local_modified_time TIMESTAMP,
+    local_path TEXT,
+    local_md5 TEXT,
+    local_sha256 TEXT,
+    drive_created_time TIMESTAMP,
+    owner_email TEXT,
+    cas_path TEXT,
+    conflict_decision TEXT,
+    completed_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(drive_id, session_id),
@@ -66,6 +76,17 @@ CREATE TABLE IF NOT EXISTS files (
     FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
 );
 
+-- File aliases table (further paths of files listed in several folders)
+CREATE TABLE IF NOT EXISTS file_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    drive_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(session_id, drive_id, path),
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
 -- Download chunks table (for resumable downloads)
 CREATE TABLE IF NOT EXISTS download_chunks (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -81,6 +102,17 @@ CREATE TABLE IF NOT EXISTS download_chunks (
     FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
 );
 
+-- Download journal (last offset of a partial download flushed to disk)
+CREATE TABLE IF NOT EXISTS download_journal (
+    file_id TEXT PRIMARY KEY,
+    temp_path TEXT NOT NULL,
+    flushed_bytes INTEGER NOT NULL DEFAULT 0,
+    size INTEGER NOT NULL,
+    md5_checksum TEXT,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (file_id) REFERENCES files(id) ON DELETE CASCADE
+);
+
 -- Error log table
 CREATE TABLE IF NOT EXISTS error_log (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -93,10 +125,52 @@ CREATE TABLE IF NOT EXISTS error_log (
     stack_trace TEXT,
     retry_count INTEGER DEFAULT 0,
     is_retryable BOOLEAN DEFAULT TRUE,
+    trace_id TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+-- Mirror deletions table (audit log of local entries removed by --mirror)
+CREATE TABLE IF NOT EXISTS mirror_deletions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('file', 'folder')),
+    trash_path TEXT,
+    dry_run BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
 );
 
+-- Events table (opt-in log of file progress events for debugging)
+CREATE TABLE IF NOT EXISTS events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    item_id TEXT,
+    item_path TEXT,
+    bytes INTEGER DEFAULT 0,
+    message TEXT,
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+-- Session archive table (summaries of sessions whose file and folder rows were pruned)
+CREATE TABLE IF NOT EXISTS session_archive (
+    session_id TEXT PRIMARY KEY,
+    total_files INTEGER DEFAULT 0,
+    completed_files INTEGER DEFAULT 0,
+    failed_files INTEGER DEFAULT 0,
+    skipped_files INTEGER DEFAULT 0,
+    total_folders INTEGER DEFAULT 0,
+    total_bytes INTEGER DEFAULT 0,
+    completed_bytes INTEGER DEFAULT 0,
+    duration_seconds INTEGER DEFAULT 0,
+    archived_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
 -- Configuration table
 CREATE TABLE IF NOT EXISTS config (
     key TEXT PRIMARY KEY,
@@ -105,6 +179,16 @@ CREATE TABLE IF NOT EXISTS config (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Settings a session was started with, applied again when it is resumed
+CREATE TABLE IF NOT EXISTS session_config (
+    session_id TEXT NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, key),
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_folders_drive_id ON folders(drive_id);
 CREATE INDEX IF NOT EXISTS idx_folders_status ON folders(status);
@@ -121,6 +205,10 @@ CREATE INDEX IF NOT EXISTS idx_chunks_status ON download_chunks(status);
 CREATE INDEX IF NOT EXISTS idx_errors_session_id ON error_log(session_id);
 CREATE INDEX IF NOT EXISTS idx_errors_item_id ON error_log(item_id);
 
+CREATE INDEX IF NOT EXISTS idx_mirror_deletions_session_id ON mirror_deletions(session_id);
+CREATE INDEX IF NOT EXISTS idx_events_session_id ON events(session_id);
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
+
 -- Triggers for updated_at
 CREATE TRIGGER IF NOT EXISTS update_sessions_timestamp
     AFTER UPDATE ON sessions
@@ -192,5 +280,5 @@ SELECT
     fo.path as folder_path
 FROM files f
 JOIN folders fo ON f.folder_id = fo.id
-WHERE f.status IN ('pending', 'downloading')
+WHERE f.status IN ('pending', 'queued', 'downloading')
 ORDER BY f.size ASC; -- Download smaller files first
@@ -39,11 +39,11 @@ func (s *SessionStore) Create(ctx context.Context, session *Session) error {
     INSERT INTO sessions (
       root_folder_id, root_folder_name, destination_path,
       status, total_files, completed_files, failed_files,
-      skipped_files, total_bytes, completed_bytes
+      skipped_files, total_bytes, completed_bytes, flatten
     ) VALUES (
       :root_folder_id, :root_folder_name, :destination_path,
       :status, :total_files, :completed_files, :failed_files,
-      :skipped_files, :total_bytes, :completed_bytes
+      :skipped_files, :total_bytes, :completed_bytes, :flatten
     ) RETURNING id, created_at, updated_at, start_time`
 
 	stmt, err := s.db.PrepareNamedContext(ctx, query)
@@ -137,6 +137,7 @@ func (s *SessionStore) Update(ctx context.Context, session *Session) error {
       skipped_files = :skipped_files,
       total_bytes = :total_bytes,
       completed_bytes = :completed_bytes,
+      flatten = :flatten,
       updated_at = :updated_at
     WHERE id = :id`
 
@@ -180,6 +181,28 @@ func (s *SessionStore) UpdateStatus(ctx context.Context, id, status string) erro
 	return nil
 }
 
+// Heartbeat records that the session is still being synced.
+func (s *SessionStore) Heartbeat(ctx context.Context, id string) error {
+	query := `UPDATE sessions SET heartbeat_at = $1 WHERE id = $2`
+
+	if _, err := s.db.ExecContext(ctx, query, time.Now().UTC(), id); err != nil {
+		return fmt.Errorf("failed to record session heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// ClearHeartbeat records that the session is no longer being synced.
+func (s *SessionStore) ClearHeartbeat(ctx context.Context, id string) error {
+	query := `UPDATE sessions SET heartbeat_at = NULL WHERE id = $1`
+
+	if _, err := s.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to clear session heartbeat: %w", err)
+	}
+
+	return nil
+}
+
 // UpdateProgress updates session progress counters.
 func (s *SessionStore) UpdateProgress(ctx context.Context, id string, delta SessionProgressDelta) error {
 	query := `
@@ -365,10 +388,11 @@ func (s *SessionStore) GetResumableSessions(ctx context.Context) ([]*Session, er
 	var sessions []*Session
 	query := `
     SELECT * FROM sessions
-    WHERE status IN ($1, $2)
+    WHERE status IN ($1, $2, $3, $4)
     ORDER BY start_time DESC`
 
-	err := s.db.SelectContext(ctx, &sessions, query, SessionStatusPaused, SessionStatusFailed)
+	err := s.db.SelectContext(ctx, &sessions, query,
+		SessionStatusPaused, SessionStatusFailed, SessionStatusStoppedQuota, SessionStatusStalled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get resumable sessions: %w", err)
 	}
@@ -376,6 +400,54 @@ func (s *SessionStore) GetResumableSessions(ctx context.Context) ([]*Session, er
 	return sessions, nil
 }
 
+// GetLatestResumable retrieves the most recently started session that can
+// be resumed: active sessions whose process stopped, paused, failed and
+// quota-stopped sessions. It returns nil if there is none.
+func (s *SessionStore) GetLatestResumable(ctx context.Context) (*Session, error) {
+	var session Session
+	query := `
+    SELECT * FROM sessions
+    WHERE status IN ($1, $2, $3, $4, $5)
+    ORDER BY start_time DESC
+    LIMIT 1`
+
+	err := s.db.GetContext(ctx, &session, query,
+		SessionStatusActive, SessionStatusPaused, SessionStatusFailed, SessionStatusStoppedQuota,
+		SessionStatusStalled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest resumable session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// FindByRoot retrieves the most recently started session of rootFolderID
+// into destinationPath that can be resumed, or nil if there is none.
+func (s *SessionStore) FindByRoot(ctx context.Context, rootFolderID, destinationPath string) (*Session, error) {
+	var session Session
+	query := `
+    SELECT * FROM sessions
+    WHERE root_folder_id = $1 AND destination_path = $2
+      AND status IN ($3, $4, $5, $6, $7)
+    ORDER BY start_time DESC
+    LIMIT 1`
+
+	err := s.db.GetContext(ctx, &session, query, rootFolderID, destinationPath,
+		SessionStatusActive, SessionStatusPaused, SessionStatusFailed, SessionStatusStoppedQuota,
+		SessionStatusStalled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find session by root folder: %w", err)
+	}
+
+	return &session, nil
+}
+
 // SessionProgressDelta represents changes to session progress counters.
 // Thread-Safety: This struct is NOT thread-safe. It is designed to be used
 // as a simple data container for passing progress updates. External
@@ -391,6 +463,11 @@ type SessionProgressDelta struct {
 	CompletedBytes int64
 }
 
+// IsZero reports whether the delta changes no counter.
+func (d SessionProgressDelta) IsZero() bool {
+	return d == SessionProgressDelta{}
+}
+
 // WithTx returns a SessionStore that uses the given transaction.
 func (s *SessionStore) WithTx(tx *sqlx.Tx) *SessionStore {
 	return &SessionStore{
@@ -46,7 +46,7 @@ type State interface {
 	UpdateSessionProgress(ctx context.Context, sessionID string, fileCompleted bool, bytesCompleted int64, failed bool) error
 
 	// File operations
-	GetNextPendingFile(ctx context.Context, sessionID string) (*File, error)
+	GetNextPendingFile(ctx context.Context, sessionID, order string) (*File, error)
 	MarkFileComplete(ctx context.Context, fileID, sessionID string) error
 	MarkFileFailed(ctx context.Context, fileID, sessionID string, err error) error
 
@@ -80,6 +80,12 @@ func IsRetryableError(err error) bool {
 		return false
 	}
 
+	// Errors classified where they occurred keep their type
+	var typed *errors.Error
+	if errors.AsError(err, &typed) {
+		return typed.IsRetryable()
+	}
+
 	// Use the errors package for sophisticated error classification
 	if errorType := errors.GetErrorType(err); errorType != errors.ErrorTypeUnknown {
 		return errorType.IsRetryable()
@@ -0,0 +1,152 @@
+/**
+ * Content-Addressed Storage for CloudPull Sync Engine
+ *
+ * Features:
+ * - Stores each distinct file content once below .cas/<prefix>/<md5>
+ * - Links the Drive layout to the stored content with symlinks or hard links
+ * - Skips writing content that is already stored
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+)
+
+// CASDirName is the directory in the destination holding stored content.
+const CASDirName = ".cas"
+
+// casLockCount is the number of locks that serialize storing content; each
+// MD5 maps to one of them.
+const casLockCount = 64
+
+// casLinkSuffix names the link created next to a logical path before it
+// replaces it.
+const casLinkSuffix = ".cloudpull-link"
+
+// CASMode selects whether downloads are stored by content and how logical
+// paths refer to the stored content.
+type CASMode string
+
+const (
+	// CASOff writes every download to its logical path.
+	CASOff CASMode = "off"
+
+	// CASSymlink makes logical paths relative symlinks to stored content.
+	CASSymlink CASMode = "symlink"
+
+	// CASHardlink makes logical paths hard links to stored content.
+	CASHardlink CASMode = "hardlink"
+)
+
+// ParseCASMode parses a mode name: off, symlink or hardlink. An empty name
+// selects off.
+func ParseCASMode(name string) (CASMode, error) {
+	switch mode := CASMode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return CASOff, nil
+	case CASOff, CASSymlink, CASHardlink:
+		return mode, nil
+	default:
+		return CASOff, errors.Errorf("unknown cas mode %q", name)
+	}
+}
+
+// enabled reports whether downloads are stored by content.
+func (m CASMode) enabled() bool {
+	return m == CASSymlink || m == CASHardlink
+}
+
+// CASBlobPath returns where content with the given MD5 is stored below
+// destination.
+func CASBlobPath(destination, md5 string) string {
+	return filepath.Join(destination, CASDirName, md5[:2], md5)
+}
+
+// storeInCAS moves the download at tempPath to the blob of its MD5 below
+// destination, or removes it when that content is stored already, and links
+// finalPath to the blob. It returns the blob path.
+func (dm *DownloadManager) storeInCAS(ctx context.Context, log *logger.Logger, destination, tempPath, finalPath, md5 string) (string, error) {
+	blob := CASBlobPath(destination, md5)
+
+	// Workers finishing the same content at once would otherwise both move
+	// onto the blob, leaving earlier hard links on a replaced inode
+	lock := dm.casLock(md5)
+	lock.Lock()
+	defer lock.Unlock()
+
+	_, err := os.Stat(blob)
+	switch {
+	case err == nil:
+		log.Debug("Content already stored, linking to it", "path", finalPath, "blob", blob)
+		if err := os.Remove(tempPath); err != nil {
+			log.Error(err, "failed to remove temp file of stored content", "path", tempPath)
+		}
+	case os.IsNotExist(err):
+		if err := dm.moveToFinal(ctx, tempPath, blob); err != nil {
+			return "", err
+		}
+	default:
+		return "", errors.Wrap(err, "failed to check stored content")
+	}
+
+	if err := dm.linkToBlob(blob, finalPath); err != nil {
+		return "", err
+	}
+	return blob, nil
+}
+
+// casLock returns the lock guarding the blob of md5.
+func (dm *DownloadManager) casLock(md5 string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(md5))
+	return &dm.casLocks[h.Sum32()%casLockCount]
+}
+
+// linkToBlob points finalPath at blob. The link is created next to
+// finalPath and renamed over it, so an existing link is replaced rather
+// than written through, which would change the stored content.
+func (dm *DownloadManager) linkToBlob(blob, finalPath string) error {
+	if err := os.MkdirAll(filepath.Dir(finalPath), dm.dirMode); err != nil {
+		return errors.Wrap(err, "failed to create destination directory")
+	}
+
+	link := finalPath + casLinkSuffix
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove stale link")
+	}
+
+	var err error
+	if dm.casMode == CASHardlink {
+		err = os.Link(blob, link)
+	} else {
+		// Relative targets keep the destination usable after moving it
+		target, relErr := filepath.Rel(filepath.Dir(finalPath), blob)
+		if relErr != nil {
+			target = blob
+		}
+		err = os.Symlink(target, link)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to link to stored content")
+	}
+
+	if err := os.Rename(link, finalPath); err != nil {
+		if removeErr := os.Remove(link); removeErr != nil {
+			dm.logger.Error(removeErr, "failed to remove link after rename failure", "path", link)
+		}
+		return errors.Wrap(err, "failed to replace file with link")
+	}
+	return dm.syncFinalDir(finalPath)
+}
@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/api/apitest"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func md5Hex(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestEngineLinksDuplicatesToStoredContent(t *testing.T) {
+	for _, mode := range []CASMode{CASSymlink, CASHardlink} {
+		t.Run(string(mode), func(t *testing.T) {
+			ctx := context.Background()
+			m := newTestStateManager(t)
+			dest := t.TempDir()
+
+			fake := apitest.NewFakeDrive()
+			fake.AddFile("root", "a", "a.txt", "shared content")
+			fake.AddFolder("root", "sub", "sub")
+			fake.AddFile("sub", "b", "b.txt", "shared content")
+			fake.AddFile("root", "c", "c.txt", "stored before")
+
+			// Content of c.txt is already in the store and must not be written
+			storedBefore := CASBlobPath(dest, md5Hex("stored before"))
+			require.NoError(t, os.MkdirAll(filepath.Dir(storedBefore), 0755))
+			require.NoError(t, os.WriteFile(storedBefore, []byte("stored before"), 0644))
+			old := time.Now().Add(-time.Hour).Truncate(time.Second)
+			require.NoError(t, os.Chtimes(storedBefore, old, old))
+
+			log := newTestLogger()
+			cfg := DefaultEngineConfig()
+			cfg.DownloadConfig.TempDir = t.TempDir()
+			cfg.DownloadConfig.CASMode = mode
+			engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+			require.NoError(t, err)
+
+			sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+			require.NoError(t, err)
+
+			select {
+			case <-engine.WaitForCompletion():
+			case <-time.After(30 * time.Second):
+				t.Fatal("sync engine did not terminate")
+			}
+
+			session, err := m.GetSession(ctx, sessionID)
+			require.NoError(t, err)
+			assert.Equal(t, state.SessionStatusCompleted, session.Status)
+
+			blobs, err := filepath.Glob(filepath.Join(dest, CASDirName, "*", "*"))
+			require.NoError(t, err)
+			assert.Len(t, blobs, 2)
+
+			shared := CASBlobPath(dest, md5Hex("shared content"))
+			for driveID, want := range map[string]string{"a": shared, "b": shared, "c": storedBefore} {
+				file, err := m.Files().GetByDriveID(ctx, driveID, sessionID)
+				require.NoError(t, err)
+				assert.Equal(t, want, file.CASPath.String)
+
+				path := LocalFilePath(session, file)
+				assert.Equal(t, path, file.LocalPath.String)
+				assertContent(t, map[string]string{shared: "shared content", storedBefore: "stored before"}[want], path)
+
+				info, err := os.Lstat(path)
+				require.NoError(t, err)
+				blob, err := os.Stat(want)
+				require.NoError(t, err)
+				if mode == CASSymlink {
+					assert.Equal(t, os.ModeSymlink, info.Mode().Type(), path)
+				} else {
+					assert.True(t, os.SameFile(info, blob), path)
+				}
+			}
+
+			info, err := os.Stat(storedBefore)
+			require.NoError(t, err)
+			assert.True(t, info.ModTime().Equal(old))
+		})
+	}
+}
+
+func TestStoreInCASLinksConcurrentDuplicatesToOneBlob(t *testing.T) {
+	dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir(), CASMode: CASHardlink})
+	require.NoError(t, err)
+
+	// Hold each move until the other worker got there too, which is where
+	// unserialized workers both find the blob missing
+	var arrived atomic.Int32
+	dm.fsyncFile = func(string) error {
+		arrived.Add(1)
+		deadline := time.Now().Add(200 * time.Millisecond)
+		for arrived.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return nil
+	}
+
+	dest := t.TempDir()
+	sum := md5Hex("shared content")
+	finalPaths := []string{filepath.Join(dest, "a.txt"), filepath.Join(dest, "sub", "b.txt")}
+
+	var wg sync.WaitGroup
+	for i, finalPath := range finalPaths {
+		tempPath := filepath.Join(t.TempDir(), fmt.Sprintf("%d.tmp", i))
+		require.NoError(t, os.WriteFile(tempPath, []byte("shared content"), 0600))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := dm.storeInCAS(context.Background(), dm.logger, dest, tempPath, finalPath, sum)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	blob, err := os.Stat(CASBlobPath(dest, sum))
+	require.NoError(t, err)
+	for _, finalPath := range finalPaths {
+		info, err := os.Stat(finalPath)
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(info, blob), finalPath)
+	}
+}
+
+func TestParseCASMode(t *testing.T) {
+	for name, want := range map[string]CASMode{
+		"":         CASOff,
+		"off":      CASOff,
+		"symlink":  CASSymlink,
+		"HardLink": CASHardlink,
+	} {
+		mode, err := ParseCASMode(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, mode, name)
+	}
+
+	_, err := ParseCASMode("reflink")
+	assert.Error(t, err)
+}
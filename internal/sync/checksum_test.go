@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	for name, expected := range map[string]ChecksumAlgorithm{
+		"":         ChecksumMD5,
+		"MD5":      ChecksumMD5,
+		" sha256 ": ChecksumSHA256,
+		"both":     ChecksumBoth,
+		"none":     ChecksumNone,
+	} {
+		algorithm, err := ParseChecksumAlgorithm(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, algorithm, name)
+	}
+
+	_, err := ParseChecksumAlgorithm("crc32")
+	assert.Error(t, err)
+}
+
+func TestVerifyChecksumReturnsComputedHashes(t *testing.T) {
+	content := []byte("cloudpull checksum test")
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	expectedMD5 := hex.EncodeToString(md5Sum[:])
+	expectedSHA256 := hex.EncodeToString(sha256Sum[:])
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, content, 0600))
+
+	newManager := func(algorithm ChecksumAlgorithm) *DownloadManager {
+		dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(),
+			&DownloadManagerConfig{TempDir: t.TempDir(), ChecksumAlgorithm: algorithm})
+		require.NoError(t, err)
+		return dm
+	}
+
+	sums, err := newManager(ChecksumBoth).verifyChecksum(path, expectedMD5)
+	require.NoError(t, err)
+	assert.Equal(t, expectedMD5, sums.MD5)
+	assert.Equal(t, expectedSHA256, sums.SHA256)
+
+	// Drive did not provide a checksum
+	sums, err = newManager(ChecksumSHA256).verifyChecksum(path, "")
+	require.NoError(t, err)
+	assert.Empty(t, sums.MD5)
+	assert.Equal(t, expectedSHA256, sums.SHA256)
+
+	// MD5 is still computed to verify Drive's checksum
+	sums, err = newManager(ChecksumNone).verifyChecksum(path, expectedMD5)
+	require.NoError(t, err)
+	assert.Equal(t, expectedMD5, sums.MD5)
+
+	_, err = newManager(ChecksumMD5).verifyChecksum(path, "0123456789abcdef0123456789abcdef")
+	assert.Error(t, err)
+}
+
+func TestEngineRecordsLocalChecksums(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "file-a", Name: "a.bin", MimeType: "application/octet-stream", Size: 10},
+			{Id: "doc-a", Name: "Notes", MimeType: "application/vnd.google-apps.document"},
+		},
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.SetChecksumAlgorithm(ChecksumBoth)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	for _, file := range files {
+		require.Equal(t, state.FileStatusCompleted, file.Status, file.Name)
+
+		// Checksums are recorded even for exports, which Drive does not hash
+		assert.Len(t, file.LocalMD5.String, 32, file.Name)
+		assert.Len(t, file.LocalSHA256.String, 64, file.Name)
+	}
+}
@@ -0,0 +1,203 @@
+/**
+ * Local Conflict Policies for CloudPull Sync Engine
+ *
+ * Features:
+ * - Detects local files changed since CloudPull last downloaded them
+ * - Overwrite, skip, rename or newer wins policies
+ * - Records each decision with the file and as a file conflict event
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// ConflictPolicy decides what happens when a download would replace a local
+// file that was changed since CloudPull downloaded it.
+type ConflictPolicy string
+
+const (
+	// ConflictOverwrite replaces the local file with the Drive copy.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+
+	// ConflictSkip keeps the local file and skips the download.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictRename keeps the local file as <name>.local next to the
+	// Drive copy.
+	ConflictRename ConflictPolicy = "rename"
+
+	// ConflictNewerWins keeps the local file if it was modified after the
+	// Drive copy, and overwrites it otherwise.
+	ConflictNewerWins ConflictPolicy = "newer_wins"
+)
+
+// Decisions recorded for a conflicting local file.
+const (
+	ConflictDecisionOverwritten  = "overwritten"
+	ConflictDecisionKeptLocal    = "kept_local"
+	ConflictDecisionRenamedLocal = "renamed_local"
+)
+
+// localCopySuffix is appended to local files kept by ConflictRename.
+const localCopySuffix = ".local"
+
+// ParseConflictPolicy parses a policy name: overwrite, skip, rename or
+// newer_wins. An empty name selects overwrite.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return ConflictOverwrite, nil
+	case ConflictOverwrite, ConflictSkip, ConflictRename, ConflictNewerWins:
+		return policy, nil
+	default:
+		return ConflictOverwrite, errors.Errorf("unknown conflict policy %q", name)
+	}
+}
+
+// localCopyKeptError reports a download discarded because the conflict
+// policy kept the local file. The worker records the file as skipped.
+func localCopyKeptError(path string) error {
+	return errors.New(errors.ErrorTypeConfiguration, "local_copy_kept", path,
+		errors.Errorf("local file %s differs from Drive and was kept", path))
+}
+
+// isLocalCopyKept reports whether err comes from localCopyKeptError.
+func isLocalCopyKept(err error) bool {
+	var typed *errors.Error
+	return errors.AsError(err, &typed) && typed.Op == "local_copy_kept"
+}
+
+// resolveConflict applies the conflict policy before the downloaded temp
+// file replaces info.FinalPath. It returns the decision taken, or "" when
+// there is no conflict: the local file is missing or was not changed
+// locally. The decision is recorded with the file.
+func (dm *DownloadManager) resolveConflict(ctx context.Context, log *logger.Logger, session *state.Session,
+	file *state.File, info *DownloadInfo) (string, error) {
+
+	if dm.conflictPolicy == "" || dm.conflictPolicy == ConflictOverwrite {
+		return "", nil
+	}
+
+	local, err := os.Lstat(info.FinalPath)
+	if err != nil || !local.Mode().IsRegular() {
+		return "", nil
+	}
+	changed, err := dm.changedLocally(ctx, session, file, info, local)
+	if err != nil || !changed {
+		return "", err
+	}
+
+	localNewer := file.DriveModifiedTime.Valid && local.ModTime().After(file.DriveModifiedTime.Time)
+	decision := ConflictDecisionOverwritten
+	switch dm.conflictPolicy {
+	case ConflictSkip:
+		decision = ConflictDecisionKeptLocal
+	case ConflictNewerWins:
+		if localNewer {
+			decision = ConflictDecisionKeptLocal
+		}
+	case ConflictRename:
+		if err := keepLocalCopy(info.FinalPath); err != nil {
+			return "", err
+		}
+		decision = ConflictDecisionRenamedLocal
+	}
+
+	log.Info("Local file was changed since it was downloaded",
+		"file_id", file.ID,
+		"path", info.FinalPath,
+		"policy", dm.conflictPolicy,
+		"decision", decision,
+	)
+	file.ConflictDecision = state.NewNullString(decision)
+	if err := dm.stateManager.Files().SetConflictDecision(ctx, file.ID, decision); err != nil {
+		log.Error(err, "Failed to record conflict decision", "file_id", file.ID)
+	}
+	dm.progressTracker.FileConflict(file.ID, file.Name, file.Path, decision)
+
+	return decision, nil
+}
+
+// changedLocally reports whether the local file at info.FinalPath differs
+// from what the file's last download wrote there: its checksum differs from
+// the recorded one or, without recorded checksums, it was modified after the
+// download completed. A local file no download wrote counts as changed
+// unless it matches the Drive copy: same size and MD5 or, without an MD5,
+// not modified after the Drive copy.
+func (dm *DownloadManager) changedLocally(ctx context.Context, session *state.Session, file *state.File,
+	info *DownloadInfo, local os.FileInfo) (bool, error) {
+
+	previous, err := dm.stateManager.Files().GetPreviousDownloads(ctx, file.DriveID, session.DestinationPath)
+	if err != nil {
+		return false, err
+	}
+	for _, prev := range previous {
+		if LocalFilePath(session, prev) != info.FinalPath {
+			continue
+		}
+		if !prev.LocalMD5.Valid && !prev.LocalSHA256.Valid {
+			return local.ModTime().After(prev.LocalModifiedTime.Time), nil
+		}
+		sums, err := computeChecksums(info.FinalPath, prev.LocalMD5.Valid, prev.LocalSHA256.Valid)
+		if err != nil {
+			return false, err
+		}
+		return (prev.LocalMD5.Valid && sums.MD5 != prev.LocalMD5.String) ||
+			(prev.LocalSHA256.Valid && sums.SHA256 != prev.LocalSHA256.String), nil
+	}
+
+	downloaded, err := os.Stat(info.TempPath)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to stat downloaded file")
+	}
+	switch {
+	case local.Size() != downloaded.Size():
+		return true, nil
+	case file.MD5Checksum.Valid:
+		_, err := dm.verifyChecksum(info.FinalPath, file.MD5Checksum.String)
+		return err != nil, nil
+	default:
+		return file.DriveModifiedTime.Valid && local.ModTime().After(file.DriveModifiedTime.Time), nil
+	}
+}
+
+// keepLocalCopy renames path to the first free <path>.local name.
+func keepLocalCopy(path string) error {
+	kept := path + localCopySuffix
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(kept); os.IsNotExist(err) {
+			break
+		}
+		kept = fmt.Sprintf("%s%s.%d", path, localCopySuffix, i)
+	}
+	if err := os.Rename(path, kept); err != nil {
+		return errors.Wrap(err, "failed to keep local copy")
+	}
+	return nil
+}
+
+// discardForLocalCopy removes the downloaded temp file and its journal
+// after the conflict policy kept the local file.
+func (dm *DownloadManager) discardForLocalCopy(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	if err := os.Remove(info.TempPath); err != nil && !os.IsNotExist(err) {
+		log.Error(err, "failed to remove temp file of skipped download", "path", info.TempPath)
+	}
+	if info.Journaled {
+		if err := dm.stateManager.Files().DeleteJournal(ctx, file.ID); err != nil {
+			log.Warn("Failed to delete download journal", "file", file.Name, "error", err)
+		}
+	}
+	return localCopyKeptError(info.FinalPath)
+}
@@ -0,0 +1,171 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/api/apitest"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// syncOverLocalFile syncs a Drive holding a.txt, modified an hour ago, into
+// a destination that already holds a.txt with localContent modified at
+// localModified. It returns the destination folder of a.txt and the file's
+// recorded state.
+func syncOverLocalFile(t *testing.T, policy ConflictPolicy, localContent string,
+	localModified time.Time) (string, *state.File) {
+
+	t.Helper()
+
+	dest := t.TempDir()
+	dir := filepath.Join(dest, "root")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	local := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(local, []byte(localContent), 0644))
+	require.NoError(t, os.Chtimes(local, localModified, localModified))
+
+	return dir, syncDriveCopy(t, newTestStateManager(t), dest, policy, "drive copy")
+}
+
+// syncDriveCopy syncs a Drive holding a.txt with driveContent, modified an
+// hour ago, into dest and returns the file's recorded state.
+func syncDriveCopy(t *testing.T, m *state.Manager, dest string, policy ConflictPolicy,
+	driveContent string) *state.File {
+
+	t.Helper()
+
+	ctx := context.Background()
+	fake := apitest.NewFakeDrive()
+	fake.AddFile("root", "file-a", "a.txt", driveContent).ModifiedTime = time.Now().Add(-time.Hour)
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.ConflictPolicy = policy
+	engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+
+	file, err := m.Files().GetByDriveID(ctx, "file-a", sessionID)
+	require.NoError(t, err)
+	return file
+}
+
+// assertContent checks the content of path.
+func assertContent(t *testing.T, want, path string) {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(content), path)
+}
+
+func TestConflictPolicies(t *testing.T) {
+	newer := time.Now()
+	older := time.Now().Add(-2 * time.Hour)
+
+	t.Run("overwrite", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictOverwrite, "local edits", newer)
+		assert.Equal(t, state.FileStatusCompleted, file.Status)
+		assertContent(t, "drive copy", filepath.Join(dir, "a.txt"))
+		assert.NoFileExists(t, filepath.Join(dir, "a.txt.local"))
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictSkip, "local edits", older)
+		assert.Equal(t, state.FileStatusSkipped, file.Status)
+		assert.Equal(t, ConflictDecisionKeptLocal, file.ErrorMessage.String)
+		assert.Equal(t, ConflictDecisionKeptLocal, file.ConflictDecision.String)
+		assertContent(t, "local edits", filepath.Join(dir, "a.txt"))
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictRename, "local edits", newer)
+		assert.Equal(t, state.FileStatusCompleted, file.Status)
+		assertContent(t, "drive copy", filepath.Join(dir, "a.txt"))
+		assertContent(t, "local edits", filepath.Join(dir, "a.txt.local"))
+		assert.Equal(t, ConflictDecisionRenamedLocal, file.ConflictDecision.String)
+	})
+
+	t.Run("newer_wins keeps a newer local file", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictNewerWins, "local edits", newer)
+		assert.Equal(t, state.FileStatusSkipped, file.Status)
+		assertContent(t, "local edits", filepath.Join(dir, "a.txt"))
+	})
+
+	t.Run("newer_wins overwrites an older local file", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictNewerWins, "local edits", older)
+		assert.Equal(t, state.FileStatusCompleted, file.Status)
+		assert.Equal(t, ConflictDecisionOverwritten, file.ConflictDecision.String)
+		assertContent(t, "drive copy", filepath.Join(dir, "a.txt"))
+	})
+
+	t.Run("identical local file is no conflict", func(t *testing.T) {
+		dir, file := syncOverLocalFile(t, ConflictSkip, "drive copy", newer)
+		assert.Equal(t, state.FileStatusCompleted, file.Status)
+		assert.False(t, file.ConflictDecision.Valid)
+		assertContent(t, "drive copy", filepath.Join(dir, "a.txt"))
+	})
+}
+
+func TestConflictComparesWithPreviousDownload(t *testing.T) {
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+	local := filepath.Join(dest, "root", "a.txt")
+
+	file := syncDriveCopy(t, m, dest, ConflictSkip, "version 1")
+	assert.Equal(t, state.FileStatusCompleted, file.Status)
+
+	// Only Drive changed: the local copy is what the last sync wrote
+	file = syncDriveCopy(t, m, dest, ConflictSkip, "version 2, longer")
+	assert.Equal(t, state.FileStatusCompleted, file.Status)
+	assert.False(t, file.ConflictDecision.Valid)
+	assertContent(t, "version 2, longer", local)
+
+	// A local edit of the same size, dated before the Drive copy
+	require.NoError(t, os.WriteFile(local, []byte("version 2, edited"), 0644))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(local, old, old))
+
+	file = syncDriveCopy(t, m, dest, ConflictSkip, "version 2, longer")
+	assert.Equal(t, state.FileStatusSkipped, file.Status)
+	assert.Equal(t, ConflictDecisionKeptLocal, file.ConflictDecision.String)
+	assertContent(t, "version 2, edited", local)
+
+	// The kept local copy still conflicts with later syncs
+	file = syncDriveCopy(t, m, dest, ConflictSkip, "version 3")
+	assert.Equal(t, state.FileStatusSkipped, file.Status)
+	assertContent(t, "version 2, edited", local)
+}
+
+func TestParseConflictPolicy(t *testing.T) {
+	policy, err := ParseConflictPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictOverwrite, policy)
+
+	policy, err = ParseConflictPolicy(" Newer_Wins ")
+	require.NoError(t, err)
+	assert.Equal(t, ConflictNewerWins, policy)
+
+	_, err = ParseConflictPolicy("ask")
+	assert.Error(t, err)
+}
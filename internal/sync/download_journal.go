@@ -0,0 +1,126 @@
+/**
+ * Download Journal for CloudPull Sync Engine
+ *
+ * Features:
+ * - Records the offset of partial downloads known to be synced to disk
+ * - Resumes from the journaled offset, dropping bytes written after it
+ * - Starts over when a partial download has no journal or a stale one
+ * - Keeps journaled temp files when temp files are cleaned up
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// journalInterval is how often an unfinished download syncs its temp file
+// and journals the offset reached.
+const journalInterval = 2 * time.Second
+
+// resumeOffset returns the offset the download of file continues from and
+// prepares its temp file for it. Only the journaled part of a partial
+// download is trusted; anything else is discarded.
+func (dm *DownloadManager) resumeOffset(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) (int64, error) {
+	journal, err := dm.stateManager.Files().GetJournal(ctx, file.ID)
+	if err != nil {
+		log.Warn("Failed to read download journal", "file", file.Name, "error", err)
+		journal = nil
+	}
+
+	stat, statErr := os.Stat(info.TempPath)
+	if statErr != nil && !os.IsNotExist(statErr) {
+		return 0, errors.Wrap(statErr, "failed to inspect partial download")
+	}
+	onDisk := int64(-1)
+	if statErr == nil {
+		onDisk = stat.Size()
+	}
+
+	if journal != nil && journalMatches(journal, file, info, onDisk) {
+		// Bytes past the journaled offset may never have reached the disk
+		if onDisk > journal.FlushedBytes {
+			if err := os.Truncate(info.TempPath, journal.FlushedBytes); err != nil {
+				return 0, errors.Wrap(err, "failed to truncate partial download")
+			}
+		}
+		return journal.FlushedBytes, nil
+	}
+
+	if journal != nil {
+		log.Warn("Discarding stale download journal",
+			"file", file.Name,
+			"journaled", journal.FlushedBytes,
+			"on_disk", onDisk,
+		)
+		if err := dm.stateManager.Files().DeleteJournal(ctx, file.ID); err != nil {
+			log.Warn("Failed to delete download journal", "file", file.Name, "error", err)
+		}
+	}
+
+	if onDisk > 0 {
+		log.Warn("Discarding partial download without journal",
+			"file", file.Name,
+			"partial_size", onDisk,
+		)
+		if err := os.Truncate(info.TempPath, 0); err != nil {
+			return 0, errors.Wrap(err, "failed to discard partial download")
+		}
+	}
+
+	return 0, nil
+}
+
+// journalMatches reports whether journal describes the partial download of
+// the current version of file, with at least the journaled bytes on disk.
+func journalMatches(journal *state.DownloadJournal, file *state.File, info *DownloadInfo, onDisk int64) bool {
+	return journal.TempPath == info.TempPath &&
+		journal.Size == file.Size &&
+		journal.MD5Checksum.String == file.MD5Checksum.String &&
+		journal.FlushedBytes > 0 &&
+		journal.FlushedBytes <= file.Size &&
+		onDisk >= journal.FlushedBytes
+}
+
+// journalFunc returns the callback recording offsets of the download of
+// file synced to disk.
+func (dm *DownloadManager) journalFunc(log *logger.Logger, file *state.File, info *DownloadInfo) func(offset int64) {
+	return func(offset int64) {
+		// The last offset is recorded while the download is being canceled
+		err := dm.stateManager.Files().RecordFlushedOffset(context.Background(), &state.DownloadJournal{
+			FileID:       file.ID,
+			TempPath:     info.TempPath,
+			FlushedBytes: offset,
+			Size:         file.Size,
+			MD5Checksum:  file.MD5Checksum,
+		})
+		if err != nil {
+			log.Warn("Failed to record download journal", "file", file.Name, "offset", offset, "error", err)
+		}
+	}
+}
+
+// journaledTempPaths returns the temp files that hold journaled partial
+// downloads, which temp file cleanup keeps.
+func (dm *DownloadManager) journaledTempPaths() map[string]bool {
+	paths, err := dm.stateManager.Files().JournaledTempPaths(context.Background())
+	if err != nil {
+		dm.logger.Warn("Failed to read download journal", "error", err)
+		return nil
+	}
+
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		keep[path] = true
+	}
+	return keep
+}
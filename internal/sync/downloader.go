@@ -4,10 +4,11 @@
  * Features:
  * - Resume partial downloads using byte ranges
  * - Checksum verification after download
- * - Atomic file operations (download to temp, then move)
+ * - Atomic file operations (download to temp, fsync, then move)
  * - Google Docs export handling
  * - Bandwidth throttling support
  * - Priority-based download scheduling
+ * - Per-file and per-chunk timeouts for stalled downloads
  *
  * Author: CloudPull Team
  * Updated: 2025-01-29
@@ -17,8 +18,6 @@ package sync
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -27,10 +26,13 @@ import (
 	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+
 	"github.com/VatsalSy/CloudPull/internal/api"
 	"github.com/VatsalSy/CloudPull/internal/errors"
 	"github.com/VatsalSy/CloudPull/internal/logger"
 	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 // DownloadManager manages file downloads with advanced features.
@@ -40,16 +42,65 @@ type DownloadManager struct {
 	errorHandler    *errors.Handler
 	downloadStats   *DownloadStats
 	cancel          context.CancelFunc
-	client          *api.DriveClient
+	client          api.DriveAPI
 	stateManager    *state.Manager
 	progressTracker *ProgressTracker
 	workerPool      *WorkerPool
+	tierLimiters    map[PriorityTier]*rate.Limiter
 	activeDownloads sync.Map
+	priorityRules   []PriorityRule
 	tempDir         string
 	chunkSize       int64
 	maxConcurrent   int
 	mu              sync.RWMutex
 	verifyChecksums bool
+
+	// checksumAlgorithm selects the local checksums recorded per file
+	checksumAlgorithm ChecksumAlgorithm
+
+	// scheduleOrder orders files of one priority tier
+	scheduleOrder ScheduleOrder
+
+	// conflictPolicy decides the fate of local files that differ from Drive
+	conflictPolicy ConflictPolicy
+
+	// casMode stores downloads by content and links their logical paths;
+	// casLocks serialize storing and linking the same content
+	casMode  CASMode
+	casLocks [casLockCount]sync.Mutex
+
+	// postDownload runs the configured command on finished files; nil if unset
+	postDownload *postDownloadHook
+
+	// perFileTimeout and perChunkTimeout bound a download attempt; 0 disables
+	perFileTimeout  time.Duration
+	perChunkTimeout time.Duration
+
+	// exportFormats lists the export formats per Google MIME type
+	exportFormats map[string][]string
+
+	// pathTemplate computes local paths; nil keeps the Drive layout
+	pathTemplate *PathTemplate
+	emailCache   accountEmailCache
+
+	// organizeByCategory prefixes local paths with the file's MIME category
+	organizeByCategory bool
+
+	// maxPathLength is the longest local path in bytes; 0 means no limit
+	maxPathLength int
+
+	// fileMode and dirMode are the permissions of the files and directories
+	// the download manager creates
+	fileMode os.FileMode
+	dirMode  os.FileMode
+
+	// sharedBandwidth is the limit shared with other sessions; nil if unset
+	sharedBandwidth *SharedBandwidthLimiter
+
+	// fsyncFile and fsyncDir flush files and directories to disk; replaced
+	// in tests
+	fsyncFile func(path string) error
+	fsyncDir  func(dir string) error
 }
 
 // DownloadInfo tracks active download information.
@@ -64,6 +115,11 @@ type DownloadInfo struct {
 	Size            int64
 	BytesDownloaded int64
 	IsGoogleDoc     bool
+
+	// Resumed is set when the download continued from a journaled partial
+	// download; Journaled once the partial download has a journal entry
+	Resumed   bool
+	Journaled bool
 }
 
 // DownloadStats tracks download statistics.
@@ -74,30 +130,54 @@ type DownloadStats struct {
 	CompletedDownloads int64
 	FailedDownloads    int64
 	BytesDownloaded    int64
+	OpenConnections    int64
 	TotalDuration      time.Duration
 }
 
 // DownloadManagerConfig contains configuration for the download manager.
 type DownloadManagerConfig struct {
-	TempDir         string
-	ChunkSize       int64
-	MaxConcurrent   int
-	VerifyChecksums bool
+	TierBandwidthLimits map[PriorityTier]int64 // bytes/s per tier, within the global limit
+	TempDir             string
+	PriorityRules       []PriorityRule // first matching rule wins
+	ChunkSize           int64
+	MaxConcurrent       int
+	VerifyChecksums     bool
+	ChecksumAlgorithm   ChecksumAlgorithm   // local checksums recorded per file
+	PostDownload        *PostDownloadConfig // command run on each finished file
+	PerFileTimeout      time.Duration       // limit for one attempt at a file; 0 disables
+	PerChunkTimeout     time.Duration       // limit for one ranged request; 0 disables
+	ExportFormats       map[string][]string // Google MIME type to export MIME types, see ParseExportFormats
+	PathTemplate        *PathTemplate       // local path per file; nil keeps the Drive layout
+	OrganizeByCategory  bool                // prefix local paths with the MIME category, e.g. "images/"
+	MaxPathLength       int                 // longest local path in bytes, shortening file names; 0 = no limit
+	FileMode            os.FileMode         // permissions of downloaded files; 0 = util.DefaultFileMode
+	DirMode             os.FileMode         // permissions of created directories; 0 = util.DefaultDirMode
+	ScheduleOrder       ScheduleOrder       // order of files within a tier; "" = smallest first
+	ConflictPolicy      ConflictPolicy      // local files that differ from Drive; "" = overwrite
+	CASMode             CASMode             // store downloads by content under .cas; "" = off
+
+	// SharedBandwidth is a limit shared with the download managers of other
+	// sessions, applied on top of the session limit (nil = none)
+	SharedBandwidth *SharedBandwidthLimiter
 }
 
 // DefaultDownloadManagerConfig returns default configuration.
 func DefaultDownloadManagerConfig() *DownloadManagerConfig {
 	return &DownloadManagerConfig{
-		TempDir:         os.TempDir(),
-		ChunkSize:       10 * 1024 * 1024, // 10MB
-		MaxConcurrent:   3,
-		VerifyChecksums: true,
+		TempDir:           os.TempDir(),
+		ChunkSize:         10 * 1024 * 1024, // 10MB
+		MaxConcurrent:     3,
+		VerifyChecksums:   true,
+		ChecksumAlgorithm: ChecksumMD5,
+		ScheduleOrder:     ScheduleSmallestFirst,
+		ConflictPolicy:    ConflictOverwrite,
+		PerChunkTimeout:   5 * time.Minute,
 	}
 }
 
 // NewDownloadManager creates a new download manager.
 func NewDownloadManager(
-	client *api.DriveClient,
+	client api.DriveAPI,
 	stateManager *state.Manager,
 	progressTracker *ProgressTracker,
 	errorHandler *errors.Handler,
@@ -109,9 +189,23 @@ func NewDownloadManager(
 		config = DefaultDownloadManagerConfig()
 	}
 
+	checksumAlgorithm := config.ChecksumAlgorithm
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = ChecksumMD5
+	}
+
+	fileMode := config.FileMode
+	if fileMode == 0 {
+		fileMode = util.DefaultFileMode
+	}
+	dirMode := config.DirMode
+	if dirMode == 0 {
+		dirMode = util.DefaultDirMode
+	}
+
 	// Create temp directory
-	tempDir := filepath.Join(config.TempDir, "cloudpull-downloads")
-	if err := os.MkdirAll(tempDir, 0750); err != nil {
+	tempDir := filepath.Join(config.TempDir, downloadTempDirName)
+	if err := os.MkdirAll(tempDir, dirMode); err != nil {
 		return nil, errors.Wrap(err, "failed to create temp directory")
 	}
 
@@ -132,17 +226,41 @@ func NewDownloadManager(
 	)
 
 	dm := &DownloadManager{
-		tempDir:         tempDir,
-		chunkSize:       config.ChunkSize,
-		maxConcurrent:   config.MaxConcurrent,
-		verifyChecksums: config.VerifyChecksums,
-		client:          client,
-		stateManager:    stateManager,
-		progressTracker: progressTracker,
-		errorHandler:    errorHandler,
-		logger:          logger,
-		workerPool:      workerPool,
-		downloadStats:   &DownloadStats{},
+		tempDir:            tempDir,
+		chunkSize:          config.ChunkSize,
+		maxConcurrent:      config.MaxConcurrent,
+		verifyChecksums:    config.VerifyChecksums,
+		checksumAlgorithm:  checksumAlgorithm,
+		scheduleOrder:      config.ScheduleOrder,
+		conflictPolicy:     config.ConflictPolicy,
+		casMode:            config.CASMode,
+		postDownload:       newPostDownloadHook(config.PostDownload),
+		perFileTimeout:     config.PerFileTimeout,
+		perChunkTimeout:    config.PerChunkTimeout,
+		exportFormats:      config.ExportFormats,
+		pathTemplate:       config.PathTemplate,
+		organizeByCategory: config.OrganizeByCategory,
+		maxPathLength:      config.MaxPathLength,
+		fileMode:           fileMode,
+		dirMode:            dirMode,
+		sharedBandwidth:    config.SharedBandwidth,
+		client:             client,
+		stateManager:       stateManager,
+		progressTracker:    progressTracker,
+		errorHandler:       errorHandler,
+		logger:             logger,
+		workerPool:         workerPool,
+		downloadStats:      &DownloadStats{},
+		priorityRules:      config.PriorityRules,
+		tierLimiters:       make(map[PriorityTier]*rate.Limiter),
+		fsyncFile:          util.SyncFile,
+		fsyncDir:           util.SyncDir,
+	}
+
+	for tier, limit := range config.TierBandwidthLimits {
+		if limit > 0 {
+			dm.tierLimiters[tier] = newBandwidthLimiter(limit)
+		}
 	}
 
 	// Set the download manager reference in the worker pool
@@ -169,8 +287,37 @@ func (dm *DownloadManager) Start(ctx context.Context) error {
 		"temp_dir", dm.tempDir,
 		"chunk_size", dm.chunkSize,
 		"max_concurrent", dm.maxConcurrent,
+		"priority_rules", len(dm.priorityRules),
+		"per_file_timeout", dm.perFileTimeout,
+		"per_chunk_timeout", dm.perChunkTimeout,
 	)
 
+	for _, rule := range dm.priorityRules {
+		dm.logger.Debug("Priority rule",
+			"pattern", rule.Pattern,
+			"tier", rule.Tier.String(),
+		)
+	}
+	for tier, limiter := range dm.tierLimiters {
+		dm.logger.Info("Priority tier bandwidth cap",
+			"tier", tier.String(),
+			"limit", formatBytes(int64(limiter.Limit()))+"/s",
+		)
+	}
+	if dm.sharedBandwidth != nil && dm.sharedBandwidth.Limit() > 0 {
+		dm.logger.Info("Shared bandwidth limit",
+			"limit", formatBytes(dm.sharedBandwidth.Limit())+"/s",
+		)
+	}
+	if dm.postDownload != nil {
+		dm.logger.Info("Post-download command enabled",
+			"command", dm.postDownload.config.Command,
+			"on_failure", string(dm.postDownload.config.OnFailure),
+			"timeout", dm.postDownload.config.Timeout,
+			"max_concurrent", dm.postDownload.config.MaxConcurrent,
+		)
+	}
+
 	return nil
 }
 
@@ -195,6 +342,31 @@ func (dm *DownloadManager) Stop() error {
 	return nil
 }
 
+// Drain stops starting new downloads and waits for active ones to finish.
+func (dm *DownloadManager) Drain(ctx context.Context) error {
+	return dm.workerPool.Drain(ctx)
+}
+
+// SetPaused stops or restarts starting queued downloads.
+func (dm *DownloadManager) SetPaused(paused bool) {
+	dm.workerPool.SetPaused(paused)
+}
+
+// flushStatuses writes the file statuses buffered by the worker pool.
+func (dm *DownloadManager) flushStatuses() {
+	dm.workerPool.statuses.flush()
+}
+
+// IsIdle reports whether all scheduled downloads have finished.
+func (dm *DownloadManager) IsIdle() bool {
+	return dm.workerPool.IsIdle()
+}
+
+// QueuedDownloads returns the number of files waiting for a worker.
+func (dm *DownloadManager) QueuedDownloads() int {
+	return dm.workerPool.QueuedTasks()
+}
+
 // ScheduleDownload schedules a file for download.
 func (dm *DownloadManager) ScheduleDownload(file *state.File, priority int) error {
 	// Check if already downloading
@@ -212,8 +384,24 @@ func (dm *DownloadManager) ScheduleBatch(files []*state.File) error {
 		"batch_size", len(files),
 	)
 
-	// Sort by size (smallest first) for better throughput
-	priorityMap := dm.calculatePriorities(files)
+	// Rank by MIME type tier, then in schedule order
+	priorityMap, tierCounts := dm.calculatePriorities(files)
+
+	// Record the hand-off before any worker can start a download, so the
+	// database tells queued files apart from ones never scheduled
+	ids := make([]string, len(files))
+	for i, file := range files {
+		ids[i] = file.ID
+	}
+	if err := dm.stateManager.Files().MarkQueued(dm.ctx, ids); err != nil {
+		dm.logger.Error(err, "Failed to mark files as queued", "batch_size", len(files))
+	} else {
+		for _, file := range files {
+			if file.Status == state.FileStatusPending {
+				file.Status = state.FileStatusQueued
+			}
+		}
+	}
 
 	scheduled := 0
 	for _, file := range files {
@@ -223,6 +411,12 @@ func (dm *DownloadManager) ScheduleBatch(files []*state.File) error {
 				"file_id", file.ID,
 				"file_name", file.Name,
 			)
+
+			// Count the file as skipped so completion is still detected,
+			// unless scheduling failed because the sync is stopping
+			if dm.ctx.Err() == nil {
+				dm.progressTracker.FileSkipped(file.ID, file.Name, file.Path, err.Error())
+			}
 		} else {
 			scheduled++
 		}
@@ -231,13 +425,22 @@ func (dm *DownloadManager) ScheduleBatch(files []*state.File) error {
 	dm.logger.Info("Batch scheduling complete",
 		"scheduled", scheduled,
 		"total", len(files),
+		"high_priority", tierCounts[PriorityTierHigh],
+		"normal_priority", tierCounts[PriorityTierNormal],
+		"low_priority", tierCounts[PriorityTierLow],
 	)
 
 	return nil
 }
 
-// DownloadFile downloads a single file with resume support.
-func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File) error {
+// DownloadFile downloads a single file with resume support. Its log lines
+// go to log, the worker's child logger for the file; nil means the
+// manager's logger.
+func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File, log *logger.Logger) error {
+	if log == nil {
+		log = dm.logger
+	}
+
 	// Get session to get destination path
 	session, err := dm.stateManager.GetSession(ctx, file.SessionID)
 	if err != nil {
@@ -260,11 +463,14 @@ func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File) e
 		downloadInfo.ExportFormat = file.ExportMimeType.String
 	}
 
-	// Generate paths - combine destination path with file path
+	// Generate paths below the session destination
 	downloadInfo.TempPath = dm.getTempPath(file)
-	downloadInfo.FinalPath = filepath.Join(session.DestinationPath, file.Path)
+	downloadInfo.FinalPath, err = dm.localPath(ctx, session, file)
+	if err != nil {
+		return err
+	}
 
-	dm.logger.Info("Starting file download",
+	log.Info("Starting file download",
 		"file_id", file.ID,
 		"file_name", file.Name,
 		"file_size", file.Size,
@@ -290,12 +496,7 @@ func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File) e
 	}()
 
 	// Perform download
-	if file.IsGoogleDoc {
-		err = dm.downloadGoogleDoc(ctx, file, downloadInfo)
-	} else {
-		err = dm.downloadRegularFile(ctx, file, downloadInfo)
-	}
-
+	err = dm.transferFile(ctx, log, file, downloadInfo)
 	if err != nil {
 		dm.downloadStats.mu.Lock()
 		dm.downloadStats.FailedDownloads++
@@ -303,22 +504,65 @@ func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File) e
 		return err
 	}
 
-	// Verify checksum if enabled
-	if dm.verifyChecksums && file.MD5Checksum.Valid && file.MD5Checksum.String != "" {
-		if err := dm.verifyChecksum(downloadInfo.TempPath, file.MD5Checksum.String); err != nil {
-			if removeErr := os.Remove(downloadInfo.TempPath); removeErr != nil {
-				dm.logger.Error(removeErr, "failed to remove temp file after checksum failure", "path", downloadInfo.TempPath)
+	// Compute local checksums, verifying the Drive MD5 if enabled; resumed
+	// downloads are always verified
+	expectedMD5 := ""
+	if (dm.verifyChecksums || downloadInfo.Resumed) && file.MD5Checksum.Valid {
+		expectedMD5 = file.MD5Checksum.String
+	}
+	checksums, err := dm.verifyChecksum(downloadInfo.TempPath, expectedMD5)
+	if err != nil {
+		return dm.discardCorruptDownload(ctx, log, file, downloadInfo, err)
+	}
+
+	decision, err := dm.resolveConflict(ctx, log, session, file, downloadInfo)
+	if err != nil {
+		return err
+	}
+	if decision == ConflictDecisionKeptLocal {
+		return dm.discardForLocalCopy(ctx, log, file, downloadInfo)
+	}
+
+	// Move to final destination, or into the content store linked from it
+	casPath := ""
+	if dm.casMode.enabled() && checksums.MD5 != "" {
+		casPath, err = dm.storeInCAS(ctx, log, session.DestinationPath, downloadInfo.TempPath,
+			downloadInfo.FinalPath, checksums.MD5)
+	} else {
+		err = dm.moveToFinal(ctx, downloadInfo.TempPath, downloadInfo.FinalPath)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// The complete temp file is reused when the sync resumes
+			if !file.IsGoogleDoc {
+				dm.journalFunc(log, file, downloadInfo)(file.Size)
 			}
-			return errors.Wrap(err, "checksum verification failed")
+			return err
+		}
+		if removeErr := os.Remove(downloadInfo.TempPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Error(removeErr, "failed to remove temp file after move failure", "path", downloadInfo.TempPath)
 		}
+		return errors.Wrap(err, "failed to move file to final destination")
 	}
 
-	// Move to final destination
-	if err := dm.moveToFinal(downloadInfo.TempPath, downloadInfo.FinalPath); err != nil {
-		if removeErr := os.Remove(downloadInfo.TempPath); removeErr != nil {
-			dm.logger.Error(removeErr, "failed to remove temp file after move failure", "path", downloadInfo.TempPath)
+	if downloadInfo.Journaled {
+		if err := dm.stateManager.Files().DeleteJournal(ctx, file.ID); err != nil {
+			log.Warn("Failed to delete download journal", "file", file.Name, "error", err)
 		}
-		return errors.Wrap(err, "failed to move file to final destination")
+	}
+
+	dm.exportExtraFormats(ctx, log, file, downloadInfo.FinalPath)
+
+	dm.recordChecksums(ctx, log, file, checksums)
+	if casPath != "" {
+		dm.recordCASPath(ctx, log, file, downloadInfo.FinalPath, casPath)
+	}
+
+	if err := dm.runPostDownloadHook(ctx, log, file, downloadInfo.FinalPath); err != nil {
+		dm.downloadStats.mu.Lock()
+		dm.downloadStats.FailedDownloads++
+		dm.downloadStats.mu.Unlock()
+		return err
 	}
 
 	// Update stats
@@ -331,61 +575,281 @@ func (dm *DownloadManager) DownloadFile(ctx context.Context, file *state.File) e
 	return nil
 }
 
+// discardCorruptDownload handles a download whose checksum did not match.
+// Drive only publishes a checksum of the whole file, so the bad bytes cannot
+// be located: the temp file is removed and the file is returned to pending
+// with no bytes downloaded, so its next attempt starts over. The failure is
+// logged as corruption and returned as a retryable error.
+func (dm *DownloadManager) discardCorruptDownload(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo, cause error) error {
+	log.Warn("Checksum mismatch, restarting download",
+		"file_id", file.ID,
+		"file_name", file.Name,
+		"error", cause,
+	)
+
+	if err := os.Remove(info.TempPath); err != nil && !os.IsNotExist(err) {
+		log.Error(err, "failed to remove temp file after checksum failure", "path", info.TempPath)
+	}
+
+	file.Status = state.FileStatusPending
+	file.BytesDownloaded = 0
+	if err := dm.stateManager.Files().ResetDownload(ctx, file.ID); err != nil {
+		log.Error(err, "Failed to reset corrupt download", "file_id", file.ID)
+	}
+
+	corruptErr := errors.New(errors.ErrorTypeCorruption, "checksum_verification", info.FinalPath,
+		errors.Wrap(cause, "checksum verification failed"))
+	if err := dm.stateManager.LogError(ctx, file.SessionID, file.ID, "file", ErrorTypeCorruption, corruptErr); err != nil {
+		log.Error(err, "Failed to record checksum failure", "file_id", file.ID)
+	}
+
+	return corruptErr
+}
+
+// transferFile downloads or exports file to its temp path, bounded by the
+// per-file timeout. A timeout is recorded in the error log and returned as a
+// retryable error, so the worker pool retries the file.
+func (dm *DownloadManager) transferFile(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	fileCtx := ctx
+	if dm.perFileTimeout > 0 {
+		var cancel context.CancelFunc
+		fileCtx, cancel = context.WithTimeout(ctx, dm.perFileTimeout)
+		defer cancel()
+	}
+
+	var err error
+	if file.IsGoogleDoc {
+		err = dm.downloadGoogleDoc(fileCtx, log, file, info)
+	} else {
+		err = dm.downloadRegularFile(fileCtx, log, file, info)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if ctx.Err() == nil && fileCtx.Err() == context.DeadlineExceeded {
+		err = downloadTimeoutError("file", dm.perFileTimeout)
+	}
+	if isDownloadTimeout(err) {
+		log.Warn("Download timed out",
+			"file_id", file.ID,
+			"file_name", file.Name,
+			"bytes_downloaded", info.BytesDownloaded,
+			"error", err,
+		)
+		if logErr := dm.stateManager.LogError(ctx, file.SessionID, file.ID, "file", "download_timeout", err); logErr != nil {
+			log.Error(logErr, "Failed to record download timeout", "file_id", file.ID)
+		}
+	}
+
+	return err
+}
+
+// downloadTimeoutError reports a download attempt that exceeded the limit
+// for a file or chunk. It is a network error, so it is retryable.
+func downloadTimeoutError(scope string, limit time.Duration) error {
+	return errors.New(errors.ErrorTypeNetwork, "download_timeout", "",
+		errors.Errorf("%s timeout after %s", scope, limit))
+}
+
+// isDownloadTimeout reports whether err comes from downloadTimeoutError.
+func isDownloadTimeout(err error) bool {
+	var typed *errors.Error
+	return errors.AsError(err, &typed) && typed.Op == "download_timeout"
+}
+
+// recordChecksums stores the checksums computed for a downloaded file. A
+// failure is only logged since the file itself is complete.
+func (dm *DownloadManager) recordChecksums(ctx context.Context, log *logger.Logger, file *state.File, checksums *FileChecksums) {
+	if checksums.MD5 == "" && checksums.SHA256 == "" {
+		return
+	}
+
+	file.LocalMD5 = state.NewNullString(checksums.MD5)
+	file.LocalSHA256 = state.NewNullString(checksums.SHA256)
+	if err := dm.stateManager.Files().SetLocalChecksums(ctx, file.ID, checksums.MD5, checksums.SHA256); err != nil {
+		log.Error(err, "Failed to record local checksums", "file_id", file.ID)
+	}
+}
+
+// recordCASPath stores the content blob of file and the logical path
+// linked to it.
+func (dm *DownloadManager) recordCASPath(ctx context.Context, log *logger.Logger, file *state.File, localPath, casPath string) {
+	file.LocalPath = state.NewNullString(localPath)
+	file.CASPath = state.NewNullString(casPath)
+	if err := dm.stateManager.Files().SetCASPath(ctx, file.ID, localPath, casPath); err != nil {
+		log.Error(err, "Failed to record content store path", "file_id", file.ID)
+	}
+}
+
 // downloadRegularFile downloads a regular (non-Google Docs) file.
-func (dm *DownloadManager) downloadRegularFile(ctx context.Context, file *state.File, info *DownloadInfo) error {
-	// Check if partial download exists
-	startOffset := int64(0)
-	if stat, err := os.Stat(info.TempPath); err == nil {
-		startOffset = stat.Size()
-		info.BytesDownloaded = startOffset
+func (dm *DownloadManager) downloadRegularFile(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	if sizeUnknown(file) {
+		return dm.downloadUnknownSize(ctx, log, file, info)
+	}
+	if file.Size == 0 {
+		return dm.createEmptyFile(log, file, info)
+	}
+
+	// Continue a journaled partial download
+	startOffset, err := dm.resumeOffset(ctx, log, file, info)
+	if err != nil {
+		return err
+	}
+	info.BytesDownloaded = startOffset
+	if startOffset > 0 {
+		info.Resumed = true
+		info.Journaled = true
 
 		// Check if already complete
-		if startOffset >= file.Size {
-			dm.logger.Info("File already downloaded",
+		if startOffset == file.Size {
+			log.Info("File already downloaded",
 				"file", file.Name,
 				"size", file.Size,
 			)
 			return nil
 		}
 
-		dm.logger.Info("Resuming partial download",
+		log.Info("Resuming partial download",
 			"file", file.Name,
 			"offset", startOffset,
 			"total", file.Size,
 		)
 	}
 
-	// Progress callback with bandwidth limiting
+	// Progress callback; bandwidth is limited while reading in downloadWithResume
 	progressFn := func(downloaded, total int64) {
-		// Check bandwidth limit
-		delta := downloaded - info.BytesDownloaded
-		if delta > 0 {
-			if err := dm.progressTracker.CheckBandwidthLimit(ctx, delta); err != nil {
-				dm.logger.Debug("Bandwidth limit check failed", "error", err)
-			}
-		}
-
 		info.BytesDownloaded = startOffset + downloaded
 		dm.progressTracker.FileProgress(file.ID, info.BytesDownloaded)
 	}
 
+	// Record synced offsets so an interrupted download can continue
+	journal := dm.journalFunc(log, file, info)
+	journalFn := func(offset int64) {
+		journal(offset)
+		info.Journaled = true
+	}
+
 	// Download file
-	err := dm.downloadWithResume(ctx, file.DriveID, info.TempPath, startOffset, file.Size, progressFn)
+	err = dm.downloadWithResume(ctx, log, file.DriveID, dm.tierFor(file), info.TempPath, startOffset, file.Size, progressFn, journalFn)
+	if err != nil {
+		return errors.Wrap(err, "download failed")
+	}
+
+	return nil
+}
+
+// createEmptyFile creates the temp file of a zero-byte file, which has no
+// content to request from Drive.
+func (dm *DownloadManager) createEmptyFile(log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	if err := os.MkdirAll(filepath.Dir(info.TempPath), dm.dirMode); err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+
+	empty, err := os.OpenFile(info.TempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dm.fileMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to create empty file")
+	}
+	if err := empty.Close(); err != nil {
+		return errors.Wrap(err, "failed to create empty file")
+	}
+
+	log.Debug("Created empty file", "file", file.Name)
+	info.BytesDownloaded = 0
+	dm.progressTracker.FileProgress(file.ID, 0)
+	return nil
+}
+
+// sizeUnknown reports whether Drive listed file without its size.
+func sizeUnknown(file *state.File) bool {
+	info := &api.FileInfo{
+		Size:        file.Size,
+		MimeType:    file.MimeType.String,
+		MD5Checksum: file.MD5Checksum.String,
+	}
+	return !file.IsGoogleDoc && info.SizeUnknown()
+}
+
+// downloadUnknownSize downloads a file Drive listed without a size. Chunks
+// cannot be requested without knowing where the file ends, so its content
+// is copied in one stream until EOF and the download always starts over.
+// The size written is recorded as the file's size.
+func (dm *DownloadManager) downloadUnknownSize(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	if err := os.MkdirAll(filepath.Dir(info.TempPath), dm.dirMode); err != nil {
+		return errors.Wrap(err, "failed to create directory")
+	}
+
+	out, err := os.OpenFile(info.TempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dm.fileMode)
+	if err != nil {
+		return errors.Wrap(err, "failed to open file")
+	}
+	defer out.Close()
+
+	resp, err := dm.client.GetFileContent(ctx, file.DriveID, 0, -1)
+	if err != nil {
+		return errors.Wrap(err, "download failed")
+	}
+	defer resp.Body.Close()
+
+	log.Debug("Downloading file of unknown size", "file", file.Name)
+
+	// Report progress once per chunk size written, like chunked downloads
+	report := func(written int64) {
+		info.BytesDownloaded = written
+		dm.progressTracker.FileProgress(file.ID, written)
+	}
+	progress := &progressWriter{w: out, report: report, every: dm.chunkSize}
+
+	dm.trackConnection(1)
+	written, err := io.Copy(progress, dm.throttle(ctx, dm.tierFor(file), resp.Body))
+	dm.trackConnection(-1)
 	if err != nil {
 		return errors.Wrap(err, "download failed")
 	}
+	report(written)
+	if err := out.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync file")
+	}
 
+	info.Size = written
+	file.Size = written
+	dm.progressTracker.AddTotals(0, written)
+	if err := dm.stateManager.Files().UpdateSize(ctx, file.ID, written); err != nil {
+		log.Warn("Failed to record file size", "file", file.Name, "error", err)
+	}
 	return nil
 }
 
-// downloadGoogleDoc exports and downloads a Google Docs file.
-func (dm *DownloadManager) downloadGoogleDoc(ctx context.Context, file *state.File, info *DownloadInfo) error {
-	// Add appropriate extension
-	if !strings.Contains(info.FinalPath, ".") {
-		ext := dm.getExportExtension(info.ExportFormat)
-		info.FinalPath += ext
-		info.TempPath += ext
+// progressWriter reports the bytes written through it each time another
+// every bytes were written.
+type progressWriter struct {
+	w        io.Writer
+	report   func(written int64)
+	every    int64
+	written  int64
+	reported int64
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	if p.written-p.reported >= p.every {
+		p.reported = p.written
+		p.report(p.written)
 	}
+	return n, err
+}
+
+// downloadGoogleDoc exports and downloads a Google Docs file. Drive reports
+// no size for Google Workspace files, so their recorded size stays 0 and
+// info.Size is only known once the export is written; an export may be empty.
+func (dm *DownloadManager) downloadGoogleDoc(ctx context.Context, log *logger.Logger, file *state.File, info *DownloadInfo) error {
+	info.TempPath = exportTempPath(info.TempPath, info.ExportFormat)
+	log.Debug("Exporting Google Workspace file",
+		"file_id", file.ID,
+		"file_name", file.Name,
+		"format", info.ExportFormat,
+	)
 
 	// Progress callback
 	progressFn := func(downloaded, total int64) {
@@ -393,8 +857,10 @@ func (dm *DownloadManager) downloadGoogleDoc(ctx context.Context, file *state.Fi
 		dm.progressTracker.FileProgress(file.ID, downloaded)
 	}
 
-	// Export file
+	// Export file; the export response is streamed over one connection
+	dm.trackConnection(1)
 	err := dm.client.ExportFile(ctx, file.DriveID, info.ExportFormat, info.TempPath, progressFn)
+	dm.trackConnection(-1)
 	if err != nil {
 		return errors.Wrap(err, "export failed")
 	}
@@ -410,19 +876,22 @@ func (dm *DownloadManager) downloadGoogleDoc(ctx context.Context, file *state.Fi
 // downloadWithResume performs resumable download.
 func (dm *DownloadManager) downloadWithResume(
 	ctx context.Context,
+	log *logger.Logger,
 	fileID string,
+	tier PriorityTier,
 	destPath string,
 	startOffset int64,
 	totalSize int64,
 	progressFn func(downloaded, total int64),
+	journalFn func(offset int64),
 ) error {
 	// Ensure directory exists
-	if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
+	if err := os.MkdirAll(filepath.Dir(destPath), dm.dirMode); err != nil {
 		return errors.Wrap(err, "failed to create directory")
 	}
 
 	// Open file for writing
-	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := os.OpenFile(destPath, os.O_CREATE|os.O_WRONLY, dm.fileMode)
 	if err != nil {
 		return errors.Wrap(err, "failed to open file")
 	}
@@ -440,6 +909,28 @@ func (dm *DownloadManager) downloadWithResume(
 	retries := 0
 	maxRetries := 3
 
+	// An unfinished download syncs what it wrote and journals the offset,
+	// every journalInterval and when it stops
+	journaledOffset := startOffset
+	lastJournal := time.Now()
+	journalWritten := func() {
+		if journalFn == nil || currentOffset == journaledOffset {
+			return
+		}
+		if err := file.Sync(); err != nil {
+			log.Warn("Failed to sync partial download", "file_id", fileID, "error", err)
+			return
+		}
+		journaledOffset = currentOffset
+		lastJournal = time.Now()
+		journalFn(currentOffset)
+	}
+	defer func() {
+		if currentOffset < totalSize {
+			journalWritten()
+		}
+	}()
+
 	for currentOffset < totalSize && retries < maxRetries {
 		// Calculate chunk boundaries
 		endOffset := currentOffset + dm.chunkSize - 1
@@ -447,11 +938,27 @@ func (dm *DownloadManager) downloadWithResume(
 			endOffset = totalSize - 1
 		}
 
-		// Download chunk
-		resp, err := dm.client.GetFileContent(ctx, fileID, currentOffset, endOffset)
+		// Download chunk; the chunk timeout covers the request and the body
+		chunkCtx, cancelChunk := ctx, context.CancelFunc(func() {})
+		if dm.perChunkTimeout > 0 {
+			chunkCtx, cancelChunk = context.WithTimeout(ctx, dm.perChunkTimeout)
+		}
+
+		resp, err := dm.client.GetFileContent(chunkCtx, fileID, currentOffset, endOffset)
 		if err != nil {
+			cancelChunk()
+			if ctx.Err() == nil && chunkCtx.Err() == context.DeadlineExceeded {
+				return downloadTimeoutError("chunk", dm.perChunkTimeout)
+			}
+
+			// Another request cannot bring back a deleted file or grant
+			// access; the worker decides what happens to the file
+			if api.IsNotFound(err) || api.IsPermissionDenied(err) || api.IsReauthRequired(err) {
+				return err
+			}
+
 			retries++
-			dm.logger.Warn("Chunk download failed, retrying",
+			log.Warn("Chunk download failed, retrying",
 				"file_id", fileID,
 				"offset", currentOffset,
 				"retry", retries,
@@ -467,21 +974,33 @@ func (dm *DownloadManager) downloadWithResume(
 			}
 		}
 
-		// Write chunk
-		written, err := io.Copy(file, resp.Body)
+		// Write chunk, throttled by the limiters shared with other workers
+		dm.trackConnection(1)
+		written, err := io.Copy(file, dm.throttle(ctx, tier, resp.Body))
 		resp.Body.Close()
+		dm.trackConnection(-1)
+		chunkTimedOut := ctx.Err() == nil && chunkCtx.Err() == context.DeadlineExceeded
+		cancelChunk()
+		currentOffset += written
 
 		if err != nil {
+			if chunkTimedOut {
+				// Bytes already written are kept and resumed on retry
+				return downloadTimeoutError("chunk", dm.perChunkTimeout)
+			}
 			return errors.Wrap(err, "failed to write chunk")
 		}
 
-		currentOffset += written
 		retries = 0 // Reset retries on success
 
 		// Report progress
 		if progressFn != nil {
 			progressFn(currentOffset-startOffset, totalSize-startOffset)
 		}
+
+		if currentOffset < totalSize && time.Since(lastJournal) >= journalInterval {
+			journalWritten()
+		}
 	}
 
 	if currentOffset < totalSize {
@@ -491,126 +1010,193 @@ func (dm *DownloadManager) downloadWithResume(
 	return nil
 }
 
-// verifyChecksum verifies file checksum.
-func (dm *DownloadManager) verifyChecksum(filePath string, expectedMD5 string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return errors.Wrap(err, "failed to open file")
+// moveToFinal moves file from temp to final location atomically. The temp
+// file is synced before the rename and the directory after it, so a crash
+// never leaves a truncated file under the final name. The copy fallback
+// stops when ctx is canceled and leaves the temp file in place.
+func (dm *DownloadManager) moveToFinal(ctx context.Context, tempPath, finalPath string) error {
+	// Ensure destination directory exists
+	if err := os.MkdirAll(filepath.Dir(finalPath), dm.dirMode); err != nil {
+		return errors.Wrap(err, "failed to create destination directory")
 	}
-	defer file.Close()
 
-	hash := md5.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return errors.Wrap(err, "failed to calculate checksum")
+	if err := dm.fsyncFile(tempPath); err != nil {
+		return errors.Wrap(err, "failed to sync temp file")
 	}
 
-	actualMD5 := hex.EncodeToString(hash.Sum(nil))
-	if actualMD5 != expectedMD5 {
-		return errors.Errorf("checksum mismatch: expected %s, got %s", expectedMD5, actualMD5)
+	// Try atomic rename first
+	if err := os.Rename(tempPath, finalPath); err == nil {
+		return dm.syncFinalDir(finalPath)
 	}
 
-	dm.logger.Debug("Checksum verified",
-		"file", filePath,
-		"md5", actualMD5,
-	)
-
-	return nil
-}
+	// Fall back to copy and delete (for cross-device moves)
+	if err := dm.copyToFinal(ctx, tempPath, finalPath); err != nil {
+		return err
+	}
 
-// moveToFinal moves file from temp to final location atomically.
-func (dm *DownloadManager) moveToFinal(tempPath, finalPath string) error {
-	// Ensure destination directory exists
-	if err := os.MkdirAll(filepath.Dir(finalPath), 0750); err != nil {
-		return errors.Wrap(err, "failed to create destination directory")
+	if err := dm.syncFinalDir(finalPath); err != nil {
+		return err
 	}
 
-	// Try atomic rename first
-	if err := os.Rename(tempPath, finalPath); err == nil {
-		return nil
+	// Remove temp file
+	if err := os.Remove(tempPath); err != nil {
+		dm.logger.Error(err, "failed to remove temp file after successful move", "path", tempPath)
 	}
 
-	// Fall back to copy and delete (for cross-device moves)
+	return nil
+}
+
+// copyToFinal copies tempPath to finalPath and syncs the copy. A partial
+// copy is removed.
+func (dm *DownloadManager) copyToFinal(ctx context.Context, tempPath, finalPath string) error {
 	src, err := os.Open(tempPath)
 	if err != nil {
 		return errors.Wrap(err, "failed to open source file")
 	}
 	defer src.Close()
 
-	dst, err := os.Create(finalPath)
+	dst, err := os.OpenFile(finalPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, dm.fileMode)
 	if err != nil {
 		return errors.Wrap(err, "failed to create destination file")
 	}
-	defer func() {
-		if err := dst.Close(); err != nil {
-			dm.logger.Error(err, "failed to close destination file", "path", finalPath)
-		}
-	}()
 
-	if _, err := io.Copy(dst, src); err != nil {
+	_, err = io.Copy(dst, util.ContextReader(ctx, src))
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+
+	if err != nil {
 		if removeErr := os.Remove(finalPath); removeErr != nil {
 			dm.logger.Error(removeErr, "failed to remove partial file after copy failure", "path", finalPath)
 		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		return errors.Wrap(err, "failed to copy file")
 	}
 
-	// Remove temp file
-	if err := os.Remove(tempPath); err != nil {
-		dm.logger.Error(err, "failed to remove temp file after successful move", "path", tempPath)
-	}
+	return nil
+}
 
+// syncFinalDir makes the new directory entry of finalPath durable.
+func (dm *DownloadManager) syncFinalDir(finalPath string) error {
+	if err := dm.fsyncDir(filepath.Dir(finalPath)); err != nil {
+		return errors.Wrap(err, "failed to sync destination directory")
+	}
 	return nil
 }
 
-// calculatePriorities calculates download priorities based on file size.
-func (dm *DownloadManager) calculatePriorities(files []*state.File) map[string]int {
+// calculatePriorities calculates download priorities for files. Lower
+// numbers are downloaded first: the MIME type tier dominates, then size as
+// the schedule order asks, then the position in files. It also returns how
+// many files fell into each tier.
+func (dm *DownloadManager) calculatePriorities(files []*state.File) (map[string]int, map[PriorityTier]int) {
 	priorities := make(map[string]int)
+	tierCounts := make(map[PriorityTier]int)
 
-	// Sort by size (smallest first gets higher priority = lower number)
 	for i, file := range files {
-		if file.Size < 1024*1024 { // < 1MB
-			priorities[file.ID] = i
-		} else if file.Size < 10*1024*1024 { // < 10MB
-			priorities[file.ID] = i + 1000
-		} else if file.Size < 100*1024*1024 { // < 100MB
-			priorities[file.ID] = i + 2000
-		} else {
-			priorities[file.ID] = i + 3000
+		tier := dm.tierFor(file)
+		tierCounts[tier]++
+		if tier != PriorityTierNormal {
+			dm.logger.Debug("Applied priority tier",
+				"file", file.Name,
+				"mime_type", file.MimeType.String,
+				"tier", tier.String(),
+			)
+		}
+
+		var sizePriority int
+		switch dm.scheduleOrder {
+		case ScheduleLargestFirst:
+			sizePriority = i + (3-sizeClass(file.Size))*1000
+		case ScheduleDriveOrder:
+			sizePriority = i
+		default:
+			sizePriority = i + sizeClass(file.Size)*1000
 		}
+
+		priorities[file.ID] = int(tier)*tierPrioritySpan + sizePriority
 	}
 
-	return priorities
+	return priorities, tierCounts
+}
+
+// tierFor returns the priority tier of a file based on its MIME type.
+func (dm *DownloadManager) tierFor(file *state.File) PriorityTier {
+	return tierForMimeType(dm.priorityRules, file.MimeType.String)
 }
 
+// throttle wraps r with the session bandwidth limit, the limit shared with
+// other sessions and the cap of tier.
+func (dm *DownloadManager) throttle(ctx context.Context, tier PriorityTier, r io.Reader) io.Reader {
+	r = dm.progressTracker.ThrottleReader(ctx, r)
+	if dm.sharedBandwidth != nil {
+		r = dm.sharedBandwidth.ThrottleReader(ctx, r)
+	}
+
+	if limiter, ok := dm.tierLimiters[tier]; ok {
+		r = &throttledReader{
+			ctx:     ctx,
+			reader:  r,
+			limiter: func() *rate.Limiter { return limiter },
+		}
+	}
+
+	return r
+}
+
+// downloadTempDirName is the directory below the configured temp directory
+// that holds partial downloads, in one subdirectory per session.
+const downloadTempDirName = "cloudpull-downloads"
+
 // getTempPath generates a temporary file path.
 func (dm *DownloadManager) getTempPath(file *state.File) string {
-	// Use file ID to ensure uniqueness
-	filename := fmt.Sprintf("%s_%s", file.ID, file.Name)
-	return filepath.Join(dm.tempDir, filename)
+	return filepath.Join(dm.tempDir, file.SessionID, tempFileName(file))
 }
 
-// getExportExtension returns the file extension for an export format.
-func (dm *DownloadManager) getExportExtension(mimeType string) string {
-	extensions := map[string]string{
-		"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
-		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
-		"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
-		"application/pdf": ".pdf",
-		"text/plain":      ".txt",
-		"text/html":       ".html",
-		"text/csv":        ".csv",
+// DownloadTempPath returns where the partial download or export of file is
+// kept for a download manager configured with tempDir.
+func DownloadTempPath(tempDir string, file *state.File) string {
+	path := filepath.Join(tempDir, downloadTempDirName, file.SessionID, tempFileName(file))
+	if file.IsGoogleDoc && file.ExportMimeType.Valid {
+		path = exportTempPath(path, file.ExportMimeType.String)
 	}
+	return path
+}
 
-	if ext, ok := extensions[mimeType]; ok {
-		return ext
+// exportTempPath returns the temp path of an export to format: ExportFile
+// writes to a path ending in the export extension.
+func exportTempPath(tempPath, format string) string {
+	if ext := exportExtension(format); ext != "" && !strings.HasSuffix(tempPath, ext) {
+		return tempPath + ext
 	}
-	return ""
+	return tempPath
 }
 
-// cleanupTempFiles removes all temporary files.
+// tempFileName names the partial download of file.
+func tempFileName(file *state.File) string {
+	// Use file ID to ensure uniqueness
+	return fmt.Sprintf("%s_%s", file.ID, file.Name)
+}
+
+// exportExtension returns the file extension for an export format.
+func exportExtension(mimeType string) string {
+	return exportExtensions[mimeType]
+}
+
+// cleanupTempFiles removes the temporary files of the current session
+// except journaled partial downloads, which a resumed session continues.
+// Other sessions may be running in other processes, so their temp files
+// are left alone.
 func (dm *DownloadManager) cleanupTempFiles() error {
+	keep := dm.journaledTempPaths()
+
 	// First, clean up any active downloads
 	dm.activeDownloads.Range(func(key, value interface{}) bool {
-		if info, ok := value.(*DownloadInfo); ok {
+		if info, ok := value.(*DownloadInfo); ok && !keep[info.TempPath] {
 			if _, err := os.Stat(info.TempPath); err == nil {
 				dm.logger.Debug("Removing temp file", "path", info.TempPath)
 				if err := os.Remove(info.TempPath); err != nil {
@@ -621,40 +1207,53 @@ func (dm *DownloadManager) cleanupTempFiles() error {
 		return true
 	})
 
-	// Then, clean up all files in temp directory from previous runs
-	if dm.tempDir != "" {
-		// Create temp directory if it doesn't exist
-		if err := os.MkdirAll(dm.tempDir, 0750); err != nil {
-			return errors.Wrap(err, "failed to create temp directory")
-		}
+	// Then, clean up the files a previous run of the session left behind
+	sessionID := dm.progressTracker.sessionID
+	if dm.tempDir == "" || sessionID == "" {
+		return nil
+	}
+	sessionDir := filepath.Join(dm.tempDir, sessionID)
 
-		// Read all files in temp directory
-		entries, err := os.ReadDir(dm.tempDir)
-		if err != nil {
-			return errors.Wrap(err, "failed to read temp directory")
+	entries, err := os.ReadDir(sessionDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
 		}
+		return errors.Wrap(err, "failed to read temp directory")
+	}
 
-		// Remove all files (they're all temporary from previous runs)
-		removedCount := 0
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				filePath := filepath.Join(dm.tempDir, entry.Name())
-				if err := os.Remove(filePath); err != nil {
-					dm.logger.Warn("Failed to remove temp file", "file", filePath, "error", err)
-				} else {
-					removedCount++
-				}
+	removedCount := 0
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			filePath := filepath.Join(sessionDir, entry.Name())
+			if keep[filePath] {
+				continue
+			}
+			if err := os.Remove(filePath); err != nil {
+				dm.logger.Warn("Failed to remove temp file", "file", filePath, "error", err)
+			} else {
+				removedCount++
 			}
 		}
+	}
 
-		if removedCount > 0 {
-			dm.logger.Info("Cleaned up old temporary files", "count", removedCount, "directory", dm.tempDir)
-		}
+	if removedCount > 0 {
+		dm.logger.Info("Cleaned up old temporary files", "count", removedCount, "directory", sessionDir)
 	}
 
+	// Only an empty directory is removed
+	_ = os.Remove(sessionDir)
+
 	return nil
 }
 
+// trackConnection records an HTTP response being opened or closed.
+func (dm *DownloadManager) trackConnection(delta int64) {
+	dm.downloadStats.mu.Lock()
+	dm.downloadStats.OpenConnections += delta
+	dm.downloadStats.mu.Unlock()
+}
+
 // GetStats returns download manager statistics.
 func (dm *DownloadManager) GetStats() *DownloadManagerStats {
 	dm.downloadStats.mu.RLock()
@@ -673,6 +1272,8 @@ func (dm *DownloadManager) GetStats() *DownloadManagerStats {
 	return &DownloadManagerStats{
 		TotalDownloads:     dm.downloadStats.TotalDownloads,
 		ActiveDownloads:    dm.downloadStats.ActiveDownloads,
+		ActiveConnections:  dm.downloadStats.OpenConnections,
+		CurrentSpeed:       dm.progressTracker.ActiveDownloadSpeed(),
 		CompletedDownloads: dm.downloadStats.CompletedDownloads,
 		FailedDownloads:    dm.downloadStats.FailedDownloads,
 		BytesDownloaded:    dm.downloadStats.BytesDownloaded,
@@ -692,4 +1293,10 @@ type DownloadManagerStats struct {
 	BytesDownloaded    int64
 	AverageSpeed       int64
 	AverageDuration    time.Duration
+
+	// CurrentSpeed is the combined speed of downloads in progress
+	CurrentSpeed int64
+
+	// ActiveConnections is the number of response bodies being read
+	ActiveConnections int64
 }
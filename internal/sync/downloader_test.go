@@ -0,0 +1,510 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+func TestDownloadManagerStatsReportCurrentThroughput(t *testing.T) {
+	tracker := NewProgressTracker("session-1")
+	dm, err := NewDownloadManager(nil, nil, tracker, nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	tracker.FileStarted("a", "a.bin", "root/a.bin", 1024*1024)
+	tracker.FileStarted("b", "b.bin", "root/b.bin", 1024*1024)
+	tracker.FileStarted("c", "c.bin", "root/c.bin", 1024*1024)
+	time.Sleep(100 * time.Millisecond)
+	tracker.FileProgress("a", 100*1024)
+	tracker.FileProgress("b", 200*1024)
+
+	dm.trackConnection(1)
+	dm.trackConnection(1)
+
+	stats := dm.GetStats()
+	a, b := tracker.activeDownloads["a"].Speed, tracker.activeDownloads["b"].Speed
+	assert.Positive(t, a)
+	assert.Positive(t, b)
+	assert.Equal(t, a+b, stats.CurrentSpeed)
+	assert.Equal(t, int64(2), stats.ActiveConnections)
+
+	// Finished downloads no longer contribute
+	tracker.FileCompleted("b")
+	dm.trackConnection(-1)
+
+	stats = dm.GetStats()
+	assert.Equal(t, a, stats.CurrentSpeed)
+	assert.Equal(t, int64(1), stats.ActiveConnections)
+}
+
+func TestMoveToFinalSyncsBeforeRename(t *testing.T) {
+	dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	tempPath := filepath.Join(t.TempDir(), "a.bin.tmp")
+	finalPath := filepath.Join(t.TempDir(), "docs", "a.bin")
+	require.NoError(t, os.WriteFile(tempPath, []byte("data"), 0600))
+
+	var calls []string
+	dm.fsyncFile = func(path string) error {
+		calls = append(calls, "file")
+		assert.Equal(t, tempPath, path)
+		assert.NoFileExists(t, finalPath, "file is synced before the rename")
+		return util.SyncFile(path)
+	}
+	dm.fsyncDir = func(dir string) error {
+		calls = append(calls, "dir")
+		assert.Equal(t, filepath.Dir(finalPath), dir)
+		assert.FileExists(t, finalPath, "directory is synced after the rename")
+		return util.SyncDir(dir)
+	}
+
+	require.NoError(t, dm.moveToFinal(context.Background(), tempPath, finalPath))
+	assert.Equal(t, []string{"file", "dir"}, calls)
+	assert.NoFileExists(t, tempPath)
+
+	// A failed sync keeps the temp file and publishes nothing
+	require.NoError(t, os.WriteFile(tempPath, []byte("data"), 0600))
+	otherPath := filepath.Join(filepath.Dir(finalPath), "b.bin")
+	dm.fsyncFile = func(string) error { return errors.NewSimple("disk full") }
+	assert.Error(t, dm.moveToFinal(context.Background(), tempPath, otherPath))
+	assert.FileExists(t, tempPath)
+	assert.NoFileExists(t, otherPath)
+}
+
+func TestDownloadTempPathOfExports(t *testing.T) {
+	file := &state.File{ID: "doc", SessionID: "session-1", Name: "Notes"}
+	assert.Equal(t, filepath.Join("tmp", downloadTempDirName, "session-1", "doc_Notes"), DownloadTempPath("tmp", file))
+
+	// Exports are written below the export extension
+	file.IsGoogleDoc = true
+	file.ExportMimeType = state.NewNullString("application/vnd.oasis.opendocument.text")
+	assert.Equal(t, filepath.Join("tmp", downloadTempDirName, "session-1", "doc_Notes.odt"), DownloadTempPath("tmp", file))
+}
+
+func TestTempCleanupKeepsOtherSessionsFiles(t *testing.T) {
+	m := newTestStateManager(t)
+	config := DefaultDownloadManagerConfig()
+	config.TempDir = t.TempDir()
+
+	// Another process is downloading a file of its own session
+	other := DownloadTempPath(config.TempDir, &state.File{ID: "b", SessionID: "session-b", Name: "b.bin"})
+	require.NoError(t, os.MkdirAll(filepath.Dir(other), 0755))
+	require.NoError(t, os.WriteFile(other, []byte("partial"), 0644))
+
+	// This session left a temp file behind in an earlier run
+	own := DownloadTempPath(config.TempDir, &state.File{ID: "a", SessionID: "session-a", Name: "a.bin"})
+	require.NoError(t, os.MkdirAll(filepath.Dir(own), 0755))
+	require.NoError(t, os.WriteFile(own, []byte("stale"), 0644))
+
+	dm, err := NewDownloadManager(nil, m, NewProgressTracker("session-a"), nil, newTestLogger(), config)
+	require.NoError(t, err)
+	require.NoError(t, dm.cleanupTempFiles())
+
+	assert.NoFileExists(t, own)
+	assert.FileExists(t, other)
+}
+
+func TestEngineRetriesStalledChunk(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const size = 64
+	file := &drive.File{Id: "stalled", Name: "stalled.bin", MimeType: "application/octet-stream", Size: size}
+
+	// The first ranged request sends half the chunk and then hangs
+	var mediaRequests atomic.Int32
+	var retryRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") == "media" {
+			if mediaRequests.Add(1) == 1 {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", size-1, size))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(make([]byte, size/2))
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+				return
+			}
+			retryRange.Store(r.Header.Get("Range"))
+			http.ServeContent(w, r, file.Name, time.Time{}, bytes.NewReader(make([]byte, size)))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Trim(r.URL.Path, "/") == "files" {
+			json.NewEncoder(w).Encode(&drive.FileList{Files: []*drive.File{file}})
+			return
+		}
+		json.NewEncoder(w).Encode(file)
+	}))
+	t.Cleanup(server.Close)
+
+	service, err := drive.NewService(ctx, option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	require.NoError(t, err)
+	client := api.NewDriveClient(service, api.NewRateLimiter(api.DefaultRateLimiterConfig()), newTestLogger())
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.PerChunkTimeout = 200 * time.Millisecond
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int32(2), mediaRequests.Load())
+
+	// The retry resumes after the bytes received before the stall
+	assert.Equal(t, fmt.Sprintf("bytes=%d-%d", size/2, size-1), retryRange.Load())
+
+	// The timeout is logged as retryable
+	var logged []struct {
+		Message   string `db:"error_message"`
+		Retryable bool   `db:"is_retryable"`
+	}
+	require.NoError(t, m.DB().SelectContext(ctx, &logged,
+		"SELECT error_message, is_retryable FROM error_log WHERE session_id = $1 AND error_type = 'download_timeout'", sessionID))
+	require.Len(t, logged, 1)
+	assert.True(t, logged[0].Retryable)
+	assert.Contains(t, logged[0].Message, "chunk timeout")
+
+	stored, err := m.Files().GetByDriveID(ctx, "stalled", sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusCompleted, stored.Status)
+	info, err := os.Stat(LocalFilePath(session, stored))
+	require.NoError(t, err)
+	assert.Equal(t, int64(size), info.Size())
+}
+
+func TestEngineCreatesEmptyFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	// Drive serves no content for empty files, so none must be requested
+	children := map[string][]*drive.File{
+		"root": {{Id: "empty", Name: "empty.txt", MimeType: "text/plain", Size: 0,
+			Md5Checksum: "d41d8cd98f00b204e9800998ecf8427e"}},
+	}
+	var mediaRequests atomic.Int32
+	handler := fakeDriveHandler(children, nil, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") == "media" {
+			mediaRequests.Add(1)
+		}
+		handler(w, r)
+	}))
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int64(1), session.CompletedFiles)
+	assert.Zero(t, mediaRequests.Load())
+
+	stored, err := m.Files().GetByDriveID(ctx, "empty", sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusCompleted, stored.Status)
+
+	info, err := os.Stat(LocalFilePath(session, stored))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+}
+
+func TestEngineStreamsFilesOfUnknownSize(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	// Some imported files are listed without a size or checksum
+	content := strings.Repeat("0123456789", 1000)
+	children := map[string][]*drive.File{
+		"root": {{Id: "imported", Name: "scan.bin", MimeType: "application/octet-stream"}},
+	}
+	var mediaRequests atomic.Int32
+	var ranged atomic.Bool
+	handler := fakeDriveHandler(children, map[string]string{"imported": content}, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") == "media" {
+			mediaRequests.Add(1)
+			if r.Header.Get("Range") != "" {
+				ranged.Store(true)
+			}
+		}
+		handler(w, r)
+	}))
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.ChunkSize = 4096
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int64(len(content)), session.CompletedBytes)
+
+	// One request read the file to its end
+	assert.Equal(t, int32(1), mediaRequests.Load())
+	assert.False(t, ranged.Load())
+
+	stored, err := m.Files().GetByDriveID(ctx, "imported", sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusCompleted, stored.Status)
+	assert.Equal(t, int64(len(content)), stored.Size)
+
+	written, err := os.ReadFile(LocalFilePath(session, stored))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(written))
+}
+
+func TestCorruptPartialDownloadIsRequeued(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	content := strings.Repeat("cloudpull", 100)
+	sum := md5.Sum([]byte(content))
+	children := map[string][]*drive.File{"root": {{Id: "data", Name: "data.txt", Size: int64(len(content))}}}
+	client := newFakeDriveClientWithContent(t, children, map[string]string{"data": content}, nil)
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+	file := &state.File{DriveID: "data", FolderID: folder.ID, SessionID: session.ID, Name: "data.txt",
+		Path: "root/data.txt", Size: int64(len(content)), Status: state.FileStatusDownloading,
+		MD5Checksum: state.NewNullString(hex.EncodeToString(sum[:]))}
+	require.NoError(t, m.Files().Create(ctx, file))
+	require.NoError(t, m.Files().UpdateProgress(ctx, file.ID, int64(len(content)/2)))
+
+	config := DefaultDownloadManagerConfig()
+	config.TempDir = t.TempDir()
+	dm, err := NewDownloadManager(client, m, NewProgressTracker(session.ID), nil, newTestLogger(), config)
+	require.NoError(t, err)
+
+	// The first half on disk was damaged before the download resumed
+	tempPath := dm.getTempPath(file)
+	require.NoError(t, os.MkdirAll(filepath.Dir(tempPath), 0750))
+	require.NoError(t, os.WriteFile(tempPath, bytes.Repeat([]byte("x"), len(content)/2), 0644))
+	require.NoError(t, m.Files().RecordFlushedOffset(ctx, &state.DownloadJournal{FileID: file.ID,
+		TempPath: tempPath, FlushedBytes: int64(len(content) / 2), Size: file.Size, MD5Checksum: file.MD5Checksum}))
+
+	err = dm.DownloadFile(ctx, file, nil)
+	require.Error(t, err)
+	var typed *errors.Error
+	require.True(t, errors.AsError(err, &typed))
+	assert.Equal(t, errors.ErrorTypeCorruption, typed.Type)
+	assert.NoFileExists(t, tempPath)
+
+	stored, err := m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusPending, stored.Status)
+	assert.Zero(t, stored.BytesDownloaded)
+	journal, err := m.Files().GetJournal(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Nil(t, journal)
+
+	logged, err := m.GetErrors(ctx, session.ID, &state.ErrorLogFilter{ErrorType: ErrorTypeCorruption})
+	require.NoError(t, err)
+	require.Len(t, logged, 1)
+	assert.True(t, logged[0].IsRetryable)
+
+	// The next attempt starts over and succeeds
+	require.NoError(t, dm.DownloadFile(ctx, stored, nil))
+	downloaded, err := os.ReadFile(LocalFilePath(session, stored))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(downloaded))
+}
+
+func TestDownloadResumesFromJournalAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	content := strings.Repeat("0123456789", 100)
+	sum := md5.Sum([]byte(content))
+	const half = 500
+
+	// Ranged requests are recorded; while crashing is set, the request for
+	// the second half stops the download as a crash would
+	crashCtx, crash := context.WithCancel(ctx)
+	defer crash()
+	var crashing atomic.Bool
+	crashing.Store(true)
+	var servedBytes atomic.Int64
+	var firstStart atomic.Int64
+	firstStart.Store(-1)
+
+	children := map[string][]*drive.File{"root": {{Id: "data", Name: "data.txt", Size: int64(len(content))}}}
+	inner := fakeDriveHandler(children, map[string]string{"data": content}, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+			if crashing.Load() && start >= half {
+				crash()
+				http.Error(w, "crashed", http.StatusServiceUnavailable)
+				return
+			}
+			firstStart.CompareAndSwap(-1, start)
+			servedBytes.Add(end - start + 1)
+		}
+		inner(w, r)
+	}))
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+	file := &state.File{DriveID: "data", FolderID: folder.ID, SessionID: session.ID, Name: "data.txt",
+		Path: "root/data.txt", Size: int64(len(content)), Status: state.FileStatusDownloading,
+		MD5Checksum: state.NewNullString(hex.EncodeToString(sum[:]))}
+	require.NoError(t, m.Files().Create(ctx, file))
+
+	config := DefaultDownloadManagerConfig()
+	config.TempDir = t.TempDir()
+	config.ChunkSize = 100
+	dm, err := NewDownloadManager(client, m, NewProgressTracker(session.ID), nil, newTestLogger(), config)
+	require.NoError(t, err)
+
+	require.Error(t, dm.DownloadFile(crashCtx, file, nil))
+	assert.Equal(t, int64(half), servedBytes.Load())
+
+	journal, err := m.Files().GetJournal(ctx, file.ID)
+	require.NoError(t, err)
+	require.NotNil(t, journal)
+	assert.Equal(t, int64(half), journal.FlushedBytes)
+
+	// Bytes written after the last journal entry never reached the disk
+	tempPath := dm.getTempPath(file)
+	partial, err := os.OpenFile(tempPath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = partial.WriteString("garbage")
+	require.NoError(t, err)
+	require.NoError(t, partial.Close())
+
+	// A new download manager keeps the journaled temp file
+	crashing.Store(false)
+	servedBytes.Store(0)
+	firstStart.Store(-1)
+	restarted, err := NewDownloadManager(client, m, NewProgressTracker(session.ID), nil, newTestLogger(), config)
+	require.NoError(t, err)
+	require.NoError(t, restarted.cleanupTempFiles())
+	require.FileExists(t, tempPath)
+
+	stored, err := m.Files().Get(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(half), stored.BytesDownloaded)
+
+	require.NoError(t, restarted.DownloadFile(ctx, stored, nil))
+
+	// Only the second half was requested again
+	assert.Equal(t, int64(half), firstStart.Load())
+	assert.Equal(t, int64(len(content)-half), servedBytes.Load())
+
+	downloaded, err := os.ReadFile(LocalFilePath(session, stored))
+	require.NoError(t, err)
+	assert.Equal(t, content, string(downloaded))
+
+	journal, err = m.Files().GetJournal(ctx, file.ID)
+	require.NoError(t, err)
+	assert.Nil(t, journal)
+}
+
+func TestDownloadUsesConfiguredFileModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not supported on Windows")
+	}
+
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	content := "configured permissions"
+	children := map[string][]*drive.File{"sub": {{Id: "data", Name: "data.txt", Size: int64(len(content))}}}
+	client := newFakeDriveClientWithContent(t, children, map[string]string{"data": content}, nil)
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "sub", SessionID: session.ID, Name: "sub", Path: "root/sub",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+	file := &state.File{DriveID: "data", FolderID: folder.ID, SessionID: session.ID, Name: "data.txt",
+		Path: "root/sub/data.txt", Size: int64(len(content)), Status: state.FileStatusDownloading}
+	require.NoError(t, m.Files().Create(ctx, file))
+
+	config := DefaultDownloadManagerConfig()
+	config.TempDir = t.TempDir()
+	config.FileMode = 0640
+	config.DirMode = 0710
+	dm, err := NewDownloadManager(client, m, NewProgressTracker(session.ID), nil, newTestLogger(), config)
+	require.NoError(t, err)
+	require.NoError(t, dm.DownloadFile(ctx, file, nil))
+
+	assertMode := func(path string, expected os.FileMode) {
+		t.Helper()
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, info.Mode().Perm(), path)
+	}
+	assertMode(filepath.Join(config.TempDir, downloadTempDirName), 0710)
+	assertMode(filepath.Join(session.DestinationPath, "root"), 0710)
+	assertMode(filepath.Join(session.DestinationPath, "root", "sub"), 0710)
+	assertMode(LocalFilePath(session, file), 0640)
+}
@@ -6,7 +6,7 @@
  * - Manages sync sessions and state persistence
  * - Handles pause/resume functionality
  * - Provides real-time progress monitoring
- * - Implements graceful shutdown
+ * - Implements graceful shutdown with in-flight download draining
  *
  * Author: CloudPull Team
  * Updated: 2025-01-29
@@ -17,13 +17,19 @@ package sync
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/VatsalSy/CloudPull/internal/api"
 	"github.com/VatsalSy/CloudPull/internal/errors"
 	"github.com/VatsalSy/CloudPull/internal/logger"
 	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
 )
 
 // Engine is the main sync orchestrator.
@@ -36,10 +42,16 @@ type Engine struct {
 	logger          *logger.Logger
 	walker          *FolderWalker
 	downloader      *DownloadManager
+	hooks           *HookRunner
 	doneChan        chan struct{}
-	client          *api.DriveClient
+	client          api.DriveAPI
 	currentSession  *state.Session
-	errorChan       chan error
+	errorChan       chan *syncError
+	completionCheck chan struct{}
+	downloadFunc    func(ctx context.Context, file *state.File) (int64, error)
+	retryFiles      []*state.File
+	events          *eventLog
+	stream          *eventStream
 	cancel          context.CancelFunc
 	sessionID       string
 	wg              sync.WaitGroup
@@ -47,6 +59,40 @@ type Engine struct {
 	isPaused        bool
 	isRunning       bool
 	walkingComplete bool
+	completed       bool
+	hooksFired      bool
+	resumed         bool
+
+	// scanOnly walks the folders of a new session without downloading
+	scanOnly bool
+
+	// scheduleBacklog counts walked files not yet handed to the download
+	// manager
+	scheduleBacklog atomic.Int64
+
+	// quotaReached is set once MaxTotalBytes were downloaded; quotaDrained
+	// once the downloads in flight at that moment have finished
+	quotaReached bool
+	quotaDrained bool
+
+	// apiCallBase is the client's call count when this run started;
+	// apiLimitReached is set once MaxAPICalls requests were made since
+	apiCallBase     int64
+	apiLimitReached bool
+
+	// authLost is set when Drive rejected the authorization and it could
+	// not be refreshed
+	authLost bool
+
+	// stalled is set when the watchdog stopped a sync that made no
+	// progress; lastErr is the latest error reported, for its diagnostics
+	stalled bool
+	lastErr error
+
+	// checkpointed holds the session counters stored by the last
+	// checkpoint; checkpoints only write what changed since
+	checkpointed state.SessionProgressDelta
+	checkpointMu sync.Mutex
 }
 
 // EngineConfig contains configuration for the sync engine.
@@ -66,8 +112,89 @@ type EngineConfig struct {
 	// Session checkpoint interval
 	CheckpointInterval time.Duration
 
-	// Maximum errors before stopping
+	// Completion hook configuration
+	HookConfig *HookConfig
+
+	// Bandwidth limit in bytes per second (0 = unlimited)
+	BandwidthLimit int64
+
+	// Flatten downloads new sessions into a single directory instead of
+	// mirroring the Drive hierarchy
+	Flatten bool
+
+	// DestDateSubdir is a Go time layout; when set, each new session
+	// downloads into a subdirectory of its destination named after the
+	// date it starts
+	DestDateSubdir string
+
+	// Maximum errors before stopping; folders that cannot be listed and
+	// files that fail for good both count, each file once
 	MaxErrors int
+
+	// MaxConsecutiveErrors stops the sync once this many files failed for
+	// good in a row, without one completing in between (0 = disabled)
+	MaxConsecutiveErrors int
+
+	// ContinueOnErrors keeps syncing after MaxErrors is reached; a sync
+	// with failed files then ends completed_with_errors
+	ContinueOnErrors bool
+
+	// SkipPermissionErrors keeps files Drive refuses access to from
+	// counting toward MaxErrors
+	SkipPermissionErrors bool
+
+	// QuietProgress suppresses the periodic progress log lines
+	QuietProgress bool
+
+	// MaxTotalBytes stops scheduling downloads once a sync has downloaded
+	// this many bytes (0 = unlimited)
+	MaxTotalBytes int64
+
+	// MaxFiles stops the folder walk once this many files were scheduled
+	// for download (0 = unlimited)
+	MaxFiles int
+
+	// MaxAPICalls stops the folder walk and new downloads once a run has
+	// made this many Drive API requests (0 = unlimited)
+	MaxAPICalls int64
+
+	// Mirror removes local entries that no longer exist in Drive once a
+	// sync completes (nil = disabled)
+	Mirror *MirrorConfig
+
+	// BatchSize is the number of walked files scheduled for download, and
+	// of file records saved per transaction, at once (0 = 100)
+	BatchSize int
+
+	// MaxQueuedFiles pauses scheduling walked files while the download
+	// queue holds this many files; scanning continues until the schedule
+	// queue fills up (0 = unlimited)
+	MaxQueuedFiles int
+
+	// WriteReport writes a JSON completion report into the destination
+	// when a sync finishes
+	WriteReport bool
+
+	// ScanThenDownload finishes the folder walk before any download is
+	// scheduled, instead of downloading while folders are still listed
+	ScanThenDownload bool
+
+	// RefreshModified makes a resumed session download completed files
+	// again when Drive reports a newer modification time for them
+	RefreshModified bool
+
+	// EventStream receives every progress event of a sync as a JSON line,
+	// see StreamRecord; nil disables the stream
+	EventStream io.Writer
+
+	// PersistEvents records file events in the events table of the state
+	// database
+	PersistEvents bool
+
+	// NoProgressTimeout stops a sync as stalled once files are queued or
+	// downloading but no bytes or files completed for this long
+	// (0 = disabled)
+	NoProgressTimeout time.Duration
 }
 
 // DefaultEngineConfig returns default engine configuration.
@@ -76,15 +203,18 @@ func DefaultEngineConfig() *EngineConfig {
 		WalkerConfig:       DefaultWalkerConfig(),
 		DownloadConfig:     DefaultDownloadManagerConfig(),
 		WorkerConfig:       DefaultWorkerPoolConfig(),
+		HookConfig:         DefaultHookConfig(),
 		ProgressInterval:   time.Second,
 		CheckpointInterval: 30 * time.Second,
 		MaxErrors:          100,
+		BatchSize:          100,
+		MaxQueuedFiles:     10000,
 	}
 }
 
 // NewEngine creates a new sync engine.
 func NewEngine(
-	client *api.DriveClient,
+	client api.DriveAPI,
 	stateManager *state.Manager,
 	errorHandler *errors.Handler,
 	logger *logger.Logger,
@@ -96,13 +226,15 @@ func NewEngine(
 	}
 
 	engine := &Engine{
-		config:       config,
-		client:       client,
-		stateManager: stateManager,
-		errorHandler: errorHandler,
-		logger:       logger,
-		errorChan:    make(chan error, config.MaxErrors),
-		doneChan:     make(chan struct{}),
+		config:          config,
+		client:          client,
+		stateManager:    stateManager,
+		errorHandler:    errorHandler,
+		logger:          logger,
+		hooks:           NewHookRunner(logger, config.HookConfig),
+		errorChan:       make(chan *syncError, config.MaxErrors),
+		completionCheck: make(chan struct{}, 1),
+		doneChan:        make(chan struct{}),
 	}
 
 	return engine, nil
@@ -156,6 +288,77 @@ func (e *Engine) StartNewSessionWithID(ctx context.Context, rootFolderID, destin
 	return session.ID, nil
 }
 
+// ScanNewSession starts a new session that only walks the folders below
+// rootFolderID, recording every file in the state database without
+// downloading it. A completed scan leaves the session scanned, ready for
+// DownloadScannedSession. It returns the session ID.
+func (e *Engine) ScanNewSession(ctx context.Context, rootFolderID, destinationPath string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.isRunning {
+		return "", errors.Errorf("sync engine is already running")
+	}
+
+	session, err := e.createSession(ctx, rootFolderID, destinationPath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create session")
+	}
+
+	e.currentSession = session
+	e.sessionID = session.ID
+	e.scanOnly = true
+
+	if err := e.startSync(ctx); err != nil {
+		return "", err
+	}
+
+	return session.ID, nil
+}
+
+// DownloadScannedSession downloads the files recorded by ScanNewSession
+// from the state database, without listing folders again. A non-empty
+// destinationPath replaces the destination recorded by the scan, as when
+// the database was copied to another machine.
+func (e *Engine) DownloadScannedSession(ctx context.Context, sessionID, destinationPath string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.isRunning {
+		return errors.Errorf("sync engine is already running")
+	}
+
+	session, err := e.stateManager.GetSession(ctx, sessionID)
+	if err != nil {
+		return errors.Wrap(err, "failed to load session")
+	}
+
+	if session == nil {
+		return errors.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Status != state.SessionStatusScanned {
+		return errors.Errorf("session is not scanned: status=%s", session.Status)
+	}
+
+	if destinationPath != "" && destinationPath != session.DestinationPath {
+		if err := e.prepareDestination(destinationPath); err != nil {
+			return err
+		}
+		session.DestinationPath = destinationPath
+		if err := e.stateManager.UpdateSession(ctx, session); err != nil {
+			return errors.Wrap(err, "failed to update session destination")
+		}
+	}
+
+	// Every folder is scanned, so resuming only schedules the pending files
+	e.currentSession = session
+	e.sessionID = session.ID
+	e.resumed = true
+
+	return e.startSync(ctx)
+}
+
 // ResumeSession resumes an existing sync session.
 func (e *Engine) ResumeSession(ctx context.Context, sessionID string) error {
 	e.mu.Lock()
@@ -180,17 +383,59 @@ func (e *Engine) ResumeSession(ctx context.Context, sessionID string) error {
 		return errors.Errorf("session is already completed")
 	}
 
-	if session.Status == state.SessionStatusFailed || session.Status == state.SessionStatusCancelled {
+	if session.Status == state.SessionStatusCompletedWithErrors {
+		return errors.Errorf("session is already completed; retry its failed files instead")
+	}
+
+	if session.Status == state.SessionStatusCancelled {
 		return errors.Errorf("session cannot be resumed: status=%s", session.Status)
 	}
 
 	e.currentSession = session
 	e.sessionID = session.ID
+	e.resumed = true
 
 	// Start sync
 	return e.startSync(ctx)
 }
 
+// RetryFailed re-downloads only the failed files of a finished session that
+// have fewer than maxAttempts download attempts, without walking folders
+// again. It returns the number of files scheduled.
+func (e *Engine) RetryFailed(ctx context.Context, sessionID string, maxAttempts int) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.isRunning {
+		return 0, errors.Errorf("sync engine is already running")
+	}
+
+	files, err := e.stateManager.PrepareRetry(ctx, sessionID, maxAttempts)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to prepare retry")
+	}
+
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	// Reload so the session reflects its reopened status
+	session, err := e.stateManager.GetSession(ctx, sessionID)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to load session")
+	}
+
+	e.currentSession = session
+	e.sessionID = session.ID
+	e.retryFiles = files
+
+	if err := e.startSync(ctx); err != nil {
+		return 0, err
+	}
+
+	return len(files), nil
+}
+
 // Pause pauses the sync engine.
 func (e *Engine) Pause() error {
 	e.mu.Lock()
@@ -205,6 +450,9 @@ func (e *Engine) Pause() error {
 	}
 
 	e.isPaused = true
+	if e.downloader != nil {
+		e.downloader.SetPaused(true)
+	}
 	e.logger.Info("Sync engine paused")
 
 	// Update session status
@@ -232,6 +480,9 @@ func (e *Engine) Resume() error {
 	}
 
 	e.isPaused = false
+	if e.downloader != nil {
+		e.downloader.SetPaused(false)
+	}
 	e.logger.Info("Sync engine resumed")
 
 	// Update session status
@@ -272,6 +523,32 @@ func (e *Engine) Stop() error {
 	return nil
 }
 
+// Drain is the first phase of a graceful shutdown: it stops scheduling new
+// downloads, lets in-flight downloads finish until ctx expires and saves a
+// checkpoint. The engine keeps running until Stop is called.
+func (e *Engine) Drain(ctx context.Context) error {
+	e.mu.RLock()
+	running := e.isRunning
+	downloader := e.downloader
+	e.mu.RUnlock()
+
+	if !running || downloader == nil {
+		return nil
+	}
+
+	e.logger.Info("Draining in-flight downloads before shutdown")
+
+	err := downloader.Drain(ctx)
+	e.saveCheckpoint()
+
+	if err != nil {
+		return errors.Wrap(err, "failed to drain downloads")
+	}
+
+	e.logger.Info("In-flight downloads finished, checkpoint saved")
+	return nil
+}
+
 // GetProgress returns current sync progress.
 func (e *Engine) GetProgress() *SyncProgress {
 	e.mu.RLock()
@@ -281,13 +558,45 @@ func (e *Engine) GetProgress() *SyncProgress {
 		return nil
 	}
 
+	return e.progressLocked()
+}
+
+// Snapshot returns a copy of the session being synced together with its
+// progress, both read at the same moment. The counters of a new session
+// come from the live progress; a resumed or retried run only tracks its
+// own files, so its counters are those of the last checkpoint. Both values
+// are nil until a session has been started.
+func (e *Engine) Snapshot() (*state.Session, *SyncProgress) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.currentSession == nil || e.progressTracker == nil {
+		return nil, nil
+	}
+
+	progress := e.progressLocked()
+	session := *e.currentSession
+	if !e.isResuming() && !e.isRetrying() {
+		session.TotalFiles = progress.TotalFiles
+		session.TotalBytes = progress.TotalBytes
+		session.CompletedFiles = progress.CompletedFiles
+		session.FailedFiles = progress.FailedFiles
+		session.SkippedFiles = progress.SkippedFiles
+		session.CompletedBytes = progress.CompletedBytes
+	}
+
+	return &session, progress
+}
+
+// progressLocked builds the current progress. e.mu must be held.
+func (e *Engine) progressLocked() *SyncProgress {
 	stats := e.progressTracker.GetStats()
 	walkerStats := &WalkerStats{}
 	if e.walker != nil {
 		walkerStats = e.walker.GetStats()
 	}
 
-	downloadStats := &DownloadManagerStats{}
+	downloadStats := &DownloadManagerStats{WorkerPoolStats: &WorkerPoolStats{}}
 	if e.downloader != nil {
 		downloadStats = e.downloader.GetStats()
 	}
@@ -309,9 +618,146 @@ func (e *Engine) GetProgress() *SyncProgress {
 		FoldersScanned:  walkerStats.FoldersScanned,
 		ActiveDownloads: downloadStats.ActiveDownloads,
 		QueuedDownloads: downloadStats.WorkerPoolStats.QueuedTasks,
+		ScheduleBacklog: e.scheduleBacklog.Load(),
+		APICalls:        e.apiCallsLocked(),
+
+		DownloadSpeed:     downloadStats.CurrentSpeed,
+		ActiveConnections: downloadStats.ActiveConnections,
 	}
 }
 
+// GetStats returns raw progress tracker and worker pool statistics.
+// Both values are nil until a session has been started.
+func (e *Engine) GetStats() (*ProgressStats, *WorkerPoolStats) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.progressTracker == nil {
+		return nil, nil
+	}
+
+	workerStats := &WorkerPoolStats{}
+	if e.downloader != nil {
+		workerStats = e.downloader.GetStats().WorkerPoolStats
+	}
+	workerStats.ScheduleBacklog = e.scheduleBacklog.Load()
+
+	return e.progressTracker.GetStats(), workerStats
+}
+
+// SetFlatten sets whether sessions started afterwards download every file
+// into the destination directory without the Drive hierarchy. Resumed
+// sessions keep the layout they were started with.
+func (e *Engine) SetFlatten(flatten bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.Flatten = flatten
+}
+
+// SetDestDateSubdir sets the Go time layout of the dated subdirectory new
+// sessions download into. An empty layout downloads into the destination.
+func (e *Engine) SetDestDateSubdir(layout string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.DestDateSubdir = layout
+}
+
+// SetChecksumAlgorithm selects the local checksums recorded for files
+// downloaded by sessions started afterwards.
+func (e *Engine) SetChecksumAlgorithm(algorithm ChecksumAlgorithm) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.config.DownloadConfig == nil {
+		e.config.DownloadConfig = DefaultDownloadManagerConfig()
+	}
+	e.config.DownloadConfig.ChecksumAlgorithm = algorithm
+}
+
+// SetMaxTotalBytes caps the bytes downloaded by syncs started afterwards.
+// Zero removes the cap.
+func (e *Engine) SetMaxTotalBytes(limit int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.MaxTotalBytes = limit
+}
+
+// SetMaxAPICalls caps the Drive API requests made by syncs started
+// afterwards. Zero removes the cap.
+func (e *Engine) SetMaxAPICalls(limit int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.MaxAPICalls = limit
+}
+
+// SetMaxFiles caps the files scheduled by syncs started afterwards. Zero
+// removes the cap.
+func (e *Engine) SetMaxFiles(limit int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.MaxFiles = limit
+}
+
+// SetMirror configures the removal of local entries missing from Drive for
+// syncs started afterwards. Nil disables it.
+func (e *Engine) SetMirror(config *MirrorConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.Mirror = config
+}
+
+// SetSkipPermissionErrors sets whether files Drive refuses access to count
+// toward the maximum errors of syncs started afterwards.
+func (e *Engine) SetSkipPermissionErrors(skip bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.SkipPermissionErrors = skip
+}
+
+// SetStarredOnly sets whether syncs started afterwards download only
+// starred files.
+func (e *Engine) SetStarredOnly(starredOnly bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.WalkerConfig.StarredOnly = starredOnly
+}
+
+// SetGoogleDocsFilter sets whether syncs started afterwards download only
+// Google Workspace files or skip them; both cannot be set at once.
+func (e *Engine) SetGoogleDocsFilter(onlyGoogleDocs, skipGoogleDocs bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.WalkerConfig.OnlyGoogleDocs = onlyGoogleDocs
+	e.config.WalkerConfig.SkipGoogleDocs = skipGoogleDocs
+}
+
+// SetQuietProgress sets whether syncs started afterwards log their progress
+// periodically.
+func (e *Engine) SetQuietProgress(quiet bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.QuietProgress = quiet
+}
+
+// SetEventStream sets the writer that syncs started afterwards write their
+// progress events to as JSON lines; nil disables the stream.
+func (e *Engine) SetEventStream(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.config.EventStream = w
+}
+
 // WaitForCompletion waits until the sync engine completes.
 func (e *Engine) WaitForCompletion() <-chan struct{} {
 	return e.doneChan
@@ -322,20 +768,57 @@ func (e *Engine) startSync(ctx context.Context) error {
 	// Create cancellable context
 	e.ctx, e.cancel = context.WithCancel(ctx)
 
+	// Resumed sessions keep the settings they were started with
+	if e.isResuming() || e.isRetrying() {
+		if err := e.loadSessionConfig(e.ctx); err != nil {
+			return errors.Wrap(err, "failed to load session settings")
+		}
+	} else if err := e.saveSessionConfig(e.ctx); err != nil {
+		return errors.Wrap(err, "failed to save session settings")
+	}
+
+	e.checkpointed = state.SessionProgressDelta{
+		CompletedFiles: e.currentSession.CompletedFiles,
+		FailedFiles:    e.currentSession.FailedFiles,
+		SkippedFiles:   e.currentSession.SkippedFiles,
+		CompletedBytes: e.currentSession.CompletedBytes,
+	}
+
 	// Create progress tracker
 	e.progressTracker = NewProgressTracker(e.sessionID)
+	if e.config.BandwidthLimit > 0 {
+		e.progressTracker.SetBandwidthLimit(e.config.BandwidthLimit)
+	}
 
 	// Register progress event handler
+	skipPermissionErrors := e.config.SkipPermissionErrors
+	quietProgress := e.config.QuietProgress
+	consecutiveFailures := 0
 	e.progressTracker.OnEvent(func(event *ProgressEvent) {
 		// Log significant events
 		switch event.Type {
+		case ProgressEventFileCompleted:
+			consecutiveFailures = 0
+			e.checkQuota()
+			e.checkAPICallLimit()
 		case ProgressEventFileFailed:
 			e.logger.Error(event.Error, "File download failed",
 				"file", event.ItemName,
 				"path", event.ItemPath,
+				"trace_id", event.Context["trace_id"],
+			)
+			if !skipPermissionErrors || !api.IsPermissionDenied(event.Error) {
+				consecutiveFailures++
+				e.reportFileFailure(event.ItemID, event.Error, consecutiveFailures)
+			}
+		case ProgressEventFileSkipped:
+			e.logger.Debug("File skipped",
+				"path", event.ItemPath,
+				"reason", event.Context["reason"],
 			)
 		case ProgressEventSessionUpdate:
-			if event.FilesCompleted%100 == 0 {
+			e.requestCompletionCheck()
+			if !quietProgress && event.FilesCompleted%100 == 0 {
 				e.logger.Info("Sync progress",
 					"completed", event.FilesCompleted,
 					"total", event.TotalFiles,
@@ -345,13 +828,34 @@ func (e *Engine) startSync(ctx context.Context) error {
 		}
 	})
 
+	e.events = nil
+	if e.config.PersistEvents {
+		e.events = newEventLog(e.stateManager, e.logger)
+		e.progressTracker.OnEvent(e.events.record)
+	}
+
+	e.stream = nil
+	if e.config.EventStream != nil {
+		e.stream = newEventStream(e.config.EventStream, e.logger)
+		e.progressTracker.OnEvent(e.stream.record)
+	}
+
+	// Listings are saved in transactions of BatchSize files too
+	walkerConfig := DefaultWalkerConfig()
+	if e.config.WalkerConfig != nil {
+		*walkerConfig = *e.config.WalkerConfig
+	}
+	if walkerConfig.InsertBatchSize <= 0 {
+		walkerConfig.InsertBatchSize = e.batchSize()
+	}
+
 	// Create folder walker
 	walker, err := NewFolderWalker(
 		e.client,
 		e.stateManager,
 		e.progressTracker,
 		e.logger,
-		e.config.WalkerConfig,
+		walkerConfig,
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to create folder walker")
@@ -371,6 +875,9 @@ func (e *Engine) startSync(ctx context.Context) error {
 		return errors.Wrap(err, "failed to create download manager")
 	}
 	e.downloader = downloader
+	if e.downloadFunc != nil {
+		downloader.workerPool.download = e.downloadFunc
+	}
 
 	// Start download manager
 	if err := e.downloader.Start(e.ctx); err != nil {
@@ -380,6 +887,16 @@ func (e *Engine) startSync(ctx context.Context) error {
 	// Mark as running
 	e.isRunning = true
 	e.walkingComplete = false
+	e.scheduleBacklog.Store(0)
+	e.quotaReached = false
+	e.quotaDrained = false
+	e.apiLimitReached = false
+	e.authLost = false
+	e.stalled = false
+	e.lastErr = nil
+	if e.client != nil {
+		e.apiCallBase = e.client.GetAPICallCount()
+	}
 
 	// Update session status
 	e.currentSession.Status = state.SessionStatusActive
@@ -395,6 +912,13 @@ func (e *Engine) startSync(ctx context.Context) error {
 	e.wg.Add(1)
 	go e.runCheckpointSaver()
 
+	// Tell other processes the session is being synced
+	if err := e.stateManager.Sessions().Heartbeat(e.ctx, e.sessionID); err != nil {
+		e.logger.Warn("Failed to record session heartbeat", "error", err)
+	}
+	e.wg.Add(1)
+	go e.runHeartbeat()
+
 	// Start error monitor
 	e.wg.Add(1)
 	go e.runErrorMonitor()
@@ -403,10 +927,20 @@ func (e *Engine) startSync(ctx context.Context) error {
 	e.wg.Add(1)
 	go e.runCompletionChecker()
 
+	// Start stall watchdog
+	if e.config.NoProgressTimeout > 0 {
+		e.wg.Add(1)
+		go e.runWatchdog()
+	}
+
 	e.logger.Info("Sync engine started",
 		"session_id", e.sessionID,
+		"scan_only", e.scanOnly,
 		"root_folder", e.currentSession.RootFolderID,
 		"destination", e.currentSession.DestinationPath,
+		"flatten", e.currentSession.Flatten,
+		"max_total_bytes", e.config.MaxTotalBytes,
+		"max_api_calls", e.config.MaxAPICalls,
 	)
 
 	return nil
@@ -417,19 +951,23 @@ func (e *Engine) runSync() {
 	defer e.wg.Done()
 	defer e.cleanup()
 
-	// Check if resuming
-	if e.isResuming() {
+	// Check if retrying failed files or resuming
+	if e.isRetrying() {
+		e.logger.Info("Retrying failed files", "count", len(e.retryFiles))
+
+		if err := e.scheduleRetryFiles(); err != nil {
+			e.logger.Error(err, "Failed to schedule retry downloads")
+			e.handleFatalError(err)
+			return
+		}
+	} else if e.isResuming() {
 		e.logger.Info("Resuming sync session",
 			"completed_files", e.currentSession.CompletedFiles,
 			"total_files", e.currentSession.TotalFiles,
 		)
 
-		// When resuming, walking is already complete
-		e.walkingComplete = true
-
-		// Schedule pending downloads
-		if err := e.schedulePendingDownloads(); err != nil {
-			e.logger.Error(err, "Failed to schedule pending downloads")
+		if err := e.resumeSync(); err != nil {
+			e.logger.Error(err, "Failed to resume sync")
 			e.handleFatalError(err)
 			return
 		}
@@ -437,7 +975,7 @@ func (e *Engine) runSync() {
 		// Start folder walking
 		e.logger.Info("Starting folder scan")
 		e.logger.Debug("About to call startFolderWalk", "rootFolderID", e.currentSession.RootFolderID)
-		if err := e.startFolderWalk(); err != nil {
+		if err := e.startFolderWalk(nil); err != nil {
 			e.logger.Error(err, "Failed to start folder walk")
 			e.handleFatalError(err)
 			return
@@ -448,25 +986,78 @@ func (e *Engine) runSync() {
 	// Wait for completion or cancellation
 	<-e.ctx.Done()
 
-	// Determine final status
-	if e.ctx.Err() == context.Canceled {
-		e.updateFinalStatus(state.SessionStatusCancelled)
-	} else {
-		stats := e.progressTracker.GetStats()
-		if stats.FailedFiles > 0 {
+	// Completion also cancels the context, so check how we got here
+	e.mu.RLock()
+	completed := e.completed
+	authLost := e.authLost
+	stalled := e.stalled
+	e.mu.RUnlock()
+
+	// Determine final status; a session that lost its authorization is
+	// resumed after authenticating again
+	if !completed {
+		if authLost {
+			e.updateFinalStatus(state.SessionStatusFailed)
+		} else if stalled {
+			e.updateFinalStatus(state.SessionStatusStalled)
+		} else {
+			e.updateFinalStatus(state.SessionStatusCancelled)
+		}
+		return
+	}
+
+	if e.scanOnly {
+		// A scan with unlisted folders is resumed like an interrupted sync
+		if e.hasFailedFolders() {
 			e.updateFinalStatus(state.SessionStatusFailed)
 		} else {
-			e.updateFinalStatus(state.SessionStatusCompleted)
+			e.updateFinalStatus(state.SessionStatusScanned)
 		}
+		return
 	}
+
+	// The walk is complete here, so the session knows every remote entry
+	e.reconcileMirror(context.Background())
+
+	stats := e.progressTracker.GetStats()
+	switch {
+	case e.stoppedByQuota(stats):
+		e.finishQuotaStop(stats)
+	case e.hasFailedFolders():
+		e.updateFinalStatus(state.SessionStatusFailed)
+	case stats.FailedFiles > 0 && e.config.ContinueOnErrors:
+		e.updateFinalStatus(state.SessionStatusCompletedWithErrors)
+	case stats.FailedFiles > 0:
+		e.updateFinalStatus(state.SessionStatusFailed)
+	default:
+		e.updateFinalStatus(state.SessionStatusCompleted)
+	}
+}
+
+// hasFailedFolders reports whether a folder listing of the session failed.
+// Such folders are listed again when the session is resumed.
+func (e *Engine) hasFailedFolders() bool {
+	counts, err := e.stateManager.Folders().CountByStatus(context.Background(), e.sessionID)
+	if err != nil {
+		e.logger.Error(err, "Failed to count failed folders")
+		return false
+	}
+	return counts[state.FolderStatusFailed] > 0
 }
 
-// startFolderWalk starts the folder walking process.
-func (e *Engine) startFolderWalk() error {
+// startFolderWalk starts the folder walking process. It walks from the root
+// folder, or from unscanned folders when continuing an interrupted walk.
+func (e *Engine) startFolderWalk(unscanned []*state.Folder) error {
 	e.logger.Debug("startFolderWalk called", "rootFolderID", e.currentSession.RootFolderID, "sessionID", e.sessionID)
 
-	// Start walking from root folder
-	resultChan, err := e.walker.Walk(e.ctx, e.currentSession.RootFolderID, e.sessionID)
+	var resultChan <-chan *WalkResult
+	var err error
+	if len(unscanned) > 0 {
+		resultChan, err = e.walker.WalkFolders(e.ctx, e.sessionID, unscanned)
+	} else {
+		resultChan, err = e.walker.WalkNamed(e.ctx, e.currentSession.RootFolderID,
+			e.currentSession.RootFolderName.String, e.sessionID)
+	}
 	if err != nil {
 		e.logger.Error(err, "Failed to start walker")
 		return err
@@ -477,16 +1068,35 @@ func (e *Engine) startFolderWalk() error {
 	go func() {
 		totalFiles := int64(0)
 		totalBytes := int64(0)
-		batchSize := 100
+		reportedFiles := int64(0)
+		reportedBytes := int64(0)
+		batchSize := e.batchSize()
 		fileBatch := make([]*state.File, 0, batchSize)
 
+		// Scheduling runs apart from this loop so wide folders keep being
+		// listed while downloads lag behind
+		batches, scheduled := e.startScheduler()
+		closeBatches := sync.OnceFunc(func() { close(batches) })
+		defer closeBatches()
+
+		// Walked files stay pending in the database until the walk ends
+		deferDownloads := e.config.ScanThenDownload
+
+		// A scan lists everything; otherwise the walk ends after MaxFiles
+		maxFiles := e.config.MaxFiles
+		walkedFiles := 0
+		limited := false
+		limitReached := func(walked int) bool {
+			return maxFiles > 0 && !e.scanOnly && walked >= maxFiles
+		}
+
 		for result := range resultChan {
 			if e.ctx.Err() != nil {
 				return
 			}
 
 			// Check if paused
-			for e.isPaused {
+			for e.paused() {
 				select {
 				case <-e.ctx.Done():
 					return
@@ -497,7 +1107,7 @@ func (e *Engine) startFolderWalk() error {
 
 			// Handle errors
 			if result.Error != nil {
-				e.errorChan <- result.Error
+				e.reportError(result.Error)
 				continue
 			}
 
@@ -509,9 +1119,22 @@ func (e *Engine) startFolderWalk() error {
 					"total_files_so_far", totalFiles,
 				)
 
-				totalFiles += int64(len(result.Files))
+				// Skipped files count towards the totals but are already
+				// accounted for by the walker and are never scheduled
 				for _, file := range result.Files {
+					if file.Status != state.FileStatusSkipped && limitReached(walkedFiles) {
+						break
+					}
+					totalFiles++
+					if file.Status == state.FileStatusSkipped {
+						continue
+					}
+
+					walkedFiles++
 					totalBytes += file.Size
+					if e.scanOnly || deferDownloads {
+						continue
+					}
 					fileBatch = append(fileBatch, file)
 
 					// Schedule batch when full
@@ -520,7 +1143,9 @@ func (e *Engine) startFolderWalk() error {
 							"batch_size", len(fileBatch),
 							"total_scheduled", totalFiles,
 						)
-						e.downloader.ScheduleBatch(fileBatch)
+						if !e.enqueueBatch(batches, fileBatch) {
+							return
+						}
 						fileBatch = make([]*state.File, 0, batchSize)
 					}
 				}
@@ -528,38 +1153,152 @@ func (e *Engine) startFolderWalk() error {
 
 			// Update totals immediately when we have files
 			if totalFiles > 0 && (totalFiles <= 100 || totalFiles%1000 == 0) {
-				e.progressTracker.SetTotals(totalFiles, totalBytes)
-				e.updateSessionTotals(totalFiles, totalBytes)
+				e.addTotals(totalFiles-reportedFiles, totalBytes-reportedBytes)
+				reportedFiles, reportedBytes = totalFiles, totalBytes
+			}
+
+			if limitReached(walkedFiles) {
+				e.logger.Info("File limit reached, stopping folder scan", "limit", maxFiles)
+				e.walker.Stop()
+				limited = true
+				break
+			}
+
+			if e.checkAPICallLimit() {
+				e.logger.Info("API call limit reached, stopping folder scan", "limit", e.config.MaxAPICalls)
+				e.walker.Stop()
+				break
+			}
+		}
+
+		// Listings still in flight store their files before the walk ends
+		if limited {
+			for range resultChan {
 			}
 		}
 
 		// Schedule remaining files
-		if len(fileBatch) > 0 {
-			e.downloader.ScheduleBatch(fileBatch)
+		if len(fileBatch) > 0 && !e.enqueueBatch(batches, fileBatch) {
+			return
 		}
 
 		// Final update
-		e.progressTracker.SetTotals(totalFiles, totalBytes)
-		e.updateSessionTotals(totalFiles, totalBytes)
+		e.addTotals(totalFiles-reportedFiles, totalBytes-reportedBytes)
 
 		e.logger.Info("Folder scan completed",
 			"folders", e.walker.GetStats().FoldersScanned,
 			"files", totalFiles,
+			"skipped", e.walker.GetStats().FilesSkipped,
 			"size", formatBytes(totalBytes),
 		)
 
-		// Signal that walking is complete
-		e.walkingComplete = true
-		e.checkIfSyncComplete()
+		// Folders shared with the user are not in My Drive unless added to it
+		if totalFiles == 0 && len(unscanned) == 0 && e.walker.GetStats().FoldersScanned <= 1 &&
+			e.currentSession.RootFolderID == api.RootFolderID {
+			e.logger.Warn("My Drive is empty; to sync folders shared with you, " +
+				"use the sharedWithMe root (cloudpull sync --shared-with-me) or the folder's ID")
+		}
+
+		if deferDownloads && !e.scanOnly && !e.enqueuePendingFiles(batches, batchSize) {
+			return
+		}
+
+		// Signal that walking is complete once every file was scheduled
+		closeBatches()
+		<-scheduled
+		if maxFiles > 0 && !e.scanOnly {
+			e.skipPastFileLimit(limited)
+		}
+		e.setWalkingComplete()
 	}()
 
 	return nil
 }
 
-// schedulePendingDownloads schedules pending downloads when resuming.
+// skipPastFileLimit marks the files still pending once MaxFiles were
+// scheduled as skipped, so resuming the session does not download them.
+// When the walk stopped early, its unlisted folders are skipped as well.
+func (e *Engine) skipPastFileLimit(walkStopped bool) {
+	skipped, err := e.stateManager.Files().SkipPending(e.ctx, e.sessionID, "limit")
+	if err != nil {
+		e.logger.Error(err, "Failed to skip files past the file limit")
+	} else if skipped > 0 {
+		e.logger.Info("Skipped files past the file limit", "count", skipped, "limit", e.config.MaxFiles)
+	}
+
+	if !walkStopped {
+		return
+	}
+	if _, err := e.stateManager.Folders().SkipUnscanned(e.ctx, e.sessionID); err != nil {
+		e.logger.Error(err, "Failed to skip folders past the file limit")
+	}
+}
+
+// resumeSync schedules the downloads left by an interrupted session and
+// continues its folder walk from the folders that were not fully scanned.
+func (e *Engine) resumeSync() error {
+	// The download queue of the previous run is gone
+	reset, err := e.stateManager.ResetInterruptedFiles(e.ctx, e.sessionID)
+	if err != nil {
+		return errors.Wrap(err, "failed to reset interrupted files")
+	}
+	if reset > 0 {
+		e.logger.Info("Reset interrupted downloads to pending", "count", reset)
+	}
+
+	if e.config.RefreshModified {
+		requeued, err := e.requeueModifiedFiles()
+		if err != nil {
+			return errors.Wrap(err, "failed to check completed files for changes")
+		}
+		if requeued > 0 {
+			e.logger.Info("Requeued files modified in Drive since their download", "count", requeued)
+		}
+	}
+
+	// Totals may not have been saved before the interruption
+	if err := e.stateManager.RecalculateSessionProgress(e.ctx, e.sessionID); err != nil {
+		return errors.Wrap(err, "failed to recalculate session progress")
+	}
+	session, err := e.stateManager.GetSession(e.ctx, e.sessionID)
+	if err != nil {
+		return errors.Wrap(err, "failed to load session")
+	}
+	e.mu.Lock()
+	e.currentSession.TotalFiles = session.TotalFiles
+	e.currentSession.TotalBytes = session.TotalBytes
+	e.mu.Unlock()
+
+	if err := e.schedulePendingDownloads(); err != nil {
+		return errors.Wrap(err, "failed to schedule pending downloads")
+	}
+
+	unscanned, err := e.stateManager.GetUnscannedFolders(e.ctx, e.sessionID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get unscanned folders")
+	}
+
+	if len(unscanned) == 0 {
+		// Without any folder the walk never got past the root
+		counts, err := e.stateManager.Folders().CountByStatus(e.ctx, e.sessionID)
+		if err != nil {
+			return errors.Wrap(err, "failed to count folders")
+		}
+		if len(counts) > 0 {
+			e.setWalkingComplete()
+			return nil
+		}
+	}
+
+	e.logger.Info("Resuming folder scan", "folders", len(unscanned))
+	return e.startFolderWalk(unscanned)
+}
+
+// schedulePendingDownloads schedules every pending download when resuming,
+// including all files of a scanned session.
 func (e *Engine) schedulePendingDownloads() error {
 	// Get pending files
-	files, err := e.stateManager.GetPendingFiles(e.ctx, e.sessionID, 1000)
+	files, err := e.stateManager.GetPendingFiles(e.ctx, e.sessionID, 0, string(e.config.DownloadConfig.ScheduleOrder))
 	if err != nil {
 		return errors.Wrap(err, "failed to get pending files")
 	}
@@ -568,10 +1307,34 @@ func (e *Engine) schedulePendingDownloads() error {
 		"count", len(files),
 	)
 
+	// Progress totals cover only what this run downloads
+	var totalBytes int64
+	for _, file := range files {
+		totalBytes += file.Size
+	}
+	e.progressTracker.AddTotals(int64(len(files)), totalBytes)
+
 	// Schedule downloads
 	return e.downloader.ScheduleBatch(files)
 }
 
+// scheduleRetryFiles schedules the files selected by RetryFailed. Progress
+// totals cover only those files so completion is detected once they finish.
+func (e *Engine) scheduleRetryFiles() error {
+	var totalBytes int64
+	for _, file := range e.retryFiles {
+		totalBytes += file.Size
+	}
+	e.progressTracker.SetTotals(int64(len(e.retryFiles)), totalBytes)
+
+	if err := e.downloader.ScheduleBatch(e.retryFiles); err != nil {
+		return err
+	}
+
+	e.setWalkingComplete()
+	return nil
+}
+
 // runCheckpointSaver periodically saves session state.
 func (e *Engine) runCheckpointSaver() {
 	defer e.wg.Done()
@@ -589,44 +1352,209 @@ func (e *Engine) runCheckpointSaver() {
 	}
 }
 
-// saveCheckpoint saves current session state.
+// runHeartbeat records a session heartbeat until the sync stops, and then
+// clears it so the session can be resumed by another process at once.
+func (e *Engine) runHeartbeat() {
+	defer e.wg.Done()
+
+	sessions := e.stateManager.Sessions()
+	sessionID := e.sessionID
+
+	ticker := time.NewTicker(state.SessionHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			if err := sessions.ClearHeartbeat(context.Background(), sessionID); err != nil {
+				e.logger.Warn("Failed to clear session heartbeat", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := sessions.Heartbeat(e.ctx, sessionID); err != nil && e.ctx.Err() == nil {
+				e.logger.Warn("Failed to record session heartbeat", "error", err)
+			}
+		}
+	}
+}
+
+// saveCheckpoint saves current session state. Buffered file statuses are
+// flushed first, and the session counters are written as a single delta
+// when they changed since the last checkpoint.
 func (e *Engine) saveCheckpoint() {
-	stats := e.progressTracker.GetStats()
+	e.checkpointMu.Lock()
+	defer e.checkpointMu.Unlock()
+
+	// Checkpoints are also taken during shutdown, after e.ctx is canceled
+	ctx := context.Background()
+
+	e.mu.RLock()
+	downloader := e.downloader
+	e.mu.RUnlock()
+	if downloader != nil {
+		downloader.flushStatuses()
+	}
+
+	var counters state.SessionProgressDelta
+	if e.isRetrying() || e.isResuming() {
+		// Progress only covers files handled in this run, so recount from records
+		if err := e.stateManager.RecalculateSessionProgress(ctx, e.sessionID); err != nil {
+			e.logger.Error(err, "Failed to save checkpoint")
+			return
+		}
+
+		recounted, err := e.stateManager.GetSession(ctx, e.sessionID)
+		if err != nil {
+			e.logger.Error(err, "Failed to save checkpoint")
+			return
+		}
+
+		counters = state.SessionProgressDelta{
+			CompletedFiles: recounted.CompletedFiles,
+			FailedFiles:    recounted.FailedFiles,
+			SkippedFiles:   recounted.SkippedFiles,
+			CompletedBytes: recounted.CompletedBytes,
+		}
+	} else {
+		stats := e.progressTracker.GetStats()
+		counters = state.SessionProgressDelta{
+			CompletedFiles: stats.CompletedFiles,
+			FailedFiles:    stats.FailedFiles,
+			SkippedFiles:   stats.SkippedFiles,
+			CompletedBytes: stats.CompletedBytes,
+		}
+
+		delta := state.SessionProgressDelta{
+			CompletedFiles: counters.CompletedFiles - e.checkpointed.CompletedFiles,
+			FailedFiles:    counters.FailedFiles - e.checkpointed.FailedFiles,
+			SkippedFiles:   counters.SkippedFiles - e.checkpointed.SkippedFiles,
+			CompletedBytes: counters.CompletedBytes - e.checkpointed.CompletedBytes,
+		}
+		if !delta.IsZero() {
+			if err := e.stateManager.Sessions().UpdateProgress(ctx, e.sessionID, delta); err != nil {
+				e.logger.Error(err, "Failed to save checkpoint")
+				return
+			}
+		}
+	}
+	e.checkpointed = counters
 
 	// Update session
 	e.mu.Lock()
-	e.currentSession.CompletedFiles = stats.CompletedFiles
-	e.currentSession.FailedFiles = stats.FailedFiles
-	e.currentSession.SkippedFiles = stats.SkippedFiles
-	e.currentSession.CompletedBytes = stats.CompletedBytes
-	session := *e.currentSession
+	e.currentSession.CompletedFiles = counters.CompletedFiles
+	e.currentSession.FailedFiles = counters.FailedFiles
+	e.currentSession.SkippedFiles = counters.SkippedFiles
+	e.currentSession.CompletedBytes = counters.CompletedBytes
 	e.mu.Unlock()
+}
 
-	// Save to database
-	if err := e.stateManager.UpdateSession(e.ctx, &session); err != nil {
-		e.logger.Error(err, "Failed to save checkpoint")
+// saveFinalCheckpoint saves the last checkpoint of a run and writes the
+// whole session record, including its end time.
+func (e *Engine) saveFinalCheckpoint() {
+	e.saveCheckpoint()
+
+	e.mu.RLock()
+	session := *e.currentSession
+	e.mu.RUnlock()
+
+	if err := e.stateManager.UpdateSession(context.Background(), &session); err != nil {
+		e.logger.Error(err, "Failed to save session")
 	}
 }
 
-// runErrorMonitor monitors errors and stops if threshold exceeded.
+// syncError is an error counted toward the maximum errors of a sync.
+type syncError struct {
+	err error
+
+	// fileID is the file that failed for good; empty for walk failures
+	fileID string
+
+	// consecutive is the number of files that failed in a row, this one
+	// included
+	consecutive int
+}
+
+// reportError counts err, a failure of the folder walk, toward the maximum
+// errors of the sync.
+func (e *Engine) reportError(err error) {
+	e.sendError(&syncError{err: err})
+}
+
+// reportFileFailure counts a file that failed for good toward the maximum
+// errors of the sync; consecutive is the number of files that failed in a
+// row.
+func (e *Engine) reportFileFailure(fileID string, err error, consecutive int) {
+	e.sendError(&syncError{err: err, fileID: fileID, consecutive: consecutive})
+}
+
+func (e *Engine) sendError(report *syncError) {
+	select {
+	case e.errorChan <- report:
+	case <-e.ctx.Done():
+	}
+}
+
+// runErrorMonitor monitors errors and stops if threshold exceeded. Walk
+// failures count each time, failed files once each.
 func (e *Engine) runErrorMonitor() {
 	defer e.wg.Done()
 
-	errorCount := 0
+	walkErrors := 0
+	failedFiles := make(map[string]bool)
+	thresholdReported := false
 
 	for {
 		select {
 		case <-e.ctx.Done():
 			return
-		case err := <-e.errorChan:
-			errorCount++
-			e.logger.Error(err, "Sync error",
+		case report := <-e.errorChan:
+			e.mu.Lock()
+			e.lastErr = report.err
+			e.mu.Unlock()
+
+			// Every later request would be rejected as well
+			if api.IsReauthRequired(report.err) {
+				e.logger.Error(report.err, "Drive authorization lost, stopping sync")
+				e.mu.Lock()
+				e.authLost = true
+				e.mu.Unlock()
+				e.cancel()
+				return
+			}
+
+			if report.fileID == "" {
+				walkErrors++
+			} else {
+				failedFiles[report.fileID] = true
+			}
+
+			errorCount := walkErrors + len(failedFiles)
+			e.logger.Error(report.err, "Sync error",
 				"count", errorCount,
 				"max", e.config.MaxErrors,
+				"walk_errors", walkErrors,
+				"failed_files", len(failedFiles),
 			)
 
 			if errorCount >= e.config.MaxErrors {
-				e.logger.Error(nil, "Maximum errors exceeded, stopping sync")
+				if !e.config.ContinueOnErrors {
+					e.logger.Error(nil, "Maximum errors exceeded, stopping sync")
+					e.cancel()
+					return
+				}
+				if !thresholdReported {
+					e.logger.Warn("Maximum errors exceeded, continuing as continue_on_errors is set",
+						"max", e.config.MaxErrors,
+					)
+					thresholdReported = true
+				}
+			}
+
+			if e.config.MaxConsecutiveErrors > 0 && report.consecutive >= e.config.MaxConsecutiveErrors {
+				e.logger.Error(nil, "Too many files failed in a row, stopping sync",
+					"consecutive", report.consecutive,
+					"max", e.config.MaxConsecutiveErrors,
+				)
 				e.cancel()
 				return
 			}
@@ -647,6 +1575,8 @@ func (e *Engine) runCompletionChecker() {
 			return
 		case <-ticker.C:
 			e.checkIfSyncComplete()
+		case <-e.completionCheck:
+			e.checkIfSyncComplete()
 		}
 	}
 }
@@ -654,22 +1584,46 @@ func (e *Engine) runCompletionChecker() {
 // cleanup performs cleanup after sync stops.
 func (e *Engine) cleanup() {
 	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	e.isRunning = false
 	e.isPaused = false
+	walker := e.walker
+	downloader := e.downloader
+	tracker := e.progressTracker
+	events := e.events
+	stream := e.stream
+	e.mu.Unlock()
 
 	// Stop components
-	if e.walker != nil {
-		e.walker.Stop()
+	if walker != nil {
+		walker.Stop()
 	}
 
-	if e.downloader != nil {
-		e.downloader.Stop()
+	if downloader != nil {
+		downloader.Stop()
 	}
 
-	// Save final checkpoint
-	e.saveCheckpoint()
+	// Stop delivering events of the finished session
+	if tracker != nil {
+		if events != nil || stream != nil {
+			tracker.drain(eventLogDrainTimeout)
+		}
+		tracker.Close()
+	}
+	if events != nil {
+		events.flush()
+	}
+
+	// Save final checkpoint (takes e.mu itself)
+	e.saveFinalCheckpoint()
+
+	if stream != nil {
+		e.mu.RLock()
+		if e.currentSession != nil {
+			stream.finish(e.currentSession)
+		}
+		e.mu.RUnlock()
+		stream.close()
+	}
 
 	// Close done channel to signal completion
 	close(e.doneChan)
@@ -677,18 +1631,36 @@ func (e *Engine) cleanup() {
 
 // Helper methods
 
+// DatedDestination returns where a session started at t downloads when its
+// destination is destinationPath: the dated subdirectory of the Go layout
+// below it, or destinationPath itself when layout is "".
+func DatedDestination(destinationPath, layout string, t time.Time) (string, error) {
+	if layout == "" {
+		return destinationPath, nil
+	}
+
+	subdir, err := util.DateSubdir(layout, t)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(destinationPath, subdir), nil
+}
+
 // createSession creates a new sync session.
 func (e *Engine) createSession(ctx context.Context, rootFolderID, destinationPath string) (*state.Session, error) {
-	// Get root folder name
-	var rootFolderName string
-	if rootFolderID == "root" {
-		rootFolderName = "My Drive"
-	} else {
-		info, err := e.client.GetFile(ctx, rootFolderID)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get root folder info")
-		}
-		rootFolderName = info.Name
+	rootFolderID, rootFolderName, err := e.resolveRoot(ctx, rootFolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The dated directory is stored as the destination, so resuming keeps it
+	destinationPath, err = DatedDestination(destinationPath, e.config.DestDateSubdir, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.prepareDestination(destinationPath); err != nil {
+		return nil, err
 	}
 
 	// Create session via state manager
@@ -697,19 +1669,97 @@ func (e *Engine) createSession(ctx context.Context, rootFolderID, destinationPat
 		return nil, errors.Wrap(err, "failed to create session")
 	}
 
+	// The layout is stored with the session so resumed runs keep it
+	if e.config.Flatten {
+		session.Flatten = true
+		if err := e.stateManager.UpdateSession(ctx, session); err != nil {
+			return nil, errors.Wrap(err, "failed to record session layout")
+		}
+	}
+
 	return session, nil
 }
 
-// isResuming checks if this is a resume operation.
+// resolveRoot returns the ID and name of the folder a new session syncs.
+// rootFolderID may also be a pasted Drive URL. A root that is a file, or
+// that the account cannot read, is reported before anything is created,
+// rather than as a listing failure once the walk starts.
+func (e *Engine) resolveRoot(ctx context.Context, rootFolderID string) (string, string, error) {
+	id := rootFolderID
+	if strings.Contains(id, "drive.google.com") {
+		parsed, err := api.ParseDriveID(id)
+		if err != nil {
+			return "", "", err
+		}
+		id = parsed
+	}
+	switch id {
+	case api.RootFolderID:
+		return id, "My Drive", nil
+	case api.SharedWithMeFolderID:
+		return id, "Shared with me", nil
+	}
+
+	info, err := e.client.GetFile(ctx, id)
+	switch {
+	case api.IsPermissionDenied(err), api.IsNotFound(err):
+		// Drive answers 404 for folders that exist but are not shared
+		return "", "", errors.Errorf("root folder %s is not accessible: check that it exists and is shared with this account", id)
+	case err != nil:
+		return "", "", errors.Wrap(err, "failed to get root folder info")
+	case !info.IsFolder:
+		// Shared Drive roots report the folder MIME type too
+		return "", "", errors.Errorf("root %s is a file (%s), not a folder", id, info.Name)
+	case !info.OwnedByMe && len(info.Parents) == 0:
+		// The walk lists it by ID; only its parent is out of reach
+		e.logger.Info("Root folder is shared with this account and not in My Drive",
+			"folder_id", id,
+			"name", info.Name,
+		)
+	}
+	return id, info.Name, nil
+}
+
+// prepareDestination creates the destination directory if it does not
+// exist, so a destination that cannot hold the synced files is reported
+// before any folder is listed.
+func (e *Engine) prepareDestination(destinationPath string) error {
+	info, err := os.Stat(destinationPath)
+	if err == nil {
+		if !info.IsDir() {
+			return errors.Errorf("destination %s is a file, not a directory", destinationPath)
+		}
+		return nil
+	}
+	dirMode := util.DefaultDirMode
+	if e.config.DownloadConfig != nil && e.config.DownloadConfig.DirMode != 0 {
+		dirMode = e.config.DownloadConfig.DirMode
+	}
+	if err := os.MkdirAll(destinationPath, dirMode); err != nil {
+		return errors.Wrapf(err, "destination %s cannot be created as a directory", destinationPath)
+	}
+	return nil
+}
+
+// isRetrying checks if this run only retries previously failed files.
+func (e *Engine) isRetrying() bool {
+	return len(e.retryFiles) > 0
+}
+
+// isResuming checks if this run continues a session started earlier.
 func (e *Engine) isResuming() bool {
-	return e.currentSession.CompletedFiles > 0 || e.currentSession.TotalFiles > 0
+	return e.resumed
 }
 
-// updateSessionTotals updates session total counts.
-func (e *Engine) updateSessionTotals(totalFiles, totalBytes int64) {
+// addTotals adds newly walked files to the progress and session totals.
+func (e *Engine) addTotals(files, bytes int64) {
+	e.progressTracker.AddTotals(files, bytes)
+
 	e.mu.Lock()
-	e.currentSession.TotalFiles = totalFiles
-	e.currentSession.TotalBytes = totalBytes
+	e.currentSession.TotalFiles += files
+	e.currentSession.TotalBytes += bytes
+	totalFiles := e.currentSession.TotalFiles
+	totalBytes := e.currentSession.TotalBytes
 	e.mu.Unlock()
 
 	if err := e.stateManager.UpdateSessionTotals(e.ctx, e.sessionID, totalFiles, totalBytes); err != nil {
@@ -724,9 +1774,42 @@ func (e *Engine) updateFinalStatus(status string) {
 	e.currentSession.EndTime = state.NewNullTime(time.Now())
 	e.mu.Unlock()
 
-	if err := e.stateManager.UpdateSessionStatus(e.ctx, e.sessionID, status); err != nil {
+	// e.ctx is already canceled when the sync finishes
+	if err := e.stateManager.UpdateSessionStatus(context.Background(), e.sessionID, status); err != nil {
 		e.logger.Error(err, "Failed to update final session status")
 	}
+
+	e.writeCompletionReport(status)
+	e.fireCompletionHooks(status)
+}
+
+// fireCompletionHooks runs the configured completion hooks once per session.
+func (e *Engine) fireCompletionHooks(status string) {
+	e.mu.Lock()
+	if e.hooksFired || !e.hooks.Enabled() {
+		e.mu.Unlock()
+		return
+	}
+	e.hooksFired = true
+	e.mu.Unlock()
+
+	// The sync context is usually canceled by now, so hooks get their own
+	ctx := context.Background()
+
+	payload := &CompletionPayload{
+		SessionID:   e.sessionID,
+		Status:      status,
+		FailedFiles: e.progressTracker.GetStats().FailedFiles,
+	}
+
+	stats, err := e.stateManager.GetSessionStats(ctx, e.sessionID)
+	if err != nil {
+		e.logger.Error(err, "Failed to collect session stats for completion hooks")
+	} else {
+		payload.Stats = stats
+	}
+
+	e.hooks.RunCompletionHooks(ctx, payload)
 }
 
 // handleFatalError handles fatal errors.
@@ -744,55 +1827,97 @@ func (e *Engine) getStatus() string {
 	if e.isPaused {
 		return "paused"
 	}
-
-	// Check if sync is complete
-	if e.walkingComplete {
-		stats := e.progressTracker.GetStats()
-		totalProcessed := stats.CompletedFiles + stats.FailedFiles + stats.SkippedFiles
-		if totalProcessed >= stats.TotalFiles && stats.TotalFiles > 0 {
-			if e.downloader != nil {
-				downloaderStats := e.downloader.GetStats()
-				if downloaderStats.ActiveDownloads == 0 && downloaderStats.WorkerPoolStats.QueuedTasks == 0 {
-					return "completed"
-				}
-			}
-		}
+	if e.completed || e.syncFinished() {
+		return "completed"
 	}
 
 	return "running"
 }
 
-// checkIfSyncComplete checks if the sync is complete and cancels the context if so.
-func (e *Engine) checkIfSyncComplete() {
+// batchSize returns the number of files scheduled or saved together.
+func (e *Engine) batchSize() int {
+	if e.config.BatchSize <= 0 {
+		return DefaultEngineConfig().BatchSize
+	}
+	return e.config.BatchSize
+}
+
+// paused reports whether the engine is paused.
+func (e *Engine) paused() bool {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
+	return e.isPaused
+}
+
+// setWalkingComplete records that every file has been scheduled and checks
+// whether the sync is already done.
+func (e *Engine) setWalkingComplete() {
+	e.mu.Lock()
+	e.walkingComplete = true
+	e.mu.Unlock()
+
+	e.checkIfSyncComplete()
+}
+
+// requestCompletionCheck asks the completion checker to run without waiting
+// for its next tick.
+func (e *Engine) requestCompletionCheck() {
+	select {
+	case e.completionCheck <- struct{}{}:
+	default:
+	}
+}
+
+// syncFinished reports whether all files are accounted for and the worker
+// pool has no outstanding tasks, or whether the byte quota stopped the sync.
+// The caller must hold e.mu.
+func (e *Engine) syncFinished() bool {
+	if !e.walkingComplete || e.progressTracker == nil || e.downloader == nil {
+		return false
+	}
+
+	// A scan downloads nothing, so it is done once the walk is
+	if e.scanOnly {
+		return true
+	}
+
+	// Queued tasks never start once the quota drained the pool
+	if e.quotaDrained {
+		return true
+	}
 
-	// Check if walking is complete
-	if !e.walkingComplete {
+	// Counts can lag behind the pool but never run ahead of it, so an idle
+	// pool plus full counts means every file really is done
+	if !e.downloader.IsIdle() {
+		return false
+	}
+
+	stats := e.progressTracker.GetStats()
+	return stats.CompletedFiles+stats.FailedFiles+stats.SkippedFiles >= stats.TotalFiles
+}
+
+// checkIfSyncComplete checks if the sync is complete and cancels the context if so.
+func (e *Engine) checkIfSyncComplete() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.completed || !e.syncFinished() {
 		return
 	}
 
-	// Check if all downloads are complete
 	stats := e.progressTracker.GetStats()
-	totalProcessed := stats.CompletedFiles + stats.FailedFiles + stats.SkippedFiles
-
-	if totalProcessed >= stats.TotalFiles && stats.TotalFiles > 0 {
-		// Check worker pool status
-		if e.downloader != nil {
-			downloaderStats := e.downloader.GetStats()
-			if downloaderStats.ActiveDownloads == 0 && downloaderStats.WorkerPoolStats.QueuedTasks == 0 {
-				e.logger.Info("All downloads complete, stopping sync engine",
-					"total_files", stats.TotalFiles,
-					"completed", stats.CompletedFiles,
-					"failed", stats.FailedFiles,
-					"skipped", stats.SkippedFiles,
-				)
-				// Cancel context to trigger shutdown
-				if e.cancel != nil {
-					e.cancel()
-				}
-			}
-		}
+	e.logger.Info("All downloads complete, stopping sync engine",
+		"total_files", stats.TotalFiles,
+		"completed", stats.CompletedFiles,
+		"failed", stats.FailedFiles,
+		"skipped", stats.SkippedFiles,
+		"api_calls", e.apiCallsLocked(),
+	)
+
+	// Cancel context to trigger shutdown
+	e.completed = true
+	if e.cancel != nil {
+		e.cancel()
 	}
 }
 
@@ -814,6 +1939,20 @@ type SyncProgress struct {
 	FoldersScanned  int64
 	ActiveDownloads int64
 	QueuedDownloads int
+
+	// ScheduleBacklog is the number of walked files waiting for room in
+	// the download queue
+	ScheduleBacklog int64
+
+	// DownloadSpeed is the combined speed of downloads in progress, as
+	// opposed to CurrentSpeed which averages recent progress samples
+	DownloadSpeed int64
+
+	// ActiveConnections is the number of download responses being read
+	ActiveConnections int64
+
+	// APICalls is the number of Drive API requests made by this run
+	APICalls int64
 }
 
 // formatBytes formats bytes to human-readable string.
@@ -0,0 +1,1230 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/api/apitest"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// newTestStateManager creates a state manager backed by a temporary database.
+func newTestStateManager(t testing.TB) *state.Manager {
+	t.Helper()
+
+	cfg := state.DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "cloudpull.db")
+
+	manager, err := state.NewManager(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { manager.Close() })
+
+	return manager
+}
+
+// newTestEngine creates an engine whose downloads are handled by download.
+func newTestEngine(t *testing.T, m *state.Manager,
+	download func(ctx context.Context, file *state.File) (int64, error)) *Engine {
+
+	t.Helper()
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.MaxConcurrent = 8
+
+	engine, err := NewEngine(nil, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.downloadFunc = download
+
+	return engine
+}
+
+// newFakeDriveClient serves folder listings from an in-memory Drive. Keys of
+// children are folder IDs; every listed file can also be fetched by ID.
+// Downloads return Size bytes and exports return a short document. The
+// account is tester@example.com. onList, if set, is called before each
+// folder listing is served.
+func newFakeDriveClient(t *testing.T, children map[string][]*drive.File,
+	onList func(r *http.Request, folderID string)) *api.DriveClient {
+
+	t.Helper()
+
+	return newFakeDriveClientWithContent(t, children, nil, onList)
+}
+
+// newFakeDriveClientWithContent is newFakeDriveClient, except that files
+// with an entry in contents download that content instead of Size bytes.
+func newFakeDriveClientWithContent(t *testing.T, children map[string][]*drive.File,
+	contents map[string]string, onList func(r *http.Request, folderID string)) *api.DriveClient {
+
+	t.Helper()
+
+	return newDriveClientForHandler(t, fakeDriveHandler(children, contents, onList))
+}
+
+// fakeDriveHandler serves the Drive API requests of the fake client.
+func fakeDriveHandler(children map[string][]*drive.File, contents map[string]string,
+	onList func(r *http.Request, folderID string)) http.HandlerFunc {
+
+	byID := make(map[string]*drive.File)
+	for _, files := range children {
+		for _, file := range files {
+			byID[file.Id] = file
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/about" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(&drive.About{User: &drive.User{EmailAddress: "tester@example.com"}})
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/files")
+		id = strings.TrimPrefix(id, "/")
+
+		if exportID, ok := strings.CutSuffix(id, "/export"); ok {
+			fmt.Fprintf(w, "exported %s", exportID)
+			return
+		}
+		if content, ok := contents[id]; ok && r.URL.Query().Get("alt") == "media" {
+			http.ServeContent(w, r, id, time.Time{}, strings.NewReader(content))
+			return
+		}
+		if file, ok := byID[id]; ok && r.URL.Query().Get("alt") == "media" {
+			http.ServeContent(w, r, file.Name, time.Time{}, bytes.NewReader(make([]byte, file.Size)))
+			return
+		}
+
+		var body interface{}
+		if id == "" {
+			// Queries look like "'<folder-id>' in parents and trashed = false"
+			parent := strings.SplitN(r.URL.Query().Get("q"), "'", 3)[1]
+			if onList != nil {
+				onList(r, parent)
+			}
+			body = &drive.FileList{Files: children[parent]}
+		} else if file, ok := byID[id]; ok {
+			body = file
+		} else {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	}
+}
+
+// newDriveClientForHandler returns a client sending its requests to handler.
+func newDriveClientForHandler(t testing.TB, handler http.Handler) *api.DriveClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	service, err := drive.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	require.NoError(t, err)
+
+	return api.NewDriveClient(service, api.NewRateLimiter(api.DefaultRateLimiterConfig()), newTestLogger())
+}
+
+// createFailedFiles creates a session holding count failed files.
+func createFailedFiles(t *testing.T, m *state.Manager, count int) *state.Session {
+	t.Helper()
+
+	ctx := context.Background()
+	session, err := m.CreateSession(ctx, "root-id", "Root", t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, m.UpdateSessionStatus(ctx, session.ID, state.SessionStatusFailed))
+
+	folder := &state.Folder{
+		DriveID:   "drive-root",
+		SessionID: session.ID,
+		Name:      "root",
+		Path:      "root",
+		Status:    state.FolderStatusScanned,
+	}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+
+	files := make([]*state.File, count)
+	for i := range files {
+		files[i] = &state.File{
+			DriveID:   fmt.Sprintf("drive-file-%d", i),
+			FolderID:  folder.ID,
+			SessionID: session.ID,
+			Name:      fmt.Sprintf("file-%d.txt", i),
+			Path:      fmt.Sprintf("root/file-%d.txt", i),
+			Size:      int64(i%7 + 1),
+			Status:    state.FileStatusFailed,
+		}
+	}
+	require.NoError(t, m.Files().CreateBatch(ctx, files))
+
+	return session
+}
+
+func TestEngineCompletionStress(t *testing.T) {
+	const fileCount = 500
+
+	for run := 0; run < 3; run++ {
+		t.Run(fmt.Sprintf("run-%d", run), func(t *testing.T) {
+			ctx := context.Background()
+			m := newTestStateManager(t)
+			session := createFailedFiles(t, m, fileCount)
+
+			// One file keeps failing so retries and final failures are exercised
+			engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+				if file.DriveID == "drive-file-0" {
+					return 0, fmt.Errorf("simulated failure")
+				}
+				return file.Size, nil
+			})
+
+			scheduled, err := engine.RetryFailed(ctx, session.ID, 10)
+			require.NoError(t, err)
+			require.Equal(t, fileCount, scheduled)
+
+			select {
+			case <-engine.WaitForCompletion():
+			case <-time.After(30 * time.Second):
+				t.Fatal("sync engine did not terminate")
+			}
+
+			stats, _ := engine.GetStats()
+			assert.Equal(t, int64(fileCount), stats.CompletedFiles+stats.FailedFiles)
+			assert.Equal(t, int64(1), stats.FailedFiles)
+
+			final, err := m.GetSession(ctx, session.ID)
+			require.NoError(t, err)
+			assert.Equal(t, state.SessionStatusFailed, final.Status)
+			assert.Equal(t, int64(fileCount-1), final.CompletedFiles)
+			assert.Equal(t, int64(1), final.FailedFiles)
+		})
+	}
+}
+
+func TestEngineCompletesSuccessfulSession(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	session := createFailedFiles(t, m, 20)
+
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	})
+
+	_, err := engine.RetryFailed(ctx, session.ID, 10)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(10 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	final, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+	assert.True(t, final.EndTime.Valid)
+}
+
+func TestEngineRecordsHeartbeatWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	session := createFailedFiles(t, m, 1)
+
+	release := make(chan struct{})
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		<-release
+		return file.Size, nil
+	})
+
+	_, err := engine.RetryFailed(ctx, session.ID, 10)
+	require.NoError(t, err)
+
+	running, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, running.IsRunning())
+
+	close(release)
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(10 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+	engine.Stop()
+
+	final, err := m.GetSession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, final.IsRunning())
+}
+
+func TestEngineRejectsFileDestination(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	var listed atomic.Bool
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	})
+	engine.client = newFakeDriveClient(t, nil, func(r *http.Request, folderID string) {
+		listed.Store(true)
+	})
+
+	dest := filepath.Join(t.TempDir(), "notes.txt")
+	require.NoError(t, os.WriteFile(dest, []byte("notes"), 0600))
+
+	_, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is a file, not a directory")
+
+	_, err = engine.StartNewSessionWithID(ctx, "root", filepath.Join(dest, "sub"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot be created as a directory")
+
+	assert.False(t, listed.Load(), "no folder is listed")
+	sessions, err := m.Sessions().List(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, sessions, "no session is created")
+
+	// A missing destination is created
+	missing := filepath.Join(t.TempDir(), "new", "dest")
+	require.NoError(t, engine.prepareDestination(missing))
+	assert.DirExists(t, missing)
+}
+
+func TestEngineValidatesRootFolder(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	folderID := "folder1234567"
+	fileID := "file123456789"
+	deniedID := "denied1234567"
+	children := map[string][]*drive.File{
+		"parent": {{Id: folderID, Name: "Projects", MimeType: "application/vnd.google-apps.folder"}, {Id: fileID, Name: "notes.txt", MimeType: "text/plain", Size: 4}},
+		folderID: {{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 4}},
+	}
+	handler := fakeDriveHandler(children, nil, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files/"+deniedID {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": {"code": 403, "message": "forbidden", "errors": [{"reason": "insufficientFilePermissions"}]}}`)
+			return
+		}
+		handler(w, r)
+	}))
+
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	})
+	engine.client = client
+
+	_, err := engine.StartNewSessionWithID(ctx, fileID, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is a file (notes.txt), not a folder")
+
+	_, err = engine.StartNewSessionWithID(ctx, deniedID, t.TempDir())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not accessible")
+
+	sessions, err := m.Sessions().List(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, sessions, "no session is created")
+
+	// A pasted folder URL syncs the folder it names
+	sessionID, err := engine.StartNewSessionWithID(ctx,
+		"https://drive.google.com/drive/u/0/folders/"+folderID+"?usp=sharing", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync did not complete")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, folderID, session.RootFolderID)
+	assert.Equal(t, "Projects", session.RootFolderName.String)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+}
+
+func TestEngineDownloadsIntoDatedSubdirectory(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	children := map[string][]*drive.File{
+		"root": {{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10}},
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.SetDestDateSubdir("2006-01-02")
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	dated := filepath.Join(dest, time.Now().Format("2006-01-02"))
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, dated, session.DestinationPath, "the dated destination is stored for resume")
+	assert.FileExists(t, filepath.Join(dated, "root", "a.txt"))
+}
+
+func TestEngineSyncsFromFakeDrive(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	fake := apitest.NewFakeDrive()
+	fake.SetPageSize(1)
+	fake.AddFolder("root", "folder-docs", "docs")
+	fake.AddFile("root", "file-a", "a.txt", "alpha")
+	fake.AddFile("folder-docs", "file-b", "b.txt", "bravo")
+	fake.Add("folder-docs", &api.FileInfo{
+		ID:           "doc-c",
+		Name:         "c",
+		MimeType:     "application/vnd.google-apps.document",
+		CanExport:    true,
+		ExportFormat: "application/pdf",
+	}, []byte("%PDF"))
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int64(3), session.CompletedFiles)
+
+	content, err := os.ReadFile(filepath.Join(dest, "root", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "alpha", string(content))
+	content, err = os.ReadFile(filepath.Join(dest, "root", "docs", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "bravo", string(content))
+	content, err = os.ReadFile(filepath.Join(dest, "root", "docs", "c.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF", string(content))
+
+	// Each page of one item is a separate listing
+	assert.Equal(t, 4, fake.Calls("ListFiles"))
+}
+
+func TestEngineSyncsSharedWithMe(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	// A folder shared with the account, outside My Drive
+	fake := apitest.NewFakeDrive()
+	fake.AddFile("root", "file-mine", "mine.txt", "mine")
+	team := fake.AddFolder(api.SharedWithMeFolderID, "folder-team", "team")
+	fake.AddFolder("folder-team", "folder-plans", "plans")
+	fake.AddFile("folder-team", "file-a", "a.txt", "alpha")
+	fake.AddFile("folder-plans", "file-b", "b.txt", "bravo")
+	fake.AddFile(api.SharedWithMeFolderID, "file-c", "c.txt", "charlie")
+	assert.Empty(t, team.Parents)
+
+	syncRoot := func(root string) (string, *state.Session) {
+		log := newTestLogger()
+		cfg := DefaultEngineConfig()
+		cfg.DownloadConfig.TempDir = t.TempDir()
+		engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+		require.NoError(t, err)
+
+		dest := t.TempDir()
+		sessionID, err := engine.StartNewSessionWithID(ctx, root, dest)
+		require.NoError(t, err)
+
+		select {
+		case <-engine.WaitForCompletion():
+		case <-time.After(30 * time.Second):
+			t.Fatal("sync engine did not terminate")
+		}
+
+		session, err := m.GetSession(ctx, sessionID)
+		require.NoError(t, err)
+		assert.Equal(t, state.SessionStatusCompleted, session.Status)
+		return dest, session
+	}
+
+	dest, session := syncRoot(api.SharedWithMeFolderID)
+	assert.Equal(t, "Shared with me", session.RootFolderName.String)
+	assert.Equal(t, int64(3), session.CompletedFiles)
+	assertContent(t, "alpha", filepath.Join(dest, "Shared with me", "team", "a.txt"))
+	assertContent(t, "bravo", filepath.Join(dest, "Shared with me", "team", "plans", "b.txt"))
+	assertContent(t, "charlie", filepath.Join(dest, "Shared with me", "c.txt"))
+	assert.NoFileExists(t, filepath.Join(dest, "Shared with me", "mine.txt"))
+
+	// A shared folder is also synced by its ID
+	dest, session = syncRoot("folder-team")
+	assert.Equal(t, "team", session.RootFolderName.String)
+	assert.Equal(t, int64(2), session.CompletedFiles)
+	assertContent(t, "bravo", filepath.Join(dest, "team", "plans", "b.txt"))
+}
+
+func TestEngineCompletesWithFilteredFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "folder-docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10},
+			{Id: "file-b", Name: "b.tmp", MimeType: "text/plain", Size: 20},
+			{Id: "file-link", Name: "link", MimeType: "application/vnd.google-apps.shortcut"},
+		},
+		"folder-docs": {
+			{Id: "file-c", Name: "c.txt", MimeType: "text/plain", Size: 30},
+			{Id: "file-d", Name: "d.tmp", MimeType: "text/plain", Size: 40},
+		},
+	}
+
+	var downloaded []string
+	var mu sync.Mutex
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded = append(downloaded, file.Name)
+		mu.Unlock()
+		return file.Size, nil
+	})
+	engine.client = newFakeDriveClient(t, children, nil)
+	engine.config.WalkerConfig.ExcludePatterns = []string{`\.tmp$`}
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	assert.ElementsMatch(t, []string{"a.txt", "c.txt"}, downloaded)
+
+	stats, _ := engine.GetStats()
+	assert.Equal(t, int64(5), stats.TotalFiles)
+	assert.Equal(t, int64(2), stats.CompletedFiles)
+	assert.Equal(t, int64(3), stats.SkippedFiles)
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+	assert.Equal(t, int64(5), final.TotalFiles)
+	assert.Equal(t, int64(3), final.SkippedFiles)
+
+	skipped, err := m.Files().GetByStatus(ctx, sessionID, state.FileStatusSkipped)
+	require.NoError(t, err)
+	assert.Len(t, skipped, 3)
+}
+
+func TestEngineStopsWalkAtFileLimit(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const limit = 4
+	rootFiles := []*drive.File{
+		{Id: "folder-more", Name: "more", MimeType: "application/vnd.google-apps.folder"},
+	}
+	var moreFiles []*drive.File
+	for i := 0; i < 10; i++ {
+		rootFiles = append(rootFiles, &drive.File{
+			Id: fmt.Sprintf("file-%02d", i), Name: fmt.Sprintf("file-%02d.bin", i),
+			MimeType: "application/octet-stream", Size: 10,
+		})
+		moreFiles = append(moreFiles, &drive.File{
+			Id: fmt.Sprintf("more-%02d", i), Name: fmt.Sprintf("more-%02d.bin", i),
+			MimeType: "application/octet-stream", Size: 10,
+		})
+	}
+	children := map[string][]*drive.File{"root": rootFiles, "folder-more": moreFiles}
+
+	var downloads atomic.Int32
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		downloads.Add(1)
+		return file.Size, nil
+	})
+	engine.client = newFakeDriveClient(t, children, nil)
+	engine.SetMaxFiles(limit)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	assert.Equal(t, int32(limit), downloads.Load())
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+	assert.Equal(t, int64(limit), final.TotalFiles)
+	assert.Equal(t, int64(limit), final.CompletedFiles)
+}
+
+func TestEngineResumeKeepsFileLimit(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const limit = 3
+	rootFiles := []*drive.File{
+		{Id: "folder-more", Name: "more", MimeType: "application/vnd.google-apps.folder"},
+	}
+	var moreFiles []*drive.File
+	for i := 0; i < 10; i++ {
+		rootFiles = append(rootFiles, &drive.File{
+			Id: fmt.Sprintf("file-%02d", i), Name: fmt.Sprintf("file-%02d.bin", i),
+			MimeType: "application/octet-stream", Size: 10,
+		})
+		moreFiles = append(moreFiles, &drive.File{
+			Id: fmt.Sprintf("more-%02d", i), Name: fmt.Sprintf("more-%02d.bin", i),
+			MimeType: "application/octet-stream", Size: 10,
+		})
+	}
+	children := map[string][]*drive.File{"root": rootFiles, "folder-more": moreFiles}
+	client := newFakeDriveClient(t, children, nil)
+
+	var downloads atomic.Int32
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		downloads.Add(1)
+		return file.Size, nil
+	}
+
+	first := newTestEngine(t, m, download)
+	first.client = client
+	first.SetMaxFiles(limit)
+	sessionID, err := first.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-first.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("limited run did not terminate")
+	}
+	require.Equal(t, int32(limit), downloads.Load())
+
+	// Files found past the limit are recorded as skipped, not pending
+	counts, err := m.Files().CountByStatus(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Zero(t, counts[state.FileStatusPending])
+	unscanned, err := m.GetUnscannedFolders(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Empty(t, unscanned)
+
+	// Resuming the session, as after a crash, downloads nothing more
+	require.NoError(t, m.UpdateSessionStatus(ctx, sessionID, state.SessionStatusActive))
+	second := newTestEngine(t, m, download)
+	second.client = client
+	require.NoError(t, second.ResumeSession(ctx, sessionID))
+
+	select {
+	case <-second.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+	assert.Equal(t, int32(limit), downloads.Load())
+}
+
+func TestEngineResumeContinuesInterruptedWalk(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	folder := func(id, name string) *drive.File {
+		return &drive.File{Id: id, Name: name, MimeType: "application/vnd.google-apps.folder"}
+	}
+	file := func(id string) *drive.File {
+		return &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 10}
+	}
+	children := map[string][]*drive.File{
+		"root":   {folder("dir-a", "a"), folder("dir-b", "b"), file("top")},
+		"dir-a":  {folder("dir-a1", "a1"), file("a-1"), file("a-2")},
+		"dir-a1": {file("a1-1")},
+		"dir-b":  {file("b-1")},
+	}
+
+	downloaded := make(map[string]int)
+	var mu sync.Mutex
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded[file.DriveID]++
+		mu.Unlock()
+		return file.Size, nil
+	}
+
+	// The first run is stopped while listing dir-a, after the root was scanned
+	first := newTestEngine(t, m, download)
+	first.config.WalkerConfig.Concurrency = 1
+
+	var interrupt sync.Once
+	client := newFakeDriveClient(t, children, func(r *http.Request, folderID string) {
+		if folderID != "dir-a" {
+			return
+		}
+		interrupted := false
+		interrupt.Do(func() {
+			interrupted = true
+			go first.Stop()
+		})
+		if interrupted {
+			<-r.Context().Done()
+		}
+	})
+	first.client = client
+
+	sessionID, err := first.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-first.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("first run did not stop")
+	}
+
+	unscanned, err := m.GetUnscannedFolders(ctx, sessionID)
+	require.NoError(t, err)
+	require.NotEmpty(t, unscanned)
+
+	// A killed process leaves the session active rather than canceled
+	require.NoError(t, m.UpdateSessionStatus(ctx, sessionID, state.SessionStatusActive))
+
+	second := newTestEngine(t, m, download)
+	second.client = client
+	require.NoError(t, second.ResumeSession(ctx, sessionID))
+
+	select {
+	case <-second.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	for _, id := range []string{"top", "a-1", "a-2", "a1-1", "b-1"} {
+		assert.Equal(t, 1, downloaded[id], "downloads of %s", id)
+	}
+
+	unscanned, err = m.GetUnscannedFolders(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Empty(t, unscanned)
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+	assert.Equal(t, int64(5), final.TotalFiles)
+	assert.Equal(t, int64(5), final.CompletedFiles)
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Len(t, files, 5)
+}
+
+func TestEngineResumeRetriesFailedFolderListing(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	folder := func(id, name string) *drive.File {
+		return &drive.File{Id: id, Name: name, MimeType: "application/vnd.google-apps.folder"}
+	}
+	file := func(id string) *drive.File {
+		return &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 10}
+	}
+	children := map[string][]*drive.File{
+		"root":   {folder("dir-a", "a"), folder("dir-b", "b"), file("top")},
+		"dir-a":  {folder("dir-a1", "a1"), file("a-1")},
+		"dir-a1": {file("a1-1")},
+		"dir-b":  {file("b-1")},
+	}
+
+	downloaded := make(map[string]int)
+	var mu sync.Mutex
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded[file.DriveID]++
+		mu.Unlock()
+		return file.Size, nil
+	}
+
+	// Listing dir-a fails until the first run is over
+	var failing atomic.Bool
+	failing.Store(true)
+	client := newFakeDriveClient(t, children, func(r *http.Request, folderID string) {
+		if folderID == "dir-a" && failing.Load() {
+			panic(http.ErrAbortHandler)
+		}
+	})
+	client.SetListMaxRetries(1)
+
+	first := newTestEngine(t, m, download)
+	first.client = client
+	sessionID, err := first.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-first.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("first run did not terminate")
+	}
+
+	// The subtree is not dropped: the folder is kept for the next run
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusFailed, session.Status)
+
+	unscanned, err := m.GetUnscannedFolders(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, unscanned, 1)
+	assert.Equal(t, "dir-a", unscanned[0].DriveID)
+	assert.Equal(t, state.FolderStatusFailed, unscanned[0].Status)
+
+	failing.Store(false)
+	second := newTestEngine(t, m, download)
+	second.client = client
+	require.NoError(t, second.ResumeSession(ctx, sessionID))
+
+	select {
+	case <-second.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	for _, id := range []string{"top", "a-1", "a1-1", "b-1"} {
+		assert.Equal(t, 1, downloaded[id], "downloads of %s", id)
+	}
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+}
+
+func TestEngineResumeAfterCrashRecoversQueuedFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+
+	newFile := func(id string) *state.File {
+		return &state.File{DriveID: id, FolderID: folder.ID, SessionID: session.ID, Name: id,
+			Path: "root/" + id, Size: 10, Status: state.FileStatusPending}
+	}
+	files := []*state.File{newFile("pending"), newFile("queued"), newFile("downloading"),
+		newFile("completed"), newFile("failed")}
+	require.NoError(t, m.Files().CreateBatch(ctx, files))
+
+	// The process died with tasks in the queue and one download running
+	require.NoError(t, m.Files().MarkQueued(ctx, []string{files[1].ID, files[2].ID}))
+	require.NoError(t, m.Files().UpdateProgress(ctx, files[2].ID, 4))
+	files[3].Status = state.FileStatusCompleted
+	require.NoError(t, m.UpdateFileStatus(ctx, files[3]))
+	files[4].Status = state.FileStatusFailed
+	require.NoError(t, m.UpdateFileStatus(ctx, files[4]))
+
+	downloaded := make(map[string]int)
+	var mu sync.Mutex
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		// Every scheduled file is queued before the first download starts
+		pending, err := m.Files().GetByStatus(ctx, session.ID, state.FileStatusPending)
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+
+		mu.Lock()
+		downloaded[file.DriveID]++
+		mu.Unlock()
+		return file.Size, nil
+	}
+
+	engine := newTestEngine(t, m, download)
+	require.NoError(t, engine.ResumeSession(ctx, session.ID))
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	assert.Equal(t, map[string]int{"pending": 1, "queued": 1, "downloading": 1}, downloaded)
+
+	counts, err := m.Files().CountByStatus(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{state.FileStatusCompleted: 4, state.FileStatusFailed: 1}, counts)
+}
+
+func TestEngineResumeRefreshesModifiedFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+
+	listed := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	newFile := func(id string) *state.File {
+		return &state.File{DriveID: id, FolderID: folder.ID, SessionID: session.ID, Name: id,
+			Path: "root/" + id, Size: 10, Status: state.FileStatusPending,
+			DriveModifiedTime: state.NewNullTime(listed)}
+	}
+	files := []*state.File{newFile("changed"), newFile("unchanged")}
+	require.NoError(t, m.Files().CreateBatch(ctx, files))
+	for _, file := range files {
+		file.Status = state.FileStatusCompleted
+		require.NoError(t, m.UpdateFileStatus(ctx, file))
+	}
+
+	// The first file was edited in Drive after the session listed it
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "changed", Name: "changed", Size: 25, Md5Checksum: "md5-new",
+				ModifiedTime: listed.Add(time.Hour).Format(time.RFC3339)},
+			{Id: "unchanged", Name: "unchanged", Size: 10,
+				ModifiedTime: listed.Format(time.RFC3339)},
+		},
+	}
+
+	var downloaded []string
+	var mu sync.Mutex
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded = append(downloaded, file.DriveID)
+		mu.Unlock()
+		return file.Size, nil
+	}
+
+	engine := newTestEngine(t, m, download)
+	engine.client = newFakeDriveClient(t, children, nil)
+	engine.config.RefreshModified = true
+	require.NoError(t, engine.ResumeSession(ctx, session.ID))
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	assert.Equal(t, []string{"changed"}, downloaded)
+
+	refreshed, err := m.Files().GetByDriveID(ctx, "changed", session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusCompleted, refreshed.Status)
+	assert.Equal(t, int64(25), refreshed.Size)
+	assert.Equal(t, "md5-new", refreshed.MD5Checksum.String)
+	assert.True(t, refreshed.DriveModifiedTime.Time.Equal(listed.Add(time.Hour)))
+}
+
+func TestEngineDownloadsScannedSessionWithoutListing(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10, Md5Checksum: "md5-a"},
+			{Id: "doc-plan", Name: "Plan", MimeType: "application/vnd.google-apps.document"},
+			{Id: "folder-sub", Name: "sub", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"folder-sub": {
+			{Id: "file-b", Name: "b.bin", MimeType: "application/octet-stream", Size: 20},
+		},
+	}
+
+	var listings atomic.Int32
+	onList := func(r *http.Request, folderID string) { listings.Add(1) }
+
+	var downloaded []string
+	var mu sync.Mutex
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded = append(downloaded, file.Path)
+		mu.Unlock()
+		return file.Size, nil
+	}
+
+	wait := func(engine *Engine) {
+		select {
+		case <-engine.WaitForCompletion():
+		case <-time.After(30 * time.Second):
+			t.Fatal("sync engine did not terminate")
+		}
+	}
+
+	scanner := newTestEngine(t, m, download)
+	scanner.client = newFakeDriveClient(t, children, onList)
+	sessionID, err := scanner.ScanNewSession(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+	wait(scanner)
+
+	// The scan records every file without downloading any
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusScanned, session.Status)
+	assert.Equal(t, int64(3), session.TotalFiles)
+	assert.Equal(t, int64(30), session.TotalBytes)
+	assert.Empty(t, downloaded)
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	for _, file := range files {
+		assert.Equal(t, state.FileStatusPending, file.Status)
+		switch file.DriveID {
+		case "file-a":
+			assert.Equal(t, int64(10), file.Size)
+			assert.Equal(t, "md5-a", file.MD5Checksum.String)
+		case "doc-plan":
+			assert.True(t, file.IsGoogleDoc)
+			assert.True(t, file.ExportMimeType.Valid)
+		}
+	}
+
+	scanListings := listings.Load()
+
+	// The download runs from the database only, possibly into a new place
+	destination := t.TempDir()
+	downloader := newTestEngine(t, m, download)
+	downloader.client = newFakeDriveClient(t, children, onList)
+	require.NoError(t, downloader.DownloadScannedSession(ctx, sessionID, destination))
+	wait(downloader)
+
+	assert.ElementsMatch(t, []string{"root/a.txt", "root/Plan", "root/sub/b.bin"}, downloaded)
+	assert.Equal(t, scanListings, listings.Load())
+
+	session, err = m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, destination, session.DestinationPath)
+	assert.Equal(t, int64(3), session.CompletedFiles)
+
+	// Only scanned sessions are downloaded this way
+	again := newTestEngine(t, m, download)
+	assert.ErrorContains(t, again.DownloadScannedSession(ctx, sessionID, ""), "not scanned")
+}
+
+func TestEngineSnapshotIsConsistentDuringUpdates(t *testing.T) {
+	m := newTestStateManager(t)
+	engine := newTestEngine(t, m, nil)
+	tracker := NewProgressTracker("session-1")
+	engine.progressTracker = tracker
+	engine.currentSession = &state.Session{ID: "session-1", Status: state.SessionStatusActive}
+	engine.sessionID = "session-1"
+
+	const files = 2000
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < files; i++ {
+			id := fmt.Sprintf("file-%d", i)
+			tracker.AddTotals(1, 100)
+			tracker.FileStarted(id, id, id, 100)
+			tracker.FileProgress(id, 50)
+			tracker.FileProgress(id, 100)
+			tracker.FileCompleted(id)
+		}
+	}()
+
+	reads := 0
+	for finished := false; !finished; reads++ {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+
+		session, progress := engine.Snapshot()
+		require.NotNil(t, session)
+		require.NotNil(t, progress)
+		require.LessOrEqual(t, session.CompletedFiles, session.TotalFiles)
+		require.LessOrEqual(t, session.CompletedBytes, session.TotalBytes)
+		require.Equal(t, progress.CompletedBytes, session.CompletedBytes)
+		require.Equal(t, progress.TotalFiles, session.TotalFiles)
+	}
+	assert.Greater(t, reads, 1)
+
+	session, _ := engine.Snapshot()
+	assert.Equal(t, int64(files), session.CompletedFiles)
+	assert.Equal(t, int64(files*100), session.CompletedBytes)
+
+	// A resumed run keeps the checkpointed counters of the whole session
+	engine.resumed = true
+	engine.currentSession.CompletedFiles = 5000
+	session, progress := engine.Snapshot()
+	assert.Equal(t, int64(5000), session.CompletedFiles)
+	assert.Equal(t, int64(files), progress.CompletedFiles)
+}
+
+func TestEngineFailsWhenAuthorizationIsLost(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{"root": {}}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("f-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 10})
+	}
+
+	var attempts atomic.Int32
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		if file.DriveID == "f-2" {
+			attempts.Add(1)
+			return 0, fmt.Errorf("%w: token revoked", api.ErrReauthRequired)
+		}
+		return file.Size, nil
+	}
+
+	engine := newTestEngine(t, m, download)
+	engine.client = newFakeDriveClient(t, children, nil)
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// Retrying cannot help, and the session is resumed after signing in again
+	assert.Equal(t, int32(1), attempts.Load())
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusFailed, session.Status)
+}
+
+func TestEngineStopsStalledSync(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{"root": {
+		{Id: "done", Name: "done.txt", MimeType: "text/plain", Size: 10},
+		{Id: "stuck", Name: "stuck.txt", MimeType: "text/plain", Size: 10},
+	}}
+
+	// One file completes, the other never makes progress
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		if file.DriveID == "done" {
+			return file.Size, nil
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+
+	engine := newTestEngine(t, m, download)
+	engine.client = newFakeDriveClient(t, children, nil)
+	engine.config.NoProgressTimeout = 300 * time.Millisecond
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not stop the stalled sync")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusStalled, session.Status)
+
+	// Stalled sessions are resumed like failed ones
+	resumable, err := m.Sessions().GetLatestResumable(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, resumable)
+	assert.Equal(t, sessionID, resumable.ID)
+}
+
+func TestEnginePauseStopsStartingDownloads(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{"root": {}}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("f-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 10})
+	}
+
+	// The first download runs until the engine is paused
+	var started atomic.Int32
+	firstStarted := make(chan struct{})
+	release := make(chan struct{})
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		if started.Add(1) == 1 {
+			close(firstStarted)
+			<-release
+		}
+		return file.Size, nil
+	}
+
+	engine := newTestEngine(t, m, download)
+	engine.client = newFakeDriveClient(t, children, nil)
+	engine.config.DownloadConfig.MaxConcurrent = 1
+	_, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-firstStarted:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no download started")
+	}
+
+	require.NoError(t, engine.Pause())
+	close(release)
+
+	// Queued files wait while paused
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, int32(1), started.Load())
+
+	require.NoError(t, engine.Resume())
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not finish after resuming")
+	}
+	assert.Equal(t, int32(5), started.Load())
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestEventLogSamplesProgressEvents(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+	require.NoError(t, err)
+
+	log := newEventLog(m, newTestLogger())
+	start := time.Now()
+	event := func(eventType ProgressEventType, offset time.Duration, bytes int64) *ProgressEvent {
+		return &ProgressEvent{
+			Type:             eventType,
+			Timestamp:        start.Add(offset),
+			SessionID:        session.ID,
+			ItemID:           "file-1",
+			ItemPath:         "docs/a.bin",
+			BytesTransferred: bytes,
+		}
+	}
+
+	log.record(event(ProgressEventFileStarted, 0, 0))
+	for i := 0; i < 20; i++ {
+		log.record(event(ProgressEventFileProgress, time.Duration(i)*time.Second, int64(i+1)*100))
+	}
+	failed := event(ProgressEventFileFailed, 20*time.Second, 0)
+	failed.ErrorMessage = "connection reset"
+	log.record(failed)
+
+	// Session updates are not file events
+	log.record(&ProgressEvent{Type: ProgressEventSessionUpdate, SessionID: session.ID})
+	log.flush()
+
+	events, err := m.GetEvents(ctx, session.ID, nil)
+	require.NoError(t, err)
+
+	var types []string
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	// Progress is recorded at 0s, 5s, 10s and 15s
+	assert.Equal(t, []string{
+		"file_started",
+		"file_progress", "file_progress", "file_progress", "file_progress",
+		"file_failed",
+	}, types)
+	assert.Equal(t, int64(600), events[2].Bytes)
+	assert.Equal(t, "connection reset", events[5].Message.String)
+	assert.Equal(t, "docs/a.bin", events[5].ItemPath.String)
+}
+
+func TestEnginePersistsFileEvents(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	})
+	engine.config.PersistEvents = true
+	engine.client = newFakeDriveClient(t, map[string][]*drive.File{
+		"root": {
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10},
+			{Id: "file-b", Name: "b.txt", MimeType: "text/plain", Size: 20},
+		},
+	}, nil)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	completed, err := m.GetEvents(ctx, sessionID, &state.EventFilter{Type: string(ProgressEventFileCompleted)})
+	require.NoError(t, err)
+	assert.Len(t, completed, 2)
+}
@@ -0,0 +1,207 @@
+/**
+ * JSON Lines Event Stream for CloudPull Sync Engine
+ *
+ * Features:
+ * - Writes every progress event as one JSON object per line
+ * - Stable, versioned record schema for tools embedding CloudPull
+ * - Never blocks downloads; events are dropped and counted when the
+ *   reader falls behind
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+const (
+	// EventStreamVersion is the schema version of StreamRecord; fields are
+	// only added within a version.
+	EventStreamVersion = 1
+
+	// Record types written besides the progress event types
+	StreamRecordEventsDropped   = "events_dropped"
+	StreamRecordSessionFinished = "session_finished"
+
+	// eventStreamQueueSize is the number of events buffered for a slow
+	// reader before events are dropped
+	eventStreamQueueSize = 4096
+
+	// eventStreamCloseTimeout bounds the wait for buffered events when a
+	// sync stops
+	eventStreamCloseTimeout = 2 * time.Second
+)
+
+// StreamRecord is one line of the event stream. Type is a progress event
+// type such as file_completed, or one of the StreamRecord types.
+type StreamRecord struct {
+	Time             time.Time              `json:"time"`
+	Details          map[string]interface{} `json:"details,omitempty"`
+	Type             string                 `json:"type"`
+	SessionID        string                 `json:"session_id"`
+	ItemID           string                 `json:"item_id,omitempty"`
+	ItemName         string                 `json:"item_name,omitempty"`
+	ItemPath         string                 `json:"item_path,omitempty"`
+	Error            string                 `json:"error,omitempty"`
+	Status           string                 `json:"status,omitempty"`
+	Version          int                    `json:"v"`
+	BytesTransferred int64                  `json:"bytes"`
+	TotalBytes       int64                  `json:"total_bytes,omitempty"`
+	FilesCompleted   int64                  `json:"files_completed,omitempty"`
+	TotalFiles       int64                  `json:"total_files,omitempty"`
+	Speed            int64                  `json:"speed_bps,omitempty"`
+	AverageSpeed     int64                  `json:"avg_speed_bps,omitempty"`
+	ETASeconds       int64                  `json:"eta_seconds,omitempty"`
+	Dropped          int64                  `json:"dropped,omitempty"`
+}
+
+// newStreamRecord converts a progress event to its stream record.
+func newStreamRecord(event *ProgressEvent) *StreamRecord {
+	record := &StreamRecord{
+		Version:          EventStreamVersion,
+		Time:             event.Timestamp,
+		Type:             string(event.Type),
+		SessionID:        event.SessionID,
+		ItemID:           event.ItemID,
+		ItemName:         event.ItemName,
+		ItemPath:         event.ItemPath,
+		Error:            event.ErrorMessage,
+		BytesTransferred: event.BytesTransferred,
+		TotalBytes:       event.TotalBytes,
+		FilesCompleted:   event.FilesCompleted,
+		TotalFiles:       event.TotalFiles,
+		Speed:            event.CurrentSpeed,
+		AverageSpeed:     event.AverageSpeed,
+		ETASeconds:       int64(event.RemainingTime.Seconds()),
+	}
+	if record.Error == "" && event.Error != nil {
+		record.Error = event.Error.Error()
+	}
+
+	// Only plain values are kept so every line encodes
+	for key, value := range event.Context {
+		switch value.(type) {
+		case string, bool, int, int64, float64:
+			if record.Details == nil {
+				record.Details = make(map[string]interface{})
+			}
+			record.Details[key] = value
+		}
+	}
+	return record
+}
+
+// eventStream writes the progress events of a sync to w. Events are queued
+// by record, which never waits, and written by a separate goroutine.
+type eventStream struct {
+	encoder *json.Encoder
+	logger  *logger.Logger
+	records chan *StreamRecord
+	done    chan struct{}
+	dropped atomic.Int64
+
+	// mu guards closed; handlers may still deliver events after close
+	mu     sync.Mutex
+	closed bool
+}
+
+func newEventStream(w io.Writer, logger *logger.Logger) *eventStream {
+	s := &eventStream{
+		encoder: json.NewEncoder(w),
+		logger:  logger,
+		records: make(chan *StreamRecord, eventStreamQueueSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// record queues an event. It is registered as a progress tracker handler.
+func (s *eventStream) record(event *ProgressEvent) {
+	s.enqueue(newStreamRecord(event))
+}
+
+// finish queues the final record of a session.
+func (s *eventStream) finish(session *state.Session) {
+	s.enqueue(&StreamRecord{
+		Version:          EventStreamVersion,
+		Time:             time.Now(),
+		Type:             StreamRecordSessionFinished,
+		SessionID:        session.ID,
+		Status:           session.Status,
+		BytesTransferred: session.CompletedBytes,
+		TotalBytes:       session.TotalBytes,
+		FilesCompleted:   session.CompletedFiles,
+		TotalFiles:       session.TotalFiles,
+		Details: map[string]interface{}{
+			"failed_files":  session.FailedFiles,
+			"skipped_files": session.SkippedFiles,
+		},
+	})
+}
+
+func (s *eventStream) enqueue(record *StreamRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	select {
+	case s.records <- record:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *eventStream) run() {
+	defer close(s.done)
+
+	for record := range s.records {
+		if dropped := s.dropped.Swap(0); dropped > 0 {
+			s.write(&StreamRecord{
+				Version:   EventStreamVersion,
+				Time:      time.Now(),
+				Type:      StreamRecordEventsDropped,
+				SessionID: record.SessionID,
+				Dropped:   dropped,
+			})
+		}
+		s.write(record)
+	}
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		s.logger.Warn("Event stream reader fell behind, events dropped", "count", dropped)
+	}
+}
+
+// write encodes one record; a failing writer stops nothing but the stream.
+func (s *eventStream) write(record *StreamRecord) {
+	if err := s.encoder.Encode(record); err != nil {
+		s.logger.Debug("Failed to write event stream record", "type", record.Type, "error", err)
+	}
+}
+
+// close writes the queued records, waiting at most eventStreamCloseTimeout
+// for a slow reader.
+func (s *eventStream) close() {
+	s.mu.Lock()
+	s.closed = true
+	close(s.records)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(eventStreamCloseTimeout):
+		s.logger.Warn("Event stream reader is not keeping up, remaining events abandoned")
+	}
+}
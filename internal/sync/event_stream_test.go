@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/api/apitest"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// newStreamTestEngine creates an engine syncing a small fake Drive whose
+// events are written to stream.
+func newStreamTestEngine(t *testing.T, m *state.Manager, stream io.Writer) *Engine {
+	t.Helper()
+
+	fake := apitest.NewFakeDrive()
+	fake.AddFolder("root", "folder-docs", "docs")
+	fake.AddFile("root", "file-a", "a.txt", "alpha")
+	fake.AddFile("folder-docs", "file-b", "b.txt", "bravo")
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.SetEventStream(stream)
+
+	return engine
+}
+
+func TestEventStreamWritesJSONLines(t *testing.T) {
+	m := newTestStateManager(t)
+	var out bytes.Buffer
+	engine := newStreamTestEngine(t, m, &out)
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	var records []StreamRecord
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var record StreamRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), scanner.Text())
+		records = append(records, record)
+	}
+	require.NotEmpty(t, records)
+
+	completed := make(map[string]int64)
+	for _, record := range records {
+		assert.Equal(t, EventStreamVersion, record.Version)
+		assert.Equal(t, sessionID, record.SessionID)
+		assert.False(t, record.Time.IsZero())
+		if record.Type == string(ProgressEventFileCompleted) {
+			completed[record.ItemPath] = record.BytesTransferred
+		}
+	}
+	assert.Equal(t, map[string]int64{"root/a.txt": 5, "root/docs/b.txt": 5}, completed)
+
+	last := records[len(records)-1]
+	assert.Equal(t, StreamRecordSessionFinished, last.Type)
+	assert.Equal(t, state.SessionStatusCompleted, last.Status)
+	assert.Equal(t, int64(2), last.FilesCompleted)
+}
+
+// blockedWriter never returns from Write until released.
+type blockedWriter struct {
+	release chan struct{}
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestEventStreamDoesNotBlockDownloads(t *testing.T) {
+	m := newTestStateManager(t)
+	writer := &blockedWriter{release: make(chan struct{})}
+	defer close(writer.release)
+	engine := newStreamTestEngine(t, m, writer)
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("a stuck event reader blocked the sync")
+	}
+
+	session, err := m.GetSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+}
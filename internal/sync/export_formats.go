@@ -0,0 +1,229 @@
+/**
+ * Google Docs Export Formats for CloudPull Sync Engine
+ *
+ * Features:
+ * - Configurable export formats per Google Workspace file type
+ * - Short names such as "document" and "pdf" or full MIME types
+ * - Additional formats exported next to the main export of a file
+ * - Spreadsheet export mode choosing between XLSX and single-tab CSV
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// ErrorTypeExtraExport is the error log type of additional export formats
+// that failed; the file itself stays completed.
+const ErrorTypeExtraExport = "extra_export"
+
+// googleAppsPrefix is the MIME type prefix of Google Workspace files.
+const googleAppsPrefix = "application/vnd.google-apps."
+
+// exportExtensions maps export MIME types to their file extensions.
+var exportExtensions = map[string]string{
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"application/vnd.oasis.opendocument.text":                                   ".odt",
+	"application/vnd.oasis.opendocument.spreadsheet":                            ".ods",
+	"application/vnd.oasis.opendocument.presentation":                           ".odp",
+	"application/pdf":      ".pdf",
+	"application/rtf":      ".rtf",
+	"application/epub+zip": ".epub",
+	"text/plain":           ".txt",
+	"text/html":            ".html",
+	"text/csv":             ".csv",
+}
+
+// Spreadsheet export modes (files.sheets_export_mode).
+const (
+	// SheetsExportXLSX exports Google Sheets as XLSX workbooks, which keep
+	// every tab.
+	SheetsExportXLSX = "xlsx"
+
+	// SheetsExportCSV exports Google Sheets as a single CSV. Drive's CSV
+	// export holds only the first tab, so the other tabs are lost.
+	SheetsExportCSV = "csv"
+)
+
+// spreadsheetMimeType is the MIME type of Google Sheets.
+const spreadsheetMimeType = googleAppsPrefix + "spreadsheet"
+
+// ApplySheetsExportMode sets the main export of Google Sheets in formats,
+// as returned by ParseExportFormats, according to mode. Formats configured
+// for spreadsheets take precedence; an empty mode means SheetsExportXLSX.
+func ApplySheetsExportMode(formats map[string][]string, mode string) (map[string][]string, error) {
+	var format string
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", SheetsExportXLSX:
+		format = exportMimeTypeFor(".xlsx")
+	case SheetsExportCSV:
+		format = exportMimeTypeFor(".csv")
+	default:
+		return nil, errors.Errorf("unknown sheets export mode %q: use %s or %s", mode, SheetsExportXLSX, SheetsExportCSV)
+	}
+
+	if len(formats[spreadsheetMimeType]) > 0 {
+		return formats, nil
+	}
+
+	applied := make(map[string][]string, len(formats)+1)
+	for googleType, list := range formats {
+		applied[googleType] = list
+	}
+	applied[spreadsheetMimeType] = []string{format}
+	return applied, nil
+}
+
+// SheetsExportedAsCSV reports whether formats export Google Sheets as CSV,
+// which keeps only the first tab of each sheet.
+func SheetsExportedAsCSV(formats map[string][]string) bool {
+	sheets := formats[spreadsheetMimeType]
+	return len(sheets) > 0 && sheets[0] == exportMimeTypeFor(".csv")
+}
+
+// exportMimeTypeFor returns the export MIME type with extension ext.
+func exportMimeTypeFor(ext string) string {
+	for mimeType, known := range exportExtensions {
+		if known == ext {
+			return mimeType
+		}
+	}
+	return ""
+}
+
+// ParseExportFormats converts configured export formats into Google MIME
+// types mapped to export MIME types. Keys are Google file types such as
+// "document" or their full MIME type; formats are extensions such as "pdf"
+// or full MIME types. The first format of a type is its main export.
+func ParseExportFormats(configured map[string][]string) (map[string][]string, error) {
+	formats := make(map[string][]string, len(configured))
+	for fileType, names := range configured {
+		googleType := strings.ToLower(strings.TrimSpace(fileType))
+		if !strings.HasPrefix(googleType, googleAppsPrefix) {
+			googleType = googleAppsPrefix + googleType
+		}
+
+		seen := make(map[string]bool, len(names))
+		for _, name := range names {
+			format, err := parseExportFormat(name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "export formats for %s", fileType)
+			}
+			if !seen[format] {
+				seen[format] = true
+				formats[googleType] = append(formats[googleType], format)
+			}
+		}
+	}
+
+	return formats, nil
+}
+
+// parseExportFormat resolves an extension such as "pdf" or ".docx", or a
+// MIME type, to a supported export MIME type.
+func parseExportFormat(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := exportExtensions[name]; ok {
+		return name, nil
+	}
+
+	if mimeType := exportMimeTypeFor("." + strings.TrimPrefix(name, ".")); mimeType != "" {
+		return mimeType, nil
+	}
+
+	return "", errors.Errorf("unknown export format %q", name)
+}
+
+// extraExportFormats returns the formats exported next to the main export
+// of file.
+func extraExportFormats(formats map[string][]string, file *state.File) []string {
+	if !file.IsGoogleDoc || !file.MimeType.Valid {
+		return nil
+	}
+
+	var extra []string
+	for _, format := range formats[file.MimeType.String] {
+		if format != file.ExportMimeType.String {
+			extra = append(extra, format)
+		}
+	}
+	return extra
+}
+
+// exportSiblingPath returns where the format export of file is stored next
+// to its main export at mainPath.
+func exportSiblingPath(file *state.File, mainPath, format string) string {
+	base := strings.TrimSuffix(mainPath, exportExtension(file.ExportMimeType.String))
+	return base + exportExtension(format)
+}
+
+// exportExtraFormats exports the additional formats of a Google Docs file
+// next to its main export at mainPath. Drive has no checksum for exports,
+// so none is verified, and the copies are not counted towards progress.
+// The main export is already in place, so a format that fails is logged
+// and recorded in the error log without failing the file.
+func (dm *DownloadManager) exportExtraFormats(ctx context.Context, log *logger.Logger, file *state.File, mainPath string) {
+	for _, format := range extraExportFormats(dm.exportFormats, file) {
+		if ctx.Err() != nil {
+			return
+		}
+
+		path, err := dm.exportExtraFormat(ctx, log, file, mainPath, format)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Warn("Failed to export additional format",
+				"file_id", file.ID,
+				"format", format,
+				"error", err,
+			)
+			if logErr := dm.stateManager.LogError(ctx, file.SessionID, file.ID, "file", ErrorTypeExtraExport, err); logErr != nil {
+				log.Error(logErr, "Failed to record additional format failure", "file_id", file.ID)
+			}
+			continue
+		}
+
+		log.Debug("Exported additional format",
+			"file_id", file.ID,
+			"format", format,
+			"path", path,
+		)
+	}
+}
+
+// exportExtraFormat exports file as format next to its main export at
+// mainPath and returns the path of the copy.
+func (dm *DownloadManager) exportExtraFormat(ctx context.Context, log *logger.Logger, file *state.File, mainPath, format string) (string, error) {
+	tempPath := dm.getTempPath(file) + exportExtension(format)
+
+	dm.trackConnection(1)
+	err := dm.client.ExportFile(ctx, file.DriveID, format, tempPath, nil)
+	dm.trackConnection(-1)
+	if err != nil {
+		return "", errors.Wrapf(err, "export as %s failed", format)
+	}
+
+	path := exportSiblingPath(file, mainPath, format)
+	if err := dm.moveToFinal(ctx, tempPath, path); err != nil {
+		if removeErr := os.Remove(tempPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Error(removeErr, "failed to remove temp export", "path", tempPath)
+		}
+		return "", errors.Wrapf(err, "failed to move %s export into place", format)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+const (
+	docxMimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	xlsxMimeType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+func TestParseExportFormats(t *testing.T) {
+	formats, err := ParseExportFormats(map[string][]string{
+		"Document": {"docx", ".pdf", "application/pdf"},
+		"application/vnd.google-apps.spreadsheet": {"XLSX", "text/csv"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"application/vnd.google-apps.document":    {docxMimeType, "application/pdf"},
+		"application/vnd.google-apps.spreadsheet": {xlsxMimeType, "text/csv"},
+	}, formats)
+
+	_, err = ParseExportFormats(map[string][]string{"document": {"wpd"}})
+	assert.Error(t, err)
+}
+
+func TestEngineExportsAdditionalFormats(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "doc", Name: "Plan", MimeType: "application/vnd.google-apps.document"},
+		},
+	}
+
+	formats, err := ParseExportFormats(map[string][]string{"document": {"pdf", "docx"}})
+	require.NoError(t, err)
+
+	log := newTestLogger()
+	m := newTestStateManager(t)
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.ExportFormats = formats
+	cfg.WalkerConfig.ExportFormats = formats
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	dest := t.TempDir()
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	for _, name := range []string{"Plan.pdf", "Plan.docx"} {
+		data, err := os.ReadFile(filepath.Join(dest, "root", name))
+		require.NoError(t, err, name)
+		assert.Equal(t, "exported doc", string(data))
+	}
+
+	// The extra format is not counted as a second file
+	files, err := m.Files().GetBySession(context.Background(), sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, state.FileStatusCompleted, files[0].Status)
+	assert.Equal(t, "application/pdf", files[0].ExportMimeType.String)
+}
+
+func TestFailedAdditionalFormatKeepsFileCompleted(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "doc", Name: "Plan", MimeType: "application/vnd.google-apps.document"},
+		},
+	}
+
+	formats, err := ParseExportFormats(map[string][]string{"document": {"pdf", "docx"}})
+	require.NoError(t, err)
+
+	// Drive refuses the DOCX export only
+	handler := fakeDriveHandler(children, nil, nil)
+	var exports atomic.Int32
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/export") {
+			exports.Add(1)
+			if r.URL.Query().Get("mimeType") == docxMimeType {
+				http.Error(w, "export too large", http.StatusBadRequest)
+				return
+			}
+		}
+		handler(w, r)
+	}))
+
+	log := newTestLogger()
+	m := newTestStateManager(t)
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.ExportFormats = formats
+	cfg.WalkerConfig.ExportFormats = formats
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	dest := t.TempDir()
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// The main export stays and is not downloaded again
+	_, err = os.Stat(filepath.Join(dest, "root", "Plan.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), exports.Load())
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, state.FileStatusCompleted, files[0].Status)
+
+	var logged int
+	require.NoError(t, m.DB().GetContext(ctx, &logged,
+		"SELECT COUNT(*) FROM error_log WHERE item_id = $1 AND error_type = $2", files[0].ID, ErrorTypeExtraExport))
+	assert.Equal(t, 1, logged)
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+}
+
+func TestApplySheetsExportMode(t *testing.T) {
+	configured, err := ParseExportFormats(map[string][]string{"document": {"pdf"}})
+	require.NoError(t, err)
+
+	// XLSX keeps every tab and is the default
+	for _, mode := range []string{"", SheetsExportXLSX} {
+		formats, err := ApplySheetsExportMode(configured, mode)
+		require.NoError(t, err)
+		assert.Equal(t, ".xlsx", exportExtension(formats[spreadsheetMimeType][0]), mode)
+		assert.Equal(t, []string{"application/pdf"}, formats["application/vnd.google-apps.document"])
+		assert.False(t, SheetsExportedAsCSV(formats))
+	}
+
+	formats, err := ApplySheetsExportMode(configured, "CSV")
+	require.NoError(t, err)
+	assert.Equal(t, ".csv", exportExtension(formats[spreadsheetMimeType][0]))
+	assert.True(t, SheetsExportedAsCSV(formats))
+	assert.NotContains(t, configured, spreadsheetMimeType)
+
+	// Formats listed for spreadsheets win over the mode
+	listed, err := ParseExportFormats(map[string][]string{"spreadsheet": {"ods", "csv"}})
+	require.NoError(t, err)
+	formats, err = ApplySheetsExportMode(listed, SheetsExportCSV)
+	require.NoError(t, err)
+	assert.Equal(t, ".ods", exportExtension(formats[spreadsheetMimeType][0]))
+
+	_, err = ApplySheetsExportMode(configured, "tabs")
+	assert.Error(t, err)
+}
+
+func TestEngineExportsSheetsInSelectedMode(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "sheet", Name: "Budget", MimeType: spreadsheetMimeType},
+		},
+	}
+
+	for mode, name := range map[string]string{SheetsExportXLSX: "Budget.xlsx", SheetsExportCSV: "Budget.csv"} {
+		formats, err := ApplySheetsExportMode(nil, mode)
+		require.NoError(t, err)
+
+		log := newTestLogger()
+		cfg := DefaultEngineConfig()
+		cfg.DownloadConfig.TempDir = t.TempDir()
+		cfg.DownloadConfig.ExportFormats = formats
+		cfg.WalkerConfig.ExportFormats = formats
+		engine, err := NewEngine(newFakeDriveClient(t, children, nil), newTestStateManager(t), errors.NewHandler(log), log, cfg)
+		require.NoError(t, err)
+
+		dest := t.TempDir()
+		_, err = engine.StartNewSessionWithID(context.Background(), "root", dest)
+		require.NoError(t, err)
+
+		select {
+		case <-engine.WaitForCompletion():
+		case <-time.After(30 * time.Second):
+			t.Fatal("sync engine did not terminate")
+		}
+
+		entries, err := os.ReadDir(filepath.Join(dest, "root"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1, mode)
+		assert.Equal(t, name, entries[0].Name(), mode)
+	}
+}
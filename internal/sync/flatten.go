@@ -0,0 +1,172 @@
+/**
+ * Local Path Resolution for CloudPull Sync Engine
+ *
+ * Features:
+ * - Mirrors the Drive hierarchy below the session destination
+ * - Flattened sessions download every file into one directory
+ * - Path templates choose the local layout per file
+ * - Optional category directories such as "images/" in front of paths
+ * - Numbered suffixes for colliding flattened or templated names
+ * - Export extensions for Google Docs files
+ * - Shortened names for paths over the length limits
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// maxFlattenCollisions bounds the numbered names tried for one file.
+const maxFlattenCollisions = 10000
+
+// localPath returns where file is stored on disk. Flattened sessions place
+// every file directly in the destination directory, a path template
+// computes the path below it, and category folders prefix either; the
+// chosen path is recorded on the file so retries, verification and resumed
+// sessions reuse it. Paths over the length limits are recorded with a
+// shortened name, while the file keeps its Drive name.
+func (dm *DownloadManager) localPath(ctx context.Context, session *state.Session, file *state.File) (string, error) {
+	if file.LocalPath.Valid && file.LocalPath.String != "" {
+		return file.LocalPath.String, nil
+	}
+
+	rel, err := dm.plannedRelPath(ctx, session, file)
+	if err != nil {
+		return "", err
+	}
+	if rel == "" {
+		rel = exportFileName(file, file.Path)
+		localPath, shortened, err := shortenPath(session.DestinationPath, rel, dm.maxPathLength)
+		if err != nil {
+			return "", err
+		}
+		if !shortened {
+			return localPath, nil
+		}
+	}
+	return dm.reservePath(ctx, session, file, rel)
+}
+
+// plannedRelPath returns the path of file below the destination before any
+// collision suffix, or "" when it keeps its Drive path unchanged.
+func (dm *DownloadManager) plannedRelPath(ctx context.Context, session *state.Session, file *state.File) (string, error) {
+	var rel string
+	switch {
+	case session.Flatten:
+		rel = exportFileName(file, sanitizeFileName(file.Name))
+	case dm.pathTemplate != nil:
+		var err error
+		if rel, err = dm.templateRelPath(ctx, file); err != nil {
+			return "", err
+		}
+	case dm.organizeByCategory:
+		rel = exportFileName(file, file.Path)
+	default:
+		return "", nil
+	}
+
+	if dm.organizeByCategory {
+		rel = filepath.Join(api.MimeCategory(file.MimeType.String), rel)
+	}
+	return rel, nil
+}
+
+// LocalFilePath returns where a file of session is stored on disk: the path
+// recorded when it was downloaded, or else its Drive path below the session
+// destination.
+func LocalFilePath(session *state.Session, file *state.File) string {
+	if file.LocalPath.Valid && file.LocalPath.String != "" {
+		return file.LocalPath.String
+	}
+	return filepath.Join(session.DestinationPath, exportFileName(file, file.Path))
+}
+
+// reservePath claims rel below the destination directory, adding " (n)"
+// before the extension while another file of the session holds it.
+func (dm *DownloadManager) reservePath(ctx context.Context, session *state.Session, file *state.File, rel string) (string, error) {
+	dir, name := filepath.Split(rel)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" {
+		// Dot files such as ".env" have no extension to keep
+		base, ext = name, ""
+	}
+
+	for n := 0; n < maxFlattenCollisions; n++ {
+		candidate := rel
+		if n > 0 {
+			candidate = dir + fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		localPath, shortened, err := shortenPath(session.DestinationPath, candidate, dm.maxPathLength)
+		if err != nil {
+			return "", err
+		}
+
+		reserved, err := dm.stateManager.Files().ReserveLocalPath(ctx, file.ID, file.SessionID, localPath)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to record local path")
+		}
+		if reserved {
+			if shortened {
+				dm.logger.Warn("Shortened a path that exceeds the length limit",
+					"file_id", file.ID,
+					"drive_path", file.Path,
+					"local_path", localPath,
+				)
+			}
+			if n > 0 {
+				dm.logger.Debug("Renamed file to avoid a name collision",
+					"file_id", file.ID,
+					"drive_path", file.Path,
+					"local_path", localPath,
+				)
+			}
+			file.LocalPath = state.NewNullString(localPath)
+			return localPath, nil
+		}
+	}
+
+	return "", errors.Errorf("no free file name for %s in %s", rel, session.DestinationPath)
+}
+
+// exportFileName appends the export extension to Google Docs names that do
+// not already end with it.
+func exportFileName(file *state.File, name string) string {
+	if !file.IsGoogleDoc || !file.ExportMimeType.Valid {
+		return name
+	}
+
+	ext := exportExtension(file.ExportMimeType.String)
+	if ext == "" || strings.EqualFold(filepath.Ext(name), ext) {
+		return name
+	}
+	return name + ext
+}
+
+// sanitizeFileName turns a Drive file name into a single path element.
+// Drive allows slashes in names, which would otherwise create directories.
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestLocalPathFlattenResolvesCollisions(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", dest)
+	require.NoError(t, err)
+	session.Flatten = true
+	require.NoError(t, m.UpdateSession(ctx, session))
+
+	folder := &state.Folder{DriveID: "drive-root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+
+	newFile := func(id, name, path string) *state.File {
+		return &state.File{
+			DriveID:   id,
+			FolderID:  folder.ID,
+			SessionID: session.ID,
+			Name:      name,
+			Path:      path,
+			Status:    state.FileStatusPending,
+		}
+	}
+	doc := newFile("doc", "Notes", "root/b/Notes")
+	doc.IsGoogleDoc = true
+	doc.ExportMimeType = state.NewNullString("application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	files := []*state.File{
+		newFile("first", "report.pdf", "root/report.pdf"),
+		newFile("second", "report.pdf", "root/a/report.pdf"),
+		newFile("third", "Report.PDF", "root/b/Report.PDF"),
+		newFile("slash", "a/b.txt", "root/a/b.txt"),
+		doc,
+	}
+	require.NoError(t, m.Files().CreateBatch(ctx, files))
+
+	dm, err := NewDownloadManager(nil, m, NewProgressTracker(session.ID), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	expected := []string{"report.pdf", "report (1).pdf", "Report (2).PDF", "a_b.txt", "Notes.docx"}
+	for i, file := range files {
+		path, err := dm.localPath(ctx, session, file)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dest, expected[i]), path)
+	}
+
+	// The reserved path is stored so a resumed session reuses it
+	stored, err := m.Files().GetByDriveID(ctx, "second", session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "report (1).pdf"), stored.LocalPath.String)
+
+	path, err := dm.localPath(ctx, session, stored)
+	require.NoError(t, err)
+	assert.Equal(t, stored.LocalPath.String, path)
+}
+
+func TestLocalPathMirrorsHierarchy(t *testing.T) {
+	dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir()})
+	require.NoError(t, err)
+
+	session := &state.Session{ID: "session-1", DestinationPath: "/dest"}
+	file := &state.File{Name: "Budget v1.2", Path: "root/Budget v1.2", IsGoogleDoc: true,
+		ExportMimeType: state.NewNullString("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")}
+
+	path, err := dm.localPath(context.Background(), session, file)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/dest", "root", "Budget v1.2.xlsx"), path)
+}
+
+func TestEngineFlattenDownloadsIntoOneDirectory(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "folder-a", Name: "a", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "file-top", Name: "data.bin", MimeType: "application/octet-stream", Size: 10},
+			{Id: "doc-top", Name: "Notes", MimeType: "application/vnd.google-apps.document"},
+		},
+		"folder-a": {
+			{Id: "file-a", Name: "data.bin", MimeType: "application/octet-stream", Size: 20},
+			{Id: "doc-a", Name: "Notes", MimeType: "application/vnd.google-apps.document"},
+		},
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.SetFlatten(true)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.True(t, session.Flatten)
+
+	entries, err := os.ReadDir(dest)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		assert.False(t, entry.IsDir(), entry.Name())
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{"data.bin", "data (1).bin", "Notes.docx", "Notes (1).docx"}, names)
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	for _, file := range files {
+		assert.FileExists(t, file.LocalPath.String, file.Path)
+	}
+}
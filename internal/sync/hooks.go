@@ -0,0 +1,204 @@
+/**
+ * Completion Hooks for CloudPull Sync Engine
+ *
+ * Features:
+ * - Webhook notification with final session statistics
+ * - Shell command execution with session environment
+ * - Per-attempt timeouts and webhook retries
+ * - Failures are logged and never fatal
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// HookConfig contains configuration for sync completion hooks.
+type HookConfig struct {
+	// URL that receives a JSON POST when a sync finishes
+	OnCompleteURL string
+
+	// Shell command executed when a sync finishes
+	OnCompleteCommand string
+
+	// Timeout for each webhook attempt and for the command
+	Timeout time.Duration
+
+	// Maximum webhook delivery attempts
+	MaxRetries int
+}
+
+// DefaultHookConfig returns default hook configuration.
+func DefaultHookConfig() *HookConfig {
+	return &HookConfig{
+		Timeout:    10 * time.Second,
+		MaxRetries: 3,
+	}
+}
+
+// CompletionPayload is the body posted to the completion webhook.
+type CompletionPayload struct {
+	Stats       *state.SessionStats `json:"stats,omitempty"`
+	SessionID   string              `json:"session_id"`
+	Status      string              `json:"status"`
+	FailedFiles int64               `json:"failed_files"`
+}
+
+// HookRunner executes sync completion hooks.
+type HookRunner struct {
+	config     *HookConfig
+	logger     *logger.Logger
+	httpClient *http.Client
+}
+
+// NewHookRunner creates a new hook runner.
+func NewHookRunner(logger *logger.Logger, config *HookConfig) *HookRunner {
+	if config == nil {
+		config = DefaultHookConfig()
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultHookConfig().Timeout
+	}
+
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = 1
+	}
+
+	return &HookRunner{
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{},
+	}
+}
+
+// Enabled reports whether any completion hook is configured.
+func (h *HookRunner) Enabled() bool {
+	return h.config.OnCompleteURL != "" || h.config.OnCompleteCommand != ""
+}
+
+// RunCompletionHooks fires all configured completion hooks.
+// Errors are logged and never returned.
+func (h *HookRunner) RunCompletionHooks(ctx context.Context, payload *CompletionPayload) {
+	if h.config.OnCompleteURL != "" {
+		if err := h.postWebhook(ctx, payload); err != nil {
+			h.logger.Error(err, "Completion webhook failed",
+				"session_id", payload.SessionID,
+				"url", h.config.OnCompleteURL,
+			)
+		} else {
+			h.logger.Info("Completion webhook delivered", "session_id", payload.SessionID)
+		}
+	}
+
+	if h.config.OnCompleteCommand != "" {
+		if err := h.runCommand(ctx, payload); err != nil {
+			h.logger.Error(err, "Completion command failed",
+				"session_id", payload.SessionID,
+				"command", h.config.OnCompleteCommand,
+			)
+		} else {
+			h.logger.Info("Completion command executed", "session_id", payload.SessionID)
+		}
+	}
+}
+
+// postWebhook posts the payload to the configured URL, retrying on failure.
+func (h *HookRunner) postWebhook(ctx context.Context, payload *CompletionPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode webhook payload")
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= h.config.MaxRetries; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt-1) * time.Second):
+			}
+		}
+
+		lastErr = h.sendWebhook(ctx, body)
+		if lastErr == nil {
+			return nil
+		}
+
+		h.logger.Warn("Completion webhook attempt failed",
+			"attempt", attempt,
+			"max_attempts", h.config.MaxRetries,
+			"error", lastErr,
+		)
+	}
+
+	return errors.Wrapf(lastErr, "webhook failed after %d attempts", h.config.MaxRetries)
+}
+
+// sendWebhook performs a single webhook delivery attempt.
+func (h *HookRunner) sendWebhook(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.OnCompleteURL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "failed to create webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// runCommand executes the configured shell command with session details in the environment.
+func (h *HookRunner) runCommand(ctx context.Context, payload *CompletionPayload) error {
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	// #nosec G204 - the command comes from the user's own configuration
+	cmd := exec.CommandContext(ctx, shell, flag, h.config.OnCompleteCommand)
+	cmd.Env = append(os.Environ(),
+		"CLOUDPULL_SESSION_ID="+payload.SessionID,
+		"CLOUDPULL_STATUS="+payload.Status,
+		"CLOUDPULL_FAILED="+strconv.FormatInt(payload.FailedFiles, 10),
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "command output: %s", bytes.TrimSpace(output))
+	}
+
+	return nil
+}
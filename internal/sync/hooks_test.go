@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/logger"
+)
+
+var (
+	testLogger     *logger.Logger
+	testLoggerOnce sync.Once
+)
+
+// newTestLogger returns a shared quiet logger. logger.New sets zerolog
+// globals, so creating one per test races with goroutines still logging.
+func newTestLogger() *logger.Logger {
+	testLoggerOnce.Do(func() {
+		testLogger = logger.New(&logger.Config{Level: "error", Output: io.Discard})
+	})
+	return testLogger
+}
+
+func TestHookRunnerWebhookRetries(t *testing.T) {
+	var attempts int32
+	var received CompletionPayload
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	runner := NewHookRunner(newTestLogger(), &HookConfig{
+		OnCompleteURL: server.URL,
+		Timeout:       time.Second,
+		MaxRetries:    3,
+	})
+
+	err := runner.postWebhook(context.Background(), &CompletionPayload{
+		SessionID:   "session-1",
+		Status:      "completed",
+		FailedFiles: 2,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, "session-1", received.SessionID)
+	assert.Equal(t, "completed", received.Status)
+	assert.Equal(t, int64(2), received.FailedFiles)
+}
+
+func TestHookRunnerWebhookGivesUp(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	runner := NewHookRunner(newTestLogger(), &HookConfig{
+		OnCompleteURL: server.URL,
+		Timeout:       time.Second,
+		MaxRetries:    2,
+	})
+
+	err := runner.postWebhook(context.Background(), &CompletionPayload{SessionID: "session-1"})
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestHookRunnerCommandEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell command test requires sh")
+	}
+
+	outFile := filepath.Join(t.TempDir(), "hook.out")
+	runner := NewHookRunner(newTestLogger(), &HookConfig{
+		OnCompleteCommand: `echo "$CLOUDPULL_SESSION_ID $CLOUDPULL_STATUS $CLOUDPULL_FAILED" > ` + outFile,
+		Timeout:           5 * time.Second,
+	})
+
+	err := runner.runCommand(context.Background(), &CompletionPayload{
+		SessionID:   "session-1",
+		Status:      "failed",
+		FailedFiles: 7,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Equal(t, "session-1 failed 7", strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,187 @@
+/**
+ * Ignore Files for CloudPull Sync Engine
+ *
+ * Features:
+ * - .cloudpullignore files in Drive folders, in the spirit of .gitignore
+ * - Rules apply to the folder holding the file and everything beneath it
+ * - Rules of nested ignore files extend those of their parents
+ * - Negated rules re-include what an earlier rule ignored
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+const (
+	// IgnoreFileName is the name of the per-folder ignore files honored when
+	// WalkerConfig.RespectIgnoreFiles is set.
+	IgnoreFileName = ".cloudpullignore"
+
+	// maxIgnoreFileSize bounds the ignore files read from Drive
+	maxIgnoreFileSize = 1 << 20
+)
+
+// ignoreRule is one pattern line of an ignore file.
+type ignoreRule struct {
+	// pattern is a filepath.Match glob without leading or trailing slash
+	pattern string
+
+	// base is the folder path holding the ignore file; source is the path
+	// of the ignore file itself
+	base   string
+	source string
+
+	// anchored patterns match the path relative to base; others match the
+	// name of an entry at any depth
+	anchored bool
+	dirOnly  bool
+	negate   bool
+}
+
+// ignoreRules holds the rules of the ignore files from the walk root down
+// to a folder, outermost first. It is never modified once built, so
+// subfolders share the rules of their parent.
+type ignoreRules struct {
+	rules []ignoreRule
+}
+
+// parseIgnoreFile parses the content of the ignore file in the folder at
+// base. Blank lines and lines starting with # are skipped; a leading !
+// negates a pattern, a trailing / limits it to folders, and a / anywhere
+// else anchors it to base.
+func parseIgnoreFile(base string, content []byte) []ignoreRule {
+	source := filepath.Join(base, IgnoreFileName)
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base, source: source}
+		if negated, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate = true
+			line = negated
+		}
+		if dir, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly = true
+			line = dir
+		}
+		if anchored, ok := strings.CutPrefix(line, "/"); ok {
+			rule.anchored = true
+			line = anchored
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+		}
+
+		// Patterns are written with / but matched against local paths
+		rule.pattern = filepath.FromSlash(line)
+		if rule.pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(rule.pattern, ""); err != nil {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// with returns the rules extended by those of a nested ignore file.
+func (r *ignoreRules) with(rules []ignoreRule) *ignoreRules {
+	if len(rules) == 0 {
+		return r
+	}
+
+	combined := make([]ignoreRule, 0, len(r.rules)+len(rules))
+	combined = append(combined, r.rules...)
+	combined = append(combined, rules...)
+	return &ignoreRules{rules: combined}
+}
+
+// ignoredBy returns the ignore file whose rules leave out the entry at
+// path, or an empty string if it is not ignored. The last matching rule
+// decides, so nested ignore files override their parents.
+func (r *ignoreRules) ignoredBy(path string, isDir bool) string {
+	source := ""
+	for _, rule := range r.rules {
+		if rule.matches(path, isDir) {
+			source = rule.source
+			if rule.negate {
+				source = ""
+			}
+		}
+	}
+
+	return source
+}
+
+// matches reports whether the rule applies to the entry at path.
+func (rule *ignoreRule) matches(path string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(rule.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	if !rule.anchored {
+		rel = filepath.Base(rel)
+	}
+	matched, _ := filepath.Match(rule.pattern, rel)
+	return matched
+}
+
+// loadIgnoreRules returns the rules that apply inside folder. It is used
+// when a walk continues from recorded folders, whose parents' ignore files
+// were read by an earlier run.
+func (fw *FolderWalker) loadIgnoreRules(folder *state.Folder) (*ignoreRules, error) {
+	rules := &ignoreRules{}
+
+	ancestors, err := fw.stateManager.Folders().GetPath(fw.ctx, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ancestor := range ancestors {
+		// The folder's own ignore file is read when it is listed
+		if ancestor.ID == folder.ID {
+			continue
+		}
+
+		files, err := fw.stateManager.Files().GetByFolder(fw.ctx, ancestor.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.Name != IgnoreFileName {
+				continue
+			}
+
+			content, err := fw.client.ReadFile(fw.ctx, file.DriveID, maxIgnoreFileSize)
+			if err != nil {
+				return nil, err
+			}
+			rules = rules.with(parseIgnoreFile(ancestor.Path, content))
+		}
+	}
+
+	return rules, nil
+}
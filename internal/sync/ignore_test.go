@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	parent := (&ignoreRules{}).with(parseIgnoreFile("root", []byte(`
+# comment
+*.log
+build/
+/notes/*.txt
+`)))
+	rules := parent.with(parseIgnoreFile("root/sub", []byte("!keep.log\n")))
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  string
+	}{
+		{"root/a.log", false, "root/.cloudpullignore"},
+		{"root/deep/down/a.log", false, "root/.cloudpullignore"},
+		{"root/sub/keep.log", false, ""},
+		{"root/keep.log", false, "root/.cloudpullignore"},
+		{"root/build", true, "root/.cloudpullignore"},
+		{"root/build", false, ""},
+		{"root/notes/a.txt", false, "root/.cloudpullignore"},
+		{"root/sub/notes/a.txt", false, ""},
+		{"root/notes/deeper/a.txt", false, ""},
+		{"other/a.log", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, rules.ignoredBy(tt.path, tt.isDir))
+		})
+	}
+}
+
+func TestEngineHonorsNestedIgnoreFiles(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "root-ignore", Name: IgnoreFileName, MimeType: "text/plain", Size: 6},
+			{Id: "file-keep", Name: "keep.txt", MimeType: "text/plain", Size: 10},
+			{Id: "file-debug", Name: "debug.log", MimeType: "text/plain", Size: 10},
+			{Id: "folder-sub", Name: "sub", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"folder-sub": {
+			{Id: "sub-ignore", Name: IgnoreFileName, MimeType: "text/plain", Size: 40},
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10},
+			{Id: "file-bak", Name: "b.bak", MimeType: "text/plain", Size: 10},
+			{Id: "file-important", Name: "important.log", MimeType: "text/plain", Size: 10},
+			{Id: "file-trace", Name: "trace.log", MimeType: "text/plain", Size: 10},
+			{Id: "file-secret", Name: "secret.txt", MimeType: "text/plain", Size: 10},
+			{Id: "folder-drafts", Name: "drafts", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"folder-drafts": {
+			{Id: "file-draft", Name: "draft.txt", MimeType: "text/plain", Size: 10},
+		},
+	}
+	contents := map[string]string{
+		"root-ignore": "*.log\n",
+		"sub-ignore":  "/drafts/\n*.bak\n!important.log\n!secret.txt\n",
+	}
+
+	var downloaded []string
+	var mu sync.Mutex
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		mu.Lock()
+		downloaded = append(downloaded, file.Path)
+		mu.Unlock()
+		return file.Size, nil
+	})
+	engine.client = newFakeDriveClientWithContent(t, children, contents, nil)
+	engine.config.WalkerConfig.RespectIgnoreFiles = true
+	engine.config.WalkerConfig.ExcludePatterns = []string{`secret\.txt$`}
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	assert.ElementsMatch(t, []string{
+		"root/" + IgnoreFileName,
+		"root/keep.txt",
+		"root/sub/" + IgnoreFileName,
+		"root/sub/a.txt",
+		"root/sub/important.log",
+	}, downloaded)
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	skipped := make(map[string]string)
+	for _, file := range files {
+		if file.Status == state.FileStatusSkipped {
+			skipped[file.Path] = file.ErrorMessage.String
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"root/debug.log":      "ignored by root/" + IgnoreFileName,
+		"root/sub/b.bak":      "ignored by root/sub/" + IgnoreFileName,
+		"root/sub/trace.log":  "ignored by root/" + IgnoreFileName,
+		"root/sub/secret.txt": `excluded by pattern secret\.txt$`,
+	}, skipped)
+
+	// The ignored folder is never listed
+	folders, err := m.Folders().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	for _, folder := range folders {
+		assert.NotEqual(t, "drafts", folder.Name)
+	}
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+}
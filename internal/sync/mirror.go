@@ -0,0 +1,374 @@
+/**
+ * Mirror Reconciliation for CloudPull Sync Engine
+ *
+ * Features:
+ * - Removes local files and folders that no longer exist in Drive
+ * - Runs only after a complete folder scan of the session
+ * - Trash directory by default, with permanent deletion on request
+ * - Trash older than the retention period is pruned on the next run
+ * - Dry run mode and a per-session deletion log for auditing
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+	"github.com/VatsalSy/CloudPull/internal/util"
+)
+
+// TrashDirName is the trash directory created in the destination when no
+// other trash directory is configured.
+const TrashDirName = ".cloudpull-trash"
+
+// DefaultTrashRetention is how long the destination's trash is kept when no
+// retention is configured.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// MirrorConfig controls the removal of local entries that are no longer
+// part of the synced Drive folder.
+type MirrorConfig struct {
+	// Entries are moved below TrashDir/<session id> instead of being
+	// deleted; empty uses TrashDirName in the destination
+	TrashDir string
+
+	// TrashRetention is how long trashed sessions are kept before the
+	// next mirror run deletes them (0 = forever)
+	TrashRetention time.Duration
+
+	// Enabled turns on the reconciliation after a complete sync
+	Enabled bool
+
+	// DryRun only records what would be removed
+	DryRun bool
+
+	// PermanentDelete deletes entries instead of moving them to the trash
+	PermanentDelete bool
+}
+
+// mirrorReconciler removes the local entries of one session that the
+// session's files and folders do not account for.
+type mirrorReconciler struct {
+	engine   *Engine
+	session  *state.Session
+	config   *MirrorConfig
+	expected map[string]bool // local paths of the session's files
+	folders  map[string]bool // local directories of the session's folders
+
+	// pruneFolders allows removing directories without a folder record;
+	// with folder filters such directories may just be excluded
+	pruneFolders bool
+	trashDir     string
+	trashDirMode os.FileMode
+	trashTouched bool
+
+	removedFiles   int
+	removedFolders int
+}
+
+// reconcileMirror removes local files and folders below the session
+// destination that are not part of the session's Drive tree. It does
+// nothing unless mirroring is enabled and every folder was scanned.
+func (e *Engine) reconcileMirror(ctx context.Context) {
+	e.mu.RLock()
+	config := e.config.Mirror
+	session := e.currentSession
+	walkerConfig := e.config.WalkerConfig
+	downloader := e.downloader
+	var exportFormats map[string][]string
+	if e.config.DownloadConfig != nil {
+		exportFormats = e.config.DownloadConfig.ExportFormats
+	}
+	e.mu.RUnlock()
+
+	if config == nil || !config.Enabled || session == nil {
+		return
+	}
+	if walkerConfig != nil && walkerConfig.StarredOnly {
+		// Unstarred files are never listed, so they would all look deleted
+		e.logger.Warn("Skipping mirror cleanup; only starred files were synced")
+		return
+	}
+
+	var trashDir string
+	if !config.PermanentDelete {
+		trashDir = config.TrashDir
+		if trashDir == "" {
+			trashDir = filepath.Join(session.DestinationPath, TrashDirName)
+		}
+		var err error
+		if trashDir, err = filepath.Abs(trashDir); err != nil {
+			e.logger.Error(err, "Invalid mirror trash directory", "trash_dir", trashDir)
+			return
+		}
+		if config.TrashRetention > 0 && !config.DryRun {
+			e.pruneTrash(ctx, trashDir, time.Now().Add(-config.TrashRetention))
+		}
+	}
+
+	folders, err := e.stateManager.Folders().GetBySession(ctx, e.sessionID)
+	if err != nil {
+		e.logger.Error(err, "Failed to load folders for mirror cleanup")
+		return
+	}
+	if len(folders) == 0 {
+		return
+	}
+	for _, folder := range folders {
+		// A folder that was not listed completely would look empty
+		if folder.Status != state.FolderStatusScanned && folder.Status != state.FolderStatusSkipped {
+			e.logger.Warn("Skipping mirror cleanup; the folder scan is incomplete",
+				"folder_path", folder.Path,
+				"status", folder.Status,
+			)
+			return
+		}
+	}
+
+	files, err := e.stateManager.Files().GetBySession(ctx, e.sessionID)
+	if err != nil {
+		e.logger.Error(err, "Failed to load files for mirror cleanup")
+		return
+	}
+
+	trashDirMode := util.DefaultDirMode
+	if downloader != nil {
+		trashDirMode = downloader.dirMode
+	}
+
+	r := &mirrorReconciler{
+		engine:       e,
+		session:      session,
+		config:       config,
+		expected:     make(map[string]bool, len(files)),
+		folders:      make(map[string]bool, len(folders)),
+		trashDir:     trashDir,
+		trashDirMode: trashDirMode,
+	}
+
+	for _, file := range files {
+		paths := []string{LocalFilePath(session, file)}
+		// Files never downloaded by this session keep their planned name
+		if !file.LocalPath.Valid && downloader != nil {
+			if rel, err := downloader.plannedRelPath(ctx, session, file); err == nil && rel != "" {
+				paths = append(paths, filepath.Join(session.DestinationPath, rel))
+			}
+		}
+		for _, path := range paths {
+			r.expected[path] = true
+			for _, format := range extraExportFormats(exportFormats, file) {
+				r.expected[exportSiblingPath(file, path, format)] = true
+			}
+		}
+	}
+
+	// Flattened sessions only own the files directly in the destination
+	if session.Flatten {
+		r.folders[session.DestinationPath] = true
+	} else {
+		for _, folder := range folders {
+			// Skipped folders were never listed, so their content is unknown
+			if folder.Status == state.FolderStatusScanned {
+				r.folders[filepath.Join(session.DestinationPath, folder.Path)] = true
+			}
+		}
+		r.pruneFolders = walkerConfig == nil ||
+			(len(walkerConfig.IncludePatterns) == 0 && len(walkerConfig.ExcludePatterns) == 0 &&
+				walkerConfig.MaxDepth <= 0 && !walkerConfig.RespectIgnoreFiles && !walkerConfig.SkipHidden)
+	}
+
+	// Path templates, category folders and shortened names create
+	// directories that have no folder record
+	for path := range r.expected {
+		r.addParentDirs(path)
+	}
+
+	dirs := make([]string, 0, len(r.folders))
+	for dir := range r.folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := r.cleanDir(ctx, dir); err != nil {
+			e.logger.Error(err, "Mirror cleanup failed", "dir", dir)
+			return
+		}
+	}
+
+	e.logger.Info("Mirror cleanup finished",
+		"removed_files", r.removedFiles,
+		"removed_folders", r.removedFolders,
+		"dry_run", config.DryRun,
+	)
+}
+
+// addParentDirs marks the directories between the session destination and
+// path as owned by the session.
+func (r *mirrorReconciler) addParentDirs(path string) {
+	prefix := r.session.DestinationPath + string(filepath.Separator)
+	for dir := filepath.Dir(path); strings.HasPrefix(dir, prefix) && !r.folders[dir]; dir = filepath.Dir(dir) {
+		r.folders[dir] = true
+	}
+}
+
+// cleanDir removes the entries of dir that the session does not expect.
+// Subdirectories with a folder record are cleaned on their own.
+func (r *mirrorReconciler) cleanDir(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to read %s", dir)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if r.trashDir != "" && path == r.trashDir {
+			continue
+		}
+		if dir == r.session.DestinationPath && (entry.Name() == ReportFileName || entry.Name() == CASDirName) {
+			continue
+		}
+
+		if entry.IsDir() {
+			if r.folders[path] || !r.pruneFolders {
+				continue
+			}
+			if err := r.remove(ctx, path, "folder"); err != nil {
+				return err
+			}
+			r.removedFolders++
+			continue
+		}
+
+		if r.expected[path] {
+			continue
+		}
+		if err := r.remove(ctx, path, "file"); err != nil {
+			return err
+		}
+		r.removedFiles++
+	}
+
+	return nil
+}
+
+// remove deletes or trashes path and records it in the deletion log.
+func (r *mirrorReconciler) remove(ctx context.Context, path, kind string) error {
+	deletion := &state.MirrorDeletion{
+		SessionID: r.session.ID,
+		Path:      path,
+		Kind:      kind,
+		DryRun:    r.config.DryRun,
+	}
+
+	if r.trashDir != "" {
+		rel, err := filepath.Rel(r.session.DestinationPath, path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve %s", path)
+		}
+		deletion.TrashPath = state.NewNullString(filepath.Join(r.trashDir, r.session.ID, rel))
+	}
+
+	if !r.config.DryRun {
+		var err error
+		switch {
+		case deletion.TrashPath.Valid:
+			if err = os.MkdirAll(filepath.Dir(deletion.TrashPath.String), r.trashDirMode); err == nil {
+				err = os.Rename(path, deletion.TrashPath.String)
+			}
+			if err == nil && !r.trashTouched {
+				// Retention counts from the last run that trashed something
+				now := time.Now()
+				sessionTrash := filepath.Join(r.trashDir, r.session.ID)
+				if err := os.Chtimes(sessionTrash, now, now); err != nil {
+					r.engine.logger.Warn("Failed to stamp trash directory", "path", sessionTrash, "error", err)
+				}
+				r.trashTouched = true
+			}
+		case kind == "folder":
+			err = os.RemoveAll(path)
+		default:
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", path)
+		}
+	}
+
+	msg := "Removed local entry missing from Drive"
+	if r.config.DryRun {
+		msg = "Would remove local entry missing from Drive"
+	}
+	r.engine.logger.Info(msg,
+		"path", path,
+		"kind", kind,
+		"trash_path", deletion.TrashPath.String,
+	)
+
+	if err := r.engine.stateManager.RecordMirrorDeletion(ctx, deletion); err != nil {
+		r.engine.logger.Error(err, "Failed to record mirror deletion", "path", path)
+	}
+
+	return nil
+}
+
+// pruneTrash deletes the session directories of trashDir that nothing was
+// moved into since cutoff. Other directories are left alone, since the
+// trash directory may be shared with other data.
+func (e *Engine) pruneTrash(ctx context.Context, trashDir string, cutoff time.Time) {
+	entries, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		e.logger.Error(err, "Failed to read mirror trash", "trash_dir", trashDir)
+		return
+	}
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !entry.IsDir() || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(trashDir, entry.Name())
+		if !e.isSessionTrash(ctx, entry.Name(), path) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			e.logger.Error(err, "Failed to prune mirror trash", "path", path)
+			continue
+		}
+		e.logger.Info("Pruned expired mirror trash", "path", path, "trashed_at", info.ModTime())
+	}
+}
+
+// isSessionTrash reports whether path is where a mirror run of the session
+// named sessionID moved entries to.
+func (e *Engine) isSessionTrash(ctx context.Context, sessionID, path string) bool {
+	deletions, err := e.stateManager.GetMirrorDeletions(ctx, sessionID)
+	if err != nil {
+		e.logger.Warn("Failed to load mirror deletions", "session_id", sessionID, "error", err)
+		return false
+	}
+
+	prefix := path + string(filepath.Separator)
+	for _, deletion := range deletions {
+		if deletion.TrashPath.Valid && strings.HasPrefix(deletion.TrashPath.String, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// runMirrorSync syncs a small Drive tree into a destination that also holds
+// entries Drive no longer has, and returns the destination and session.
+func runMirrorSync(t *testing.T, m *state.Manager, mirror *MirrorConfig) (string, string) {
+	t.Helper()
+
+	return runMirrorSyncWith(t, m, mirror, nil)
+}
+
+// runMirrorSyncWith is runMirrorSync, except that configure, if set, is
+// called on the engine before the session starts.
+func runMirrorSyncWith(t *testing.T, m *state.Manager, mirror *MirrorConfig, configure func(*Engine)) (string, string) {
+	t.Helper()
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "keep", Name: "keep.txt", MimeType: "text/plain", Size: 4},
+			{Id: "docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"docs": {
+			{Id: "a", Name: "a.txt", MimeType: "text/plain", Size: 4},
+		},
+	}
+
+	dest := t.TempDir()
+	for _, path := range []string{
+		"root/keep.txt", "root/stale.txt", "root/docs/a.txt", "root/docs/old.txt",
+		"root/gone/x.txt", "unrelated.txt",
+	} {
+		path = filepath.Join(dest, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.Mirror = mirror
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+	if configure != nil {
+		configure(engine)
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	return dest, sessionID
+}
+
+func TestMirrorRemovesEntriesMissingFromDrive(t *testing.T) {
+	m := newTestStateManager(t)
+	trash := t.TempDir()
+	dest, sessionID := runMirrorSync(t, m, &MirrorConfig{Enabled: true, TrashDir: trash})
+
+	for _, path := range []string{"root/keep.txt", "root/docs/a.txt", "unrelated.txt"} {
+		assert.FileExists(t, filepath.Join(dest, path))
+	}
+	assert.NoFileExists(t, filepath.Join(dest, "root/stale.txt"))
+	assert.NoFileExists(t, filepath.Join(dest, "root/docs/old.txt"))
+	assert.NoDirExists(t, filepath.Join(dest, "root/gone"))
+
+	// Trashed entries keep their place in the tree
+	assert.FileExists(t, filepath.Join(trash, sessionID, "root/stale.txt"))
+	assert.FileExists(t, filepath.Join(trash, sessionID, "root/gone/x.txt"))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	removed := make(map[string]string)
+	for _, deletion := range deletions {
+		assert.False(t, deletion.DryRun)
+		removed[deletion.Path] = deletion.Kind
+	}
+	assert.Equal(t, map[string]string{
+		filepath.Join(dest, "root/stale.txt"):    "file",
+		filepath.Join(dest, "root/docs/old.txt"): "file",
+		filepath.Join(dest, "root/gone"):         "folder",
+	}, removed)
+}
+
+func TestMirrorMovesEntriesToDestinationTrashByDefault(t *testing.T) {
+	m := newTestStateManager(t)
+	dest, sessionID := runMirrorSync(t, m, &MirrorConfig{Enabled: true})
+
+	assert.NoFileExists(t, filepath.Join(dest, "root/stale.txt"))
+	trash := filepath.Join(dest, TrashDirName, sessionID)
+	assert.FileExists(t, filepath.Join(trash, "root/stale.txt"))
+	assert.FileExists(t, filepath.Join(trash, "root/docs/old.txt"))
+	assert.FileExists(t, filepath.Join(trash, "root/gone/x.txt"))
+}
+
+func TestMirrorPermanentDeleteSkipsTrash(t *testing.T) {
+	m := newTestStateManager(t)
+	dest, sessionID := runMirrorSync(t, m, &MirrorConfig{Enabled: true, PermanentDelete: true})
+
+	assert.NoFileExists(t, filepath.Join(dest, "root/stale.txt"))
+	assert.NoDirExists(t, filepath.Join(dest, "root/gone"))
+	assert.NoDirExists(t, filepath.Join(dest, TrashDirName))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	require.Len(t, deletions, 3)
+	for _, deletion := range deletions {
+		assert.False(t, deletion.TrashPath.Valid)
+	}
+}
+
+func TestMirrorPrunesExpiredTrash(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	trash := t.TempDir()
+
+	// Trash of two earlier sessions, one past the retention period, and an
+	// old directory that mirror runs did not create
+	dirs := make(map[string]string)
+	for name, age := range map[string]time.Duration{
+		"expired": 40 * 24 * time.Hour, "recent": time.Hour, "unrelated": 40 * 24 * time.Hour,
+	} {
+		dirName := name
+		if name != "unrelated" {
+			session, err := m.CreateSession(ctx, "root", "Root", t.TempDir())
+			require.NoError(t, err)
+			dirName = session.ID
+			require.NoError(t, m.RecordMirrorDeletion(ctx, &state.MirrorDeletion{
+				SessionID: session.ID,
+				Path:      "old.txt",
+				Kind:      "file",
+				TrashPath: state.NewNullString(filepath.Join(trash, session.ID, "old.txt")),
+			}))
+		}
+
+		dir := filepath.Join(trash, dirName)
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("data"), 0600))
+		stamp := time.Now().Add(-age)
+		require.NoError(t, os.Chtimes(dir, stamp, stamp))
+		dirs[name] = dir
+	}
+
+	_, sessionID := runMirrorSync(t, m, &MirrorConfig{
+		Enabled:        true,
+		TrashDir:       trash,
+		TrashRetention: 30 * 24 * time.Hour,
+	})
+
+	assert.NoDirExists(t, dirs["expired"])
+	assert.FileExists(t, filepath.Join(dirs["recent"], "old.txt"))
+	assert.FileExists(t, filepath.Join(dirs["unrelated"], "old.txt"))
+	assert.FileExists(t, filepath.Join(trash, sessionID, "root/stale.txt"))
+}
+
+func TestMirrorDryRunOnlyRecordsDeletions(t *testing.T) {
+	m := newTestStateManager(t)
+	dest, sessionID := runMirrorSync(t, m, &MirrorConfig{Enabled: true, DryRun: true})
+
+	assert.FileExists(t, filepath.Join(dest, "root/stale.txt"))
+	assert.FileExists(t, filepath.Join(dest, "root/gone/x.txt"))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	require.Len(t, deletions, 3)
+	for _, deletion := range deletions {
+		assert.True(t, deletion.DryRun)
+	}
+}
+
+func TestMirrorDisabledByDefault(t *testing.T) {
+	m := newTestStateManager(t)
+	dest, sessionID := runMirrorSync(t, m, nil)
+
+	assert.FileExists(t, filepath.Join(dest, "root/stale.txt"))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Empty(t, deletions)
+}
+
+func TestMirrorKeepsFilesOfTemplateAndCategoryLayouts(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "a", Name: "a.txt", MimeType: "text/plain", Size: 4},
+			{Id: "photos", Name: "photos", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"photos": {
+			{Id: "b", Name: "b.jpg", MimeType: "image/jpeg", Size: 4},
+		},
+	}
+
+	tmpl, err := ParsePathTemplate("{{.DriveName}}/{{.MimeCategory}}/{{.FileName}}")
+	require.NoError(t, err)
+
+	for name, configure := range map[string]func(cfg *DownloadManagerConfig){
+		"template": func(cfg *DownloadManagerConfig) { cfg.PathTemplate = tmpl },
+		"category": func(cfg *DownloadManagerConfig) { cfg.OrganizeByCategory = true },
+	} {
+		t.Run(name, func(t *testing.T) {
+			log := newTestLogger()
+			m := newTestStateManager(t)
+			cfg := DefaultEngineConfig()
+			cfg.DownloadConfig.TempDir = t.TempDir()
+			cfg.Mirror = &MirrorConfig{Enabled: true, PermanentDelete: true}
+			configure(cfg.DownloadConfig)
+			engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+			require.NoError(t, err)
+
+			dest := t.TempDir()
+			sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+			require.NoError(t, err)
+
+			select {
+			case <-engine.WaitForCompletion():
+			case <-time.After(30 * time.Second):
+				t.Fatal("sync engine did not terminate")
+			}
+
+			files, err := m.Files().GetBySession(context.Background(), sessionID)
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			for _, file := range files {
+				assert.FileExists(t, file.LocalPath.String, file.Path)
+			}
+
+			deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+			require.NoError(t, err)
+			assert.Empty(t, deletions)
+		})
+	}
+}
+
+func TestMirrorSkippedForStarredOnlySync(t *testing.T) {
+	m := newTestStateManager(t)
+	dest, sessionID := runMirrorSyncWith(t, m, &MirrorConfig{Enabled: true, PermanentDelete: true},
+		func(engine *Engine) { engine.SetStarredOnly(true) })
+
+	assert.FileExists(t, filepath.Join(dest, "root/stale.txt"))
+	assert.FileExists(t, filepath.Join(dest, "root/gone/x.txt"))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Empty(t, deletions)
+}
+
+func TestMirrorKeepsHiddenEntriesSkippedBySync(t *testing.T) {
+	m := newTestStateManager(t)
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "keep", Name: "keep.txt", MimeType: "text/plain", Size: 4},
+			{Id: "env", Name: ".env", MimeType: "text/plain", Size: 4},
+			{Id: "git", Name: ".git", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"git": {{Id: "head", Name: "HEAD", MimeType: "text/plain", Size: 4}},
+	}
+
+	dest := t.TempDir()
+	for _, path := range []string{"root/keep.txt", "root/stale.txt", "root/.git/HEAD", "root/.env"} {
+		path = filepath.Join(dest, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+		require.NoError(t, os.WriteFile(path, []byte("data"), 0600))
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.Mirror = &MirrorConfig{Enabled: true, PermanentDelete: true}
+	cfg.WalkerConfig.SkipHidden = true
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// Hidden entries were left out of the sync, so their local copies stay
+	assert.FileExists(t, filepath.Join(dest, "root/.git/HEAD"))
+	assert.FileExists(t, filepath.Join(dest, "root/.env"))
+	assert.NoFileExists(t, filepath.Join(dest, "root/stale.txt"))
+
+	deletions, err := m.GetMirrorDeletions(context.Background(), sessionID)
+	require.NoError(t, err)
+	for _, deletion := range deletions {
+		assert.NotContains(t, deletion.Path, "/.")
+	}
+}
@@ -0,0 +1,103 @@
+/**
+ * Local Path Length Limits for CloudPull Sync Engine
+ *
+ * Features:
+ * - Shortens path elements longer than most filesystems accept
+ * - Optional limit on the length of the whole local path
+ * - Keeps extensions and adds a short hash so shortened names stay unique
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+const (
+	// maxNameLength is the longest path element, in bytes, that common
+	// filesystems accept.
+	maxNameLength = 255
+
+	// shortNameHashLength is the number of hex digits of the hash added to
+	// shortened names.
+	shortNameHashLength = 8
+
+	// maxKeptExtension is the longest extension kept when shortening a name;
+	// longer ones are treated as part of the name.
+	maxKeptExtension = 16
+)
+
+// shortenPath joins rel below dest, shortening elements of rel longer than
+// maxNameLength and, when the result is longer than maxPathLength bytes, the
+// file name. A maxPathLength of 0 means no limit. The second result reports
+// whether anything was shortened.
+func shortenPath(dest, rel string, maxPathLength int) (string, bool, error) {
+	elems := strings.Split(filepath.Clean(rel), string(filepath.Separator))
+	name := elems[len(elems)-1]
+
+	shortened := false
+	for i, elem := range elems {
+		if len(elem) > maxNameLength {
+			elems[i] = shortenName(elem, maxNameLength)
+			shortened = true
+		}
+	}
+
+	path := filepath.Join(dest, filepath.Join(elems...))
+	if maxPathLength <= 0 || len(path) <= maxPathLength {
+		return path, shortened, nil
+	}
+
+	dir := filepath.Dir(path) + string(filepath.Separator)
+	room := maxPathLength - len(dir)
+	if room < shortNameHashLength+2 {
+		return "", false, errors.Errorf("directory %s leaves no room for %s within %d bytes", dir, name, maxPathLength)
+	}
+
+	return dir + shortenName(name, room), true, nil
+}
+
+// shortenName cuts name to at most limit bytes, keeping its extension and
+// adding a hash of the full name, e.g. "very long na~1a2b3c4d.pdf".
+func shortenName(name string, limit int) string {
+	if len(name) <= limit {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if base == "" || len(ext) > maxKeptExtension {
+		base, ext = name, ""
+	}
+
+	sum := sha1.Sum([]byte(name))
+	suffix := "~" + hex.EncodeToString(sum[:])[:shortNameHashLength]
+
+	keep := limit - len(suffix) - len(ext)
+	if keep < 1 {
+		ext = ""
+		keep = limit - len(suffix)
+	}
+
+	return truncateUTF8(base, keep) + suffix + ext
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
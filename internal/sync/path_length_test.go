@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestShortenPathLongNames(t *testing.T) {
+	longName := strings.Repeat("a", 300) + ".pdf"
+
+	path, shortened, err := shortenPath("/dest", filepath.Join("root", longName), 0)
+	require.NoError(t, err)
+	assert.True(t, shortened)
+
+	name := filepath.Base(path)
+	assert.Len(t, name, maxNameLength)
+	assert.True(t, strings.HasSuffix(name, ".pdf"))
+	assert.Equal(t, "/dest/root", filepath.Dir(path))
+
+	// Names differing only past the cut stay distinct
+	other, _, err := shortenPath("/dest", filepath.Join("root", strings.Repeat("a", 300)+"b.pdf"), 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, path, other)
+
+	// Long folder names are shortened the same way for every file in them
+	longDir := strings.Repeat("é", 200)
+	first, _, err := shortenPath("/dest", filepath.Join("root", longDir, "a.txt"), 0)
+	require.NoError(t, err)
+	second, _, err := shortenPath("/dest", filepath.Join("root", longDir, "b.txt"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Dir(first), filepath.Dir(second))
+	assert.LessOrEqual(t, len(filepath.Base(filepath.Dir(first))), maxNameLength)
+	assert.True(t, utf8.ValidString(first))
+
+	path, shortened, err = shortenPath("/dest", "root/short.txt", 0)
+	require.NoError(t, err)
+	assert.False(t, shortened)
+	assert.Equal(t, "/dest/root/short.txt", path)
+}
+
+func TestShortenPathDeeplyNested(t *testing.T) {
+	rel := filepath.Join(strings.Repeat("level/", 12), "quarterly report final version.xlsx")
+
+	path, shortened, err := shortenPath("/dest", rel, 100)
+	require.NoError(t, err)
+	assert.True(t, shortened)
+	assert.Len(t, path, 100)
+	assert.True(t, strings.HasPrefix(path, "/dest/"+strings.Repeat("level/", 12)+"quar"))
+	assert.True(t, strings.HasSuffix(path, ".xlsx"))
+
+	// Folders alone over the limit leave nothing to shorten
+	_, _, err = shortenPath("/dest", filepath.Join(strings.Repeat("level/", 20), "a.txt"), 100)
+	assert.Error(t, err)
+}
+
+func TestLocalPathRecordsShortenedPath(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	session, err := m.CreateSession(ctx, "root-id", "Root", dest)
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "drive-root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+
+	name := strings.Repeat("x", 80) + ".txt"
+	long := &state.File{DriveID: "long", FolderID: folder.ID, SessionID: session.ID,
+		Name: name, Path: "root/" + name, Status: state.FileStatusPending}
+	short := &state.File{DriveID: "short", FolderID: folder.ID, SessionID: session.ID,
+		Name: "a.txt", Path: "root/a.txt", Status: state.FileStatusPending}
+	require.NoError(t, m.Files().CreateBatch(ctx, []*state.File{long, short}))
+
+	maxLength := len(dest) + 60
+	dm, err := NewDownloadManager(nil, m, NewProgressTracker(session.ID), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir(), MaxPathLength: maxLength})
+	require.NoError(t, err)
+
+	path, err := dm.localPath(ctx, session, long)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(path), maxLength)
+	assert.True(t, strings.HasSuffix(path, ".txt"))
+
+	// The file keeps its Drive name and records where it is stored
+	stored, err := m.Files().GetByDriveID(ctx, "long", session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, name, stored.Name)
+	assert.Equal(t, path, stored.LocalPath.String)
+
+	path, err = dm.localPath(ctx, session, short)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "root", "a.txt"), path)
+	assert.False(t, short.LocalPath.Valid)
+}
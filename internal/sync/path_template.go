@@ -0,0 +1,161 @@
+/**
+ * Destination Path Templates for CloudPull Sync Engine
+ *
+ * Features:
+ * - Local paths computed per file from a text/template
+ * - Drive name, folder path, file name, MIME category and account email
+ * - Paths are kept below the session destination
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// PathTemplate computes the local path of each file below the session
+// destination.
+type PathTemplate struct {
+	tmpl *template.Template
+	text string
+}
+
+// PathTemplateData holds the fields available to a path template.
+type PathTemplateData struct {
+	accountEmail func() (string, error)
+
+	// DriveName is the name of the synced Drive folder
+	DriveName string
+
+	// FolderPath is the folder of the file below the synced folder; empty
+	// for files directly inside it
+	FolderPath string
+
+	// FileName is the local file name, including any export extension
+	FileName string
+
+	// MimeCategory groups MIME types, e.g. "documents" or "images"
+	MimeCategory string
+}
+
+// AccountEmail returns the email address of the signed-in account. It is
+// only looked up when a template uses it.
+func (d *PathTemplateData) AccountEmail() (string, error) {
+	if d.accountEmail == nil {
+		return "", errors.Errorf("account email not available")
+	}
+	return d.accountEmail()
+}
+
+// ParsePathTemplate parses a path template such as
+// "{{.AccountEmail}}/{{.FolderPath}}/{{.FileName}}". An empty text returns
+// nil, which keeps the Drive layout.
+func ParsePathTemplate(text string) (*PathTemplate, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("path").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid path template")
+	}
+
+	t := &PathTemplate{tmpl: tmpl, text: text}
+
+	// Unknown fields only show up when the template runs
+	sample := &PathTemplateData{
+		accountEmail: func() (string, error) { return "user@example.com", nil },
+		DriveName:    "My Drive",
+		FolderPath:   "Projects",
+		FileName:     "report.pdf",
+		MimeCategory: api.MimeCategoryDocuments,
+	}
+	if _, err := t.Execute(sample); err != nil {
+		return nil, errors.Wrap(err, "invalid path template")
+	}
+
+	return t, nil
+}
+
+// String returns the template text.
+func (t *PathTemplate) String() string {
+	return t.text
+}
+
+// Execute returns the relative path the template yields for data. Empty
+// path elements are dropped and ".." cannot climb above the destination.
+func (t *PathTemplate) Execute(data *PathTemplateData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	rel := strings.TrimPrefix(filepath.Clean("/"+filepath.FromSlash(b.String())), string(filepath.Separator))
+	if rel == "" {
+		return "", errors.Errorf("path template %q yields an empty path", t.text)
+	}
+	return rel, nil
+}
+
+// pathTemplateData returns the template fields of file.
+func pathTemplateData(file *state.File, accountEmail func() (string, error)) *PathTemplateData {
+	data := &PathTemplateData{
+		accountEmail: accountEmail,
+		FileName:     exportFileName(file, sanitizeFileName(file.Name)),
+		MimeCategory: api.MimeCategory(file.MimeType.String),
+	}
+
+	// file.Path starts with the synced folder and ends with the file name
+	parts := strings.Split(filepath.Dir(file.Path), string(filepath.Separator))
+	if parts[0] != "." {
+		data.DriveName = parts[0]
+		data.FolderPath = filepath.Join(parts[1:]...)
+	}
+
+	return data
+}
+
+// templateRelPath returns the path of file below the destination that the
+// path template yields, before any collision suffix.
+func (dm *DownloadManager) templateRelPath(ctx context.Context, file *state.File) (string, error) {
+	rel, err := dm.pathTemplate.Execute(pathTemplateData(file, func() (string, error) {
+		return dm.accountEmail(ctx)
+	}))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to apply path template to %s", file.Path)
+	}
+	return rel, nil
+}
+
+// accountEmailCache remembers the account email once it was looked up.
+type accountEmailCache struct {
+	email string
+	mu    sync.Mutex
+}
+
+// accountEmail returns the email address of the signed-in account, asking
+// Drive the first time.
+func (dm *DownloadManager) accountEmail(ctx context.Context) (string, error) {
+	dm.emailCache.mu.Lock()
+	defer dm.emailCache.mu.Unlock()
+
+	if dm.emailCache.email == "" {
+		email, err := dm.client.GetAccountEmail(ctx)
+		if err != nil {
+			return "", err
+		}
+		dm.emailCache.email = email
+	}
+	return dm.emailCache.email, nil
+}
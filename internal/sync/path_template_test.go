@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestParsePathTemplate(t *testing.T) {
+	tmpl, err := ParsePathTemplate("")
+	require.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	_, err = ParsePathTemplate("{{.FileName")
+	assert.Error(t, err)
+
+	// Unknown fields are caught before any file is downloaded
+	_, err = ParsePathTemplate("{{.Owner}}/{{.FileName}}")
+	assert.Error(t, err)
+
+	tmpl, err = ParsePathTemplate("{{.MimeCategory}}/{{.FolderPath}}/../../../{{.FileName}}")
+	require.NoError(t, err)
+
+	file := &state.File{Name: "photo.jpg", Path: filepath.Join("root", "photo.jpg"), MimeType: state.NewNullString("image/jpeg")}
+	rel, err := tmpl.Execute(pathTemplateData(file, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "photo.jpg", rel, "paths never leave the destination")
+}
+
+func TestEngineAppliesPathTemplate(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "a", Name: "a.txt", MimeType: "text/plain", Size: 4},
+			{Id: "docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"docs": {
+			{Id: "b", Name: "b.txt", MimeType: "text/plain", Size: 4},
+		},
+	}
+
+	tmpl, err := ParsePathTemplate("{{.AccountEmail}}/{{.FolderPath}}/{{.FileName}}")
+	require.NoError(t, err)
+
+	log := newTestLogger()
+	m := newTestStateManager(t)
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.PathTemplate = tmpl
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	dest := t.TempDir()
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	assert.FileExists(t, filepath.Join(dest, "tester@example.com", "a.txt"))
+	assert.FileExists(t, filepath.Join(dest, "tester@example.com", "docs", "b.txt"))
+	assert.NoDirExists(t, filepath.Join(dest, "root"))
+
+	// The resolved paths are kept for verification and resumed sessions
+	files, err := m.Files().GetBySession(context.Background(), sessionID)
+	require.NoError(t, err)
+	paths := make(map[string]string)
+	for _, file := range files {
+		paths[file.DriveID] = file.LocalPath.String
+	}
+	assert.Equal(t, map[string]string{
+		"a": filepath.Join(dest, "tester@example.com", "a.txt"),
+		"b": filepath.Join(dest, "tester@example.com", "docs", "b.txt"),
+	}, paths)
+}
+
+func TestEngineOrganizesByCategory(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "a", Name: "a.txt", MimeType: "text/plain", Size: 4},
+			{Id: "photos", Name: "photos", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"photos": {
+			{Id: "b", Name: "b.jpg", MimeType: "image/jpeg", Size: 4},
+			{Id: "c", Name: "c.zip", MimeType: "application/zip", Size: 4},
+		},
+	}
+
+	log := newTestLogger()
+	m := newTestStateManager(t)
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.OrganizeByCategory = true
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	dest := t.TempDir()
+	_, err = engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	assert.FileExists(t, filepath.Join(dest, "documents", "root", "a.txt"))
+	assert.FileExists(t, filepath.Join(dest, "images", "root", "photos", "b.jpg"))
+	assert.FileExists(t, filepath.Join(dest, "archives", "root", "photos", "c.zip"))
+	assert.NoDirExists(t, filepath.Join(dest, "root"))
+}
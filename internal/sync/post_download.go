@@ -0,0 +1,193 @@
+/**
+ * Post-Download Hook for CloudPull Sync Engine
+ *
+ * Features:
+ * - Shell command run on every file after it reaches its destination
+ * - File details passed as an argument and in the environment
+ * - Per-file timeout and bounded hook concurrency
+ * - Failures either logged or marking the file failed without a retry
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// PostDownloadFailurePolicy decides what a failing post-download command
+// means for the file.
+type PostDownloadFailurePolicy string
+
+const (
+	// PostDownloadLog logs the failure and keeps the file completed.
+	PostDownloadLog PostDownloadFailurePolicy = "log"
+
+	// PostDownloadFail marks the file failed. The file stays in place and
+	// is not downloaded again, since that cannot change the command's
+	// verdict.
+	PostDownloadFail PostDownloadFailurePolicy = "fail"
+)
+
+// ParsePostDownloadFailurePolicy parses a policy name: log or fail. An
+// empty name selects log.
+func ParsePostDownloadFailurePolicy(name string) (PostDownloadFailurePolicy, error) {
+	switch policy := PostDownloadFailurePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return PostDownloadLog, nil
+	case PostDownloadLog, PostDownloadFail:
+		return policy, nil
+	default:
+		return PostDownloadLog, errors.Errorf("unknown post-download failure policy %q", name)
+	}
+}
+
+// PostDownloadConfig contains configuration for the post-download hook.
+type PostDownloadConfig struct {
+	// Shell command run for each downloaded file; empty disables the hook
+	Command string
+
+	// What a non-zero exit or timeout means for the file
+	OnFailure PostDownloadFailurePolicy
+
+	// Timeout for a single run of the command
+	Timeout time.Duration
+
+	// Maximum commands running at once across all workers
+	MaxConcurrent int
+}
+
+// DefaultPostDownloadConfig returns default post-download hook configuration.
+func DefaultPostDownloadConfig() *PostDownloadConfig {
+	return &PostDownloadConfig{
+		OnFailure:     PostDownloadLog,
+		Timeout:       60 * time.Second,
+		MaxConcurrent: 2,
+	}
+}
+
+// postDownloadHook runs the post-download command with bounded concurrency.
+type postDownloadHook struct {
+	config *PostDownloadConfig
+	slots  chan struct{}
+}
+
+// newPostDownloadHook creates a hook, or returns nil when no command is
+// configured.
+func newPostDownloadHook(config *PostDownloadConfig) *postDownloadHook {
+	if config == nil || strings.TrimSpace(config.Command) == "" {
+		return nil
+	}
+
+	defaults := DefaultPostDownloadConfig()
+	hookConfig := *config
+	if hookConfig.OnFailure == "" {
+		hookConfig.OnFailure = defaults.OnFailure
+	}
+	if hookConfig.Timeout <= 0 {
+		hookConfig.Timeout = defaults.Timeout
+	}
+	if hookConfig.MaxConcurrent <= 0 {
+		hookConfig.MaxConcurrent = defaults.MaxConcurrent
+	}
+
+	return &postDownloadHook{
+		config: &hookConfig,
+		slots:  make(chan struct{}, hookConfig.MaxConcurrent),
+	}
+}
+
+// run executes the command for a file stored at path. It waits for a free
+// slot first, so a slow command delays only the workers that finish while
+// every slot is busy.
+func (h *postDownloadHook) run(ctx context.Context, file *state.File, path string) error {
+	select {
+	case h.slots <- struct{}{}:
+		defer func() { <-h.slots }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.config.Timeout)
+	defer cancel()
+
+	// The path is also passed as $1 so commands can use it without quoting
+	// concerns; cmd.exe only gets the environment variable
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		// #nosec G204 - the command comes from the user's own configuration
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.config.Command)
+	} else {
+		// #nosec G204 - the command comes from the user's own configuration
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.config.Command, "cloudpull", path)
+	}
+	// Children of the shell may hold the output open after it is killed
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"CLOUDPULL_FILE_PATH="+path,
+		"CLOUDPULL_FILE_ID="+file.ID,
+		"CLOUDPULL_DRIVE_ID="+file.DriveID,
+		"CLOUDPULL_DRIVE_PATH="+file.Path,
+		"CLOUDPULL_SESSION_ID="+file.SessionID,
+	)
+
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.Errorf("post-download command timed out after %s", h.config.Timeout)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "post-download command output: %s", bytes.TrimSpace(output))
+	}
+
+	return nil
+}
+
+// runPostDownloadHook runs the post-download command for a downloaded file.
+// Failures are recorded in the error log; the error is only returned when
+// the failure policy fails the file.
+func (dm *DownloadManager) runPostDownloadHook(ctx context.Context, log *logger.Logger, file *state.File, path string) error {
+	if dm.postDownload == nil {
+		return nil
+	}
+
+	err := dm.postDownload.run(ctx, file, path)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	log.Error(err, "Post-download command failed",
+		"file_id", file.ID,
+		"path", path,
+		"policy", string(dm.postDownload.config.OnFailure),
+	)
+	if logErr := dm.stateManager.LogError(ctx, file.SessionID, file.ID, "file", "post_download_hook", err); logErr != nil {
+		log.Error(logErr, "Failed to record post-download failure", "file_id", file.ID)
+	}
+
+	if dm.postDownload.config.OnFailure == PostDownloadFail {
+		return errors.New(errors.ErrorTypeConfiguration, "post_download_hook", path, err)
+	}
+	return nil
+}
+
+// isPostDownloadFailure reports whether err is a post-download command
+// failure that fails the file. The worker does not retry such files.
+func isPostDownloadFailure(err error) bool {
+	var typed *errors.Error
+	return errors.AsError(err, &typed) && typed.Op == "post_download_hook"
+}
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// newPostDownloadTestManager creates a download manager running command
+// after each download, plus a session to log errors against.
+func newPostDownloadTestManager(t *testing.T, config *PostDownloadConfig) (*DownloadManager, *state.File) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("post-download tests use sh")
+	}
+
+	m := newTestStateManager(t)
+	session, err := m.CreateSession(context.Background(), "root-id", "Root", t.TempDir())
+	require.NoError(t, err)
+
+	dm, err := NewDownloadManager(nil, m, NewProgressTracker(session.ID), nil, newTestLogger(),
+		&DownloadManagerConfig{TempDir: t.TempDir(), PostDownload: config})
+	require.NoError(t, err)
+
+	file := &state.File{ID: "file-1", DriveID: "drive-1", SessionID: session.ID, Path: "root/a.txt"}
+	return dm, file
+}
+
+func TestPostDownloadHookReceivesFilePath(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	dm, file := newPostDownloadTestManager(t, &PostDownloadConfig{
+		Command: `printf '%s|%s|%s' "$1" "$CLOUDPULL_FILE_PATH" "$CLOUDPULL_DRIVE_PATH" > ` + out,
+	})
+
+	require.NoError(t, dm.runPostDownloadHook(context.Background(), dm.logger, file, "/dest/root/a b.txt"))
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "/dest/root/a b.txt|/dest/root/a b.txt|root/a.txt", string(data))
+}
+
+func TestPostDownloadHookFailurePolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// Log-only failures keep the download successful but are recorded
+	dm, file := newPostDownloadTestManager(t, &PostDownloadConfig{Command: "echo infected >&2; exit 3"})
+	require.NoError(t, dm.runPostDownloadHook(ctx, dm.logger, file, "/dest/a.txt"))
+
+	var logged int
+	require.NoError(t, dm.stateManager.DB().GetContext(ctx, &logged,
+		"SELECT COUNT(*) FROM error_log WHERE item_id = $1 AND error_type = 'post_download_hook'", file.ID))
+	assert.Equal(t, 1, logged)
+
+	dm, file = newPostDownloadTestManager(t, &PostDownloadConfig{
+		Command:   "echo infected >&2; exit 3",
+		OnFailure: PostDownloadFail,
+	})
+	err := dm.runPostDownloadHook(ctx, dm.logger, file, "/dest/a.txt")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "infected")
+}
+
+func TestPostDownloadHookTimeout(t *testing.T) {
+	dm, file := newPostDownloadTestManager(t, &PostDownloadConfig{
+		Command:   "sleep 5",
+		Timeout:   100 * time.Millisecond,
+		OnFailure: PostDownloadFail,
+	})
+
+	start := time.Now()
+	err := dm.runPostDownloadHook(context.Background(), dm.logger, file, "/dest/a.txt")
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "timed out"), err.Error())
+	assert.Less(t, time.Since(start), 3*time.Second)
+}
+
+func TestParsePostDownloadFailurePolicy(t *testing.T) {
+	policy, err := ParsePostDownloadFailurePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, PostDownloadLog, policy)
+
+	policy, err = ParsePostDownloadFailurePolicy("FAIL")
+	require.NoError(t, err)
+	assert.Equal(t, PostDownloadFail, policy)
+
+	_, err = ParsePostDownloadFailurePolicy("ignore")
+	assert.Error(t, err)
+}
+
+func TestFailingPostDownloadHookDoesNotDownloadAgain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("post-download tests use sh")
+	}
+
+	children := map[string][]*drive.File{
+		"root": {{Id: "file-1", Name: "a.bin", MimeType: "application/octet-stream", Size: 10}},
+	}
+	handler := fakeDriveHandler(children, nil, nil)
+	var downloads atomic.Int32
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") == "media" {
+			downloads.Add(1)
+		}
+		handler(w, r)
+	}))
+
+	log := newTestLogger()
+	m := newTestStateManager(t)
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.PostDownload = &PostDownloadConfig{Command: "exit 3", OnFailure: PostDownloadFail}
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	dest := t.TempDir()
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// The file is failed once and stays in place
+	assert.Equal(t, int32(1), downloads.Load())
+	_, err = os.Stat(filepath.Join(dest, "root", "a.bin"))
+	require.NoError(t, err)
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, state.FileStatusFailed, files[0].Status)
+}
@@ -0,0 +1,131 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestParsePriorityTier(t *testing.T) {
+	for name, expected := range map[string]PriorityTier{
+		"high":   PriorityTierHigh,
+		"Normal": PriorityTierNormal,
+		"":       PriorityTierNormal,
+		" low ":  PriorityTierLow,
+	} {
+		tier, err := ParsePriorityTier(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, tier, name)
+	}
+
+	_, err := ParsePriorityTier("urgent")
+	assert.Error(t, err)
+}
+
+func TestTierForMimeType(t *testing.T) {
+	rules := []PriorityRule{
+		{Pattern: "video/*", Tier: PriorityTierLow},
+		{Pattern: "application/vnd.google-apps.*", Tier: PriorityTierHigh},
+		{Pattern: "application/pdf", Tier: PriorityTierHigh},
+	}
+
+	assert.Equal(t, PriorityTierLow, tierForMimeType(rules, "video/mp4"))
+	assert.Equal(t, PriorityTierHigh, tierForMimeType(rules, "application/vnd.google-apps.document"))
+	assert.Equal(t, PriorityTierHigh, tierForMimeType(rules, "application/pdf"))
+	assert.Equal(t, PriorityTierNormal, tierForMimeType(rules, "image/png"))
+	assert.Equal(t, PriorityTierNormal, tierForMimeType(rules, ""))
+
+	// The first matching rule wins
+	rules = append([]PriorityRule{{Pattern: "*", Tier: PriorityTierHigh}}, rules...)
+	assert.Equal(t, PriorityTierHigh, tierForMimeType(rules, "video/mp4"))
+}
+
+func TestCalculatePrioritiesOrdersByTierThenSize(t *testing.T) {
+	cfg := DefaultDownloadManagerConfig()
+	cfg.TempDir = t.TempDir()
+	cfg.PriorityRules = []PriorityRule{
+		{Pattern: "video/*", Tier: PriorityTierLow},
+		{Pattern: "application/pdf", Tier: PriorityTierHigh},
+	}
+
+	dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(), cfg)
+	require.NoError(t, err)
+
+	newFile := func(id, mimeType string, size int64) *state.File {
+		return &state.File{ID: id, Size: size, MimeType: state.NewNullString(mimeType)}
+	}
+	files := []*state.File{
+		newFile("small-video", "video/mp4", 10),
+		newFile("large-pdf", "application/pdf", 500*1024*1024),
+		newFile("small-image", "image/png", 10),
+		newFile("large-image", "image/png", 50*1024*1024),
+	}
+
+	priorities, tierCounts := dm.calculatePriorities(files)
+
+	assert.Less(t, priorities["large-pdf"], priorities["small-image"])
+	assert.Less(t, priorities["small-image"], priorities["large-image"])
+	assert.Less(t, priorities["large-image"], priorities["small-video"])
+
+	assert.Equal(t, 1, tierCounts[PriorityTierHigh])
+	assert.Equal(t, 2, tierCounts[PriorityTierNormal])
+	assert.Equal(t, 1, tierCounts[PriorityTierLow])
+}
+
+func TestParseScheduleOrder(t *testing.T) {
+	for name, expected := range map[string]ScheduleOrder{
+		"":               ScheduleSmallestFirst,
+		"smallest_first": ScheduleSmallestFirst,
+		"Largest_First":  ScheduleLargestFirst,
+		" drive_order ":  ScheduleDriveOrder,
+	} {
+		order, err := ParseScheduleOrder(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, order, name)
+	}
+
+	_, err := ParseScheduleOrder("random")
+	assert.Error(t, err)
+}
+
+func TestCalculatePrioritiesFollowsScheduleOrder(t *testing.T) {
+	files := []*state.File{
+		{ID: "medium", Size: 5 * 1024 * 1024},
+		{ID: "huge", Size: 500 * 1024 * 1024},
+		{ID: "tiny", Size: 10},
+		{ID: "large", Size: 50 * 1024 * 1024},
+	}
+
+	tests := []struct {
+		order    ScheduleOrder
+		expected []string
+	}{
+		{order: ScheduleSmallestFirst, expected: []string{"tiny", "medium", "large", "huge"}},
+		{order: ScheduleLargestFirst, expected: []string{"huge", "large", "medium", "tiny"}},
+		{order: ScheduleDriveOrder, expected: []string{"medium", "huge", "tiny", "large"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.order), func(t *testing.T) {
+			cfg := DefaultDownloadManagerConfig()
+			cfg.TempDir = t.TempDir()
+			cfg.ScheduleOrder = tt.order
+			cfg.PriorityRules = []PriorityRule{{Pattern: "application/pdf", Tier: PriorityTierHigh}}
+
+			dm, err := NewDownloadManager(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(), cfg)
+			require.NoError(t, err)
+
+			// A higher tier still comes first
+			pdf := &state.File{ID: "pdf", Size: 900 * 1024 * 1024, MimeType: state.NewNullString("application/pdf")}
+			priorities, _ := dm.calculatePriorities(append([]*state.File{pdf}, files...))
+
+			for i := 1; i < len(tt.expected); i++ {
+				assert.Less(t, priorities[tt.expected[i-1]], priorities[tt.expected[i]], tt.expected[i])
+			}
+			assert.Less(t, priorities["pdf"], priorities[tt.expected[0]])
+		})
+	}
+}
@@ -4,9 +4,10 @@
  * Features:
  * - Real-time progress tracking for sync operations
  * - Per-file and overall session progress
- * - Event emission for UI updates
+ * - Ordered, lossless event delivery to each handler
+ * - Emitting never waits for a slow handler; updates are coalesced
  * - Bandwidth calculation and throttling stats
- * - ETA estimation
+ * - ETA estimation from a smoothed throughput that ignores pauses
  *
  * Author: CloudPull Team
  * Updated: 2025-01-29
@@ -16,9 +17,14 @@ package sync
 
 import (
 	"context"
+	"io"
+	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
 // ProgressEventType defines types of progress events.
@@ -30,6 +36,8 @@ const (
 	ProgressEventFileProgress    ProgressEventType = "file_progress"
 	ProgressEventFileCompleted   ProgressEventType = "file_completed"
 	ProgressEventFileFailed      ProgressEventType = "file_failed"
+	ProgressEventFileSkipped     ProgressEventType = "file_skipped"
+	ProgressEventFileConflict    ProgressEventType = "file_conflict"
 	ProgressEventFolderStarted   ProgressEventType = "folder_started"
 	ProgressEventFolderCompleted ProgressEventType = "folder_completed"
 	ProgressEventSessionUpdate   ProgressEventType = "session_update"
@@ -56,27 +64,50 @@ type ProgressEvent struct {
 	BytesTransferred int64
 }
 
+const (
+	// speedSmoothingWindow is the time constant of the moving average of
+	// throughput; a sample's weight decays to 1/e over this long.
+	speedSmoothingWindow = 10 * time.Second
+
+	// idleGapThreshold is the longest gap between progress updates still
+	// counted as transfer time. Longer gaps are pauses or idle stretches
+	// and are left out of the throughput.
+	idleGapThreshold = 5 * time.Second
+)
+
+// eventQueueSize is the number of events queued for one handler without
+// allocating. Once a handler falls this far behind, its progress, session
+// and bandwidth updates are coalesced to the latest of each and other
+// events wait in an overflow list, so emitting never waits for it and no
+// file event is lost.
+const eventQueueSize = 1024
+
 // ProgressTracker tracks sync progress and emits events.
 type ProgressTracker struct {
+	// lastUpdate is when the last throughput sample was taken;
+	// lastSessionEmit when FileProgress last emitted a session update
 	lastUpdate      time.Time
+	lastSessionEmit time.Time
 	startTime       time.Time
-	periodStart     time.Time
 	activeDownloads map[string]*FileProgress
+	limiter         *rate.Limiter
 	sessionID       string
-	eventHandlers   []func(event *ProgressEvent)
-	speedSamples    []int64
+	subscriptions   []*eventSubscription
 	totalFiles      int64
 	skippedFiles    int64
 	failedFiles     int64
 	lastBytes       int64
-	currentSpeed    int64
 	completedFiles  int64
-	maxSpeedSamples int
 	completedBytes  int64
 	bandwidthLimit  int64
-	bytesThisPeriod int64
 	totalBytes      int64
-	mu              sync.RWMutex
+
+	// smoothedSpeed is the exponentially weighted moving average of the
+	// throughput in bytes per second, over activeTime only
+	smoothedSpeed float64
+	activeTime    time.Duration
+	activeBytes   int64
+	mu            sync.RWMutex
 }
 
 // FileProgress tracks individual file download progress.
@@ -97,10 +128,8 @@ func NewProgressTracker(sessionID string) *ProgressTracker {
 		sessionID:       sessionID,
 		startTime:       time.Now(),
 		lastUpdate:      time.Now(),
+		lastSessionEmit: time.Now(),
 		activeDownloads: make(map[string]*FileProgress),
-		speedSamples:    make([]int64, 0, 10),
-		maxSpeedSamples: 10,
-		periodStart:     time.Now(),
 	}
 }
 
@@ -114,18 +143,275 @@ func (pt *ProgressTracker) SetTotals(totalFiles, totalBytes int64) {
 	pt.emitSessionUpdate()
 }
 
-// SetBandwidthLimit sets the bandwidth limit in bytes per second.
+// AddTotals adds files and bytes discovered after the totals were set.
+func (pt *ProgressTracker) AddTotals(files, bytes int64) {
+	pt.mu.Lock()
+	pt.totalFiles += files
+	pt.totalBytes += bytes
+	pt.mu.Unlock()
+
+	pt.emitSessionUpdate()
+}
+
+// SetBandwidthLimit sets the bandwidth limit in bytes per second. The limit
+// is shared by every reader returned from ThrottleReader; zero disables it.
 func (pt *ProgressTracker) SetBandwidthLimit(bytesPerSecond int64) {
 	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
 	pt.bandwidthLimit = bytesPerSecond
+	if bytesPerSecond <= 0 {
+		pt.limiter = nil
+		return
+	}
+
+	if pt.limiter == nil {
+		pt.limiter = newBandwidthLimiter(bytesPerSecond)
+		return
+	}
+
+	updated := newBandwidthLimiter(bytesPerSecond)
+	pt.limiter.SetLimit(updated.Limit())
+	pt.limiter.SetBurst(updated.Burst())
+}
+
+// ThrottleReader wraps r so reads are limited by the shared bandwidth limit.
+func (pt *ProgressTracker) ThrottleReader(ctx context.Context, r io.Reader) io.Reader {
+	return &throttledReader{ctx: ctx, reader: r, limiter: pt.bandwidthLimiter}
+}
+
+// bandwidthLimiter returns the shared token bucket, or nil when unlimited.
+func (pt *ProgressTracker) bandwidthLimiter() *rate.Limiter {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.limiter
+}
+
+// eventSubscription delivers events to one handler, in the order they were
+// emitted, from a single goroutine.
+type eventSubscription struct {
+	handler func(event *ProgressEvent)
+	events  chan *ProgressEvent
+	done    chan struct{}
+	once    sync.Once
+
+	// overflow holds, in order, the other events that found the queue
+	// full; they follow the queued events.
+	overflow []*ProgressEvent
+
+	// coalesced holds the latest update of each kind that found the queue
+	// full; it is delivered once the queue is empty. wake signals the
+	// dispatch goroutine that an update or overflow event was stored.
+	coalesced map[coalesceKey]*ProgressEvent
+	wake      chan struct{}
+	mu        sync.Mutex
+}
+
+// coalesceKey identifies updates that supersede each other.
+type coalesceKey struct {
+	eventType ProgressEventType
+	itemID    string
+}
+
+// coalescable reports whether a later event of the same kind replaces
+// event, so only the latest has to reach a handler that fell behind.
+func coalescable(event *ProgressEvent) bool {
+	switch event.Type {
+	case ProgressEventFileProgress, ProgressEventSessionUpdate, ProgressEventBandwidthUpdate:
+		return true
+	default:
+		return false
+	}
+}
+
+// offer queues event without waiting. Updates that find the queue full
+// are coalesced; every other event is kept in the overflow list.
+func (s *eventSubscription) offer(event *ProgressEvent) {
+	if coalescable(event) {
+		key := coalesceKey{eventType: event.Type, itemID: event.ItemID}
+
+		s.mu.Lock()
+		// An update already waiting must not be overtaken by a newer one
+		if _, waiting := s.coalesced[key]; waiting {
+			s.coalesced[key] = event
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	} else {
+		s.mu.Lock()
+		// Events already overflowing must not be overtaken either
+		if len(s.overflow) > 0 {
+			s.overflow = append(s.overflow, event)
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+
+	select {
+	case s.events <- event:
+		return
+	default:
+	}
+
+	s.mu.Lock()
+	if coalescable(event) {
+		s.coalesced[coalesceKey{eventType: event.Type, itemID: event.ItemID}] = event
+	} else {
+		s.overflow = append(s.overflow, event)
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// backlog returns the number of events waiting for the handler.
+func (s *eventSubscription) backlog() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events) + len(s.overflow) + len(s.coalesced)
+}
+
+// takeOverflow removes the oldest overflow event, or returns nil.
+func (s *eventSubscription) takeOverflow() *ProgressEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.overflow) == 0 {
+		return nil
+	}
+
+	event := s.overflow[0]
+	s.overflow[0] = nil
+	s.overflow = s.overflow[1:]
+	if len(s.overflow) == 0 {
+		s.overflow = nil
+	}
+	return event
+}
+
+// takeCoalesced removes the stored updates, oldest first.
+func (s *eventSubscription) takeCoalesced() []*ProgressEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.coalesced) == 0 {
+		return nil
+	}
+
+	events := make([]*ProgressEvent, 0, len(s.coalesced))
+	for key, event := range s.coalesced {
+		events = append(events, event)
+		delete(s.coalesced, key)
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	return events
+}
+
+// dispatch calls the handler for each queued event until the subscription
+// is cancelled. Overflow events follow the queued ones, and coalesced
+// updates follow once both are empty.
+func (s *eventSubscription) dispatch() {
+	for {
+		select {
+		case event := <-s.events:
+			s.handler(event)
+			continue
+		case <-s.done:
+			return
+		default:
+		}
+
+		if event := s.takeOverflow(); event != nil {
+			s.handler(event)
+			continue
+		}
+
+		if coalesced := s.takeCoalesced(); len(coalesced) > 0 {
+			for _, event := range coalesced {
+				s.handler(event)
+			}
+			continue
+		}
+
+		select {
+		case event := <-s.events:
+			s.handler(event)
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// cancel stops the dispatch goroutine; queued events are dropped.
+func (s *eventSubscription) cancel() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// OnEvent registers an event handler and returns a function that removes
+// it again. Each handler runs on its own goroutine and sees events in the
+// order they were emitted.
+func (pt *ProgressTracker) OnEvent(handler func(event *ProgressEvent)) (unsubscribe func()) {
+	sub := &eventSubscription{
+		handler:   handler,
+		events:    make(chan *ProgressEvent, eventQueueSize),
+		done:      make(chan struct{}),
+		coalesced: make(map[coalesceKey]*ProgressEvent),
+		wake:      make(chan struct{}, 1),
+	}
+	go sub.dispatch()
+
+	pt.mu.Lock()
+	pt.subscriptions = append(pt.subscriptions, sub)
 	pt.mu.Unlock()
+
+	return func() { pt.unsubscribe(sub) }
+}
+
+// unsubscribe removes sub and stops its goroutine.
+func (pt *ProgressTracker) unsubscribe(sub *eventSubscription) {
+	pt.mu.Lock()
+	for i, s := range pt.subscriptions {
+		if s == sub {
+			pt.subscriptions = append(pt.subscriptions[:i:i], pt.subscriptions[i+1:]...)
+			break
+		}
+	}
+	pt.mu.Unlock()
+
+	sub.cancel()
+}
+
+// drain waits up to timeout for every handler to be handed the events
+// queued for it.
+func (pt *ProgressTracker) drain(timeout time.Duration) {
+	pt.mu.RLock()
+	subs := pt.subscriptions
+	pt.mu.RUnlock()
+
+	deadline := time.Now().Add(timeout)
+	for _, sub := range subs {
+		for sub.backlog() > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
 }
 
-// OnEvent registers an event handler.
-func (pt *ProgressTracker) OnEvent(handler func(event *ProgressEvent)) {
+// Close removes every event handler. Events emitted afterwards are dropped.
+func (pt *ProgressTracker) Close() {
 	pt.mu.Lock()
-	pt.eventHandlers = append(pt.eventHandlers, handler)
+	subs := pt.subscriptions
+	pt.subscriptions = nil
 	pt.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.cancel()
+	}
 }
 
 // FileStarted notifies that a file download has started.
@@ -173,15 +459,18 @@ func (pt *ProgressTracker) FileProgress(fileID string, bytesDownloaded int64) {
 	fp.BytesDownloaded = bytesDownloaded
 	fp.LastUpdate = now
 
-	// Update session totals
+	// Update session totals and speed tracking
 	atomic.AddInt64(&pt.completedBytes, deltaBytes)
+	pt.recordThroughput(now, deltaBytes)
 
-	pt.mu.Unlock()
-
-	// Update speed tracking
-	pt.updateSpeed(deltaBytes)
+	// Emit session update periodically
+	emitSession := now.Sub(pt.lastSessionEmit) > time.Second
+	if emitSession {
+		pt.lastSessionEmit = now
+	}
 
-	pt.emit(&ProgressEvent{
+	// Other workers may update fp once the lock is released
+	event := &ProgressEvent{
 		Type:             ProgressEventFileProgress,
 		Timestamp:        now,
 		SessionID:        pt.sessionID,
@@ -191,10 +480,12 @@ func (pt *ProgressTracker) FileProgress(fileID string, bytesDownloaded int64) {
 		BytesTransferred: bytesDownloaded,
 		TotalBytes:       fp.TotalBytes,
 		CurrentSpeed:     fp.Speed,
-	})
+	}
 
-	// Emit session update periodically
-	if now.Sub(pt.lastUpdate) > time.Second {
+	pt.mu.Unlock()
+
+	pt.emit(event)
+	if emitSession {
 		pt.emitSessionUpdate()
 	}
 }
@@ -213,6 +504,10 @@ func (pt *ProgressTracker) FileCompleted(fileID string) {
 	fileName := fp.FileName
 	filePath := fp.FilePath
 	totalBytes := fp.TotalBytes
+	if fp.BytesDownloaded > totalBytes {
+		// Exports and files listed without a size end larger than expected
+		totalBytes = fp.BytesDownloaded
+	}
 	pt.mu.Unlock()
 
 	pt.emit(&ProgressEvent{
@@ -229,8 +524,9 @@ func (pt *ProgressTracker) FileCompleted(fileID string) {
 	pt.emitSessionUpdate()
 }
 
-// FileFailed notifies that a file download failed.
-func (pt *ProgressTracker) FileFailed(fileID string, err error) {
+// FileFailed notifies that a file download failed. The event carries
+// traceID so its log line can be correlated with the download's.
+func (pt *ProgressTracker) FileFailed(fileID, traceID string, err error) {
 	pt.mu.Lock()
 	fp, exists := pt.activeDownloads[fileID]
 	if exists {
@@ -260,6 +556,9 @@ func (pt *ProgressTracker) FileFailed(fileID string, err error) {
 		ItemPath:     filePath,
 		Error:        err,
 		ErrorMessage: errorMsg,
+		Context: map[string]interface{}{
+			"trace_id": traceID,
+		},
 	})
 
 	pt.emitSessionUpdate()
@@ -270,7 +569,7 @@ func (pt *ProgressTracker) FileSkipped(fileID, fileName, filePath string, reason
 	atomic.AddInt64(&pt.skippedFiles, 1)
 
 	pt.emit(&ProgressEvent{
-		Type:      ProgressEventFileCompleted,
+		Type:      ProgressEventFileSkipped,
 		Timestamp: time.Now(),
 		SessionID: pt.sessionID,
 		ItemID:    fileID,
@@ -285,6 +584,22 @@ func (pt *ProgressTracker) FileSkipped(fileID, fileName, filePath string, reason
 	pt.emitSessionUpdate()
 }
 
+// FileConflict notifies that a download met a local file that differs
+// from Drive, and the conflict policy decided what to keep.
+func (pt *ProgressTracker) FileConflict(fileID, fileName, filePath string, decision string) {
+	pt.emit(&ProgressEvent{
+		Type:      ProgressEventFileConflict,
+		Timestamp: time.Now(),
+		SessionID: pt.sessionID,
+		ItemID:    fileID,
+		ItemName:  fileName,
+		ItemPath:  filePath,
+		Context: map[string]interface{}{
+			"decision": decision,
+		},
+	})
+}
+
 // FolderStarted notifies that folder scanning started.
 func (pt *ProgressTracker) FolderStarted(folderID, folderName, folderPath string) {
 	pt.emit(&ProgressEvent{
@@ -331,104 +646,96 @@ func (pt *ProgressTracker) GetStats() *ProgressStats {
 		SkippedFiles:    pt.skippedFiles,
 		TotalBytes:      pt.totalBytes,
 		CompletedBytes:  pt.completedBytes,
-		CurrentSpeed:    pt.currentSpeed,
+		CurrentSpeed:    int64(pt.smoothedSpeed),
 		AverageSpeed:    pt.calculateAverageSpeed(),
 		ActiveDownloads: len(pt.activeDownloads),
 		BandwidthLimit:  pt.bandwidthLimit,
 	}
 }
 
-// CheckBandwidthLimit checks if we're within bandwidth limits.
+// CheckBandwidthLimit blocks until bytesRequested may be transferred under
+// the shared bandwidth limit.
 func (pt *ProgressTracker) CheckBandwidthLimit(ctx context.Context, bytesRequested int64) error {
-	if pt.bandwidthLimit <= 0 {
+	limiter := pt.bandwidthLimiter()
+	if limiter == nil {
 		return nil // No limit
 	}
 
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
-
-	now := time.Now()
-	periodDuration := now.Sub(pt.periodStart)
-
-	// Reset period if it's been more than a second
-	if periodDuration >= time.Second {
-		pt.bytesThisPeriod = 0
-		pt.periodStart = now
-		periodDuration = 0
-	}
-
-	// Check if adding these bytes would exceed the limit
-	if pt.bytesThisPeriod+bytesRequested > pt.bandwidthLimit {
-		// Calculate wait time
-		remainingPeriod := time.Second - periodDuration
-
-		// Wait for the next period
-		timer := time.NewTimer(remainingPeriod)
-		defer timer.Stop()
-
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-timer.C:
-			pt.bytesThisPeriod = bytesRequested
-			pt.periodStart = time.Now()
+	// WaitN rejects requests larger than the bucket, so wait in bursts
+	for bytesRequested > 0 {
+		n := int64(limiter.Burst())
+		if n > bytesRequested {
+			n = bytesRequested
 		}
-	} else {
-		pt.bytesThisPeriod += bytesRequested
+		if err := limiter.WaitN(ctx, int(n)); err != nil {
+			return err
+		}
+		bytesRequested -= n
 	}
 
 	return nil
 }
 
-// updateSpeed updates the current speed calculation.
-func (pt *ProgressTracker) updateSpeed(deltaBytes int64) {
-	pt.mu.Lock()
-	defer pt.mu.Unlock()
+// ActiveDownloadSpeed returns the combined speed of the files being
+// downloaded, using each file's most recent progress sample.
+func (pt *ProgressTracker) ActiveDownloadSpeed() int64 {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
 
-	now := time.Now()
-	deltaTime := now.Sub(pt.lastUpdate).Seconds()
+	var speed int64
+	for _, fp := range pt.activeDownloads {
+		speed += fp.Speed
+	}
+	return speed
+}
 
-	if deltaTime > 0 {
-		speed := int64(float64(deltaBytes) / deltaTime)
+// recordThroughput adds deltaBytes transferred by now to the smoothed
+// throughput. The weight of a sample grows with the time it covers, so
+// bursts of closely spaced updates do not swing the average. Gaps longer
+// than idleGapThreshold are pauses: their bytes are counted, but neither the
+// gap nor its bytes count towards the throughput. The caller holds pt.mu.
+func (pt *ProgressTracker) recordThroughput(now time.Time, deltaBytes int64) {
+	interval := now.Sub(pt.lastUpdate)
+	pt.lastUpdate = now
+	pt.lastBytes += deltaBytes
 
-		// Add to samples
-		pt.speedSamples = append(pt.speedSamples, speed)
-		if len(pt.speedSamples) > pt.maxSpeedSamples {
-			pt.speedSamples = pt.speedSamples[1:]
-		}
+	if interval <= 0 || interval > idleGapThreshold {
+		return
+	}
 
-		// Calculate current speed as average of recent samples
-		if len(pt.speedSamples) > 0 {
-			sum := int64(0)
-			for _, s := range pt.speedSamples {
-				sum += s
-			}
-			pt.currentSpeed = sum / int64(len(pt.speedSamples))
-		}
+	pt.activeTime += interval
+	pt.activeBytes += deltaBytes
+
+	speed := float64(deltaBytes) / interval.Seconds()
+	if pt.smoothedSpeed == 0 {
+		pt.smoothedSpeed = speed
+		return
 	}
 
-	pt.lastUpdate = now
-	pt.lastBytes += deltaBytes
+	alpha := 1 - math.Exp(-float64(interval)/float64(speedSmoothingWindow))
+	pt.smoothedSpeed += alpha * (speed - pt.smoothedSpeed)
 }
 
-// calculateAverageSpeed calculates the average speed since start.
+// calculateAverageSpeed calculates the average speed over the time spent
+// transferring, leaving out pauses.
 func (pt *ProgressTracker) calculateAverageSpeed() int64 {
-	elapsed := time.Since(pt.startTime).Seconds()
-	if elapsed > 0 {
-		return int64(float64(pt.completedBytes) / elapsed)
+	if pt.activeTime > 0 {
+		return int64(float64(pt.activeBytes) / pt.activeTime.Seconds())
 	}
 	return 0
 }
 
-// calculateRemainingTime estimates time remaining.
+// calculateRemainingTime estimates the time remaining from the bytes left
+// and the smoothed throughput.
 func (pt *ProgressTracker) calculateRemainingTime() time.Duration {
-	if pt.currentSpeed <= 0 || pt.completedBytes >= pt.totalBytes {
+	completedBytes := atomic.LoadInt64(&pt.completedBytes)
+	if pt.smoothedSpeed < 1 || completedBytes >= pt.totalBytes {
 		return 0
 	}
 
-	remainingBytes := pt.totalBytes - pt.completedBytes
-	seconds := float64(remainingBytes) / float64(pt.currentSpeed)
-	return time.Duration(seconds) * time.Second
+	remainingBytes := pt.totalBytes - completedBytes
+	seconds := float64(remainingBytes) / pt.smoothedSpeed
+	return time.Duration(seconds * float64(time.Second)).Round(time.Second)
 }
 
 // emitSessionUpdate emits a session progress update.
@@ -454,16 +761,15 @@ func (pt *ProgressTracker) emitSessionUpdate() {
 	})
 }
 
-// emit sends an event to all registered handlers.
+// emit queues an event for all registered handlers without waiting for
+// them.
 func (pt *ProgressTracker) emit(event *ProgressEvent) {
 	pt.mu.RLock()
-	handlers := make([]func(*ProgressEvent), len(pt.eventHandlers))
-	copy(handlers, pt.eventHandlers)
+	subs := pt.subscriptions
 	pt.mu.RUnlock()
 
-	for _, handler := range handlers {
-		// Call handlers in goroutines to prevent blocking
-		go handler(event)
+	for _, sub := range subs {
+		sub.offer(event)
 	}
 }
 
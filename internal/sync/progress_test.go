@@ -0,0 +1,202 @@
+package sync
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressTrackerDeliversEventsInOrder(t *testing.T) {
+	const events = 5000
+
+	tracker := NewProgressTracker("session-1")
+	baseline := runtime.NumGoroutine()
+
+	received := make(chan string, events)
+	release := make(chan struct{})
+	unsubscribe := tracker.OnEvent(func(event *ProgressEvent) {
+		<-release
+		if event.Type == ProgressEventFileStarted {
+			received <- event.ItemID
+		}
+	})
+
+	// The handler is stuck, so emitting must neither wait for it nor pile
+	// up goroutines; events beyond its queue overflow and none is lost
+	emitted := make(chan struct{})
+	go func() {
+		defer close(emitted)
+		for i := 0; i < events; i++ {
+			tracker.FileStarted(fmt.Sprintf("file-%d", i), "f.bin", "root/f.bin", 1)
+		}
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("emitting waited for a stuck handler")
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline+3)
+	close(release)
+
+	for i := 0; i < events; i++ {
+		select {
+		case id := <-received:
+			require.Equal(t, fmt.Sprintf("file-%d", i), id)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("only %d of %d events arrived", i, events)
+		}
+	}
+
+	// Unsubscribed handlers see no further events and their goroutine exits
+	unsubscribe()
+	tracker.FileStarted("late", "late.bin", "root/late.bin", 1)
+	// Eventually runs the condition on a goroutine of its own
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= baseline+1 }, 5*time.Second, 10*time.Millisecond)
+	select {
+	case id := <-received:
+		t.Fatalf("unsubscribed handler received %s", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestProgressTrackerCoalescesUpdatesForSlowHandler(t *testing.T) {
+	tracker := NewProgressTracker("session-1")
+	defer tracker.Close()
+
+	var mu sync.Mutex
+	var totals []int64
+	release := make(chan struct{})
+	tracker.OnEvent(func(event *ProgressEvent) {
+		<-release
+		if event.Type == ProgressEventSessionUpdate {
+			mu.Lock()
+			totals = append(totals, event.TotalFiles)
+			mu.Unlock()
+		}
+	})
+
+	// Fill the queue, then send updates that find no room
+	for i := 0; i <= eventQueueSize; i++ {
+		tracker.FileStarted(fmt.Sprintf("file-%d", i), "f.bin", "root/f.bin", 1)
+	}
+	for i := int64(1); i <= 50; i++ {
+		tracker.SetTotals(i, i)
+	}
+	close(release)
+
+	// Only the latest update reaches the handler, after the queued events
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(totals) > 0 && totals[len(totals)-1] == 50
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.Less(t, len(totals), 50)
+	mu.Unlock()
+}
+
+func TestProgressTrackerETAIgnoresPauses(t *testing.T) {
+	const (
+		mb    = 1 << 20
+		total = 120 * mb
+		tick  = 100 * time.Millisecond
+	)
+
+	tracker := NewProgressTracker("session-1")
+	tracker.SetTotals(1, total)
+
+	now := tracker.lastUpdate
+	feed := func(d time.Duration, n int64) {
+		now = now.Add(d)
+		tracker.mu.Lock()
+		tracker.completedBytes += n
+		tracker.recordThroughput(now, n)
+		tracker.mu.Unlock()
+	}
+
+	// About 1 MB/s, arriving in uneven updates
+	transfer := func(ticks int) {
+		for i := 0; i < ticks; i++ {
+			n := int64(mb / 20)
+			if i%2 == 1 {
+				n = mb/10 + mb/20
+			}
+			feed(tick, n)
+		}
+	}
+
+	transfer(300)
+	before := tracker.GetStats()
+	assert.InDelta(t, mb, before.CurrentSpeed, 0.05*mb)
+	assert.InDelta(t, 90, before.RemainingTime.Seconds(), 5)
+
+	// A minute without progress, then the transfer continues with a burst
+	// of the bytes buffered meanwhile
+	feed(time.Minute, 2*mb)
+	afterPause := tracker.GetStats()
+	assert.InDelta(t, float64(before.CurrentSpeed), float64(afterPause.CurrentSpeed), 0.01*mb)
+	assert.InDelta(t, 88, afterPause.RemainingTime.Seconds(), 5)
+	assert.InDelta(t, mb, afterPause.AverageSpeed, 0.05*mb)
+
+	// The ETA keeps counting down steadily
+	previous := afterPause.RemainingTime
+	for i := 0; i < 30; i++ {
+		transfer(10)
+		remaining := tracker.GetStats().RemainingTime
+		assert.InDelta(t, (previous - time.Second).Seconds(), remaining.Seconds(), 2)
+		previous = remaining
+	}
+	assert.InDelta(t, 58, previous.Seconds(), 5)
+}
+
+func TestProgressTrackerConcurrentFileProgress(t *testing.T) {
+	tracker := NewProgressTracker("session-1")
+	defer tracker.Close()
+
+	sessionUpdates := make(chan struct{}, 1)
+	tracker.OnEvent(func(event *ProgressEvent) {
+		if event.Type == ProgressEventSessionUpdate {
+			select {
+			case sessionUpdates <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	const workers = 8
+	for i := 0; i < workers; i++ {
+		tracker.FileStarted(fmt.Sprintf("file-%d", i), "f.bin", "root/f.bin", 1000)
+	}
+
+	// A session update is due with the next progress
+	tracker.mu.Lock()
+	tracker.lastSessionEmit = time.Now().Add(-2 * time.Second)
+	tracker.mu.Unlock()
+
+	// Workers report progress on their own and a shared file while the
+	// stats are read; run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for n := int64(1); n <= 100; n++ {
+				tracker.FileProgress(id, n*10)
+				tracker.FileProgress("file-0", n)
+				tracker.GetStats()
+			}
+		}(fmt.Sprintf("file-%d", i))
+	}
+	wg.Wait()
+
+	select {
+	case <-sessionUpdates:
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress emitted no session update")
+	}
+}
@@ -0,0 +1,184 @@
+/**
+ * Download Quota for CloudPull Sync Engine
+ *
+ * Features:
+ * - Stops scheduling downloads once a sync reaches its byte cap
+ * - Stops scanning and scheduling once a run reaches its API call cap
+ * - Lets in-flight downloads finish before the session ends
+ * - Keeps scanning so totals show what the quota left out
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// checkQuota drains the download queue once this sync has downloaded
+// MaxTotalBytes. Downloads already running finish; queued ones never start.
+func (e *Engine) checkQuota() {
+	e.mu.Lock()
+	limit := e.config.MaxTotalBytes
+	if limit <= 0 || e.quotaReached || e.progressTracker == nil || e.downloader == nil {
+		e.mu.Unlock()
+		return
+	}
+
+	stats := e.progressTracker.GetStats()
+	if stats.CompletedBytes < limit {
+		e.mu.Unlock()
+		return
+	}
+
+	e.quotaReached = true
+	downloader := e.downloader
+	ctx := e.ctx
+	e.mu.Unlock()
+
+	e.logger.Info("Download quota reached, finishing in-flight downloads",
+		"limit", formatBytes(limit),
+		"downloaded", formatBytes(stats.CompletedBytes),
+	)
+
+	e.drainForQuota(ctx, downloader)
+}
+
+// checkAPICallLimit reports whether this run has made MaxAPICalls Drive
+// requests. The first time it has, queued downloads are drained like at the
+// byte quota; the caller walking folders stops the walk. Scans are not
+// limited.
+func (e *Engine) checkAPICallLimit() bool {
+	e.mu.Lock()
+	limit := e.config.MaxAPICalls
+	if limit <= 0 || e.scanOnly || e.client == nil || e.downloader == nil {
+		e.mu.Unlock()
+		return false
+	}
+	if e.apiLimitReached {
+		e.mu.Unlock()
+		return true
+	}
+
+	calls := e.apiCallsLocked()
+	if calls < limit {
+		e.mu.Unlock()
+		return false
+	}
+
+	e.apiLimitReached = true
+	draining := e.quotaReached
+	e.quotaReached = true
+	downloader := e.downloader
+	ctx := e.ctx
+	e.mu.Unlock()
+
+	e.logger.Info("API call limit reached, finishing in-flight downloads",
+		"limit", limit,
+		"api_calls", calls,
+	)
+
+	// The byte quota may already be draining the queue
+	if !draining {
+		e.drainForQuota(ctx, downloader)
+	}
+	return true
+}
+
+// apiCallsLocked returns the Drive requests made since this run started.
+// e.mu must be held.
+func (e *Engine) apiCallsLocked() int64 {
+	if e.client == nil {
+		return 0
+	}
+	return e.client.GetAPICallCount() - e.apiCallBase
+}
+
+// drainForQuota lets the downloads in flight finish without starting queued
+// ones, then checks whether the sync is done.
+func (e *Engine) drainForQuota(ctx context.Context, downloader *DownloadManager) {
+	go func() {
+		if err := downloader.Drain(ctx); err != nil {
+			// The sync was stopped while draining
+			return
+		}
+
+		e.mu.Lock()
+		e.quotaDrained = true
+		e.mu.Unlock()
+
+		e.requestCompletionCheck()
+	}()
+}
+
+// quotaExceeded reports whether a quota has stopped scheduling.
+func (e *Engine) quotaExceeded() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.quotaReached
+}
+
+// scheduleWalkedFiles schedules files found by the walker. Past the byte
+// quota they are only counted, so the session shows what was left out.
+func (e *Engine) scheduleWalkedFiles(files []*state.File) {
+	if e.quotaExceeded() {
+		return
+	}
+
+	e.downloader.ScheduleBatch(files)
+}
+
+// stoppedByQuota reports whether a quota left files of a finished sync
+// undownloaded. The API call limit also ends the folder walk early, so it
+// may have left files unlisted.
+func (e *Engine) stoppedByQuota(stats *ProgressStats) bool {
+	e.mu.RLock()
+	reached, apiLimit := e.quotaReached, e.apiLimitReached
+	e.mu.RUnlock()
+
+	if !reached {
+		return false
+	}
+	if apiLimit {
+		return true
+	}
+
+	return stats.CompletedFiles+stats.FailedFiles+stats.SkippedFiles < stats.TotalFiles
+}
+
+// finishQuotaStop ends a session stopped by a quota. Files queued when the
+// quota was reached become pending again, so a resumed session downloads
+// them and lists the folders the walk did not reach.
+func (e *Engine) finishQuotaStop(stats *ProgressStats) {
+	// e.ctx is already canceled when the sync finishes
+	if _, err := e.stateManager.ResetInterruptedFiles(context.Background(), e.sessionID); err != nil {
+		e.logger.Error(err, "Failed to reset files left by the download quota")
+	}
+
+	e.mu.RLock()
+	apiLimit := e.apiLimitReached
+	calls := e.apiCallsLocked()
+	e.mu.RUnlock()
+
+	remaining := stats.TotalFiles - stats.CompletedFiles - stats.FailedFiles - stats.SkippedFiles
+	if apiLimit {
+		e.logger.Info("Sync stopped at API call limit",
+			"limit", e.config.MaxAPICalls,
+			"api_calls", calls,
+			"downloaded", formatBytes(stats.CompletedBytes),
+			"remaining_files", remaining,
+		)
+	} else {
+		e.logger.Info("Sync stopped at download quota",
+			"limit", formatBytes(e.config.MaxTotalBytes),
+			"downloaded", formatBytes(stats.CompletedBytes),
+			"remaining_files", remaining,
+		)
+	}
+
+	e.updateFinalStatus(state.SessionStatusStoppedQuota)
+}
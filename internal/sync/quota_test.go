@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/api/apitest"
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestEngineStopsSchedulingAtQuota(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const fileCount = 20
+	var rootFiles []*drive.File
+	for i := 0; i < fileCount; i++ {
+		rootFiles = append(rootFiles, &drive.File{
+			Id:       fmt.Sprintf("file-%02d", i),
+			Name:     fmt.Sprintf("file-%02d.bin", i),
+			MimeType: "application/octet-stream",
+			Size:     10,
+		})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.MaxConcurrent = 1
+	cfg.MaxTotalBytes = 25
+	engine, err := NewEngine(newFakeDriveClient(t, map[string][]*drive.File{"root": rootFiles}, nil),
+		m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	var downloads atomic.Int32
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		downloads.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusStoppedQuota, session.Status)
+
+	// Scanning continues past the quota, so every file is counted
+	assert.Equal(t, int64(fileCount), session.TotalFiles)
+
+	// The quota is crossed by the third file; at most the download already
+	// picked up when it was reached finishes after it
+	assert.GreaterOrEqual(t, downloads.Load(), int32(3))
+	assert.Less(t, downloads.Load(), int32(fileCount))
+
+	counts, err := m.Files().CountByStatus(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(downloads.Load()), counts[state.FileStatusCompleted])
+	assert.Equal(t, int64(fileCount)-counts[state.FileStatusCompleted], counts[state.FileStatusPending])
+}
+
+func TestEngineStopsAtAPICallLimit(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	dest := t.TempDir()
+
+	const folderCount = 10
+	fake := apitest.NewFakeDrive()
+	for i := 0; i < folderCount; i++ {
+		folderID := fmt.Sprintf("folder-%02d", i)
+		fake.AddFolder("root", folderID, folderID)
+		fake.AddFile(folderID, fmt.Sprintf("file-%02d", i), "a.txt", "content")
+	}
+
+	newEngine := func(maxCalls int64) *Engine {
+		log := newTestLogger()
+		cfg := DefaultEngineConfig()
+		cfg.DownloadConfig.TempDir = t.TempDir()
+		cfg.DownloadConfig.MaxConcurrent = 1
+		cfg.WalkerConfig.Concurrency = 1
+		cfg.MaxAPICalls = maxCalls
+		engine, err := NewEngine(fake, m, errors.NewHandler(log), log, cfg)
+		require.NoError(t, err)
+		return engine
+	}
+	wait := func(engine *Engine) {
+		select {
+		case <-engine.WaitForCompletion():
+		case <-time.After(30 * time.Second):
+			t.Fatal("sync engine did not terminate")
+		}
+	}
+
+	const limit = 6
+	engine := newEngine(limit)
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", dest)
+	require.NoError(t, err)
+	wait(engine)
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusStoppedQuota, session.Status)
+	assert.Less(t, session.CompletedFiles, int64(folderCount))
+
+	// Only requests already in flight at the limit are made after it
+	calls := fake.GetAPICallCount()
+	assert.GreaterOrEqual(t, calls, int64(limit))
+	assert.LessOrEqual(t, calls, int64(limit+3))
+	assert.Less(t, fake.Calls("ListFiles"), folderCount+1)
+
+	// A resumed run counts its own calls and lists the remaining folders
+	resumed := newEngine(0)
+	require.NoError(t, resumed.ResumeSession(ctx, sessionID))
+	wait(resumed)
+
+	session, err = m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	counts, err := m.Files().CountByStatus(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(folderCount), counts[state.FileStatusCompleted])
+}
@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+)
+
+func TestListRemoteAppliesWalkerFilters(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "folder-docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "folder-tmp", Name: "tmp", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10},
+			{Id: "file-b", Name: "b.tmp", MimeType: "text/plain", Size: 20},
+			{Id: "file-link", Name: "link", MimeType: "application/vnd.google-apps.shortcut"},
+		},
+		"folder-docs": {
+			{Id: "folder-deep", Name: "deep", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "doc-notes", Name: "Notes", MimeType: "application/vnd.google-apps.document"},
+			{Id: "file-c", Name: "c.txt", MimeType: "text/plain", Size: 30},
+		},
+		"folder-deep": {
+			{Id: "file-d", Name: "d.txt", MimeType: "text/plain", Size: 40},
+		},
+	}
+
+	var listed []string
+	client := newFakeDriveClient(t, children, func(_ *http.Request, folderID string) {
+		listed = append(listed, folderID)
+	})
+
+	config := DefaultWalkerConfig()
+	config.ExcludePatterns = []string{`\.tmp$`, `^root/tmp$`}
+	config.MaxDepth = 1
+
+	listing, err := ListRemote(context.Background(), client, newTestLogger(), "root", true, config)
+	require.NoError(t, err)
+
+	// Excluded folders and folders below the depth limit are not listed
+	assert.Equal(t, []string{"root", "folder-docs"}, listed)
+
+	var paths []string
+	skipped := make(map[string]string)
+	for _, item := range listing.Items {
+		paths = append(paths, item.Path)
+		if item.SkipReason != "" {
+			skipped[item.Path] = item.SkipReason
+		}
+	}
+	assert.Equal(t, []string{
+		"root/docs", "root/docs/deep", "root/docs/Notes", "root/docs/c.txt",
+		"root/tmp", "root/a.txt", "root/b.tmp", "root/link",
+	}, paths)
+	assert.Equal(t, map[string]string{
+		"root/tmp":   "excluded by folder patterns",
+		"root/b.tmp": `excluded by pattern \.tmp$`,
+		"root/link":  "shortcut",
+	}, skipped)
+
+	assert.Equal(t, 3, listing.Folders)
+	assert.Equal(t, 5, listing.Files)
+	assert.Equal(t, 1, listing.GoogleDocs)
+	assert.Equal(t, 1, listing.Shortcuts)
+	assert.Equal(t, 1, listing.SkippedFolders)
+	assert.Equal(t, 2, listing.SkippedFiles)
+	assert.Equal(t, int64(40), listing.TotalBytes)
+	assert.Equal(t, int64(20), listing.SkippedBytes)
+}
+
+func TestListRemoteWithoutRecursion(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "folder-docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "file-a", Name: "a.txt", MimeType: "text/plain", Size: 10},
+		},
+		"folder-docs": {
+			{Id: "file-c", Name: "c.txt", MimeType: "text/plain", Size: 30},
+		},
+	}
+
+	listing, err := ListRemote(context.Background(), newFakeDriveClient(t, children, nil), newTestLogger(),
+		"root", false, nil)
+	require.NoError(t, err)
+
+	require.Len(t, listing.Items, 2)
+	assert.Equal(t, 1, listing.Folders)
+	assert.Equal(t, 1, listing.Files)
+	assert.Equal(t, int64(10), listing.TotalBytes)
+	assert.False(t, listing.RootSkipped)
+}
@@ -0,0 +1,156 @@
+/**
+ * Completion Report for CloudPull Sync Engine
+ *
+ * Features:
+ * - JSON summary written to the destination when a sync finishes
+ * - Final session statistics and error summary
+ * - Failed files with errors and skipped files with reasons
+ * - Name and size duplicates found in the session
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// ReportFileName is the name of the completion report in the destination.
+const ReportFileName = "cloudpull-report.json"
+
+// CompletionReport summarizes a finished sync.
+type CompletionReport struct {
+	GeneratedAt     time.Time              `json:"generated_at"`
+	Stats           *state.SessionStats    `json:"stats"`
+	SessionID       string                 `json:"session_id"`
+	Status          string                 `json:"status"`
+	RootFolderID    string                 `json:"root_folder_id"`
+	DestinationPath string                 `json:"destination_path"`
+	FailedFiles     []*ReportFile          `json:"failed_files"`
+	SkippedFiles    []*ReportFile          `json:"skipped_files"`
+	Duplicates      []*state.DuplicateFile `json:"duplicates"`
+}
+
+// ReportFile is a file listed in the completion report. Reason holds the
+// error of a failed file or why a skipped file was left out.
+type ReportFile struct {
+	DriveID string `json:"drive_id"`
+	Path    string `json:"path"`
+	Reason  string `json:"reason,omitempty"`
+	Size    int64  `json:"size"`
+}
+
+// BuildCompletionReport collects the completion report of a session.
+func BuildCompletionReport(ctx context.Context, m *state.Manager, session *state.Session, status string) (*CompletionReport, error) {
+	report := &CompletionReport{
+		GeneratedAt:     time.Now(),
+		SessionID:       session.ID,
+		Status:          status,
+		RootFolderID:    session.RootFolderID,
+		DestinationPath: session.DestinationPath,
+	}
+
+	stats, err := m.GetSessionStats(ctx, session.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get session stats")
+	}
+	report.Stats = stats
+
+	if report.FailedFiles, err = reportFiles(ctx, m, session.ID, state.FileStatusFailed); err != nil {
+		return nil, err
+	}
+	if report.SkippedFiles, err = reportFiles(ctx, m, session.ID, state.FileStatusSkipped); err != nil {
+		return nil, err
+	}
+
+	if report.Duplicates, err = m.Queries().FindDuplicates(ctx, session.ID); err != nil {
+		return nil, errors.Wrap(err, "failed to find duplicates")
+	}
+	if report.Duplicates == nil {
+		report.Duplicates = []*state.DuplicateFile{}
+	}
+
+	return report, nil
+}
+
+// reportFiles lists the files of a session with status.
+func reportFiles(ctx context.Context, m *state.Manager, sessionID, status string) ([]*ReportFile, error) {
+	files, err := m.Files().GetByStatus(ctx, sessionID, status)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s files", status)
+	}
+
+	entries := make([]*ReportFile, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, &ReportFile{
+			DriveID: file.DriveID,
+			Path:    file.Path,
+			Reason:  file.ErrorMessage.String,
+			Size:    file.Size,
+		})
+	}
+
+	return entries, nil
+}
+
+// WriteCompletionReport writes report as ReportFileName into dir and
+// returns its path. The file is replaced atomically.
+func WriteCompletionReport(dir string, report *CompletionReport) (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode completion report")
+	}
+
+	path := filepath.Join(dir, ReportFileName)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return "", errors.Wrap(err, "failed to write completion report")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", errors.Wrap(err, "failed to move completion report into place")
+	}
+
+	return path, nil
+}
+
+// writeCompletionReport writes the report of the current session into its
+// destination if reports are enabled. Failures are logged, never fatal.
+func (e *Engine) writeCompletionReport(status string) {
+	e.mu.RLock()
+	enabled := e.config.WriteReport
+	session := e.currentSession
+	e.mu.RUnlock()
+
+	if !enabled || session == nil {
+		return
+	}
+
+	path := filepath.Join(session.DestinationPath, ReportFileName)
+
+	// e.ctx is already canceled when the sync finishes
+	report, err := BuildCompletionReport(context.Background(), e.stateManager, session, status)
+	if err == nil {
+		_, err = WriteCompletionReport(session.DestinationPath, report)
+	}
+	if err != nil {
+		e.logger.Error(err, "Completion report not written", "path", path)
+		return
+	}
+
+	e.logger.Info("Completion report written",
+		"path", path,
+		"failed_files", len(report.FailedFiles),
+		"skipped_files", len(report.SkippedFiles),
+		"duplicates", len(report.Duplicates),
+	)
+}
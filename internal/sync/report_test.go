@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestBuildCompletionReport(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	session := createFailedFiles(t, m, 2)
+
+	files, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	require.NoError(t, m.Files().MarkAsFailed(ctx, files[0].ID, "quota exceeded"))
+	require.NoError(t, m.Files().MarkAsSkipped(ctx, files[1].ID, "excluded by patterns"))
+	require.NoError(t, m.LogError(ctx, session.ID, files[0].ID, "file", "download", errors.Errorf("quota exceeded")))
+
+	report, err := BuildCompletionReport(ctx, m, session, state.SessionStatusFailed)
+	require.NoError(t, err)
+
+	assert.Equal(t, session.ID, report.SessionID)
+	assert.Equal(t, state.SessionStatusFailed, report.Status)
+	require.NotNil(t, report.Stats)
+	require.Len(t, report.Stats.Errors, 1)
+	assert.Equal(t, int64(1), report.Stats.Errors[0].Count)
+
+	require.Len(t, report.FailedFiles, 1)
+	assert.Equal(t, files[0].Path, report.FailedFiles[0].Path)
+	assert.Contains(t, report.FailedFiles[0].Reason, "quota exceeded")
+
+	require.Len(t, report.SkippedFiles, 1)
+	assert.Equal(t, "excluded by patterns", report.SkippedFiles[0].Reason)
+	assert.Empty(t, report.Duplicates)
+}
+
+func TestEngineWritesCompletionReport(t *testing.T) {
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "a", Name: "a.txt", MimeType: "text/plain", Size: 4},
+			{Id: "b", Name: "b.txt", MimeType: "text/plain", Size: 4},
+		},
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.WriteReport = true
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), newTestStateManager(t),
+		errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	dest := t.TempDir()
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", dest)
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, ReportFileName))
+	require.NoError(t, err)
+
+	var report CompletionReport
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, sessionID, report.SessionID)
+	assert.Equal(t, state.SessionStatusCompleted, report.Status)
+	assert.Equal(t, int64(2), report.Stats.Files.CompletedCount)
+	assert.Empty(t, report.FailedFiles)
+}
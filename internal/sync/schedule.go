@@ -0,0 +1,117 @@
+/**
+ * Walk Result Scheduling for CloudPull Sync Engine
+ *
+ * Features:
+ * - Bounded queue of file batches between the folder walk and the downloader
+ * - Scheduling waits while the download queue holds MaxQueuedFiles files
+ * - Backlog of walked but unscheduled files for progress and metrics
+ * - Optional scan-then-download mode scheduling only after the walk
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"slices"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+const (
+	// scheduleQueueBatches is the number of walked file batches buffered
+	// before the walk result consumer blocks
+	scheduleQueueBatches = 16
+
+	// queueRoomInterval is how often a scheduler waiting for room in the
+	// download queue looks again
+	queueRoomInterval = 100 * time.Millisecond
+)
+
+// startScheduler starts handing walked file batches to the download
+// manager. Batches are sent on the returned channel, which the caller
+// closes when the walk ends; done is closed once every batch was handled.
+func (e *Engine) startScheduler() (batches chan []*state.File, done <-chan struct{}) {
+	batches = make(chan []*state.File, scheduleQueueBatches)
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		for batch := range batches {
+			// Batches left after cancellation stay pending in the state
+			// database and are picked up by a resumed session
+			if e.waitForQueueRoom() {
+				e.scheduleWalkedFiles(batch)
+			}
+			e.scheduleBacklog.Add(-int64(len(batch)))
+		}
+	}()
+
+	return batches, finished
+}
+
+// enqueueBatch passes a batch of walked files to the scheduler, blocking
+// while the schedule queue is full. It returns false once the sync is
+// canceled.
+func (e *Engine) enqueueBatch(batches chan<- []*state.File, batch []*state.File) bool {
+	e.scheduleBacklog.Add(int64(len(batch)))
+
+	select {
+	case batches <- batch:
+		return true
+	case <-e.ctx.Done():
+		e.scheduleBacklog.Add(-int64(len(batch)))
+		return false
+	}
+}
+
+// enqueuePendingFiles passes the pending files of the session to the
+// scheduler in batches, once a walk in scan-then-download mode is done. It
+// returns false if the sync is canceled or the files cannot be loaded.
+func (e *Engine) enqueuePendingFiles(batches chan<- []*state.File, batchSize int) bool {
+	files, err := e.stateManager.GetPendingFiles(e.ctx, e.sessionID, 0, string(e.config.DownloadConfig.ScheduleOrder))
+	if err != nil {
+		e.handleFatalError(errors.Wrap(err, "failed to load walked files"))
+		return false
+	}
+
+	// Files a resumed session scheduled before the walk are queued already
+	files = slices.DeleteFunc(files, func(file *state.File) bool {
+		return file.Status != state.FileStatusPending
+	})
+
+	// Folders listed whole may hold more files than MaxFiles
+	if limit := e.config.MaxFiles; limit > 0 && len(files) > limit {
+		files = files[:limit]
+	}
+
+	e.logger.Info("Scheduling walked files", "count", len(files))
+
+	for start := 0; start < len(files); start += batchSize {
+		end := min(start+batchSize, len(files))
+		if !e.enqueueBatch(batches, files[start:end]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// waitForQueueRoom blocks while the download queue holds MaxQueuedFiles or
+// more files. It returns false once the sync is canceled.
+func (e *Engine) waitForQueueRoom() bool {
+	limit := e.config.MaxQueuedFiles
+	for limit > 0 && e.downloader.QueuedDownloads() >= limit {
+		select {
+		case <-e.ctx.Done():
+			return false
+		case <-time.After(queueRoomInterval):
+		}
+	}
+
+	return e.ctx.Err() == nil
+}
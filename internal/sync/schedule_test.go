@@ -0,0 +1,257 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestEngineCapsQueuedDownloads(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const (
+		fileCount = 120
+		batchSize = 10
+		maxQueued = 20
+	)
+	var rootFiles []*drive.File
+	for i := 0; i < fileCount; i++ {
+		rootFiles = append(rootFiles, &drive.File{
+			Id:       fmt.Sprintf("file-%03d", i),
+			Name:     fmt.Sprintf("file-%03d.bin", i),
+			MimeType: "application/octet-stream",
+			Size:     10,
+		})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.MaxConcurrent = 2
+	cfg.BatchSize = batchSize
+	cfg.MaxQueuedFiles = maxQueued
+	engine, err := NewEngine(newFakeDriveClient(t, map[string][]*drive.File{"root": rootFiles}, nil),
+		m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	var maxSeenQueued, maxSeenBacklog atomic.Int64
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		if queued := int64(engine.downloader.QueuedDownloads()); queued > maxSeenQueued.Load() {
+			maxSeenQueued.Store(queued)
+		}
+		if backlog := engine.GetProgress().ScheduleBacklog; backlog > maxSeenBacklog.Load() {
+			maxSeenBacklog.Store(backlog)
+		}
+		time.Sleep(time.Millisecond)
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int64(fileCount), session.CompletedFiles)
+
+	// A batch is scheduled whole once the queue drops below the cap
+	assert.Less(t, maxSeenQueued.Load(), int64(maxQueued+batchSize))
+
+	// Scanning ran ahead of the downloads instead of waiting for them
+	assert.Positive(t, maxSeenBacklog.Load())
+	assert.Zero(t, engine.GetProgress().ScheduleBacklog)
+}
+
+// runPhaseSync syncs a root file and two folders with one folder listed at
+// a time. Listing dir-b waits briefly for a download to start. It returns
+// the listings and downloads in the order they happened.
+func runPhaseSync(t *testing.T, scanThenDownload bool) []string {
+	t.Helper()
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "dir-a", Name: "a", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "dir-b", Name: "b", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "top", Name: "top.txt", MimeType: "text/plain", Size: 4},
+		},
+		"dir-a": {{Id: "a-1", Name: "a-1.txt", MimeType: "text/plain", Size: 4}},
+		"dir-b": {{Id: "b-1", Name: "b-1.txt", MimeType: "text/plain", Size: 4}},
+	}
+
+	var events []string
+	var mu sync.Mutex
+	record := func(event string) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	}
+
+	downloadStarted := make(chan struct{})
+	var startOnce sync.Once
+
+	client := newFakeDriveClient(t, children, func(_ *http.Request, folderID string) {
+		if folderID == "dir-b" {
+			select {
+			case <-downloadStarted:
+			case <-time.After(300 * time.Millisecond):
+			}
+		}
+		record("list:" + folderID)
+	})
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.WalkerConfig.Concurrency = 1
+	cfg.WalkerConfig.PageDelay = 0
+	cfg.BatchSize = 1
+	cfg.ScanThenDownload = scanThenDownload
+	engine, err := NewEngine(client, newTestStateManager(t), errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		record("download:" + file.DriveID)
+		startOnce.Do(func() { close(downloadStarted) })
+		return file.Size, nil
+	}
+
+	_, err = engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return events
+}
+
+func TestEngineDownloadsWhileScanningByDefault(t *testing.T) {
+	events := runPhaseSync(t, false)
+
+	require.Len(t, events, 6)
+	assert.Less(t, slices.Index(events, "download:top"), slices.Index(events, "list:dir-b"))
+}
+
+func TestEngineScanThenDownloadFinishesWalkFirst(t *testing.T) {
+	events := runPhaseSync(t, true)
+
+	require.Len(t, events, 6)
+	for i, event := range events {
+		if i < 3 {
+			assert.True(t, strings.HasPrefix(event, "list:"), "event %d is %s", i, event)
+		} else {
+			assert.True(t, strings.HasPrefix(event, "download:"), "event %d is %s", i, event)
+		}
+	}
+}
+
+func TestEngineScanThenDownloadResumeSchedulesFilesOnce(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "dir-a", Name: "a", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "top", Name: "top.txt", MimeType: "text/plain", Size: 4},
+		},
+		"dir-a": {{Id: "a-1", Name: "a-1.txt", MimeType: "text/plain", Size: 4}},
+	}
+
+	// The file listed by the first run is still downloading when the
+	// resumed walk ends and its files are scheduled
+	walkedDownloaded := make(chan struct{})
+	var walkedOnce sync.Once
+	var downloads sync.Map
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		count, _ := downloads.LoadOrStore(file.DriveID, new(atomic.Int32))
+		count.(*atomic.Int32).Add(1)
+		switch file.DriveID {
+		case "top":
+			select {
+			case <-walkedDownloaded:
+			case <-time.After(5 * time.Second):
+			}
+		case "a-1":
+			walkedOnce.Do(func() { close(walkedDownloaded) })
+		}
+		return file.Size, nil
+	}
+
+	// The first run is stopped while listing dir-a, after the root was scanned
+	first := newTestEngine(t, m, download)
+	first.config.ScanThenDownload = true
+	first.config.WalkerConfig.Concurrency = 1
+
+	var interrupt sync.Once
+	client := newFakeDriveClient(t, children, func(r *http.Request, folderID string) {
+		if folderID != "dir-a" {
+			return
+		}
+		interrupted := false
+		interrupt.Do(func() {
+			interrupted = true
+			go first.Stop()
+		})
+		if interrupted {
+			<-r.Context().Done()
+		}
+	})
+	first.client = client
+
+	sessionID, err := first.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-first.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("first run did not stop")
+	}
+	require.NoError(t, m.UpdateSessionStatus(ctx, sessionID, state.SessionStatusActive))
+
+	second := newTestEngine(t, m, download)
+	second.config.ScanThenDownload = true
+	second.client = client
+	require.NoError(t, second.ResumeSession(ctx, sessionID))
+
+	select {
+	case <-second.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	for _, id := range []string{"top", "a-1"} {
+		count, ok := downloads.Load(id)
+		require.True(t, ok, id)
+		assert.Equal(t, int32(1), count.(*atomic.Int32).Load(), "downloads of %s", id)
+	}
+
+	final, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompleted, final.Status)
+	assert.Equal(t, int64(2), final.CompletedFiles)
+}
@@ -0,0 +1,204 @@
+/**
+ * Session Settings for CloudPull Sync Engine
+ *
+ * Features:
+ * - Records the settings a session was started with in the state database
+ * - Applies them again when the session is resumed or retried
+ * - Validates overrides set for a single session
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+)
+
+// sessionSetting maps one stored session setting to the engine configuration.
+type sessionSetting struct {
+	get func(config *EngineConfig) string
+	set func(config *EngineConfig, value string) error
+}
+
+// sessionSettings are the settings stored with every session, keyed by the
+// name used in the session_config table.
+var sessionSettings = map[string]sessionSetting{
+	"max_concurrent": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.DownloadConfig.MaxConcurrent) },
+		set: positiveInt(func(c *EngineConfig, v int) { c.DownloadConfig.MaxConcurrent = v }),
+	},
+	"walker_concurrency": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.WalkerConfig.Concurrency) },
+		set: positiveInt(func(c *EngineConfig, v int) { c.WalkerConfig.Concurrency = v }),
+	},
+	"max_errors": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.MaxErrors) },
+		set: positiveInt(func(c *EngineConfig, v int) { c.MaxErrors = v }),
+	},
+	"max_consecutive_errors": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.MaxConsecutiveErrors) },
+		set: nonNegativeInt64(func(c *EngineConfig, v int64) { c.MaxConsecutiveErrors = int(v) }),
+	},
+	"batch_size": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.BatchSize) },
+		set: positiveInt(func(c *EngineConfig, v int) { c.BatchSize = v }),
+	},
+	"max_queued_files": {
+		get: func(c *EngineConfig) string { return strconv.Itoa(c.MaxQueuedFiles) },
+		set: nonNegativeInt64(func(c *EngineConfig, v int64) { c.MaxQueuedFiles = int(v) }),
+	},
+	"bandwidth_limit": {
+		get: func(c *EngineConfig) string { return strconv.FormatInt(c.BandwidthLimit, 10) },
+		set: nonNegativeInt64(func(c *EngineConfig, v int64) { c.BandwidthLimit = v }),
+	},
+	"max_total_bytes": {
+		get: func(c *EngineConfig) string { return strconv.FormatInt(c.MaxTotalBytes, 10) },
+		set: nonNegativeInt64(func(c *EngineConfig, v int64) { c.MaxTotalBytes = v }),
+	},
+	"checksum_algorithm": {
+		get: func(c *EngineConfig) string { return string(c.DownloadConfig.ChecksumAlgorithm) },
+		set: func(c *EngineConfig, value string) error {
+			algorithm, err := ParseChecksumAlgorithm(value)
+			if err != nil {
+				return err
+			}
+			c.DownloadConfig.ChecksumAlgorithm = algorithm
+			return nil
+		},
+	},
+	"schedule_order": {
+		get: func(c *EngineConfig) string { return string(c.DownloadConfig.ScheduleOrder) },
+		set: func(c *EngineConfig, value string) error {
+			order, err := ParseScheduleOrder(value)
+			if err != nil {
+				return err
+			}
+			c.DownloadConfig.ScheduleOrder = order
+			return nil
+		},
+	},
+	"continue_on_errors": {
+		get: func(c *EngineConfig) string { return strconv.FormatBool(c.ContinueOnErrors) },
+		set: boolean(func(c *EngineConfig, v bool) { c.ContinueOnErrors = v }),
+	},
+	"skip_permission_errors": {
+		get: func(c *EngineConfig) string { return strconv.FormatBool(c.SkipPermissionErrors) },
+		set: boolean(func(c *EngineConfig, v bool) { c.SkipPermissionErrors = v }),
+	},
+}
+
+func positiveInt(set func(*EngineConfig, int)) func(*EngineConfig, string) error {
+	return func(c *EngineConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return errors.Errorf("invalid positive number %q", value)
+		}
+		set(c, n)
+		return nil
+	}
+}
+
+func nonNegativeInt64(set func(*EngineConfig, int64)) func(*EngineConfig, string) error {
+	return func(c *EngineConfig, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return errors.Errorf("invalid non-negative number %q", value)
+		}
+		set(c, n)
+		return nil
+	}
+}
+
+func boolean(set func(*EngineConfig, bool)) func(*EngineConfig, string) error {
+	return func(c *EngineConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("invalid boolean %q", value)
+		}
+		set(c, b)
+		return nil
+	}
+}
+
+// SessionConfigKeys returns the settings stored with every session, sorted.
+func SessionConfigKeys() []string {
+	keys := make([]string, 0, len(sessionSettings))
+	for key := range sessionSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateSessionConfig checks that value can be stored as the session
+// setting key.
+func ValidateSessionConfig(key, value string) error {
+	setting, ok := sessionSettings[key]
+	if !ok {
+		return errors.Errorf("unknown session setting %q", key)
+	}
+
+	// Parse into a scratch config so nothing is changed
+	scratch := DefaultEngineConfig()
+	if err := setting.set(scratch, value); err != nil {
+		return errors.Wrapf(err, "invalid value for %s", key)
+	}
+	return nil
+}
+
+// ensureSubConfigs fills in the walker and download settings left nil.
+func (c *EngineConfig) ensureSubConfigs() {
+	if c.WalkerConfig == nil {
+		c.WalkerConfig = DefaultWalkerConfig()
+	}
+	if c.DownloadConfig == nil {
+		c.DownloadConfig = DefaultDownloadManagerConfig()
+	}
+}
+
+// saveSessionConfig records the settings the current session starts with.
+func (e *Engine) saveSessionConfig(ctx context.Context) error {
+	e.config.ensureSubConfigs()
+
+	for _, key := range SessionConfigKeys() {
+		value := sessionSettings[key].get(e.config)
+		if err := e.stateManager.SetSessionConfig(ctx, e.sessionID, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSessionConfig applies the settings stored with the current session
+// over the engine configuration. Sessions started before settings were
+// stored keep the current configuration.
+func (e *Engine) loadSessionConfig(ctx context.Context) error {
+	stored, err := e.stateManager.GetSessionConfig(ctx, e.sessionID)
+	if err != nil {
+		return err
+	}
+
+	e.config.ensureSubConfigs()
+
+	for key, value := range stored {
+		setting, ok := sessionSettings[key]
+		if !ok {
+			e.logger.Warn("Ignoring unknown session setting", "key", key)
+			continue
+		}
+		if err := setting.set(e.config, value); err != nil {
+			e.logger.Warn("Ignoring invalid session setting",
+				"key", key,
+				"value", value,
+				"error", err,
+			)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestResumedSessionKeepsItsStoredSettings(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+	require.NoError(t, m.Files().CreateBatch(ctx, []*state.File{{DriveID: "file-a", FolderID: folder.ID,
+		SessionID: session.ID, Name: "a.txt", Path: "root/a.txt", Size: 10, Status: state.FileStatusPending}}))
+
+	// The session was started with two workers and SHA-256 checksums
+	first := newTestEngine(t, m, nil)
+	first.config.DownloadConfig.MaxConcurrent = 2
+	first.config.DownloadConfig.ChecksumAlgorithm = ChecksumSHA256
+	first.sessionID = session.ID
+	require.NoError(t, first.saveSessionConfig(ctx))
+
+	stored, err := m.GetSessionConfig(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "2", stored["max_concurrent"])
+	assert.Equal(t, "sha256", stored["checksum_algorithm"])
+	assert.Len(t, stored, len(SessionConfigKeys()))
+
+	// Overrides set for the session win over the stored settings
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "max_errors", "7"))
+	require.NoError(t, m.SetSessionConfig(ctx, session.ID, "retired_setting", "1"))
+
+	download := func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	}
+	resumed := newTestEngine(t, m, download)
+	require.NoError(t, resumed.ResumeSession(ctx, session.ID))
+
+	select {
+	case <-resumed.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("resumed run did not terminate")
+	}
+
+	assert.Equal(t, 2, resumed.config.DownloadConfig.MaxConcurrent)
+	assert.Equal(t, ChecksumSHA256, resumed.config.DownloadConfig.ChecksumAlgorithm)
+	assert.Equal(t, 7, resumed.config.MaxErrors)
+	assert.Equal(t, 2, resumed.downloader.maxConcurrent)
+}
+
+func TestValidateSessionConfig(t *testing.T) {
+	assert.NoError(t, ValidateSessionConfig("max_concurrent", "4"))
+	assert.NoError(t, ValidateSessionConfig("checksum_algorithm", "both"))
+	assert.NoError(t, ValidateSessionConfig("bandwidth_limit", "0"))
+
+	assert.ErrorContains(t, ValidateSessionConfig("max_concurrent", "0"), "invalid value for max_concurrent")
+	assert.ErrorContains(t, ValidateSessionConfig("checksum_algorithm", "crc"), "unknown checksum algorithm")
+	assert.ErrorContains(t, ValidateSessionConfig("destination", "/tmp"), "unknown session setting")
+}
@@ -0,0 +1,97 @@
+/**
+ * Batched File Status Updates for CloudPull Sync Engine
+ *
+ * Features:
+ * - Buffers the file statuses recorded by download results
+ * - Coalesces repeated updates of a file to its latest status
+ * - Flushes in a single transaction by count or age
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+// statusBatch buffers file status updates until they are flushed together.
+type statusBatch struct {
+	stateManager *state.Manager
+	logger       *logger.Logger
+	pending      map[string]string // file ID -> latest status
+	oldest       time.Time
+	maxSize      int
+	maxAge       time.Duration
+	mu           sync.Mutex
+}
+
+func newStatusBatch(stateManager *state.Manager, logger *logger.Logger, maxSize int, maxAge time.Duration) *statusBatch {
+	return &statusBatch{
+		stateManager: stateManager,
+		logger:       logger,
+		pending:      make(map[string]string),
+		maxSize:      maxSize,
+		maxAge:       maxAge,
+	}
+}
+
+// add records the status of a file and flushes once the batch is full.
+func (b *statusBatch) add(fileID, status string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) == 0 {
+		b.oldest = time.Now()
+	}
+	b.pending[fileID] = status
+
+	if len(b.pending) >= b.maxSize {
+		b.flushLocked()
+	}
+}
+
+// forget drops a buffered status of a file whose record is written
+// directly, so the buffered status cannot overwrite it.
+func (b *statusBatch) forget(fileID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.pending, fileID)
+}
+
+// flushIfDue flushes a batch that has waited longer than maxAge.
+func (b *statusBatch) flushIfDue() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pending) > 0 && time.Since(b.oldest) >= b.maxAge {
+		b.flushLocked()
+	}
+}
+
+// flush writes every buffered status.
+func (b *statusBatch) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushLocked()
+}
+
+func (b *statusBatch) flushLocked() {
+	if len(b.pending) == 0 {
+		return
+	}
+
+	// Flushes also run during shutdown, after the pool context is canceled
+	if err := b.stateManager.UpdateFileStatuses(context.Background(), b.pending); err != nil {
+		b.logger.Error(err, "Failed to update file statuses", "count", len(b.pending))
+	}
+	b.pending = make(map[string]string)
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestStatusBatchCoalescesAndFlushesWhenFull(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+	folder := &state.Folder{DriveID: "root", SessionID: session.ID, Name: "root", Path: "root",
+		Status: state.FolderStatusScanned}
+	require.NoError(t, m.CreateFolder(ctx, folder))
+	var files []*state.File
+	for _, id := range []string{"a", "b", "c"} {
+		files = append(files, &state.File{DriveID: id, FolderID: folder.ID, SessionID: session.ID,
+			Name: id, Path: "root/" + id, Size: 1, Status: state.FileStatusPending})
+	}
+	require.NoError(t, m.Files().CreateBatch(ctx, files))
+
+	statusOf := func(file *state.File) string {
+		stored, err := m.Files().Get(ctx, file.ID)
+		require.NoError(t, err)
+		return stored.Status
+	}
+
+	batch := newStatusBatch(m, newTestLogger(), 2, time.Hour)
+
+	// A file written directly drops its buffered status
+	batch.add(files[2].ID, state.FileStatusQueued)
+	batch.forget(files[2].ID)
+
+	// A retried file is queued and then completed; only its last status counts
+	batch.add(files[0].ID, state.FileStatusQueued)
+	batch.add(files[0].ID, state.FileStatusCompleted)
+	assert.Equal(t, state.FileStatusPending, statusOf(files[0]))
+
+	batch.add(files[1].ID, state.FileStatusCompleted)
+	assert.Equal(t, state.FileStatusCompleted, statusOf(files[0]))
+	assert.Equal(t, state.FileStatusCompleted, statusOf(files[1]))
+	assert.Equal(t, state.FileStatusPending, statusOf(files[2]))
+
+	// Nothing is due before the flush interval
+	batch.add(files[2].ID, state.FileStatusCompleted)
+	batch.flushIfDue()
+	assert.Equal(t, state.FileStatusPending, statusOf(files[2]))
+
+	batch.flush()
+	assert.Equal(t, state.FileStatusCompleted, statusOf(files[2]))
+}
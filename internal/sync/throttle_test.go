@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleReaderSharesLimitAcrossReaders(t *testing.T) {
+	const (
+		limit   = 1024 * 1024
+		readers = 4
+		size    = 300 * 1024
+	)
+
+	tracker := NewProgressTracker("session-1")
+	tracker.SetBandwidthLimit(limit)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := tracker.ThrottleReader(context.Background(), bytes.NewReader(make([]byte, size)))
+			n, err := io.Copy(io.Discard, r)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(size), n)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// The initial bucket is free; the rest is paced at the aggregate limit
+	burst := int64(limit / 10)
+	expected := time.Duration(float64(readers*size-burst) / limit * float64(time.Second))
+	assert.GreaterOrEqual(t, elapsed, expected-100*time.Millisecond)
+	assert.Less(t, elapsed, expected+time.Second)
+}
+
+func TestThrottleReaderUnlimited(t *testing.T) {
+	tracker := NewProgressTracker("session-1")
+
+	r := tracker.ThrottleReader(context.Background(), bytes.NewReader(make([]byte, 10*1024*1024)))
+	start := time.Now()
+	n, err := io.Copy(io.Discard, r)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10*1024*1024), n)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestThrottleReaderStopsOnCancel(t *testing.T) {
+	tracker := NewProgressTracker("session-1")
+	tracker.SetBandwidthLimit(minThrottleBurst)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	r := tracker.ThrottleReader(ctx, bytes.NewReader(make([]byte, 1024*1024)))
+	_, err := io.Copy(io.Discard, r)
+	assert.Error(t, err)
+}
+
+func TestSharedBandwidthLimiterCapsManagers(t *testing.T) {
+	const (
+		shared  = 1024 * 1024
+		session = 4 * 1024 * 1024
+		size    = 300 * 1024
+	)
+
+	limiter := NewSharedBandwidthLimiter(shared)
+	var managers []*DownloadManager
+	for _, id := range []string{"session-1", "session-2"} {
+		tracker := NewProgressTracker(id)
+		tracker.SetBandwidthLimit(session)
+		dm, err := NewDownloadManager(nil, nil, tracker, nil, newTestLogger(),
+			&DownloadManagerConfig{TempDir: t.TempDir(), SharedBandwidth: limiter})
+		require.NoError(t, err)
+		managers = append(managers, dm)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, dm := range managers {
+		wg.Add(1)
+		go func(dm *DownloadManager) {
+			defer wg.Done()
+			r := dm.throttle(context.Background(), PriorityTierNormal, bytes.NewReader(make([]byte, size)))
+			n, err := io.Copy(io.Discard, r)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(size), n)
+		}(dm)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Each session alone could go four times faster; together they are
+	// held to the shared limit
+	burst := int64(shared / 10)
+	expected := time.Duration(float64(int64(len(managers)*size)-burst) / shared * float64(time.Second))
+	assert.GreaterOrEqual(t, elapsed, expected-100*time.Millisecond)
+	assert.Less(t, elapsed, expected+time.Second)
+
+	// Lifting the shared limit leaves only the session limits
+	limiter.SetLimit(0)
+	assert.Zero(t, limiter.Limit())
+}
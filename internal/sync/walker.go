@@ -5,7 +5,9 @@
  * - Streaming folder traversal without loading entire tree
  * - Support for BFS and DFS traversal strategies
  * - Pagination support for large folders (1000 items per page)
+ * - Bounded concurrent folder listings and jittered page requests
  * - Folder filtering patterns
+ * - Per-folder .cloudpullignore files
  * - Google Drive shortcuts handling
  * - Progress reporting during traversal
  *
@@ -18,8 +20,10 @@ package sync
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -50,6 +54,38 @@ type WalkerConfig struct {
 	Concurrency       int
 	ChannelBufferSize int
 	FollowShortcuts   bool
+
+	// MaxConcurrentFolders bounds the folders listed at once during a BFS
+	// walk (0 = Concurrency)
+	MaxConcurrentFolders int
+
+	// PageDelay is the average pause between page requests for the same
+	// folder; each pause is jittered by ±50% (0 = no pause)
+	PageDelay time.Duration
+
+	// ExportFormats lists the export formats per Google MIME type; the
+	// first format replaces the default export (see ParseExportFormats)
+	ExportFormats map[string][]string
+
+	// RespectIgnoreFiles leaves out entries matched by the .cloudpullignore
+	// files found during the walk; global patterns are applied first
+	RespectIgnoreFiles bool
+
+	// StarredOnly lists only starred files; every folder is still walked
+	StarredOnly bool
+
+	// SkipHidden leaves out entries whose name starts with a dot and files
+	// in the application data folder
+	SkipHidden bool
+
+	// OnlyGoogleDocs skips every file that is not a Google Workspace file,
+	// SkipGoogleDocs every file that is; at most one of them may be set
+	OnlyGoogleDocs bool
+	SkipGoogleDocs bool
+
+	// InsertBatchSize is the number of file records saved per transaction
+	// when a folder's listing is stored (0 = the whole folder at once)
+	InsertBatchSize int
 }
 
 // DefaultWalkerConfig returns default walker configuration.
@@ -60,6 +96,7 @@ func DefaultWalkerConfig() *WalkerConfig {
 		FollowShortcuts:   false,
 		Concurrency:       3,
 		ChannelBufferSize: 100,
+		PageDelay:         100 * time.Millisecond,
 	}
 }
 
@@ -71,15 +108,21 @@ type FolderWalker struct {
 	stateManager    *state.Manager
 	progressTracker *ProgressTracker
 	logger          *logger.Logger
-	client          *api.DriveClient
+	client          api.DriveAPI
 	excludeRegexps  []*regexp.Regexp
 	includeRegexps  []*regexp.Regexp
 	errors          []error
+	claimedFiles    map[string]string
 	wg              sync.WaitGroup
 	foldersScanned  int64
 	filesFound      int64
+	filesSkipped    int64
 	totalSize       int64
 	mu              sync.RWMutex
+	resumed         bool
+
+	// createFiles saves file records; nil uses the state manager
+	createFiles func(ctx context.Context, files []*state.File) error
 }
 
 // WalkResult represents a folder walk result.
@@ -94,7 +137,7 @@ type WalkResult struct {
 
 // NewFolderWalker creates a new folder walker.
 func NewFolderWalker(
-	client *api.DriveClient,
+	client api.DriveAPI,
 	stateManager *state.Manager,
 	progressTracker *ProgressTracker,
 	logger *logger.Logger,
@@ -105,12 +148,17 @@ func NewFolderWalker(
 		config = DefaultWalkerConfig()
 	}
 
+	if config.OnlyGoogleDocs && config.SkipGoogleDocs {
+		return nil, errors.Errorf("only Google Docs and skip Google Docs are mutually exclusive")
+	}
+
 	walker := &FolderWalker{
 		config:          config,
 		client:          client,
 		stateManager:    stateManager,
 		progressTracker: progressTracker,
 		logger:          logger,
+		claimedFiles:    make(map[string]string),
 	}
 
 	// Compile include patterns
@@ -140,10 +188,54 @@ func NewFolderWalker(
 	return walker, nil
 }
 
+// folderTask is a folder queued for scanning. Folder is nil for the root of
+// a new walk; otherwise it is the record saved when the folder was found.
+// Ignore holds the ignore rules of its parents, or nil if they still need
+// to be loaded. Name is the root's name when already known, so it is not
+// looked up again.
+type folderTask struct {
+	folder   *state.Folder
+	ignore   *ignoreRules
+	folderID string
+	name     string
+	depth    int
+}
+
 // Walk starts walking the folder tree from the given root.
 func (fw *FolderWalker) Walk(ctx context.Context, rootFolderID string, sessionID string) (<-chan *WalkResult, error) {
+	return fw.WalkNamed(ctx, rootFolderID, "", sessionID)
+}
+
+// WalkNamed is Walk for a root whose name is already known, such as from
+// its session, saving the metadata request for it.
+func (fw *FolderWalker) WalkNamed(ctx context.Context, rootFolderID, rootFolderName, sessionID string) (<-chan *WalkResult, error) {
 	fw.logger.Debug("Walk called", "rootFolderID", rootFolderID, "sessionID", sessionID, "strategy", fw.config.Strategy)
 
+	return fw.start(ctx, sessionID, []*folderTask{{folderID: rootFolderID, name: rootFolderName, ignore: &ignoreRules{}}})
+}
+
+// WalkFolders continues an interrupted walk from folders that were found
+// but not fully scanned, such as those returned by GetUnscannedFolders.
+func (fw *FolderWalker) WalkFolders(ctx context.Context, sessionID string, folders []*state.Folder) (<-chan *WalkResult, error) {
+	fw.logger.Debug("WalkFolders called", "sessionID", sessionID, "folders", len(folders), "strategy", fw.config.Strategy)
+
+	// Files found before the interruption are only known to the database
+	fw.resumed = true
+
+	tasks := make([]*folderTask, 0, len(folders))
+	for _, folder := range folders {
+		tasks = append(tasks, &folderTask{
+			folder:   folder,
+			folderID: folder.DriveID,
+			depth:    strings.Count(folder.Path, string(filepath.Separator)),
+		})
+	}
+
+	return fw.start(ctx, sessionID, tasks)
+}
+
+// start traverses the folders of tasks and everything beneath them.
+func (fw *FolderWalker) start(ctx context.Context, sessionID string, tasks []*folderTask) (<-chan *WalkResult, error) {
 	// Create cancellable context
 	fw.ctx, fw.cancel = context.WithCancel(ctx)
 
@@ -155,11 +247,16 @@ func (fw *FolderWalker) Walk(ctx context.Context, rootFolderID string, sessionID
 	case TraversalBFS:
 		fw.logger.Debug("Starting BFS traversal")
 		fw.wg.Add(1)
-		go fw.walkBFS(rootFolderID, sessionID, resultChan)
+		go fw.walkBFS(tasks, sessionID, resultChan)
 	case TraversalDFS:
 		fw.logger.Debug("Starting DFS traversal")
 		fw.wg.Add(1)
-		go fw.walkDFS(rootFolderID, sessionID, "", 0, resultChan)
+		go func() {
+			defer fw.wg.Done()
+			for _, task := range tasks {
+				fw.walkDFS(task, sessionID, resultChan)
+			}
+		}()
 	default:
 		close(resultChan)
 		return nil, fmt.Errorf("unknown traversal strategy: %v", fw.config.Strategy)
@@ -191,117 +288,114 @@ func (fw *FolderWalker) GetStats() *WalkerStats {
 	return &WalkerStats{
 		FoldersScanned: fw.foldersScanned,
 		FilesFound:     fw.filesFound,
+		FilesSkipped:   fw.filesSkipped,
 		TotalSize:      fw.totalSize,
 		ErrorCount:     len(fw.errors),
 	}
 }
 
 // walkBFS performs breadth-first search traversal.
-func (fw *FolderWalker) walkBFS(rootFolderID string, sessionID string, resultChan chan<- *WalkResult) {
+func (fw *FolderWalker) walkBFS(tasks []*folderTask, sessionID string, resultChan chan<- *WalkResult) {
 	defer fw.wg.Done()
-	fw.logger.Debug("walkBFS started", "rootFolderID", rootFolderID, "sessionID", sessionID)
-
-	type folderTask struct {
-		folderID   string
-		parentPath string
-		depth      int
-	}
+	fw.logger.Debug("walkBFS started", "folders", len(tasks), "sessionID", sessionID)
 
 	// Queue for BFS
 	queue := make(chan *folderTask, fw.config.ChannelBufferSize)
 
-	// Track active tasks
+	// Track queued and active tasks
 	var activeTasksWg sync.WaitGroup
-	activeTasksWg.Add(1) // Start with 1 for the root folder
 
-	// Start workers
+	// Sends happen in their own goroutine so workers never block each other
+	// on a full queue
+	enqueue := func(task *folderTask) {
+		activeTasksWg.Add(1)
+		go func() {
+			select {
+			case queue <- task:
+			case <-fw.ctx.Done():
+				activeTasksWg.Done()
+			}
+		}()
+	}
+
 	workers := fw.config.Concurrency
+	if workers <= 0 {
+		workers = DefaultWalkerConfig().Concurrency
+	}
+
+	// Folder listings are bounded separately from the workers, so Drive
+	// never sees more than this many folders paginated at once
+	maxFolders := fw.config.MaxConcurrentFolders
+	if maxFolders <= 0 {
+		maxFolders = workers
+	}
+	folderSlots := make(chan struct{}, maxFolders)
+
+	// scan processes one folder and returns its subfolders to queue
+	scan := func(task *folderTask) []*folderTask {
+		if fw.ctx.Err() != nil {
+			return nil
+		}
+
+		select {
+		case folderSlots <- struct{}{}:
+		case <-fw.ctx.Done():
+			return nil
+		}
+		folder, files, subfolders, err := fw.processFolder(task, sessionID)
+		<-folderSlots
+
+		// Send result
+		result := &WalkResult{
+			Folder: folder,
+			Files:  files,
+			Error:  err,
+			Depth:  task.depth,
+		}
+
+		select {
+		case resultChan <- result:
+		case <-fw.ctx.Done():
+			return nil
+		}
+
+		if err != nil {
+			return nil
+		}
+		return subfolders
+	}
+
+	// Start workers
 	workerWg := sync.WaitGroup{}
 	fw.logger.Debug("Starting workers", "count", workers)
 
 	for i := 0; i < workers; i++ {
 		workerWg.Add(1)
-		go func(workerID int) {
+		go func() {
 			defer workerWg.Done()
 
 			for task := range queue {
-				if fw.ctx.Err() != nil {
-					activeTasksWg.Done()
-					return
-				}
-
-				// Process folder
-				folder, files, subfolders, err := fw.processFolder(
-					task.folderID,
-					task.parentPath,
-					sessionID,
-					task.depth,
-				)
-
-				// Send result
-				result := &WalkResult{
-					Folder: folder,
-					Files:  files,
-					Error:  err,
-					Depth:  task.depth,
-				}
-
-				select {
-				case resultChan <- result:
-				case <-fw.ctx.Done():
-					activeTasksWg.Done()
-					return
-				}
-
-				// Queue subfolders if within depth limit
-				withinDepthLimit := fw.config.MaxDepth == -1 || fw.config.MaxDepth == 0 || task.depth < fw.config.MaxDepth
-				fw.logger.Info("Checking subfolder queueing",
-					"err", err,
-					"subfolders_count", len(subfolders),
-					"current_depth", task.depth,
-					"max_depth", fw.config.MaxDepth,
-					"depth_check", withinDepthLimit,
-				)
-				if err == nil && withinDepthLimit {
-					fw.logger.Info("Queueing subfolders",
+				subfolders := scan(task)
+				if len(subfolders) > 0 {
+					fw.logger.Debug("Queueing subfolders",
 						"count", len(subfolders),
 						"parent_folder", task.folderID,
 						"current_depth", task.depth,
-						"max_depth", fw.config.MaxDepth,
 					)
-					for _, subfolder := range subfolders {
-						activeTasksWg.Add(1) // Add before queuing
-						subTask := &folderTask{
-							folderID:   subfolder.ID,
-							parentPath: filepath.Join(task.parentPath, subfolder.Name),
-							depth:      task.depth + 1,
-						}
-
-						fw.logger.Debug("Queueing subfolder task",
-							"folder_id", subfolder.ID,
-							"folder_name", subfolder.Name,
-							"depth", subTask.depth,
-						)
-
-						select {
-						case queue <- subTask:
-						case <-fw.ctx.Done():
-							activeTasksWg.Done()
-							return
-						}
-					}
+				}
+
+				for _, subfolder := range subfolders {
+					enqueue(subfolder)
 				}
 
 				activeTasksWg.Done() // Mark this task as done
 			}
-		}(i)
+		}()
 	}
 
-	// Start with root folder
-	queue <- &folderTask{
-		folderID:   rootFolderID,
-		parentPath: "",
-		depth:      0,
+	// Start with the given folders
+	for _, task := range tasks {
+		enqueue(task)
 	}
 
 	// Close queue when all tasks are done
@@ -315,37 +409,21 @@ func (fw *FolderWalker) walkBFS(rootFolderID string, sessionID string, resultCha
 }
 
 // walkDFS performs depth-first search traversal.
-func (fw *FolderWalker) walkDFS(
-	folderID string,
-	sessionID string,
-	parentPath string,
-	depth int,
-	resultChan chan<- *WalkResult,
-) {
-
-	if depth == 0 {
-		defer fw.wg.Done()
-	}
-
+func (fw *FolderWalker) walkDFS(task *folderTask, sessionID string, resultChan chan<- *WalkResult) {
 	// Check context
 	if fw.ctx.Err() != nil {
 		return
 	}
 
-	// Check depth limit
-	if fw.config.MaxDepth > 0 && depth > fw.config.MaxDepth {
-		return
-	}
-
 	// Process folder
-	folder, files, subfolders, err := fw.processFolder(folderID, parentPath, sessionID, depth)
+	folder, files, subfolders, err := fw.processFolder(task, sessionID)
 
 	// Send result
 	result := &WalkResult{
 		Folder: folder,
 		Files:  files,
 		Error:  err,
-		Depth:  depth,
+		Depth:  task.depth,
 	}
 
 	select {
@@ -357,171 +435,532 @@ func (fw *FolderWalker) walkDFS(
 	// Recursively process subfolders
 	if err == nil {
 		for _, subfolder := range subfolders {
-			fw.walkDFS(
-				subfolder.ID,
-				sessionID,
-				filepath.Join(parentPath, subfolder.Name),
-				depth+1,
-				resultChan,
-			)
+			fw.walkDFS(subfolder, sessionID, resultChan)
 		}
 	}
 }
 
-// processFolder processes a single folder.
+// processFolder scans a single folder. Files are saved with the folder, and
+// subfolders within the depth limit are saved as pending folders before the
+// folder is marked scanned, so an interrupted walk can be continued. The
+// subfolders are returned as tasks to scan next.
 func (fw *FolderWalker) processFolder(
-	folderID string,
-	parentPath string,
+	task *folderTask,
 	sessionID string,
-	depth int,
-) (*state.Folder, []*state.File, []*api.FileInfo, error) {
+) (*state.Folder, []*state.File, []*folderTask, error) {
+
+	fw.logger.Debug("processFolder called", "folderID", task.folderID, "depth", task.depth)
 
-	fw.logger.Debug("processFolder called", "folderID", folderID, "parentPath", parentPath, "depth", depth)
+	folder := task.folder
+	var known map[string]bool
 
-	// Get folder metadata
-	var folderName string
+	if folder == nil {
+		// Subfolders come with their record, so only the root may still
+		// need its metadata
+		folderName := task.name
 
-	if folderID == "root" {
-		folderName = "root"
+		if task.folderID == "root" {
+			folderName = "root"
+		} else if folderName == "" {
+			fw.logger.Debug("Getting folder metadata from API", "folderID", task.folderID)
+			info, err := fw.client.GetFile(fw.ctx, task.folderID)
+			if err != nil {
+				fw.logger.Error(err, "Failed to get folder metadata", "folderID", task.folderID)
+				fw.mu.Lock()
+				fw.errors = append(fw.errors, err)
+				fw.mu.Unlock()
+				return nil, nil, nil, errors.Wrap(err, "failed to get folder metadata")
+			}
+			folderName = info.Name
+			fw.logger.Debug("Got folder metadata", "folderName", folderName)
+		}
+
+		// Check if folder should be skipped
+		if fw.shouldSkipFolder(folderName) {
+			return nil, nil, nil, nil
+		}
+
+		// Create folder record
+		folder = &state.Folder{
+			ID:        generateID(),
+			DriveID:   task.folderID,
+			SessionID: sessionID,
+			Name:      folderName,
+			Path:      folderName,
+			Status:    state.FolderStatusScanning,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+
+		// Save to database
+		if err := fw.stateManager.CreateFolder(fw.ctx, folder); err != nil {
+			fw.logger.Error(err, "Failed to create folder record",
+				"folder_id", task.folderID,
+				"folder_path", folder.Path,
+			)
+		}
 	} else {
-		fw.logger.Debug("Getting folder metadata from API", "folderID", folderID)
-		info, err := fw.client.GetFile(fw.ctx, folderID)
-		if err != nil {
-			fw.logger.Error(err, "Failed to get folder metadata", "folderID", folderID)
-			fw.mu.Lock()
-			fw.errors = append(fw.errors, err)
-			fw.mu.Unlock()
-			return nil, nil, nil, errors.Wrap(err, "failed to get folder metadata")
+		// A folder interrupted while scanning, or that failed after its
+		// files were saved, may already have some of its contents recorded;
+		// those are not recorded twice
+		if folder.Status == state.FolderStatusScanning || folder.Status == state.FolderStatusFailed {
+			var err error
+			if known, err = fw.knownChildren(folder); err != nil {
+				fw.logger.Warn("Failed to load previously scanned items",
+					"folder_path", folder.Path,
+					"error", err,
+				)
+			}
 		}
-		folderName = info.Name
-		fw.logger.Debug("Got folder metadata", "folderName", folderName)
+
+		folder.Status = state.FolderStatusScanning
+		fw.stateManager.UpdateFolder(fw.ctx, folder)
 	}
 
-	folderPath := filepath.Join(parentPath, folderName)
+	folderID := folder.DriveID
+	folderPath := folder.Path
 
-	// Check if folder should be skipped
-	if fw.shouldSkipFolder(folderPath) {
-		return nil, nil, nil, nil
-	}
+	// Notify progress tracker
+	fw.progressTracker.FolderStarted(folder.ID, folder.Name, folder.Path)
+
+	// failFolder records why the folder could not be scanned
+	failFolder := func(err error, message string) error {
+		folder.Status = state.FolderStatusFailed
+		folder.ErrorMessage = state.NewNullString(err.Error())
+		fw.stateManager.UpdateFolder(fw.ctx, folder)
+
+		fw.mu.Lock()
+		fw.errors = append(fw.errors, err)
+		fw.mu.Unlock()
 
-	// Create folder record
-	folder := &state.Folder{
-		ID:        generateID(),
-		DriveID:   folderID,
-		SessionID: sessionID,
-		Name:      folderName,
-		Path:      folderPath,
-		Status:    state.FolderStatusScanning,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		return errors.Wrap(err, message)
 	}
 
-	// Save to database
-	if err := fw.stateManager.CreateFolder(fw.ctx, folder); err != nil {
-		fw.logger.Error(err, "Failed to create folder record",
-			"folder_id", folderID,
-			"folder_path", folderPath,
-		)
+	var filter *api.ListFilter
+	if fw.config.StarredOnly {
+		filter = &api.ListFilter{StarredOnly: true}
 	}
 
-	// Notify progress tracker
-	fw.progressTracker.FolderStarted(folder.ID, folder.Name, folder.Path)
+	// Each page is recorded while the next one is listed. With ignore files,
+	// entries are recorded once every page is listed instead, since the
+	// folder's ignore file may be on any page.
+	var ignore *ignoreRules
+	if !fw.config.RespectIgnoreFiles {
+		ignore = &ignoreRules{}
+	}
 
-	// List folder contents with pagination
+	var listed []*api.FileInfo
 	var allFiles []*state.File
-	var subfolders []*api.FileInfo
-	pageToken := ""
-	pageCount := 0
+	var subfolderInfos []*api.FileInfo
 
-	for {
-		// Check context
-		if fw.ctx.Err() != nil {
-			return folder, allFiles, subfolders, fw.ctx.Err()
+	for page := range fw.listPages(folderID, filter) {
+		if page.err != nil {
+			return folder, nil, nil, failFolder(page.err, "failed to list folder contents")
+		}
+		if ignore == nil {
+			listed = append(listed, page.files...)
+			continue
 		}
 
-		// List files
-		files, nextPageToken, err := fw.client.ListFiles(fw.ctx, folderID, pageToken)
-		if err != nil {
+		files, subfolders := fw.recordEntries(folder, sessionID, page.files, known, ignore)
+		allFiles = append(allFiles, files...)
+		subfolderInfos = append(subfolderInfos, subfolders...)
+	}
+	if fw.ctx.Err() != nil {
+		return folder, nil, nil, fw.ctx.Err()
+	}
+
+	if ignore == nil {
+		var err error
+		if ignore, err = fw.folderIgnoreRules(task, folder, listed); err != nil {
+			return folder, nil, nil, failFolder(err, "failed to read ignore file")
+		}
+		allFiles, subfolderInfos = fw.recordEntries(folder, sessionID, listed, known, ignore)
+	}
+
+	// Save subfolders as pending so they survive an interruption
+	var subfolders []*state.Folder
+	if fw.withinDepthLimit(task.depth) {
+		subfolders = make([]*state.Folder, 0, len(subfolderInfos))
+		for _, info := range subfolderInfos {
+			subfolders = append(subfolders, &state.Folder{
+				DriveID:   info.ID,
+				ParentID:  state.NewNullString(folder.ID),
+				SessionID: sessionID,
+				Name:      info.Name,
+				Path:      filepath.Join(folderPath, info.Name),
+				Status:    state.FolderStatusPending,
+			})
+		}
+
+		if err := fw.stateManager.Folders().CreateBatch(fw.ctx, subfolders); err != nil {
+			fw.logger.Error(err, "Failed to create subfolder records",
+				"folder_id", folderID,
+				"subfolder_count", len(subfolders),
+			)
+
 			folder.Status = state.FolderStatusFailed
-			folder.ErrorMessage.Valid = true
-			folder.ErrorMessage.String = err.Error()
+			folder.ErrorMessage = state.NewNullString(err.Error())
 			fw.stateManager.UpdateFolder(fw.ctx, folder)
 
-			fw.mu.Lock()
-			fw.errors = append(fw.errors, err)
-			fw.mu.Unlock()
+			return folder, allFiles, nil, errors.Wrap(err, "failed to save subfolders")
+		}
+	}
+
+	// Update folder status
+	folder.Status = state.FolderStatusScanned
+	fw.stateManager.UpdateFolder(fw.ctx, folder)
+
+	// Update metrics
+	fw.mu.Lock()
+	fw.foldersScanned++
+	fw.mu.Unlock()
+
+	// Notify progress tracker
+	fw.progressTracker.FolderCompleted(folder.ID, folder.Name, folder.Path, int64(len(allFiles)))
+
+	tasks := make([]*folderTask, 0, len(subfolders))
+	for _, subfolder := range subfolders {
+		tasks = append(tasks, &folderTask{
+			folder:   subfolder,
+			ignore:   ignore,
+			folderID: subfolder.DriveID,
+			depth:    task.depth + 1,
+		})
+	}
+
+	return folder, allFiles, tasks, nil
+}
+
+// listedPage is one page of a folder listing, or the error that ended it.
+type listedPage struct {
+	err   error
+	files []*api.FileInfo
+}
+
+// listPages lists the pages of a folder in the background, so the next page
+// is requested while the previous one is recorded. The channel is closed
+// after the last page, an error or cancellation.
+func (fw *FolderWalker) listPages(folderID string, filter *api.ListFilter) <-chan listedPage {
+	pages := make(chan listedPage)
+
+	go func() {
+		defer close(pages)
 
-			return folder, allFiles, subfolders, errors.Wrap(err, "failed to list folder contents")
+		pageToken := ""
+		for pageCount := 0; ; pageCount++ {
+			if fw.ctx.Err() != nil {
+				return
+			}
+
+			// Spread out the pages of one folder to avoid per-folder throttling
+			if pageCount > 0 && !fw.waitBeforePage() {
+				return
+			}
+
+			files, nextPageToken, err := fw.client.ListFilesMatching(fw.ctx, folderID, pageToken, filter)
+			if err == nil {
+				fw.logger.Debug("Listed folder page",
+					"folder_id", folderID,
+					"page", pageCount+1,
+					"items", len(files),
+				)
+			}
+
+			select {
+			case pages <- listedPage{files: files, err: err}:
+			case <-fw.ctx.Done():
+				return
+			}
+
+			if err != nil || nextPageToken == "" {
+				return
+			}
+			pageToken = nextPageToken
 		}
+	}()
 
-		pageCount++
-		fw.logger.Debug("Listed folder page",
-			"folder_id", folderID,
-			"page", pageCount,
-			"items", len(files),
-		)
+	return pages
+}
 
-		// Process files
-		for _, fileInfo := range files {
-			if fileInfo.IsFolder {
-				// Handle shortcuts if configured
-				if !fw.config.FollowShortcuts && fw.isShortcut(fileInfo) {
-					fw.logger.Debug("Skipping shortcut folder",
-						"folder_id", fileInfo.ID,
-						"folder_name", fileInfo.Name,
-					)
-					continue
-				}
+// recordEntries saves the files among the listed entries of folder, filtered
+// files and hidden folders as skipped, and returns the files with the
+// subfolders to scan.
+func (fw *FolderWalker) recordEntries(
+	folder *state.Folder,
+	sessionID string,
+	entries []*api.FileInfo,
+	known map[string]bool,
+	ignore *ignoreRules,
+) ([]*state.File, []*api.FileInfo) {
+
+	folderID := folder.DriveID
+	folderPath := folder.Path
+
+	var allFiles []*state.File
+	var skippedFiles []*state.File
+	var skippedFolders []*state.Folder
+	var subfolderInfos []*api.FileInfo
 
-				fw.logger.Info("Found subfolder",
+	for _, fileInfo := range entries {
+		if known[fileInfo.ID] {
+			continue
+		}
+
+		if fileInfo.IsFolder {
+			// Handle shortcuts if configured
+			if !fw.config.FollowShortcuts && fw.isShortcut(fileInfo) {
+				fw.logger.Debug("Skipping shortcut folder",
 					"folder_id", fileInfo.ID,
 					"folder_name", fileInfo.Name,
-					"parent_folder", folderName,
 				)
-				subfolders = append(subfolders, fileInfo)
-			} else {
-				// Create file record
-				file := fw.createFileRecord(fileInfo, folder, sessionID, folderPath)
-				allFiles = append(allFiles, file)
+				continue
+			}
+
+			if fw.config.SkipHidden && isHidden(fileInfo) {
+				fw.logger.Debug("Skipping hidden folder",
+					"folder_id", fileInfo.ID,
+					"folder_name", fileInfo.Name,
+				)
+				skippedFolders = append(skippedFolders, &state.Folder{
+					DriveID:   fileInfo.ID,
+					ParentID:  state.NewNullString(folder.ID),
+					SessionID: sessionID,
+					Name:      fileInfo.Name,
+					Path:      filepath.Join(folderPath, fileInfo.Name),
+					Status:    state.FolderStatusSkipped,
+				})
+				continue
+			}
+
+			// Check if folder should be skipped
+			subfolderPath := filepath.Join(folderPath, fileInfo.Name)
+			if fw.shouldSkipFolder(subfolderPath) {
+				continue
+			}
+			if source := ignore.ignoredBy(subfolderPath, true); source != "" {
+				fw.logger.Debug("Skipping ignored folder",
+					"path", subfolderPath,
+					"ignore_file", source,
+				)
+				continue
+			}
+
+			fw.logger.Info("Found subfolder",
+				"folder_id", fileInfo.ID,
+				"folder_name", fileInfo.Name,
+				"parent_folder", folder.Name,
+			)
+			subfolderInfos = append(subfolderInfos, fileInfo)
+		} else {
+			// Create file record
+			file := fw.createFileRecord(fileInfo, folder, sessionID, folderPath)
+
+			// Filtered files are recorded as skipped so they still count
+			// towards the session totals
+			reason := fw.fileSkipReason(fileInfo, file.Path)
+			if source := ignore.ignoredBy(file.Path, false); reason == "" && source != "" {
+				reason = "ignored by " + source
+			}
+			if reason != "" && len(fileInfo.Parents) > 1 {
+				// Another of its paths may be included and record the file
+				fw.logger.Debug("Skipping filtered path of a file in several folders",
+					"file_id", file.DriveID,
+					"path", file.Path,
+					"reason", reason,
+				)
+				continue
+			}
+
+			// A file with several parents is downloaded once, to the first
+			// included path found; its other paths are recorded as aliases
+			if reason == "" {
+				if firstPath, claimed := fw.claimFile(sessionID, file.DriveID, file.Path); claimed {
+					fw.recordAlias(sessionID, file.DriveID, file.Path, firstPath)
+					continue
+				}
+			}
+			allFiles = append(allFiles, file)
+
+			if reason != "" {
+				file.Status = state.FileStatusSkipped
+				file.ErrorMessage = state.NewNullString(reason)
+				skippedFiles = append(skippedFiles, file)
 
-				// Update metrics
 				fw.mu.Lock()
 				fw.filesFound++
-				fw.totalSize += file.Size
+				fw.filesSkipped++
 				fw.mu.Unlock()
+				continue
 			}
-		}
 
-		// Check if more pages
-		if nextPageToken == "" {
-			break
+			// Update metrics
+			fw.mu.Lock()
+			fw.filesFound++
+			fw.totalSize += file.Size
+			fw.mu.Unlock()
 		}
-		pageToken = nextPageToken
 	}
 
-	// Batch save files to database
-	if len(allFiles) > 0 {
-		if err := fw.stateManager.CreateFiles(fw.ctx, allFiles); err != nil {
+	// Batch save files to database; large folders in several transactions
+	// so none holds the database for long
+	createFiles := fw.createFiles
+	if createFiles == nil {
+		createFiles = fw.stateManager.CreateFiles
+	}
+	batchSize := fw.config.InsertBatchSize
+	if batchSize <= 0 {
+		batchSize = len(allFiles)
+	}
+	for start := 0; start < len(allFiles); start += batchSize {
+		batch := allFiles[start:min(start+batchSize, len(allFiles))]
+		if err := createFiles(fw.ctx, batch); err != nil {
 			fw.logger.Error(err, "Failed to create file records",
 				"folder_id", folderID,
-				"file_count", len(allFiles),
+				"file_count", len(batch),
 			)
 		}
 	}
 
-	// Update folder status
-	folder.Status = state.FolderStatusScanned
-	fw.stateManager.UpdateFolder(fw.ctx, folder)
+	// Hidden folders are recorded so they are known but never listed
+	if err := fw.stateManager.Folders().CreateBatch(fw.ctx, skippedFolders); err != nil {
+		fw.logger.Error(err, "Failed to create skipped folder records",
+			"folder_id", folderID,
+			"folder_count", len(skippedFolders),
+		)
+	}
+
+	// Report skipped files once they have their database IDs
+	for _, file := range skippedFiles {
+		fw.progressTracker.FileSkipped(file.ID, file.Name, file.Path, file.ErrorMessage.String)
+	}
+
+	return allFiles, subfolderInfos
+}
+
+// folderIgnoreRules returns the ignore rules that apply to the entries
+// listed in folder: those inherited from its parents plus its own ignore
+// file, if any.
+func (fw *FolderWalker) folderIgnoreRules(task *folderTask, folder *state.Folder, listed []*api.FileInfo) (*ignoreRules, error) {
+	if !fw.config.RespectIgnoreFiles {
+		return &ignoreRules{}, nil
+	}
+
+	rules := task.ignore
+	if rules == nil {
+		var err error
+		if rules, err = fw.loadIgnoreRules(folder); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, info := range listed {
+		if info.IsFolder || info.Name != IgnoreFileName {
+			continue
+		}
+
+		content, err := fw.client.ReadFile(fw.ctx, info.ID, maxIgnoreFileSize)
+		if err != nil {
+			return nil, err
+		}
+		rules = rules.with(parseIgnoreFile(folder.Path, content))
+	}
+
+	return rules, nil
+}
+
+// waitBeforePage pauses for a jittered PageDelay before the next page of a
+// folder is requested. It returns false if the walk was canceled meanwhile.
+func (fw *FolderWalker) waitBeforePage() bool {
+	if fw.config.PageDelay <= 0 {
+		return true
+	}
+
+	// #nosec G404 - jitter does not need a secure random source
+	delay := time.Duration(float64(fw.config.PageDelay) * (0.5 + rand.Float64()))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-fw.ctx.Done():
+		return false
+	}
+}
+
+// withinDepthLimit reports whether subfolders of a folder at depth are walked.
+func (fw *FolderWalker) withinDepthLimit(depth int) bool {
+	return fw.config.MaxDepth <= 0 || depth < fw.config.MaxDepth
+}
+
+// knownChildren returns the Drive IDs of files and subfolders already
+// recorded for folder.
+func (fw *FolderWalker) knownChildren(folder *state.Folder) (map[string]bool, error) {
+	known := make(map[string]bool)
+
+	files, err := fw.stateManager.Files().GetByFolder(fw.ctx, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		known[file.DriveID] = true
+	}
+
+	children, err := fw.stateManager.Folders().GetChildren(fw.ctx, folder.ID, folder.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		known[child.DriveID] = true
+	}
+
+	return known, nil
+}
+
+// claimFile reserves the file with driveID for path. If another folder of
+// the session already lists the file, it returns that folder's path for the
+// file and true instead.
+func (fw *FolderWalker) claimFile(sessionID, driveID, path string) (string, bool) {
+	fw.mu.RLock()
+	firstPath, claimed := fw.claimedFiles[driveID]
+	fw.mu.RUnlock()
+	if claimed {
+		return firstPath, true
+	}
+
+	if fw.resumed {
+		existing, err := fw.stateManager.Files().GetByDriveID(fw.ctx, driveID, sessionID)
+		if err != nil {
+			fw.logger.Warn("Failed to look up file", "file_id", driveID, "error", err)
+		} else if existing != nil && existing.Path != path {
+			path = existing.Path
+			claimed = true
+		}
+	}
 
-	// Update metrics
 	fw.mu.Lock()
-	fw.foldersScanned++
-	fw.mu.Unlock()
+	defer fw.mu.Unlock()
+	if firstPath, ok := fw.claimedFiles[driveID]; ok {
+		return firstPath, true
+	}
+	fw.claimedFiles[driveID] = path
+	return path, claimed
+}
 
-	// Notify progress tracker
-	fw.progressTracker.FolderCompleted(folder.ID, folder.Name, folder.Path, int64(len(allFiles)))
+// recordAlias records path as a further path of a file downloaded to
+// firstPath.
+func (fw *FolderWalker) recordAlias(sessionID, driveID, path, firstPath string) {
+	fw.logger.Info("File is in several folders, downloading it once",
+		"file_id", driveID,
+		"path", path,
+		"downloaded_to", firstPath,
+	)
 
-	return folder, allFiles, subfolders, nil
+	alias := &state.FileAlias{SessionID: sessionID, DriveID: driveID, Path: path}
+	if err := fw.stateManager.Files().AddAlias(fw.ctx, alias); err != nil {
+		fw.logger.Error(err, "Failed to record file alias", "file_id", driveID, "path", path)
+	}
 }
 
 // shouldSkipFolder checks if a folder should be skipped based on patterns.
@@ -557,6 +996,48 @@ func (fw *FolderWalker) shouldSkipFolder(folderPath string) bool {
 	return false
 }
 
+// fileSkipReason returns why a file is filtered out, or an empty string if
+// it should be downloaded.
+func (fw *FolderWalker) fileSkipReason(fileInfo *api.FileInfo, filePath string) string {
+	if !fw.config.FollowShortcuts && fw.isShortcut(fileInfo) {
+		return "shortcut"
+	}
+
+	if fw.config.SkipHidden && isHidden(fileInfo) {
+		return "hidden"
+	}
+
+	if fw.config.OnlyGoogleDocs && !fileInfo.CanExport {
+		return "not a Google Workspace file"
+	}
+	if fw.config.SkipGoogleDocs && fileInfo.CanExport {
+		return "Google Workspace file"
+	}
+
+	for _, re := range fw.excludeRegexps {
+		if re.MatchString(filePath) {
+			return "excluded by pattern " + re.String()
+		}
+	}
+
+	if len(fw.includeRegexps) > 0 {
+		for _, re := range fw.includeRegexps {
+			if re.MatchString(filePath) {
+				return ""
+			}
+		}
+		return "not matched by include patterns"
+	}
+
+	return ""
+}
+
+// isHidden reports whether a Drive entry is hidden: its name starts with a
+// dot or it is stored in the application data folder.
+func isHidden(fileInfo *api.FileInfo) bool {
+	return strings.HasPrefix(fileInfo.Name, ".") || slices.Contains(fileInfo.Spaces, "appDataFolder")
+}
+
 // isShortcut checks if a file is a Google Drive shortcut.
 func (fw *FolderWalker) isShortcut(fileInfo *api.FileInfo) bool {
 	return fileInfo.MimeType == "application/vnd.google-apps.shortcut" ||
@@ -612,6 +1093,9 @@ func (fw *FolderWalker) createFileRecord(
 		file.IsGoogleDoc = true
 		file.ExportMimeType.Valid = true
 		file.ExportMimeType.String = fileInfo.ExportFormat
+		if formats := fw.config.ExportFormats[fileInfo.MimeType]; len(formats) > 0 {
+			file.ExportMimeType.String = formats[0]
+		}
 	}
 
 	if !fileInfo.ModifiedTime.IsZero() {
@@ -619,6 +1103,15 @@ func (fw *FolderWalker) createFileRecord(
 		file.DriveModifiedTime.Time = fileInfo.ModifiedTime
 	}
 
+	if !fileInfo.CreatedTime.IsZero() {
+		file.DriveCreatedTime.Valid = true
+		file.DriveCreatedTime.Time = fileInfo.CreatedTime
+	}
+
+	if len(fileInfo.Owners) > 0 {
+		file.OwnerEmail = state.NewNullString(fileInfo.Owners[0])
+	}
+
 	return file
 }
 
@@ -626,6 +1119,7 @@ func (fw *FolderWalker) createFileRecord(
 type WalkerStats struct {
 	FoldersScanned int64
 	FilesFound     int64
+	FilesSkipped   int64
 	TotalSize      int64
 	ErrorCount     int
 }
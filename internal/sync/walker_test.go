@@ -0,0 +1,565 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+
+	"github.com/VatsalSy/CloudPull/internal/api"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func TestWalkerBoundsConcurrentFolderListings(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const folderCount = 12
+	children := map[string][]*drive.File{}
+	for i := 0; i < folderCount; i++ {
+		id := fmt.Sprintf("dir-%02d", i)
+		children["root"] = append(children["root"], &drive.File{
+			Id: id, Name: id, MimeType: "application/vnd.google-apps.folder",
+		})
+		children[id] = []*drive.File{{Id: id + "-file", Name: "file.txt", MimeType: "text/plain", Size: 1}}
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	client := newFakeDriveClient(t, children, func(_ *http.Request, _ string) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			peak := maxInFlight.Load()
+			if n <= peak || maxInFlight.CompareAndSwap(peak, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	walker, err := NewFolderWalker(client, m, NewProgressTracker(session.ID), newTestLogger(), &WalkerConfig{
+		Strategy:             TraversalBFS,
+		Concurrency:          8,
+		ChannelBufferSize:    10,
+		MaxConcurrentFolders: 2,
+	})
+	require.NoError(t, err)
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(t, err)
+
+	files := 0
+	for result := range results {
+		require.NoError(t, result.Error)
+		files += len(result.Files)
+	}
+
+	assert.Equal(t, folderCount, files)
+	assert.Equal(t, int32(2), maxInFlight.Load())
+}
+
+func TestWalkerRecordsFileWithSeveralParentsOnce(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	shared := &drive.File{Id: "shared", Name: "report.pdf", MimeType: "application/pdf", Size: 5}
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "dir-a", Name: "a", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "dir-b", Name: "b", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"dir-a": {shared},
+		"dir-b": {shared},
+		"dir-c": {shared},
+	}
+	client := newFakeDriveClient(t, children, nil)
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	walk := func(start func(*FolderWalker) (<-chan *WalkResult, error)) int {
+		walker, err := NewFolderWalker(client, m, NewProgressTracker(session.ID), newTestLogger(), &WalkerConfig{
+			Strategy:          TraversalBFS,
+			Concurrency:       2,
+			ChannelBufferSize: 10,
+		})
+		require.NoError(t, err)
+
+		results, err := start(walker)
+		require.NoError(t, err)
+
+		files := 0
+		for result := range results {
+			require.NoError(t, result.Error)
+			files += len(result.Files)
+		}
+		return files
+	}
+
+	files := walk(func(w *FolderWalker) (<-chan *WalkResult, error) { return w.Walk(ctx, "root", session.ID) })
+	assert.Equal(t, 1, files)
+
+	recorded, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+
+	aliases, err := m.Files().GetAliases(ctx, session.ID, "shared")
+	require.NoError(t, err)
+	require.Len(t, aliases, 1)
+	assert.NotEqual(t, recorded[0].Path, aliases[0].Path)
+
+	// A resumed walk knows the file from the database
+	root, err := m.Folders().GetByDriveID(ctx, "root", session.ID)
+	require.NoError(t, err)
+	resumed := &state.Folder{
+		DriveID:   "dir-c",
+		ParentID:  state.NewNullString(root.ID),
+		SessionID: session.ID,
+		Name:      "c",
+		Path:      "root/c",
+		Status:    state.FolderStatusPending,
+	}
+	require.NoError(t, m.Folders().CreateBatch(ctx, []*state.Folder{resumed}))
+
+	files = walk(func(w *FolderWalker) (<-chan *WalkResult, error) {
+		return w.WalkFolders(ctx, session.ID, []*state.Folder{resumed})
+	})
+	assert.Equal(t, 0, files)
+
+	aliases, err = m.Files().GetAliases(ctx, session.ID, "shared")
+	require.NoError(t, err)
+	require.Len(t, aliases, 2)
+	assert.Equal(t, "root/c/report.pdf", aliases[1].Path)
+}
+
+func TestWalkerRecordsFileWithSeveralParentsAtIncludedPath(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	shared := &drive.File{Id: "shared", Name: "report.pdf", MimeType: "application/pdf", Size: 5,
+		Parents: []string{"dir-a", "dir-b"}}
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "dir-a", Name: "a", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "dir-b", Name: "b", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"dir-a": {shared},
+		"dir-b": {shared},
+	}
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	// One folder at a time lists the excluded path first
+	walker, err := NewFolderWalker(newFakeDriveClient(t, children, nil), m, NewProgressTracker(session.ID),
+		newTestLogger(), &WalkerConfig{
+			Strategy:          TraversalBFS,
+			Concurrency:       1,
+			ChannelBufferSize: 10,
+			ExcludePatterns:   []string{`^root/a/`},
+		})
+	require.NoError(t, err)
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(t, err)
+	for result := range results {
+		require.NoError(t, result.Error)
+	}
+
+	recorded, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	require.Len(t, recorded, 1)
+	assert.Equal(t, "root/b/report.pdf", recorded[0].Path)
+	assert.Equal(t, state.FileStatusPending, recorded[0].Status)
+
+	aliases, err := m.Files().GetAliases(ctx, session.ID, "shared")
+	require.NoError(t, err)
+	assert.Empty(t, aliases)
+}
+
+func TestSyncLooksUpOnlyTheRootFolderMetadata(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	folder := func(id, name string) *drive.File {
+		return &drive.File{Id: id, Name: name, MimeType: "application/vnd.google-apps.folder"}
+	}
+	file := func(id string) *drive.File {
+		return &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 10}
+	}
+	children := map[string][]*drive.File{
+		"parent": {folder("top", "Projects")},
+		"top":    {folder("dir-a", "a"), file("top-1")},
+		"dir-a":  {folder("dir-a1", "a1"), file("a-1")},
+		"dir-a1": {file("a1-1")},
+	}
+
+	// Metadata requests are GETs of a single file that are not downloads
+	var metadataRequests atomic.Int32
+	handler := fakeDriveHandler(children, nil, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/files/") && r.URL.Query().Get("alt") != "media" {
+			metadataRequests.Add(1)
+		}
+		handler(w, r)
+	}))
+
+	engine := newTestEngine(t, m, func(ctx context.Context, file *state.File) (int64, error) {
+		return file.Size, nil
+	})
+	engine.client = client
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "top", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync did not finish")
+	}
+
+	folders, err := m.Folders().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(folders))
+	for _, f := range folders {
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{"Projects", "Projects/a", "Projects/a/a1"}, paths)
+
+	// Only the session's root is looked up; the walk reuses its name and
+	// takes subfolder names from the parent listings
+	assert.Equal(t, int32(1), metadataRequests.Load())
+}
+
+func TestWalkerStarredOnlyFiltersListings(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {{Id: "dir-a", Name: "a", MimeType: "application/vnd.google-apps.folder"}},
+	}
+
+	var queries []string
+	var mu sync.Mutex
+	client := newFakeDriveClient(t, children, func(r *http.Request, _ string) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query().Get("q"))
+		mu.Unlock()
+	})
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	walker, err := NewFolderWalker(client, m, NewProgressTracker(session.ID), newTestLogger(), &WalkerConfig{
+		Strategy:          TraversalBFS,
+		Concurrency:       1,
+		ChannelBufferSize: 10,
+		StarredOnly:       true,
+	})
+	require.NoError(t, err)
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(t, err)
+	for result := range results {
+		require.NoError(t, result.Error)
+	}
+
+	// Both the root and the unstarred subfolder are listed with the filter
+	require.Len(t, queries, 2)
+	for _, query := range queries {
+		assert.Contains(t, query, "(starred = true or mimeType = 'application/vnd.google-apps.folder')")
+	}
+}
+
+func TestWalkerGoogleDocsFilters(t *testing.T) {
+	ctx := context.Background()
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "doc", Name: "notes", MimeType: "application/vnd.google-apps.document"},
+			{Id: "sheet", Name: "budget", MimeType: "application/vnd.google-apps.spreadsheet"},
+			{Id: "pdf", Name: "report.pdf", MimeType: "application/pdf", Size: 5},
+			{Id: "photo", Name: "photo.jpg", MimeType: "image/jpeg", Size: 7},
+		},
+	}
+
+	walk := func(config *WalkerConfig) map[string]*state.File {
+		m := newTestStateManager(t)
+		session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+		require.NoError(t, err)
+
+		config.Strategy = TraversalBFS
+		config.Concurrency = 1
+		config.ChannelBufferSize = 10
+		walker, err := NewFolderWalker(newFakeDriveClient(t, children, nil), m, NewProgressTracker(session.ID), newTestLogger(), config)
+		require.NoError(t, err)
+
+		results, err := walker.Walk(ctx, "root", session.ID)
+		require.NoError(t, err)
+		for result := range results {
+			require.NoError(t, result.Error)
+		}
+
+		files, err := m.Files().GetBySession(ctx, session.ID)
+		require.NoError(t, err)
+		byDriveID := make(map[string]*state.File, len(files))
+		for _, file := range files {
+			byDriveID[file.DriveID] = file
+		}
+		require.Len(t, byDriveID, 4)
+		return byDriveID
+	}
+
+	files := walk(&WalkerConfig{OnlyGoogleDocs: true})
+	for _, id := range []string{"doc", "sheet"} {
+		assert.Equal(t, state.FileStatusPending, files[id].Status, id)
+	}
+	for _, id := range []string{"pdf", "photo"} {
+		assert.Equal(t, state.FileStatusSkipped, files[id].Status, id)
+		assert.Equal(t, "not a Google Workspace file", files[id].ErrorMessage.String, id)
+	}
+
+	files = walk(&WalkerConfig{SkipGoogleDocs: true})
+	for _, id := range []string{"doc", "sheet"} {
+		assert.Equal(t, state.FileStatusSkipped, files[id].Status, id)
+		assert.Equal(t, "Google Workspace file", files[id].ErrorMessage.String, id)
+	}
+	for _, id := range []string{"pdf", "photo"} {
+		assert.Equal(t, state.FileStatusPending, files[id].Status, id)
+	}
+
+	_, err := NewFolderWalker(nil, nil, nil, newTestLogger(), &WalkerConfig{OnlyGoogleDocs: true, SkipGoogleDocs: true})
+	assert.Error(t, err)
+}
+
+// newPagedDriveClient returns a client listing pages pages of pageSize files
+// in the folder "root". Each list request takes latency and calls onPage,
+// if set, with the number of the requested page before it is answered.
+func newPagedDriveClient(tb testing.TB, pages, pageSize int, latency time.Duration, onPage func(page int)) *api.DriveClient {
+	tb.Helper()
+
+	return newDriveClientForHandler(tb, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := 0
+		if token := r.URL.Query().Get("pageToken"); token != "" {
+			page, _ = strconv.Atoi(token)
+		}
+		if onPage != nil {
+			onPage(page)
+		}
+		time.Sleep(latency)
+
+		list := &drive.FileList{}
+		for i := 0; i < pageSize; i++ {
+			id := fmt.Sprintf("file-%d-%d", page, i)
+			list.Files = append(list.Files, &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 1})
+		}
+		if page+1 < pages {
+			list.NextPageToken = strconv.Itoa(page + 1)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+}
+
+// walkRoot walks the folder "root" into a new session and returns the
+// number of files found.
+func walkRoot(tb testing.TB, m *state.Manager, client *api.DriveClient, config *WalkerConfig) int {
+	tb.Helper()
+
+	ctx := context.Background()
+	session, err := m.CreateSession(ctx, "root", "root", tb.TempDir())
+	require.NoError(tb, err)
+
+	config.Strategy = TraversalBFS
+	config.Concurrency = 1
+	config.ChannelBufferSize = 10
+	walker, err := NewFolderWalker(client, m, NewProgressTracker(session.ID), newTestLogger(), config)
+	require.NoError(tb, err)
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(tb, err)
+
+	files := 0
+	for result := range results {
+		require.NoError(tb, result.Error)
+		files += len(result.Files)
+	}
+	return files
+}
+
+func TestWalkerRecordsPagesWhileListingTheNext(t *testing.T) {
+	const pages, pageSize = 5, 50
+
+	m := newTestStateManager(t)
+
+	// Every page after the first is only answered once the files of the
+	// previous page are in the database, which a walker listing all pages
+	// before recording them never gets to
+	var waited []bool
+	var mu sync.Mutex
+	client := newPagedDriveClient(t, pages, pageSize, 0, func(page int) {
+		if page == 0 {
+			return
+		}
+		recorded := assert.Eventually(t, func() bool {
+			var count int
+			err := m.DB().Get(context.Background(), &count, `SELECT COUNT(*) FROM files`)
+			return err == nil && count >= page*pageSize
+		}, 5*time.Second, time.Millisecond)
+
+		mu.Lock()
+		waited = append(waited, recorded)
+		mu.Unlock()
+	})
+
+	assert.Equal(t, pages*pageSize, walkRoot(t, m, client, &WalkerConfig{}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []bool{true, true, true, true}, waited)
+}
+
+func BenchmarkWalkerPagedFolder(b *testing.B) {
+	const pages, pageSize = 10, 1000
+
+	for _, bench := range []struct {
+		name   string
+		config WalkerConfig
+	}{
+		{name: "pipelined", config: WalkerConfig{}},
+		// Ignore files need the whole listing before any entry is recorded
+		{name: "listed-first", config: WalkerConfig{RespectIgnoreFiles: true}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				m := newTestStateManager(b)
+				client := newPagedDriveClient(b, pages, pageSize, 20*time.Millisecond, nil)
+				config := bench.config
+				b.StartTimer()
+
+				walkRoot(b, m, client, &config)
+			}
+		})
+	}
+}
+
+func TestWalkerSkipsHiddenEntries(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "env", Name: ".env", MimeType: "text/plain", Size: 1},
+			{Id: "settings", Name: "settings.json", MimeType: "application/json", Size: 1, Spaces: []string{"appDataFolder"}},
+			{Id: "notes", Name: "notes.txt", MimeType: "text/plain", Size: 1},
+			{Id: "draft", Name: "draft.tmp", MimeType: "text/plain", Size: 1},
+			{Id: "git", Name: ".git", MimeType: "application/vnd.google-apps.folder"},
+			{Id: "docs", Name: "docs", MimeType: "application/vnd.google-apps.folder"},
+		},
+		"git":  {{Id: "head", Name: "HEAD", MimeType: "text/plain", Size: 1}},
+		"docs": {{Id: "guide", Name: "guide.txt", MimeType: "text/plain", Size: 1}},
+	}
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	walker, err := NewFolderWalker(newFakeDriveClient(t, children, nil), m, NewProgressTracker(session.ID), newTestLogger(), &WalkerConfig{
+		Strategy:          TraversalBFS,
+		Concurrency:       1,
+		ChannelBufferSize: 10,
+		SkipHidden:        true,
+		ExcludePatterns:   []string{`\.tmp$`},
+	})
+	require.NoError(t, err)
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(t, err)
+	for result := range results {
+		require.NoError(t, result.Error)
+	}
+
+	files, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	byDriveID := make(map[string]*state.File, len(files))
+	for _, file := range files {
+		byDriveID[file.DriveID] = file
+	}
+
+	// The hidden folder is never listed, so its contents are not recorded
+	require.Len(t, byDriveID, 5)
+	assert.NotContains(t, byDriveID, "head")
+	for _, id := range []string{"env", "settings"} {
+		assert.Equal(t, state.FileStatusSkipped, byDriveID[id].Status, id)
+		assert.Equal(t, "hidden", byDriveID[id].ErrorMessage.String, id)
+	}
+	assert.Equal(t, state.FileStatusSkipped, byDriveID["draft"].Status)
+	assert.Contains(t, byDriveID["draft"].ErrorMessage.String, "excluded by pattern")
+	assert.Equal(t, state.FileStatusPending, byDriveID["notes"].Status)
+	assert.Equal(t, state.FileStatusPending, byDriveID["guide"].Status)
+
+	folder, err := m.Folders().GetByDriveID(ctx, "git", session.ID)
+	require.NoError(t, err)
+	require.NotNil(t, folder)
+	assert.Equal(t, state.FolderStatusSkipped, folder.Status)
+	assert.Equal(t, "root/.git", folder.Path)
+
+	unscanned, err := m.GetUnscannedFolders(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Empty(t, unscanned)
+}
+
+func TestWalkerSavesLargeFoldersInBatches(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	const fileCount = 250
+	children := map[string][]*drive.File{}
+	for i := 0; i < fileCount; i++ {
+		id := fmt.Sprintf("file-%03d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 1})
+	}
+	client := newFakeDriveClient(t, children, nil)
+
+	session, err := m.CreateSession(ctx, "root", "root", t.TempDir())
+	require.NoError(t, err)
+
+	walker, err := NewFolderWalker(client, m, NewProgressTracker(session.ID), newTestLogger(), &WalkerConfig{
+		Strategy:          TraversalBFS,
+		Concurrency:       1,
+		ChannelBufferSize: 10,
+		InsertBatchSize:   100,
+	})
+	require.NoError(t, err)
+
+	var batches []int
+	walker.createFiles = func(ctx context.Context, files []*state.File) error {
+		batches = append(batches, len(files))
+		return m.CreateFiles(ctx, files)
+	}
+
+	results, err := walker.Walk(ctx, "root", session.ID)
+	require.NoError(t, err)
+	for result := range results {
+		require.NoError(t, result.Error)
+	}
+
+	assert.Equal(t, []int{100, 100, 50}, batches)
+	recorded, err := m.Files().GetBySession(ctx, session.ID)
+	require.NoError(t, err)
+	assert.Len(t, recorded, fileCount)
+}
@@ -0,0 +1,88 @@
+/**
+ * Stall Watchdog for CloudPull Sync Engine
+ *
+ * Features:
+ * - Stops a sync whose queued downloads make no progress
+ * - Counts both downloaded bytes and finished files as progress
+ * - Ignores paused engines and idle periods without pending work
+ * - Logs queue depth, active workers and the last error before stopping
+ *
+ * Author: CloudPull Team
+ * Updated: 2025-01-30
+ */
+
+package sync
+
+import (
+	"time"
+)
+
+// minWatchdogInterval bounds how often the watchdog samples progress.
+const minWatchdogInterval = 10 * time.Millisecond
+
+// progressMark identifies how far a sync got; any change is progress.
+type progressMark struct {
+	files int64
+	bytes int64
+}
+
+// runWatchdog stops the sync as stalled once work is pending but no
+// progress was made for NoProgressTimeout.
+func (e *Engine) runWatchdog() {
+	defer e.wg.Done()
+
+	timeout := e.config.NoProgressTimeout
+	interval := timeout / 4
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last progressMark
+	lastProgress := time.Now()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		progress, workers := e.GetStats()
+		if progress == nil || workers == nil {
+			continue
+		}
+
+		mark := progressMark{
+			files: progress.CompletedFiles + progress.FailedFiles + progress.SkippedFiles,
+			bytes: progress.CompletedBytes,
+		}
+		pending := workers.QueuedTasks > 0 || workers.ActiveWorkers > 0 || workers.ScheduleBacklog > 0
+		if mark != last || !pending || e.paused() {
+			last = mark
+			lastProgress = time.Now()
+			continue
+		}
+
+		if time.Since(lastProgress) < timeout {
+			continue
+		}
+
+		e.mu.Lock()
+		e.stalled = true
+		lastErr := e.lastErr
+		e.mu.Unlock()
+
+		e.logger.Error(lastErr, "Sync made no progress, stopping as stalled",
+			"timeout", timeout,
+			"queued_tasks", workers.QueuedTasks,
+			"active_workers", workers.ActiveWorkers,
+			"schedule_backlog", workers.ScheduleBacklog,
+			"completed_files", progress.CompletedFiles,
+			"completed_bytes", progress.CompletedBytes,
+		)
+		e.cancel()
+		return
+	}
+}
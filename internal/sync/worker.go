@@ -5,8 +5,10 @@
  * - Configurable worker pool size
  * - Priority queue support
  * - Graceful shutdown and restart
+ * - Drain mode that finishes in-flight downloads without starting new ones
  * - Worker health monitoring
  * - Task distribution and load balancing
+ * - File statuses written in batches instead of one write per result
  *
  * Author: CloudPull Team
  * Updated: 2025-01-29
@@ -28,12 +30,28 @@ import (
 	"github.com/VatsalSy/CloudPull/internal/state"
 )
 
+// ErrorTypePermissionDenied is the error log type of files Drive refused
+// to serve; they are failed without retries.
+const ErrorTypePermissionDenied = "permission_denied"
+
+// ErrorTypeDownloadFailed is the error log type of files that failed for
+// good after their retries.
+const ErrorTypeDownloadFailed = "download_failed"
+
+// ErrorTypeFileGone is the error log type and skip reason of files that
+// were trashed or deleted between listing and download.
+const ErrorTypeFileGone = "file_gone"
+
+// ErrorTypeCorruption is the error log type of downloads whose checksum did
+// not match; they restart from the beginning.
+const ErrorTypeCorruption = "corruption"
+
 // WorkerPool manages concurrent download workers.
 type WorkerPool struct {
 	ctx             context.Context
 	cancel          context.CancelFunc
 	taskQueue       *PriorityQueue
-	client          *api.DriveClient
+	client          api.DriveAPI
 	stateManager    *state.Manager
 	progressTracker *ProgressTracker
 	errorHandler    *errors.Handler
@@ -50,7 +68,13 @@ type WorkerPool struct {
 	tasksSucceeded  int64
 	tasksFailed     int64
 	bytesDownloaded int64
+	inFlight        int64
+	outstanding     int64
+	download        func(ctx context.Context, file *state.File) (int64, error)
+	statuses        *statusBatch
 	mu              sync.RWMutex
+	draining        atomic.Bool
+	paused          atomic.Bool
 }
 
 // Worker represents a download worker.
@@ -70,8 +94,12 @@ type DownloadTask struct {
 	File        *state.File
 	StartedAt   *time.Time
 	CompletedAt *time.Time
-	Priority    int
-	Retries     int
+
+	// TraceID correlates the log lines and error log entries of every
+	// attempt to download the file
+	TraceID  string
+	Priority int
+	Retries  int
 }
 
 // TaskResult represents the result of a download task.
@@ -95,20 +123,27 @@ type WorkerPoolConfig struct {
 	WorkerCount     int
 	MaxRetries      int
 	ShutdownTimeout time.Duration
+
+	// File statuses are written in batches of StatusBatchSize, or once
+	// the oldest buffered status is StatusFlushInterval old
+	StatusBatchSize     int
+	StatusFlushInterval time.Duration
 }
 
 // DefaultWorkerPoolConfig returns default configuration.
 func DefaultWorkerPoolConfig() *WorkerPoolConfig {
 	return &WorkerPoolConfig{
-		WorkerCount:     3,
-		MaxRetries:      3,
-		ShutdownTimeout: 30 * time.Second,
+		WorkerCount:         3,
+		MaxRetries:          3,
+		ShutdownTimeout:     30 * time.Second,
+		StatusBatchSize:     100,
+		StatusFlushInterval: 500 * time.Millisecond,
 	}
 }
 
 // NewWorkerPool creates a new worker pool.
 func NewWorkerPool(
-	client *api.DriveClient,
+	client api.DriveAPI,
 	stateManager *state.Manager,
 	progressTracker *ProgressTracker,
 	errorHandler *errors.Handler,
@@ -122,6 +157,16 @@ func NewWorkerPool(
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	defaults := DefaultWorkerPoolConfig()
+	batchSize := config.StatusBatchSize
+	if batchSize <= 0 {
+		batchSize = defaults.StatusBatchSize
+	}
+	flushInterval := config.StatusFlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaults.StatusFlushInterval
+	}
+
 	return &WorkerPool{
 		workerCount:     config.WorkerCount,
 		maxRetries:      config.MaxRetries,
@@ -132,6 +177,7 @@ func NewWorkerPool(
 		errorHandler:    errorHandler,
 		logger:          logger,
 		taskQueue:       NewPriorityQueue(),
+		statuses:        newStatusBatch(stateManager, logger, batchSize, flushInterval),
 		taskChan:        make(chan *DownloadTask, config.WorkerCount*2),
 		resultChan:      make(chan *TaskResult, config.WorkerCount*2),
 		ctx:             ctx,
@@ -207,6 +253,42 @@ func (wp *WorkerPool) Stop() error {
 	}
 }
 
+// Drain stops dispatching queued tasks and waits until every task already
+// handed to a worker has finished and had its result recorded. Queued tasks
+// stay pending so a resumed session picks them up. It returns an error if
+// ctx expires first.
+func (wp *WorkerPool) Drain(ctx context.Context) error {
+	wp.draining.Store(true)
+
+	wp.logger.Info("Draining worker pool",
+		"in_flight", atomic.LoadInt64(&wp.inFlight),
+		"queued", wp.taskQueue.Len(),
+	)
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		if atomic.LoadInt64(&wp.inFlight) == 0 {
+			wp.statuses.flush()
+			wp.logger.Info("Worker pool drained")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "worker pool drain interrupted")
+		case <-ticker.C:
+		}
+	}
+}
+
+// SetPaused stops or restarts dispatching queued tasks. Tasks already
+// handed to a worker finish; the rest stay queued until the pool resumes.
+func (wp *WorkerPool) SetPaused(paused bool) {
+	wp.paused.Store(paused)
+}
+
 // SubmitTask submits a download task to the pool.
 func (wp *WorkerPool) SubmitTask(file *state.File, priority int) error {
 	select {
@@ -219,12 +301,14 @@ func (wp *WorkerPool) SubmitTask(file *state.File, priority int) error {
 		File:      file,
 		Priority:  priority,
 		CreatedAt: time.Now(),
+		TraceID:   generateID(),
 	}
 
 	// Add to priority queue
+	atomic.AddInt64(&wp.outstanding, 1)
 	wp.taskQueue.Push(task)
 
-	wp.logger.Info("Task submitted to queue",
+	task.logger(wp.logger).Info("Task submitted to queue",
 		"file_id", file.ID,
 		"file_name", file.Name,
 		"priority", priority,
@@ -234,6 +318,17 @@ func (wp *WorkerPool) SubmitTask(file *state.File, priority int) error {
 	return nil
 }
 
+// IsIdle reports whether every submitted task has either completed or
+// permanently failed. Tasks waiting for a retry are still outstanding.
+func (wp *WorkerPool) IsIdle() bool {
+	return atomic.LoadInt64(&wp.outstanding) == 0
+}
+
+// QueuedTasks returns the number of tasks waiting for a worker.
+func (wp *WorkerPool) QueuedTasks() int {
+	return wp.taskQueue.Len()
+}
+
 // GetStats returns worker pool statistics.
 func (wp *WorkerPool) GetStats() *WorkerPoolStats {
 	wp.mu.RLock()
@@ -273,6 +368,11 @@ func (wp *WorkerPool) dispatchTasks() {
 			return
 
 		case <-ticker.C:
+			// Leave everything queued while draining or paused
+			if wp.draining.Load() || wp.paused.Load() {
+				continue
+			}
+
 			queueSize := wp.taskQueue.Len()
 			if queueSize > 0 {
 				wp.logger.Debug("Checking task queue", "queue_size", queueSize)
@@ -286,14 +386,18 @@ func (wp *WorkerPool) dispatchTasks() {
 					break
 				}
 
+				// A worker owns the task once sent, so capture log fields first
+				fileID, fileName, priority := task.File.ID, task.File.Name, task.Priority
+				log := task.logger(wp.logger)
+
 				// Send task to workers
 				select {
 				case wp.taskChan <- task:
 					// Task dispatched
-					wp.logger.Info("Task dispatched to worker",
-						"file_id", task.File.ID,
-						"file_name", task.File.Name,
-						"priority", task.Priority,
+					log.Info("Task dispatched to worker",
+						"file_id", fileID,
+						"file_name", fileName,
+						"priority", priority,
 						"queue_size_after", wp.taskQueue.Len(),
 					)
 				case <-wp.ctx.Done():
@@ -302,7 +406,7 @@ func (wp *WorkerPool) dispatchTasks() {
 					return
 				default:
 					// Channel is full, put task back and wait
-					wp.logger.Debug("Task channel full, requeueing task",
+					log.Debug("Task channel full, requeueing task",
 						"file_id", task.File.ID,
 						"file_name", task.File.Name,
 					)
@@ -319,73 +423,172 @@ func (wp *WorkerPool) dispatchTasks() {
 func (wp *WorkerPool) processResults() {
 	defer wp.wg.Done()
 
+	ticker := time.NewTicker(wp.statuses.maxAge)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-wp.ctx.Done():
+			wp.statuses.flush()
 			return
 
+		case <-ticker.C:
+			wp.statuses.flushIfDue()
+
 		case result := <-wp.resultChan:
-			atomic.AddInt64(&wp.tasksProcessed, 1)
-
-			if result.Success {
-				atomic.AddInt64(&wp.tasksSucceeded, 1)
-				atomic.AddInt64(&wp.bytesDownloaded, result.BytesWritten)
-
-				// Update file status in database
-				result.Task.File.Status = state.FileStatusCompleted
-				result.Task.File.BytesDownloaded = result.Task.File.Size
-				if err := wp.stateManager.UpdateFileStatus(wp.ctx, result.Task.File); err != nil {
-					wp.logger.Error(err, "Failed to update file status",
-						"file_id", result.Task.File.ID,
-						"status", result.Task.File.Status,
-					)
+			wp.handleResult(result)
+			atomic.AddInt64(&wp.inFlight, -1)
+		}
+	}
+}
+
+// resolveTask marks a task as finished. The last outstanding task flushes
+// the buffered statuses first, so they are stored before the pool looks idle.
+func (wp *WorkerPool) resolveTask() {
+	if atomic.LoadInt64(&wp.outstanding) <= 1 {
+		wp.statuses.flush()
+	}
+	atomic.AddInt64(&wp.outstanding, -1)
+}
+
+// handleResult records the outcome of a finished task.
+func (wp *WorkerPool) handleResult(result *TaskResult) {
+	atomic.AddInt64(&wp.tasksProcessed, 1)
+
+	log := result.Task.logger(wp.logger)
+	ctx := state.WithTraceID(wp.ctx, result.Task.TraceID)
+
+	if result.Success {
+		atomic.AddInt64(&wp.tasksSucceeded, 1)
+		atomic.AddInt64(&wp.bytesDownloaded, result.BytesWritten)
+
+		// Update file status in database
+		result.Task.File.Status = state.FileStatusCompleted
+		result.Task.File.BytesDownloaded = result.Task.File.Size
+		wp.statuses.add(result.Task.File.ID, result.Task.File.Status)
+
+		// Resolve the task before notifying, whose event triggers completion checks
+		wp.resolveTask()
+		wp.progressTracker.FileCompleted(result.Task.File.ID)
+	} else if api.IsNotFound(result.Error) {
+		wp.skipGoneFile(ctx, log, result)
+	} else if isLocalCopyKept(result.Error) {
+		wp.skipFile(ctx, log, result.Task.File, ConflictDecisionKeptLocal)
+	} else {
+		atomic.AddInt64(&wp.tasksFailed, 1)
+
+		// Retrying cannot fix missing access to a file, and downloading a
+		// file again does not change the post-download command's verdict
+		permissionDenied := api.IsPermissionDenied(result.Error)
+		hookFailed := isPostDownloadFailure(result.Error)
+
+		// Handle retry logic; a lost authorization stops the whole sync
+		if result.Task.Retries < wp.maxRetries && !permissionDenied && !hookFailed &&
+			!api.IsReauthRequired(result.Error) {
+			result.Task.Retries++
+			result.Task.LastError = result.Error
+
+			// Calculate retry priority (lower priority for retries)
+			result.Task.Priority += 1000 * result.Task.Retries
+
+			// The file waits in the queue again
+			result.Task.File.Status = state.FileStatusQueued
+			wp.statuses.add(result.Task.File.ID, result.Task.File.Status)
+
+			// Re-queue the task
+			wp.taskQueue.Push(result.Task)
+
+			log.Warn("Retrying download task",
+				"file_id", result.Task.File.ID,
+				"attempt", result.Task.Retries,
+				"error", result.Error,
+			)
+		} else {
+			// Max retries exceeded
+			result.Task.File.Status = state.FileStatusFailed
+			result.Task.File.ErrorMessage.Valid = true
+			result.Task.File.ErrorMessage.String = result.Error.Error()
+
+			// Persist attempts and the error too so retries can honor max attempts
+			wp.statuses.forget(result.Task.File.ID)
+			if err := wp.stateManager.Files().Update(ctx, result.Task.File); err != nil {
+				log.Error(err, "Failed to update file status",
+					"file_id", result.Task.File.ID,
+					"status", result.Task.File.Status,
+				)
+			}
+
+			if permissionDenied {
+				// The error type marks the entry as not retryable
+				if err := wp.stateManager.LogError(ctx, result.Task.File.SessionID, result.Task.File.ID,
+					"file", ErrorTypePermissionDenied,
+					errors.New(errors.ErrorTypePermission, "download", result.Task.File.Path, result.Error)); err != nil {
+					log.Error(err, "Failed to log permission error", "file_id", result.Task.File.ID)
+				}
+			} else if !hookFailed {
+				// Hook failures are in the error log already
+				if err := wp.stateManager.LogError(ctx, result.Task.File.SessionID, result.Task.File.ID,
+					"file", ErrorTypeDownloadFailed, result.Error); err != nil {
+					log.Error(err, "Failed to log download error", "file_id", result.Task.File.ID)
 				}
+			}
 
-				// Notify progress tracker
-				wp.progressTracker.FileCompleted(result.Task.File.ID)
+			// Notify progress tracker
+			wp.resolveTask()
+			wp.progressTracker.FileFailed(result.Task.File.ID, result.Task.TraceID, result.Error)
+
+			if permissionDenied {
+				log.Warn("Permission denied, not retrying download",
+					"file_id", result.Task.File.ID,
+					"path", result.Task.File.Path,
+				)
+			} else if hookFailed {
+				log.Warn("Post-download command failed, not retrying download",
+					"file_id", result.Task.File.ID,
+					"path", result.Task.File.Path,
+				)
 			} else {
-				atomic.AddInt64(&wp.tasksFailed, 1)
+				log.Error(result.Error, "Download task failed after max retries",
+					"file_id", result.Task.File.ID,
+					"attempts", result.Task.Retries,
+				)
+			}
+		}
+	}
+}
 
-				// Handle retry logic
-				if result.Task.Retries < wp.maxRetries {
-					result.Task.Retries++
-					result.Task.LastError = result.Error
+// skipGoneFile records a file that no longer exists in Drive as skipped,
+// without retrying it or counting it as an error.
+func (wp *WorkerPool) skipGoneFile(ctx context.Context, log *logger.Logger, result *TaskResult) {
+	file := result.Task.File
+	if err := wp.stateManager.LogError(ctx, file.SessionID, file.ID,
+		"file", ErrorTypeFileGone, errors.New(errors.ErrorTypeNotFound, "download", file.Path, result.Error)); err != nil {
+		log.Error(err, "Failed to log missing file", "file_id", file.ID)
+	}
 
-					// Calculate retry priority (lower priority for retries)
-					result.Task.Priority += 1000 * result.Task.Retries
+	wp.skipFile(ctx, log, file, ErrorTypeFileGone)
 
-					// Re-queue the task
-					wp.taskQueue.Push(result.Task)
+	log.Warn("File no longer exists in Drive, skipping",
+		"file_id", file.ID,
+		"path", file.Path,
+	)
+}
 
-					wp.logger.Warn("Retrying download task",
-						"file_id", result.Task.File.ID,
-						"attempt", result.Task.Retries,
-						"error", result.Error,
-					)
-				} else {
-					// Max retries exceeded
-					result.Task.File.Status = state.FileStatusFailed
-					result.Task.File.ErrorMessage.Valid = true
-					result.Task.File.ErrorMessage.String = result.Error.Error()
-
-					if err := wp.stateManager.UpdateFileStatus(wp.ctx, result.Task.File); err != nil {
-						wp.logger.Error(err, "Failed to update file status",
-							"file_id", result.Task.File.ID,
-							"status", result.Task.File.Status,
-						)
-					}
-
-					// Notify progress tracker
-					wp.progressTracker.FileFailed(result.Task.File.ID, result.Error)
-
-					wp.logger.Error(result.Error, "Download task failed after max retries",
-						"file_id", result.Task.File.ID,
-						"attempts", result.Task.Retries,
-					)
-				}
-			}
-		}
+// skipFile records file as skipped for reason, which is stored as its
+// error message.
+func (wp *WorkerPool) skipFile(ctx context.Context, log *logger.Logger, file *state.File, reason string) {
+	file.Status = state.FileStatusSkipped
+	file.ErrorMessage = state.NewNullString(reason)
+	wp.statuses.forget(file.ID)
+	if err := wp.stateManager.Files().Update(ctx, file); err != nil {
+		log.Error(err, "Failed to update file status",
+			"file_id", file.ID,
+			"status", file.Status,
+		)
 	}
+
+	wp.resolveTask()
+	wp.progressTracker.FileSkipped(file.ID, file.Name, file.Path, reason)
 }
 
 // Worker methods
@@ -403,6 +606,15 @@ func (w *Worker) run() {
 			return
 
 		case task := <-w.pool.taskChan:
+			atomic.AddInt64(&w.pool.inFlight, 1)
+
+			// A task dispatched just before draining or pausing goes back to the queue
+			if w.pool.draining.Load() || w.pool.paused.Load() {
+				w.pool.taskQueue.Push(task)
+				atomic.AddInt64(&w.pool.inFlight, -1)
+				continue
+			}
+
 			w.processTask(task)
 		}
 	}
@@ -417,7 +629,10 @@ func (w *Worker) processTask(task *DownloadTask) {
 	startTime := time.Now()
 	task.StartedAt = &startTime
 
-	w.pool.logger.Info("Worker processing task",
+	log := task.logger(w.pool.logger)
+	ctx := state.WithTraceID(w.pool.ctx, task.TraceID)
+
+	log.Info("Worker processing task",
 		"worker_id", w.id,
 		"file_id", task.File.ID,
 		"file_name", task.File.Name,
@@ -436,8 +651,8 @@ func (w *Worker) processTask(task *DownloadTask) {
 	// Update file status
 	task.File.Status = state.FileStatusDownloading
 	task.File.DownloadAttempts++
-	if err := w.pool.stateManager.UpdateFileStatus(w.pool.ctx, task.File); err != nil {
-		w.pool.logger.Error(err, "Failed to update file status",
+	if err := w.pool.stateManager.UpdateFileStatus(ctx, task.File); err != nil {
+		log.Error(err, "Failed to update file status",
 			"file_id", task.File.ID,
 			"status", task.File.Status,
 		)
@@ -445,21 +660,27 @@ func (w *Worker) processTask(task *DownloadTask) {
 
 	// Download the file
 	var bytesWritten int64
-	err := w.downloadFile(task, &bytesWritten)
+	err := w.downloadFile(ctx, log, task, &bytesWritten)
 
 	completedTime := time.Now()
 	task.CompletedAt = &completedTime
 	duration := completedTime.Sub(startTime)
 
-	if err != nil {
-		w.pool.logger.Error(err, "Download failed",
+	if isLocalCopyKept(err) {
+		log.Info("Download discarded, local copy kept",
+			"worker_id", w.id,
+			"file_id", task.File.ID,
+			"file_name", task.File.Name,
+		)
+	} else if err != nil {
+		log.Error(err, "Download failed",
 			"worker_id", w.id,
 			"file_id", task.File.ID,
 			"file_name", task.File.Name,
 			"duration", duration,
 		)
 	} else {
-		w.pool.logger.Info("Download completed",
+		log.Info("Download completed",
 			"worker_id", w.id,
 			"file_id", task.File.ID,
 			"file_name", task.File.Name,
@@ -491,11 +712,19 @@ func (w *Worker) processTask(task *DownloadTask) {
 	}
 }
 
-// downloadFile performs the actual file download.
-func (w *Worker) downloadFile(task *DownloadTask, bytesWritten *int64) error {
+// downloadFile performs the actual file download, logging to the task's
+// child logger.
+func (w *Worker) downloadFile(ctx context.Context, log *logger.Logger, task *DownloadTask, bytesWritten *int64) error {
+	// Custom download function (used by tests)
+	if w.pool.download != nil {
+		n, err := w.pool.download(ctx, task.File)
+		*bytesWritten = n
+		return err
+	}
+
 	// Use download manager if available (for advanced features like resume, checksum, etc)
 	if w.pool.downloadManager != nil {
-		err := w.pool.downloadManager.DownloadFile(w.pool.ctx, task.File)
+		err := w.pool.downloadManager.DownloadFile(ctx, task.File, log)
 		if err != nil {
 			return errors.Wrap(err, "download failed")
 		}
@@ -512,7 +741,7 @@ func (w *Worker) downloadFile(task *DownloadTask, bytesWritten *int64) error {
 
 	// Download the file
 	err := w.pool.client.DownloadFile(
-		w.pool.ctx,
+		ctx,
 		task.File.DriveID,
 		task.File.Path,
 		progressFn,
@@ -525,6 +754,11 @@ func (w *Worker) downloadFile(task *DownloadTask, bytesWritten *int64) error {
 	return nil
 }
 
+// logger returns a child of log carrying the task's trace ID.
+func (t *DownloadTask) logger(log *logger.Logger) *logger.Logger {
+	return log.WithField("trace_id", t.TraceID)
+}
+
 // Priority queue implementation
 
 // NewPriorityQueue creates a new priority queue.
@@ -592,4 +826,8 @@ type WorkerPoolStats struct {
 	TasksSucceeded  int64
 	TasksFailed     int64
 	BytesDownloaded int64
+
+	// ScheduleBacklog is the number of walked files not yet submitted; it
+	// is filled in by the engine
+	ScheduleBacklog int64
 }
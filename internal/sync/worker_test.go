@@ -0,0 +1,497 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/googleapi"
+
+	"github.com/VatsalSy/CloudPull/internal/errors"
+	"github.com/VatsalSy/CloudPull/internal/logger"
+	"github.com/VatsalSy/CloudPull/internal/state"
+)
+
+func newTestWorkerPool(t *testing.T) *WorkerPool {
+	t.Helper()
+
+	pool := NewWorkerPool(nil, nil, NewProgressTracker("session-1"), nil, newTestLogger(), nil)
+	require.NoError(t, pool.Start(context.Background()))
+	t.Cleanup(func() { pool.Stop() })
+
+	return pool
+}
+
+func TestWorkerPoolDrainLeavesQueuedTasks(t *testing.T) {
+	pool := newTestWorkerPool(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, pool.Drain(ctx))
+
+	// Nothing is dispatched once draining has started
+	require.NoError(t, pool.SubmitTask(&state.File{ID: "file-1", Name: "a.txt"}, 1))
+	time.Sleep(300 * time.Millisecond)
+
+	stats := pool.GetStats()
+	assert.Equal(t, 1, stats.QueuedTasks)
+	assert.Equal(t, int64(0), stats.TasksProcessed)
+}
+
+func TestWorkerPoolDrainWaitsForInFlight(t *testing.T) {
+	pool := newTestWorkerPool(t)
+
+	// Simulate a download that is still running
+	atomic.AddInt64(&pool.inFlight, 1)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- pool.Drain(context.Background())
+	}()
+
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a download was in flight")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	atomic.AddInt64(&pool.inFlight, -1)
+
+	select {
+	case err := <-drained:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return after the download finished")
+	}
+}
+
+func TestWorkerPoolDrainTimeout(t *testing.T) {
+	pool := newTestWorkerPool(t)
+	atomic.AddInt64(&pool.inFlight, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	assert.Error(t, pool.Drain(ctx))
+}
+
+// runPermissionDeniedSync syncs a file Drive refuses to serve, which is
+// downloaded first, and ten others with a limit of one error. It returns the
+// session and the download attempts of the refused file.
+func runPermissionDeniedSync(t *testing.T, m *state.Manager, skipPermissionErrors bool) (*state.Session, int32) {
+	t.Helper()
+
+	children := map[string][]*drive.File{
+		"root": {{Id: "denied", Name: "denied.txt", MimeType: "text/plain", Size: 1}},
+	}
+	for i := 0; i < 10; i++ {
+		id := fmt.Sprintf("open-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 4})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.MaxErrors = 1
+	cfg.SkipPermissionErrors = skipPermissionErrors
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	var attempts atomic.Int32
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		if file.DriveID == "denied" {
+			attempts.Add(1)
+			return 0, errors.Wrap(&googleapi.Error{
+				Code:   403,
+				Errors: []googleapi.ErrorItem{{Reason: "insufficientFilePermissions"}},
+			}, "download failed")
+		}
+		time.Sleep(20 * time.Millisecond)
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	return session, attempts.Load()
+}
+
+func TestWorkerRecordsPermissionDeniedWithoutRetrying(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+	session, attempts := runPermissionDeniedSync(t, m, true)
+
+	// The sync ran to the end; only the refused file failed
+	assert.Equal(t, int32(1), attempts)
+	assert.Equal(t, state.SessionStatusFailed, session.Status)
+	assert.Equal(t, int64(10), session.CompletedFiles)
+
+	entries, err := m.GetErrors(ctx, session.ID, &state.ErrorLogFilter{ErrorType: ErrorTypePermissionDenied})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.False(t, entries[0].IsRetryable)
+	require.True(t, entries[0].TraceID.Valid)
+
+	traced, err := m.GetErrors(ctx, session.ID, &state.ErrorLogFilter{TraceID: entries[0].TraceID.String})
+	require.NoError(t, err)
+	assert.Len(t, traced, 1)
+
+	file, err := m.Files().Get(ctx, entries[0].ItemID)
+	require.NoError(t, err)
+	assert.Equal(t, "denied", file.DriveID)
+	assert.Equal(t, state.FileStatusFailed, file.Status)
+}
+
+func TestPermissionDeniedCountsTowardMaxErrors(t *testing.T) {
+	m := newTestStateManager(t)
+	session, _ := runPermissionDeniedSync(t, m, false)
+
+	// Reaching the error limit stops the sync
+	assert.Equal(t, state.SessionStatusCancelled, session.Status)
+}
+
+func TestWorkerSkipsFileGoneFromDrive(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "gone", Name: "gone.txt", MimeType: "text/plain", Size: 1},
+			{Id: "kept", Name: "kept.txt", MimeType: "text/plain", Size: 4},
+		},
+	}
+
+	// The file is trashed after the listing, so Drive no longer serves it
+	var attempts atomic.Int32
+	serve := fakeDriveHandler(children, nil, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files/gone" && r.URL.Query().Get("alt") == "media" {
+			attempts.Add(1)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": {"code": 404, "message": "File not found: gone.", "errors": [{"reason": "notFound"}]}}`)
+			return
+		}
+		serve(w, r)
+	}))
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.MaxErrors = 1
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// Not retried and not counted toward the error limit
+	session, err := m.GetSession(ctx, sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), attempts.Load())
+	assert.Equal(t, state.SessionStatusCompleted, session.Status)
+	assert.Equal(t, int64(1), session.CompletedFiles)
+	assert.Equal(t, int64(0), session.FailedFiles)
+	assert.Equal(t, int64(1), session.SkippedFiles)
+
+	file, err := m.Files().GetByDriveID(ctx, "gone", sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.FileStatusSkipped, file.Status)
+	assert.Equal(t, ErrorTypeFileGone, file.ErrorMessage.String)
+
+	entries, err := m.GetErrors(ctx, sessionID, &state.ErrorLogFilter{ErrorType: ErrorTypeFileGone})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.False(t, entries[0].IsRetryable)
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent log writers.
+type lockedBuffer struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestDownloadLogLinesShareTraceID(t *testing.T) {
+	ctx := context.Background()
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {
+			{Id: "drive-a", Name: "a.txt", MimeType: "text/plain", Size: 8},
+			{Id: "drive-b", Name: "b.txt", MimeType: "text/plain", Size: 8},
+			{Id: "drive-doc", Name: "notes", MimeType: "application/vnd.google-apps.document"},
+			{Id: "drive-denied", Name: "denied.txt", MimeType: "text/plain", Size: 8},
+		},
+	}
+	handler := fakeDriveHandler(children, nil, nil)
+	client := newDriveClientForHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/files/drive-denied" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": {"code": 403, "message": "forbidden", "errors": [{"reason": "insufficientFilePermissions"}]}}`)
+			return
+		}
+		handler(w, r)
+	}))
+
+	output := &lockedBuffer{}
+	log := logger.New(&logger.Config{Level: "debug", Output: output, Format: logger.FormatJSON})
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	engine, err := NewEngine(client, m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	sessionID, err := engine.StartNewSessionWithID(ctx, "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	files, err := m.Files().GetBySession(ctx, sessionID)
+	require.NoError(t, err)
+	require.Len(t, files, 4)
+	fileIDs := make(map[string]string)
+	for _, file := range files {
+		fileIDs[file.Name] = file.ID
+	}
+
+	// Collect the messages and trace IDs logged for each file; the failure
+	// reported by the engine names the file instead of its ID
+	messages := make(map[string][]string)
+	traceIDs := make(map[string]map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(output.Bytes()))
+	for scanner.Scan() {
+		var line struct {
+			Message string `json:"message"`
+			FileID  string `json:"file_id"`
+			File    string `json:"file"`
+			TraceID string `json:"trace_id"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		if line.Message == "File download failed" {
+			line.FileID = fileIDs[line.File]
+		}
+		if line.FileID == "" {
+			continue
+		}
+		messages[line.FileID] = append(messages[line.FileID], line.Message)
+		if traceIDs[line.FileID] == nil {
+			traceIDs[line.FileID] = make(map[string]bool)
+		}
+		traceIDs[line.FileID][line.TraceID] = true
+	}
+
+	assert.Contains(t, messages[fileIDs["notes"]], "Exporting Google Workspace file")
+	assert.Contains(t, messages[fileIDs["denied.txt"]], "File download failed")
+
+	seen := make(map[string]bool)
+	for _, file := range files {
+		assert.Subset(t, messages[file.ID],
+			[]string{"Task submitted to queue", "Worker processing task", "Starting file download"})
+
+		require.Len(t, traceIDs[file.ID], 1, "log lines of %s carry different trace IDs", file.Name)
+		for traceID := range traceIDs[file.ID] {
+			assert.NotEmpty(t, traceID)
+			assert.False(t, seen[traceID], "trace ID shared between files")
+			seen[traceID] = true
+		}
+	}
+}
+
+func TestErrorMonitorCountsEachFailedFileOnce(t *testing.T) {
+	m := newTestStateManager(t)
+	engine := newTestEngine(t, m, nil)
+	engine.config.MaxErrors = 2
+	engine.errorChan = make(chan *syncError)
+	engine.ctx, engine.cancel = context.WithCancel(context.Background())
+	defer engine.cancel()
+
+	engine.wg.Add(1)
+	go engine.runErrorMonitor()
+
+	// A file failing in three passes is one error; each report is received
+	// only after the previous one was counted
+	for i := 1; i <= 3; i++ {
+		engine.reportFileFailure("flaky", fmt.Errorf("attempt %d failed", i), i)
+	}
+	assert.NoError(t, engine.ctx.Err())
+
+	engine.reportFileFailure("other", fmt.Errorf("failed"), 4)
+	assert.Eventually(t, func() bool { return engine.ctx.Err() != nil }, 5*time.Second, 10*time.Millisecond)
+	engine.wg.Wait()
+}
+
+func TestRetriedFileCountsAsOneError(t *testing.T) {
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{
+		"root": {{Id: "flaky", Name: "flaky.txt", MimeType: "text/plain", Size: 1}},
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("file-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 4})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.MaxErrors = 2
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	var attempts atomic.Int32
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		if file.DriveID == "flaky" {
+			attempts.Add(1)
+			return 0, fmt.Errorf("connection reset")
+		}
+		engine.progressTracker.FileProgress(file.ID, file.Size)
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// Three retries and the final failure stay below the limit of two
+	session, err := m.GetSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), attempts.Load())
+	assert.Equal(t, state.SessionStatusFailed, session.Status)
+	assert.Equal(t, int64(5), session.CompletedFiles)
+	assert.Equal(t, int64(1), session.FailedFiles)
+}
+
+func TestConsecutiveFailuresStopSync(t *testing.T) {
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{}
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("file-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 4})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.MaxConcurrent = 1
+	cfg.WorkerConfig.WorkerCount = 1
+	cfg.WorkerConfig.MaxRetries = 0
+	cfg.MaxConsecutiveErrors = 3
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		return 0, fmt.Errorf("disk full")
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	// Far below sync.max_errors, but every file fails
+	session, err := m.GetSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCancelled, session.Status)
+	assert.Less(t, session.FailedFiles, int64(20))
+}
+
+func TestContinueOnErrorsFinishesPastMaxErrors(t *testing.T) {
+	m := newTestStateManager(t)
+
+	children := map[string][]*drive.File{}
+	for i := 0; i < 30; i++ {
+		id := fmt.Sprintf("file-%d", i)
+		children["root"] = append(children["root"], &drive.File{Id: id, Name: id + ".txt", MimeType: "text/plain", Size: 4})
+	}
+
+	log := newTestLogger()
+	cfg := DefaultEngineConfig()
+	cfg.DownloadConfig.TempDir = t.TempDir()
+	cfg.DownloadConfig.MaxConcurrent = 4
+	cfg.WorkerConfig.MaxRetries = 0
+	cfg.MaxErrors = 5
+	cfg.ContinueOnErrors = true
+	engine, err := NewEngine(newFakeDriveClient(t, children, nil), m, errors.NewHandler(log), log, cfg)
+	require.NoError(t, err)
+
+	// Two of every three files fail
+	engine.downloadFunc = func(ctx context.Context, file *state.File) (int64, error) {
+		var n int
+		fmt.Sscanf(file.DriveID, "file-%d", &n)
+		if n%3 != 0 {
+			return 0, fmt.Errorf("disk full")
+		}
+		return file.Size, nil
+	}
+
+	sessionID, err := engine.StartNewSessionWithID(context.Background(), "root", t.TempDir())
+	require.NoError(t, err)
+
+	select {
+	case <-engine.WaitForCompletion():
+	case <-time.After(30 * time.Second):
+		t.Fatal("sync engine did not terminate")
+	}
+
+	session, err := m.GetSession(context.Background(), sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, state.SessionStatusCompletedWithErrors, session.Status)
+	assert.Equal(t, int64(10), session.CompletedFiles)
+	assert.Equal(t, int64(20), session.FailedFiles)
+
+	var logged int
+	require.NoError(t, m.DB().Get(context.Background(), &logged,
+		"SELECT COUNT(DISTINCT item_id) FROM error_log WHERE session_id = $1", sessionID))
+	assert.Equal(t, 20, logged, "every failure is in the error log")
+}
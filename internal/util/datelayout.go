@@ -0,0 +1,92 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// strftimeDirectives maps the strftime directives accepted by
+// ParseDateLayout to Go time layout elements.
+var strftimeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'b': "Jan",
+	'd': "02",
+	'j': "002",
+	'H': "15",
+	'M': "04",
+	'S': "05",
+	'%': "%",
+}
+
+// layoutCheckTimes differ in every date element, so text that time.Format
+// reads as an element does not come out unchanged for both.
+var layoutCheckTimes = []time.Time{
+	time.Date(2001, 2, 3, 4, 5, 6, 7, time.FixedZone("AAA", 3600)),
+	time.Date(2012, 11, 25, 21, 43, 57, 876543210, time.FixedZone("BBB", -(2*3600+30*60))),
+}
+
+// ParseDateLayout returns the Go time layout of layout, which is either a
+// Go layout such as "2006-01-02" or a strftime pattern such as "%Y-%m-%d".
+// Only %Y, %y, %m, %b, %d, %j, %H, %M, %S and %% are understood. Go layouts
+// cannot escape text, so a pattern is refused when its literal text, such as
+// the 1 of "run1-%Y", would be formatted as a date element.
+func ParseDateLayout(layout string) (string, error) {
+	if !strings.Contains(layout, "%") {
+		return layout, nil
+	}
+
+	var b strings.Builder
+	// Each part is formatted on its own to render the pattern as intended
+	var parts []string
+	var literals []bool
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			b.WriteByte(layout[i])
+			parts = append(parts, layout[i:i+1])
+			literals = append(literals, true)
+			continue
+		}
+		if i+1 == len(layout) {
+			return "", fmt.Errorf("invalid date layout %q: trailing %%", layout)
+		}
+		i++
+		element, ok := strftimeDirectives[layout[i]]
+		if !ok {
+			return "", fmt.Errorf("invalid date layout %q: unsupported directive %%%c", layout, layout[i])
+		}
+		b.WriteString(element)
+		parts = append(parts, element)
+		literals = append(literals, layout[i] == '%')
+	}
+
+	goLayout := b.String()
+	for _, t := range layoutCheckTimes {
+		var want strings.Builder
+		for i, part := range parts {
+			if literals[i] {
+				want.WriteString(part)
+			} else {
+				want.WriteString(t.Format(part))
+			}
+		}
+		if t.Format(goLayout) != want.String() {
+			return "", fmt.Errorf("invalid date layout %q: its literal text would be formatted as a date", layout)
+		}
+	}
+	return goLayout, nil
+}
+
+// DateSubdir formats t with the Go layout into a relative directory, such
+// as "2024-06-01" or "2024/06". It fails when the result would leave the
+// directory it is joined to.
+func DateSubdir(layout string, t time.Time) (string, error) {
+	dir := filepath.Clean(filepath.FromSlash(t.Format(layout)))
+	if dir == "." || filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("date layout %q does not give a subdirectory: %q", layout, dir)
+	}
+	return dir, nil
+}
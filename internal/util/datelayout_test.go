@@ -0,0 +1,50 @@
+package util
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateLayout(t *testing.T) {
+	tests := map[string]string{
+		"2006-01-02":         "2006-01-02",
+		"%Y-%m-%d":           "2006-01-02",
+		"backup-%y%m%d_%H%M": "backup-060102_1504",
+		"%Y/%b":              "2006/Jan",
+		"%Y%%":               "2006%",
+		"backup/run_%j":      "backup/run_002",
+	}
+
+	for input, expected := range tests {
+		layout, err := ParseDateLayout(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, layout, input)
+	}
+
+	// Go would format literal text such as 1, 2, Jan or PM as a date
+	for _, input := range []string{"%Y-%q", "%Y%", "100%%", "run1-%Y", "%Y-Jan", "%H-PM", "%Y2"} {
+		_, err := ParseDateLayout(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDateSubdir(t *testing.T) {
+	day := time.Date(2024, 6, 1, 9, 30, 0, 0, time.UTC)
+
+	dir, err := DateSubdir("2006-01-02", day)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06-01", dir)
+
+	dir, err = DateSubdir("2006/01", day)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("2024", "06"), dir)
+
+	for _, layout := range []string{"/2006", "../2006", ".."} {
+		_, err := DateSubdir(layout, day)
+		assert.Error(t, err, layout)
+	}
+}
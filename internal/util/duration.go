@@ -0,0 +1,108 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day   = 24 * time.Hour
+	week  = 7 * day
+	month = 30 * day
+)
+
+// durationUnits are the units accepted by ParseDuration, longest first so
+// "mo" and "ms" are not read as minutes.
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"mo", month},
+	{"ms", time.Millisecond},
+	{"us", time.Microsecond},
+	{"µs", time.Microsecond},
+	{"ns", time.Nanosecond},
+	{"w", week},
+	{"d", day},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+}
+
+// ParseDuration parses a duration such as "30d", "2w", "1mo" or "1d12h".
+// Besides the units of time.ParseDuration it accepts d (24h), w (7 days)
+// and mo (30 days).
+func ParseDuration(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "0" {
+		return 0, nil
+	}
+
+	var total time.Duration
+	for s != "" {
+		end := strings.IndexFunc(s, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if end <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		n, err := strconv.ParseFloat(s[:end], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		s = s[end:]
+
+		matched := false
+		for _, u := range durationUnits {
+			if rest, ok := strings.CutPrefix(s, u.suffix); ok {
+				total += time.Duration(n * float64(u.unit))
+				s = rest
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit, use s, m, h, d, w or mo", value)
+		}
+	}
+
+	if negative {
+		total = -total
+	}
+	return total, nil
+}
+
+// DurationValue is a command line flag holding a duration parsed with
+// ParseDuration.
+type DurationValue time.Duration
+
+// Set parses value into the flag.
+func (d *DurationValue) Set(value string) error {
+	parsed, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = DurationValue(parsed)
+	return nil
+}
+
+// String returns the duration in the format of time.Duration.
+func (d *DurationValue) String() string {
+	return time.Duration(*d).String()
+}
+
+// Type names the flag type in help output.
+func (d *DurationValue) Type() string {
+	return "duration"
+}
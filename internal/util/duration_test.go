@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+	}{
+		{input: "30d", expected: 720 * time.Hour},
+		{input: "2w", expected: 336 * time.Hour},
+		{input: "1mo", expected: 720 * time.Hour},
+		{input: "12h", expected: 12 * time.Hour},
+		{input: "90m", expected: 90 * time.Minute},
+		{input: "10s", expected: 10 * time.Second},
+		{input: "500ms", expected: 500 * time.Millisecond},
+		{input: "1d12h", expected: 36 * time.Hour},
+		{input: "1.5d", expected: 36 * time.Hour},
+		{input: " 7d ", expected: 168 * time.Hour},
+		{input: "0", expected: 0},
+		{input: "-1w", expected: -168 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := ParseDuration(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseDurationRejectsInvalidInput(t *testing.T) {
+	for _, input := range []string{"", "d", "30", "30x", "3 days", "1y", "mo"} {
+		_, err := ParseDuration(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDurationValueSet(t *testing.T) {
+	value := DurationValue(time.Minute)
+	assert.Equal(t, "1m0s", value.String())
+
+	require.NoError(t, value.Set("2w"))
+	assert.Equal(t, 336*time.Hour, time.Duration(value))
+
+	assert.Error(t, value.Set("soon"))
+	assert.Equal(t, 336*time.Hour, time.Duration(value), "unchanged after an invalid value")
+}
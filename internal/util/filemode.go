@@ -0,0 +1,10 @@
+package util
+
+import "os"
+
+// Default permissions of downloaded files and of the directories created
+// for them, before the process umask is applied.
+const (
+	DefaultFileMode os.FileMode = 0644
+	DefaultDirMode  os.FileMode = 0750
+)
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package util
+
+import "os"
+
+// SyncDir flushes the entries of directory dir, such as a file renamed into
+// it, to stable storage.
+func SyncDir(dir string) error {
+	d, err := os.Open(dir) // #nosec G304 - dir is a destination directory
+	if err != nil {
+		return err
+	}
+
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
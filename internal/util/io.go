@@ -0,0 +1,45 @@
+package util
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// ContextReader returns a reader that stops with ctx.Err() once ctx is
+// done, so long copies end promptly when an operation is canceled.
+func ContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, reader: r}
+}
+
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+// Read checks the context before every read.
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.reader.Read(p)
+}
+
+// SyncFile flushes the contents of the file at path to stable storage. The
+// file is opened for writing since Windows only flushes writable handles;
+// read-only files are synced through a read handle, which Unix allows.
+func SyncFile(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0) // #nosec G304 - path is a file this process wrote
+	if os.IsPermission(err) {
+		f, err = os.Open(path) // #nosec G304 - path is a file this process wrote
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,24 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncFile(t *testing.T) {
+	dir := t.TempDir()
+
+	writable := filepath.Join(dir, "writable")
+	require.NoError(t, os.WriteFile(writable, []byte("data"), 0600))
+	assert.NoError(t, SyncFile(writable))
+
+	readOnly := filepath.Join(dir, "read-only")
+	require.NoError(t, os.WriteFile(readOnly, []byte("data"), 0400))
+	assert.NoError(t, SyncFile(readOnly))
+
+	assert.Error(t, SyncFile(filepath.Join(dir, "missing")))
+}